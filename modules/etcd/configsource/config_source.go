@@ -16,6 +16,9 @@ package configsource
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"regexp"
 	"strings"
@@ -24,6 +27,7 @@ import (
 
 	internalclient "github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/client"
 	"github.com/codesjoy/yggdrasil/v3/config/source"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"gopkg.in/yaml.v3"
 )
@@ -107,6 +111,12 @@ type configSource struct {
 	watch       bool
 	dialTimeout time.Duration
 
+	// mu guards the etag and revision of the last content read, which let
+	// watch events that change nothing be skipped before parsing.
+	mu       sync.Mutex
+	etag     string
+	revision int64
+
 	closeOnce sync.Once
 	closeCh   chan struct{}
 }
@@ -116,14 +126,12 @@ func (s *configSource) Kind() string { return Kind }
 func (s *configSource) Name() string { return s.name }
 
 func (s *configSource) Read() (source.Data, error) {
-	switch s.cfg.Mode {
-	case ModeBlob:
-		return s.readBlob()
-	case ModeKV:
-		return s.readKV()
-	default:
-		return nil, errors.New("unknown etcd config source mode")
+	resp, err := s.get()
+	if err != nil {
+		return nil, err
 	}
+	s.advance(resp)
+	return s.build(resp), nil
 }
 
 func (s *configSource) Watch() (<-chan source.Data, error) {
@@ -151,11 +159,17 @@ func (s *configSource) Watch() (<-chan source.Data, error) {
 				if !ok || resp.Canceled {
 					return
 				}
-				data, err := s.Read()
+				if s.seen(resp.Header.Revision) {
+					continue
+				}
+				getResp, err := s.get()
 				if err != nil {
 					continue
 				}
-				out <- data
+				if !s.advance(getResp) {
+					continue
+				}
+				out <- s.build(getResp)
 			}
 		}
 	}()
@@ -186,25 +200,54 @@ func (s *configSource) watchOptions() []clientv3.OpOption {
 	return nil
 }
 
-func (s *configSource) readBlob() (source.Data, error) {
+func (s *configSource) get() (*clientv3.GetResponse, error) {
+	var (
+		key  string
+		opts []clientv3.OpOption
+	)
+	switch s.cfg.Mode {
+	case ModeBlob:
+		key = s.cfg.Key
+	case ModeKV:
+		key, opts = s.cfg.Prefix, []clientv3.OpOption{clientv3.WithPrefix()}
+	default:
+		return nil, errors.New("unknown etcd config source mode")
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), s.dialTimeout)
 	defer cancel()
-	resp, err := s.client.Get(ctx, s.cfg.Key)
-	if err != nil {
-		return nil, err
+	return s.client.Get(ctx, key, opts...)
+}
+
+// seen reports whether a watch event at revision is already reflected in
+// the last content read. A zero revision is never treated as seen.
+func (s *configSource) seen(revision int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return revision > 0 && revision <= s.revision
+}
+
+// advance records the revision and etag of resp and reports whether its
+// content differs from the last content read.
+func (s *configSource) advance(resp *clientv3.GetResponse) bool {
+	etag := contentETag(resp.Kvs)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if revision := resp.Header.GetRevision(); revision > s.revision {
+		s.revision = revision
 	}
-	if len(resp.Kvs) == 0 {
-		return source.NewBytesData(nil, s.cfg.Format), nil
+	if etag == s.etag {
+		return false
 	}
-	return source.NewBytesData(resp.Kvs[0].Value, s.cfg.Format), nil
+	s.etag = etag
+	return true
 }
 
-func (s *configSource) readKV() (source.Data, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), s.dialTimeout)
-	defer cancel()
-	resp, err := s.client.Get(ctx, s.cfg.Prefix, clientv3.WithPrefix())
-	if err != nil {
-		return nil, err
+func (s *configSource) build(resp *clientv3.GetResponse) source.Data {
+	if s.cfg.Mode == ModeBlob {
+		if len(resp.Kvs) == 0 {
+			return source.NewBytesData(nil, s.cfg.Format)
+		}
+		return source.NewBytesData(resp.Kvs[0].Value, s.cfg.Format)
 	}
 
 	out := map[string]any{}
@@ -217,7 +260,22 @@ func (s *configSource) readKV() (source.Data, error) {
 		parts := splitConfigPath(strings.ReplaceAll(rel, "/", "."), ".")
 		setNested(out, parts, parseScalarOrDoc(item.Value, s.cfg.Format))
 	}
-	return source.NewMapData(out), nil
+	return source.NewMapData(out)
+}
+
+// contentETag digests the keys and values of kvs. It ignores revisions, so
+// rewriting a key with the same value keeps the etag.
+func contentETag(kvs []*mvccpb.KeyValue) string {
+	h := sha256.New()
+	var size [8]byte
+	for _, kv := range kvs {
+		for _, field := range [][]byte{kv.Key, kv.Value} {
+			binary.BigEndian.PutUint64(size[:], uint64(len(field)))
+			h.Write(size[:])
+			h.Write(field)
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
 }
 
 func parseScalarOrDoc(data []byte, parser source.Parser) any {
@@ -156,4 +156,26 @@ func TestConfigSourceWatchIntegration(t *testing.T) {
 	case <-time.After(5 * time.Second):
 		t.Fatal("timeout waiting for watch event")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cli.Put(ctx, "/test/config/watch", "updated"); err != nil {
+		t.Fatalf("Put() same value error = %v", err)
+	}
+	select {
+	case data := <-ch:
+		t.Fatalf("rewriting the same value emitted %q", string(data.Bytes()))
+	case <-time.After(300 * time.Millisecond):
+	}
+	if _, err := cli.Put(ctx, "/test/config/watch", "changed"); err != nil {
+		t.Fatalf("Put() new value error = %v", err)
+	}
+	select {
+	case data := <-ch:
+		if string(data.Bytes()) != "changed" {
+			t.Fatalf("expected changed, got %q", string(data.Bytes()))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for changed value")
+	}
 }
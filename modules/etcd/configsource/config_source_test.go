@@ -19,11 +19,13 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/testutil"
 	"github.com/codesjoy/yggdrasil/v3/config/source"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"gopkg.in/yaml.v3"
 )
@@ -224,6 +226,69 @@ func TestConfigSourceWatch(t *testing.T) {
 	}
 }
 
+func TestConfigSourceWatchSkipsUnchangedContent(t *testing.T) {
+	watchCh := make(chan clientv3.WatchResponse, 4)
+	defer close(watchCh)
+
+	var (
+		mu    sync.Mutex
+		gets  int
+		value = "foo: bar"
+	)
+	s := &configSource{
+		cfg: Config{Mode: ModeBlob, Key: "/blob", Format: yaml.Unmarshal},
+		client: &testutil.FakeClient{
+			WatchFunc: func(context.Context, string, ...clientv3.OpOption) clientv3.WatchChan { return watchCh },
+			GetFunc: func(context.Context, string, ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				gets++
+				return testutil.GetResp(int64(gets)+1, testutil.KV("/blob", value)), nil
+			},
+		},
+		watch:       true,
+		dialTimeout: time.Second,
+		closeCh:     make(chan struct{}),
+	}
+	defer s.Close() //nolint:errcheck
+
+	if _, err := s.Read(); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	ch, err := s.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	watchCh <- watchResp(2)
+	watchCh <- watchResp(3)
+	mustNotReceiveSourceData(t, ch)
+	mu.Lock()
+	if gets != 2 {
+		t.Fatalf("Get calls = %d, want 2: the revision already read must not refetch", gets)
+	}
+	value = "foo: baz"
+	mu.Unlock()
+
+	watchCh <- watchResp(4)
+	select {
+	case data := <-ch:
+		var out map[string]any
+		if err := data.Unmarshal(&out); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if out["foo"] != "baz" {
+			t.Fatalf("data = %#v, want foo=baz", out)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for changed content")
+	}
+}
+
+func watchResp(revision int64) clientv3.WatchResponse {
+	return clientv3.WatchResponse{Header: etcdserverpb.ResponseHeader{Revision: revision}}
+}
+
 func TestConfigSourceWatchSkipsReadErrorsAndHonorsFlags(t *testing.T) {
 	s := &configSource{watch: false}
 	if _, err := s.Watch(); err == nil || !strings.Contains(err.Error(), "not changeable") {
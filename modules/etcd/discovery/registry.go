@@ -21,6 +21,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +39,14 @@ type RegistryConfig struct {
 	TTL           time.Duration `mapstructure:"ttl"`
 	KeepAlive     *bool         `mapstructure:"keep_alive"`
 	RetryInterval time.Duration `mapstructure:"retry_interval"`
+	// DeleteOnClose deletes every registered key when the registry closes, so
+	// clients stop seeing the instances before their leases expire. It
+	// defaults to true.
+	DeleteOnClose *bool `mapstructure:"delete_on_close"`
+	// CloseTimeout bounds the deletes made by Close. It defaults to 3s.
+	CloseTimeout time.Duration `mapstructure:"close_timeout"`
+	// OnStateChange is invoked when keepalive for a registered key is lost or recovers.
+	OnStateChange func(key string, healthy bool) `mapstructure:"-"`
 }
 
 // Registry is the etcd-backed service registry.
@@ -53,8 +63,16 @@ type Registry struct {
 }
 
 type registryEntry struct {
-	cancel context.CancelFunc
-	lease  clientv3.LeaseID
+	cancel  context.CancelFunc
+	lease   clientv3.LeaseID
+	healthy bool
+	batch   *registryBatch
+}
+
+// registryBatch tracks the keys that share one lease and keepalive loop.
+// Its kvs map is guarded by Registry.mu.
+type registryBatch struct {
+	kvs map[string]string
 }
 
 // NewRegistry creates one etcd-backed registry.
@@ -68,6 +86,9 @@ func NewRegistry(cfg RegistryConfig) (*Registry, error) {
 	if cfg.RetryInterval <= 0 {
 		cfg.RetryInterval = 3 * time.Second
 	}
+	if cfg.CloseTimeout <= 0 {
+		cfg.CloseTimeout = 3 * time.Second
+	}
 	clientCfg := internalclient.LoadConfig(cfg.Client)
 	cli, err := internalclient.New(clientCfg)
 	if err != nil {
@@ -112,16 +133,12 @@ func (r *Registry) Register(ctx context.Context, inst yregistry.Instance) error
 	keepAlive := r.cfg.KeepAlive == nil || *r.cfg.KeepAlive
 
 	r.mu.Lock()
-	ent, ok := r.regs[key]
-	if ok && ent.cancel != nil {
-		ent.cancel()
-		delete(r.regs, key)
-	}
+	r.releaseEntryLocked(key)
 	bgCtx, cancel := context.WithCancel(context.Background())
 	r.regs[key] = registryEntry{cancel: cancel}
 	r.mu.Unlock()
 
-	if err := r.putOnce(ctx, key, value); err != nil {
+	if err := r.putOnce(ctx, map[string]string{key: value}); err != nil {
 		cancel()
 		r.mu.Lock()
 		delete(r.regs, key)
@@ -151,6 +168,68 @@ func (r *Registry) Register(ctx context.Context, inst yregistry.Instance) error
 	return nil
 }
 
+// RegisterBatch adds several service instances to etcd under one shared lease.
+// The batch is written in a single transaction and kept alive by one loop.
+func (r *Registry) RegisterBatch(ctx context.Context, insts []yregistry.Instance) error {
+	if len(insts) == 0 {
+		return errors.New("empty instance batch")
+	}
+	batch := &registryBatch{kvs: make(map[string]string, len(insts))}
+	for _, inst := range insts {
+		if inst == nil {
+			return errors.New("nil instance")
+		}
+		key, value, err := r.buildKeyValue(inst)
+		if err != nil {
+			return err
+		}
+		batch.kvs[key] = value
+	}
+	kvs := maps.Clone(batch.kvs)
+	keepAlive := r.cfg.KeepAlive == nil || *r.cfg.KeepAlive
+
+	r.mu.Lock()
+	bgCtx, cancel := context.WithCancel(context.Background())
+	for key := range kvs {
+		r.releaseEntryLocked(key)
+		r.regs[key] = registryEntry{cancel: cancel, batch: batch}
+	}
+	r.mu.Unlock()
+
+	if err := r.putOnce(ctx, kvs); err != nil {
+		cancel()
+		r.mu.Lock()
+		r.dropBatchLocked(batch)
+		r.mu.Unlock()
+		return err
+	}
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			r.dropBatchLocked(batch)
+			r.mu.Unlock()
+		}()
+
+		if keepAlive {
+			r.keepAliveBatchLoop(bgCtx, func() map[string]string {
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				return maps.Clone(batch.kvs)
+			})
+			return
+		}
+
+		select {
+		case <-r.close:
+			cancel()
+		case <-bgCtx.Done():
+		}
+	}()
+
+	return nil
+}
+
 // Deregister removes one service instance from etcd.
 func (r *Registry) Deregister(ctx context.Context, inst yregistry.Instance) error {
 	if inst == nil {
@@ -162,54 +241,233 @@ func (r *Registry) Deregister(ctx context.Context, inst yregistry.Instance) erro
 	}
 
 	r.mu.Lock()
-	ent, ok := r.regs[key]
-	if ok && ent.cancel != nil {
-		ent.cancel()
-		delete(r.regs, key)
-	}
+	r.releaseEntryLocked(key)
 	r.mu.Unlock()
 
 	_, err = r.client.Delete(ctx, key)
 	return err
 }
 
-// Close stops all outstanding keepalive loops and closes the etcd client.
+// DeregisterBatch removes instances registered with RegisterBatch by revoking
+// their shared lease. Every instance sharing that lease is removed with it.
+func (r *Registry) DeregisterBatch(ctx context.Context, insts []yregistry.Instance) error {
+	leases := make(map[clientv3.LeaseID]struct{})
+	revoked := make(map[string]struct{})
+	var standalone []string
+
+	r.mu.Lock()
+	for _, inst := range insts {
+		if inst == nil {
+			r.mu.Unlock()
+			return errors.New("nil instance")
+		}
+		key, _, err := r.buildKeyValue(inst)
+		if err != nil {
+			r.mu.Unlock()
+			return err
+		}
+		if _, ok := revoked[key]; ok {
+			continue
+		}
+		ent, ok := r.regs[key]
+		if !ok || ent.batch == nil || ent.lease == 0 {
+			r.releaseEntryLocked(key)
+			standalone = append(standalone, key)
+			continue
+		}
+		leases[ent.lease] = struct{}{}
+		for batchKey := range ent.batch.kvs {
+			revoked[batchKey] = struct{}{}
+		}
+		r.dropBatchLocked(ent.batch)
+		if ent.cancel != nil {
+			ent.cancel()
+		}
+	}
+	r.mu.Unlock()
+
+	var errs error
+	for lease := range leases {
+		if _, err := r.client.Revoke(ctx, lease); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	for _, key := range standalone {
+		if _, err := r.client.Delete(ctx, key); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// releaseEntryLocked forgets one key and stops its keepalive loop once no other
+// batch member depends on it.
+func (r *Registry) releaseEntryLocked(key string) {
+	ent, ok := r.regs[key]
+	if !ok {
+		return
+	}
+	delete(r.regs, key)
+	if ent.batch != nil {
+		delete(ent.batch.kvs, key)
+		if len(ent.batch.kvs) > 0 {
+			return
+		}
+	}
+	if ent.cancel != nil {
+		ent.cancel()
+	}
+}
+
+func (r *Registry) dropBatchLocked(batch *registryBatch) {
+	for key := range batch.kvs {
+		if ent, ok := r.regs[key]; ok && ent.batch == batch {
+			delete(r.regs, key)
+		}
+	}
+	batch.kvs = map[string]string{}
+}
+
+// Close stops all outstanding keepalive loops, deletes the registered keys
+// unless DeleteOnClose is false, and closes the etcd client.
 func (r *Registry) Close() error {
+	var err error
 	r.once.Do(func() {
 		close(r.close)
 		r.mu.Lock()
-		for _, ent := range r.regs {
+		keys := make([]string, 0, len(r.regs))
+		for key, ent := range r.regs {
+			keys = append(keys, key)
 			if ent.cancel != nil {
 				ent.cancel()
 			}
 		}
 		r.regs = map[string]registryEntry{}
 		r.mu.Unlock()
-		if r.client != nil {
-			_ = r.client.Close()
+		if r.client == nil {
+			return
 		}
+		if r.cfg.DeleteOnClose == nil || *r.cfg.DeleteOnClose {
+			err = r.deleteKeys(keys)
+		}
+		_ = r.client.Close()
 	})
-	return nil
+	return err
+}
+
+// deleteKeys removes keys within CloseTimeout so they disappear before their
+// leases expire.
+func (r *Registry) deleteKeys(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	timeout := r.cfg.CloseTimeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var errs error
+	for _, key := range keys {
+		if _, err := r.client.Delete(ctx, key); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
 }
 
-func (r *Registry) putOnce(ctx context.Context, key string, value string) error {
+func (r *Registry) putOnce(ctx context.Context, kvs map[string]string) error {
 	resp, err := r.client.Grant(ctx, int64(r.cfg.TTL/time.Second))
 	if err != nil {
 		return err
 	}
-	_, err = r.client.Put(ctx, key, value, clientv3.WithLease(resp.ID))
+	if err := r.putWithLease(ctx, kvs, resp.ID); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	for key := range kvs {
+		ent := r.regs[key]
+		ent.lease = resp.ID
+		ent.healthy = true
+		r.regs[key] = ent
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// putWithLease writes every key under lease, using one transaction for batches.
+func (r *Registry) putWithLease(
+	ctx context.Context,
+	kvs map[string]string,
+	lease clientv3.LeaseID,
+) error {
+	if len(kvs) == 1 {
+		for key, value := range kvs {
+			_, err := r.client.Put(ctx, key, value, clientv3.WithLease(lease))
+			return err
+		}
+	}
+
+	ops := make([]clientv3.Op, 0, len(kvs))
+	for _, key := range slices.Sorted(maps.Keys(kvs)) {
+		ops = append(ops, clientv3.OpPut(key, kvs[key], clientv3.WithLease(lease)))
+	}
+	resp, err := r.client.Txn(ctx).Then(ops...).Commit()
 	if err != nil {
 		return err
 	}
+	if !resp.Succeeded {
+		return errors.New("batch registration transaction failed")
+	}
+	return nil
+}
+
+// Healthy reports whether the registration of inst currently holds a live
+// lease. inst is matched the same way Register and Deregister match it.
+func (r *Registry) Healthy(inst yregistry.Instance) bool {
+	if inst == nil {
+		return false
+	}
+	key, _, err := r.buildKeyValue(inst)
+	if err != nil {
+		return false
+	}
 	r.mu.Lock()
-	ent := r.regs[key]
-	ent.lease = resp.ID
+	defer r.mu.Unlock()
+	return r.regs[key].healthy
+}
+
+func (r *Registry) setHealthy(key string, healthy bool) {
+	r.mu.Lock()
+	ent, ok := r.regs[key]
+	if !ok || ent.healthy == healthy {
+		r.mu.Unlock()
+		return
+	}
+	ent.healthy = healthy
 	r.regs[key] = ent
 	r.mu.Unlock()
-	return nil
+
+	if r.cfg.OnStateChange != nil {
+		r.cfg.OnStateChange(key, healthy)
+	}
+}
+
+func (r *Registry) setBatchHealthy(kvs map[string]string, healthy bool) {
+	for key := range kvs {
+		r.setHealthy(key, healthy)
+	}
 }
 
 func (r *Registry) keepAliveLoop(ctx context.Context, key string, value string) {
+	r.keepAliveBatchLoop(ctx, func() map[string]string {
+		return map[string]string{key: value}
+	})
+}
+
+// keepAliveBatchLoop keeps one lease alive for the keys returned by current,
+// re-granting and re-writing them whenever the lease is lost.
+func (r *Registry) keepAliveBatchLoop(ctx context.Context, current func() map[string]string) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -219,8 +477,14 @@ func (r *Registry) keepAliveLoop(ctx context.Context, key string, value string)
 		default:
 		}
 
+		kvs := current()
+		if len(kvs) == 0 {
+			return
+		}
+
 		resp, err := r.client.Grant(ctx, int64(r.cfg.TTL/time.Second))
 		if err != nil {
+			r.setBatchHealthy(kvs, false)
 			if !r.waitRetry(ctx) {
 				return
 			}
@@ -229,14 +493,15 @@ func (r *Registry) keepAliveLoop(ctx context.Context, key string, value string)
 
 		keepaliveCh, keepaliveErr := r.client.KeepAlive(ctx, resp.ID)
 		if keepaliveErr != nil {
+			r.setBatchHealthy(kvs, false)
 			if !r.waitRetry(ctx) {
 				return
 			}
 			continue
 		}
 
-		_, err = r.client.Put(ctx, key, value, clientv3.WithLease(resp.ID))
-		if err != nil {
+		if err := r.putWithLease(ctx, kvs, resp.ID); err != nil {
+			r.setBatchHealthy(kvs, false)
 			if !r.waitRetry(ctx) {
 				return
 			}
@@ -244,10 +509,13 @@ func (r *Registry) keepAliveLoop(ctx context.Context, key string, value string)
 		}
 
 		r.mu.Lock()
-		ent := r.regs[key]
-		ent.lease = resp.ID
-		r.regs[key] = ent
+		for key := range kvs {
+			ent := r.regs[key]
+			ent.lease = resp.ID
+			r.regs[key] = ent
+		}
 		r.mu.Unlock()
+		r.setBatchHealthy(kvs, true)
 
 	LoopKeepAlive:
 		for {
@@ -265,6 +533,7 @@ func (r *Registry) keepAliveLoop(ctx context.Context, key string, value string)
 				}
 			}
 		}
+		r.setBatchHealthy(kvs, false)
 
 		select {
 		case <-ctx.Done():
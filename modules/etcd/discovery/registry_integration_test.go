@@ -130,3 +130,48 @@ func TestRegistryKeepAlive(t *testing.T) {
 		t.Fatalf("expected key still exists, got %d", len(resp.Kvs))
 	}
 }
+
+func TestRegistryCloseDeletesKeysBeforeTTL(t *testing.T) {
+	ee := testutil.NewEmbeddedEtcd(t)
+	testutil.UseClientConfigs(t, map[string]internalclient.Config{
+		internalclient.DefaultClientName: {Endpoints: []string{ee.Endpoint}},
+	})
+
+	reg, err := NewRegistry(RegistryConfig{Prefix: "/yggdrasil/registry", TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	inst := testutil.DemoInstance{
+		NamespaceValue: "default",
+		NameValue:      "svc",
+		VersionValue:   "1.0.0",
+		EndpointsValue: []yregistry.Endpoint{
+			testutil.DemoEndpoint{SchemeValue: "grpc", AddressValue: "127.0.0.1:9000"},
+		},
+	}
+	key, _, err := reg.buildKeyValue(inst)
+	if err != nil {
+		t.Fatalf("buildKeyValue() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := reg.Register(ctx, inst); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := reg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	cli, err := internalclient.New(internalclient.Config{Endpoints: []string{ee.Endpoint}})
+	if err != nil {
+		t.Fatalf("client New() error = %v", err)
+	}
+	defer func() { _ = cli.Close() }()
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(resp.Kvs) != 0 {
+		t.Fatalf("expected key removed on Close, got %d", len(resp.Kvs))
+	}
+}
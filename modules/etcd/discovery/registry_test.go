@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -44,6 +45,9 @@ func TestNewRegistryDefaults(t *testing.T) {
 	if reg.cfg.RetryInterval != 3*time.Second {
 		t.Fatalf("retryInterval = %v, want 3s", reg.cfg.RetryInterval)
 	}
+	if reg.cfg.CloseTimeout != 3*time.Second {
+		t.Fatalf("closeTimeout = %v, want 3s", reg.cfg.CloseTimeout)
+	}
 }
 
 func TestRegistryBuildKeyValue(t *testing.T) {
@@ -160,6 +164,9 @@ func TestRegistryRegisterDeregisterAndClose(t *testing.T) {
 	if reg.regs[key].lease != clientv3.LeaseID(7) {
 		t.Fatalf("lease = %v, want 7", reg.regs[key].lease)
 	}
+	if !reg.Healthy(inst) {
+		t.Fatal("Healthy() = false after Register, want true")
+	}
 
 	if err := reg.Deregister(ctx, nil); err == nil ||
 		!strings.Contains(err.Error(), "nil instance") {
@@ -183,6 +190,74 @@ func TestRegistryRegisterDeregisterAndClose(t *testing.T) {
 	}
 }
 
+func TestRegistryCloseDeletesRegisteredKeys(t *testing.T) {
+	inst := testutil.DemoInstance{
+		NamespaceValue: "default",
+		NameValue:      "svc",
+		VersionValue:   "v1",
+		EndpointsValue: []yregistry.Endpoint{
+			testutil.DemoEndpoint{SchemeValue: "grpc", AddressValue: "127.0.0.1:9000"},
+		},
+	}
+	newRegistry := func(deleteOnClose *bool, deleted *[]string) *Registry {
+		var mu sync.Mutex
+		return &Registry{
+			cfg: RegistryConfig{
+				Prefix:        "/yggdrasil/registry",
+				KeepAlive:     testutil.BoolPtr(false),
+				TTL:           10 * time.Second,
+				DeleteOnClose: deleteOnClose,
+			},
+			client: &testutil.FakeClient{
+				GrantFunc: func(context.Context, int64) (*clientv3.LeaseGrantResponse, error) {
+					return &clientv3.LeaseGrantResponse{ID: clientv3.LeaseID(7)}, nil
+				},
+				DeleteFunc: func(
+					ctx context.Context,
+					key string,
+					_ ...clientv3.OpOption,
+				) (*clientv3.DeleteResponse, error) {
+					if _, ok := ctx.Deadline(); !ok {
+						t.Errorf("Delete(%s) context has no deadline", key)
+					}
+					mu.Lock()
+					*deleted = append(*deleted, key)
+					mu.Unlock()
+					return &clientv3.DeleteResponse{}, nil
+				},
+			},
+			regs:  map[string]registryEntry{},
+			close: make(chan struct{}),
+			after: testutil.ImmediateAfter,
+		}
+	}
+
+	var deleted []string
+	reg := newRegistry(nil, &deleted)
+	if err := reg.Register(context.Background(), inst); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	key, _, _ := reg.buildKeyValue(inst)
+	if err := reg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != key {
+		t.Fatalf("deleted keys = %v, want [%s]", deleted, key)
+	}
+
+	var kept []string
+	reg = newRegistry(testutil.BoolPtr(false), &kept)
+	if err := reg.Register(context.Background(), inst); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := reg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(kept) != 0 {
+		t.Fatalf("deleted keys = %v, want none with DeleteOnClose false", kept)
+	}
+}
+
 func TestRegistryRegisterCancelsExistingEntryAndRollsBackOnError(t *testing.T) {
 	ctx := context.Background()
 	inst := testutil.DemoInstance{
@@ -319,3 +394,166 @@ func TestRegistryKeepAliveLoopBranches(t *testing.T) {
 		}
 	})
 }
+
+func TestRegistryKeepAliveLossNotifiesStateChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inst := testutil.DemoInstance{
+		NamespaceValue: "default",
+		NameValue:      "svc",
+		EndpointsValue: []yregistry.Endpoint{
+			testutil.DemoEndpoint{SchemeValue: "grpc", AddressValue: "127.0.0.1:9000"},
+		},
+	}
+	keepaliveCh := make(chan *clientv3.LeaseKeepAliveResponse)
+	type stateChange struct {
+		key     string
+		healthy bool
+	}
+	changes := make(chan stateChange, 4)
+	reg := &Registry{
+		cfg: RegistryConfig{
+			Prefix:        "/yggdrasil/registry",
+			TTL:           time.Second,
+			RetryInterval: time.Millisecond,
+			OnStateChange: func(key string, healthy bool) {
+				changes <- stateChange{key: key, healthy: healthy}
+			},
+		},
+		client: &testutil.FakeClient{
+			GrantFunc: func(context.Context, int64) (*clientv3.LeaseGrantResponse, error) {
+				return &clientv3.LeaseGrantResponse{ID: 11}, nil
+			},
+			KeepAliveFunc: func(context.Context, clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+				return keepaliveCh, nil
+			},
+		},
+		regs:  map[string]registryEntry{},
+		close: make(chan struct{}),
+		after: func(time.Duration) <-chan time.Time { cancel(); return testutil.ImmediateAfter(0) },
+	}
+
+	key, value, err := reg.buildKeyValue(inst)
+	if err != nil {
+		t.Fatalf("buildKeyValue() error = %v", err)
+	}
+	reg.regs[key] = registryEntry{healthy: true}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reg.keepAliveLoop(ctx, key, value)
+	}()
+
+	close(keepaliveCh)
+	select {
+	case change := <-changes:
+		if change.key != key || change.healthy {
+			t.Fatalf("state change = %+v, want %s unhealthy", change, key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for unhealthy state change")
+	}
+	<-done
+
+	if reg.Healthy(inst) {
+		t.Fatal("Healthy() = true after keepalive loss, want false")
+	}
+	other := inst
+	other.NameValue = "other"
+	if reg.Healthy(other) {
+		t.Fatal("Healthy() = true for unregistered instance, want false")
+	}
+	if reg.Healthy(nil) {
+		t.Fatal("Healthy(nil) = true, want false")
+	}
+}
+
+func TestRegistryRegisterBatchSharesOneLease(t *testing.T) {
+	ctx := context.Background()
+	insts := make([]yregistry.Instance, 0, 3)
+	for _, addr := range []string{"127.0.0.1:9000", "127.0.0.1:9001", "127.0.0.1:9002"} {
+		insts = append(insts, testutil.DemoInstance{
+			NamespaceValue: "default",
+			NameValue:      "svc",
+			VersionValue:   "v1",
+			EndpointsValue: []yregistry.Endpoint{
+				testutil.DemoEndpoint{SchemeValue: "grpc", AddressValue: addr},
+			},
+		})
+	}
+
+	var grants, puts, deletes int32
+	var revoked []clientv3.LeaseID
+	txn := &testutil.FakeTxn{}
+	reg := &Registry{
+		cfg: RegistryConfig{
+			Prefix:    "/yggdrasil/registry",
+			KeepAlive: testutil.BoolPtr(false),
+			TTL:       2 * time.Second,
+		},
+		client: &testutil.FakeClient{
+			GrantFunc: func(context.Context, int64) (*clientv3.LeaseGrantResponse, error) {
+				atomic.AddInt32(&grants, 1)
+				return &clientv3.LeaseGrantResponse{ID: clientv3.LeaseID(21)}, nil
+			},
+			PutFunc: func(context.Context, string, string, ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+				atomic.AddInt32(&puts, 1)
+				return &clientv3.PutResponse{}, nil
+			},
+			DeleteFunc: func(context.Context, string, ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+				atomic.AddInt32(&deletes, 1)
+				return &clientv3.DeleteResponse{}, nil
+			},
+			TxnFunc: func(context.Context) clientv3.Txn { return txn },
+			RevokeFunc: func(_ context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+				revoked = append(revoked, id)
+				return &clientv3.LeaseRevokeResponse{}, nil
+			},
+		},
+		regs:  map[string]registryEntry{},
+		close: make(chan struct{}),
+		after: testutil.ImmediateAfter,
+	}
+	defer func() { _ = reg.Close() }()
+
+	if err := reg.RegisterBatch(ctx, insts); err != nil {
+		t.Fatalf("RegisterBatch() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&grants); got != 1 {
+		t.Fatalf("grant count = %d, want 1", got)
+	}
+	if got := atomic.LoadInt32(&puts); got != 0 {
+		t.Fatalf("single put count = %d, want 0", got)
+	}
+	if len(txn.ThenOps) != 3 {
+		t.Fatalf("txn ops = %d, want 3", len(txn.ThenOps))
+	}
+
+	reg.mu.Lock()
+	for _, inst := range insts {
+		key, _, _ := reg.buildKeyValue(inst)
+		if lease := reg.regs[key].lease; lease != clientv3.LeaseID(21) {
+			reg.mu.Unlock()
+			t.Fatalf("lease for %q = %v, want 21", key, lease)
+		}
+	}
+	reg.mu.Unlock()
+
+	if err := reg.DeregisterBatch(ctx, insts); err != nil {
+		t.Fatalf("DeregisterBatch() error = %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != clientv3.LeaseID(21) {
+		t.Fatalf("revoked leases = %v, want [21]", revoked)
+	}
+	if got := atomic.LoadInt32(&deletes); got != 0 {
+		t.Fatalf("delete count = %d, want 0", got)
+	}
+	reg.mu.Lock()
+	remaining := len(reg.regs)
+	reg.mu.Unlock()
+	if remaining != 0 {
+		t.Fatalf("remaining registrations = %d, want 0", remaining)
+	}
+}
@@ -35,6 +35,9 @@ type ResolverConfig struct {
 	Namespace string        `mapstructure:"namespace"`
 	Protocols []string      `mapstructure:"protocols"`
 	Debounce  time.Duration `mapstructure:"debounce"`
+	// Metadata lists key/value pairs an endpoint must carry, in its own or
+	// its instance's metadata, to be resolved.
+	Metadata map[string]string `mapstructure:"metadata"`
 }
 
 // ResolverConfigLoader loads resolver config for one named resolver.
@@ -291,6 +294,9 @@ func (r *Resolver) fetchState(ctx context.Context, serviceName string) (yresolve
 			for name, value := range endpoint.Metadata {
 				attrs[name] = value
 			}
+			if !matchMetadata(attrs, r.cfg.Metadata) {
+				continue
+			}
 			state.Endpoints = append(state.Endpoints, yresolver.BaseEndpoint{
 				Address:    endpoint.Address,
 				Protocol:   endpoint.Scheme,
@@ -319,6 +325,16 @@ func instanceKey(
 	return fmt.Sprintf("%s/%s/%s/%s/%s", namespace, name, version, scheme, address)
 }
 
+// matchMetadata reports whether attrs carries every required key/value pair.
+func matchMetadata(attrs map[string]any, required map[string]string) bool {
+	for name, want := range required {
+		if got, ok := attrs[name].(string); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
 func toProtocolAllow(list []string) map[string]bool {
 	if len(list) == 0 {
 		list = []string{"grpc", "http"}
@@ -19,6 +19,7 @@ package discovery
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -109,3 +110,66 @@ func TestResolverDelWatch(t *testing.T) {
 	case <-time.After(500 * time.Millisecond):
 	}
 }
+
+func TestResolverMetadataFilterRoundTrip(t *testing.T) {
+	ee := testutil.NewEmbeddedEtcd(t)
+	testutil.UseClientConfigs(t, map[string]internalclient.Config{
+		internalclient.DefaultClientName: {Endpoints: []string{ee.Endpoint}},
+	})
+
+	res, err := NewResolver("test", ResolverConfig{
+		Prefix:    "/yggdrasil/registry",
+		Namespace: "default",
+		Metadata:  map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { _ = res.cli.Close() })
+
+	reg, err := NewRegistry(RegistryConfig{
+		Prefix: "/yggdrasil/registry",
+		TTL:    5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	t.Cleanup(func() { _ = reg.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, env := range []string{"prod", "staging", "prod", "dev"} {
+		inst := testutil.DemoInstance{
+			NamespaceValue: "default",
+			NameValue:      "svc",
+			VersionValue:   fmt.Sprintf("1.0.%d", i),
+			MetadataValue:  map[string]string{"env": env},
+			EndpointsValue: []yregistry.Endpoint{
+				testutil.DemoEndpoint{
+					SchemeValue:  "grpc",
+					AddressValue: fmt.Sprintf("127.0.0.1:%d", 9000+i),
+				},
+			},
+		}
+		if err := reg.Register(ctx, inst); err != nil {
+			t.Fatalf("Register(%s) error = %v", env, err)
+		}
+	}
+
+	watcher := testutil.NewCaptureWatcher(1)
+	if err := res.AddWatch("svc", watcher); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+
+	state := testutil.MustReceiveState(t, watcher.Channel())
+	var got []string
+	for _, ep := range state.GetEndpoints() {
+		if env := ep.GetAttributes()["env"]; env != "prod" {
+			t.Fatalf("endpoint %s has env %v, want prod", ep.GetAddress(), env)
+		}
+		got = append(got, ep.GetAddress())
+	}
+	if fmt.Sprint(got) != "[127.0.0.1:9000 127.0.0.1:9002]" {
+		t.Fatalf("endpoints = %v, want the two prod instances", got)
+	}
+}
@@ -180,6 +180,50 @@ func TestResolverFetchStateErrorAndNamespaceFiltering(t *testing.T) {
 	}
 }
 
+func TestResolverFetchStateFiltersByMetadata(t *testing.T) {
+	prod, _ := json.Marshal(instanceRecord{
+		Name: "svc", Namespace: "default", Metadata: map[string]string{"env": "prod"},
+		Endpoints: []endpointRecord{
+			{Scheme: "grpc", Address: "prod:1"},
+			{Scheme: "grpc", Address: "canary:1", Metadata: map[string]string{"env": "canary"}},
+		},
+	})
+	dev, _ := json.Marshal(instanceRecord{
+		Name: "svc", Namespace: "default", Metadata: map[string]string{"env": "dev"},
+		Endpoints: []endpointRecord{
+			{Scheme: "grpc", Address: "dev:1"},
+			{Scheme: "grpc", Address: "dev-prod:1", Metadata: map[string]string{"env": "prod"}},
+		},
+	})
+	res := &Resolver{
+		cfg: NormalizeConfig(ResolverConfig{
+			Prefix:   "/registry",
+			Metadata: map[string]string{"env": "prod"},
+		}),
+		client: &testutil.FakeClient{
+			GetFunc: func(context.Context, string, ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+				return testutil.GetResp(
+					1,
+					testutil.KV("/registry/default/svc/1", string(prod)),
+					testutil.KV("/registry/default/svc/2", string(dev)),
+				), nil
+			},
+		},
+	}
+
+	state, err := res.fetchState(context.Background(), "svc")
+	if err != nil {
+		t.Fatalf("fetchState() error = %v", err)
+	}
+	var got []string
+	for _, ep := range state.GetEndpoints() {
+		got = append(got, ep.GetAddress())
+	}
+	if fmt.Sprint(got) != "[dev-prod:1 prod:1]" {
+		t.Fatalf("endpoints = %v, want [dev-prod:1 prod:1]", got)
+	}
+}
+
 func TestResolverAddAndDelWatch(t *testing.T) {
 	watchCh := make(chan clientv3.WatchResponse)
 	close(watchCh)
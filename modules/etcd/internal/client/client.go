@@ -34,6 +34,16 @@ type Config struct {
 	DialTimeout time.Duration `mapstructure:"dial_timeout"`
 	Username    string        `mapstructure:"username"`
 	Password    string        `mapstructure:"password"`
+	// AutoSyncInterval refreshes the endpoint list from the cluster members,
+	// so the client follows members that replace the configured ones. Zero
+	// disables syncing.
+	AutoSyncInterval time.Duration `mapstructure:"auto_sync_interval"`
+	// DialKeepAliveTime is how often the client pings the server to check
+	// the transport is alive. Zero disables keepalive pings.
+	DialKeepAliveTime time.Duration `mapstructure:"dial_keep_alive_time"`
+	// DialKeepAliveTimeout is how long the client waits for a keepalive ping
+	// response before closing the transport.
+	DialKeepAliveTimeout time.Duration `mapstructure:"dial_keep_alive_timeout"`
 }
 
 // Client is the minimal etcd client surface used by this module.
@@ -50,7 +60,9 @@ type Client interface {
 		key string,
 		opts ...clientv3.OpOption,
 	) (*clientv3.DeleteResponse, error)
+	Txn(ctx context.Context) clientv3.Txn
 	Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error)
+	Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error)
 	KeepAlive(
 		ctx context.Context,
 		id clientv3.LeaseID,
@@ -108,13 +120,19 @@ func Normalize(cfg Config) Config {
 
 // New creates one real etcd client from the normalized config.
 func New(cfg Config) (*clientv3.Client, error) {
-	cfg = Normalize(cfg)
-	return clientv3.New(clientv3.Config{
-		Endpoints:   cfg.Endpoints,
-		DialTimeout: cfg.DialTimeout,
-		Username:    cfg.Username,
-		Password:    cfg.Password,
-	})
+	return clientv3.New(clientConfig(Normalize(cfg)))
+}
+
+func clientConfig(cfg Config) clientv3.Config {
+	return clientv3.Config{
+		Endpoints:            cfg.Endpoints,
+		AutoSyncInterval:     cfg.AutoSyncInterval,
+		DialTimeout:          cfg.DialTimeout,
+		DialKeepAliveTime:    cfg.DialKeepAliveTime,
+		DialKeepAliveTimeout: cfg.DialKeepAliveTimeout,
+		Username:             cfg.Username,
+		Password:             cfg.Password,
+	}
 }
 
 // Wrap converts one real etcd client into the internal Client interface.
@@ -14,7 +14,10 @@
 
 package client
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestNewDefaults(t *testing.T) {
 	cli, err := New(Config{})
@@ -43,3 +46,24 @@ func TestLoadConfigUsesDefaultName(t *testing.T) {
 		t.Fatalf("LoadConfig() = %#v", cfg)
 	}
 }
+
+func TestClientConfigPassesResilienceSettings(t *testing.T) {
+	cfg := clientConfig(Normalize(Config{
+		AutoSyncInterval:     30 * time.Second,
+		DialKeepAliveTime:    10 * time.Second,
+		DialKeepAliveTimeout: 3 * time.Second,
+	}))
+	if cfg.AutoSyncInterval != 30*time.Second {
+		t.Fatalf("AutoSyncInterval = %v, want 30s", cfg.AutoSyncInterval)
+	}
+	if cfg.DialKeepAliveTime != 10*time.Second || cfg.DialKeepAliveTimeout != 3*time.Second {
+		t.Fatalf(
+			"DialKeepAliveTime, DialKeepAliveTimeout = %v, %v, want 10s, 3s",
+			cfg.DialKeepAliveTime,
+			cfg.DialKeepAliveTimeout,
+		)
+	}
+	if cfg.DialTimeout != 5*time.Second {
+		t.Fatalf("DialTimeout = %v, want the 5s default", cfg.DialTimeout)
+	}
+}
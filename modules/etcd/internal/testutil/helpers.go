@@ -33,7 +33,9 @@ type FakeClient struct {
 	GetFunc       func(context.Context, string, ...clientv3.OpOption) (*clientv3.GetResponse, error)
 	PutFunc       func(context.Context, string, string, ...clientv3.OpOption) (*clientv3.PutResponse, error)
 	DeleteFunc    func(context.Context, string, ...clientv3.OpOption) (*clientv3.DeleteResponse, error)
+	TxnFunc       func(context.Context) clientv3.Txn
 	GrantFunc     func(context.Context, int64) (*clientv3.LeaseGrantResponse, error)
+	RevokeFunc    func(context.Context, clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error)
 	KeepAliveFunc func(context.Context, clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error)
 	WatchFunc     func(context.Context, string, ...clientv3.OpOption) clientv3.WatchChan
 	CloseFunc     func() error
@@ -78,6 +80,14 @@ func (f *FakeClient) Delete(
 	return &clientv3.DeleteResponse{}, nil
 }
 
+// Txn implements the internal etcd client interface.
+func (f *FakeClient) Txn(ctx context.Context) clientv3.Txn {
+	if f.TxnFunc != nil {
+		return f.TxnFunc(ctx)
+	}
+	return &FakeTxn{}
+}
+
 // Grant implements the internal etcd client interface.
 func (f *FakeClient) Grant(
 	ctx context.Context,
@@ -89,6 +99,17 @@ func (f *FakeClient) Grant(
 	return &clientv3.LeaseGrantResponse{}, nil
 }
 
+// Revoke implements the internal etcd client interface.
+func (f *FakeClient) Revoke(
+	ctx context.Context,
+	id clientv3.LeaseID,
+) (*clientv3.LeaseRevokeResponse, error) {
+	if f.RevokeFunc != nil {
+		return f.RevokeFunc(ctx, id)
+	}
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
 // KeepAlive implements the internal etcd client interface.
 func (f *FakeClient) KeepAlive(
 	ctx context.Context,
@@ -124,6 +145,40 @@ func (f *FakeClient) Close() error {
 	return nil
 }
 
+// FakeTxn is a configurable etcd transaction double that records its operations.
+type FakeTxn struct {
+	Cmps       []clientv3.Cmp
+	ThenOps    []clientv3.Op
+	ElseOps    []clientv3.Op
+	CommitFunc func(*FakeTxn) (*clientv3.TxnResponse, error)
+}
+
+// If records the transaction comparisons.
+func (t *FakeTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Cmps = append(t.Cmps, cs...)
+	return t
+}
+
+// Then records the operations applied when the comparisons succeed.
+func (t *FakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ThenOps = append(t.ThenOps, ops...)
+	return t
+}
+
+// Else records the operations applied when the comparisons fail.
+func (t *FakeTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.ElseOps = append(t.ElseOps, ops...)
+	return t
+}
+
+// Commit finishes the transaction.
+func (t *FakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.CommitFunc != nil {
+		return t.CommitFunc(t)
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
 // DemoInstance is a simple registry instance used by tests.
 type DemoInstance struct {
 	NamespaceValue string
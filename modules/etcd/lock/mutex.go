@@ -0,0 +1,103 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lock provides an etcd-backed distributed mutex.
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	internalclient "github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/client"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// ErrLocked is returned by TryLock when another holder has the lock.
+var ErrLocked = concurrency.ErrLocked
+
+// Config configures one distributed mutex.
+type Config struct {
+	// Client names the etcd client under `yggdrasil.etcd.clients`.
+	Client string `mapstructure:"client"`
+	// Key is the lock prefix; holders contend on keys created under it.
+	Key string `mapstructure:"key"`
+	// TTL is the session lease TTL. A crashed holder releases the lock once
+	// its lease expires.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Mutex is a mutual-exclusion lock shared through etcd. Its lifetime is tied
+// to a session lease that is kept alive while the Mutex is open.
+type Mutex struct {
+	cli     *clientv3.Client
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+}
+
+// NewMutex creates one distributed mutex on the named etcd client.
+func NewMutex(cfg Config) (*Mutex, error) {
+	if strings.TrimSpace(cfg.Key) == "" {
+		return nil, errors.New("etcd lock key is required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Second
+	}
+	ttl := max(int(cfg.TTL/time.Second), 1)
+
+	cli, err := internalclient.New(internalclient.LoadConfig(cfg.Client))
+	if err != nil {
+		return nil, err
+	}
+	session, err := concurrency.NewSession(cli, concurrency.WithTTL(ttl))
+	if err != nil {
+		_ = cli.Close()
+		return nil, err
+	}
+	return &Mutex{
+		cli:     cli,
+		session: session,
+		mutex:   concurrency.NewMutex(session, cfg.Key),
+	}, nil
+}
+
+// Lock blocks until the lock is held or ctx is done.
+func (m *Mutex) Lock(ctx context.Context) error {
+	return m.mutex.Lock(ctx)
+}
+
+// TryLock takes the lock if it is free and returns ErrLocked otherwise.
+func (m *Mutex) TryLock(ctx context.Context) error {
+	return m.mutex.TryLock(ctx)
+}
+
+// Unlock releases the lock.
+func (m *Mutex) Unlock(ctx context.Context) error {
+	return m.mutex.Unlock(ctx)
+}
+
+// Done is closed when the session lease is lost, after which the lock is no
+// longer held.
+func (m *Mutex) Done() <-chan struct{} {
+	return m.session.Done()
+}
+
+// Close revokes the session lease, releasing the lock if it is held, and
+// closes the etcd client.
+func (m *Mutex) Close() error {
+	err := m.session.Close()
+	return errors.Join(err, m.cli.Close())
+}
@@ -0,0 +1,130 @@
+//go:build integration
+// +build integration
+
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalclient "github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/client"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/testutil"
+)
+
+func newTestMutex(t *testing.T, key string, ttl time.Duration) *Mutex {
+	t.Helper()
+	m, err := NewMutex(Config{Key: key, TTL: ttl})
+	if err != nil {
+		t.Fatalf("NewMutex() error = %v", err)
+	}
+	return m
+}
+
+func TestMutexMutualExclusion(t *testing.T) {
+	ee := testutil.NewEmbeddedEtcd(t)
+	testutil.UseClientConfigs(t, map[string]internalclient.Config{
+		internalclient.DefaultClientName: {Endpoints: []string{ee.Endpoint}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var holders, maxHolders, acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		m := newTestMutex(t, "/yggdrasil/lock/job", 5*time.Second)
+		t.Cleanup(func() { _ = m.Close() })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if err := m.Lock(ctx); err != nil {
+					t.Errorf("Lock() error = %v", err)
+					return
+				}
+				current := atomic.AddInt32(&holders, 1)
+				for {
+					seen := atomic.LoadInt32(&maxHolders)
+					if current <= seen || atomic.CompareAndSwapInt32(&maxHolders, seen, current) {
+						break
+					}
+				}
+				atomic.AddInt32(&acquired, 1)
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				if err := m.Unlock(ctx); err != nil {
+					t.Errorf("Unlock() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired != 10 {
+		t.Fatalf("acquired = %d, want 10", acquired)
+	}
+	if maxHolders != 1 {
+		t.Fatalf("max concurrent holders = %d, want 1", maxHolders)
+	}
+}
+
+func TestMutexReleasedWhenHolderSessionEnds(t *testing.T) {
+	ee := testutil.NewEmbeddedEtcd(t)
+	testutil.UseClientConfigs(t, map[string]internalclient.Config{
+		internalclient.DefaultClientName: {Endpoints: []string{ee.Endpoint}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	waiter := newTestMutex(t, "/yggdrasil/lock/job", time.Second)
+	t.Cleanup(func() { _ = waiter.Close() })
+
+	// A holder that closes its session releases the lock right away.
+	holder := newTestMutex(t, "/yggdrasil/lock/job", time.Second)
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("holder Lock() error = %v", err)
+	}
+	if err := waiter.TryLock(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryLock() while held error = %v, want ErrLocked", err)
+	}
+	if err := holder.Close(); err != nil {
+		t.Fatalf("holder Close() error = %v", err)
+	}
+	if err := waiter.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock() after holder Close() error = %v", err)
+	}
+	if err := waiter.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+
+	// A crashed holder stops keeping its lease alive; the lock is released
+	// once the lease expires.
+	crashed := newTestMutex(t, "/yggdrasil/lock/job", time.Second)
+	if err := crashed.Lock(ctx); err != nil {
+		t.Fatalf("crashed Lock() error = %v", err)
+	}
+	_ = crashed.cli.Close()
+	if err := waiter.Lock(ctx); err != nil {
+		t.Fatalf("Lock() after holder crash error = %v", err)
+	}
+}
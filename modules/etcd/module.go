@@ -22,6 +22,7 @@ import (
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/configsource"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/discovery"
 	internalclient "github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/client"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/lock"
 	"github.com/codesjoy/yggdrasil/v3"
 	"github.com/codesjoy/yggdrasil/v3/capabilities"
 	"github.com/codesjoy/yggdrasil/v3/config"
@@ -67,6 +68,11 @@ func NewConfigSource(cfg ConfigSourceConfig) (source.Source, error) {
 	return configsource.NewConfigSource(cfg)
 }
 
+// NewMutex creates a distributed mutex on the named etcd client.
+func NewMutex(cfg MutexConfig) (*lock.Mutex, error) {
+	return lock.NewMutex(cfg)
+}
+
 // WithConfigSource registers one programmatic etcd-backed config source layer.
 func WithConfigSource(name string, cfg ConfigSourceConfig) yggdrasil.Option {
 	src, err := NewConfigSource(cfg)
@@ -18,6 +18,7 @@ import (
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/configsource"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/discovery"
 	internalclient "github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/internal/client"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/etcd/v3/lock"
 )
 
 const (
@@ -41,3 +42,6 @@ type RegistryConfig = discovery.RegistryConfig
 
 // ResolverConfig configures the etcd resolver provider.
 type ResolverConfig = discovery.ResolverConfig
+
+// MutexConfig configures one etcd-backed distributed mutex.
+type MutexConfig = lock.Config
@@ -0,0 +1,187 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/codesjoy/yggdrasil/v3/config/source"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// KindComposite is the config source kind for ordered fallback sources.
+const KindComposite = "composite"
+
+type compositeSource struct {
+	name    string
+	sources []source.Source
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewCompositeSource creates a source that resolves each key from the first
+// of sources that has it, so a ConfigMap listed before a baked-in file
+// overrides the file while the file fills the keys the ConfigMap leaves out.
+// Nested maps fall through key by key. A source whose Kubernetes resource does
+// not exist contributes nothing. The composite is watchable when any source
+// is, and re-reads every source when one of them changes. An empty name joins
+// the source names with "|".
+func NewCompositeSource(name string, sources ...source.Source) (source.Source, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no sources to compose")
+	}
+	for i, src := range sources {
+		if src == nil {
+			return nil, fmt.Errorf("nil source at index %d", i)
+		}
+	}
+	if strings.TrimSpace(name) == "" {
+		names := make([]string, 0, len(sources))
+		for _, src := range sources {
+			names = append(names, src.Name())
+		}
+		name = strings.Join(names, "|")
+	}
+	return &compositeSource{
+		name:    name,
+		sources: append([]source.Source(nil), sources...),
+		closeCh: make(chan struct{}),
+	}, nil
+}
+
+func (s *compositeSource) Kind() string { return KindComposite }
+
+func (s *compositeSource) Name() string { return s.name }
+
+func (s *compositeSource) Read() (source.Data, error) {
+	merged := map[string]any{}
+	// Merge from the last source to the first so earlier sources win.
+	for i := len(s.sources) - 1; i >= 0; i-- {
+		src := s.sources[i]
+		data, err := src.Read()
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("source %q: %w", src.Name(), err)
+		}
+		values := map[string]any{}
+		if err := data.Unmarshal(&values); err != nil {
+			return nil, fmt.Errorf("source %q: %w", src.Name(), err)
+		}
+		merged = mergeMaps(merged, values)
+	}
+	return source.NewMapData(merged), nil
+}
+
+func (s *compositeSource) Watch() (<-chan source.Data, error) {
+	var channels []<-chan source.Data
+	for _, src := range s.sources {
+		watchable, ok := src.(source.Watchable)
+		if !ok {
+			continue
+		}
+		// Sources with watching disabled report an error; they are read
+		// again whenever another source changes.
+		ch, err := watchable.Watch()
+		if err != nil || ch == nil {
+			continue
+		}
+		channels = append(channels, ch)
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("no watchable source")
+	}
+
+	changed := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(ch <-chan source.Data) {
+			defer wg.Done()
+			for {
+				select {
+				case <-s.closeCh:
+					return
+				case _, ok := <-ch:
+					if !ok {
+						return
+					}
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}(ch)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	out := make(chan source.Data)
+	go func() {
+		defer close(out)
+
+		var last string
+		for {
+			select {
+			case <-s.closeCh:
+				return
+			case <-done:
+				return
+			case <-changed:
+			}
+
+			payload, err := s.Read()
+			if err != nil {
+				slog.Warn("kubernetes composite source skipped change, read failed",
+					slog.String("source", s.name),
+					slog.Any("error", err))
+				continue
+			}
+			content := string(payload.Bytes())
+			if content == last {
+				continue
+			}
+			last = content
+			select {
+			case out <- payload:
+			case <-s.closeCh:
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close closes the composite and every source in it.
+func (s *compositeSource) Close() error {
+	var errs []error
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+		for _, src := range s.sources {
+			errs = append(errs, src.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
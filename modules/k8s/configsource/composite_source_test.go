@@ -0,0 +1,129 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codesjoy/yggdrasil/v3/config/source"
+	"github.com/codesjoy/yggdrasil/v3/config/source/file"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCompositeSourceConfigMapOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(
+		path,
+		[]byte("app:\n  name: demo\n  level: info\n  port: 8080\n"),
+		0o600,
+	); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	client := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"config.yaml": "app:\n  level: debug\n"},
+	})
+	watchers := make(chan *watch.FakeWatcher, 1)
+	client.PrependWatchReactor(
+		"configmaps",
+		func(k8stesting.Action) (bool, watch.Interface, error) {
+			fw := watch.NewFake()
+			watchers <- fw
+			return true, fw, nil
+		},
+	)
+	configMap, err := NewConfigMapSource(Config{
+		Namespace: "default",
+		Name:      "app",
+		Key:       "config.yaml",
+		Watch:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigMapSource() error = %v", err)
+	}
+	configMap.(*configSource).clientForConfig = func(string) (kubernetes.Interface, error) {
+		return client, nil
+	}
+
+	src, err := NewCompositeSource("", configMap, file.NewSource(path, false))
+	if err != nil {
+		t.Fatalf("NewCompositeSource() error = %v", err)
+	}
+	defer src.Close() //nolint:errcheck
+	if src.Kind() != KindComposite || src.Name() != "app|"+path {
+		t.Fatalf("identity = %q/%q", src.Kind(), src.Name())
+	}
+
+	var got struct {
+		App map[string]any `mapstructure:"app"`
+	}
+	data, err := src.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if err := data.Unmarshal(&got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.App["level"] != "debug" || got.App["name"] != "demo" || got.App["port"] != 8080 {
+		t.Fatalf("app = %#v, want level from the ConfigMap and the rest from the file", got.App)
+	}
+
+	ch, err := src.(source.Watchable).Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	var fw *watch.FakeWatcher
+	select {
+	case fw = <-watchers:
+	case <-time.After(2 * time.Second):
+		t.Fatal("configmap was not watched")
+	}
+
+	updated := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"config.yaml": "app:\n  level: warn\n  port: 9090\n"},
+	}
+	if _, err := client.CoreV1().ConfigMaps("default").Update(
+		context.Background(),
+		updated,
+		metav1.UpdateOptions{},
+	); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	fw.Modify(updated)
+
+	select {
+	case update := <-ch:
+		got.App = nil
+		if err := update.Unmarshal(&got); err != nil {
+			t.Fatalf("watch Unmarshal() error = %v", err)
+		}
+		if got.App["level"] != "warn" || got.App["port"] != 9090 || got.App["name"] != "demo" {
+			t.Fatalf("app after update = %#v, want level=warn port=9090 name=demo", got.App)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for composite watch update")
+	}
+}
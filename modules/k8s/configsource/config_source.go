@@ -16,17 +16,25 @@ package configsource
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/k8s/v3/internal/backoff"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/k8s/v3/internal/kube"
 	"github.com/codesjoy/yggdrasil/v3/config/source"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 )
@@ -42,6 +50,16 @@ const (
 	resourceTypeSecret    = "secret"
 )
 
+// Secret value decoding modes for Config.SecretDecode.
+const (
+	// SecretDecodeUTF8 exposes each secret value as a string.
+	SecretDecodeUTF8 = "utf8"
+	// SecretDecodeBase64 base64-decodes each secret value into a string.
+	SecretDecodeBase64 = "base64"
+	// SecretDecodeRaw exposes each secret value as []byte without conversion.
+	SecretDecodeRaw = "raw"
+)
+
 // Config configures a Kubernetes ConfigMap or Secret source.
 type Config struct {
 	Namespace    string        `mapstructure:"namespace"`
@@ -51,6 +69,57 @@ type Config struct {
 	Format       source.Parser `mapstructure:"format"`
 	Watch        bool          `mapstructure:"watch"`
 	Kubeconfig   string        `mapstructure:"kubeconfig"`
+	// SecretDecode controls how Secret values are decoded: "utf8" (default),
+	// "base64" or "raw". It is ignored for ConfigMaps.
+	SecretDecode string `mapstructure:"secret_decode"`
+	// DebounceInterval coalesces watch updates: the first change opens a
+	// window of this length and only the latest content at its end is
+	// emitted. Zero emits every change immediately.
+	DebounceInterval time.Duration `mapstructure:"debounce_interval"`
+	// Alias names the source instead of the resource name, so several
+	// sources reading same-named resources (for example from different
+	// namespaces) load as distinct config layers.
+	Alias string `mapstructure:"alias"`
+	// Backoff controls the delay before re-establishing a failed watch.
+	// It defaults to a constant 1s, capped at 30s.
+	Backoff BackoffConfig `mapstructure:"backoff"`
+	// ConfigMaps lists the ConfigMaps merged by a kubernetes-configmap-merged
+	// source in place of Name. It is ignored by single-resource sources.
+	ConfigMaps []LayerConfig `mapstructure:"configmaps"`
+}
+
+// VersionedData is the source.Data returned by ConfigMap and Secret sources.
+// ResourceVersion is the resourceVersion of the object the data was read
+// from, so consumers can tell generations apart.
+type VersionedData interface {
+	source.Data
+	ResourceVersion() string
+}
+
+type versionedData struct {
+	source.Data
+	version string
+}
+
+func (d versionedData) ResourceVersion() string { return d.version }
+
+// BackoffConfig configures watch retry timing.
+type BackoffConfig = backoff.Config
+
+func normalizeBackoff(cfg BackoffConfig) BackoffConfig {
+	if cfg.Strategy == "" {
+		cfg.Strategy = backoff.StrategyConstant
+	}
+	if cfg.BaseDelay == 0 {
+		cfg.BaseDelay = time.Second
+	}
+	if cfg.Multiplier == 0 {
+		cfg.Multiplier = 1.6
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = 30 * time.Second
+	}
+	return cfg
 }
 
 type configSource struct {
@@ -59,6 +128,7 @@ type configSource struct {
 	cfg             Config
 	watch           bool
 	clientForConfig func(string) (kubernetes.Interface, error)
+	bo              *backoff.Backoff
 
 	closeOnce sync.Once
 	closeCh   chan struct{}
@@ -69,6 +139,9 @@ func NewConfigMapSource(cfg Config) (source.Source, error) {
 	if strings.TrimSpace(cfg.Name) == "" {
 		return nil, errors.New("empty configmap name")
 	}
+	if err := cfg.Backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid backoff: %w", err)
+	}
 	return newSource(KindConfigMap, resourceTypeConfigMap, cfg), nil
 }
 
@@ -77,32 +150,47 @@ func NewSecretSource(cfg Config) (source.Source, error) {
 	if strings.TrimSpace(cfg.Name) == "" {
 		return nil, errors.New("empty secret name")
 	}
+	switch cfg.SecretDecode {
+	case "", SecretDecodeUTF8, SecretDecodeBase64, SecretDecodeRaw:
+	default:
+		return nil, fmt.Errorf("unsupported secret_decode %q", cfg.SecretDecode)
+	}
+	if err := cfg.Backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid backoff: %w", err)
+	}
 	return newSource(KindSecret, resourceTypeSecret, cfg), nil
 }
 
 func newSource(kind string, resourceType string, cfg Config) *configSource {
 	factory := kube.NewClientFactory()
+	cfg.Backoff = normalizeBackoff(cfg.Backoff)
 	return &configSource{
 		kind:            kind,
 		resourceType:    resourceType,
 		cfg:             cfg,
 		watch:           cfg.Watch,
 		clientForConfig: factory.Client,
+		bo:              backoff.New(cfg.Backoff),
 		closeCh:         make(chan struct{}),
 	}
 }
 
 func (s *configSource) Kind() string { return s.kind }
 
-func (s *configSource) Name() string { return s.cfg.Name }
+func (s *configSource) Name() string {
+	if alias := strings.TrimSpace(s.cfg.Alias); alias != "" {
+		return alias
+	}
+	return s.cfg.Name
+}
 
 func (s *configSource) Read() (source.Data, error) {
-	data, parser, err := s.fetch()
+	data, parser, version, err := s.fetch()
 	if err != nil {
 		return nil, err
 	}
 	if s.cfg.MergeAllKeys {
-		return source.NewMapData(data), nil
+		return versionedData{Data: source.NewMapData(data), version: version}, nil
 	}
 
 	key := s.cfg.Key
@@ -113,14 +201,14 @@ func (s *configSource) Read() (source.Data, error) {
 	if !ok {
 		return nil, fmt.Errorf("key %q not found", key)
 	}
-	str, ok := value.(string)
+	raw, ok := valueBytes(value)
 	if !ok {
 		return nil, fmt.Errorf("key %q is not a string", key)
 	}
 	if parser == nil {
 		parser = inferParser(key)
 	}
-	return source.NewBytesData([]byte(str), parser), nil
+	return versionedData{Data: source.NewBytesData(raw, parser), version: version}, nil
 }
 
 func (s *configSource) Watch() (<-chan source.Data, error) {
@@ -147,38 +235,110 @@ func (s *configSource) Watch() (<-chan source.Data, error) {
 		defer close(out)
 		defer cancel()
 
-		var last string
+		var (
+			last           string
+			lastVersion    string
+			lastETag       string
+			pending        source.Data
+			pendingContent string
+			timer          *time.Timer
+			flush          <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		emit := func() bool {
+			payload, content := pending, pendingContent
+			pending, flush = nil, nil
+			if payload == nil || content == last {
+				return true
+			}
+			last = content
+			select {
+			case out <- payload:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		retries := 0
 		for {
 			ch, err := s.doWatch(ctx, client)
 			if err != nil {
 				if errors.Is(err, context.Canceled) {
 					return
 				}
-				time.Sleep(time.Second)
+				if !s.waitRetry(ctx, retries) {
+					return
+				}
+				retries++
 				continue
 			}
+			retries = 0
 
-			for event := range ch {
-				if event.Type == watch.Deleted {
+		events:
+			for {
+				select {
+				case <-ctx.Done():
 					return
-				}
-				if event.Type != watch.Added && event.Type != watch.Modified {
+				case <-flush:
+					if !emit() {
+						return
+					}
 					continue
+				case event, ok := <-ch:
+					if !ok {
+						break events
+					}
+					if event.Type == watch.Deleted {
+						emit()
+						return
+					}
+					if event.Type != watch.Added && event.Type != watch.Modified {
+						continue
+					}
+					version, etag, ok := objectETag(event.Object)
+					if ok {
+						// resourceVersion is opaque, so only an exact repeat
+						// of the last version counts as seen; any other
+						// unchanged event is caught by the data digest.
+						if (version != "" && version == lastVersion) || etag == lastETag {
+							lastVersion = version
+							continue
+						}
+					}
+
+					data, parser, fetchedVersion, err := s.fetch()
+					if err != nil {
+						continue
+					}
+					payload, content, err := s.payload(data, parser)
+					if err != nil {
+						continue
+					}
+					if ok {
+						lastVersion, lastETag = version, etag
+					}
+					pending = versionedData{Data: payload, version: fetchedVersion}
+					pendingContent = content
+					if s.cfg.DebounceInterval <= 0 {
+						if !emit() {
+							return
+						}
+						continue
+					}
+					if flush == nil {
+						if timer == nil {
+							timer = time.NewTimer(s.cfg.DebounceInterval)
+						} else {
+							timer.Reset(s.cfg.DebounceInterval)
+						}
+						flush = timer.C
+					}
 				}
-
-				data, parser, err := s.fetch()
-				if err != nil {
-					continue
-				}
-				payload, content, err := s.payload(data, parser)
-				if err != nil {
-					continue
-				}
-				if content == last {
-					continue
-				}
-				last = content
-				out <- payload
 			}
 
 			select {
@@ -191,6 +351,19 @@ func (s *configSource) Watch() (<-chan source.Data, error) {
 	return out, nil
 }
 
+// waitRetry sleeps for the backoff delay of the given retry and reports
+// whether ctx is still live afterwards.
+func (s *configSource) waitRetry(ctx context.Context, retry int) bool {
+	timer := time.NewTimer(s.bo.Backoff(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 func (s *configSource) Close() error {
 	s.closeOnce.Do(func() {
 		close(s.closeCh)
@@ -215,25 +388,28 @@ func (s *configSource) payload(
 	if !ok {
 		return nil, "", fmt.Errorf("key %q not found", key)
 	}
-	str, ok := value.(string)
+	raw, ok := valueBytes(value)
 	if !ok {
 		return nil, "", fmt.Errorf("key %q is not a string", key)
 	}
 	if parser == nil {
 		parser = inferParser(key)
 	}
-	return source.NewBytesData([]byte(str), parser), str, nil
+	return source.NewBytesData(raw, parser), string(raw), nil
 }
 
-func (s *configSource) fetch() (map[string]any, source.Parser, error) {
+// fetch reads the resource and returns its values, the parser for the
+// configured key and the resourceVersion it was read at.
+func (s *configSource) fetch() (map[string]any, source.Parser, string, error) {
 	client, err := s.clientForConfig(s.cfg.Kubeconfig)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get kube client: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to get kube client: %w", err)
 	}
 
 	var (
-		data   map[string]any
-		parser source.Parser
+		data    map[string]any
+		parser  source.Parser
+		version string
 	)
 	if s.resourceType == resourceTypeConfigMap {
 		cm, err := client.CoreV1().ConfigMaps(s.cfg.Namespace).Get(
@@ -242,8 +418,9 @@ func (s *configSource) fetch() (map[string]any, source.Parser, error) {
 			metav1.GetOptions{},
 		)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get configmap: %w", err)
+			return nil, nil, "", fmt.Errorf("failed to get configmap: %w", err)
 		}
+		version = cm.ResourceVersion
 		data = make(map[string]any, len(cm.Data))
 		for key, value := range cm.Data {
 			data[key] = value
@@ -255,11 +432,16 @@ func (s *configSource) fetch() (map[string]any, source.Parser, error) {
 			metav1.GetOptions{},
 		)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get secret: %w", err)
+			return nil, nil, "", fmt.Errorf("failed to get secret: %w", err)
 		}
+		version = secret.ResourceVersion
 		data = make(map[string]any, len(secret.Data))
 		for key, value := range secret.Data {
-			data[key] = string(value)
+			decoded, err := decodeSecretValue(s.cfg.SecretDecode, value)
+			if err != nil {
+				return nil, nil, "", fmt.Errorf("failed to decode secret key %q: %w", key, err)
+			}
+			data[key] = decoded
 		}
 	}
 
@@ -268,7 +450,7 @@ func (s *configSource) fetch() (map[string]any, source.Parser, error) {
 	} else if s.cfg.Key != "" {
 		parser = inferParser(s.cfg.Key)
 	}
-	return data, parser, nil
+	return data, parser, version, nil
 }
 
 func (s *configSource) doWatch(
@@ -293,6 +475,75 @@ func (s *configSource) doWatch(
 	return w.ResultChan(), nil
 }
 
+// objectETag returns the resourceVersion of a watched ConfigMap or Secret
+// and a digest of its data, so events that change neither can be skipped
+// before the resource is fetched and parsed.
+func objectETag(obj runtime.Object) (string, string, bool) {
+	var (
+		version string
+		fields  = map[string][]byte{}
+	)
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		version = o.ResourceVersion
+		for key, value := range o.Data {
+			fields["data/"+key] = []byte(value)
+		}
+		for key, value := range o.BinaryData {
+			fields["binaryData/"+key] = value
+		}
+	case *corev1.Secret:
+		version = o.ResourceVersion
+		for key, value := range o.Data {
+			fields[key] = value
+		}
+	default:
+		return "", "", false
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	var size [8]byte
+	for _, key := range keys {
+		for _, field := range [][]byte{[]byte(key), fields[key]} {
+			binary.BigEndian.PutUint64(size[:], uint64(len(field)))
+			h.Write(size[:])
+			h.Write(field)
+		}
+	}
+	return version, "sha256:" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+func decodeSecretValue(mode string, value []byte) (any, error) {
+	switch mode {
+	case SecretDecodeRaw:
+		return append([]byte(nil), value...), nil
+	case SecretDecodeBase64:
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+		if err != nil {
+			return nil, err
+		}
+		return string(decoded), nil
+	default:
+		return string(value), nil
+	}
+}
+
+func valueBytes(value any) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
 func inferParser(key string) source.Parser {
 	ext := strings.ToLower(filepath.Ext(key))
 	switch ext {
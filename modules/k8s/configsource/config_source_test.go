@@ -15,16 +15,21 @@
 package configsource
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/codesjoy/yggdrasil/v3/config"
 	"github.com/codesjoy/yggdrasil/v3/config/source"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -218,6 +223,116 @@ func TestSecretSourceReadMergeAndErrorPaths(t *testing.T) {
 	}
 }
 
+func TestSecretSourceDecodeModes(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0xfe, 0x80, 'k', 0x7f}
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+			Data: map[string][]byte{
+				"config.yaml": []byte("foo: bar"),
+				"cert.der":    binary,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "wrapped", Namespace: "default"},
+			Data: map[string][]byte{
+				"config.yaml": []byte(base64.StdEncoding.EncodeToString([]byte("foo: wrapped"))),
+			},
+		},
+	)
+	newSecret := func(t *testing.T, cfg Config) *configSource {
+		t.Helper()
+		cfg.Namespace = "default"
+		if cfg.Name == "" {
+			cfg.Name = "secret"
+		}
+		raw, err := NewSecretSource(cfg)
+		if err != nil {
+			t.Fatalf("NewSecretSource() error = %v", err)
+		}
+		src := raw.(*configSource)
+		src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+		return src
+	}
+
+	t.Run("utf8 default", func(t *testing.T) {
+		data, err := newSecret(t, Config{Key: "config.yaml"}).Read()
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		var got map[string]any
+		if err := data.Unmarshal(&got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got["foo"] != "bar" {
+			t.Fatalf("foo = %v, want bar", got["foo"])
+		}
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		data, err := newSecret(t, Config{
+			Name:         "wrapped",
+			Key:          "config.yaml",
+			SecretDecode: SecretDecodeBase64,
+		}).Read()
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		var got map[string]any
+		if err := data.Unmarshal(&got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got["foo"] != "wrapped" {
+			t.Fatalf("foo = %v, want wrapped", got["foo"])
+		}
+
+		if _, err := newSecret(t, Config{
+			Key:          "config.yaml",
+			SecretDecode: SecretDecodeBase64,
+		}).Read(); err == nil {
+			t.Fatal("Read() expected error for a value that is not base64")
+		}
+	})
+
+	t.Run("raw binary round-trip", func(t *testing.T) {
+		data, err := newSecret(t, Config{
+			MergeAllKeys: true,
+			SecretDecode: SecretDecodeRaw,
+		}).Read()
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		var got map[string]any
+		if err := data.Unmarshal(&got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		value, ok := got["cert.der"].([]byte)
+		if !ok {
+			t.Fatalf("cert.der = %T, want []byte", got["cert.der"])
+		}
+		if !bytes.Equal(value, binary) {
+			t.Fatalf("cert.der = %x, want %x", value, binary)
+		}
+
+		single, err := newSecret(t, Config{
+			Key:          "cert.der",
+			SecretDecode: SecretDecodeRaw,
+		}).Read()
+		if err != nil {
+			t.Fatalf("Read() single key error = %v", err)
+		}
+		if !bytes.Equal(single.Bytes(), binary) {
+			t.Fatalf("Bytes() = %x, want %x", single.Bytes(), binary)
+		}
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		if _, err := NewSecretSource(Config{Name: "secret", SecretDecode: "hex"}); err == nil {
+			t.Fatal("NewSecretSource() expected unsupported secret_decode error")
+		}
+	})
+}
+
 func TestConfigSourceConstructorsAndIdentity(t *testing.T) {
 	if _, err := NewConfigMapSource(Config{}); err == nil {
 		t.Fatal("NewConfigMapSource() expected empty name error")
@@ -225,12 +340,21 @@ func TestConfigSourceConstructorsAndIdentity(t *testing.T) {
 	if _, err := NewSecretSource(Config{}); err == nil {
 		t.Fatal("NewSecretSource() expected empty name error")
 	}
+	if _, err := NewConfigMapSource(Config{
+		Name:    "app",
+		Backoff: BackoffConfig{Strategy: "fibonacci"},
+	}); err == nil {
+		t.Fatal("NewConfigMapSource() expected unsupported backoff strategy error")
+	}
 
 	rawConfigMap, err := NewConfigMapSource(Config{Name: "app"})
 	if err != nil {
 		t.Fatalf("NewConfigMapSource() error = %v", err)
 	}
 	configMapSource := rawConfigMap.(*configSource)
+	if got := configMapSource.bo.Backoff(5); got != time.Second {
+		t.Fatalf("default watch backoff = %v, want constant 1s", got)
+	}
 	if configMapSource.Kind() != KindConfigMap {
 		t.Fatalf("Kind() = %q, want %q", configMapSource.Kind(), KindConfigMap)
 	}
@@ -383,7 +507,7 @@ func TestConfigSourceFetchAndDoWatchBranches(t *testing.T) {
 	configMapSource.clientForConfig = func(string) (kubernetes.Interface, error) {
 		return k8sfake.NewSimpleClientset(), nil
 	}
-	if _, _, err := configMapSource.fetch(); err == nil {
+	if _, _, _, err := configMapSource.fetch(); err == nil {
 		t.Fatal("fetch() expected missing configmap error")
 	}
 
@@ -398,7 +522,7 @@ func TestConfigSourceFetchAndDoWatchBranches(t *testing.T) {
 	secretSource.clientForConfig = func(string) (kubernetes.Interface, error) {
 		return k8sfake.NewSimpleClientset(), nil
 	}
-	if _, _, err := secretSource.fetch(); err == nil {
+	if _, _, _, err := secretSource.fetch(); err == nil {
 		t.Fatal("fetch() expected missing secret error")
 	}
 
@@ -540,6 +664,373 @@ func TestConfigSourceWatchAddedDeduplicatesAndClientErrors(t *testing.T) {
 	})
 }
 
+func TestConfigSourceWatchSkipsNoOpUpdates(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"config.yaml": "foo: bar"},
+	})
+	var gets atomic.Int32
+	client.PrependReactor(
+		"get",
+		"configmaps",
+		func(k8stesting.Action) (bool, runtime.Object, error) {
+			gets.Add(1)
+			return false, nil, nil
+		},
+	)
+	fw := watch.NewFake()
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			return true, fw, nil
+		},
+	)
+
+	raw, err := NewConfigMapSource(Config{
+		Namespace: "default",
+		Name:      "app",
+		Key:       "config.yaml",
+		Watch:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigMapSource() error = %v", err)
+	}
+	src := raw.(*configSource)
+	src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer src.Close() //nolint:errcheck
+
+	ch, err := src.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	configMap := func(version, content string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "app",
+				Namespace:       "default",
+				ResourceVersion: version,
+			},
+			Data: map[string]string{"config.yaml": content},
+		}
+	}
+
+	fw.Add(configMap("1", "foo: bar"))
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for initial watch update")
+	}
+	fetched := gets.Load()
+
+	fw.Modify(configMap("2", "foo: bar"))
+	fw.Modify(configMap("2", "foo: bar"))
+	select {
+	case update := <-ch:
+		t.Fatalf("no-op update triggered a reload: %q", string(update.Bytes()))
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := gets.Load(); got != fetched {
+		t.Fatalf("configmap fetched %d times for no-op updates, want 0", got-fetched)
+	}
+
+	updated := configMap("3", "foo: baz")
+	if _, err := client.CoreV1().ConfigMaps("default").Update(
+		context.Background(),
+		updated,
+		metav1.UpdateOptions{},
+	); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	fw.Modify(updated)
+	select {
+	case update := <-ch:
+		if got := string(update.Bytes()); got != "foo: baz" {
+			t.Fatalf("update = %q, want foo: baz", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for changed content")
+	}
+}
+
+func TestConfigSourceWatchSkipsRepeatedVersions(t *testing.T) {
+	configMap := func(version, content string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "app",
+				Namespace:       "default",
+				ResourceVersion: version,
+			},
+			Data: map[string]string{"config.yaml": content},
+		}
+	}
+	client := k8sfake.NewSimpleClientset(configMap("5", "foo: bar"))
+	var gets atomic.Int32
+	client.PrependReactor(
+		"get",
+		"configmaps",
+		func(k8stesting.Action) (bool, runtime.Object, error) {
+			gets.Add(1)
+			return false, nil, nil
+		},
+	)
+	fw := watch.NewFake()
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			return true, fw, nil
+		},
+	)
+	raw, err := NewConfigMapSource(Config{
+		Namespace: "default",
+		Name:      "app",
+		Key:       "config.yaml",
+		Watch:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigMapSource() error = %v", err)
+	}
+	src := raw.(*configSource)
+	src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer src.Close() //nolint:errcheck
+
+	data, err := src.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := data.(VersionedData).ResourceVersion(); got != "5" {
+		t.Fatalf("Read() resourceVersion = %q, want 5", got)
+	}
+
+	ch, err := src.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	fw.Add(configMap("5", "foo: bar"))
+	select {
+	case update := <-ch:
+		if got := update.(VersionedData).ResourceVersion(); got != "5" {
+			t.Fatalf("watch resourceVersion = %q, want 5", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for initial watch update")
+	}
+	fetched := gets.Load()
+
+	fw.Modify(configMap("5", "foo: bar"))
+	fw.Modify(configMap("5", "foo: bar"))
+	select {
+	case update := <-ch:
+		t.Fatalf("repeated version triggered a reload: %q", string(update.Bytes()))
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := gets.Load(); got != fetched {
+		t.Fatalf("configmap fetched %d times for repeated versions, want 0", got-fetched)
+	}
+
+	// resourceVersion is opaque: a lexically or numerically smaller version
+	// with new data is still a change and must be reloaded.
+	updated := configMap("10a", "foo: baz")
+	if _, err := client.CoreV1().ConfigMaps("default").Update(
+		context.Background(),
+		updated,
+		metav1.UpdateOptions{},
+	); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	fw.Modify(updated)
+	select {
+	case update := <-ch:
+		if got := string(update.Bytes()); got != "foo: baz" {
+			t.Fatalf("update = %q, want foo: baz", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for changed content")
+	}
+}
+
+func TestConfigSourceWatchDebouncesRapidUpdates(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data: map[string]string{
+			"config.yaml": "foo: v0",
+		},
+	})
+	fw := watch.NewFake()
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			return true, fw, nil
+		},
+	)
+
+	raw, err := NewConfigMapSource(Config{
+		Namespace:        "default",
+		Name:             "app",
+		Key:              "config.yaml",
+		Watch:            true,
+		DebounceInterval: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigMapSource() error = %v", err)
+	}
+	src := raw.(*configSource)
+	src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer src.Close() //nolint:errcheck
+
+	ch, err := src.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	for _, value := range []string{"v1", "v2", "v3"} {
+		cm, err := client.CoreV1().
+			ConfigMaps("default").
+			Get(context.Background(), "app", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		cm.Data["config.yaml"] = "foo: " + value
+		if _, err := client.CoreV1().ConfigMaps("default").Update(
+			context.Background(),
+			cm,
+			metav1.UpdateOptions{},
+		); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		fw.Modify(cm)
+	}
+
+	select {
+	case update := <-ch:
+		var got map[string]any
+		if err := update.Unmarshal(&got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got["foo"] != "v3" {
+			t.Fatalf("foo = %v, want v3", got["foo"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for debounced update")
+	}
+
+	select {
+	case extra := <-ch:
+		var got map[string]any
+		_ = extra.Unmarshal(&got)
+		t.Fatalf("received extra update after debounce: %#v", got)
+	case <-time.After(400 * time.Millisecond):
+	}
+}
+
+func TestConfigSourcesWithAliasesLoadAsSeparateLayers(t *testing.T) {
+	newConfigMap := func(namespace, content string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+			Data:       map[string]string{"config.yaml": content},
+		}
+	}
+	client := k8sfake.NewSimpleClientset(
+		newConfigMap("app", "app:\n  name: demo\n"),
+		newConfigMap("flags", "flags:\n  beta: false\n"),
+	)
+	watchers := map[string]*watch.FakeWatcher{
+		"app":   watch.NewFake(),
+		"flags": watch.NewFake(),
+	}
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			return true, watchers[action.GetNamespace()], nil
+		},
+	)
+
+	newAliased := func(namespace, alias string) *configSource {
+		raw, err := NewConfigMapSource(Config{
+			Namespace: namespace,
+			Name:      "config",
+			Key:       "config.yaml",
+			Watch:     true,
+			Alias:     alias,
+		})
+		if err != nil {
+			t.Fatalf("NewConfigMapSource() error = %v", err)
+		}
+		src := raw.(*configSource)
+		src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+		return src
+	}
+	appSource := newAliased("app", "app-config")
+	flagsSource := newAliased("flags", "feature-flags")
+	if appSource.Name() != "app-config" || flagsSource.Name() != "feature-flags" {
+		t.Fatalf("Name() = %q/%q, want the aliases", appSource.Name(), flagsSource.Name())
+	}
+
+	manager := config.NewManager()
+	defer manager.Close() //nolint:errcheck
+	for _, src := range []*configSource{appSource, flagsSource} {
+		if err := manager.LoadLayer(src.Name(), config.PriorityRemote, src); err != nil {
+			t.Fatalf("LoadLayer(%q) error = %v", src.Name(), err)
+		}
+	}
+
+	var got struct {
+		App   map[string]any `mapstructure:"app"`
+		Flags map[string]any `mapstructure:"flags"`
+	}
+	if err := manager.Snapshot().Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.App["name"] != "demo" || got.Flags["beta"] != false {
+		t.Fatalf("snapshot = %#v, want both layers loaded", got)
+	}
+
+	appChanged := make(chan struct{}, 4)
+	flagsChanged := make(chan struct{}, 4)
+	defer manager.Watch([]string{"app"}, func(config.Snapshot) { appChanged <- struct{}{} })()
+	defer manager.Watch([]string{"flags"}, func(config.Snapshot) { flagsChanged <- struct{}{} })()
+	drain := func(ch chan struct{}) {
+		for len(ch) > 0 {
+			<-ch
+		}
+	}
+	drain(appChanged)
+	drain(flagsChanged)
+
+	flags, err := client.CoreV1().
+		ConfigMaps("flags").
+		Get(context.Background(), "config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	flags.Data["config.yaml"] = "flags:\n  beta: true\n"
+	if _, err := client.CoreV1().ConfigMaps("flags").Update(
+		context.Background(),
+		flags,
+		metav1.UpdateOptions{},
+	); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	watchers["flags"].Modify(flags)
+
+	select {
+	case <-flagsChanged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for feature-flags layer update")
+	}
+	select {
+	case <-appChanged:
+		t.Fatal("app-config layer changed on a feature-flags update")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := manager.Snapshot().Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.App["name"] != "demo" || got.Flags["beta"] != true {
+		t.Fatalf("snapshot after update = %#v", got)
+	}
+}
+
 func TestExplicitFormatParserCanPopulateData(t *testing.T) {
 	parser := source.Parser(func(data []byte, out any) error {
 		target, ok := out.(*map[string]any)
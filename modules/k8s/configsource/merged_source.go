@@ -0,0 +1,289 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codesjoy/yggdrasil/v3/config/source"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KindMergedConfigMap is the config source kind for layered ConfigMaps.
+const KindMergedConfigMap = "kubernetes-configmap-merged"
+
+// LayerConfig names one ConfigMap of a priority-merged source.
+type LayerConfig struct {
+	Name string `mapstructure:"name"`
+	// Priority orders the layer; higher priorities override lower ones on
+	// conflicting keys. Equal priorities keep list order.
+	Priority int `mapstructure:"priority"`
+}
+
+type mergedSource struct {
+	layers []*configSource
+	watch  bool
+	alias  string
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewMergedConfigMapSource creates a source that layers several ConfigMaps.
+// Layers are deep-merged in slice order, so later entries override earlier
+// ones on conflicting keys. A missing ConfigMap contributes an empty layer.
+// The source is watchable when any layer enables Watch; a change to any
+// watched layer re-emits the merged result.
+func NewMergedConfigMapSource(cfgs []Config) (source.Source, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("no configmaps to merge")
+	}
+	s := &mergedSource{
+		layers:  make([]*configSource, 0, len(cfgs)),
+		closeCh: make(chan struct{}),
+	}
+	for i, cfg := range cfgs {
+		if strings.TrimSpace(cfg.Name) == "" {
+			return nil, fmt.Errorf("empty configmap name at index %d", i)
+		}
+		if err := cfg.Backoff.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid backoff at index %d: %w", i, err)
+		}
+		s.layers = append(s.layers, newSource(KindConfigMap, resourceTypeConfigMap, cfg))
+		s.watch = s.watch || cfg.Watch
+	}
+	return s, nil
+}
+
+// NewPriorityConfigMapSource creates a merged source over the ConfigMaps
+// listed in cfg.ConfigMaps. Every layer shares the namespace, key, format and
+// watch settings of cfg and is deep-merged in ascending priority, so an
+// overlay with a higher priority wins over its base. Deleting an overlay
+// leaves an empty layer, which restores the values underneath it.
+func NewPriorityConfigMapSource(cfg Config) (source.Source, error) {
+	if len(cfg.ConfigMaps) == 0 {
+		return nil, errors.New("no configmaps to merge")
+	}
+	layers := make([]LayerConfig, len(cfg.ConfigMaps))
+	copy(layers, cfg.ConfigMaps)
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].Priority < layers[j].Priority
+	})
+	cfgs := make([]Config, 0, len(layers))
+	for _, layer := range layers {
+		layerCfg := cfg
+		layerCfg.Name = layer.Name
+		layerCfg.Alias = ""
+		layerCfg.ConfigMaps = nil
+		cfgs = append(cfgs, layerCfg)
+	}
+	src, err := NewMergedConfigMapSource(cfgs)
+	if err != nil {
+		return nil, err
+	}
+	merged := src.(*mergedSource)
+	merged.alias = cfg.Alias
+	return merged, nil
+}
+
+func (s *mergedSource) Kind() string { return KindMergedConfigMap }
+
+func (s *mergedSource) Name() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	names := make([]string, 0, len(s.layers))
+	for _, layer := range s.layers {
+		names = append(names, layer.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+func (s *mergedSource) Read() (source.Data, error) {
+	merged, err := s.merge()
+	if err != nil {
+		return nil, err
+	}
+	return source.NewMapData(merged), nil
+}
+
+func (s *mergedSource) Watch() (<-chan source.Data, error) {
+	if !s.watch {
+		return nil, errors.New("watch disabled for this source")
+	}
+
+	clients := make(map[*configSource]kubernetes.Interface, len(s.layers))
+	for _, layer := range s.layers {
+		if !layer.watch {
+			continue
+		}
+		client, err := layer.clientForConfig(layer.cfg.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get kube client: %w", err)
+		}
+		clients[layer] = client
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-s.closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	changed := make(chan struct{}, 1)
+	for layer, client := range clients {
+		go layer.notifyChanges(ctx, client, changed)
+	}
+
+	out := make(chan source.Data)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		var last string
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+
+			merged, err := s.merge()
+			if err != nil {
+				continue
+			}
+			payload := source.NewMapData(merged)
+			content := string(payload.Bytes())
+			if content == last {
+				continue
+			}
+			last = content
+			select {
+			case out <- payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *mergedSource) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+	return nil
+}
+
+func (s *mergedSource) merge() (map[string]any, error) {
+	merged := map[string]any{}
+	for _, layer := range s.layers {
+		values, err := layer.values()
+		if err != nil {
+			return nil, fmt.Errorf("configmap %q: %w", layer.cfg.Name, err)
+		}
+		merged = mergeMaps(merged, values)
+	}
+	return merged, nil
+}
+
+// values returns the parsed content of the resource, or an empty map when
+// the resource does not exist.
+func (s *configSource) values() (map[string]any, error) {
+	data, parser, _, err := s.fetch()
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]any{}, nil
+		}
+		return nil, err
+	}
+	if s.cfg.MergeAllKeys {
+		return data, nil
+	}
+	payload, _, err := s.payload(data, parser)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	if err := payload.Unmarshal(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// notifyChanges watches the resource until ctx is done and signals changed
+// on every add, modify or delete.
+func (s *configSource) notifyChanges(
+	ctx context.Context,
+	client kubernetes.Interface,
+	changed chan<- struct{},
+) {
+	retries := 0
+	for {
+		ch, err := s.doWatch(ctx, client)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			if !s.waitRetry(ctx, retries) {
+				return
+			}
+			retries++
+			continue
+		}
+		retries = 0
+
+		for event := range ch {
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+			default:
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+func mergeMaps(dst, src map[string]any) map[string]any {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcValue
+	}
+	return dst
+}
@@ -0,0 +1,280 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMergedConfigMapSourceOverlayOverridesBase(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "default"},
+			Data: map[string]string{
+				"config.yaml": "app:\n  name: demo\n  level: info\n",
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "overlay", Namespace: "default"},
+			Data: map[string]string{
+				"config.yaml": "app:\n  level: debug\n",
+			},
+		},
+	)
+
+	var (
+		mu       sync.Mutex
+		watchers = make(map[string]*watch.FakeWatcher)
+	)
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			restrictions := action.(k8stesting.WatchActionImpl).GetWatchRestrictions()
+			name, _ := restrictions.Fields.RequiresExactMatch("metadata.name")
+			fw := watch.NewFake()
+			mu.Lock()
+			watchers[name] = fw
+			mu.Unlock()
+			return true, fw, nil
+		},
+	)
+
+	raw, err := NewMergedConfigMapSource([]Config{
+		{Namespace: "default", Name: "base", Key: "config.yaml"},
+		{Namespace: "default", Name: "missing", Key: "config.yaml"},
+		{Namespace: "default", Name: "overlay", Key: "config.yaml", Watch: true},
+	})
+	if err != nil {
+		t.Fatalf("NewMergedConfigMapSource() error = %v", err)
+	}
+	src := raw.(*mergedSource)
+	for _, layer := range src.layers {
+		layer.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	}
+	defer src.Close() //nolint:errcheck
+
+	if src.Kind() != KindMergedConfigMap || src.Name() != "base+missing+overlay" {
+		t.Fatalf("identity = %q/%q", src.Kind(), src.Name())
+	}
+
+	data, err := src.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	var got struct {
+		App map[string]string `mapstructure:"app"`
+	}
+	if err := data.Unmarshal(&got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.App["name"] != "demo" || got.App["level"] != "debug" {
+		t.Fatalf("merged app = %#v, want name=demo level=debug", got.App)
+	}
+
+	ch, err := src.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	var overlayWatch *watch.FakeWatcher
+	deadline := time.Now().Add(2 * time.Second)
+	for overlayWatch == nil && time.Now().Before(deadline) {
+		mu.Lock()
+		overlayWatch = watchers["overlay"]
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if overlayWatch == nil {
+		t.Fatal("overlay configmap was not watched")
+	}
+	mu.Lock()
+	_, baseWatched := watchers["base"]
+	mu.Unlock()
+	if baseWatched {
+		t.Fatal("base configmap was watched without Watch enabled")
+	}
+
+	overlay, err := client.CoreV1().
+		ConfigMaps("default").
+		Get(context.Background(), "overlay", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	overlay.Data["config.yaml"] = "app:\n  level: warn\n"
+	if _, err := client.CoreV1().ConfigMaps("default").Update(
+		context.Background(),
+		overlay,
+		metav1.UpdateOptions{},
+	); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	overlayWatch.Modify(overlay)
+
+	select {
+	case update := <-ch:
+		if err := update.Unmarshal(&got); err != nil {
+			t.Fatalf("watch Unmarshal() error = %v", err)
+		}
+		if got.App["name"] != "demo" || got.App["level"] != "warn" {
+			t.Fatalf("merged app after update = %#v, want name=demo level=warn", got.App)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for merged watch update")
+	}
+}
+
+func TestPriorityConfigMapSourceOverlayWinsAndDeletionReverts(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "default"},
+			Data: map[string]string{
+				"config.yaml": "app:\n  name: demo\n  level: info\n",
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "overlay", Namespace: "default"},
+			Data: map[string]string{
+				"config.yaml": "app:\n  level: debug\n",
+			},
+		},
+	)
+
+	var (
+		mu       sync.Mutex
+		watchers = make(map[string]*watch.FakeWatcher)
+	)
+	client.PrependWatchReactor(
+		"configmaps",
+		func(action k8stesting.Action) (bool, watch.Interface, error) {
+			restrictions := action.(k8stesting.WatchActionImpl).GetWatchRestrictions()
+			name, _ := restrictions.Fields.RequiresExactMatch("metadata.name")
+			fw := watch.NewFake()
+			mu.Lock()
+			watchers[name] = fw
+			mu.Unlock()
+			return true, fw, nil
+		},
+	)
+
+	raw, err := NewPriorityConfigMapSource(Config{
+		Namespace: "default",
+		Key:       "config.yaml",
+		Watch:     true,
+		Alias:     "app",
+		ConfigMaps: []LayerConfig{
+			{Name: "overlay", Priority: 10},
+			{Name: "base"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPriorityConfigMapSource() error = %v", err)
+	}
+	src := raw.(*mergedSource)
+	for _, layer := range src.layers {
+		layer.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	}
+	defer src.Close() //nolint:errcheck
+
+	if src.Kind() != KindMergedConfigMap || src.Name() != "app" {
+		t.Fatalf("identity = %q/%q", src.Kind(), src.Name())
+	}
+
+	var got struct {
+		App map[string]string `mapstructure:"app"`
+	}
+	data, err := src.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if err := data.Unmarshal(&got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.App["name"] != "demo" || got.App["level"] != "debug" {
+		t.Fatalf("merged app = %#v, want name=demo level=debug", got.App)
+	}
+
+	ch, err := src.Watch()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	var overlayWatch *watch.FakeWatcher
+	deadline := time.Now().Add(2 * time.Second)
+	for overlayWatch == nil && time.Now().Before(deadline) {
+		mu.Lock()
+		overlayWatch = watchers["overlay"]
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if overlayWatch == nil {
+		t.Fatal("overlay configmap was not watched")
+	}
+
+	overlay, err := client.CoreV1().
+		ConfigMaps("default").
+		Get(context.Background(), "overlay", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := client.CoreV1().ConfigMaps("default").Delete(
+		context.Background(),
+		"overlay",
+		metav1.DeleteOptions{},
+	); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	overlayWatch.Delete(overlay)
+
+	select {
+	case update := <-ch:
+		got.App = nil
+		if err := update.Unmarshal(&got); err != nil {
+			t.Fatalf("watch Unmarshal() error = %v", err)
+		}
+		if got.App["name"] != "demo" || got.App["level"] != "info" {
+			t.Fatalf("merged app after delete = %#v, want name=demo level=info", got.App)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for merged watch update")
+	}
+}
+
+func TestMergedConfigMapSourceValidatesConfigs(t *testing.T) {
+	if _, err := NewMergedConfigMapSource(nil); err == nil {
+		t.Fatal("NewMergedConfigMapSource(nil) expected error")
+	}
+	if _, err := NewMergedConfigMapSource([]Config{{Name: "base"}, {}}); err == nil {
+		t.Fatal("NewMergedConfigMapSource() expected empty name error")
+	}
+	raw, err := NewMergedConfigMapSource([]Config{{Name: "base"}})
+	if err != nil {
+		t.Fatalf("NewMergedConfigMapSource() error = %v", err)
+	}
+	if _, err := raw.(*mergedSource).Watch(); err == nil {
+		t.Fatal("Watch() expected error when no layer enables watch")
+	}
+	if _, err := NewPriorityConfigMapSource(Config{Name: "base"}); err == nil {
+		t.Fatal("NewPriorityConfigMapSource() expected error without configmaps")
+	}
+}
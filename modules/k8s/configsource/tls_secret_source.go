@@ -0,0 +1,258 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// TLSSecretCAKey is the Secret key holding the PEM CA bundle, as written by
+// cert-manager next to corev1.TLSCertKey and corev1.TLSPrivateKeyKey.
+const TLSSecretCAKey = "ca.crt"
+
+// TLSMaterial is the PEM content of a TLS Secret.
+type TLSMaterial struct {
+	CertPEM []byte
+	KeyPEM  []byte
+	// CAPEM is empty when the Secret has no ca.crt key.
+	CAPEM []byte
+}
+
+// TLSSecretSource builds TLS configs from a kubernetes.io/tls Secret and,
+// with Config.Watch, keeps them in step with certificate rotation.
+type TLSSecretSource struct {
+	src *configSource
+
+	loadMu    sync.Mutex
+	watchOnce sync.Once
+
+	mu       sync.RWMutex
+	material TLSMaterial
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+// NewTLSSecretSource creates a source for the TLS Secret named by cfg.Name.
+// Only Namespace, Name, Kubeconfig, Watch and Backoff are used. The Secret is
+// read on the first call to TLSConfig or Material.
+func NewTLSSecretSource(cfg Config) (*TLSSecretSource, error) {
+	if strings.TrimSpace(cfg.Name) == "" {
+		return nil, errors.New("empty secret name")
+	}
+	if err := cfg.Backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid backoff: %w", err)
+	}
+	return &TLSSecretSource{src: newSource(KindSecret, resourceTypeSecret, cfg)}, nil
+}
+
+// TLSConfig returns a TLS config usable by both clients and servers. The
+// certificate is resolved per handshake, so a rotated certificate is picked
+// up without rebuilding the config. RootCAs is fixed when the config is
+// built, while servers verify clients against the latest ClientCAs; call
+// TLSConfig again after a CA rotation to refresh RootCAs.
+func (s *TLSSecretSource) TLSConfig() (*tls.Config, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.buildTLSConfig(), nil
+}
+
+// Material returns the current PEM content of the Secret, for TLS options
+// that take certificates as bytes rather than a *tls.Config.
+func (s *TLSSecretSource) Material() (TLSMaterial, error) {
+	if err := s.load(); err != nil {
+		return TLSMaterial{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.material, nil
+}
+
+// Close stops watching the Secret.
+func (s *TLSSecretSource) Close() error {
+	return s.src.Close()
+}
+
+// load reads the Secret until it has been read successfully once, then
+// starts watching it when the source watches.
+func (s *TLSSecretSource) load() error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if s.current() != nil {
+		return nil
+	}
+	client, err := s.src.clientForConfig(s.src.cfg.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to get kube client: %w", err)
+	}
+	secret, err := client.CoreV1().Secrets(s.src.cfg.Namespace).Get(
+		context.Background(),
+		s.src.cfg.Name,
+		metav1.GetOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+	if err := s.apply(secret); err != nil {
+		return err
+	}
+	if s.src.watch {
+		s.watchOnce.Do(func() { go s.watchLoop() })
+	}
+	return nil
+}
+
+// apply parses secret and, when it holds a usable key pair, replaces the
+// current material.
+func (s *TLSSecretSource) apply(secret *corev1.Secret) error {
+	switch secret.Type {
+	case corev1.SecretTypeTLS, corev1.SecretTypeOpaque, "":
+	default:
+		return fmt.Errorf(
+			"secret %s/%s has type %q, want %q",
+			secret.Namespace,
+			secret.Name,
+			secret.Type,
+			corev1.SecretTypeTLS,
+		)
+	}
+	material := TLSMaterial{
+		CertPEM: secret.Data[corev1.TLSCertKey],
+		KeyPEM:  secret.Data[corev1.TLSPrivateKeyKey],
+		CAPEM:   secret.Data[TLSSecretCAKey],
+	}
+	cert, err := tls.X509KeyPair(material.CertPEM, material.KeyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair from secret %s/%s: %w",
+			secret.Namespace, secret.Name, err)
+	}
+	var pool *x509.CertPool
+	if len(material.CAPEM) > 0 {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(material.CAPEM) {
+			return fmt.Errorf("no certificates found in %s of secret %s/%s",
+				TLSSecretCAKey, secret.Namespace, secret.Name)
+		}
+	}
+
+	s.mu.Lock()
+	s.material, s.cert, s.pool = material, &cert, pool
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *TLSSecretSource) buildTLSConfig() *tls.Config {
+	s.mu.RLock()
+	pool := s.pool
+	s.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ClientCAs:  pool,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.current(), nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.current(), nil
+		},
+	}
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		s.mu.RLock()
+		latest := s.pool
+		s.mu.RUnlock()
+		if latest == pool {
+			return nil, nil
+		}
+		next := cfg.Clone()
+		next.ClientCAs = latest
+		next.GetConfigForClient = nil
+		return next, nil
+	}
+	return cfg
+}
+
+func (s *TLSSecretSource) current() *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert
+}
+
+// watchLoop applies every added or modified version of the Secret until the
+// source is closed. Versions that fail to parse keep the previous material.
+func (s *TLSSecretSource) watchLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.src.closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client, err := s.src.clientForConfig(s.src.cfg.Kubeconfig)
+	if err != nil {
+		return
+	}
+	retries := 0
+	for {
+		ch, err := s.src.doWatch(ctx, client)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || !s.src.waitRetry(ctx, retries) {
+				return
+			}
+			retries++
+			continue
+		}
+		retries = 0
+
+	events:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					break events
+				}
+				if event.Type != watch.Added && event.Type != watch.Modified {
+					continue
+				}
+				if secret, ok := event.Object.(*corev1.Secret); ok {
+					// The previous key pair stays in use when the update is
+					// unusable, so the failure is only reported.
+					if err := s.apply(secret); err != nil {
+						slog.Warn("kubernetes tls secret source kept previous key pair",
+							slog.String("namespace", secret.Namespace),
+							slog.String("name", secret.Name),
+							slog.Any("error", err))
+					}
+				}
+			}
+		}
+	}
+}
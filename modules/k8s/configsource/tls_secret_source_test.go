@@ -0,0 +1,179 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configsource
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTLSSecretSourceBuildsTLSConfigAndFollowsRotation(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t, "first")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+			TLSSecretCAKey:          certPEM,
+		},
+	}
+	client := k8sfake.NewSimpleClientset(secret)
+	fw := watch.NewFake()
+	client.PrependWatchReactor("secrets", func(k8stesting.Action) (bool, watch.Interface, error) {
+		return true, fw, nil
+	})
+	src, err := NewTLSSecretSource(Config{Namespace: "default", Name: "tls", Watch: true})
+	if err != nil {
+		t.Fatalf("NewTLSSecretSource() error = %v", err)
+	}
+	src.src.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer src.Close() //nolint:errcheck
+
+	cfg, err := src.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+	if got := leafCommonName(t, cfg); got != "first" {
+		t.Fatalf("certificate CN = %q, want first", got)
+	}
+	if cfg.RootCAs == nil || cfg.ClientCAs == nil {
+		t.Fatal("CA pools should be loaded from ca.crt")
+	}
+	material, err := src.Material()
+	if err != nil {
+		t.Fatalf("Material() error = %v", err)
+	}
+	if string(material.CertPEM) != string(certPEM) || string(material.CAPEM) != string(certPEM) {
+		t.Fatal("Material() should expose the Secret PEM content")
+	}
+
+	rotatedCert, rotatedKey := selfSignedPEM(t, "second")
+	fw.Modify(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       rotatedCert,
+			corev1.TLSPrivateKeyKey: rotatedKey,
+		},
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for leafCommonName(t, cfg) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTLSSecretSourceRejectsInvalidSecrets(t *testing.T) {
+	if _, err := NewTLSSecretSource(Config{}); err == nil {
+		t.Fatal("NewTLSSecretSource() should reject an empty name")
+	}
+
+	certPEM, keyPEM := selfSignedPEM(t, "demo")
+	tests := map[string]*corev1.Secret{
+		"wrong type": {
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			},
+		},
+		"missing key": {
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{corev1.TLSCertKey: certPEM},
+		},
+		"bad ca": {
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+				TLSSecretCAKey:          []byte("not a certificate"),
+			},
+		},
+	}
+	for name, secret := range tests {
+		t.Run(name, func(t *testing.T) {
+			secret.ObjectMeta = metav1.ObjectMeta{Name: "tls", Namespace: "default"}
+			client := k8sfake.NewSimpleClientset(secret)
+			src, err := NewTLSSecretSource(Config{Namespace: "default", Name: "tls"})
+			if err != nil {
+				t.Fatalf("NewTLSSecretSource() error = %v", err)
+			}
+			src.src.clientForConfig = func(string) (kubernetes.Interface, error) {
+				return client, nil
+			}
+			if _, err := src.TLSConfig(); err == nil {
+				t.Fatal("TLSConfig() should fail")
+			}
+		})
+	}
+}
+
+func leafCommonName(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func selfSignedPEM(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
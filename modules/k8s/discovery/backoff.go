@@ -14,31 +14,15 @@
 
 package discovery
 
-import (
-	"math"
-	"math/rand/v2"
-	"time"
-)
-
-type backoff struct {
-	cfg BackoffConfig
-}
+import "github.com/codesjoy/yggdrasil-ecosystem/modules/k8s/v3/internal/backoff"
 
-func newBackoff(cfg BackoffConfig) *backoff {
-	return &backoff{cfg: cfg}
-}
+// Backoff strategies accepted by BackoffConfig.Strategy.
+const (
+	BackoffConstant    = backoff.StrategyConstant
+	BackoffLinear      = backoff.StrategyLinear
+	BackoffExponential = backoff.StrategyExponential
+)
 
-func (b *backoff) Backoff(retry int) time.Duration {
-	if retry == 0 {
-		return b.cfg.BaseDelay
-	}
-	delay := float64(b.cfg.BaseDelay) * math.Pow(b.cfg.Multiplier, float64(retry))
-	if b.cfg.Jitter > 0 {
-		delay *= 1.0 + b.cfg.Jitter*(2*rand.Float64()-1.0) //nolint:gosec
-	}
-	result := time.Duration(delay)
-	if result > b.cfg.MaxDelay {
-		return b.cfg.MaxDelay
-	}
-	return result
+func newBackoff(cfg BackoffConfig) *backoff.Backoff {
+	return backoff.New(cfg)
 }
@@ -18,19 +18,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/k8s/v3/internal/backoff"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/k8s/v3/internal/kube"
 	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 type resolverMode string
@@ -41,21 +52,20 @@ const (
 
 const (
 	resolverType = "kubernetes"
+	// allNamespaces in Namespaces watches every namespace of the cluster.
+	allNamespaces = "*"
 )
 
 // BackoffConfig configures resolver watch retry timing.
-type BackoffConfig struct {
-	BaseDelay  time.Duration `mapstructure:"base_delay"`
-	Multiplier float64       `mapstructure:"multiplier"`
-	Jitter     float64       `mapstructure:"jitter"`
-	MaxDelay   time.Duration `mapstructure:"max_delay"`
-}
+type BackoffConfig = backoff.Config
 
 // ResolverConfig configures the Kubernetes resolver.
 type ResolverConfig struct {
 	Namespace          string            `mapstructure:"namespace"`
+	Namespaces         []string          `mapstructure:"namespaces"`
 	Mode               string            `mapstructure:"mode"`
 	PortName           string            `mapstructure:"port_name"`
+	AppProtocol        string            `mapstructure:"app_protocol"`
 	Port               int32             `mapstructure:"port"`
 	Protocol           string            `mapstructure:"protocol"`
 	Kubeconfig         string            `mapstructure:"kubeconfig"`
@@ -63,6 +73,22 @@ type ResolverConfig struct {
 	Timeout            time.Duration     `mapstructure:"timeout"`
 	Backoff            BackoffConfig     `mapstructure:"backoff"`
 	EndpointAttributes map[string]string `mapstructure:"endpoint_attributes"`
+	IncludeTerminating bool              `mapstructure:"include_terminating"`
+	PreferLocalZone    bool              `mapstructure:"prefer_local_zone"`
+	Zone               string            `mapstructure:"zone"`
+	// LabelSelector and FieldSelector, when either is set, replace the
+	// per-service defaults (kubernetes.io/service-name=<app> for
+	// EndpointSlices, metadata.name=<app> for Endpoints).
+	LabelSelector string `mapstructure:"label_selector"`
+	FieldSelector string `mapstructure:"field_selector"`
+	// AnnotationKeys and LabelKeys name annotations and labels copied from
+	// an endpoint's Service, then its Pod, into the endpoint attributes.
+	// Keys an object does not carry are omitted.
+	AnnotationKeys []string `mapstructure:"annotation_keys"`
+	LabelKeys      []string `mapstructure:"label_keys"`
+	// MetadataLabelSelector limits the Services and Pods cached for
+	// AnnotationKeys and LabelKeys.
+	MetadataLabelSelector string `mapstructure:"metadata_label_selector"`
 }
 
 // ResolverConfigLoader loads resolver config for a named resolver.
@@ -77,12 +103,18 @@ func NormalizeConfig(cfg ResolverConfig) ResolverConfig {
 			cfg.Namespace = "default"
 		}
 	}
+	if cfg.PreferLocalZone && cfg.Zone == "" {
+		cfg.Zone = os.Getenv("KUBERNETES_ZONE")
+	}
 	if cfg.Mode == "" {
 		cfg.Mode = string(modeEndpointSlice)
 	}
 	if cfg.Protocol == "" {
 		cfg.Protocol = "grpc"
 	}
+	if cfg.Backoff.Strategy == "" {
+		cfg.Backoff.Strategy = BackoffExponential
+	}
 	if cfg.Backoff.BaseDelay == 0 {
 		cfg.Backoff.BaseDelay = time.Second
 	}
@@ -102,7 +134,7 @@ func NormalizeConfig(cfg ResolverConfig) ResolverConfig {
 type Resolver struct {
 	name            string
 	cfg             ResolverConfig
-	bo              *backoff
+	bo              *backoff.Backoff
 	initErr         error
 	clientForConfig func(string) (kubernetes.Interface, error)
 
@@ -110,11 +142,32 @@ type Resolver struct {
 	watchers map[string]map[yresolver.Client]struct{}
 	cancels  map[string]context.CancelFunc
 	states   map[string]yresolver.State
+	// nsStates holds the latest state per app and watched namespace; states
+	// holds their merged view.
+	nsStates map[string]map[string]yresolver.State
+	// zone is the local zone looked up from the node when PreferLocalZone is
+	// set without an explicit Zone.
+	zone string
+	// factories holds the informer factories shared by the app watches of
+	// one client and namespace.
+	factories map[informerKey]*sharedFactory
 }
 
 // NewResolver creates a new Kubernetes resolver.
 func NewResolver(name string, cfg ResolverConfig) (*Resolver, error) {
 	cfg = NormalizeConfig(cfg)
+	if _, err := labels.Parse(cfg.LabelSelector); err != nil {
+		return nil, fmt.Errorf("invalid label_selector: %w", err)
+	}
+	if _, err := fields.ParseSelector(cfg.FieldSelector); err != nil {
+		return nil, fmt.Errorf("invalid field_selector: %w", err)
+	}
+	if _, err := labels.Parse(cfg.MetadataLabelSelector); err != nil {
+		return nil, fmt.Errorf("invalid metadata_label_selector: %w", err)
+	}
+	if err := cfg.Backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid backoff: %w", err)
+	}
 	factory := kube.NewClientFactory()
 	return &Resolver{
 		name:            name,
@@ -124,6 +177,7 @@ func NewResolver(name string, cfg ResolverConfig) (*Resolver, error) {
 		watchers:        map[string]map[yresolver.Client]struct{}{},
 		cancels:         map[string]context.CancelFunc{},
 		states:          map[string]yresolver.State{},
+		nsStates:        map[string]map[string]yresolver.State{},
 	}, nil
 }
 
@@ -150,6 +204,7 @@ func NewResolverWithError(name string, cfg ResolverConfig, initErr error) *Resol
 		watchers:        map[string]map[yresolver.Client]struct{}{},
 		cancels:         map[string]context.CancelFunc{},
 		states:          map[string]yresolver.State{},
+		nsStates:        map[string]map[string]yresolver.State{},
 	}
 }
 
@@ -176,7 +231,9 @@ func (r *Resolver) AddWatch(appName string, watcher yresolver.Client) error {
 	if !running {
 		ctx, cancel := context.WithCancel(context.Background())
 		r.cancels[appName] = cancel
-		go r.watchLoop(ctx, appName)
+		for _, namespace := range r.namespaces() {
+			go r.watchLoop(ctx, appName, namespace)
+		}
 	}
 	r.mu.Unlock()
 
@@ -203,12 +260,34 @@ func (r *Resolver) DelWatch(appName string, watcher yresolver.Client) error {
 				cancel()
 			}
 			delete(r.states, appName)
+			delete(r.nsStates, appName)
 		}
 	}
 	r.mu.Unlock()
 	return nil
 }
 
+// namespaces returns the namespaces to watch. "*" in Namespaces watches all
+// namespaces through one cluster-wide watch, returned as metav1.NamespaceAll.
+func (r *Resolver) namespaces() []string {
+	if len(r.cfg.Namespaces) == 0 {
+		return []string{r.cfg.Namespace}
+	}
+	if slices.Contains(r.cfg.Namespaces, allNamespaces) {
+		return []string{metav1.NamespaceAll}
+	}
+	seen := make(map[string]struct{}, len(r.cfg.Namespaces))
+	out := make([]string, 0, len(r.cfg.Namespaces))
+	for _, namespace := range r.cfg.Namespaces {
+		if _, ok := seen[namespace]; ok {
+			continue
+		}
+		seen[namespace] = struct{}{}
+		out = append(out, namespace)
+	}
+	return out
+}
+
 func (r *Resolver) getState(appName string) (yresolver.State, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -216,10 +295,49 @@ func (r *Resolver) getState(appName string) (yresolver.State, bool) {
 	return state, ok
 }
 
-func (r *Resolver) setState(appName string, state yresolver.State) {
+// setState records the state of one namespace and returns the merged state
+// across every namespace watched for appName.
+func (r *Resolver) setState(appName, namespace string, state yresolver.State) yresolver.State {
 	r.mu.Lock()
-	r.states[appName] = state
-	r.mu.Unlock()
+	defer r.mu.Unlock()
+	if r.nsStates == nil {
+		r.nsStates = map[string]map[string]yresolver.State{}
+	}
+	byNamespace := r.nsStates[appName]
+	if byNamespace == nil {
+		byNamespace = map[string]yresolver.State{}
+		r.nsStates[appName] = byNamespace
+	}
+	byNamespace[namespace] = state
+	merged := mergeNamespaceStates(appName, byNamespace)
+	r.states[appName] = merged
+	return merged
+}
+
+func mergeNamespaceStates(appName string, byNamespace map[string]yresolver.State) yresolver.State {
+	if len(byNamespace) == 1 {
+		for _, state := range byNamespace {
+			return state
+		}
+	}
+
+	namespaces := make([]string, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	merged := yresolver.BaseState{
+		Attributes: map[string]any{
+			"service":    appName,
+			"namespaces": namespaces,
+		},
+		Endpoints: []yresolver.Endpoint{},
+	}
+	for _, namespace := range namespaces {
+		merged.Endpoints = append(merged.Endpoints, byNamespace[namespace].GetEndpoints()...)
+	}
+	return merged
 }
 
 func (r *Resolver) snapshotWatchers(appName string) []yresolver.Client {
@@ -239,7 +357,7 @@ func (r *Resolver) notify(appName string, state yresolver.State) {
 	}
 }
 
-func (r *Resolver) watchLoop(ctx context.Context, appName string) {
+func (r *Resolver) watchLoop(ctx context.Context, appName, namespace string) {
 	retries := 0
 	for {
 		select {
@@ -248,7 +366,9 @@ func (r *Resolver) watchLoop(ctx context.Context, appName string) {
 		default:
 		}
 
-		if err := r.watch(ctx, appName); err != nil {
+		// A watch that synced once starts the backoff over when it fails.
+		synced := func() { retries = 0 }
+		if err := r.watch(ctx, appName, namespace, synced); err != nil {
 			if errors.Is(err, context.Canceled) {
 				return
 			}
@@ -265,14 +385,20 @@ func (r *Resolver) watchLoop(ctx context.Context, appName string) {
 	}
 }
 
-func (r *Resolver) watch(ctx context.Context, appName string) error {
+func (r *Resolver) watch(
+	ctx context.Context,
+	appName string,
+	namespace string,
+	onSynced func(),
+) error {
 	client, err := r.clientForConfig(r.cfg.Kubeconfig)
 	if err != nil {
 		return fmt.Errorf("failed to get kube client: %w", err)
 	}
 
 	if r.cfg.Mode == string(modeEndpointSlice) {
-		err = r.watchEndpointSlice(ctx, client, appName)
+		r.lookupLocalZone(ctx, client)
+		err = r.watchEndpointSlice(ctx, client, appName, namespace, onSynced)
 		if err == nil {
 			return nil
 		}
@@ -280,7 +406,40 @@ func (r *Resolver) watch(ctx context.Context, appName string) error {
 			return err
 		}
 	}
-	return r.watchEndpoints(ctx, client, appName)
+	return r.watchEndpoints(ctx, client, appName, namespace, onSynced)
+}
+
+// lookupLocalZone resolves the zone label of the node named by
+// KUBERNETES_NODE_NAME when PreferLocalZone is set without a configured zone.
+func (r *Resolver) lookupLocalZone(ctx context.Context, client kubernetes.Interface) {
+	if !r.cfg.PreferLocalZone || r.localZone() != "" {
+		return
+	}
+	nodeName := os.Getenv("KUBERNETES_NODE_NAME")
+	if nodeName == "" {
+		return
+	}
+	node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.zone = node.Labels[corev1.LabelTopologyZone]
+	r.mu.Unlock()
+}
+
+// localZone returns the zone whose hinted endpoints are marked preferred.
+func (r *Resolver) localZone() string {
+	if !r.cfg.PreferLocalZone {
+		return ""
+	}
+	if r.cfg.Zone != "" {
+		return r.cfg.Zone
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.zone
 }
 
 //nolint:staticcheck // SA1019: corev1.Endpoints is deprecated in v1.33+, kept for backward compatibility with older Kubernetes clusters.
@@ -288,70 +447,90 @@ func (r *Resolver) watchEndpoints(
 	ctx context.Context,
 	client kubernetes.Interface,
 	appName string,
+	namespace string,
+	onSynced func(),
 ) error {
-	listOpts := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("metadata.name=%s", appName),
+	labelSelector, fieldSelector := r.selectors(
+		"",
+		fields.OneTermEqualSelector("metadata.name", appName).String(),
+	)
+	probeOpts := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+		Limit:         1,
+	}
+	if _, err := client.CoreV1().Endpoints(namespace).List(ctx, probeOpts); err != nil {
+		return fmt.Errorf("failed to list endpoints: %w", err)
 	}
-	for {
-		w, err := client.CoreV1().Endpoints(r.cfg.Namespace).Watch(ctx, listOpts)
-		if err != nil {
-			return fmt.Errorf("failed to watch endpoints: %w", err)
-		}
-
-		state, err := r.listEndpoints(ctx, client, appName)
-		if err != nil {
-			w.Stop()
-			return fmt.Errorf("failed to list endpoints: %w", err)
-		}
-		r.setState(appName, state)
-		r.notify(appName, state)
-
-		for event := range w.ResultChan() {
-			if event.Type == watch.Deleted {
-				emptyState := yresolver.BaseState{Endpoints: []yresolver.Endpoint{}}
-				r.setState(appName, emptyState)
-				r.notify(appName, emptyState)
-				continue
-			}
-			if event.Type != watch.Added && event.Type != watch.Modified {
-				continue
-			}
-
-			state, err := r.listEndpoints(ctx, client, appName)
-			if err != nil {
-				w.Stop()
-				return fmt.Errorf("failed to list endpoints after event: %w", err)
-			}
-			r.setState(appName, state)
-			r.notify(appName, state)
-		}
-		w.Stop()
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+	shared, release := r.acquireFactory(client, namespace)
+	defer release()
+	customSelectors := r.customSelectors()
+	owns := func(endpoints *corev1.Endpoints) bool {
+		return customSelectors || endpoints.Name == appName
 	}
+	endpointsInformer := shared.factory.Core().V1().Endpoints()
+	lister := endpointsInformer.Lister()
+	meta := r.metaLookup(shared, client, namespace)
+	return r.runInformer(ctx, shared, endpointsInformer.Informer(), appName, namespace,
+		func(obj any) bool {
+			endpoints, ok := obj.(*corev1.Endpoints)
+			return ok && owns(endpoints)
+		},
+		func() yresolver.State {
+			items, _ := lister.List(labels.Everything())
+			matched := make([]*corev1.Endpoints, 0, len(items))
+			for _, item := range items {
+				if owns(item) {
+					matched = append(matched, item)
+				}
+			}
+			sort.Slice(matched, func(i, j int) bool {
+				if matched[i].Namespace != matched[j].Namespace {
+					return matched[i].Namespace < matched[j].Namespace
+				}
+				return matched[i].Name < matched[j].Name
+			})
+			return r.endpointsListToState(appName, matched, meta)
+		},
+		meta,
+		onSynced,
+	)
 }
 
 //nolint:staticcheck // SA1019: corev1.Endpoints is deprecated in v1.33+, kept for backward compatibility with older Kubernetes clusters.
-func (r *Resolver) listEndpoints(
-	ctx context.Context,
-	client kubernetes.Interface,
+func (r *Resolver) endpointsListToState(
 	appName string,
-) (yresolver.State, error) {
-	endpoints, err := client.CoreV1().
-		Endpoints(r.cfg.Namespace).
-		Get(ctx, appName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get endpoints: %w", err)
+	items []*corev1.Endpoints,
+	meta *metaLookup,
+) yresolver.State {
+	switch len(items) {
+	case 0:
+		return yresolver.BaseState{Endpoints: []yresolver.Endpoint{}}
+	case 1:
+		return r.endpointsToState(items[0], meta)
 	}
-	return r.endpointsToState(endpoints), nil
+
+	byNamespace := make(map[string]yresolver.State, len(items))
+	for _, item := range items {
+		state := r.endpointsToState(item, meta)
+		// Custom selectors can match several Endpoints in one namespace.
+		if existing, ok := byNamespace[item.Namespace]; ok {
+			state = yresolver.BaseState{
+				Attributes: existing.GetAttributes(),
+				Endpoints:  append(existing.GetEndpoints(), state.GetEndpoints()...),
+			}
+		}
+		byNamespace[item.Namespace] = state
+	}
+	return mergeNamespaceStates(appName, byNamespace)
 }
 
 //nolint:staticcheck // SA1019: corev1.Endpoints is deprecated in v1.33+, kept for backward compatibility with older Kubernetes clusters.
-func (r *Resolver) endpointsToState(endpoints *corev1.Endpoints) yresolver.State {
+func (r *Resolver) endpointsToState(
+	endpoints *corev1.Endpoints,
+	meta *metaLookup,
+) yresolver.State {
 	baseState := yresolver.BaseState{
 		Attributes: map[string]any{
 			"service":   endpoints.Name,
@@ -371,13 +550,15 @@ func (r *Resolver) endpointsToState(endpoints *corev1.Endpoints) yresolver.State
 			}
 			endpointAddr := net.JoinHostPort(addr.IP, strconv.Itoa(int(port.Port)))
 			attrs := map[string]any{
-				"hostname": addr.Hostname,
-				"nodeName": addr.NodeName,
+				"hostname":  addr.Hostname,
+				"nodeName":  addr.NodeName,
+				"namespace": endpoints.Namespace,
 			}
 			if addr.TargetRef != nil {
 				attrs["targetRefKind"] = addr.TargetRef.Kind
 				attrs["targetRefName"] = addr.TargetRef.Name
 			}
+			r.copyObjectMeta(attrs, meta, endpoints.Namespace, endpoints.Name, addr.TargetRef)
 			for key, value := range r.cfg.EndpointAttributes {
 				attrs[key] = value
 			}
@@ -404,6 +585,13 @@ func (r *Resolver) selectPort(ports []corev1.EndpointPort) *corev1.EndpointPort
 			}
 		}
 	}
+	if r.cfg.AppProtocol != "" {
+		for i := range ports {
+			if ports[i].AppProtocol != nil && *ports[i].AppProtocol == r.cfg.AppProtocol {
+				return &ports[i]
+			}
+		}
+	}
 	if r.cfg.Port != 0 {
 		for i := range ports {
 			if ports[i].Port == r.cfg.Port {
@@ -418,73 +606,246 @@ func (r *Resolver) watchEndpointSlice(
 	ctx context.Context,
 	client kubernetes.Interface,
 	appName string,
+	namespace string,
+	onSynced func(),
 ) error {
-	listOpts := metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("kubernetes.io/service-name=%s", appName),
+	labelSelector, fieldSelector := r.selectors(
+		fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, appName),
+		"",
+	)
+	// Probe with a plain list first so clusters without EndpointSlice support
+	// fall back to Endpoints instead of leaving the informer retrying.
+	probeOpts := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+		Limit:         1,
+	}
+	if _, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, probeOpts); err != nil {
+		return fmt.Errorf("failed to list endpointslices: %w", err)
 	}
-	for {
-		w, err := client.DiscoveryV1().EndpointSlices(r.cfg.Namespace).Watch(ctx, listOpts)
-		if err != nil {
-			return fmt.Errorf("failed to watch endpointslices: %w", err)
-		}
-
-		state, err := r.listEndpointSlice(ctx, client, appName)
-		if err != nil {
-			w.Stop()
-			return fmt.Errorf("failed to list endpointslices: %w", err)
-		}
-		r.setState(appName, state)
-		r.notify(appName, state)
 
-		for event := range w.ResultChan() {
-			if event.Type == watch.Deleted {
-				emptyState := yresolver.BaseState{Endpoints: []yresolver.Endpoint{}}
-				r.setState(appName, emptyState)
-				r.notify(appName, emptyState)
-				continue
-			}
-			if event.Type != watch.Added && event.Type != watch.Modified {
-				continue
+	shared, release := r.acquireFactory(client, namespace)
+	defer release()
+	selector := labels.Everything()
+	if !r.customSelectors() {
+		selector = labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: appName})
+	}
+	sliceInformer := shared.factory.Discovery().V1().EndpointSlices()
+	lister := sliceInformer.Lister()
+	meta := r.metaLookup(shared, client, namespace)
+	return r.runInformer(ctx, shared, sliceInformer.Informer(), appName, namespace,
+		func(obj any) bool {
+			slice, ok := obj.(*discoveryv1.EndpointSlice)
+			return ok && selector.Matches(labels.Set(slice.Labels))
+		},
+		func() yresolver.State {
+			items, _ := lister.List(selector)
+			sort.Slice(items, func(i, j int) bool {
+				if items[i].Namespace != items[j].Namespace {
+					return items[i].Namespace < items[j].Namespace
+				}
+				return items[i].Name < items[j].Name
+			})
+			endpointSlices := make([]discoveryv1.EndpointSlice, 0, len(items))
+			for _, item := range items {
+				endpointSlices = append(endpointSlices, *item)
 			}
+			return r.endpointSlicesToState(endpointSlices, meta)
+		},
+		meta,
+		onSynced,
+	)
+}
 
-			state, err := r.listEndpointSlice(ctx, client, appName)
-			if err != nil {
-				w.Stop()
-				return fmt.Errorf("failed to list endpointslices after event: %w", err)
-			}
-			r.setState(appName, state)
-			r.notify(appName, state)
-		}
-		w.Stop()
+// customSelectors reports whether the config overrides the default selectors.
+func (r *Resolver) customSelectors() bool {
+	return r.cfg.LabelSelector != "" || r.cfg.FieldSelector != ""
+}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+// selectors returns the label and field selectors used to list resources:
+// the configured ones when set, otherwise the given per-service defaults.
+func (r *Resolver) selectors(defaultLabel, defaultField string) (string, string) {
+	if r.customSelectors() {
+		return r.cfg.LabelSelector, r.cfg.FieldSelector
+	}
+	return defaultLabel, defaultField
+}
+
+// informerKey identifies the informer factory shared by the app watches of
+// one client and namespace.
+type informerKey struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// sharedFactory is an informer factory started once for all app watches of
+// one client and namespace and shut down when the last of them releases it.
+type sharedFactory struct {
+	factory informers.SharedInformerFactory
+	// meta is created by the first watch that needs it.
+	meta *metaLookup
+	stop chan struct{}
+	refs int
+}
+
+// acquireFactory returns the informer factory shared by the app watches of
+// client and namespace and a func releasing it. The factory lists with the
+// custom selectors when set and otherwise every object of the namespace;
+// each watch picks its own service out of the shared cache.
+func (r *Resolver) acquireFactory(
+	client kubernetes.Interface,
+	namespace string,
+) (*sharedFactory, func()) {
+	key := informerKey{client: client, namespace: namespace}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.factories == nil {
+		r.factories = map[informerKey]*sharedFactory{}
+	}
+	shared := r.factories[key]
+	if shared == nil {
+		options := []informers.SharedInformerOption{informers.WithNamespace(namespace)}
+		if r.customSelectors() {
+			options = append(options, informers.WithTweakListOptions(
+				func(opts *metav1.ListOptions) {
+					opts.LabelSelector = r.cfg.LabelSelector
+					opts.FieldSelector = r.cfg.FieldSelector
+				},
+			))
+		}
+		shared = &sharedFactory{
+			factory: informers.NewSharedInformerFactoryWithOptions(
+				client,
+				r.cfg.ResyncPeriod,
+				options...,
+			),
+			stop: make(chan struct{}),
 		}
+		r.factories[key] = shared
+	}
+	shared.refs++
+
+	var once sync.Once
+	return shared, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			shared.refs--
+			last := shared.refs == 0
+			if last {
+				delete(r.factories, key)
+			}
+			meta := shared.meta
+			r.mu.Unlock()
+			if last {
+				close(shared.stop)
+				shared.factory.Shutdown()
+				if meta != nil {
+					for _, factory := range meta.factories {
+						factory.Shutdown()
+					}
+				}
+			}
+		})
 	}
 }
 
-func (r *Resolver) listEndpointSlice(
+// runInformer starts informer in the shared factory and publishes the state
+// built by toState once its cache syncs and after every add, update or delete
+// of an object owned by appName. The metadata in meta does not hold that
+// back: the state is published again once meta syncs and after every change
+// to it. It blocks until ctx is done; the informers relist and rewatch on
+// their own.
+func (r *Resolver) runInformer(
 	ctx context.Context,
-	client kubernetes.Interface,
+	shared *sharedFactory,
+	informer cache.SharedIndexInformer,
 	appName string,
-) (yresolver.State, error) {
-	opts := metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("kubernetes.io/service-name=%s", appName),
+	namespace string,
+	owns func(obj any) bool,
+	toState func() yresolver.State,
+	meta *metaLookup,
+	onSynced func(),
+) error {
+	var (
+		mu   sync.Mutex
+		last yresolver.State
+	)
+	publish := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		state := toState()
+		if last != nil && reflect.DeepEqual(last, state) {
+			return
+		}
+		last = state
+		r.notify(appName, r.setState(appName, namespace, state))
 	}
-	slices, err := client.DiscoveryV1().EndpointSlices(r.cfg.Namespace).List(ctx, opts)
+	// onEvent publishes after an event on an object accepted by accept.
+	onEvent := func(obj any, accept func(any) bool) {
+		if deleted, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = deleted.Obj
+		}
+		if !informer.HasSynced() || !accept(obj) {
+			return
+		}
+		publish()
+	}
+	handlers := func(accept func(any) bool) cache.ResourceEventHandlerFuncs {
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj any) { onEvent(obj, accept) },
+			UpdateFunc: func(_, obj any) { onEvent(obj, accept) },
+			DeleteFunc: func(obj any) { onEvent(obj, accept) },
+		}
+	}
+	reg, err := informer.AddEventHandler(handlers(owns))
 	if err != nil {
-		return nil, fmt.Errorf("failed to list endpointslices: %w", err)
+		return fmt.Errorf("failed to register informer handler: %w", err)
 	}
-	return r.endpointSlicesToState(slices.Items), nil
+	// The shared informer outlives this watch, so drop its handler on return.
+	defer func() { _ = informer.RemoveEventHandler(reg) }()
+	if meta != nil {
+		// Events replayed while the metadata syncs are covered by the
+		// publish that follows the sync.
+		metaSynced := func(any) bool { return meta.hasSynced() }
+		for _, item := range meta.informers {
+			metaReg, err := item.AddEventHandler(handlers(metaSynced))
+			if err != nil {
+				return fmt.Errorf("failed to register informer handler: %w", err)
+			}
+			defer func() { _ = item.RemoveEventHandler(metaReg) }()
+		}
+	}
+
+	shared.factory.Start(shared.stop)
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return ctx.Err()
+	}
+	publish()
+	if onSynced != nil {
+		onSynced()
+	}
+	if meta != nil {
+		go func() {
+			if cache.WaitForCacheSync(ctx.Done(), meta.hasSynced) && ctx.Err() == nil {
+				publish()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
 }
 
-func (r *Resolver) endpointSlicesToState(slices []discoveryv1.EndpointSlice) yresolver.State {
+func (r *Resolver) endpointSlicesToState(
+	slices []discoveryv1.EndpointSlice,
+	meta *metaLookup,
+) yresolver.State {
 	baseState := yresolver.BaseState{
 		Attributes: map[string]any{},
 		Endpoints:  []yresolver.Endpoint{},
 	}
+	localZone := r.localZone()
 
 	for _, slice := range slices {
 		for _, port := range slice.Ports {
@@ -493,7 +854,7 @@ func (r *Resolver) endpointSlicesToState(slices []discoveryv1.EndpointSlice) yre
 				continue
 			}
 			for _, endpoint := range slice.Endpoints {
-				if len(endpoint.Addresses) == 0 {
+				if len(endpoint.Addresses) == 0 || !r.endpointUsable(endpoint.Conditions) {
 					continue
 				}
 				for _, addr := range endpoint.Addresses {
@@ -502,6 +863,9 @@ func (r *Resolver) endpointSlicesToState(slices []discoveryv1.EndpointSlice) yre
 					}
 					endpointAddr := net.JoinHostPort(addr, strconv.Itoa(int(portNumber)))
 					attrs := map[string]any{}
+					if slice.Namespace != "" {
+						attrs["namespace"] = slice.Namespace
+					}
 					if endpoint.NodeName != nil {
 						attrs["nodeName"] = *endpoint.NodeName
 					}
@@ -512,6 +876,19 @@ func (r *Resolver) endpointSlicesToState(slices []discoveryv1.EndpointSlice) yre
 						attrs["targetRefKind"] = endpoint.TargetRef.Kind
 						attrs["targetRefName"] = endpoint.TargetRef.Name
 					}
+					r.copyObjectMeta(
+						attrs,
+						meta,
+						slice.Namespace,
+						slice.Labels[discoveryv1.LabelServiceName],
+						endpoint.TargetRef,
+					)
+					if hintZones := endpointHintZones(endpoint.Hints); len(hintZones) > 0 {
+						attrs["hintZones"] = hintZones
+						if localZone != "" {
+							attrs["preferred"] = containsString(hintZones, localZone)
+						}
+					}
 					for key, value := range r.cfg.EndpointAttributes {
 						attrs[key] = value
 					}
@@ -528,6 +905,170 @@ func (r *Resolver) endpointSlicesToState(slices []discoveryv1.EndpointSlice) yre
 	return baseState
 }
 
+// metaLookup reads the Services and Pods whose annotations and labels are
+// copied into endpoint attributes from informer caches shared by the app
+// watches of one client and namespace.
+type metaLookup struct {
+	factories []informers.SharedInformerFactory
+	informers []cache.SharedIndexInformer
+	services  corelisters.ServiceLister
+	pods      corelisters.PodLister
+}
+
+// hasSynced reports whether the Service and Pod caches have synced.
+func (m *metaLookup) hasSynced() bool {
+	for _, informer := range m.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// metaLookup returns the metadata lookup of shared, creating and starting it
+// on first use, when AnnotationKeys or LabelKeys is set, and nil otherwise.
+func (r *Resolver) metaLookup(
+	shared *sharedFactory,
+	client kubernetes.Interface,
+	namespace string,
+) *metaLookup {
+	if len(r.cfg.AnnotationKeys) == 0 && len(r.cfg.LabelKeys) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if shared.meta == nil {
+		shared.meta = r.newMetaLookup(client, namespace)
+		for _, factory := range shared.meta.factories {
+			factory.Start(shared.stop)
+		}
+	}
+	return shared.meta
+}
+
+// newMetaLookup watches the Services and the running Pods in namespace that
+// match MetadataLabelSelector. It uses factories of its own: the endpoint
+// factory's selectors match no Service or Pod. List and watch failures are
+// logged and leave the endpoints published without the missing metadata.
+func (r *Resolver) newMetaLookup(client kubernetes.Interface, namespace string) *metaLookup {
+	newFactory := func(fieldSelector string) informers.SharedInformerFactory {
+		return informers.NewSharedInformerFactoryWithOptions(
+			client,
+			r.cfg.ResyncPeriod,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = r.cfg.MetadataLabelSelector
+				opts.FieldSelector = fieldSelector
+			}),
+		)
+	}
+	serviceFactory := newFactory("")
+	// Only running Pods back ready or serving endpoints.
+	podFactory := newFactory(
+		fields.OneTermEqualSelector("status.phase", string(corev1.PodRunning)).String(),
+	)
+	services := serviceFactory.Core().V1().Services()
+	pods := podFactory.Core().V1().Pods()
+	meta := &metaLookup{
+		factories: []informers.SharedInformerFactory{serviceFactory, podFactory},
+		informers: []cache.SharedIndexInformer{services.Informer(), pods.Informer()},
+		services:  services.Lister(),
+		pods:      pods.Lister(),
+	}
+	for _, informer := range meta.informers {
+		_ = informer.SetWatchErrorHandler(r.logMetaWatchError)
+	}
+	return meta
+}
+
+// logMetaWatchError logs a failed Service or Pod list or watch, skipping the
+// closed and expired watches the reflector renews as a matter of course.
+func (r *Resolver) logMetaWatchError(reflector *cache.Reflector, err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		return
+	}
+	slog.Warn("kubernetes resolver metadata watch failed, endpoints published without it",
+		slog.String("resolver", r.name),
+		slog.String("type", reflector.TypeDescription()),
+		slog.Any("error", err))
+}
+
+// copyObjectMeta copies the configured annotations and labels of the Service
+// and then of the Pod behind one endpoint into attrs, so Pod values win.
+func (r *Resolver) copyObjectMeta(
+	attrs map[string]any,
+	meta *metaLookup,
+	namespace string,
+	service string,
+	targetRef *corev1.ObjectReference,
+) {
+	if meta == nil {
+		return
+	}
+	if service != "" {
+		if svc, err := meta.services.Services(namespace).Get(service); err == nil {
+			r.copyKeys(attrs, svc.ObjectMeta)
+		}
+	}
+	if targetRef != nil && targetRef.Kind == "Pod" {
+		podNamespace := targetRef.Namespace
+		if podNamespace == "" {
+			podNamespace = namespace
+		}
+		if pod, err := meta.pods.Pods(podNamespace).Get(targetRef.Name); err == nil {
+			r.copyKeys(attrs, pod.ObjectMeta)
+		}
+	}
+}
+
+func (r *Resolver) copyKeys(attrs map[string]any, object metav1.ObjectMeta) {
+	for _, key := range r.cfg.AnnotationKeys {
+		if value, ok := object.Annotations[key]; ok {
+			attrs[key] = value
+		}
+	}
+	for _, key := range r.cfg.LabelKeys {
+		if value, ok := object.Labels[key]; ok {
+			attrs[key] = value
+		}
+	}
+}
+
+func endpointHintZones(hints *discoveryv1.EndpointHints) []string {
+	if hints == nil || len(hints.ForZones) == 0 {
+		return nil
+	}
+	zones := make([]string, 0, len(hints.ForZones))
+	for _, zone := range hints.ForZones {
+		zones = append(zones, zone.Name)
+	}
+	return zones
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointUsable reports whether an EndpointSlice endpoint may receive traffic.
+// Unset conditions are treated as ready, matching the EndpointSlice API
+// semantics. Terminating endpoints are skipped unless IncludeTerminating is
+// set, in which case they are kept while still serving.
+func (r *Resolver) endpointUsable(conditions discoveryv1.EndpointConditions) bool {
+	if conditions.Terminating != nil && *conditions.Terminating {
+		if !r.cfg.IncludeTerminating {
+			return false
+		}
+		return conditions.Serving == nil || *conditions.Serving
+	}
+	return conditions.Ready == nil || *conditions.Ready
+}
+
 func (r *Resolver) slicePortValue(port discoveryv1.EndpointPort) (int32, bool) {
 	if port.Port == nil {
 		return 0, false
@@ -537,6 +1078,11 @@ func (r *Resolver) slicePortValue(port discoveryv1.EndpointPort) (int32, bool) {
 			return 0, false
 		}
 	}
+	if r.cfg.AppProtocol != "" {
+		if port.AppProtocol == nil || *port.AppProtocol != r.cfg.AppProtocol {
+			return 0, false
+		}
+	}
 	if r.cfg.Port != 0 && *port.Port != r.cfg.Port {
 		return 0, false
 	}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -75,6 +76,15 @@ func TestBackoffBounds(t *testing.T) {
 	if got := bo.Backoff(3); got != 3*time.Millisecond {
 		t.Fatalf("Backoff(3) = %v, want capped 3ms", got)
 	}
+
+	if got := NormalizeConfig(ResolverConfig{}).Backoff.Strategy; got != BackoffExponential {
+		t.Fatalf("default strategy = %q, want %q", got, BackoffExponential)
+	}
+	if _, err := NewResolver("default", ResolverConfig{
+		Backoff: BackoffConfig{Strategy: "fibonacci"},
+	}); err == nil {
+		t.Fatal("NewResolver() expected unsupported backoff strategy error")
+	}
 }
 
 func TestResolverProviderUsesLoader(t *testing.T) {
@@ -109,7 +119,7 @@ func TestEndpointsToState(t *testing.T) {
 		}},
 	}
 
-	state := r.endpointsToState(endpoints)
+	state := r.endpointsToState(endpoints, nil)
 	if state.GetAttributes()["service"] != "test-svc" {
 		t.Fatalf("service attribute = %v, want test-svc", state.GetAttributes()["service"])
 	}
@@ -140,7 +150,7 @@ func TestEndpointSlicesToState(t *testing.T) {
 		}},
 	}}
 
-	state := r.endpointSlicesToState(slices)
+	state := r.endpointSlicesToState(slices, nil)
 	items := state.GetEndpoints()
 	if len(items) != 2 {
 		t.Fatalf("endpoints len = %d, want 2", len(items))
@@ -156,6 +166,111 @@ func TestEndpointSlicesToState(t *testing.T) {
 	}
 }
 
+func TestEndpointSlicesToStateSkipsNotReadyEndpoints(t *testing.T) {
+	portNum := int32(8080)
+	ready, notReady := true, false
+	terminating := true
+	slices := []discoveryv1.EndpointSlice{{
+		ObjectMeta:  metav1.ObjectMeta{Name: "test-svc-abc", Namespace: "default"},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Port: &portNum}},
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses:  []string{"10.0.0.5"},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			},
+			{
+				Addresses:  []string{"10.0.0.6"},
+				Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+			},
+			{
+				Addresses: []string{"10.0.0.7"},
+				Conditions: discoveryv1.EndpointConditions{
+					Ready:       &notReady,
+					Serving:     &ready,
+					Terminating: &terminating,
+				},
+			},
+		},
+	}}
+
+	r := &Resolver{cfg: ResolverConfig{Protocol: "grpc"}}
+	items := r.endpointSlicesToState(slices, nil).GetEndpoints()
+	if len(items) != 1 || items[0].GetAddress() != "10.0.0.5:8080" {
+		t.Fatalf("endpoints = %v, want only 10.0.0.5:8080", items)
+	}
+
+	r.cfg.IncludeTerminating = true
+	items = r.endpointSlicesToState(slices, nil).GetEndpoints()
+	if len(items) != 2 || items[1].GetAddress() != "10.0.0.7:8080" {
+		t.Fatalf("endpoints with terminating = %v, want 10.0.0.5:8080 and 10.0.0.7:8080", items)
+	}
+}
+
+func TestEndpointSlicesToStateMarksPreferredZone(t *testing.T) {
+	portNum := int32(8080)
+	slices := []discoveryv1.EndpointSlice{{
+		ObjectMeta:  metav1.ObjectMeta{Name: "test-svc-abc", Namespace: "default"},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Port: &portNum}},
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses: []string{"10.0.0.8"},
+				Zone:      strPtr("zone-a"),
+				Hints: &discoveryv1.EndpointHints{
+					ForZones: []discoveryv1.ForZone{{Name: "zone-a"}},
+				},
+			},
+			{
+				Addresses: []string{"10.0.0.9"},
+				Zone:      strPtr("zone-b"),
+				Hints: &discoveryv1.EndpointHints{
+					ForZones: []discoveryv1.ForZone{{Name: "zone-b"}},
+				},
+			},
+		},
+	}}
+
+	r := &Resolver{cfg: NormalizeConfig(ResolverConfig{PreferLocalZone: true, Zone: "zone-a"})}
+	items := r.endpointSlicesToState(slices, nil).GetEndpoints()
+	if len(items) != 2 {
+		t.Fatalf("endpoints len = %d, want 2", len(items))
+	}
+	if items[0].GetAttributes()["preferred"] != true {
+		t.Fatalf("same-zone endpoint attributes = %#v, want preferred", items[0].GetAttributes())
+	}
+	if items[1].GetAttributes()["preferred"] != false {
+		t.Fatalf("other-zone endpoint attributes = %#v, want not preferred", items[1].GetAttributes())
+	}
+	hintZones, _ := items[1].GetAttributes()["hintZones"].([]string)
+	if len(hintZones) != 1 || hintZones[0] != "zone-b" {
+		t.Fatalf("hintZones = %#v, want [zone-b]", items[1].GetAttributes()["hintZones"])
+	}
+
+	r = &Resolver{cfg: ResolverConfig{}}
+	attrs := r.endpointSlicesToState(slices, nil).GetEndpoints()[0].GetAttributes()
+	if _, ok := attrs["preferred"]; ok {
+		t.Fatal("preferred attribute set without PreferLocalZone")
+	}
+}
+
+func TestResolverLooksUpLocalZoneFromNode(t *testing.T) {
+	t.Setenv("KUBERNETES_ZONE", "")
+	t.Setenv("KUBERNETES_NODE_NAME", "node-1")
+	client := k8sfake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{corev1.LabelTopologyZone: "zone-c"},
+		},
+	})
+
+	r := &Resolver{cfg: NormalizeConfig(ResolverConfig{PreferLocalZone: true})}
+	r.lookupLocalZone(context.Background(), client)
+	if got := r.localZone(); got != "zone-c" {
+		t.Fatalf("localZone() = %q, want zone-c", got)
+	}
+}
+
 func TestResolverTypeAndSelectPort(t *testing.T) {
 	r := &Resolver{cfg: ResolverConfig{PortName: "grpc", Port: 9090}}
 	if got := r.Type(); got != "kubernetes" {
@@ -181,6 +296,160 @@ func TestResolverTypeAndSelectPort(t *testing.T) {
 	}
 }
 
+func TestResolverSelectsPortByAppProtocol(t *testing.T) {
+	grpcProtocol, h2cProtocol := "grpc", "kubernetes.io/h2c"
+	r := &Resolver{cfg: ResolverConfig{AppProtocol: "grpc"}}
+
+	ports := []corev1.EndpointPort{
+		{Port: 8080, AppProtocol: &h2cProtocol},
+		{Port: 9090, AppProtocol: &grpcProtocol},
+	}
+	if port := r.selectPort(ports); port == nil || port.Port != 9090 {
+		t.Fatalf("selectPort() by appProtocol = %#v, want 9090", port)
+	}
+
+	h2cPort, grpcPort := int32(8080), int32(9090)
+	state := r.endpointSlicesToState([]discoveryv1.EndpointSlice{{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-1", Namespace: "default"},
+		Ports: []discoveryv1.EndpointPort{
+			{Port: &h2cPort, AppProtocol: &h2cProtocol},
+			{Port: &grpcPort, AppProtocol: &grpcProtocol},
+		},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.5.1"}}},
+	}}, nil)
+	endpoints := state.GetEndpoints()
+	if len(endpoints) != 1 || endpoints[0].GetAddress() != "10.0.5.1:9090" {
+		t.Fatalf("endpointslice endpoints = %#v, want only 10.0.5.1:9090", endpoints)
+	}
+}
+
+func newObjectMetaClient() *k8sfake.Clientset {
+	port := int32(8080)
+	client := k8sfake.NewSimpleClientset(
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "svc-abc",
+				Namespace: "default",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "svc"},
+			},
+			Ports: []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{{
+				Addresses: []string{"10.0.0.1"},
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-1"},
+			}},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:        "svc",
+			Namespace:   "default",
+			Annotations: map[string]string{"team": "payments", "version": "v1"},
+		}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pod-1",
+				Namespace:   "default",
+				Annotations: map[string]string{"version": "v2"},
+				Labels:      map[string]string{"track": "canary"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+	return client
+}
+
+// waitForAttribute returns the attributes of the single endpoint of the
+// first state whose endpoint carries key; states published before the
+// metadata caches sync lack it.
+func waitForAttribute(t *testing.T, rec *stateRecorder, key string) map[string]any {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case state := <-rec.ch:
+			items := state.GetEndpoints()
+			if len(items) != 1 {
+				t.Fatalf("endpoints len = %d, want 1", len(items))
+			}
+			if attrs := items[0].GetAttributes(); attrs[key] != nil {
+				return attrs
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for endpoint attribute %q", key)
+		}
+	}
+}
+
+func TestResolverCopiesObjectMetaFromServiceAndPod(t *testing.T) {
+	client := newObjectMetaClient()
+	r, err := NewResolver("default", ResolverConfig{
+		Namespace:      "default",
+		Mode:           string(modeEndpointSlice),
+		AnnotationKeys: []string{"team", "version", "missing"},
+		LabelKeys:      []string{"track"},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+
+	rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+	if err := r.AddWatch("svc", rec); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+	t.Cleanup(func() { _ = r.DelWatch("svc", rec) })
+
+	attrs := waitForAttribute(t, rec, "track")
+	if attrs["team"] != "payments" {
+		t.Fatalf("team = %v, want payments", attrs["team"])
+	}
+	if attrs["version"] != "v2" {
+		t.Fatalf("version = %v, want pod value v2", attrs["version"])
+	}
+	if attrs["track"] != "canary" {
+		t.Fatalf("track = %v, want canary", attrs["track"])
+	}
+	if _, ok := attrs["missing"]; ok {
+		t.Fatalf("missing annotation should be omitted, attrs = %v", attrs)
+	}
+}
+
+func TestResolverPublishesWithoutPodMetaWhenPodsForbidden(t *testing.T) {
+	client := newObjectMetaClient()
+	client.PrependReactor("list", "pods",
+		func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("pods is forbidden")
+		},
+	)
+	r, err := NewResolver("default", ResolverConfig{
+		Namespace:      "default",
+		Mode:           string(modeEndpointSlice),
+		AnnotationKeys: []string{"team", "version"},
+		LabelKeys:      []string{"track"},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+
+	rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+	if err := r.AddWatch("svc", rec); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+	t.Cleanup(func() { _ = r.DelWatch("svc", rec) })
+
+	select {
+	case state := <-rec.ch:
+		items := state.GetEndpoints()
+		if len(items) != 1 || items[0].GetAddress() != "10.0.0.1:8080" {
+			t.Fatalf("endpoints = %#v, want 10.0.0.1:8080", items)
+		}
+		if track := items[0].GetAttributes()["track"]; track != nil {
+			t.Fatalf("track = %v, want no pod metadata", track)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for state without pod metadata")
+	}
+}
+
 func TestResolverWatchesEndpoints(t *testing.T) {
 	//nolint:staticcheck // Intentional coverage for deprecated Endpoints compatibility path.
 	endpoints := &corev1.Endpoints{
@@ -351,6 +620,362 @@ func TestResolverWatchesEndpointSlices(t *testing.T) {
 	}
 }
 
+func TestResolverUsesCustomSelectors(t *testing.T) {
+	type restriction struct{ labels, fields string }
+	recordSelectors := func(client *k8sfake.Clientset, resource string) func() []restriction {
+		var (
+			mu   sync.Mutex
+			seen []restriction
+		)
+		record := func(action k8stesting.Action) {
+			var item restriction
+			switch a := action.(type) {
+			case k8stesting.ListActionImpl:
+				r := a.GetListRestrictions()
+				item = restriction{r.Labels.String(), r.Fields.String()}
+			case k8stesting.WatchActionImpl:
+				r := a.GetWatchRestrictions()
+				item = restriction{r.Labels.String(), r.Fields.String()}
+			default:
+				return
+			}
+			mu.Lock()
+			seen = append(seen, item)
+			mu.Unlock()
+		}
+		client.PrependReactor("list", resource,
+			func(action k8stesting.Action) (bool, runtime.Object, error) {
+				record(action)
+				return false, nil, nil
+			},
+		)
+		client.PrependWatchReactor(resource,
+			func(action k8stesting.Action) (bool, watch.Interface, error) {
+				record(action)
+				return false, nil, nil
+			},
+		)
+		return func() []restriction {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]restriction(nil), seen...)
+		}
+	}
+	waitForEndpoints := func(t *testing.T, rec *stateRecorder, want int) {
+		t.Helper()
+		select {
+		case st := <-rec.ch:
+			if len(st.GetEndpoints()) != want {
+				t.Fatalf("endpoints = %#v, want %d", st.GetEndpoints(), want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for resolver state")
+		}
+	}
+
+	t.Run("endpointslice", func(t *testing.T) {
+		port := int32(9090)
+		client := k8sfake.NewSimpleClientset(&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "payments-pods",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "payments"},
+			},
+			Ports:     []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.3.1"}}},
+		})
+		seen := recordSelectors(client, "endpointslices")
+
+		r, err := NewResolver("default", ResolverConfig{
+			Namespace:     "default",
+			Mode:          string(modeEndpointSlice),
+			LabelSelector: "app=payments",
+			FieldSelector: "metadata.namespace=default",
+		})
+		if err != nil {
+			t.Fatalf("NewResolver() error = %v", err)
+		}
+		r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+		rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+		if err := r.AddWatch("payments", rec); err != nil {
+			t.Fatalf("AddWatch() error = %v", err)
+		}
+		t.Cleanup(func() { _ = r.DelWatch("payments", rec) })
+		waitForEndpoints(t, rec, 1)
+
+		want := restriction{"app=payments", "metadata.namespace=default"}
+		got := seen()
+		if len(got) < 3 {
+			t.Fatalf("recorded selectors = %v, want probe list, informer list and watch", got)
+		}
+		for _, item := range got {
+			if item != want {
+				t.Fatalf("selectors = %v, want %v", item, want)
+			}
+		}
+	})
+
+	t.Run("endpoints", func(t *testing.T) {
+		newEndpoints := func(name, ip string) *corev1.Endpoints {
+			return &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Labels:    map[string]string{"app": "payments"},
+				},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{{IP: ip}},
+					Ports:     []corev1.EndpointPort{{Port: 8080}},
+				}},
+			}
+		}
+		client := k8sfake.NewSimpleClientset(
+			newEndpoints("payments-a", "10.0.4.1"),
+			newEndpoints("payments-b", "10.0.4.2"),
+		)
+		seen := recordSelectors(client, "endpoints")
+
+		r, err := NewResolver("default", ResolverConfig{
+			Namespace:     "default",
+			Mode:          "endpoints",
+			LabelSelector: "app=payments",
+		})
+		if err != nil {
+			t.Fatalf("NewResolver() error = %v", err)
+		}
+		r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+		rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+		if err := r.AddWatch("payments", rec); err != nil {
+			t.Fatalf("AddWatch() error = %v", err)
+		}
+		t.Cleanup(func() { _ = r.DelWatch("payments", rec) })
+		waitForEndpoints(t, rec, 2)
+
+		got := seen()
+		if len(got) == 0 {
+			t.Fatal("no endpoints list or watch recorded")
+		}
+		for _, item := range got {
+			if item != (restriction{"app=payments", ""}) {
+				t.Fatalf("selectors = %v, want label app=payments without field selector", item)
+			}
+		}
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		if _, err := NewResolver("default", ResolverConfig{LabelSelector: "app in ("}); err == nil {
+			t.Fatal("NewResolver() expected invalid label selector error")
+		}
+		if _, err := NewResolver("default", ResolverConfig{FieldSelector: "metadata.name"}); err == nil {
+			t.Fatal("NewResolver() expected invalid field selector error")
+		}
+	})
+}
+
+func TestResolverInformerTracksEndpointSliceEvents(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	r, err := NewResolver("default", ResolverConfig{
+		Namespace:    "default",
+		Mode:         string(modeEndpointSlice),
+		ResyncPeriod: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+
+	rec := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	if err := r.AddWatch("svc", rec); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+	defer func() { _ = r.DelWatch("svc", rec) }()
+
+	expectEndpoints := func(stage string, want int) {
+		t.Helper()
+		select {
+		case st := <-rec.ch:
+			if got := len(st.GetEndpoints()); got != want {
+				t.Fatalf("%s: endpoints len = %d, want %d", stage, got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: timeout waiting for resolver state", stage)
+		}
+	}
+	expectEndpoints("initial sync", 0)
+
+	port := int32(9090)
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "svc-1",
+			Namespace: "default",
+			Labels:    map[string]string{"kubernetes.io/service-name": "svc"},
+		},
+		Ports:     []discoveryv1.EndpointPort{{Port: &port}},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.3.1"}}},
+	}
+	slices := client.DiscoveryV1().EndpointSlices("default")
+	ctx := context.Background()
+	if _, err := slices.Create(ctx, slice, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	expectEndpoints("add", 1)
+
+	slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.3.2"}})
+	if _, err := slices.Update(ctx, slice, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	expectEndpoints("update", 2)
+
+	if err := slices.Delete(ctx, slice.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	expectEndpoints("delete", 0)
+}
+
+func TestResolverSharesInformerAcrossServices(t *testing.T) {
+	port := int32(9090)
+	newSlice := func(service, addr string) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      service + "-1",
+				Namespace: "default",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+			},
+			Ports:     []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{{Addresses: []string{addr}}},
+		}
+	}
+	client := k8sfake.NewSimpleClientset(
+		newSlice("orders", "10.0.6.1"),
+		newSlice("payments", "10.0.6.2"),
+	)
+
+	r, err := NewResolver("default", ResolverConfig{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+
+	recs := map[string]*stateRecorder{}
+	for service, addr := range map[string]string{
+		"orders":   "10.0.6.1:9090",
+		"payments": "10.0.6.2:9090",
+	} {
+		rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+		recs[service] = rec
+		if err := r.AddWatch(service, rec); err != nil {
+			t.Fatalf("AddWatch(%s) error = %v", service, err)
+		}
+		select {
+		case st := <-rec.ch:
+			eps := st.GetEndpoints()
+			if len(eps) != 1 || eps[0].GetAddress() != addr {
+				t.Fatalf("%s endpoints = %#v, want %s", service, eps, addr)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %s state", service)
+		}
+	}
+	r.mu.Lock()
+	refs := 0
+	if shared := r.factories[informerKey{client: client, namespace: "default"}]; shared != nil {
+		refs = shared.refs
+	}
+	factories := len(r.factories)
+	r.mu.Unlock()
+	if factories != 1 || refs != 2 {
+		t.Fatalf("shared factories = %d with %d refs, want 1 used by both services",
+			factories, refs)
+	}
+
+	for service, rec := range recs {
+		if err := r.DelWatch(service, rec); err != nil {
+			t.Fatalf("DelWatch(%s) error = %v", service, err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.mu.Lock()
+		remaining := len(r.factories)
+		r.mu.Unlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("shared factories = %d after DelWatch, want 0", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResolverWatchesServiceAcrossNamespaces(t *testing.T) {
+	port := int32(9090)
+	newSlice := func(namespace, addr string) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "svc-1",
+				Namespace: namespace,
+				Labels: map[string]string{
+					"kubernetes.io/service-name": "svc",
+				},
+			},
+			Ports:     []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{{Addresses: []string{addr}}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		cfg  ResolverConfig
+	}{
+		{name: "explicit namespaces", cfg: ResolverConfig{Namespaces: []string{"team-a", "team-b"}}},
+		{name: "all namespaces", cfg: ResolverConfig{Namespaces: []string{"*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KUBERNETES_NAMESPACE", "")
+			client := k8sfake.NewSimpleClientset(
+				newSlice("team-a", "10.0.1.1"),
+				newSlice("team-b", "10.0.2.1"),
+			)
+			r, err := NewResolver("default", tt.cfg)
+			if err != nil {
+				t.Fatalf("NewResolver() error = %v", err)
+			}
+			r.clientForConfig = func(string) (kubernetes.Interface, error) { return client, nil }
+
+			rec := &stateRecorder{ch: make(chan yresolver.State, 4)}
+			if err := r.AddWatch("svc", rec); err != nil {
+				t.Fatalf("AddWatch() error = %v", err)
+			}
+			defer func() { _ = r.DelWatch("svc", rec) }()
+
+			want := map[string]string{
+				"10.0.1.1:9090": "team-a",
+				"10.0.2.1:9090": "team-b",
+			}
+			deadline := time.After(2 * time.Second)
+			for {
+				select {
+				case st := <-rec.ch:
+					if len(st.GetEndpoints()) != len(want) {
+						continue
+					}
+					for _, endpoint := range st.GetEndpoints() {
+						namespace := endpoint.GetAttributes()["namespace"]
+						if want[endpoint.GetAddress()] != namespace {
+							t.Fatalf("endpoint %s namespace = %v", endpoint.GetAddress(), namespace)
+						}
+					}
+					return
+				case <-deadline:
+					t.Fatal("timeout waiting for endpoints from both namespaces")
+				}
+			}
+		})
+	}
+}
+
 func TestResolverFallsBackFromEndpointSliceToEndpoints(t *testing.T) {
 	//nolint:staticcheck // Intentional coverage for deprecated Endpoints compatibility path.
 	endpoints := &corev1.Endpoints{
@@ -363,9 +988,10 @@ func TestResolverFallsBackFromEndpointSliceToEndpoints(t *testing.T) {
 	}
 	client := k8sfake.NewSimpleClientset(endpoints)
 	endpointWatch := watch.NewFake()
-	client.PrependWatchReactor(
+	client.PrependReactor(
+		"list",
 		"endpointslices",
-		func(action k8stesting.Action) (bool, watch.Interface, error) {
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
 			return true, nil, errors.New("endpoint slices unavailable")
 		},
 	)
@@ -424,7 +1050,7 @@ func TestResolverWatchLoopStopsAfterContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{})
 	go func() {
-		r.watchLoop(ctx, "svc")
+		r.watchLoop(ctx, "svc", "default")
 		close(done)
 	}()
 	time.Sleep(10 * time.Millisecond)
@@ -531,58 +1157,28 @@ func TestResolverWatchAndListErrorPaths(t *testing.T) {
 		r.clientForConfig = func(string) (kubernetes.Interface, error) {
 			return nil, errors.New("client boom")
 		}
-		if err := r.watch(context.Background(), "svc"); err == nil ||
+		if err := r.watch(context.Background(), "svc", "default", nil); err == nil ||
 			!strings.Contains(err.Error(), "failed to get kube client") {
 			t.Fatalf("watch() error = %v, want kube client error", err)
 		}
 	})
 
-	t.Run("watch endpoints watch error", func(t *testing.T) {
-		client := k8sfake.NewSimpleClientset()
-		client.PrependWatchReactor(
-			"endpoints",
-			func(action k8stesting.Action) (bool, watch.Interface, error) {
-				return true, nil, errors.New("watch boom")
-			},
-		)
-		r := &Resolver{cfg: ResolverConfig{Namespace: "default"}}
-		if err := r.watchEndpoints(context.Background(), client, "svc"); err == nil ||
-			!strings.Contains(err.Error(), "failed to watch endpoints") {
-			t.Fatalf("watchEndpoints() error = %v, want watch error", err)
-		}
-	})
-
 	t.Run("watch endpoints list error", func(t *testing.T) {
 		client := k8sfake.NewSimpleClientset()
-		fw := watch.NewFake()
-		client.PrependWatchReactor(
+		client.PrependReactor(
+			"list",
 			"endpoints",
-			func(action k8stesting.Action) (bool, watch.Interface, error) {
-				return true, fw, nil
+			func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("list boom")
 			},
 		)
 		r := &Resolver{cfg: ResolverConfig{Namespace: "default"}}
-		if err := r.watchEndpoints(context.Background(), client, "missing"); err == nil ||
-			!strings.Contains(err.Error(), "failed to list endpoints") {
+		err := r.watchEndpoints(context.Background(), client, "missing", "default", nil)
+		if err == nil || !strings.Contains(err.Error(), "failed to list endpoints") {
 			t.Fatalf("watchEndpoints() error = %v, want list error", err)
 		}
 	})
 
-	t.Run("watch endpointslices watch error", func(t *testing.T) {
-		client := k8sfake.NewSimpleClientset()
-		client.PrependWatchReactor(
-			"endpointslices",
-			func(action k8stesting.Action) (bool, watch.Interface, error) {
-				return true, nil, errors.New("watch boom")
-			},
-		)
-		r := &Resolver{cfg: ResolverConfig{Namespace: "default"}}
-		if err := r.watchEndpointSlice(context.Background(), client, "svc"); err == nil ||
-			!strings.Contains(err.Error(), "failed to watch endpointslices") {
-			t.Fatalf("watchEndpointSlice() error = %v, want watch error", err)
-		}
-	})
-
 	t.Run("watch endpointslices list error", func(t *testing.T) {
 		client := k8sfake.NewSimpleClientset()
 		fw := watch.NewFake()
@@ -605,8 +1201,8 @@ func TestResolverWatchAndListErrorPaths(t *testing.T) {
 			watchers: map[string]map[yresolver.Client]struct{}{},
 			cancels:  map[string]context.CancelFunc{},
 		}
-		if err := r.watchEndpointSlice(context.Background(), client, "missing"); err == nil ||
-			!strings.Contains(err.Error(), "failed to list endpointslices") {
+		err := r.watchEndpointSlice(context.Background(), client, "missing", "default", nil)
+		if err == nil || !strings.Contains(err.Error(), "failed to list endpointslices") {
 			t.Fatalf("watchEndpointSlice() error = %v, want list error", err)
 		}
 	})
@@ -641,7 +1237,7 @@ func TestResolverStateConversionFilters(t *testing.T) {
 		},
 	}
 
-	state := r.endpointsToState(endpoints)
+	state := r.endpointsToState(endpoints, nil)
 	items := state.GetEndpoints()
 	if len(items) != 1 {
 		t.Fatalf("endpoints len = %d, want 1", len(items))
@@ -703,7 +1299,7 @@ func TestEndpointSlicesToStateAndSlicePortValueFilters(t *testing.T) {
 		},
 	}}
 
-	state := r.endpointSlicesToState(slices)
+	state := r.endpointSlicesToState(slices, nil)
 	items := state.GetEndpoints()
 	if len(items) != 1 {
 		t.Fatalf("endpoints len = %d, want 1", len(items))
@@ -0,0 +1,96 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backoff computes watch reconnection delays for the Kubernetes
+// resolver and config sources.
+package backoff
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Supported backoff strategies.
+const (
+	// StrategyConstant waits BaseDelay before every retry.
+	StrategyConstant = "constant"
+	// StrategyLinear waits BaseDelay * (retry + 1).
+	StrategyLinear = "linear"
+	// StrategyExponential waits BaseDelay * Multiplier^retry.
+	StrategyExponential = "exponential"
+)
+
+// Config configures watch retry timing.
+type Config struct {
+	// Strategy is "constant", "linear" or "exponential". Empty means the
+	// caller's default.
+	Strategy   string        `mapstructure:"strategy"`
+	BaseDelay  time.Duration `mapstructure:"base_delay"`
+	Multiplier float64       `mapstructure:"multiplier"`
+	Jitter     float64       `mapstructure:"jitter"`
+	MaxDelay   time.Duration `mapstructure:"max_delay"`
+}
+
+// Validate reports an unsupported strategy.
+func (c Config) Validate() error {
+	switch c.Strategy {
+	case "", StrategyConstant, StrategyLinear, StrategyExponential:
+		return nil
+	default:
+		return fmt.Errorf("unsupported backoff strategy %q", c.Strategy)
+	}
+}
+
+// Backoff computes the delay before a retry.
+type Backoff struct {
+	cfg Config
+}
+
+// New creates a Backoff. An empty strategy is treated as exponential.
+func New(cfg Config) *Backoff {
+	return &Backoff{cfg: cfg}
+}
+
+// Backoff returns the delay before the given retry, with jitter applied and
+// capped at MaxDelay when it is set.
+func (b *Backoff) Backoff(retry int) time.Duration {
+	var delay float64
+	switch b.cfg.Strategy {
+	case StrategyConstant:
+		delay = float64(b.cfg.BaseDelay)
+	case StrategyLinear:
+		delay = float64(b.cfg.BaseDelay) * float64(retry+1)
+	default:
+		if retry == 0 {
+			return b.cap(b.cfg.BaseDelay)
+		}
+		delay = float64(b.cfg.BaseDelay) * math.Pow(b.cfg.Multiplier, float64(retry))
+	}
+	if b.cfg.Jitter > 0 {
+		delay *= 1.0 + b.cfg.Jitter*(2*rand.Float64()-1.0) //nolint:gosec
+	}
+	if b.cfg.MaxDelay > 0 && delay > float64(b.cfg.MaxDelay) {
+		return b.cfg.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+func (b *Backoff) cap(delay time.Duration) time.Duration {
+	if b.cfg.MaxDelay > 0 && delay > b.cfg.MaxDelay {
+		return b.cfg.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffStrategySequences(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []time.Duration
+	}{
+		{
+			name: "constant",
+			cfg:  Config{Strategy: StrategyConstant, BaseDelay: 100 * time.Millisecond},
+			want: []time.Duration{
+				100 * time.Millisecond,
+				100 * time.Millisecond,
+				100 * time.Millisecond,
+				100 * time.Millisecond,
+			},
+		},
+		{
+			name: "linear capped",
+			cfg: Config{
+				Strategy:  StrategyLinear,
+				BaseDelay: 100 * time.Millisecond,
+				MaxDelay:  350 * time.Millisecond,
+			},
+			want: []time.Duration{
+				100 * time.Millisecond,
+				200 * time.Millisecond,
+				300 * time.Millisecond,
+				350 * time.Millisecond,
+				350 * time.Millisecond,
+			},
+		},
+		{
+			name: "exponential capped",
+			cfg: Config{
+				Strategy:   StrategyExponential,
+				BaseDelay:  100 * time.Millisecond,
+				Multiplier: 2,
+				MaxDelay:   time.Second,
+			},
+			want: []time.Duration{
+				100 * time.Millisecond,
+				200 * time.Millisecond,
+				400 * time.Millisecond,
+				800 * time.Millisecond,
+				time.Second,
+				time.Second,
+			},
+		},
+		{
+			name: "empty strategy is exponential",
+			cfg:  Config{BaseDelay: time.Millisecond, Multiplier: 3},
+			want: []time.Duration{time.Millisecond, 3 * time.Millisecond, 9 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bo := New(tt.cfg)
+			for retry, want := range tt.want {
+				if got := bo.Backoff(retry); got != want {
+					t.Fatalf("Backoff(%d) = %v, want %v", retry, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffJitterStaysWithinBoundsAndCap(t *testing.T) {
+	bo := New(Config{
+		Strategy:  StrategyConstant,
+		BaseDelay: 100 * time.Millisecond,
+		Jitter:    0.5,
+		MaxDelay:  120 * time.Millisecond,
+	})
+	for i := 0; i < 100; i++ {
+		got := bo.Backoff(i)
+		if got < 50*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("Backoff(%d) = %v, want within [50ms, 120ms]", i, got)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, strategy := range []string{"", StrategyConstant, StrategyLinear, StrategyExponential} {
+		if err := (Config{Strategy: strategy}).Validate(); err != nil {
+			t.Fatalf("Validate(%q) error = %v", strategy, err)
+		}
+	}
+	if err := (Config{Strategy: "fibonacci"}).Validate(); err == nil {
+		t.Fatal("Validate(fibonacci) error = nil, want error")
+	}
+}
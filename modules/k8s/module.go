@@ -79,6 +79,30 @@ func NewSecretSource(cfg ConfigSourceConfig) (source.Source, error) {
 	return configsource.NewSecretSource(cfg)
 }
 
+// NewMergedConfigMapSource creates a config source that deep-merges several
+// ConfigMaps, with later entries overriding earlier ones.
+func NewMergedConfigMapSource(cfgs []ConfigSourceConfig) (source.Source, error) {
+	return configsource.NewMergedConfigMapSource(cfgs)
+}
+
+// NewPriorityConfigMapSource creates a config source that deep-merges the
+// ConfigMaps listed in cfg.ConfigMaps, with higher priorities winning.
+func NewPriorityConfigMapSource(cfg ConfigSourceConfig) (source.Source, error) {
+	return configsource.NewPriorityConfigMapSource(cfg)
+}
+
+// NewTLSSecretSource creates a source of TLS configs backed by a
+// kubernetes.io/tls Secret.
+func NewTLSSecretSource(cfg ConfigSourceConfig) (*configsource.TLSSecretSource, error) {
+	return configsource.NewTLSSecretSource(cfg)
+}
+
+// NewCompositeSource creates a config source that resolves each key from the
+// first of sources that has it, with later sources filling the gaps.
+func NewCompositeSource(name string, sources ...source.Source) (source.Source, error) {
+	return configsource.NewCompositeSource(name, sources...)
+}
+
 // WithConfigMapSource registers an explicit ConfigMap-backed config source.
 func WithConfigMapSource(
 	name string,
@@ -156,8 +180,9 @@ func (m *k8sModule) Capabilities() []module.Capability {
 
 func (m *k8sModule) ConfigSourceBuilders() map[string]configchain.ContextBuilder {
 	return map[string]configchain.ContextBuilder{
-		configsource.KindConfigMap: m.configMapSourceBuilder,
-		configsource.KindSecret:    m.secretSourceBuilder,
+		configsource.KindConfigMap:       m.configMapSourceBuilder,
+		configsource.KindSecret:          m.secretSourceBuilder,
+		configsource.KindMergedConfigMap: m.mergedConfigMapSourceBuilder,
 	}
 }
 
@@ -175,6 +200,13 @@ func (m *k8sModule) secretSourceBuilder(
 	return m.buildConfigSource(configsource.KindSecret, spec)
 }
 
+func (m *k8sModule) mergedConfigMapSourceBuilder(
+	_ configchain.BuildContext,
+	spec configchain.SourceSpec,
+) (source.Source, config.Priority, error) {
+	return m.buildConfigSource(configsource.KindMergedConfigMap, spec)
+}
+
 func (m *k8sModule) buildConfigSource(
 	kind string,
 	spec configchain.SourceSpec,
@@ -194,6 +226,9 @@ func (m *k8sModule) buildConfigSource(
 	case configsource.KindSecret:
 		src, err := NewSecretSource(cfg)
 		return src, priority, err
+	case configsource.KindMergedConfigMap:
+		src, err := NewPriorityConfigMapSource(cfg)
+		return src, priority, err
 	default:
 		return nil, 0, fmt.Errorf("unsupported config source kind %q", kind)
 	}
@@ -134,6 +134,33 @@ func TestModuleConfigSourceBuilders(t *testing.T) {
 			secretSource.Name(),
 		)
 	}
+
+	mergedSource, _, err := builders["kubernetes-configmap-merged"](
+		configchain.BuildContext{},
+		configchain.SourceSpec{
+			Kind:     "kubernetes-configmap-merged",
+			Priority: "remote",
+			Config: map[string]any{
+				"namespace": "default",
+				"key":       "config.yaml",
+				"configmaps": []any{
+					map[string]any{"name": "overlay", "priority": 10},
+					map[string]any{"name": "base"},
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("merged configmap builder() error = %v", err)
+	}
+	if mergedSource.Kind() != "kubernetes-configmap-merged" ||
+		mergedSource.Name() != "base+overlay" {
+		t.Fatalf(
+			"unexpected merged source: kind=%s name=%s",
+			mergedSource.Kind(),
+			mergedSource.Name(),
+		)
+	}
 }
 
 func TestRootConfigSourceHelpers(t *testing.T) {
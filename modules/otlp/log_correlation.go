@@ -0,0 +1,64 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	logTraceIDKey = "trace_id"
+	logSpanIDKey  = "span_id"
+)
+
+// traceContextHandler adds the IDs of the span active in the logging context
+// to every record.
+type traceContextHandler struct {
+	base slog.Handler
+}
+
+// NewTraceContextHandler wraps base so that records logged with a context
+// carrying a valid span get trace_id and span_id attributes, letting log
+// backends correlate them with the exported trace.
+func NewTraceContextHandler(base slog.Handler) slog.Handler {
+	return &traceContextHandler{base: base}
+}
+
+func (h *traceContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.base.Enabled(ctx, level)
+}
+
+func (h *traceContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if spanCtx.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String(logTraceIDKey, spanCtx.TraceID().String()),
+			slog.String(logSpanIDKey, spanCtx.SpanID().String()),
+		)
+	}
+	return h.base.Handle(ctx, r)
+}
+
+func (h *traceContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceContextHandler{base: h.base.WithAttrs(attrs)}
+}
+
+func (h *traceContextHandler) WithGroup(name string) slog.Handler {
+	return &traceContextHandler{base: h.base.WithGroup(name)}
+}
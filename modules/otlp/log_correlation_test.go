@@ -0,0 +1,82 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceContextHandlerAddsSpanIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewTraceContextHandler(slog.NewJSONHandler(&buf, nil)))
+
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background()) //nolint:errcheck
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	logger.InfoContext(ctx, "inside span", slog.String("user", "alice"))
+	span.End()
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	spanCtx := span.SpanContext()
+	if record[logTraceIDKey] != spanCtx.TraceID().String() {
+		t.Fatalf("trace_id = %v, want %s", record[logTraceIDKey], spanCtx.TraceID())
+	}
+	if record[logSpanIDKey] != spanCtx.SpanID().String() {
+		t.Fatalf("span_id = %v, want %s", record[logSpanIDKey], spanCtx.SpanID())
+	}
+	if record["user"] != "alice" {
+		t.Fatalf("user = %v, want alice", record["user"])
+	}
+
+	buf.Reset()
+	logger.Info("outside span")
+	record = nil
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if _, ok := record[logTraceIDKey]; ok {
+		t.Fatalf("record without a span has trace_id: %v", record)
+	}
+}
+
+func TestLogHandlerAddTraceWrapsHandler(t *testing.T) {
+	mod := Module().(*otlpModule)
+	defer mod.Stop(context.Background()) //nolint:errcheck
+
+	handler, err := mod.newGRPCLogHandler("", map[string]any{"addTrace": true})
+	if err != nil {
+		t.Fatalf("newGRPCLogHandler() error = %v", err)
+	}
+	if _, ok := handler.(*traceContextHandler); !ok {
+		t.Fatalf("handler = %T, want trace context wrapper", handler)
+	}
+
+	handler, err = mod.newGRPCLogHandler("", nil)
+	if err != nil {
+		t.Fatalf("newGRPCLogHandler() error = %v", err)
+	}
+	if _, ok := handler.(*traceContextHandler); ok {
+		t.Fatal("handler should not be wrapped without addTrace")
+	}
+}
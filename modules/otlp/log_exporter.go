@@ -0,0 +1,250 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/codesjoy/yggdrasil/v3/config"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// logHandlerConfig is the per-handler config passed by the logger runtime.
+type logHandlerConfig struct {
+	ServiceName string `mapstructure:"serviceName"`
+	// AddTrace adds trace_id and span_id attributes from the active span.
+	AddTrace bool `mapstructure:"addTrace"`
+}
+
+// NewLoggerProvider creates a new OTLP logger provider.
+func NewLoggerProvider(
+	serviceName string,
+	cfg LogExporterConfig,
+) (*sdklog.LoggerProvider, error) {
+	return newLoggerProvider(serviceName, cfg, ResourceConfig{})
+}
+
+func newLoggerProvider(
+	serviceName string,
+	cfg LogExporterConfig,
+	resCfg ResourceConfig,
+) (*sdklog.LoggerProvider, error) {
+	ctx := context.Background()
+	cfg = applyLogDefaults(cfg)
+
+	var (
+		exporter sdklog.Exporter
+		err      error
+	)
+
+	switch cfg.Protocol {
+	case "grpc", "":
+		exporter, err = createGRPCLogExporter(ctx, cfg)
+	case "http":
+		exporter, err = createHTTPLogExporter(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s (supported: grpc, http)", cfg.Protocol)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+
+	// Create batch log processor
+	processor := sdklog.NewBatchProcessor(exporter,
+		sdklog.WithExportInterval(cfg.Batch.BatchTimeout),
+		sdklog.WithMaxQueueSize(cfg.Batch.MaxQueueSize),
+		sdklog.WithExportMaxBatchSize(cfg.Batch.MaxExportBatchSize),
+		sdklog.WithExportTimeout(cfg.Batch.ExportTimeout),
+	)
+
+	// Create resource
+	res, err := NewResource(ctx, serviceName, resCfg, cfg.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(processor),
+	)
+
+	return lp, nil
+}
+
+// NewSlogHandler bridges slog records to the given OTLP logger provider.
+func NewSlogHandler(name string, provider *sdklog.LoggerProvider) slog.Handler {
+	return otelslog.NewHandler(name, otelslog.WithLoggerProvider(provider))
+}
+
+// createGRPCLogExporter creates a gRPC OTLP log exporter.
+func createGRPCLogExporter(
+	ctx context.Context,
+	cfg LogExporterConfig,
+) (sdklog.Exporter, error) {
+	opts, err := createGRPCLogClientOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client options: %w", err)
+	}
+
+	exporter, err := otlploggrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC log exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// createHTTPLogExporter creates an HTTP OTLP log exporter.
+func createHTTPLogExporter(
+	ctx context.Context,
+	cfg LogExporterConfig,
+) (sdklog.Exporter, error) {
+	opts, err := createHTTPLogClientOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client options: %w", err)
+	}
+
+	exporter, err := otlploghttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP log exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// newGRPCLogHandler creates a slog handler exporting over OTLP gRPC.
+func (m *otlpModule) newGRPCLogHandler(_ string, cfgMap map[string]any) (slog.Handler, error) {
+	cfg := m.logConfig()
+	cfg.Protocol = "grpc"
+
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPLogsEndpoint) == "" {
+		cfg.Endpoint = defaultGRPCEndpoint
+	}
+
+	return m.newLogHandler(cfg, cfgMap)
+}
+
+// newHTTPLogHandler creates a slog handler exporting over OTLP HTTP.
+func (m *otlpModule) newHTTPLogHandler(_ string, cfgMap map[string]any) (slog.Handler, error) {
+	cfg := m.logConfig()
+	cfg.Protocol = "http"
+
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPLogsEndpoint) == "" {
+		cfg.Endpoint = defaultHTTPEndpoint
+	}
+
+	return m.newLogHandler(cfg, cfgMap)
+}
+
+func (m *otlpModule) newLogHandler(
+	cfg LogExporterConfig,
+	cfgMap map[string]any,
+) (slog.Handler, error) {
+	var handlerCfg logHandlerConfig
+	if err := config.NewSnapshot(cfgMap).Decode(&handlerCfg); err != nil {
+		return nil, err
+	}
+	serviceName := handlerCfg.ServiceName
+	if serviceName == "" {
+		serviceName = "unknown_service:" + filepath.Base(os.Args[0])
+	}
+
+	lp, err := newLoggerProvider(serviceName, cfg, m.resourceConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.loggerProviders = append(m.loggerProviders, lp)
+	m.mu.Unlock()
+
+	handler := NewSlogHandler(serviceName, lp)
+	if handlerCfg.AddTrace {
+		handler = NewTraceContextHandler(handler)
+	}
+	return handler, nil
+}
+
+// Stop flushes and shuts down logger providers created for slog handlers,
+// closes Prometheus scrape servers and the shared gRPC connection, and
+// restores the global propagator replaced by Start.
+func (m *otlpModule) Stop(ctx context.Context) error {
+	m.restorePropagator()
+
+	m.mu.Lock()
+	providers := m.loggerProviders
+	m.loggerProviders = nil
+	servers := m.metricServers
+	m.metricServers = nil
+	conn := m.grpcConn
+	m.grpcConn = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for _, lp := range providers {
+		if err := lp.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if conn != nil {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func applyLogDefaults(cfg LogExporterConfig) LogExporterConfig {
+	if cfg.Batch.BatchTimeout == 0 {
+		cfg.Batch.BatchTimeout = defaultBatchTimeout
+	}
+	if cfg.Batch.MaxQueueSize == 0 {
+		cfg.Batch.MaxQueueSize = defaultMaxQueueSize
+	}
+	if cfg.Batch.MaxExportBatchSize == 0 {
+		cfg.Batch.MaxExportBatchSize = defaultMaxExportBatchSize
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Batch.ExportTimeout == 0 {
+		cfg.Batch.ExportTimeout = cfg.Timeout
+	}
+	if cfg.Retry.InitialDelay == 0 {
+		cfg.Retry.InitialDelay = defaultRetryInitialDelay
+	}
+	if cfg.Retry.MaxDelay == 0 {
+		cfg.Retry.MaxDelay = defaultRetryMaxDelay
+	}
+	if cfg.Retry.MaxAttempts == 0 && cfg.Retry.Enabled {
+		cfg.Retry.MaxAttempts = defaultMaxAttempts
+	}
+
+	return cfg
+}
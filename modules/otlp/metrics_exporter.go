@@ -16,55 +16,70 @@ package otlp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
+	"sort"
 
-	xotel "github.com/codesjoy/yggdrasil/v3/observability/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
 )
 
 // NewMeterProvider creates a new OTLP meter provider.
 func NewMeterProvider(
 	serviceName string,
 	cfg MetricExporterConfig,
+) (*sdkmetric.MeterProvider, error) {
+	return newMeterProvider(serviceName, cfg, ResourceConfig{}, nil)
+}
+
+func newMeterProvider(
+	serviceName string,
+	cfg MetricExporterConfig,
+	resCfg ResourceConfig,
+	conn *grpc.ClientConn,
 ) (*sdkmetric.MeterProvider, error) {
 	ctx := context.Background()
 	cfg = applyMetricDefaults(cfg)
 
-	var (
-		exporter sdkmetric.Exporter
-		err      error
-	)
+	views, err := newMetricViews(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter sdkmetric.Exporter
 
 	switch cfg.Protocol {
 	case "grpc", "":
-		exporter, err = createGRPCMeterExporter(ctx, cfg)
+		exporter, err = createGRPCMeterExporter(ctx, cfg, conn)
 	case "http":
 		exporter, err = createHTTPMeterExporter(ctx, cfg)
+	case protocolStdout:
+		exporter, err = createStdoutMeterExporter(os.Stdout, cfg)
+	case protocolPrometheus:
+		return nil, errors.New(
+			"prometheus metrics are pulled, use NewPrometheusMeterProvider to serve them",
+		)
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s (supported: grpc, http)", cfg.Protocol)
+		return nil, fmt.Errorf(
+			"unsupported protocol: %s (supported: grpc, http, prometheus, stdout)",
+			cfg.Protocol,
+		)
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
 	}
 
-	// Build resource attributes
-	resourceAttrs := buildResourceAttributes(serviceName, cfg.Resource)
-
-	// Create resource
-	attrs := xotel.ParseAttributes(resourceAttrs)
-	res, err := resource.New(ctx,
-		resource.WithAttributes(attrs...),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
-	}
-
 	// Create periodic reader
 	var readerOpts []sdkmetric.PeriodicReaderOption
 	if cfg.ExportInterval > 0 {
@@ -80,24 +95,120 @@ func NewMeterProvider(
 
 	reader := sdkmetric.NewPeriodicReader(exporter, readerOpts...)
 
+	return newReaderMeterProvider(ctx, serviceName, cfg, resCfg, reader, views)
+}
+
+// newReaderMeterProvider wires reader, the resource and views into a meter
+// provider.
+func newReaderMeterProvider(
+	ctx context.Context,
+	serviceName string,
+	cfg MetricExporterConfig,
+	resCfg ResourceConfig,
+	reader sdkmetric.Reader,
+	views []sdkmetric.View,
+) (*sdkmetric.MeterProvider, error) {
+	// Create resource
+	res, err := NewResource(ctx, serviceName, resCfg, cfg.Resource)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create meter provider
-	// Note: Temporality configuration is not directly supported on periodic readers.
-	// Default cumulative temporality is used. For delta temporality, use views.
 	var providerOpts []sdkmetric.Option
 	providerOpts = append(providerOpts, sdkmetric.WithResource(res))
 	providerOpts = append(providerOpts, sdkmetric.WithReader(reader))
+	if cfg.Exemplars != nil {
+		providerOpts = append(providerOpts,
+			sdkmetric.WithExemplarFilter(exemplarFilter(*cfg.Exemplars)))
+	}
+	if len(views) > 0 {
+		providerOpts = append(providerOpts, sdkmetric.WithView(views...))
+	}
 
 	mp := sdkmetric.NewMeterProvider(providerOpts...)
 
 	return mp, nil
 }
 
+// exemplarFilter offers measurements taken inside a sampled span to the
+// exemplar reservoirs when exemplars are enabled, and none otherwise.
+func exemplarFilter(enabled bool) exemplar.Filter {
+	if enabled {
+		return exemplar.TraceBasedFilter
+	}
+	return exemplar.AlwaysOffFilter
+}
+
+// newMetricViews builds the views that replace the default histogram
+// aggregation when custom boundaries or exponential histograms are configured.
+func newMetricViews(cfg MetricExporterConfig) ([]sdkmetric.View, error) {
+	var aggregation sdkmetric.Aggregation
+	switch {
+	case cfg.UseExponentialHistogram && len(cfg.HistogramBoundaries) > 0:
+		return nil, errors.New(
+			"histogramBoundaries and useExponentialHistogram are mutually exclusive",
+		)
+	case cfg.UseExponentialHistogram:
+		aggregation = sdkmetric.AggregationBase2ExponentialHistogram{
+			MaxSize:  defaultExponentialHistogramMaxSize,
+			MaxScale: defaultExponentialHistogramMaxScale,
+		}
+	case len(cfg.HistogramBoundaries) > 0:
+		if !sort.Float64sAreSorted(cfg.HistogramBoundaries) {
+			return nil, fmt.Errorf(
+				"histogram boundaries must be in increasing order: %v",
+				cfg.HistogramBoundaries,
+			)
+		}
+		aggregation = sdkmetric.AggregationExplicitBucketHistogram{
+			Boundaries: slices.Clone(cfg.HistogramBoundaries),
+		}
+	default:
+		return nil, nil
+	}
+
+	return []sdkmetric.View{sdkmetric.NewView(
+		sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram},
+		sdkmetric.Stream{Aggregation: aggregation},
+	)}, nil
+}
+
+// getMetricTemporality maps a temporality name to the selector installed on the
+// exporter. Delta follows the OpenTelemetry "delta" preference: counters and
+// histograms report deltas, up-down counters stay cumulative.
+func getMetricTemporality(temporality string) (sdkmetric.TemporalitySelector, error) {
+	switch temporality {
+	case temporalityCumulative, "":
+		return sdkmetric.DefaultTemporalitySelector, nil
+	case temporalityDelta:
+		return deltaTemporalitySelector, nil
+	default:
+		return nil, fmt.Errorf(
+			"unsupported metric temporality: %s (supported: %s, %s)",
+			temporality, temporalityCumulative, temporalityDelta,
+		)
+	}
+}
+
+func deltaTemporalitySelector(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindHistogram,
+		sdkmetric.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
 // createGRPCMeterExporter creates a gRPC OTLP metric exporter.
 func createGRPCMeterExporter(
 	ctx context.Context,
 	cfg MetricExporterConfig,
+	conn *grpc.ClientConn,
 ) (sdkmetric.Exporter, error) {
-	opts, err := createGRPCMeterClientOptions(cfg)
+	opts, err := createGRPCMeterClientOptions(cfg, conn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC client options: %w", err)
 	}
@@ -128,16 +239,41 @@ func createHTTPMeterExporter(
 	return exporter, nil
 }
 
+// createStdoutMeterExporter creates an exporter that pretty-prints metrics as
+// JSON to w.
+func createStdoutMeterExporter(w io.Writer, cfg MetricExporterConfig) (sdkmetric.Exporter, error) {
+	temporality, err := getMetricTemporality(cfg.Temporality)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := stdoutmetric.New(
+		stdoutmetric.WithWriter(w),
+		stdoutmetric.WithPrettyPrint(),
+		stdoutmetric.WithTemporalitySelector(temporality),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
 // newGRPCMeterProvider creates a gRPC meter provider from config.
 func (m *otlpModule) newGRPCMeterProvider(serviceName string) metric.MeterProvider {
 	cfg := m.metricConfig()
-	cfg.Protocol = "grpc"
+	if cfg.Protocol == protocolPrometheus {
+		return m.newPrometheusMeterProvider(serviceName, cfg)
+	}
+	if cfg.Protocol != protocolStdout {
+		cfg.Protocol = "grpc"
+	}
 
-	if cfg.Endpoint == "" {
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPMetricsEndpoint) == "" {
 		cfg.Endpoint = defaultGRPCEndpoint
 	}
 
-	mp, err := NewMeterProvider(serviceName, cfg)
+	mp, err := newMeterProvider(serviceName, cfg, m.resourceConfig(), m.sharedConn())
 	if err != nil {
 		slog.Warn("failed to create OTLP gRPC meter provider, using noop",
 			slog.String("error", err.Error()))
@@ -151,13 +287,18 @@ func (m *otlpModule) newGRPCMeterProvider(serviceName string) metric.MeterProvid
 // newHTTPMeterProvider creates an HTTP meter provider from config.
 func (m *otlpModule) newHTTPMeterProvider(serviceName string) metric.MeterProvider {
 	cfg := m.metricConfig()
-	cfg.Protocol = "http"
+	if cfg.Protocol == protocolPrometheus {
+		return m.newPrometheusMeterProvider(serviceName, cfg)
+	}
+	if cfg.Protocol != protocolStdout {
+		cfg.Protocol = "http"
+	}
 
-	if cfg.Endpoint == "" {
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPMetricsEndpoint) == "" {
 		cfg.Endpoint = defaultHTTPEndpoint
 	}
 
-	mp, err := NewMeterProvider(serviceName, cfg)
+	mp, err := newMeterProvider(serviceName, cfg, m.resourceConfig(), nil)
 	if err != nil {
 		slog.Warn("failed to create OTLP HTTP meter provider, using noop",
 			slog.String("error", err.Error()))
@@ -15,8 +15,18 @@
 package otlp
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewMeterProvider_InvalidProtocol(t *testing.T) {
@@ -100,3 +110,229 @@ func TestMetricConfigDefaults(t *testing.T) {
 		t.Errorf("Retry.MaxDelay = %v, want %v", cfg.Retry.MaxDelay, defaultRetryMaxDelay)
 	}
 }
+
+func TestGetMetricTemporality(t *testing.T) {
+	delta, err := getMetricTemporality(temporalityDelta)
+	if err != nil {
+		t.Fatalf("getMetricTemporality(delta) error = %v", err)
+	}
+	if got := delta(sdkmetric.InstrumentKindCounter); got != metricdata.DeltaTemporality {
+		t.Fatalf("delta selector for counter = %v, want Delta", got)
+	}
+	got := delta(sdkmetric.InstrumentKindUpDownCounter)
+	if got != metricdata.CumulativeTemporality {
+		t.Fatalf("delta selector for up-down counter = %v, want Cumulative", got)
+	}
+
+	cumulative, err := getMetricTemporality("")
+	if err != nil {
+		t.Fatalf("getMetricTemporality(\"\") error = %v", err)
+	}
+	if got := cumulative(sdkmetric.InstrumentKindCounter); got != metricdata.CumulativeTemporality {
+		t.Fatalf("default selector for counter = %v, want Cumulative", got)
+	}
+
+	if _, err := getMetricTemporality("sometimes"); err == nil {
+		t.Fatal("getMetricTemporality(sometimes) error = nil, want error")
+	}
+}
+
+func TestMeterExporterUsesConfiguredTemporality(t *testing.T) {
+	opts, err := createHTTPMeterClientOptions(MetricExporterConfig{
+		Endpoint:    "localhost:4318",
+		Temporality: temporalityDelta,
+	})
+	if err != nil {
+		t.Fatalf("createHTTPMeterClientOptions() error = %v", err)
+	}
+	exporter, err := otlpmetrichttp.New(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("otlpmetrichttp.New() error = %v", err)
+	}
+	defer exporter.Shutdown(context.Background()) //nolint:errcheck
+
+	got := exporter.Temporality(sdkmetric.InstrumentKindCounter)
+	if got != metricdata.DeltaTemporality {
+		t.Fatalf("exporter temporality for counter = %v, want Delta", got)
+	}
+
+	_, err = createGRPCMeterClientOptions(MetricExporterConfig{Temporality: "sometimes"}, nil)
+	if err == nil {
+		t.Fatal("createGRPCMeterClientOptions() error = nil, want unsupported temporality error")
+	}
+}
+
+func TestMetricViewsApplyHistogramBoundaries(t *testing.T) {
+	boundaries := []float64{1, 5, 25, 100}
+	views, err := newMetricViews(MetricExporterConfig{HistogramBoundaries: boundaries})
+	if err != nil {
+		t.Fatalf("newMetricViews() error = %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(views...),
+	)
+	defer provider.Shutdown(context.Background()) //nolint:errcheck
+
+	histogram, err := provider.Meter("test").Float64Histogram("latency")
+	if err != nil {
+		t.Fatalf("Float64Histogram() error = %v", err)
+	}
+	histogram.Record(context.Background(), 7)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	raw := rm.ScopeMetrics[0].Metrics[0].Data
+	data, ok := raw.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("metric data = %T, want explicit bucket histogram", raw)
+	}
+	if got := data.DataPoints[0].Bounds; !slices.Equal(got, boundaries) {
+		t.Fatalf("histogram bounds = %v, want %v", got, boundaries)
+	}
+}
+
+func TestMetricViewsExponentialHistogram(t *testing.T) {
+	views, err := newMetricViews(MetricExporterConfig{UseExponentialHistogram: true})
+	if err != nil {
+		t.Fatalf("newMetricViews() error = %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(views...),
+	)
+	defer provider.Shutdown(context.Background()) //nolint:errcheck
+
+	histogram, err := provider.Meter("test").Float64Histogram("latency")
+	if err != nil {
+		t.Fatalf("Float64Histogram() error = %v", err)
+	}
+	histogram.Record(context.Background(), 7)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	data := rm.ScopeMetrics[0].Metrics[0].Data
+	if _, ok := data.(metricdata.ExponentialHistogram[float64]); !ok {
+		t.Fatalf("metric data = %T, want exponential histogram", data)
+	}
+}
+
+func TestMetricViewsRejectInvalidHistogramConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  MetricExporterConfig
+	}{
+		{
+			name: "unsorted boundaries",
+			cfg:  MetricExporterConfig{HistogramBoundaries: []float64{10, 1}},
+		},
+		{
+			name: "boundaries with exponential",
+			cfg: MetricExporterConfig{
+				HistogramBoundaries:     []float64{1, 10},
+				UseExponentialHistogram: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newMetricViews(tt.cfg); err == nil {
+				t.Fatal("newMetricViews() error = nil, want error")
+			}
+		})
+	}
+
+	views, err := newMetricViews(MetricExporterConfig{})
+	if err != nil || len(views) != 0 {
+		t.Fatalf("newMetricViews(default) = %v, %v; want no views", views, err)
+	}
+}
+
+func TestStdoutMeterExporterWritesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := createStdoutMeterExporter(&buf, MetricExporterConfig{})
+	if err != nil {
+		t.Fatalf("createStdoutMeterExporter() error = %v", err)
+	}
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+	)
+	defer mp.Shutdown(context.Background()) //nolint:errcheck
+
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(context.Background(), 1)
+	if err := mp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	if !json.Valid(buf.Bytes()) || !bytes.Contains(buf.Bytes(), []byte(`"requests"`)) {
+		t.Fatalf("output is not JSON with the requests metric:\n%s", buf.String())
+	}
+}
+
+func TestMeterProviderAttachesTraceExemplars(t *testing.T) {
+	ctx := context.Background()
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(ctx) //nolint:errcheck
+
+	record := func(
+		cfg MetricExporterConfig,
+	) (metricdata.HistogramDataPoint[float64], trace.SpanContext) {
+		reader := sdkmetric.NewManualReader()
+		mp, err := newReaderMeterProvider(ctx, "svc", cfg, ResourceConfig{}, reader, nil)
+		if err != nil {
+			t.Fatalf("newReaderMeterProvider() error = %v", err)
+		}
+		defer mp.Shutdown(ctx) //nolint:errcheck
+		histogram, err := mp.Meter("test").Float64Histogram("latency")
+		if err != nil {
+			t.Fatalf("Float64Histogram() error = %v", err)
+		}
+
+		spanCtx, span := tp.Tracer("test").Start(ctx, "op")
+		histogram.Record(spanCtx, 12.5)
+		span.End()
+
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		data := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+		return data.DataPoints[0], span.SpanContext()
+	}
+
+	enabled, disabled := true, false
+	point, spanCtx := record(MetricExporterConfig{Exemplars: &enabled})
+	if len(point.Exemplars) != 1 {
+		t.Fatalf("exemplars = %d, want 1", len(point.Exemplars))
+	}
+	traceID := spanCtx.TraceID()
+	if got := point.Exemplars[0].TraceID; string(got) != string(traceID[:]) {
+		t.Fatalf("exemplar trace id = %x, want %s", got, traceID)
+	}
+
+	point, _ = record(MetricExporterConfig{Exemplars: &disabled})
+	if len(point.Exemplars) != 0 {
+		t.Fatalf("exemplars = %d with exemplars disabled, want 0", len(point.Exemplars))
+	}
+
+	// Unset, the SDK default filter and its environment variable apply.
+	t.Setenv("OTEL_METRICS_EXEMPLAR_FILTER", "trace_based")
+	if point, _ = record(MetricExporterConfig{}); len(point.Exemplars) != 1 {
+		t.Fatalf("exemplars = %d with the trace_based env filter, want 1", len(point.Exemplars))
+	}
+	t.Setenv("OTEL_METRICS_EXEMPLAR_FILTER", "always_off")
+	if point, _ = record(MetricExporterConfig{}); len(point.Exemplars) != 0 {
+		t.Fatalf("exemplars = %d with the always_off env filter, want 0", len(point.Exemplars))
+	}
+}
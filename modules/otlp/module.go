@@ -16,13 +16,22 @@ package otlp
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/codesjoy/yggdrasil/v3"
 	"github.com/codesjoy/yggdrasil/v3/capabilities"
 	"github.com/codesjoy/yggdrasil/v3/config"
 	"github.com/codesjoy/yggdrasil/v3/module"
+	"github.com/codesjoy/yggdrasil/v3/observability/logger"
 	xotel "github.com/codesjoy/yggdrasil/v3/observability/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -35,6 +44,14 @@ const (
 type otlpModule struct {
 	mu       sync.RWMutex
 	settings Config
+
+	loggerProviders []*sdklog.LoggerProvider
+	metricServers   []*http.Server
+
+	propagator         propagation.TextMapPropagator
+	previousPropagator propagation.TextMapPropagator
+
+	grpcConn *grpc.ClientConn
 }
 
 // Module returns the Yggdrasil v3 OTLP provider module.
@@ -60,12 +77,107 @@ func (m *otlpModule) Init(_ context.Context, view config.View) error {
 			return err
 		}
 	}
+	if _, err := resourceDetectorOptions(next.Resource.Detectors); err != nil {
+		return err
+	}
+	var propagator propagation.TextMapPropagator
+	if len(next.Propagators) > 0 {
+		var err error
+		if propagator, err = NewPropagator(next.Propagators); err != nil {
+			return err
+		}
+	}
+	var conn *grpc.ClientConn
+	if next.SharedGRPCConn {
+		var err error
+		if conn, err = newSharedGRPCConn(next); err != nil {
+			return err
+		}
+	}
 	m.mu.Lock()
 	m.settings = next
+	m.propagator = propagator
+	previousConn := m.grpcConn
+	m.grpcConn = conn
 	m.mu.Unlock()
+	if previousConn != nil {
+		_ = previousConn.Close()
+	}
 	return nil
 }
 
+// newSharedGRPCConn creates the connection shared by the otlp-grpc tracer and
+// meter providers. Both endpoints are resolved as the exporters would resolve
+// them, so the OTEL environment variables still apply; the metric endpoint
+// must then be empty or match the trace one.
+func newSharedGRPCConn(cfg Config) (*grpc.ClientConn, error) {
+	endpoint := sharedGRPCEndpoint(cfg.Trace.Endpoint, envOTLPTracesEndpoint)
+	if endpoint == "" {
+		endpoint = defaultGRPCEndpoint
+	}
+	target, tlsCfg, err := sharedGRPCTarget(endpoint, cfg.Trace.TLS)
+	if err != nil {
+		return nil, err
+	}
+	metricEndpoint := sharedGRPCEndpoint(cfg.Metric.Endpoint, envOTLPMetricsEndpoint)
+	if metricEndpoint != "" {
+		metricTarget, _, err := sharedGRPCTarget(metricEndpoint, cfg.Trace.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if metricTarget != target {
+			return nil, fmt.Errorf(
+				"sharedGRPCConn needs one endpoint, got trace %q and metric %q",
+				endpoint, metricEndpoint,
+			)
+		}
+	}
+	opts, err := createGRPCDialOptions(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared gRPC connection: %w", err)
+	}
+	return conn, nil
+}
+
+// sharedGRPCEndpoint returns the configured endpoint, falling back to the
+// OTEL environment variables for the signal.
+func sharedGRPCEndpoint(endpoint, signalVar string) string {
+	if endpoint != "" {
+		return endpoint
+	}
+	return endpointFromEnv(signalVar)
+}
+
+// sharedGRPCTarget reduces an endpoint URL to the host:port to dial. Like the
+// exporters, an unset tls block then follows the URL scheme.
+func sharedGRPCTarget(endpoint string, tlsCfg TLSConfig) (string, TLSConfig, error) {
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, tlsCfg, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", tlsCfg, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	if tlsCfg == (TLSConfig{}) {
+		if u.Scheme == "https" {
+			tlsCfg.Enabled = true
+		} else {
+			tlsCfg.Insecure = true
+		}
+	}
+	return u.Host, tlsCfg, nil
+}
+
+func (m *otlpModule) sharedConn() *grpc.ClientConn {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.grpcConn
+}
+
 func (m *otlpModule) Capabilities() []module.Capability {
 	return []module.Capability{
 		capabilities.ProvideNamed(
@@ -88,6 +200,16 @@ func (m *otlpModule) Capabilities() []module.Capability {
 			httpProviderName,
 			xotel.MeterProviderBuilder(m.newHTTPMeterProvider),
 		),
+		capabilities.ProvideNamed(
+			capabilities.LoggerHandlerSpec,
+			grpcProviderName,
+			logger.HandlerBuilder(m.newGRPCLogHandler),
+		),
+		capabilities.ProvideNamed(
+			capabilities.LoggerHandlerSpec,
+			httpProviderName,
+			logger.HandlerBuilder(m.newHTTPLogHandler),
+		),
 	}
 }
 
@@ -103,6 +225,21 @@ func (m *otlpModule) metricConfig() MetricExporterConfig {
 	return cloneMetricConfig(m.settings.Metric)
 }
 
+func (m *otlpModule) logConfig() LogExporterConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return cloneLogConfig(m.settings.Log)
+}
+
+func (m *otlpModule) resourceConfig() ResourceConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	resCfg := m.settings.Resource
+	resCfg.Attributes = cloneAnyMap(resCfg.Attributes)
+	resCfg.Detectors = slices.Clone(resCfg.Detectors)
+	return resCfg
+}
+
 func cloneTraceConfig(in TraceExporterConfig) TraceExporterConfig {
 	in.Headers = cloneStringMap(in.Headers)
 	in.Resource = cloneAnyMap(in.Resource)
@@ -110,6 +247,13 @@ func cloneTraceConfig(in TraceExporterConfig) TraceExporterConfig {
 }
 
 func cloneMetricConfig(in MetricExporterConfig) MetricExporterConfig {
+	in.Headers = cloneStringMap(in.Headers)
+	in.Resource = cloneAnyMap(in.Resource)
+	in.HistogramBoundaries = slices.Clone(in.HistogramBoundaries)
+	return in
+}
+
+func cloneLogConfig(in LogExporterConfig) LogExporterConfig {
 	in.Headers = cloneStringMap(in.Headers)
 	in.Resource = cloneAnyMap(in.Resource)
 	return in
@@ -16,14 +16,22 @@ package otlp
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 
 	"github.com/codesjoy/yggdrasil/v3"
 	"github.com/codesjoy/yggdrasil/v3/capabilities"
 	"github.com/codesjoy/yggdrasil/v3/config"
+	"github.com/codesjoy/yggdrasil/v3/observability/logger"
 	xotel "github.com/codesjoy/yggdrasil/v3/observability/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collectormetric "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
 )
 
 func TestModuleConfig(t *testing.T) {
@@ -47,6 +55,7 @@ func TestModuleConfig(t *testing.T) {
 		"metric": map[string]any{
 			"endpoint":       "collector:4318",
 			"exportInterval": "10s",
+			"exemplars":      false,
 		},
 	}))
 	if err := mod.Init(context.Background(), view); err != nil {
@@ -62,6 +71,9 @@ func TestModuleConfig(t *testing.T) {
 	if got := mod.metricConfig().Endpoint; got != "collector:4318" {
 		t.Fatalf("metric endpoint = %q, want collector:4318", got)
 	}
+	if got := mod.metricConfig().Exemplars; got == nil || *got {
+		t.Fatalf("metric exemplars = %v, want explicitly false", got)
+	}
 }
 
 func TestModuleExposesV3Capabilities(t *testing.T) {
@@ -75,6 +87,8 @@ func TestModuleExposesV3Capabilities(t *testing.T) {
 		capabilities.TracerProviderSpec.Name + "/" + httpProviderName: false,
 		capabilities.MeterProviderSpec.Name + "/" + grpcProviderName:  false,
 		capabilities.MeterProviderSpec.Name + "/" + httpProviderName:  false,
+		capabilities.LoggerHandlerSpec.Name + "/" + grpcProviderName:  false,
+		capabilities.LoggerHandlerSpec.Name + "/" + httpProviderName:  false,
 	}
 	for _, cap := range mod.Capabilities() {
 		switch cap.Spec.Name {
@@ -86,6 +100,10 @@ func TestModuleExposesV3Capabilities(t *testing.T) {
 			if _, ok := cap.Value.(xotel.MeterProviderBuilder); !ok {
 				t.Fatalf("meter provider %q type = %T", cap.Name, cap.Value)
 			}
+		case capabilities.LoggerHandlerSpec.Name:
+			if _, ok := cap.Value.(logger.HandlerBuilder); !ok {
+				t.Fatalf("logger handler %q type = %T", cap.Name, cap.Value)
+			}
 		}
 		key := cap.Spec.Name + "/" + cap.Name
 		if _, ok := want[key]; ok {
@@ -138,3 +156,218 @@ yggdrasil:
 		t.Fatal("yggdrasil.New() app = nil")
 	}
 }
+
+func TestLogHandlerBuildersShutDownOnStop(t *testing.T) {
+	mod, ok := Module().(*otlpModule)
+	if !ok {
+		t.Fatalf("Module() type = %T, want *otlpModule", Module())
+	}
+
+	handler, err := mod.newHTTPLogHandler("", map[string]any{"serviceName": "demo"})
+	if err != nil {
+		t.Fatalf("newHTTPLogHandler() error = %v", err)
+	}
+	if handler == nil {
+		t.Fatal("newHTTPLogHandler() returned nil handler")
+	}
+	if len(mod.loggerProviders) != 1 {
+		t.Fatalf("logger providers = %d, want 1", len(mod.loggerProviders))
+	}
+
+	if err := mod.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if len(mod.loggerProviders) != 0 {
+		t.Fatalf("logger providers after Stop = %d, want 0", len(mod.loggerProviders))
+	}
+	if err := mod.Stop(context.Background()); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+}
+
+type countingListener struct {
+	net.Listener
+	accepts atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepts.Add(1)
+	}
+	return conn, err
+}
+
+type traceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	exports atomic.Int32
+}
+
+func (c *traceCollector) Export(
+	context.Context,
+	*collectortrace.ExportTraceServiceRequest,
+) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.exports.Add(1)
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+type metricCollector struct {
+	collectormetric.UnimplementedMetricsServiceServer
+	exports atomic.Int32
+}
+
+func (c *metricCollector) Export(
+	context.Context,
+	*collectormetric.ExportMetricsServiceRequest,
+) (*collectormetric.ExportMetricsServiceResponse, error) {
+	c.exports.Add(1)
+	return &collectormetric.ExportMetricsServiceResponse{}, nil
+}
+
+func TestSharedGRPCConnCarriesTracesAndMetrics(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	counting := &countingListener{Listener: lis}
+	traces, metrics := &traceCollector{}, &metricCollector{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, traces)
+	collectormetric.RegisterMetricsServiceServer(server, metrics)
+	go server.Serve(counting) //nolint:errcheck
+	defer server.Stop()
+
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"sharedGRPCConn": true,
+		"trace": map[string]any{
+			"endpoint": lis.Addr().String(),
+			"tls":      map[string]any{"insecure": true},
+		},
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer mod.Stop(context.Background()) //nolint:errcheck
+
+	ctx := context.Background()
+	tp, ok := mod.newGRPCTracerProvider("svc").(*sdktrace.TracerProvider)
+	if !ok {
+		t.Fatal("gRPC tracer provider fell back to noop")
+	}
+	defer tp.Shutdown(ctx) //nolint:errcheck
+	mp, ok := mod.newGRPCMeterProvider("svc").(*sdkmetric.MeterProvider)
+	if !ok {
+		t.Fatal("gRPC meter provider has an unexpected type")
+	}
+	defer mp.Shutdown(ctx) //nolint:errcheck
+
+	_, span := tp.Tracer("test").Start(ctx, "op")
+	span.End()
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(ctx, 1)
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatalf("tracer ForceFlush() error = %v", err)
+	}
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Fatalf("meter ForceFlush() error = %v", err)
+	}
+
+	if traces.exports.Load() == 0 || metrics.exports.Load() == 0 {
+		t.Fatalf(
+			"exports = %d traces, %d metrics, want both",
+			traces.exports.Load(),
+			metrics.exports.Load(),
+		)
+	}
+	if got := counting.accepts.Load(); got != 1 {
+		t.Fatalf("collector accepted %d connections, want 1 shared connection", got)
+	}
+}
+
+func TestSharedGRPCConnUsesEnvEndpoint(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	counting := &countingListener{Listener: lis}
+	traces, metrics := &traceCollector{}, &metricCollector{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, traces)
+	collectormetric.RegisterMetricsServiceServer(server, metrics)
+	go server.Serve(counting) //nolint:errcheck
+	defer server.Stop()
+
+	t.Setenv(envOTLPEndpoint, "")
+	t.Setenv(envOTLPTracesEndpoint, "http://"+lis.Addr().String())
+	t.Setenv(envOTLPMetricsEndpoint, lis.Addr().String())
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"sharedGRPCConn": true,
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer mod.Stop(context.Background()) //nolint:errcheck
+
+	ctx := context.Background()
+	tp, ok := mod.newGRPCTracerProvider("svc").(*sdktrace.TracerProvider)
+	if !ok {
+		t.Fatal("gRPC tracer provider fell back to noop")
+	}
+	defer tp.Shutdown(ctx) //nolint:errcheck
+	mp, ok := mod.newGRPCMeterProvider("svc").(*sdkmetric.MeterProvider)
+	if !ok {
+		t.Fatal("gRPC meter provider has an unexpected type")
+	}
+	defer mp.Shutdown(ctx) //nolint:errcheck
+
+	_, span := tp.Tracer("test").Start(ctx, "op")
+	span.End()
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(ctx, 1)
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatalf("tracer ForceFlush() error = %v", err)
+	}
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Fatalf("meter ForceFlush() error = %v", err)
+	}
+
+	if traces.exports.Load() == 0 || metrics.exports.Load() == 0 {
+		t.Fatalf(
+			"exports = %d traces, %d metrics, want both at the env endpoint",
+			traces.exports.Load(),
+			metrics.exports.Load(),
+		)
+	}
+	if got := counting.accepts.Load(); got != 1 {
+		t.Fatalf("collector accepted %d connections, want 1 shared connection", got)
+	}
+}
+
+func TestSharedGRPCConnRejectsDifferentEndpoints(t *testing.T) {
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"sharedGRPCConn": true,
+		"trace":          map[string]any{"endpoint": "collector:4317"},
+		"metric":         map[string]any{"endpoint": "other:4317"},
+	}))
+	if err := mod.Init(context.Background(), view); err == nil {
+		t.Fatal("Init() should reject different trace and metric endpoints")
+	}
+
+	t.Setenv(envOTLPMetricsEndpoint, "http://other:4317")
+	view = config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"sharedGRPCConn": true,
+		"trace":          map[string]any{"endpoint": "collector:4317"},
+	}))
+	if err := mod.Init(context.Background(), view); err == nil {
+		t.Fatal("Init() should reject a different metric endpoint from the environment")
+	}
+}
@@ -15,21 +15,37 @@
 package otlp
 
 import (
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	otlpmetricgrpc "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	otlpmetrichttp "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/grpc"
 )
 
 // createGRPCTraceClientOptions creates gRPC client options for trace exporter.
-func createGRPCTraceClientOptions(cfg TraceExporterConfig) ([]otlptracegrpc.Option, error) {
+// A non-nil conn replaces the endpoint and TLS settings of cfg.
+func createGRPCTraceClientOptions(
+	cfg TraceExporterConfig,
+	conn *grpc.ClientConn,
+) ([]otlptracegrpc.Option, error) {
 	var opts []otlptracegrpc.Option
 
-	// Set endpoint
-	if cfg.Endpoint != "" {
-		opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+	// Set endpoint, falling back to the OTEL environment variables. Values
+	// with a scheme are URLs, which also select TLS by scheme.
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = endpointFromEnv(envOTLPTracesEndpoint)
+	}
+	switch {
+	case strings.Contains(endpoint, "://"):
+		opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+	case endpoint != "":
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
 	}
 
 	// Set headers
@@ -52,13 +68,15 @@ func createGRPCTraceClientOptions(cfg TraceExporterConfig) ([]otlptracegrpc.Opti
 		// Unknown compression, use default
 	}
 
-	// Configure TLS
-	grpcOpts, err := createGRPCDialOptions(cfg.TLS)
-	if err != nil {
-		return nil, err
-	}
-	if len(grpcOpts) > 0 {
-		opts = append(opts, otlptracegrpc.WithDialOption(grpcOpts...))
+	// Configure TLS, unless an endpoint URL selects it by scheme
+	if !strings.Contains(endpoint, "://") || cfg.TLS != (TLSConfig{}) {
+		grpcOpts, err := createGRPCDialOptions(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if len(grpcOpts) > 0 {
+			opts = append(opts, otlptracegrpc.WithDialOption(grpcOpts...))
+		}
 	}
 
 	// Configure retry
@@ -72,6 +90,10 @@ func createGRPCTraceClientOptions(cfg TraceExporterConfig) ([]otlptracegrpc.Opti
 		opts = append(opts, otlptracegrpc.WithRetry(backoff))
 	}
 
+	if conn != nil {
+		opts = append(opts, otlptracegrpc.WithGRPCConn(conn))
+	}
+
 	return opts, nil
 }
 
@@ -126,12 +148,24 @@ func createHTTPTraceClientOptions(cfg TraceExporterConfig) ([]otlptracehttp.Opti
 }
 
 // createGRPCMeterClientOptions creates gRPC client options for metrics exporter.
-func createGRPCMeterClientOptions(cfg MetricExporterConfig) ([]otlpmetricgrpc.Option, error) {
+// A non-nil conn replaces the endpoint and TLS settings of cfg.
+func createGRPCMeterClientOptions(
+	cfg MetricExporterConfig,
+	conn *grpc.ClientConn,
+) ([]otlpmetricgrpc.Option, error) {
 	var opts []otlpmetricgrpc.Option
 
-	// Set endpoint
-	if cfg.Endpoint != "" {
-		opts = append(opts, otlpmetricgrpc.WithEndpoint(cfg.Endpoint))
+	// Set endpoint, falling back to the OTEL environment variables. Values
+	// with a scheme are URLs, which also select TLS by scheme.
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = endpointFromEnv(envOTLPMetricsEndpoint)
+	}
+	switch {
+	case strings.Contains(endpoint, "://"):
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(endpoint))
+	case endpoint != "":
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
 	}
 
 	// Set headers
@@ -154,13 +188,15 @@ func createGRPCMeterClientOptions(cfg MetricExporterConfig) ([]otlpmetricgrpc.Op
 		// Unknown compression, use default
 	}
 
-	// Configure TLS
-	grpcOpts, err := createGRPCDialOptions(cfg.TLS)
-	if err != nil {
-		return nil, err
-	}
-	if len(grpcOpts) > 0 {
-		opts = append(opts, otlpmetricgrpc.WithDialOption(grpcOpts...))
+	// Configure TLS, unless an endpoint URL selects it by scheme
+	if !strings.Contains(endpoint, "://") || cfg.TLS != (TLSConfig{}) {
+		grpcOpts, err := createGRPCDialOptions(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if len(grpcOpts) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithDialOption(grpcOpts...))
+		}
 	}
 
 	// Configure retry
@@ -174,6 +210,17 @@ func createGRPCMeterClientOptions(cfg MetricExporterConfig) ([]otlpmetricgrpc.Op
 		opts = append(opts, otlpmetricgrpc.WithRetry(backoff))
 	}
 
+	// Configure temporality
+	temporality, err := getMetricTemporality(cfg.Temporality)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(temporality))
+
+	if conn != nil {
+		opts = append(opts, otlpmetricgrpc.WithGRPCConn(conn))
+	}
+
 	return opts, nil
 }
 
@@ -224,5 +271,124 @@ func createHTTPMeterClientOptions(cfg MetricExporterConfig) ([]otlpmetrichttp.Op
 		opts = append(opts, otlpmetrichttp.WithRetry(backoff))
 	}
 
+	// Configure temporality
+	temporality, err := getMetricTemporality(cfg.Temporality)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, otlpmetrichttp.WithTemporalitySelector(temporality))
+
+	return opts, nil
+}
+
+// createGRPCLogClientOptions creates gRPC client options for log exporter.
+func createGRPCLogClientOptions(cfg LogExporterConfig) ([]otlploggrpc.Option, error) {
+	var opts []otlploggrpc.Option
+
+	// Set endpoint, falling back to the OTEL environment variables. Values
+	// with a scheme are URLs, which also select TLS by scheme.
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = endpointFromEnv(envOTLPLogsEndpoint)
+	}
+	switch {
+	case strings.Contains(endpoint, "://"):
+		opts = append(opts, otlploggrpc.WithEndpointURL(endpoint))
+	case endpoint != "":
+		opts = append(opts, otlploggrpc.WithEndpoint(endpoint))
+	}
+
+	// Set headers
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlploggrpc.WithHeaders(cfg.Headers))
+	}
+
+	// Set timeout
+	if cfg.Timeout > 0 {
+		opts = append(opts, otlploggrpc.WithTimeout(cfg.Timeout))
+	}
+
+	// Set compression
+	switch cfg.Compression {
+	case "gzip":
+		opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+	case "none", "":
+		// Default (no compression)
+	default:
+		// Unknown compression, use default
+	}
+
+	// Configure TLS, unless an endpoint URL selects it by scheme
+	if !strings.Contains(endpoint, "://") || cfg.TLS != (TLSConfig{}) {
+		grpcOpts, err := createGRPCDialOptions(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if len(grpcOpts) > 0 {
+			opts = append(opts, otlploggrpc.WithDialOption(grpcOpts...))
+		}
+	}
+
+	// Configure retry
+	if cfg.Retry.Enabled {
+		backoff := otlploggrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: cfg.Retry.InitialDelay,
+			MaxInterval:     cfg.Retry.MaxDelay,
+			MaxElapsedTime:  cfg.Retry.MaxDelay * time.Duration(cfg.Retry.MaxAttempts),
+		}
+		opts = append(opts, otlploggrpc.WithRetry(backoff))
+	}
+
+	return opts, nil
+}
+
+// createHTTPLogClientOptions creates HTTP client options for log exporter.
+func createHTTPLogClientOptions(cfg LogExporterConfig) ([]otlploghttp.Option, error) {
+	var opts []otlploghttp.Option
+
+	// Set endpoint
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlploghttp.WithEndpoint(cfg.Endpoint))
+	}
+
+	// Set headers
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlploghttp.WithHeaders(cfg.Headers))
+	}
+
+	// Set timeout
+	if cfg.Timeout > 0 {
+		opts = append(opts, otlploghttp.WithTimeout(cfg.Timeout))
+	}
+
+	// Set compression
+	switch cfg.Compression {
+	case "gzip":
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+	case "none", "":
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.NoCompression))
+	default:
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.NoCompression))
+	}
+
+	// Configure TLS
+	tlsClientOpt, err := createHTTPLogClientTLSOption(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, tlsClientOpt)
+
+	// Configure retry
+	if cfg.Retry.Enabled {
+		backoff := otlploghttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: cfg.Retry.InitialDelay,
+			MaxInterval:     cfg.Retry.MaxDelay,
+			MaxElapsedTime:  cfg.Retry.MaxDelay * time.Duration(cfg.Retry.MaxAttempts),
+		}
+		opts = append(opts, otlploghttp.WithRetry(backoff))
+	}
+
 	return opts, nil
 }
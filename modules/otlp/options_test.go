@@ -16,8 +16,21 @@ package otlp
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collectormetric "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
 )
 
 func TestClientOptionBuilders(t *testing.T) {
@@ -50,7 +63,7 @@ func TestClientOptionBuilders(t *testing.T) {
 		},
 	}
 
-	grpcTraceOpts, err := createGRPCTraceClientOptions(traceCfg)
+	grpcTraceOpts, err := createGRPCTraceClientOptions(traceCfg, nil)
 	if err != nil {
 		t.Fatalf("createGRPCTraceClientOptions() error = %v", err)
 	}
@@ -66,7 +79,7 @@ func TestClientOptionBuilders(t *testing.T) {
 		t.Fatal("createHTTPTraceClientOptions() returned no options")
 	}
 
-	grpcMetricOpts, err := createGRPCMeterClientOptions(metricCfg)
+	grpcMetricOpts, err := createGRPCMeterClientOptions(metricCfg, nil)
 	if err != nil {
 		t.Fatalf("createGRPCMeterClientOptions() error = %v", err)
 	}
@@ -83,6 +96,65 @@ func TestClientOptionBuilders(t *testing.T) {
 	}
 }
 
+func TestLogClientOptionsCarryEndpointAndGzip(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- r:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logCfg := LogExporterConfig{
+		Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+		Headers:     map[string]string{"authorization": "token"},
+		Timeout:     time.Second,
+		Compression: "gzip",
+		TLS:         TLSConfig{Insecure: true},
+	}
+
+	grpcLogOpts, err := createGRPCLogClientOptions(logCfg)
+	if err != nil {
+		t.Fatalf("createGRPCLogClientOptions() error = %v", err)
+	}
+	if len(grpcLogOpts) == 0 {
+		t.Fatal("createGRPCLogClientOptions() returned no options")
+	}
+
+	httpLogOpts, err := createHTTPLogClientOptions(logCfg)
+	if err != nil {
+		t.Fatalf("createHTTPLogClientOptions() error = %v", err)
+	}
+	exporter, err := otlploghttp.New(context.Background(), httpLogOpts...)
+	if err != nil {
+		t.Fatalf("otlploghttp.New() error = %v", err)
+	}
+	defer func() { _ = exporter.Shutdown(context.Background()) }()
+
+	var record sdklog.Record
+	record.SetBody(otellog.StringValue("hello"))
+	if err := exporter.Export(context.Background(), []sdklog.Record{record}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	select {
+	case req := <-requests:
+		if req.URL.Path != "/v1/logs" {
+			t.Fatalf("request path = %q, want /v1/logs", req.URL.Path)
+		}
+		if got := req.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		if got := req.Header.Get("authorization"); got != "token" {
+			t.Fatalf("authorization header = %q, want token", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("log exporter did not reach the configured endpoint")
+	}
+}
+
 func TestCreateGRPCDialOptions(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -166,7 +238,7 @@ func TestExporterFactories(t *testing.T) {
 		TLS:      TLSConfig{Insecure: true},
 	}
 
-	if _, err := createGRPCTraceExporter(context.Background(), traceCfg); err != nil {
+	if _, err := createGRPCTraceExporter(context.Background(), traceCfg, nil); err != nil {
 		t.Fatalf("createGRPCTraceExporter() error = %v", err)
 	}
 	if _, err := createHTTPTraceExporter(context.Background(), TraceExporterConfig{
@@ -175,7 +247,7 @@ func TestExporterFactories(t *testing.T) {
 	}); err != nil {
 		t.Fatalf("createHTTPTraceExporter() error = %v", err)
 	}
-	if _, err := createGRPCMeterExporter(context.Background(), metricCfg); err != nil {
+	if _, err := createGRPCMeterExporter(context.Background(), metricCfg, nil); err != nil {
 		t.Fatalf("createGRPCMeterExporter() error = %v", err)
 	}
 	if _, err := createHTTPMeterExporter(context.Background(), MetricExporterConfig{
@@ -267,3 +339,68 @@ func TestLoadConfigsAndProviderFallbacks(t *testing.T) {
 		t.Fatal("newHTTPMeterProvider() returned nil")
 	}
 }
+
+func TestEndpointFromEnvPrefersSignalVariable(t *testing.T) {
+	t.Setenv(envOTLPEndpoint, "http://generic:4317")
+	t.Setenv(envOTLPTracesEndpoint, "http://traces:4317")
+	t.Setenv(envOTLPMetricsEndpoint, "")
+
+	if got := endpointFromEnv(envOTLPTracesEndpoint); got != "http://traces:4317" {
+		t.Fatalf("traces endpoint = %q, want the signal-specific value", got)
+	}
+	if got := endpointFromEnv(envOTLPMetricsEndpoint); got != "http://generic:4317" {
+		t.Fatalf("metrics endpoint = %q, want the generic value", got)
+	}
+}
+
+func TestGRPCExportersUseEnvEndpoint(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	traces, metrics := &traceCollector{}, &metricCollector{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, traces)
+	collectormetric.RegisterMetricsServiceServer(server, metrics)
+	go server.Serve(lis) //nolint:errcheck
+	defer server.Stop()
+
+	t.Setenv(envOTLPEndpoint, "http://"+lis.Addr().String())
+	t.Setenv(envOTLPTracesEndpoint, "http://"+lis.Addr().String())
+	t.Setenv(envOTLPMetricsEndpoint, "")
+	mod := Module().(*otlpModule)
+	ctx := context.Background()
+
+	tp, ok := mod.newGRPCTracerProvider("svc").(*sdktrace.TracerProvider)
+	if !ok {
+		t.Fatal("gRPC tracer provider fell back to noop")
+	}
+	defer tp.Shutdown(ctx) //nolint:errcheck
+	_, span := tp.Tracer("test").Start(ctx, "op")
+	span.End()
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatalf("tracer ForceFlush() error = %v", err)
+	}
+
+	mp, ok := mod.newGRPCMeterProvider("svc").(*sdkmetric.MeterProvider)
+	if !ok {
+		t.Fatal("gRPC meter provider has an unexpected type")
+	}
+	defer mp.Shutdown(ctx) //nolint:errcheck
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(ctx, 1)
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Fatalf("meter ForceFlush() error = %v", err)
+	}
+
+	if traces.exports.Load() == 0 || metrics.exports.Load() == 0 {
+		t.Fatalf(
+			"exports = %d traces, %d metrics, want both at the env endpoint",
+			traces.exports.Load(),
+			metrics.exports.Load(),
+		)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// NewPrometheusMeterProvider creates a meter provider whose metrics are
+// pulled through the returned handler in the Prometheus exposition format
+// instead of being pushed to a collector. Each provider uses its own registry.
+func NewPrometheusMeterProvider(
+	serviceName string,
+	cfg MetricExporterConfig,
+) (*sdkmetric.MeterProvider, http.Handler, error) {
+	return newPrometheusMeterProvider(serviceName, cfg, ResourceConfig{})
+}
+
+func newPrometheusMeterProvider(
+	serviceName string,
+	cfg MetricExporterConfig,
+	resCfg ResourceConfig,
+) (*sdkmetric.MeterProvider, http.Handler, error) {
+	views, err := newMetricViews(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus metric exporter: %w", err)
+	}
+
+	mp, err := newReaderMeterProvider(
+		context.Background(), serviceName, cfg, resCfg, exporter, views,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mp, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// newPrometheusMeterProvider creates a Prometheus meter provider and serves
+// it on the configured address until the module stops.
+func (m *otlpModule) newPrometheusMeterProvider(
+	serviceName string,
+	cfg MetricExporterConfig,
+) metric.MeterProvider {
+	mp, server, err := m.servePrometheus(serviceName, cfg)
+	if err != nil {
+		slog.Warn("failed to create Prometheus meter provider, using noop",
+			slog.String("error", err.Error()))
+		return sdkmetric.NewMeterProvider()
+	}
+
+	m.mu.Lock()
+	m.metricServers = append(m.metricServers, server)
+	m.mu.Unlock()
+	return mp
+}
+
+func (m *otlpModule) servePrometheus(
+	serviceName string,
+	cfg MetricExporterConfig,
+) (*sdkmetric.MeterProvider, *http.Server, error) {
+	address := cfg.Prometheus.Address
+	if address == "" {
+		address = defaultPrometheusAddress
+	}
+	path := cfg.Prometheus.Path
+	if path == "" {
+		path = defaultPrometheusPath
+	}
+
+	mp, handler, err := newPrometheusMeterProvider(serviceName, cfg, m.resourceConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		_ = mp.Shutdown(context.Background())
+		return nil, nil, fmt.Errorf("failed to listen for Prometheus scrapes: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: defaultTimeout}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Prometheus metrics server stopped", slog.String("error", err.Error()))
+		}
+	}()
+	return mp, server, nil
+}
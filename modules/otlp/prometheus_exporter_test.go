@@ -0,0 +1,94 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codesjoy/yggdrasil/v3/config"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestPrometheusMeterProviderServesCounter(t *testing.T) {
+	mp, handler, err := NewPrometheusMeterProvider("test-service", MetricExporterConfig{})
+	if err != nil {
+		t.Fatalf("NewPrometheusMeterProvider() error = %v", err)
+	}
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(context.Background(), 3)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("scrape error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read scrape body error = %v", err)
+	}
+
+	if !strings.Contains(string(body), "requests_total") ||
+		!strings.Contains(string(body), "} 3") {
+		t.Fatalf("scraped metrics missing requests_total 3:\n%s", body)
+	}
+	if !strings.Contains(string(body), `service_name="test-service"`) {
+		t.Fatalf("scraped metrics missing service name target info:\n%s", body)
+	}
+}
+
+func TestNewMeterProviderRejectsPrometheusProtocol(t *testing.T) {
+	_, err := NewMeterProvider("test-service", MetricExporterConfig{Protocol: protocolPrometheus})
+	if err == nil {
+		t.Fatal("NewMeterProvider(prometheus) error = nil, want pull mode error")
+	}
+}
+
+func TestModulePrometheusMeterProviderStopsServer(t *testing.T) {
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"metric": map[string]any{
+			"protocol":   "prometheus",
+			"prometheus": map[string]any{"address": "127.0.0.1:0"},
+		},
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if _, ok := mod.newGRPCMeterProvider("test-service").(*sdkmetric.MeterProvider); !ok {
+		t.Fatal("newGRPCMeterProvider() did not return an SDK meter provider")
+	}
+	if len(mod.metricServers) != 1 {
+		t.Fatalf("metric servers = %d, want 1", len(mod.metricServers))
+	}
+	if err := mod.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if len(mod.metricServers) != 0 {
+		t.Fatalf("metric servers after Stop = %d, want 0", len(mod.metricServers))
+	}
+}
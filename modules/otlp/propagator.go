@@ -0,0 +1,77 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// NewPropagator builds a composite propagator from propagator names. "b3"
+// uses the single b3 header and "b3multi" the X-B3-* headers.
+func NewPropagator(names PropagatorsConfig) (propagation.TextMapPropagator, error) {
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case propagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case propagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case propagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case propagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case propagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		default:
+			return nil, fmt.Errorf(
+				"unsupported propagator: %s (supported: %s, %s, %s, %s, %s)",
+				name, propagatorTraceContext, propagatorBaggage,
+				propagatorB3, propagatorB3Multi, propagatorJaeger,
+			)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+// Start installs the configured propagator as the global OpenTelemetry
+// propagator. It runs after the Yggdrasil runtime has installed its own
+// process defaults, which would otherwise replace a propagator set in Init.
+func (m *otlpModule) Start(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.propagator == nil || m.previousPropagator != nil {
+		return nil
+	}
+	m.previousPropagator = otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(m.propagator)
+	return nil
+}
+
+// restorePropagator reinstalls the global propagator replaced by Start.
+func (m *otlpModule) restorePropagator() {
+	m.mu.Lock()
+	previous := m.previousPropagator
+	m.previousPropagator = nil
+	m.mu.Unlock()
+	if previous != nil {
+		otel.SetTextMapPropagator(previous)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codesjoy/yggdrasil/v3/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func sampledSpanContext() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09},
+		SpanID:     trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func TestNewPropagatorInjectsConfiguredHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   PropagatorsConfig
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "b3",
+			names:   PropagatorsConfig{"b3"},
+			want:    []string{"b3"},
+			notWant: []string{"traceparent"},
+		},
+		{name: "b3multi", names: PropagatorsConfig{"b3multi"}, want: []string{"x-b3-traceid"}},
+		{name: "jaeger", names: PropagatorsConfig{"jaeger"}, want: []string{"uber-trace-id"}},
+		{
+			name:  "tracecontext and b3",
+			names: PropagatorsConfig{"tracecontext", "baggage", "b3"},
+			want:  []string{"traceparent", "b3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			propagator, err := NewPropagator(tt.names)
+			if err != nil {
+				t.Fatalf("NewPropagator() error = %v", err)
+			}
+			carrier := propagation.MapCarrier{}
+			propagator.Inject(sampledSpanContext(), carrier)
+			for _, key := range tt.want {
+				if carrier.Get(key) == "" {
+					t.Errorf("header %q not injected, got %v", key, carrier)
+				}
+			}
+			for _, key := range tt.notWant {
+				if carrier.Get(key) != "" {
+					t.Errorf("header %q injected, want absent", key)
+				}
+			}
+		})
+	}
+
+	if _, err := NewPropagator(PropagatorsConfig{"xray"}); err == nil {
+		t.Fatal("NewPropagator(xray) error = nil, want error")
+	}
+}
+
+func TestModuleInstallsPropagatorOnStart(t *testing.T) {
+	// The Yggdrasil runtime installs a propagator before modules start.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	mod, ok := Module().(*otlpModule)
+	if !ok {
+		t.Fatalf("Module() type = %T, want *otlpModule", Module())
+	}
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"propagators": []any{"b3"},
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := mod.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(sampledSpanContext(), carrier)
+	if carrier.Get("b3") == "" {
+		t.Fatalf("global propagator did not inject b3, got %v", carrier)
+	}
+
+	if err := mod.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	carrier = propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(sampledSpanContext(), carrier)
+	if carrier.Get("b3") != "" || carrier.Get("traceparent") == "" {
+		t.Fatalf("global propagator after Stop injected %v, want traceparent only", carrier)
+	}
+}
+
+func TestModuleInitRejectsUnknownPropagator(t *testing.T) {
+	mod, ok := Module().(*otlpModule)
+	if !ok {
+		t.Fatalf("Module() type = %T, want *otlpModule", Module())
+	}
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"propagators": []any{"xray"},
+	}))
+	if err := mod.Init(context.Background(), view); err == nil {
+		t.Fatal("Init() error = nil, want unsupported propagator error")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	xotel "github.com/codesjoy/yggdrasil/v3/observability/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// NewResource builds the OpenTelemetry resource shared by OTLP providers.
+//
+// Auto-detected attributes (telemetry SDK, the detectors enabled in
+// cfg.Detectors, OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME) are merged
+// first; configured attributes override them in the order cfg.Attributes, the
+// typed cfg fields, then signalAttrs. service.name defaults to serviceName when
+// cfg.ServiceName is empty. Detector failures are logged and the attributes
+// that could be detected are kept.
+func NewResource(
+	ctx context.Context,
+	serviceName string,
+	cfg ResourceConfig,
+	signalAttrs map[string]interface{},
+) (*resource.Resource, error) {
+	detectors, err := resourceDetectorOptions(cfg.Detectors)
+	if err != nil {
+		return nil, err
+	}
+
+	custom := make(map[string]interface{}, len(cfg.Attributes)+len(signalAttrs)+2)
+	for key, value := range cfg.Attributes {
+		custom[key] = value
+	}
+	if cfg.ServiceVersion != "" {
+		custom[string(semconv.ServiceVersionKey)] = cfg.ServiceVersion
+	}
+	if cfg.Environment != "" {
+		custom[string(semconv.DeploymentEnvironmentNameKey)] = cfg.Environment
+	}
+	for key, value := range signalAttrs {
+		custom[key] = value
+	}
+	if cfg.ServiceName != "" {
+		serviceName = cfg.ServiceName
+	}
+	attrs := xotel.ParseAttributes(buildResourceAttributes(serviceName, custom))
+
+	opts := make([]resource.Option, 0, len(detectors)+3)
+	opts = append(opts, resource.WithTelemetrySDK())
+	opts = append(opts, detectors...)
+	opts = append(opts, resource.WithFromEnv(), resource.WithAttributes(attrs...))
+
+	// resource.New runs every detector and always returns what it detected,
+	// so a failing detector or a malformed OTEL_RESOURCE_ATTRIBUTES entry
+	// should not disable telemetry.
+	res, err := resource.New(ctx, opts...)
+	if err != nil {
+		slog.Warn("partial OTLP resource detected", slog.String("error", err.Error()))
+	}
+	return res, nil
+}
+
+// resourceDetectorOptions maps detector names to resource options.
+func resourceDetectorOptions(names []string) ([]resource.Option, error) {
+	opts := make([]resource.Option, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case resourceDetectorHost:
+			opts = append(opts, resource.WithHost())
+		case resourceDetectorProcess:
+			opts = append(opts, resource.WithProcess())
+		case resourceDetectorContainer:
+			opts = append(opts, resource.WithContainerID())
+		case resourceDetectorK8s:
+			opts = append(opts, resource.WithDetectors(k8sDetector{}))
+		default:
+			return nil, fmt.Errorf(
+				"unsupported resource detector: %s (supported: %s, %s, %s, %s)",
+				name, resourceDetectorHost, resourceDetectorProcess,
+				resourceDetectorContainer, resourceDetectorK8s,
+			)
+		}
+	}
+	return opts, nil
+}
+
+// k8sDetector reads pod identity exposed through the Kubernetes downward API
+// as environment variables.
+type k8sDetector struct{}
+
+var k8sDetectorEnv = []struct {
+	env string
+	key attribute.Key
+}{
+	{env: "K8S_POD_NAME", key: semconv.K8SPodNameKey},
+	{env: "K8S_POD_UID", key: semconv.K8SPodUIDKey},
+	{env: "K8S_NAMESPACE_NAME", key: semconv.K8SNamespaceNameKey},
+	{env: "K8S_NODE_NAME", key: semconv.K8SNodeNameKey},
+}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for _, item := range k8sDetectorEnv {
+		if value := os.Getenv(item.env); value != "" {
+			attrs = append(attrs, item.key.String(value))
+		}
+	}
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/codesjoy/yggdrasil/v3/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func resourceValue(res *resource.Resource, key string) (string, bool) {
+	value, ok := res.Set().Value(attribute.Key(key))
+	return value.Emit(), ok
+}
+
+func TestNewResourceDefaultsServiceName(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+
+	res, err := NewResource(context.Background(), "my-app", ResourceConfig{}, nil)
+	if err != nil {
+		t.Fatalf("NewResource() error = %v", err)
+	}
+	if got, _ := resourceValue(res, "service.name"); got != "my-app" {
+		t.Fatalf("service.name = %q, want my-app", got)
+	}
+	if _, ok := resourceValue(res, "telemetry.sdk.language"); !ok {
+		t.Fatal("telemetry.sdk.language missing, want auto-detected SDK attributes")
+	}
+}
+
+func TestNewResourceUsesConfiguredAttributes(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=platform,service.version=0.0.1")
+
+	res, err := NewResource(context.Background(), "my-app", ResourceConfig{
+		ServiceName:    "orders",
+		ServiceVersion: "1.2.3",
+		Environment:    "prod",
+		Attributes: map[string]interface{}{
+			"region":                      "eu",
+			"deployment.environment.name": "dev",
+		},
+	}, map[string]interface{}{"region": "us"})
+	if err != nil {
+		t.Fatalf("NewResource() error = %v", err)
+	}
+
+	want := map[string]string{
+		"service.name":                "orders",
+		"service.version":             "1.2.3",
+		"deployment.environment.name": "prod",
+		"region":                      "us",
+		"team":                        "platform",
+	}
+	for key, wantValue := range want {
+		if got, _ := resourceValue(res, key); got != wantValue {
+			t.Errorf("%s = %q, want %q", key, got, wantValue)
+		}
+	}
+}
+
+func TestModuleResourceConfig(t *testing.T) {
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"resource": map[string]any{
+			"serviceName": "orders",
+			"attributes":  map[string]any{"region": "eu"},
+		},
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	res, err := NewResource(context.Background(), "my-app", mod.resourceConfig(), nil)
+	if err != nil {
+		t.Fatalf("NewResource() error = %v", err)
+	}
+	if got, _ := resourceValue(res, "service.name"); got != "orders" {
+		t.Fatalf("service.name = %q, want orders", got)
+	}
+	if got, _ := resourceValue(res, "region"); got != "eu" {
+		t.Fatalf("region = %q, want eu", got)
+	}
+}
+
+func TestNewResourceProcessDetector(t *testing.T) {
+	res, err := NewResource(context.Background(), "my-app", ResourceConfig{
+		Detectors: []string{resourceDetectorProcess},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewResource() error = %v", err)
+	}
+	want := strconv.Itoa(os.Getpid())
+	if got, ok := resourceValue(res, "process.pid"); !ok || got != want {
+		t.Fatalf("process.pid = %q, want %q", got, want)
+	}
+}
+
+func TestNewResourceK8sDetector(t *testing.T) {
+	t.Setenv("K8S_POD_NAME", "orders-7d9f")
+	t.Setenv("K8S_NAMESPACE_NAME", "shop")
+
+	res, err := NewResource(context.Background(), "my-app", ResourceConfig{
+		Detectors: []string{resourceDetectorK8s},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewResource() error = %v", err)
+	}
+	if got, _ := resourceValue(res, "k8s.pod.name"); got != "orders-7d9f" {
+		t.Fatalf("k8s.pod.name = %q, want orders-7d9f", got)
+	}
+	if got, _ := resourceValue(res, "k8s.namespace.name"); got != "shop" {
+		t.Fatalf("k8s.namespace.name = %q, want shop", got)
+	}
+	if _, ok := resourceValue(res, "k8s.node.name"); ok {
+		t.Fatal("k8s.node.name present, want unset")
+	}
+}
+
+func TestModuleInitRejectsUnknownResourceDetector(t *testing.T) {
+	mod := Module().(*otlpModule)
+	view := config.NewView(mod.ConfigPath(), config.NewSnapshot(map[string]any{
+		"resource": map[string]any{"detectors": []any{"host", "gpu"}},
+	}))
+	if err := mod.Init(context.Background(), view); err == nil {
+		t.Fatal("Init() error = nil, want unsupported resource detector error")
+	}
+}
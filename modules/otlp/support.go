@@ -18,8 +18,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"os"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	otlpmetrichttp "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"google.golang.org/grpc"
@@ -40,6 +42,58 @@ const (
 
 	defaultExportInterval = 60 * time.Second
 	defaultExportTimeout  = 30 * time.Second
+
+	defaultExponentialHistogramMaxSize  = 160
+	defaultExponentialHistogramMaxScale = 20
+
+	defaultSamplingRatio = 1.0
+
+	defaultPrometheusAddress = ":9464"
+	defaultPrometheusPath    = "/metrics"
+)
+
+// OTLP exporter endpoint environment variables from the OpenTelemetry
+// specification. Signal-specific variables take precedence over the generic
+// one.
+const (
+	envOTLPEndpoint        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOTLPTracesEndpoint  = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	envOTLPMetricsEndpoint = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	envOTLPLogsEndpoint    = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+)
+
+// protocolPrometheus selects the Prometheus pull exporter for metrics.
+const protocolPrometheus = "prometheus"
+
+// protocolStdout selects the pretty-printing stdout exporter for traces and
+// metrics, for local development without a Collector.
+const protocolStdout = "stdout"
+
+const (
+	samplerAlwaysOn     = "always_on"
+	samplerAlwaysOff    = "always_off"
+	samplerTraceIDRatio = "traceid_ratio"
+	samplerParentBased  = "parent_based"
+)
+
+const (
+	propagatorTraceContext = "tracecontext"
+	propagatorBaggage      = "baggage"
+	propagatorB3           = "b3"
+	propagatorB3Multi      = "b3multi"
+	propagatorJaeger       = "jaeger"
+)
+
+const (
+	resourceDetectorHost      = "host"
+	resourceDetectorProcess   = "process"
+	resourceDetectorContainer = "container"
+	resourceDetectorK8s       = "k8s"
+)
+
+const (
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
 )
 
 func buildResourceAttributes(
@@ -56,6 +110,15 @@ func buildResourceAttributes(
 	return attrs
 }
 
+// endpointFromEnv returns the value of the signal-specific endpoint variable,
+// or of OTEL_EXPORTER_OTLP_ENDPOINT when it is unset.
+func endpointFromEnv(signalVar string) string {
+	if endpoint := strings.TrimSpace(os.Getenv(signalVar)); endpoint != "" {
+		return endpoint
+	}
+	return strings.TrimSpace(os.Getenv(envOTLPEndpoint))
+}
+
 func createGRPCDialOptions(tlsCfg TLSConfig) ([]grpc.DialOption, error) {
 	var opts []grpc.DialOption
 
@@ -103,6 +166,20 @@ func createHTTPMetricClientTLSOption(tlsCfg TLSConfig) (otlpmetrichttp.Option, e
 	return otlpmetrichttp.WithInsecure(), nil
 }
 
+func createHTTPLogClientTLSOption(tlsCfg TLSConfig) (otlploghttp.Option, error) {
+	if tlsCfg.Insecure {
+		return otlploghttp.WithInsecure(), nil
+	}
+	if tlsCfg.Enabled {
+		tlsConfig, err := createTLSConfig(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		return otlploghttp.WithTLSClientConfig(tlsConfig), nil
+	}
+	return otlploghttp.WithInsecure(), nil
+}
+
 func createTLSConfig(tlsCfg TLSConfig) (*tls.Config, error) {
 	tlsConfig := &tls.Config{} // nolint:gosec
 
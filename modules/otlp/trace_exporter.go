@@ -17,86 +17,133 @@ package otlp
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 
-	xotel "github.com/codesjoy/yggdrasil/v3/observability/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
 )
 
 // NewTracerProvider creates a new OTLP tracer provider.
 func NewTracerProvider(serviceName string, cfg TraceExporterConfig) (trace.TracerProvider, error) {
+	return newTracerProvider(serviceName, cfg, ResourceConfig{}, nil)
+}
+
+func newTracerProvider(
+	serviceName string,
+	cfg TraceExporterConfig,
+	resCfg ResourceConfig,
+	conn *grpc.ClientConn,
+) (trace.TracerProvider, error) {
 	ctx := context.Background()
 	cfg = applyTraceDefaults(cfg)
+	sampler, err := newSampler(cfg.Sampling)
+	if err != nil {
+		return nil, err
+	}
 
-	var (
-		exporter sdktrace.SpanExporter
-		err      error
-	)
+	var exporter sdktrace.SpanExporter
 
 	switch cfg.Protocol {
 	case "grpc", "":
-		exporter, err = createGRPCTraceExporter(ctx, cfg)
+		exporter, err = createGRPCTraceExporter(ctx, cfg, conn)
 	case "http":
 		exporter, err = createHTTPTraceExporter(ctx, cfg)
+	case protocolStdout:
+		exporter, err = createStdoutTraceExporter(os.Stdout)
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s (supported: grpc, http)", cfg.Protocol)
+		return nil, fmt.Errorf(
+			"unsupported protocol: %s (supported: grpc, http, stdout)",
+			cfg.Protocol,
+		)
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
 	}
 
-	// Build resource attributes
-	resourceAttrs := buildResourceAttributes(serviceName, cfg.Resource)
+	// Create tracer provider
+	res, err := NewResource(ctx, serviceName, resCfg, cfg.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithBatcher(exporter, newBatchSpanProcessorOptions(cfg.Batch)...),
+	)
+
+	return tp, nil
+}
 
-	// Create batch span processor
-	var batchOpts []sdktrace.BatchSpanProcessorOption
-	if cfg.Batch.BatchTimeout > 0 {
-		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(cfg.Batch.BatchTimeout))
+// newBatchSpanProcessorOptions maps cfg onto batch span processor options,
+// using the package defaults for unset values. An unset export timeout keeps
+// the SDK default.
+func newBatchSpanProcessorOptions(cfg BatchConfig) []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if cfg.BatchTimeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(cfg.BatchTimeout))
 	} else {
-		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(defaultBatchTimeout))
+		opts = append(opts, sdktrace.WithBatchTimeout(defaultBatchTimeout))
 	}
-	if cfg.Batch.MaxQueueSize > 0 {
-		batchOpts = append(batchOpts, sdktrace.WithMaxQueueSize(cfg.Batch.MaxQueueSize))
+	if cfg.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(cfg.MaxQueueSize))
 	} else {
-		batchOpts = append(batchOpts, sdktrace.WithMaxQueueSize(defaultMaxQueueSize))
+		opts = append(opts, sdktrace.WithMaxQueueSize(defaultMaxQueueSize))
 	}
-	if cfg.Batch.MaxExportBatchSize > 0 {
-		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(cfg.Batch.MaxExportBatchSize))
+	if cfg.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(cfg.MaxExportBatchSize))
 	} else {
-		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(defaultMaxExportBatchSize))
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(defaultMaxExportBatchSize))
 	}
-
-	bsp := sdktrace.NewBatchSpanProcessor(exporter, batchOpts...)
-
-	// Create tracer provider
-	attrs := xotel.ParseAttributes(resourceAttrs)
-	res, err := resource.New(ctx,
-		resource.WithAttributes(attrs...),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+	if cfg.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(cfg.ExportTimeout))
 	}
+	return opts
+}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
+// newSampler creates the sampler described by cfg. Parent-based sampling
+// follows the parent's decision and samples root spans by ratio.
+func newSampler(cfg SamplingConfig) (sdktrace.Sampler, error) {
+	ratio := cfg.Ratio
+	if ratio == 0 {
+		ratio = defaultSamplingRatio
+	}
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid sampling ratio: %v (must be in (0, 1])", cfg.Ratio)
+	}
 
-	return tp, nil
+	switch cfg.Type {
+	case samplerAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case samplerAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case samplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case samplerParentBased, "":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	default:
+		return nil, fmt.Errorf(
+			"unsupported sampler type: %s (supported: %s, %s, %s, %s)",
+			cfg.Type, samplerAlwaysOn, samplerAlwaysOff, samplerTraceIDRatio, samplerParentBased,
+		)
+	}
 }
 
 // createGRPCTraceExporter creates a gRPC OTLP trace exporter.
 func createGRPCTraceExporter(
 	ctx context.Context,
 	cfg TraceExporterConfig,
+	conn *grpc.ClientConn,
 ) (sdktrace.SpanExporter, error) {
-	opts, err := createGRPCTraceClientOptions(cfg)
+	opts, err := createGRPCTraceClientOptions(cfg, conn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC client options: %w", err)
 	}
@@ -127,16 +174,29 @@ func createHTTPTraceExporter(
 	return exporter, nil
 }
 
+// createStdoutTraceExporter creates an exporter that pretty-prints spans as
+// JSON to w.
+func createStdoutTraceExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
 // newGRPCTracerProvider creates a gRPC tracer provider from config.
 func (m *otlpModule) newGRPCTracerProvider(serviceName string) trace.TracerProvider {
 	cfg := m.traceConfig()
-	cfg.Protocol = "grpc"
+	if cfg.Protocol != protocolStdout {
+		cfg.Protocol = "grpc"
+	}
 
-	if cfg.Endpoint == "" {
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPTracesEndpoint) == "" {
 		cfg.Endpoint = defaultGRPCEndpoint
 	}
 
-	tp, err := NewTracerProvider(serviceName, cfg)
+	tp, err := newTracerProvider(serviceName, cfg, m.resourceConfig(), m.sharedConn())
 	if err != nil {
 		slog.Warn("failed to create OTLP gRPC tracer provider, using noop",
 			slog.String("error", err.Error()))
@@ -149,13 +209,15 @@ func (m *otlpModule) newGRPCTracerProvider(serviceName string) trace.TracerProvi
 // newHTTPTracerProvider creates an HTTP tracer provider from config.
 func (m *otlpModule) newHTTPTracerProvider(serviceName string) trace.TracerProvider {
 	cfg := m.traceConfig()
-	cfg.Protocol = "http"
+	if cfg.Protocol != protocolStdout {
+		cfg.Protocol = "http"
+	}
 
-	if cfg.Endpoint == "" {
+	if cfg.Endpoint == "" && endpointFromEnv(envOTLPTracesEndpoint) == "" {
 		cfg.Endpoint = defaultHTTPEndpoint
 	}
 
-	tp, err := NewTracerProvider(serviceName, cfg)
+	tp, err := newTracerProvider(serviceName, cfg, m.resourceConfig(), nil)
 	if err != nil {
 		slog.Warn("failed to create OTLP HTTP tracer provider, using noop",
 			slog.String("error", err.Error()))
@@ -187,6 +249,12 @@ func applyTraceDefaults(cfg TraceExporterConfig) TraceExporterConfig {
 	if cfg.Retry.MaxAttempts == 0 && cfg.Retry.Enabled {
 		cfg.Retry.MaxAttempts = defaultMaxAttempts
 	}
+	if cfg.Sampling.Type == "" {
+		cfg.Sampling.Type = samplerParentBased
+	}
+	if cfg.Sampling.Ratio == 0 {
+		cfg.Sampling.Ratio = defaultSamplingRatio
+	}
 
 	return cfg
 }
@@ -15,8 +15,14 @@
 package otlp
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestBuildResourceAttributes(t *testing.T) {
@@ -124,3 +130,150 @@ func TestDefaultConstants(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SamplingConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default is parent based with full ratio",
+			cfg:  SamplingConfig{},
+			want: "ParentBased{root:AlwaysOnSampler,",
+		},
+		{
+			name: "always on",
+			cfg:  SamplingConfig{Type: "always_on"},
+			want: "AlwaysOnSampler",
+		},
+		{
+			name: "always off",
+			cfg:  SamplingConfig{Type: "always_off"},
+			want: "AlwaysOffSampler",
+		},
+		{
+			name: "traceid ratio",
+			cfg:  SamplingConfig{Type: "traceid_ratio", Ratio: 0.25},
+			want: "TraceIDRatioBased{0.25}",
+		},
+		{
+			name: "parent based ratio",
+			cfg:  SamplingConfig{Type: "parent_based", Ratio: 0.5},
+			want: "ParentBased{root:TraceIDRatioBased{0.5},",
+		},
+		{
+			name:    "unknown type",
+			cfg:     SamplingConfig{Type: "sometimes"},
+			wantErr: true,
+		},
+		{
+			name:    "ratio out of range",
+			cfg:     SamplingConfig{Type: "traceid_ratio", Ratio: 1.5},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := newSampler(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newSampler() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newSampler() error = %v", err)
+			}
+			if got := sampler.Description(); !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("Description() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTraceDefaultsSampling(t *testing.T) {
+	cfg := applyTraceDefaults(TraceExporterConfig{})
+	if cfg.Sampling.Type != "parent_based" || cfg.Sampling.Ratio != 1.0 {
+		t.Fatalf("Sampling = %#v, want parent_based with ratio 1.0", cfg.Sampling)
+	}
+	if _, err := NewTracerProvider("svc", TraceExporterConfig{
+		Sampling: SamplingConfig{Type: "sometimes"},
+	}); err == nil {
+		t.Fatal("NewTracerProvider() expected unsupported sampler error")
+	}
+}
+
+func TestNewBatchSpanProcessorOptions(t *testing.T) {
+	apply := func(cfg BatchConfig) sdktrace.BatchSpanProcessorOptions {
+		var got sdktrace.BatchSpanProcessorOptions
+		for _, opt := range newBatchSpanProcessorOptions(cfg) {
+			opt(&got)
+		}
+		return got
+	}
+
+	got := apply(BatchConfig{
+		BatchTimeout:       time.Second,
+		MaxQueueSize:       10000,
+		MaxExportBatchSize: 1000,
+		ExportTimeout:      7 * time.Second,
+	})
+	want := sdktrace.BatchSpanProcessorOptions{
+		BatchTimeout:       time.Second,
+		MaxQueueSize:       10000,
+		MaxExportBatchSize: 1000,
+		ExportTimeout:      7 * time.Second,
+	}
+	if got != want {
+		t.Fatalf("options = %+v, want %+v", got, want)
+	}
+
+	got = apply(BatchConfig{})
+	if got.BatchTimeout != defaultBatchTimeout ||
+		got.MaxQueueSize != defaultMaxQueueSize ||
+		got.MaxExportBatchSize != defaultMaxExportBatchSize ||
+		got.ExportTimeout != 0 {
+		t.Fatalf("default options = %+v", got)
+	}
+}
+
+func TestStdoutTraceExporterWritesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := createStdoutTraceExporter(&buf)
+	if err != nil {
+		t.Fatalf("createStdoutTraceExporter() error = %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background()) //nolint:errcheck
+
+	_, span := tp.Tracer("test").Start(context.Background(), "stdout-span")
+	span.End()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	var got struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	traceID := span.SpanContext().TraceID().String()
+	if got.Name != "stdout-span" || got.SpanContext.TraceID != traceID {
+		t.Fatalf("span = %+v, want stdout-span with trace %s", got, traceID)
+	}
+}
+
+func TestNewTracerProviderStdoutProtocol(t *testing.T) {
+	tp, err := NewTracerProvider("svc", TraceExporterConfig{Protocol: protocolStdout})
+	if err != nil {
+		t.Fatalf("NewTracerProvider() error = %v", err)
+	}
+	if err := tp.(*sdktrace.TracerProvider).Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
@@ -19,13 +19,34 @@ import "time"
 
 // Config is the top-level configuration for OTLP exporters.
 type Config struct {
-	Trace  TraceExporterConfig  `mapstructure:"trace"`
-	Metric MetricExporterConfig `mapstructure:"metric"`
+	Trace       TraceExporterConfig  `mapstructure:"trace"`
+	Metric      MetricExporterConfig `mapstructure:"metric"`
+	Log         LogExporterConfig    `mapstructure:"log"`
+	Resource    ResourceConfig       `mapstructure:"resource"`
+	Propagators PropagatorsConfig    `mapstructure:"propagators"`
+
+	// SharedGRPCConn makes the otlp-grpc tracer and meter providers export
+	// over one gRPC connection dialed from the trace endpoint and TLS settings.
+	SharedGRPCConn bool `mapstructure:"sharedGRPCConn"`
+}
+
+// ResourceConfig describes the resource shared by all OTLP signals. Per-signal
+// resource maps are merged on top of it.
+type ResourceConfig struct {
+	ServiceName    string                 `mapstructure:"serviceName"`    // Defaults to app name
+	ServiceVersion string                 `mapstructure:"serviceVersion"` // Service version
+	Environment    string                 `mapstructure:"environment"`    // Deployment environment
+	Attributes     map[string]interface{} `mapstructure:"attributes"`     // Extra attributes
+	Detectors      []string               `mapstructure:"detectors"`      // Resource detectors
 }
 
+// PropagatorsConfig lists the trace context propagators to install, in order:
+// tracecontext, baggage, b3, b3multi or jaeger.
+type PropagatorsConfig []string
+
 // TraceExporterConfig is the configuration for OTLP trace exporter.
 type TraceExporterConfig struct {
-	Protocol    string                 `mapstructure:"protocol"`    // grpc or http
+	Protocol    string                 `mapstructure:"protocol"`    // grpc, http or stdout
 	Endpoint    string                 `mapstructure:"endpoint"`    // OTLP endpoint
 	TLS         TLSConfig              `mapstructure:"tls"`         // TLS configuration
 	Headers     map[string]string      `mapstructure:"headers"`     // Custom headers (e.g., auth)
@@ -34,11 +55,18 @@ type TraceExporterConfig struct {
 	Retry       RetryConfig            `mapstructure:"retry"`       // Retry configuration
 	Batch       BatchConfig            `mapstructure:"batch"`       // Batch processing config
 	Resource    map[string]interface{} `mapstructure:"resource"`    // Resource attributes
+	Sampling    SamplingConfig         `mapstructure:"sampling"`    // Trace sampling policy
+}
+
+// SamplingConfig is the trace sampling configuration.
+type SamplingConfig struct {
+	Type  string  `mapstructure:"type"`  // always_on, always_off, traceid_ratio or parent_based
+	Ratio float64 `mapstructure:"ratio"` // Sampling ratio in (0, 1]; 0 means 1.0
 }
 
 // MetricExporterConfig is the configuration for OTLP metrics exporter.
 type MetricExporterConfig struct {
-	Protocol       string                 `mapstructure:"protocol"`       // grpc or http
+	Protocol       string                 `mapstructure:"protocol"`       // grpc/http/prometheus
 	Endpoint       string                 `mapstructure:"endpoint"`       // OTLP endpoint
 	TLS            TLSConfig              `mapstructure:"tls"`            // TLS configuration
 	Headers        map[string]string      `mapstructure:"headers"`        // Custom headers
@@ -49,6 +77,36 @@ type MetricExporterConfig struct {
 	Resource       map[string]interface{} `mapstructure:"resource"`       // Resource attributes
 	ExportInterval time.Duration          `mapstructure:"exportInterval"` // Metrics export interval
 	ExportTimeout  time.Duration          `mapstructure:"exportTimeout"`  // Metrics export timeout
+
+	HistogramBoundaries     []float64 `mapstructure:"histogramBoundaries"`     // Explicit buckets
+	UseExponentialHistogram bool      `mapstructure:"useExponentialHistogram"` // Base-2 exponential
+
+	// Exemplars attaches the trace and span of sampled spans active during a
+	// measurement as exemplars when true and records none when false. Unset,
+	// the SDK default applies, which honors OTEL_METRICS_EXEMPLAR_FILTER.
+	Exemplars *bool `mapstructure:"exemplars"`
+
+	Prometheus PrometheusConfig `mapstructure:"prometheus"` // Pull endpoint for protocol prometheus
+}
+
+// PrometheusConfig is the scrape endpoint served when metrics use the
+// prometheus protocol.
+type PrometheusConfig struct {
+	Address string `mapstructure:"address"` // Listen address, default :9464
+	Path    string `mapstructure:"path"`    // Scrape path, default /metrics
+}
+
+// LogExporterConfig is the configuration for OTLP log exporter.
+type LogExporterConfig struct {
+	Protocol    string                 `mapstructure:"protocol"`    // grpc or http
+	Endpoint    string                 `mapstructure:"endpoint"`    // OTLP endpoint
+	TLS         TLSConfig              `mapstructure:"tls"`         // TLS configuration
+	Headers     map[string]string      `mapstructure:"headers"`     // Custom headers (e.g., auth)
+	Timeout     time.Duration          `mapstructure:"timeout"`     // Request timeout
+	Compression string                 `mapstructure:"compression"` // Compression type (gzip, none)
+	Retry       RetryConfig            `mapstructure:"retry"`       // Retry configuration
+	Batch       BatchConfig            `mapstructure:"batch"`       // Batch processing config
+	Resource    map[string]interface{} `mapstructure:"resource"`    // Resource attributes
 }
 
 // TLSConfig is the TLS configuration for OTLP clients.
@@ -68,9 +126,10 @@ type RetryConfig struct {
 	MaxDelay     time.Duration `mapstructure:"maxDelay"`     // Maximum delay between retries
 }
 
-// BatchConfig is the batch configuration for trace exporters.
+// BatchConfig is the batch configuration for trace and log exporters.
 type BatchConfig struct {
 	BatchTimeout       time.Duration `mapstructure:"batchTimeout"`       // Time to wait before exporting
 	MaxQueueSize       int           `mapstructure:"maxQueueSize"`       // Maximum queue size
 	MaxExportBatchSize int           `mapstructure:"maxExportBatchSize"` // Maximum batch size
+	ExportTimeout      time.Duration `mapstructure:"exportTimeout"`      // Timeout of one batch export
 }
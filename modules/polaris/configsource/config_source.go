@@ -17,6 +17,8 @@ package configsource
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -32,12 +34,17 @@ import (
 )
 
 // Config is the config for the Polaris config source.
+//
+// Files, when set, replaces FileName with several files of FileGroup. They are
+// deep-merged in declared order, so later files override earlier ones, and a
+// change to any of them re-emits the merged tree.
 type Config struct {
 	Addresses    []string      `mapstructure:"addresses"`
 	SDK          string        `mapstructure:"sdk"`
 	Namespace    string        `mapstructure:"namespace"`
 	FileGroup    string        `mapstructure:"file_group"`
 	FileName     string        `mapstructure:"file_name"`
+	Files        []string      `mapstructure:"files"`
 	Subscribe    *bool         `mapstructure:"subscribe"`
 	Mode         int           `mapstructure:"mode"`
 	Format       source.Parser `mapstructure:"format"`
@@ -48,12 +55,19 @@ type Config struct {
 
 // NewConfigSource creates a new Polaris config source.
 func NewConfigSource(cfg Config) (source.Source, error) {
-	if strings.TrimSpace(cfg.FileName) == "" {
-		return nil, errors.New("empty polaris config file_name")
+	files := cfg.Files
+	if len(files) == 0 {
+		files = []string{cfg.FileName}
+	}
+	for _, name := range files {
+		if strings.TrimSpace(name) == "" {
+			return nil, errors.New("empty polaris config file_name")
+		}
 	}
 	subscribe := cfg.Subscribe == nil || *cfg.Subscribe
 	return &configSource{
-		name:      cfg.FileName,
+		name:      strings.Join(files, "+"),
+		files:     files,
 		cfg:       cfg,
 		subscribe: subscribe,
 		closeCh:   make(chan struct{}),
@@ -62,6 +76,7 @@ func NewConfigSource(cfg Config) (source.Source, error) {
 
 type configSource struct {
 	name      string
+	files     []string
 	cfg       Config
 	subscribe bool
 
@@ -74,35 +89,80 @@ func (s *configSource) Name() string { return s.name }
 func (s *configSource) Kind() string { return "polaris" }
 
 func (s *configSource) Read() (source.Data, error) {
-	file, parser, err := s.fetchConfigFile()
-	if err != nil {
-		return nil, err
+	contents := make([]string, len(s.files))
+	for i, name := range s.files {
+		file, err := s.fetchConfigFile(name)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = file.GetContent()
 	}
-	return source.NewBytesData([]byte(file.GetContent()), parser), nil
+	return s.data(contents)
 }
 
 func (s *configSource) Watch() (<-chan source.Data, error) {
 	if !s.subscribe {
 		return nil, errors.New("polaris config source is not subscribable")
 	}
-	file, parser, err := s.fetchConfigFile()
-	if err != nil {
-		return nil, err
+	contents := make([]string, len(s.files))
+	listeners := make([]<-chan model.ConfigFileChangeEvent, len(s.files))
+	for i, name := range s.files {
+		file, err := s.fetchConfigFile(name)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = file.GetContent()
+		listeners[i] = file.AddChangeListenerWithChannel()
+	}
+
+	type change struct {
+		index int
+		value string
+	}
+	changes := make(chan change)
+	for i, ch := range listeners {
+		go func(index int, ch <-chan model.ConfigFileChangeEvent) {
+			for {
+				select {
+				case <-s.closeCh:
+					return
+				case ev, ok := <-ch:
+					if !ok {
+						return
+					}
+					select {
+					case changes <- change{index: index, value: ev.NewValue}:
+					case <-s.closeCh:
+						return
+					}
+				}
+			}
+		}(i, ch)
 	}
 
 	out := make(chan source.Data)
-	ch := file.AddChangeListenerWithChannel()
 	go func() {
 		defer close(out)
 		for {
 			select {
 			case <-s.closeCh:
 				return
-			case ev, ok := <-ch:
-				if !ok {
+			case c := <-changes:
+				contents[c.index] = c.value
+				data, err := s.data(contents)
+				if err != nil {
+					// Nothing is emitted until every file parses again.
+					slog.Warn("polaris config source skipped unparsable change",
+						slog.String("file", s.files[c.index]),
+						slog.String("file_group", s.cfg.FileGroup),
+						slog.Any("error", err))
+					continue
+				}
+				select {
+				case out <- data:
+				case <-s.closeCh:
 					return
 				}
-				out <- source.NewBytesData([]byte(ev.NewValue), parser)
 			}
 		}
 	}()
@@ -116,7 +176,34 @@ func (s *configSource) Close() error {
 	return nil
 }
 
-func (s *configSource) fetchConfigFile() (model.ConfigFile, source.Parser, error) {
+// data returns a single file as raw bytes and several files as their merged
+// tree, parsing each with its own format.
+func (s *configSource) data(contents []string) (source.Data, error) {
+	if len(contents) == 1 {
+		return source.NewBytesData([]byte(contents[0]), s.parser(s.files[0])), nil
+	}
+	merged := map[string]any{}
+	for i, content := range contents {
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		values := map[string]any{}
+		if err := s.parser(s.files[i])([]byte(content), &values); err != nil {
+			return nil, fmt.Errorf("parse polaris config file %s: %w", s.files[i], err)
+		}
+		merged = mergeMaps(merged, values)
+	}
+	return source.NewMapData(merged), nil
+}
+
+func (s *configSource) parser(fileName string) source.Parser {
+	if s.cfg.Format != nil {
+		return s.cfg.Format
+	}
+	return inferParserFromFilename(fileName)
+}
+
+func (s *configSource) fetchConfigFile(fileName string) (model.ConfigFile, error) {
 	namespace := s.cfg.Namespace
 	if namespace == "" {
 		namespace = "default"
@@ -126,18 +213,13 @@ func (s *configSource) fetchConfigFile() (model.ConfigFile, source.Parser, error
 		fileGroup = "default"
 	}
 
-	parser := s.cfg.Format
-	if parser == nil {
-		parser = inferParserFromFilename(s.cfg.FileName)
-	}
-
 	client := s.cfg.API
 	if client == nil {
 		sdkName := sdk.ResolveSDKName("default", s.cfg.SDK)
 		addresses := sdk.ResolveSDKConfigAddresses("default", s.cfg.SDK, s.cfg.Addresses)
 		api, err := sdk.GetHolder(sdkName, nil, addresses).Config()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		client = api
 	}
@@ -145,7 +227,7 @@ func (s *configSource) fetchConfigFile() (model.ConfigFile, source.Parser, error
 	req := &polaris.GetConfigFileRequest{GetConfigFileRequest: &model.GetConfigFileRequest{
 		Namespace: namespace,
 		FileGroup: fileGroup,
-		FileName:  s.cfg.FileName,
+		FileName:  fileName,
 		Subscribe: s.subscribe,
 		Mode:      model.GetConfigFileRequestMode(s.cfg.Mode),
 	}}
@@ -154,11 +236,7 @@ func (s *configSource) fetchConfigFile() (model.ConfigFile, source.Parser, error
 	}
 
 	if s.cfg.FetchTimeout <= 0 {
-		file, err := client.FetchConfigFile(req)
-		if err != nil {
-			return nil, nil, err
-		}
-		return file, parser, nil
+		return client.FetchConfigFile(req)
 	}
 
 	type resp struct {
@@ -174,13 +252,23 @@ func (s *configSource) fetchConfigFile() (model.ConfigFile, source.Parser, error
 	defer t.Stop()
 	select {
 	case r := <-ch:
-		if r.err != nil {
-			return nil, nil, r.err
-		}
-		return r.file, parser, nil
+		return r.file, r.err
 	case <-t.C:
-		return nil, nil, errors.New("polaris config fetch timeout")
+		return nil, errors.New("polaris config fetch timeout")
+	}
+}
+
+func mergeMaps(dst, src map[string]any) map[string]any {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcValue
 	}
+	return dst
 }
 
 func inferParserFromFilename(name string) source.Parser {
@@ -24,15 +24,19 @@ import (
 )
 
 type fakeConfigAPI struct {
-	reqs []polaris.GetConfigFileRequest
-	file model.ConfigFile
-	err  error
+	reqs  []polaris.GetConfigFileRequest
+	file  model.ConfigFile
+	files map[string]model.ConfigFile
+	err   error
 }
 
 func (f *fakeConfigAPI) FetchConfigFile(
 	req *polaris.GetConfigFileRequest,
 ) (model.ConfigFile, error) {
 	f.reqs = append(f.reqs, *req)
+	if f.files != nil {
+		return f.files[req.FileName], f.err
+	}
 	return f.file, f.err
 }
 
@@ -142,3 +146,86 @@ func TestConfigSourceReadAndWatch(t *testing.T) {
 		t.Fatal("timeout waiting for watch event")
 	}
 }
+
+func TestConfigSourceMergesGroupFiles(t *testing.T) {
+	base := &fakeConfigFile{
+		namespace: "prod",
+		group:     "app",
+		name:      "base.yaml",
+		content:   "app:\n  name: demo\n  rest:\n    port: 8080\n    enable: true\n",
+		ch:        make(chan model.ConfigFileChangeEvent, 1),
+	}
+	override := &fakeConfigFile{
+		namespace: "prod",
+		group:     "app",
+		name:      "override.json",
+		content:   `{"app":{"rest":{"port":9090}}}`,
+		ch:        make(chan model.ConfigFileChangeEvent, 1),
+	}
+	api := &fakeConfigAPI{files: map[string]model.ConfigFile{
+		"base.yaml":     base,
+		"override.json": override,
+	}}
+	src, err := NewConfigSource(Config{
+		Namespace: "prod",
+		FileGroup: "app",
+		Files:     []string{"base.yaml", "override.json"},
+		API:       api,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigSource err: %v", err)
+	}
+	defer src.Close()
+
+	if src.Name() != "base.yaml+override.json" {
+		t.Fatalf("Name() = %q, want base.yaml+override.json", src.Name())
+	}
+	data, err := src.Read()
+	if err != nil {
+		t.Fatalf("Read err: %v", err)
+	}
+	assertRest := func(data source.Data, port float64, enable bool) {
+		t.Helper()
+		var m map[string]any
+		if err := data.Unmarshal(&m); err != nil {
+			t.Fatalf("Unmarshal err: %v", err)
+		}
+		app := m["app"].(map[string]any)
+		rest := app["rest"].(map[string]any)
+		if app["name"] != "demo" || toFloat(rest["port"]) != port || rest["enable"] != enable {
+			t.Fatalf("merged app = %#v, want name demo, port %v, enable %v", app, port, enable)
+		}
+	}
+	assertRest(data, 9090, true)
+	for i, want := range []string{"base.yaml", "override.json"} {
+		req := api.reqs[i].GetConfigFileRequest
+		if req.Namespace != "prod" || req.FileGroup != "app" || req.FileName != want {
+			t.Fatalf("request %d = %+v, want prod/app/%s", i, req, want)
+		}
+	}
+
+	wch, err := src.(source.Watchable).Watch()
+	if err != nil {
+		t.Fatalf("Watch err: %v", err)
+	}
+	base.ch <- model.ConfigFileChangeEvent{
+		NewValue: "app:\n  name: demo\n  rest:\n    port: 8080\n    enable: false\n",
+	}
+	select {
+	case d := <-wch:
+		assertRest(d, 9090, false)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for merged watch event")
+	}
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	default:
+		return -1
+	}
+}
@@ -171,6 +171,50 @@ func TestRegistryRegisterAndDeregister(t *testing.T) {
 	}
 }
 
+func TestRegistryRegisterNamespaceAndToken(t *testing.T) {
+	fp := &fakeProvider{nextID: "instance-2"}
+	r := &Registry{
+		cfg:          RegistryConfig{Namespace: "configured", ServiceToken: "token"},
+		api:          fp,
+		instanceName: "default",
+		registered:   map[string]registeredInstance{},
+	}
+	inst := testInstance{
+		namespace: "ignored",
+		name:      "svc",
+		endpoints: []yregistry.Endpoint{
+			testEndpoint{scheme: "http", address: "10.0.0.1:80"},
+		},
+	}
+
+	if err := r.Register(context.Background(), inst); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	req := fp.registerReqs[0]
+	if req.Namespace != "configured" || req.ServiceToken != "token" {
+		t.Fatalf("Namespace/ServiceToken = %q/%q, want configured/token",
+			req.Namespace, req.ServiceToken)
+	}
+	if req.TTL != nil || req.AutoHeartbeat {
+		t.Fatalf("TTL = %v AutoHeartbeat = %v, want unset without ttl", req.TTL, req.AutoHeartbeat)
+	}
+
+	if err := r.Deregister(context.Background(), inst); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	dreq := fp.deregisterReqs[0]
+	if dreq.Namespace != "configured" || dreq.ServiceToken != "token" ||
+		dreq.InstanceID != "instance-2" || dreq.Host != "10.0.0.1" || dreq.Port != 80 {
+		t.Fatalf("unexpected deregister request: %+v", dreq.InstanceDeRegisterRequest)
+	}
+	if err := r.Deregister(context.Background(), inst); err != nil {
+		t.Fatalf("second Deregister() error = %v", err)
+	}
+	if got := len(fp.deregisterReqs); got != 1 {
+		t.Fatalf("deregisterReqs len = %d, want 1 after repeated deregister", got)
+	}
+}
+
 func TestRegistryConstructorsAndDecodeMap(t *testing.T) {
 	restoreDiscoveryGlobals(t)
 
@@ -33,8 +33,32 @@ import (
 )
 
 type polarisModule struct {
-	mu       sync.RWMutex
-	settings settings
+	mu          sync.RWMutex
+	appName     string
+	settings    settings
+	trafficOpts []traffic.Option
+}
+
+// ModuleOption configures the Polaris module.
+type ModuleOption func(*polarisModule)
+
+// WithAppName sets the app name passed to yggdrasil.New. It is the default
+// caller service for routing, rate limiting, and circuit breaking when
+// caller_service is unset.
+func WithAppName(name string) ModuleOption {
+	return func(m *polarisModule) {
+		m.appName = name
+	}
+}
+
+// WithArgumentExtractor sets the extractor the polaris_routing_args unary
+// client interceptor runs on calls to serviceName, mapping request fields to
+// Polaris custom routing arguments. An empty serviceName applies to every
+// service without its own extractor.
+func WithArgumentExtractor(serviceName string, extractor traffic.ArgumentExtractor) ModuleOption {
+	return func(m *polarisModule) {
+		m.trafficOpts = append(m.trafficOpts, traffic.WithArgumentExtractor(serviceName, extractor))
+	}
 }
 
 type settings struct {
@@ -43,6 +67,7 @@ type settings struct {
 		Governance struct {
 			Defaults map[string]any            `mapstructure:"defaults"`
 			Services map[string]map[string]any `mapstructure:"services"`
+			Server   map[string]any            `mapstructure:"server"`
 		} `mapstructure:"governance"`
 	} `mapstructure:"polaris"`
 	Discovery struct {
@@ -61,18 +86,26 @@ type settings struct {
 			Config map[string]any `mapstructure:"config"`
 		} `mapstructure:"services"`
 	} `mapstructure:"balancers"`
+	Admin struct {
+		Application struct {
+			Namespace string `mapstructure:"namespace"`
+		} `mapstructure:"application"`
+	} `mapstructure:"admin"`
 }
 
 // Module returns the Yggdrasil v3 Polaris capability module.
-func Module() module.Module {
+func Module(opts ...ModuleOption) module.Module {
 	m := &polarisModule{}
+	for _, opt := range opts {
+		opt(m)
+	}
 	sdk.ConfigureConfigLoader(m.sdkConfig)
 	return m
 }
 
 // WithModule registers the Polaris capability module on a Yggdrasil v3 app.
-func WithModule() yggdrasil.Option {
-	return yggdrasil.WithModules(Module())
+func WithModule(opts ...ModuleOption) yggdrasil.Option {
+	return yggdrasil.WithModules(Module(opts...))
 }
 
 // WithConfigSource registers a Polaris-backed configuration source layer.
@@ -121,6 +154,7 @@ func (m *polarisModule) Init(_ context.Context, view config.View) error {
 }
 
 func (m *polarisModule) Capabilities() []module.Capability {
+	load := traffic.WithCallerDefaults(m.governanceConfig, m.callerIdentity)
 	caps := []module.Capability{
 		capabilities.ProvideNamed(
 			capabilities.RegistryProviderSpec,
@@ -135,16 +169,30 @@ func (m *polarisModule) Capabilities() []module.Capability {
 		capabilities.ProvideNamed(
 			capabilities.BalancerProviderSpec,
 			"polaris",
-			traffic.BalancerProvider(m.governanceConfig),
+			traffic.BalancerProvider(load),
 		),
 	}
-	for _, provider := range traffic.UnaryClientInterceptorProviders(m.governanceConfig) {
+	for _, provider := range traffic.UnaryClientInterceptorProviders(load, m.trafficOpts...) {
 		caps = append(caps, capabilities.ProvideOrdered(
 			capabilities.UnaryClientInterceptorSpec,
 			provider.Name(),
 			provider,
 		))
 	}
+	for _, provider := range traffic.StreamClientInterceptorProviders(load) {
+		caps = append(caps, capabilities.ProvideOrdered(
+			capabilities.StreamClientInterceptorSpec,
+			provider.Name(),
+			provider,
+		))
+	}
+	for _, provider := range traffic.UnaryServerInterceptorProviders(m.serverGovernanceConfig) {
+		caps = append(caps, capabilities.ProvideOrdered(
+			capabilities.UnaryServerInterceptorSpec,
+			provider.Name(),
+			provider,
+		))
+	}
 	return caps
 }
 
@@ -212,6 +260,38 @@ func (m *polarisModule) governanceConfig(serviceName string) map[string]any {
 	return out
 }
 
+// callerIdentity returns the app name and yggdrasil.admin.application
+// namespace used as the default caller of outbound governance.
+func (m *polarisModule) callerIdentity() (string, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.appName, m.settings.Admin.Application.Namespace
+}
+
+// serverGovernanceConfig merges the governance defaults with the settings
+// for inbound requests.
+func (m *polarisModule) serverGovernanceConfig() map[string]any {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// MergeStringMap merges nested maps in place, so start from a copy to
+	// keep the shared defaults intact.
+	out := cloneConfigMap(m.settings.Polaris.Governance.Defaults)
+	xmap.MergeStringMap(out, m.settings.Polaris.Governance.Server)
+	xmap.CoverInterfaceMapToStringMap(out)
+	return out
+}
+
+func cloneConfigMap(in map[string]any) map[string]any {
+	out := make(map[string]any, len(in))
+	for key, value := range in {
+		if nested, ok := value.(map[string]any); ok {
+			value = cloneConfigMap(nested)
+		}
+		out[key] = value
+	}
+	return out
+}
+
 func decodeMap(input map[string]any, target any) error {
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
@@ -20,9 +20,11 @@ import (
 	"testing"
 
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/traffic"
 	"github.com/codesjoy/yggdrasil/v3/capabilities"
 	"github.com/codesjoy/yggdrasil/v3/config"
 	configchain "github.com/codesjoy/yggdrasil/v3/config/chain"
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
 )
 
 func TestModuleExposesV3Capabilities(t *testing.T) {
@@ -60,6 +62,7 @@ func TestModuleExposesV3Capabilities(t *testing.T) {
 		capabilities.BalancerProviderSpec.Name + "/polaris":                      false,
 		capabilities.UnaryClientInterceptorSpec.Name + "/polaris_ratelimit":      false,
 		capabilities.UnaryClientInterceptorSpec.Name + "/polaris_circuitbreaker": false,
+		capabilities.UnaryClientInterceptorSpec.Name + "/polaris_retry":          false,
 	}
 	for _, cap := range caps {
 		key := cap.Spec.Name + "/" + cap.Name
@@ -82,6 +85,64 @@ func TestModuleExposesV3Capabilities(t *testing.T) {
 	}
 }
 
+func TestModuleDefaultsCallerToAppIdentity(t *testing.T) {
+	mod := Module(WithAppName("library-client")).(*polarisModule)
+	view := config.NewView("yggdrasil", config.NewSnapshot(map[string]any{
+		"admin": map[string]any{
+			"application": map[string]any{"namespace": "prod"},
+		},
+		"polaris": map[string]any{
+			"governance": map[string]any{
+				"services": map[string]any{
+					"pinned": map[string]any{"caller_service": "explicit"},
+				},
+			},
+		},
+	}))
+	if err := mod.Init(context.Background(), view); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	load := traffic.WithCallerDefaults(mod.governanceConfig, mod.callerIdentity)
+	cfg := load("svc")
+	if cfg["caller_service"] != "library-client" || cfg["caller_namespace"] != "prod" {
+		t.Fatalf("governance config = %#v, want app identity as caller", cfg)
+	}
+	if cfg := load("pinned"); cfg["caller_service"] != "explicit" {
+		t.Fatalf("pinned caller_service = %#v, want explicit", cfg["caller_service"])
+	}
+}
+
+func TestModuleRunsConfiguredArgumentExtractor(t *testing.T) {
+	var extracted []string
+	mod := Module(WithArgumentExtractor("library", func(method string, _ any) map[string]string {
+		extracted = append(extracted, method)
+		return map[string]string{"user_id": "u-1"}
+	})).(*polarisModule)
+
+	var provider interceptor.UnaryClientInterceptorProvider
+	for _, cap := range mod.Capabilities() {
+		if cap.Spec.Name == capabilities.UnaryClientInterceptorSpec.Name &&
+			cap.Name == "polaris_routing_args" {
+			provider, _ = cap.Value.(interceptor.UnaryClientInterceptorProvider)
+		}
+	}
+	if provider == nil {
+		t.Fatal("polaris_routing_args interceptor provider not exposed")
+	}
+
+	invoker := func(context.Context, string, any, any) error { return nil }
+	for _, service := range []string{"library", "other"} {
+		if err := provider.New(service)(context.Background(), "/"+service+"/Get", nil, nil,
+			invoker); err != nil {
+			t.Fatalf("interceptor(%s) error = %v", service, err)
+		}
+	}
+	if len(extracted) != 1 || extracted[0] != "/library/Get" {
+		t.Fatalf("extracted methods = %v, want only /library/Get", extracted)
+	}
+}
+
 func TestModuleConfigSourceBuilderUsesBaseSDKConfig(t *testing.T) {
 	mod, ok := Module().(*polarisModule)
 	if !ok {
@@ -148,11 +209,13 @@ func TestModuleIdentityInvalidSourceAndGovernanceMerge(t *testing.T) {
 				Governance struct {
 					Defaults map[string]any            `mapstructure:"defaults"`
 					Services map[string]map[string]any `mapstructure:"services"`
+					Server   map[string]any            `mapstructure:"server"`
 				} `mapstructure:"governance"`
 			}{
 				Governance: struct {
 					Defaults map[string]any            `mapstructure:"defaults"`
 					Services map[string]map[string]any `mapstructure:"services"`
+					Server   map[string]any            `mapstructure:"server"`
 				}{
 					Defaults: map[string]any{
 						"namespace":  "default",
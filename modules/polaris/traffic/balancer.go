@@ -17,6 +17,8 @@ package traffic
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"math/rand/v2"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,15 +28,21 @@ import (
 	"github.com/polarismesh/polaris-go/pkg/model"
 	"google.golang.org/genproto/googleapis/rpc/code"
 
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/common"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
 	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
 	"github.com/codesjoy/yggdrasil/v3/rpc/metadata"
-	"github.com/codesjoy/yggdrasil/v3/rpc/status"
 	remote "github.com/codesjoy/yggdrasil/v3/transport"
 	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
 )
 
-const polarisBalancerName = "polaris"
+const (
+	polarisBalancerName = "polaris"
+
+	// defaultInstanceWeight is the Polaris default for instances that are ready
+	// but missing from the instances response.
+	defaultInstanceWeight = 100
+)
 
 var getBalancerAPIs = func(
 	serviceName string,
@@ -57,6 +65,8 @@ type polarisBalancer struct {
 	remoteByName      map[string]remote.Client
 	remoteByInstance  map[string]remote.Client
 	instancesResponse *model.InstancesResponse
+	readySince        map[remote.Client]time.Time
+	now               func() time.Time
 
 	governance governanceConfig
 	router     sdk.RouterAPI
@@ -65,6 +75,8 @@ type polarisBalancer struct {
 	limitErr   error
 	cb         sdk.CircuitBreakerAPI
 	cbErr      error
+	breakers   *breakerTracker
+	metrics    *governanceMetrics
 }
 
 // BalancerProvider returns the Polaris v3 client balancer provider.
@@ -87,12 +99,16 @@ func newPolarisBalancer(
 	cli balancer.Client,
 ) (balancer.Balancer, error) {
 	cfg := loadGovernanceConfig(load, serviceName)
+	if err := cfg.Routing.validate(); err != nil {
+		return nil, err
+	}
 	r, rErr, l, lErr, cb, cbErr := getBalancerAPIs(serviceName, cfg)
 	return &polarisBalancer{
 		serviceName:      serviceName,
 		cli:              cli,
 		remoteByName:     make(map[string]remote.Client),
 		remoteByInstance: make(map[string]remote.Client),
+		now:              time.Now,
 		governance:       cfg,
 		router:           r,
 		routerErr:        rErr,
@@ -100,6 +116,8 @@ func newPolarisBalancer(
 		limitErr:         lErr,
 		cb:               cb,
 		cbErr:            cbErr,
+		breakers:         breakerTrackerFor(serviceName),
+		metrics:          newGovernanceMetrics(),
 	}, nil
 }
 
@@ -134,11 +152,27 @@ func (b *polarisBalancer) UpdateState(state yresolver.State) {
 	nextByName := make(map[string]remote.Client, len(state.GetEndpoints()))
 	nextByInstance := make(map[string]remote.Client, len(state.GetEndpoints()))
 	for _, ep := range state.GetEndpoints() {
-		if cli, ok := b.remoteByName[ep.Name()]; ok {
-			nextByName[ep.Name()] = cli
-			if id, ok := ep.GetAttributes()["instance_id"].(string); ok && id != "" {
-				nextByInstance[id] = cli
-			}
+		if !b.governance.InstanceFilter.allowsEndpoint(ep) {
+			continue
+		}
+		name := ep.Name()
+		if _, dup := nextByName[name]; dup {
+			slog.Warn("polaris balancer ignored duplicate endpoint",
+				slog.String("service", b.serviceName),
+				slog.String("endpoint", name))
+			continue
+		}
+		key := endpointInstanceKey(ep)
+		if _, dup := nextByInstance[key]; dup {
+			slog.Warn("polaris balancer ignored endpoint with duplicate instance_id",
+				slog.String("service", b.serviceName),
+				slog.String("endpoint", name),
+				slog.String("instance_id", key))
+			continue
+		}
+		if cli, ok := b.remoteByName[name]; ok {
+			nextByName[name] = cli
+			nextByInstance[key] = cli
 			continue
 		}
 		cli, err := b.cli.NewRemoteClient(
@@ -151,10 +185,8 @@ func (b *polarisBalancer) UpdateState(state yresolver.State) {
 		if cli == nil {
 			continue
 		}
-		nextByName[ep.Name()] = cli
-		if id, ok := ep.GetAttributes()["instance_id"].(string); ok && id != "" {
-			nextByInstance[id] = cli
-		}
+		nextByName[name] = cli
+		nextByInstance[key] = cli
 		cli.Connect()
 	}
 
@@ -166,25 +198,44 @@ func (b *polarisBalancer) UpdateState(state yresolver.State) {
 	}
 	b.remoteByName = nextByName
 	b.remoteByInstance = nextByInstance
-	b.instancesResponse = resp
+	b.instancesResponse = b.governance.InstanceFilter.filterInstancesResponse(resp)
 	picker := b.buildPickerLocked()
 	b.mu.Unlock()
 
 	b.cli.UpdateState(balancer.State{Picker: picker})
 }
 
+// endpointInstanceKey returns the instance_id of ep, falling back to the
+// endpoint name for endpoints that carry no instance_id.
+func endpointInstanceKey(ep yresolver.Endpoint) string {
+	if id, ok := ep.GetAttributes()["instance_id"].(string); ok && id != "" {
+		return id
+	}
+	return ep.Name()
+}
+
 func (b *polarisBalancer) updateRemoteClientState(_ remote.ClientState) {
-	b.mu.RLock()
+	b.mu.Lock()
 	if b.remoteByName == nil {
-		b.mu.RUnlock()
+		b.mu.Unlock()
 		return
 	}
 	picker := b.buildPickerLocked()
-	b.mu.RUnlock()
+	b.mu.Unlock()
 	b.cli.UpdateState(balancer.State{Picker: picker})
 }
 
 func (b *polarisBalancer) buildPickerLocked() balancer.Picker {
+	now := b.now
+	if now == nil {
+		now = time.Now
+	}
+	var readySince map[remote.Client]time.Time
+	if b.governance.WarmUp.enabled() {
+		// Pickers share the map read-only; it is replaced, never mutated.
+		b.readySince = trackReadySince(b.readySince, b.remoteByName, now())
+		readySince = b.readySince
+	}
 	readyByInstance := make(map[string]remote.Client, len(b.remoteByInstance))
 	for id, cli := range b.remoteByInstance {
 		if cli.State() == remote.Ready {
@@ -197,11 +248,13 @@ func (b *polarisBalancer) buildPickerLocked() balancer.Picker {
 			readyAny = append(readyAny, cli)
 		}
 	}
-	return &polarisPicker{
+	p := &polarisPicker{
 		serviceName:       b.serviceName,
 		instancesResponse: b.instancesResponse,
 		readyByInstance:   readyByInstance,
 		readyAny:          readyAny,
+		readySince:        readySince,
+		now:               now,
 		governance:        b.governance,
 		router:            b.router,
 		routerErr:         b.routerErr,
@@ -209,7 +262,11 @@ func (b *polarisBalancer) buildPickerLocked() balancer.Picker {
 		limitErr:          b.limitErr,
 		cb:                b.cb,
 		cbErr:             b.cbErr,
+		breakers:          b.breakers,
+		metrics:           b.metrics,
 	}
+	p.weights = p.instanceWeights()
+	return p
 }
 
 type polarisPicker struct {
@@ -218,6 +275,9 @@ type polarisPicker struct {
 	instancesResponse *model.InstancesResponse
 	readyByInstance   map[string]remote.Client
 	readyAny          []remote.Client
+	readySince        map[remote.Client]time.Time
+	weights           map[remote.Client]int
+	now               func() time.Time
 	idx               int64
 
 	governance governanceConfig
@@ -227,6 +287,8 @@ type polarisPicker struct {
 	limitErr   error
 	cb         sdk.CircuitBreakerAPI
 	cbErr      error
+	breakers   *breakerTracker
+	metrics    *governanceMetrics
 }
 
 func (p *polarisPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
@@ -234,7 +296,8 @@ func (p *polarisPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
 		return nil, balancer.ErrNoAvailableInstance
 	}
 
-	if p.governance.RateLimit.Enable {
+	methodCfg := p.governance.forMethod(ri.Method)
+	if methodCfg.RateLimit.Enable {
 		if p.limitErr != nil {
 			return nil, p.limitErr
 		}
@@ -244,17 +307,14 @@ func (p *polarisPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
 	}
 
 	var methodResource *model.MethodResource
-	if p.governance.CircuitBreaker.Enable {
+	if methodCfg.CircuitBreaker.Enable {
 		if p.cbErr != nil {
 			return nil, p.cbErr
 		}
-		dstNS := p.governance.Namespace
-		if dstNS == "" {
-			dstNS = "default"
-		}
+		dstNS := p.governance.namespaceFor(ri.Ctx)
 		srcNS := p.governance.CallerNamespace
 		if srcNS == "" {
-			srcNS = dstNS
+			srcNS = p.governance.baseNamespace()
 		}
 		srcSvc := p.governance.CallerService
 		if srcSvc == "" {
@@ -271,7 +331,9 @@ func (p *polarisPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
 		if err != nil {
 			return nil, err
 		}
+		p.breakers.observeCheck(ri.Method, cr)
 		if cr != nil && !cr.Pass {
+			p.metrics.circuitOpen(ri.Ctx, p.serviceName, ri.Method)
 			msg := "polaris circuit breaker open"
 			if cr.RuleName != "" {
 				msg = msg + ": " + cr.RuleName
@@ -290,29 +352,29 @@ func (p *polarisPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
 		start:          time.Now(),
 		methodResource: methodResource,
 		cb:             p.cb,
+		method:         ri.Method,
+		breakers:       p.breakers,
 	}, nil
 }
 
 func (p *polarisPicker) checkRateLimit(ctx context.Context, method string) error {
-	dstNS := p.governance.Namespace
-	if dstNS == "" {
-		dstNS = "default"
-	}
+	rateLimit := p.governance.forMethod(method).RateLimit
+	dstNS := p.governance.namespaceFor(ctx)
 
 	qr := polaris.NewQuotaRequest()
 	qr.SetNamespace(dstNS)
 	qr.SetService(p.serviceName)
 	qr.SetMethod(method)
-	if p.governance.RateLimit.Token > 0 {
-		qr.SetToken(p.governance.RateLimit.Token)
+	if rateLimit.Token > 0 {
+		qr.SetToken(rateLimit.Token)
 	}
-	if p.governance.RateLimit.Timeout > 0 {
-		qr.SetTimeout(p.governance.RateLimit.Timeout)
+	if rateLimit.Timeout > 0 {
+		qr.SetTimeout(rateLimit.Timeout)
 	}
-	if p.governance.RateLimit.RetryCount > 0 {
-		qr.SetRetryCount(p.governance.RateLimit.RetryCount)
+	if rateLimit.RetryCount > 0 {
+		qr.SetRetryCount(rateLimit.RetryCount)
 	}
-	for k, v := range p.governance.RateLimit.Arguments {
+	for k, v := range rateLimit.Arguments {
 		qr.AddArgument(model.BuildCustomArgument(k, v))
 	}
 	if md, ok := metadata.FromOutContext(ctx); ok {
@@ -328,7 +390,7 @@ func (p *polarisPicker) checkRateLimit(ctx context.Context, method string) error
 	if err != nil {
 		return err
 	}
-	if p.governance.RateLimit.Release {
+	if rateLimit.Release {
 		defer future.Release()
 	}
 	resp := future.GetImmediately()
@@ -340,6 +402,7 @@ func (p *polarisPicker) checkRateLimit(ctx context.Context, method string) error
 		if msg == "" {
 			msg = "polaris rate limit exceeded"
 		}
+		p.metrics.rateLimited(ctx, p.serviceName, method)
 		return xerror.New(code.Code_RESOURCE_EXHAUSTED, msg)
 	}
 	if resp.WaitMs > 0 {
@@ -378,14 +441,13 @@ func (p *polarisPicker) pickRemote(ctx context.Context, method string) (remote.C
 			}
 			return nil, balancer.ErrNoAvailableInstance
 		}
-		one, err := p.processLoadBalance(filtered)
+		one, err := p.processLoadBalance(method, filtered)
 		if err != nil {
 			return nil, err
 		}
-		inst := one.GetInstance()
-		if inst != nil {
-			if cli, ok := p.readyByInstance[inst.GetId()]; ok {
-				return cli, nil
+		if inst := one.GetInstance(); inst != nil {
+			if cli, ok := p.readyClient(inst); ok {
+				return p.warmUpRouted(cli, filtered), nil
 			}
 		}
 	}
@@ -397,10 +459,90 @@ func (p *polarisPicker) randAllReady() (remote.Client, error) {
 	if len(p.readyAny) == 0 {
 		return nil, balancer.ErrNoAvailableInstance
 	}
+	if cli, ok := p.pickWarmUp(p.readyAny); ok {
+		return cli, nil
+	}
+	if cli, ok := p.pickWeighted(); ok {
+		return cli, nil
+	}
 	idx := int(atomic.AddInt64(&p.idx, 1)-1) % len(p.readyAny)
 	return p.readyAny[idx], nil
 }
 
+// instanceWeights maps each ready client to its Polaris instance weight. It
+// returns nil when weights are unknown or uniform, keeping round-robin.
+func (p *polarisPicker) instanceWeights() map[remote.Client]int {
+	if p.instancesResponse == nil || len(p.readyAny) < 2 {
+		return nil
+	}
+	weights := make(map[remote.Client]int, len(p.readyAny))
+	for _, inst := range p.instancesResponse.Instances {
+		if inst == nil {
+			continue
+		}
+		if cli, ok := p.readyClient(inst); ok {
+			weights[cli] = max(inst.GetWeight(), 0)
+		}
+	}
+	if len(weights) == 0 {
+		return nil
+	}
+	total, uniform := 0, true
+	for _, cli := range p.readyAny {
+		w, ok := weights[cli]
+		if !ok {
+			w = defaultInstanceWeight
+			weights[cli] = w
+		}
+		total += w
+		uniform = uniform && w == weights[p.readyAny[0]]
+	}
+	if uniform || total == 0 {
+		return nil
+	}
+	return weights
+}
+
+// pickWeighted selects a ready client in proportion to its instance weight.
+// It reports false when no weights apply.
+func (p *polarisPicker) pickWeighted() (remote.Client, bool) {
+	if p.weights == nil {
+		return nil, false
+	}
+	total := 0
+	for _, cli := range p.readyAny {
+		total += p.weights[cli]
+	}
+	r := rand.IntN(total) //nolint:gosec // load balancing does not need crypto randomness.
+	for _, cli := range p.readyAny {
+		w := p.weights[cli]
+		if r < w {
+			return cli, true
+		}
+		r -= w
+	}
+	return p.readyAny[len(p.readyAny)-1], true
+}
+
+// instanceWeight returns the relative weight of cli, 1 when weights are unset.
+func (p *polarisPicker) instanceWeight(cli remote.Client) float64 {
+	if p.weights == nil {
+		return 1
+	}
+	return float64(p.weights[cli])
+}
+
+// readyClient returns the ready client for inst, matching its instance id
+// first and then its protocol/address endpoint name.
+func (p *polarisPicker) readyClient(inst model.Instance) (remote.Client, bool) {
+	if cli, ok := p.readyByInstance[inst.GetId()]; ok {
+		return cli, true
+	}
+	name := inst.GetProtocol() + "/" + common.NetAddr(inst.GetHost(), inst.GetPort())
+	cli, ok := p.readyByInstance[name]
+	return cli, ok
+}
+
 func (p *polarisPicker) filterReadyInstances(
 	dst *model.InstancesResponse,
 ) *model.InstancesResponse {
@@ -412,7 +554,7 @@ func (p *polarisPicker) filterReadyInstances(
 		if inst == nil {
 			continue
 		}
-		if _, ok := p.readyByInstance[inst.GetId()]; ok {
+		if _, ok := p.readyClient(inst); ok {
 			instances = append(instances, inst)
 		}
 	}
@@ -426,18 +568,19 @@ func (p *polarisPicker) processRouters(
 	method string,
 	dst *model.InstancesResponse,
 ) (*model.InstancesResponse, error) {
-	dstNS := p.governance.Namespace
-	if dstNS == "" {
-		dstNS = "default"
-	}
 	srcNS := p.governance.CallerNamespace
 	if srcNS == "" {
-		srcNS = dstNS
+		srcNS = p.governance.baseNamespace()
 	}
 	srcSvc := p.governance.CallerService
 	if srcSvc == "" {
 		srcSvc = "unknown"
 	}
+	if dstNS, ok := p.governance.namespaceOverride(ctx); ok && dst != nil {
+		overridden := *dst
+		overridden.Namespace = dstNS
+		dst = &overridden
+	}
 
 	req := &polaris.ProcessRoutersRequest{ProcessRoutersRequest: model.ProcessRoutersRequest{
 		Routers:       append([]string{}, p.governance.Routing.Routers...),
@@ -456,6 +599,9 @@ func (p *polarisPicker) processRouters(
 			req.AddArguments(model.BuildCustomArgument(k, vs[0]))
 		}
 	}
+	for k, v := range routingArgumentsFromContext(ctx) {
+		req.AddArguments(model.BuildCustomArgument(k, v))
+	}
 	if p.governance.Routing.Timeout > 0 {
 		req.SetTimeout(p.governance.Routing.Timeout)
 	}
@@ -470,12 +616,13 @@ func (p *polarisPicker) processRouters(
 }
 
 func (p *polarisPicker) processLoadBalance(
+	method string,
 	dst model.ServiceInstances,
 ) (*model.OneInstanceResponse, error) {
 	req := &polaris.ProcessLoadBalanceRequest{
 		ProcessLoadBalanceRequest: model.ProcessLoadBalanceRequest{
 			DstInstances: dst,
-			LbPolicy:     p.governance.Routing.LbPolicy,
+			LbPolicy:     p.governance.Routing.lbPolicyFor(method),
 		},
 	}
 	return p.router.ProcessLoadBalance(req)
@@ -488,6 +635,8 @@ type polarisPickResult struct {
 
 	methodResource *model.MethodResource
 	cb             sdk.CircuitBreakerAPI
+	method         string
+	breakers       *breakerTracker
 }
 
 func (r *polarisPickResult) RemoteClient() remote.Client { return r.endpoint }
@@ -496,16 +645,5 @@ func (r *polarisPickResult) Report(err error) {
 	if r.methodResource == nil || r.cb == nil {
 		return
 	}
-	retStatus := model.RetSuccess
-	retCode := "0"
-	if err != nil {
-		retStatus = model.RetFail
-		retCode = status.FromError(err).Code().String()
-	}
-	_ = r.cb.Report(&model.ResourceStat{
-		Resource:  r.methodResource,
-		RetCode:   retCode,
-		Delay:     time.Since(r.start),
-		RetStatus: retStatus,
-	})
+	reportCircuitBreaker(r.cb, r.breakers, r.methodResource, r.start, err)
 }
@@ -220,6 +220,81 @@ func TestPolarisBalancerRoutingFiltersNonReadyInstancesBeforeCallingRouter(t *te
 	}
 }
 
+func TestPolarisBalancerRoutingFallsBackToEndpointNameWithoutInstanceID(t *testing.T) {
+	bc := &fakeBalancerClient{}
+	pb := newTestPolarisBalancer(bc, &fakeRouter{pickInstanceID: "ins-2"})
+
+	state := testResolverState().(yresolver.BaseState)
+	state.Endpoints[1] = yresolver.BaseEndpoint{
+		Address:  "127.0.0.1:9001",
+		Protocol: "grpc",
+	}
+	pb.UpdateState(state)
+
+	if _, ok := pb.remoteByInstance["grpc/127.0.0.1:9001"]; !ok {
+		t.Fatalf("remoteByInstance = %#v, want name-based key", pb.remoteByInstance)
+	}
+	for i := 0; i < 4; i++ {
+		pr, err := bc.lastPicker.Next(
+			balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/method"},
+		)
+		if err != nil {
+			t.Fatalf("picker Next err: %v", err)
+		}
+		if pr.RemoteClient().Protocol() != "grpc/127.0.0.1:9001" {
+			t.Fatalf("unexpected remote picked: %s", pr.RemoteClient().Protocol())
+		}
+	}
+}
+
+func TestPolarisBalancerIgnoresDuplicateInstanceID(t *testing.T) {
+	bc := &fakeBalancerClient{}
+	pb := newTestPolarisBalancer(bc, &fakeRouter{pickInstanceID: "ins-1"})
+
+	state := testResolverState().(yresolver.BaseState)
+	state.Endpoints[1] = yresolver.BaseEndpoint{
+		Address:    "127.0.0.1:9001",
+		Protocol:   "grpc",
+		Attributes: map[string]any{"instance_id": "ins-1"},
+	}
+	pb.UpdateState(state)
+
+	if len(pb.remoteByName) != 1 || len(pb.remoteByInstance) != 1 {
+		t.Fatalf("remote maps = %#v / %#v", pb.remoteByName, pb.remoteByInstance)
+	}
+	if cli := pb.remoteByInstance["ins-1"]; cli.Protocol() != "grpc/127.0.0.1:9000" {
+		t.Fatalf("ins-1 mapped to %s, want first endpoint", cli.Protocol())
+	}
+}
+
+func TestPolarisBalancerHonorsInstanceWeightsWithoutRouting(t *testing.T) {
+	bc := &fakeBalancerClient{}
+	pb := newTestPolarisBalancer(bc, &fakeRouter{})
+	pb.governance.Routing.Enable = false
+
+	state := testResolverState().(yresolver.BaseState)
+	resp := state.Attributes["polaris_instances_response"].(*model.InstancesResponse)
+	resp.Instances[0].(*fakeInstance).weight = 90
+	resp.Instances[1].(*fakeInstance).weight = 10
+	pb.UpdateState(state)
+
+	const picks = 10000
+	counts := map[string]int{}
+	for i := 0; i < picks; i++ {
+		pr, err := bc.lastPicker.Next(
+			balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/method"},
+		)
+		if err != nil {
+			t.Fatalf("picker Next err: %v", err)
+		}
+		counts[pr.RemoteClient().Protocol()]++
+	}
+	heavy := float64(counts["grpc/127.0.0.1:9000"]) / picks
+	if heavy < 0.87 || heavy > 0.93 {
+		t.Fatalf("weight-90 share = %.3f, want about 0.9 (counts %v)", heavy, counts)
+	}
+}
+
 func newTestPolarisBalancer(
 	cli balancer.Client,
 	router interface {
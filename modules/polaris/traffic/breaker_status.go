@@ -0,0 +1,175 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// CircuitBreakerState is the client-side view of a Polaris method breaker.
+type CircuitBreakerState string
+
+// Circuit breaker states reported by GetCircuitBreakerStatus.
+const (
+	CircuitBreakerClosed   CircuitBreakerState = "closed"
+	CircuitBreakerOpen     CircuitBreakerState = "open"
+	CircuitBreakerHalfOpen CircuitBreakerState = "half_open"
+)
+
+// CircuitBreakerStatus is the last breaker state observed for one method.
+//
+// The state is derived from the Polaris check and report results of real
+// calls rather than from an extra check, which would consume half-open probe
+// permits: a failed check opens it, a passing check after an open one makes
+// it half-open, and the reported outcome of that probe closes or re-opens it.
+type CircuitBreakerStatus struct {
+	Method    string
+	State     CircuitBreakerState
+	RuleName  string
+	UpdatedAt time.Time
+}
+
+// BalancerStats contains the circuit breaker statistics of one service.
+type BalancerStats struct {
+	Service         string
+	CircuitBreakers []CircuitBreakerStatus
+}
+
+// breakerTrackers holds the breaker states of every service, recorded by the
+// balancer and by the polaris_circuitbreaker client interceptors alike.
+var breakerTrackers = &breakerRegistry{trackers: map[string]*breakerTracker{}}
+
+type breakerRegistry struct {
+	mu       sync.Mutex
+	trackers map[string]*breakerTracker
+}
+
+// breakerTrackerFor returns the tracker of serviceName, creating it on first
+// use.
+func breakerTrackerFor(serviceName string) *breakerTracker {
+	r := breakerTrackers
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.trackers[serviceName]
+	if !ok {
+		t = newBreakerTracker()
+		r.trackers[serviceName] = t
+	}
+	return t
+}
+
+func lookupBreakerTracker(serviceName string) *breakerTracker {
+	r := breakerTrackers
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.trackers[serviceName]
+}
+
+// breakerTracker records the breaker states of one service.
+type breakerTracker struct {
+	mu       sync.Mutex
+	statuses map[string]CircuitBreakerStatus
+	now      func() time.Time
+}
+
+func newBreakerTracker() *breakerTracker {
+	return &breakerTracker{statuses: map[string]CircuitBreakerStatus{}, now: time.Now}
+}
+
+// observeCheck records the result of a breaker check for method.
+func (t *breakerTracker) observeCheck(method string, cr *model.CheckResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, seen := t.statuses[method]
+	next := CircuitBreakerStatus{Method: method, State: CircuitBreakerClosed, UpdatedAt: t.now()}
+	switch {
+	case cr != nil && !cr.Pass:
+		next.State = CircuitBreakerOpen
+		next.RuleName = cr.RuleName
+	case seen && (prev.State == CircuitBreakerOpen || prev.State == CircuitBreakerHalfOpen):
+		next.State = CircuitBreakerHalfOpen
+		next.RuleName = prev.RuleName
+	}
+	t.statuses[method] = next
+}
+
+// observeReport records the outcome of a call admitted by the breaker.
+func (t *breakerTracker) observeReport(method string, callErr error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.statuses[method]
+	if !ok || prev.State != CircuitBreakerHalfOpen {
+		return
+	}
+	if callErr == nil {
+		prev.State = CircuitBreakerClosed
+		prev.RuleName = ""
+	} else {
+		prev.State = CircuitBreakerOpen
+	}
+	prev.UpdatedAt = t.now()
+	t.statuses[method] = prev
+}
+
+func (t *breakerTracker) status(method string) (CircuitBreakerStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.statuses[method]
+	return st, ok
+}
+
+func (t *breakerTracker) snapshot() []CircuitBreakerStatus {
+	t.mu.Lock()
+	out := make([]CircuitBreakerStatus, 0, len(t.statuses))
+	for _, st := range t.statuses {
+		out = append(out, st)
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
+
+// GetCircuitBreakerStatus returns the last observed breaker status for a
+// method of serviceName, from the balancer or the polaris_circuitbreaker
+// client interceptors. It reports false when no call to method has been
+// checked yet.
+func GetCircuitBreakerStatus(serviceName, method string) (CircuitBreakerStatus, bool) {
+	t := lookupBreakerTracker(serviceName)
+	if t == nil {
+		return CircuitBreakerStatus{}, false
+	}
+	return t.status(method)
+}
+
+// GetStats returns the circuit breaker statistics of serviceName.
+func GetStats(serviceName string) BalancerStats {
+	stats := BalancerStats{Service: serviceName, CircuitBreakers: []CircuitBreakerStatus{}}
+	if t := lookupBreakerTracker(serviceName); t != nil {
+		stats.CircuitBreakers = t.snapshot()
+	}
+	return stats
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	remote "github.com/codesjoy/yggdrasil/v3/transport"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+)
+
+func TestPolarisBalancerCircuitBreakerStatus(t *testing.T) {
+	cb := &trafficCircuitBreakerAPI{
+		checkResp: &model.CheckResult{Pass: false, RuleName: "rule-a"},
+	}
+	bc := &trafficBalancerClient{}
+	b := &polarisBalancer{
+		cli:         bc,
+		serviceName: "breaker-status-svc",
+		remoteByName: map[string]remote.Client{
+			"a": &trafficRemoteClient{name: "a", state: remote.Ready},
+		},
+		remoteByInstance: map[string]remote.Client{},
+		governance: governanceConfig{
+			CircuitBreaker: circuitBreakerConfig{Enable: true},
+		},
+		cb:       cb,
+		breakers: breakerTrackerFor("breaker-status-svc"),
+	}
+	b.updateRemoteClientState(remote.ClientState{})
+	picker := bc.updates[len(bc.updates)-1].Picker
+	info := balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/Method"}
+	state := func() CircuitBreakerStatus {
+		st, _ := GetCircuitBreakerStatus("breaker-status-svc", "/svc/Method")
+		return st
+	}
+
+	if _, ok := GetCircuitBreakerStatus("breaker-status-svc", "/svc/Method"); ok {
+		t.Fatal("GetCircuitBreakerStatus() reported a status before any call")
+	}
+	if _, err := picker.Next(info); err == nil {
+		t.Fatal("Next() expected open circuit error")
+	}
+	st, ok := GetCircuitBreakerStatus("breaker-status-svc", "/svc/Method")
+	if !ok || st.State != CircuitBreakerOpen || st.RuleName != "rule-a" {
+		t.Fatalf("status = %+v, %v, want open rule-a", st, ok)
+	}
+
+	cb.checkResp = &model.CheckResult{Pass: true}
+	res, err := picker.Next(info)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if st := state(); st.State != CircuitBreakerHalfOpen {
+		t.Fatalf("state after probe admitted = %q, want half_open", st.State)
+	}
+	res.Report(errors.New("still failing"))
+	if st := state(); st.State != CircuitBreakerOpen {
+		t.Fatalf("state after failed probe = %q, want open", st.State)
+	}
+
+	res, err = picker.Next(info)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	res.Report(nil)
+	if st := state(); st.State != CircuitBreakerClosed {
+		t.Fatalf("state after successful probe = %q, want closed", st.State)
+	}
+
+	stats := GetStats("breaker-status-svc")
+	if stats.Service != "breaker-status-svc" || len(stats.CircuitBreakers) != 1 ||
+		stats.CircuitBreakers[0].Method != "/svc/Method" {
+		t.Fatalf("GetStats() = %+v", stats)
+	}
+}
+
+func TestPolarisCircuitBreakerInterceptorRecordsStatus(t *testing.T) {
+	restoreTrafficGlobals(t)
+	api := &trafficCircuitBreakerAPI{
+		checkResp: &model.CheckResult{Pass: false, RuleName: "rule-b"},
+	}
+	getCircuitBreakerAPI = func(string, governanceConfig) (sdk.CircuitBreakerAPI, error) {
+		return api, nil
+	}
+	unary := buildPolarisCircuitBreakerUnary(func(string) map[string]any {
+		return map[string]any{"circuit_breaker": map[string]any{"enable": true}}
+	}, "breaker-interceptor-svc")
+	invoke := func(err error) error {
+		return unary(context.Background(), "/svc/Method", nil, nil,
+			func(context.Context, string, any, any) error { return err })
+	}
+
+	if err := invoke(nil); err == nil {
+		t.Fatal("unary() error = nil, want open breaker rejection")
+	}
+	st, ok := GetCircuitBreakerStatus("breaker-interceptor-svc", "/svc/Method")
+	if !ok || st.State != CircuitBreakerOpen || st.RuleName != "rule-b" {
+		t.Fatalf("status = %+v, %v, want open rule-b", st, ok)
+	}
+
+	api.checkResp = &model.CheckResult{Pass: true}
+	if err := invoke(nil); err != nil {
+		t.Fatalf("unary() error = %v", err)
+	}
+	st, _ = GetCircuitBreakerStatus("breaker-interceptor-svc", "/svc/Method")
+	if st.State != CircuitBreakerClosed {
+		t.Fatalf("state after successful probe = %q, want closed", st.State)
+	}
+	if stats := GetStats("other-svc"); len(stats.CircuitBreakers) != 0 {
+		t.Fatalf("GetStats(other-svc) = %+v, want no breakers", stats)
+	}
+}
@@ -16,16 +16,21 @@ package traffic
 
 import (
 	"context"
+	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/codesjoy/pkg/basic/xerror"
 	"github.com/mitchellh/mapstructure"
 	polaris "github.com/polarismesh/polaris-go"
+	"github.com/polarismesh/polaris-go/pkg/config"
 	"github.com/polarismesh/polaris-go/pkg/model"
 	"google.golang.org/genproto/googleapis/rpc/code"
 
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
 	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+	"github.com/codesjoy/yggdrasil/v3/rpc/metadata"
 	"github.com/codesjoy/yggdrasil/v3/rpc/status"
 )
 
@@ -45,18 +50,64 @@ var (
 // ConfigLoader loads merged Polaris traffic governance config for a service.
 type ConfigLoader func(serviceName string) map[string]any
 
+// WithCallerDefaults wraps load so that configs without caller_service or
+// caller_namespace take them from caller, typically the local app identity.
+func WithCallerDefaults(load ConfigLoader, caller func() (service, namespace string)) ConfigLoader {
+	return func(serviceName string) map[string]any {
+		var cfg map[string]any
+		if load != nil {
+			cfg = load(serviceName)
+		}
+		service, namespace := caller()
+		for key, value := range map[string]string{
+			"caller_service":   service,
+			"caller_namespace": namespace,
+		} {
+			if current, _ := cfg[key].(string); current != "" || value == "" {
+				continue
+			}
+			if cfg == nil {
+				cfg = map[string]any{}
+			}
+			cfg[key] = value
+		}
+		return cfg
+	}
+}
+
 type governanceConfig struct {
 	Addresses       []string `mapstructure:"addresses"`
 	SDK             string   `mapstructure:"sdk"`
 	Namespace       string   `mapstructure:"namespace"`
 	CallerService   string   `mapstructure:"caller_service"`
 	CallerNamespace string   `mapstructure:"caller_namespace"`
+	// NamespaceMetadataKey names an outgoing metadata key whose value, when
+	// present, overrides Namespace for that call.
+	NamespaceMetadataKey string `mapstructure:"namespace_metadata_key"`
+	// Service names the local service for server-side governance.
+	Service string `mapstructure:"service"`
 
 	RateLimit rateLimitConfig `mapstructure:"rate_limit"`
 
 	CircuitBreaker circuitBreakerConfig `mapstructure:"circuit_breaker"`
 
 	Routing routingConfig `mapstructure:"routing"`
+
+	InstanceFilter instanceFilterConfig `mapstructure:"instance_filter"`
+
+	WarmUp warmUpConfig `mapstructure:"warm_up"`
+
+	Retry retryConfig `mapstructure:"retry"`
+
+	// Methods holds per-method rate_limit and circuit_breaker settings merged
+	// over the service defaults, keyed as configured under "methods".
+	Methods map[string]methodGovernanceConfig `mapstructure:"-"`
+}
+
+// methodGovernanceConfig is the governance applied to one RPC method.
+type methodGovernanceConfig struct {
+	RateLimit      rateLimitConfig      `mapstructure:"rate_limit"`
+	CircuitBreaker circuitBreakerConfig `mapstructure:"circuit_breaker"`
 }
 
 type rateLimitConfig struct {
@@ -66,6 +117,9 @@ type rateLimitConfig struct {
 	RetryCount int               `mapstructure:"retry_count"`
 	Arguments  map[string]string `mapstructure:"arguments"`
 	Release    bool              `mapstructure:"release"`
+	// MetadataArguments lists the inbound metadata keys the server rate
+	// limiter passes to Polaris as custom arguments.
+	MetadataArguments []string `mapstructure:"metadata_arguments"`
 }
 
 type circuitBreakerConfig struct {
@@ -79,9 +133,63 @@ type routingConfig struct {
 	Timeout    time.Duration     `mapstructure:"timeout"`
 	RetryCount int               `mapstructure:"retry_count"`
 	LbPolicy   string            `mapstructure:"lb_policy"`
+	LbPolicies map[string]string `mapstructure:"lb_policies"`
 	Arguments  map[string]string `mapstructure:"arguments"`
 }
 
+// knownLbPolicies lists the load balancers built into the Polaris SDK; an
+// empty policy leaves the choice to the SDK configuration.
+var knownLbPolicies = map[string]struct{}{
+	"":                                 {},
+	config.DefaultLoadBalancerWR:       {},
+	config.DefaultLoadBalancerRingHash: {},
+	config.DefaultLoadBalancerMaglev:   {},
+	config.DefaultLoadBalancerL5CST:    {},
+	config.DefaultLoadBalancerHash:     {},
+}
+
+// validate reports lb_policy values no Polaris load balancer accepts and
+// lb_policies keys that are not valid patterns.
+func (c routingConfig) validate() error {
+	if _, ok := knownLbPolicies[c.LbPolicy]; !ok {
+		return fmt.Errorf("polaris routing: unknown lb_policy %q", c.LbPolicy)
+	}
+	for pattern, policy := range c.LbPolicies {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("polaris routing: invalid lb_policies pattern %q: %w", pattern, err)
+		}
+		if _, ok := knownLbPolicies[policy]; !ok {
+			return fmt.Errorf("polaris routing: unknown lb_policy %q for %q", policy, pattern)
+		}
+	}
+	return nil
+}
+
+// lbPolicyFor returns the load-balance policy for method. Exact keys win over
+// the bare method name, which wins over patterns; among patterns the longest
+// one matches first. Methods without an entry use LbPolicy.
+func (c routingConfig) lbPolicyFor(method string) string {
+	if policy, ok := c.LbPolicies[method]; ok {
+		return policy
+	}
+	if idx := strings.LastIndex(method, "/"); idx >= 0 {
+		if policy, ok := c.LbPolicies[method[idx+1:]]; ok {
+			return policy
+		}
+	}
+	best, policy := "", c.LbPolicy
+	for pattern, candidate := range c.LbPolicies {
+		if ok, _ := path.Match(pattern, method); !ok {
+			continue
+		}
+		if best == "" || len(pattern) > len(best) ||
+			(len(pattern) == len(best) && pattern < best) {
+			best, policy = pattern, candidate
+		}
+	}
+	return policy
+}
+
 func loadGovernanceConfig(loader ConfigLoader, serviceName string) governanceConfig {
 	if loader == nil {
 		return governanceConfig{}
@@ -91,23 +199,141 @@ func loadGovernanceConfig(loader ConfigLoader, serviceName string) governanceCon
 
 func decodeGovernanceConfig(m map[string]any) governanceConfig {
 	var out governanceConfig
+	decodeGovernanceMap(m, &out)
+
+	methods, _ := m["methods"].(map[string]any)
+	for method, raw := range methods {
+		override, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		merged := mergeGovernanceMaps(map[string]any{
+			"rate_limit":      m["rate_limit"],
+			"circuit_breaker": m["circuit_breaker"],
+		}, override)
+		var methodCfg methodGovernanceConfig
+		decodeGovernanceMap(merged, &methodCfg)
+		if out.Methods == nil {
+			out.Methods = make(map[string]methodGovernanceConfig, len(methods))
+		}
+		out.Methods[method] = methodCfg
+	}
+	return out
+}
+
+func decodeGovernanceMap(m map[string]any, target any) {
 	decoder, _ := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			mapstructure.TextUnmarshallerHookFunc(),
 			mapstructure.StringToTimeDurationHookFunc(),
 		),
-		Result: &out,
+		Result: target,
 	})
 	if decoder != nil {
 		_ = decoder.Decode(m)
 	}
+}
+
+// mergeGovernanceMaps returns a copy of base with override merged over it;
+// nested maps are merged key by key and nil base values are dropped.
+func mergeGovernanceMaps(base, override map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		if value != nil {
+			out[key] = value
+		}
+	}
+	for key, value := range override {
+		baseMap, baseOK := out[key].(map[string]any)
+		overrideMap, overrideOK := value.(map[string]any)
+		if baseOK && overrideOK {
+			out[key] = mergeGovernanceMaps(baseMap, overrideMap)
+			continue
+		}
+		out[key] = value
+	}
 	return out
 }
 
+// forMethod returns the governance for method. Overrides are looked up by
+// the full method name ("/pkg.Service/Method") and then by its last path
+// segment; methods without an override use the service defaults.
+func (c governanceConfig) forMethod(method string) methodGovernanceConfig {
+	if methodCfg, ok := c.Methods[method]; ok {
+		return methodCfg
+	}
+	if idx := strings.LastIndex(method, "/"); idx >= 0 {
+		if methodCfg, ok := c.Methods[method[idx+1:]]; ok {
+			return methodCfg
+		}
+	}
+	return methodGovernanceConfig{RateLimit: c.RateLimit, CircuitBreaker: c.CircuitBreaker}
+}
+
+// rateLimitEnabled reports whether any method may be rate limited.
+func (c governanceConfig) rateLimitEnabled() bool {
+	if c.RateLimit.Enable {
+		return true
+	}
+	for _, methodCfg := range c.Methods {
+		if methodCfg.RateLimit.Enable {
+			return true
+		}
+	}
+	return false
+}
+
+// circuitBreakerEnabled reports whether any method may be circuit broken.
+func (c governanceConfig) circuitBreakerEnabled() bool {
+	if c.CircuitBreaker.Enable {
+		return true
+	}
+	for _, methodCfg := range c.Methods {
+		if methodCfg.CircuitBreaker.Enable {
+			return true
+		}
+	}
+	return false
+}
+
+// baseNamespace returns the configured destination namespace.
+func (c governanceConfig) baseNamespace() string {
+	if c.Namespace == "" {
+		return "default"
+	}
+	return c.Namespace
+}
+
+// namespaceOverride returns the NamespaceMetadataKey value in the outgoing
+// metadata of ctx, if any.
+func (c governanceConfig) namespaceOverride(ctx context.Context) (string, bool) {
+	if c.NamespaceMetadataKey == "" || ctx == nil {
+		return "", false
+	}
+	md, ok := metadata.FromOutContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if vs := md.Get(c.NamespaceMetadataKey); len(vs) > 0 && vs[0] != "" {
+		return vs[0], true
+	}
+	return "", false
+}
+
+// namespaceFor returns the destination namespace for a call.
+func (c governanceConfig) namespaceFor(ctx context.Context) string {
+	if ns, ok := c.namespaceOverride(ctx); ok {
+		return ns
+	}
+	return c.baseNamespace()
+}
+
 // UnaryClientInterceptorProviders returns Polaris governance interceptor providers.
 func UnaryClientInterceptorProviders(
 	load ConfigLoader,
+	opts ...Option,
 ) []interceptor.UnaryClientInterceptorProvider {
+	o := newOptions(opts)
 	return []interceptor.UnaryClientInterceptorProvider{
 		interceptor.NewUnaryClientInterceptorProvider(
 			"polaris_ratelimit",
@@ -121,6 +347,18 @@ func UnaryClientInterceptorProviders(
 				return buildPolarisCircuitBreakerUnary(load, serviceName)
 			},
 		),
+		interceptor.NewUnaryClientInterceptorProvider(
+			"polaris_retry",
+			func(serviceName string) interceptor.UnaryClientInterceptor {
+				return buildPolarisRetryUnary(load, serviceName)
+			},
+		),
+		interceptor.NewUnaryClientInterceptorProvider(
+			"polaris_routing_args",
+			func(serviceName string) interceptor.UnaryClientInterceptor {
+				return buildPolarisRoutingArgsUnary(o.argumentExtractorFor(serviceName))
+			},
+		),
 	}
 }
 
@@ -129,74 +367,32 @@ func buildPolarisRateLimitUnary(
 	serviceName string,
 ) interceptor.UnaryClientInterceptor {
 	cfg := loadGovernanceConfig(load, serviceName)
-	if !cfg.RateLimit.Enable {
+	if !cfg.rateLimitEnabled() {
 		return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
 			return invoker(ctx, method, req, reply)
 		}
 	}
 
-	namespace := cfg.Namespace
-	if namespace == "" {
-		namespace = "default"
-	}
 	api, initErr := getRateLimitAPI(serviceName, cfg)
+	metrics := newGovernanceMetrics()
 
 	return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+		rateLimit := cfg.forMethod(method).RateLimit
+		if !rateLimit.Enable {
+			return invoker(ctx, method, req, reply)
+		}
 		if initErr != nil {
 			return initErr
 		}
 
-		qr := polaris.NewQuotaRequest()
-		qr.SetNamespace(namespace)
-		qr.SetService(serviceName)
-		qr.SetMethod(method)
-		if cfg.RateLimit.Token > 0 {
-			qr.SetToken(cfg.RateLimit.Token)
-		}
-		if cfg.RateLimit.Timeout > 0 {
-			qr.SetTimeout(cfg.RateLimit.Timeout)
-		}
-		if cfg.RateLimit.RetryCount > 0 {
-			qr.SetRetryCount(cfg.RateLimit.RetryCount)
-		}
-		for k, v := range cfg.RateLimit.Arguments {
-			qr.AddArgument(model.BuildCustomArgument(k, v))
-		}
-
-		future, err := api.GetQuota(qr)
+		namespace := cfg.namespaceFor(ctx)
+		release, err := acquireQuota(
+			ctx, api, metrics, namespace, serviceName, method, rateLimit, nil,
+		)
 		if err != nil {
 			return err
 		}
-		if cfg.RateLimit.Release {
-			defer future.Release()
-		}
-		resp := future.GetImmediately()
-		if resp == nil {
-			return xerror.New(code.Code_UNKNOWN, "polaris rate limit: empty response")
-		}
-		if resp.Code != model.QuotaResultOk {
-			msg := resp.Info
-			if msg == "" {
-				msg = "polaris rate limit exceeded"
-			}
-			return xerror.New(code.Code_RESOURCE_EXHAUSTED, msg)
-		}
-		if resp.WaitMs > 0 {
-			t := time.NewTimer(time.Duration(resp.WaitMs) * time.Millisecond)
-			defer t.Stop()
-			select {
-			case <-ctx.Done():
-				switch ctx.Err() {
-				case context.DeadlineExceeded:
-					return xerror.Wrap(ctx.Err(), code.Code_DEADLINE_EXCEEDED, "")
-				case context.Canceled:
-					return xerror.Wrap(ctx.Err(), code.Code_CANCELLED, "")
-				default:
-					return xerror.Wrap(ctx.Err(), code.Code_UNKNOWN, "")
-				}
-			case <-t.C:
-			}
-		}
+		defer release()
 		return invoker(ctx, method, req, reply)
 	}
 }
@@ -206,19 +402,15 @@ func buildPolarisCircuitBreakerUnary(
 	serviceName string,
 ) interceptor.UnaryClientInterceptor {
 	cfg := loadGovernanceConfig(load, serviceName)
-	if !cfg.CircuitBreaker.Enable {
+	if !cfg.circuitBreakerEnabled() {
 		return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
 			return invoker(ctx, method, req, reply)
 		}
 	}
 
-	namespace := cfg.Namespace
-	if namespace == "" {
-		namespace = "default"
-	}
 	callerNamespace := cfg.CallerNamespace
 	if callerNamespace == "" {
-		callerNamespace = namespace
+		callerNamespace = cfg.baseNamespace()
 	}
 	callerService := cfg.CallerService
 	if callerService == "" {
@@ -226,44 +418,156 @@ func buildPolarisCircuitBreakerUnary(
 	}
 
 	api, initErr := getCircuitBreakerAPI(serviceName, cfg)
-	dst := &model.ServiceKey{Namespace: namespace, Service: serviceName}
 	src := &model.ServiceKey{Namespace: callerNamespace, Service: callerService}
+	metrics := newGovernanceMetrics()
+	breakers := breakerTrackerFor(serviceName)
 
 	return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+		if !cfg.forMethod(method).CircuitBreaker.Enable {
+			return invoker(ctx, method, req, reply)
+		}
 		if initErr != nil {
 			return initErr
 		}
 
-		res, err := model.NewMethodResource(dst, src, method)
+		dst := &model.ServiceKey{Namespace: cfg.namespaceFor(ctx), Service: serviceName}
+		res, err := checkCircuitBreaker(ctx, api, metrics, breakers, dst, src, method)
 		if err != nil {
 			return err
 		}
-		cr, err := api.Check(res)
-		if err != nil {
-			return err
+
+		start := time.Now()
+		invokeErr := invoker(ctx, method, req, reply)
+		reportCircuitBreaker(api, breakers, res, start, invokeErr)
+		return invokeErr
+	}
+}
+
+// acquireQuota requests a Polaris quota for method and waits out any delay the
+// quota server asks for. args are passed as custom arguments after the
+// configured ones. The returned release func must be called once the guarded
+// call has finished.
+func acquireQuota(
+	ctx context.Context,
+	api sdk.LimitAPI,
+	metrics *governanceMetrics,
+	namespace, serviceName, method string,
+	rateLimit rateLimitConfig,
+	args map[string]string,
+) (func(), error) {
+	qr := polaris.NewQuotaRequest()
+	qr.SetNamespace(namespace)
+	qr.SetService(serviceName)
+	qr.SetMethod(method)
+	if rateLimit.Token > 0 {
+		qr.SetToken(rateLimit.Token)
+	}
+	if rateLimit.Timeout > 0 {
+		qr.SetTimeout(rateLimit.Timeout)
+	}
+	if rateLimit.RetryCount > 0 {
+		qr.SetRetryCount(rateLimit.RetryCount)
+	}
+	for k, v := range rateLimit.Arguments {
+		qr.AddArgument(model.BuildCustomArgument(k, v))
+	}
+	for k, v := range args {
+		qr.AddArgument(model.BuildCustomArgument(k, v))
+	}
+
+	future, err := api.GetQuota(qr)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {}
+	if rateLimit.Release {
+		release = future.Release
+	}
+	resp := future.GetImmediately()
+	if resp == nil {
+		release()
+		return nil, xerror.New(code.Code_UNKNOWN, "polaris rate limit: empty response")
+	}
+	if resp.Code != model.QuotaResultOk {
+		release()
+		msg := resp.Info
+		if msg == "" {
+			msg = "polaris rate limit exceeded"
 		}
-		if cr != nil && !cr.Pass {
-			msg := "polaris circuit breaker open"
-			if cr.RuleName != "" {
-				msg = msg + ": " + cr.RuleName
+		metrics.rateLimited(ctx, serviceName, method)
+		return nil, xerror.New(code.Code_RESOURCE_EXHAUSTED, msg)
+	}
+	if resp.WaitMs > 0 {
+		t := time.NewTimer(time.Duration(resp.WaitMs) * time.Millisecond)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			release()
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, xerror.Wrap(ctx.Err(), code.Code_DEADLINE_EXCEEDED, "")
+			case context.Canceled:
+				return nil, xerror.Wrap(ctx.Err(), code.Code_CANCELLED, "")
+			default:
+				return nil, xerror.Wrap(ctx.Err(), code.Code_UNKNOWN, "")
 			}
-			return xerror.New(code.Code_UNAVAILABLE, msg)
+		case <-t.C:
 		}
+	}
+	return release, nil
+}
 
-		start := time.Now()
-		invokeErr := invoker(ctx, method, req, reply)
-		retStatus := model.RetSuccess
-		retCode := "0"
-		if invokeErr != nil {
-			retStatus = model.RetFail
-			retCode = status.FromError(invokeErr).Code().String()
+// checkCircuitBreaker returns the method resource to report against, or an
+// UNAVAILABLE error when the breaker for method is open. The result is
+// recorded in breakers.
+func checkCircuitBreaker(
+	ctx context.Context,
+	api sdk.CircuitBreakerAPI,
+	metrics *governanceMetrics,
+	breakers *breakerTracker,
+	dst, src *model.ServiceKey,
+	method string,
+) (*model.MethodResource, error) {
+	res, err := model.NewMethodResource(dst, src, method)
+	if err != nil {
+		return nil, err
+	}
+	cr, err := api.Check(res)
+	if err != nil {
+		return nil, err
+	}
+	breakers.observeCheck(method, cr)
+	if cr != nil && !cr.Pass {
+		metrics.circuitOpen(ctx, dst.Service, method)
+		msg := "polaris circuit breaker open"
+		if cr.RuleName != "" {
+			msg = msg + ": " + cr.RuleName
 		}
-		_ = api.Report(&model.ResourceStat{
-			Resource:  res,
-			RetCode:   retCode,
-			Delay:     time.Since(start),
-			RetStatus: retStatus,
-		})
-		return invokeErr
+		return nil, xerror.New(code.Code_UNAVAILABLE, msg)
 	}
+	return res, nil
+}
+
+// reportCircuitBreaker reports the outcome of a call started at start and
+// records it in breakers.
+func reportCircuitBreaker(
+	api sdk.CircuitBreakerAPI,
+	breakers *breakerTracker,
+	res *model.MethodResource,
+	start time.Time,
+	callErr error,
+) {
+	retStatus := model.RetSuccess
+	retCode := "0"
+	if callErr != nil {
+		retStatus = model.RetFail
+		retCode = status.FromError(callErr).Code().String()
+	}
+	_ = api.Report(&model.ResourceStat{
+		Resource:  res,
+		RetCode:   retCode,
+		Delay:     time.Since(start),
+		RetStatus: retStatus,
+	})
+	breakers.observeReport(res.Method, callErr)
 }
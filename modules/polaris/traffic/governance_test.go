@@ -15,8 +15,16 @@
 package traffic
 
 import (
+	"context"
 	"testing"
 	"time"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	"github.com/codesjoy/yggdrasil/v3/rpc/metadata"
+	remote "github.com/codesjoy/yggdrasil/v3/transport"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
 )
 
 func TestDecodeGovernanceConfigUsesSnakeCase(t *testing.T) {
@@ -51,3 +59,198 @@ func TestDecodeGovernanceConfigUsesSnakeCase(t *testing.T) {
 		t.Fatalf("routing config = %#v", cfg.Routing)
 	}
 }
+
+func TestDecodeGovernanceConfigMergesMethodOverrides(t *testing.T) {
+	cfg := decodeGovernanceConfig(map[string]any{
+		"rate_limit": map[string]any{
+			"enable":    true,
+			"token":     1,
+			"timeout":   "100ms",
+			"arguments": map[string]any{"tenant": "gold"},
+		},
+		"circuit_breaker": map[string]any{"enable": false},
+		"methods": map[string]any{
+			"ListBooks": map[string]any{
+				"rate_limit":      map[string]any{"token": 5},
+				"circuit_breaker": map[string]any{"enable": true},
+			},
+		},
+	})
+
+	listBooks := cfg.forMethod("/library.v1.LibraryService/ListBooks")
+	if listBooks.RateLimit.Token != 5 || !listBooks.RateLimit.Enable ||
+		listBooks.RateLimit.Timeout != 100*time.Millisecond ||
+		listBooks.RateLimit.Arguments["tenant"] != "gold" {
+		t.Fatalf("ListBooks rate_limit = %#v, want token override", listBooks.RateLimit)
+	}
+	if !listBooks.CircuitBreaker.Enable {
+		t.Fatalf("ListBooks circuit_breaker = %#v, want enabled", listBooks.CircuitBreaker)
+	}
+
+	getBook := cfg.forMethod("/library.v1.LibraryService/GetBook")
+	if getBook.RateLimit.Token != 1 || getBook.CircuitBreaker.Enable {
+		t.Fatalf("GetBook governance = %#v, want service defaults", getBook)
+	}
+	if !cfg.circuitBreakerEnabled() {
+		t.Fatal("circuitBreakerEnabled() = false, want true from ListBooks override")
+	}
+}
+
+func TestRateLimitUsesNamespaceFromMetadata(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}
+	api := &trafficLimitAPI{future: future}
+	getRateLimitAPI = func(string, governanceConfig) (sdk.LimitAPI, error) { return api, nil }
+
+	unary := buildPolarisRateLimitUnary(func(string) map[string]any {
+		return map[string]any{
+			"namespace":              "base",
+			"namespace_metadata_key": "x-polaris-namespace",
+			"rate_limit":             map[string]any{"enable": true},
+		}
+	}, "svc")
+	invoke := func(context.Context, string, any, any) error { return nil }
+
+	for _, ns := range []string{"tenant-a", "tenant-b"} {
+		ctx := metadata.WithOutContext(
+			context.Background(),
+			metadata.Pairs("x-polaris-namespace", ns),
+		)
+		if err := unary(ctx, "/svc/method", nil, nil, invoke); err != nil {
+			t.Fatalf("unary(%s) error = %v", ns, err)
+		}
+	}
+	if err := unary(context.Background(), "/svc/method", nil, nil, invoke); err != nil {
+		t.Fatalf("unary() error = %v", err)
+	}
+
+	want := []string{"tenant-a", "tenant-b", "base"}
+	if len(api.reqs) != len(want) {
+		t.Fatalf("quota requests = %d, want %d", len(api.reqs), len(want))
+	}
+	for i, ns := range want {
+		if got := api.reqs[i].(*model.QuotaRequestImpl).GetNamespace(); got != ns {
+			t.Fatalf("quota request %d namespace = %q, want %q", i, got, ns)
+		}
+	}
+}
+
+func TestPickerUsesNamespaceFromMetadata(t *testing.T) {
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}
+	limit := &trafficLimitAPI{future: future}
+	cb := &trafficCircuitBreakerAPI{}
+	p := &polarisPicker{
+		serviceName: "svc",
+		readyAny:    []remote.Client{&fakeRemoteClient{name: "a", state: remote.Ready}},
+		governance: governanceConfig{
+			Namespace:            "base",
+			NamespaceMetadataKey: "x-polaris-namespace",
+			RateLimit:            rateLimitConfig{Enable: true},
+			CircuitBreaker:       circuitBreakerConfig{Enable: true},
+		},
+		limit: limit,
+		cb:    cb,
+	}
+
+	for _, ns := range []string{"tenant-a", "tenant-b"} {
+		ctx := metadata.WithOutContext(
+			context.Background(),
+			metadata.Pairs("x-polaris-namespace", ns),
+		)
+		if _, err := p.Next(balancer.RPCInfo{Ctx: ctx, Method: "/svc/method"}); err != nil {
+			t.Fatalf("Next(%s) error = %v", ns, err)
+		}
+	}
+
+	for i, ns := range []string{"tenant-a", "tenant-b"} {
+		if got := limit.reqs[i].(*model.QuotaRequestImpl).GetNamespace(); got != ns {
+			t.Fatalf("quota request %d namespace = %q, want %q", i, got, ns)
+		}
+		res := cb.checks[i].(*model.MethodResource)
+		if got := res.GetService().Namespace; got != ns {
+			t.Fatalf("breaker resource %d namespace = %q, want %q", i, got, ns)
+		}
+		if got := res.GetCallerService().Namespace; got != "base" {
+			t.Fatalf("breaker caller %d namespace = %q, want base", i, got)
+		}
+	}
+}
+
+func TestPolarisPickerUsesPerMethodLbPolicy(t *testing.T) {
+	ready1 := &trafficRemoteClient{name: "ready-1", state: remote.Ready}
+	ready2 := &trafficRemoteClient{name: "ready-2", state: remote.Ready}
+	router := &trafficRouterAPI{}
+	cfg := decodeGovernanceConfig(map[string]any{
+		"routing": map[string]any{
+			"enable":      true,
+			"lb_policy":   "weightedRandom",
+			"lb_policies": map[string]any{"/svc/A": "ringHash"},
+		},
+	})
+	if err := cfg.Routing.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	p := &polarisPicker{
+		serviceName: "svc",
+		instancesResponse: testResolverState().
+			GetAttributes()["polaris_instances_response"].(*model.InstancesResponse),
+		readyByInstance: map[string]remote.Client{"ins-1": ready1, "ins-2": ready2},
+		readyAny:        []remote.Client{ready1, ready2},
+		router:          router,
+		governance:      cfg,
+	}
+
+	for _, method := range []string{"/svc/A", "/svc/B"} {
+		if _, err := p.pickRemote(context.Background(), method); err != nil {
+			t.Fatalf("pickRemote(%q) error = %v", method, err)
+		}
+	}
+	if len(router.lbReqs) != 2 {
+		t.Fatalf("load balance requests = %d, want 2", len(router.lbReqs))
+	}
+	if got := router.lbReqs[0].LbPolicy; got != "ringHash" {
+		t.Fatalf("/svc/A lb policy = %q, want ringHash", got)
+	}
+	if got := router.lbReqs[1].LbPolicy; got != "weightedRandom" {
+		t.Fatalf("/svc/B lb policy = %q, want weightedRandom", got)
+	}
+}
+
+func TestRoutingConfigLbPolicyFor(t *testing.T) {
+	cfg := routingConfig{
+		LbPolicy: "weightedRandom",
+		LbPolicies: map[string]string{
+			"/library.v1.LibraryService/*":        "maglev",
+			"/library.v1.LibraryService/Get*":     "hash",
+			"/library.v1.LibraryService/GetShelf": "l5cst",
+			"ListBooks":                           "ringHash",
+		},
+	}
+	for method, want := range map[string]string{
+		"/library.v1.LibraryService/GetShelf":   "l5cst",
+		"/library.v1.LibraryService/ListBooks":  "ringHash",
+		"/library.v1.LibraryService/GetBook":    "hash",
+		"/library.v1.LibraryService/DeleteBook": "maglev",
+		"/greeter.v1.Greeter/SayHello":          "weightedRandom",
+	} {
+		if got := cfg.lbPolicyFor(method); got != want {
+			t.Fatalf("lbPolicyFor(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestNewPolarisBalancerRejectsUnknownLbPolicy(t *testing.T) {
+	for name, routing := range map[string]map[string]any{
+		"global":     {"lb_policy": "round_robin"},
+		"per method": {"lb_policies": map[string]any{"/svc/A": "leastConn"}},
+		"pattern":    {"lb_policies": map[string]any{"/svc/[": "ringHash"}},
+	} {
+		_, err := newPolarisBalancer(func(string) map[string]any {
+			return map[string]any{"routing": routing}
+		}, "svc", "polaris", &trafficBalancerClient{})
+		if err == nil {
+			t.Fatalf("%s: newPolarisBalancer() error = nil, want invalid lb policy", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+// instanceFilterConfig holds client-side compatibility constraints applied to
+// instances before Polaris routing and load balancing.
+type instanceFilterConfig struct {
+	// Metadata lists key/value pairs an instance must advertise. The keys
+	// protocol and version fall back to the instance's own protocol and version
+	// when they are not present in its metadata.
+	Metadata map[string]string `mapstructure:"metadata"`
+	// MinVersion is the lowest acceptable instance version, compared as
+	// dot-separated numbers.
+	MinVersion string `mapstructure:"min_version"`
+}
+
+func (f instanceFilterConfig) enabled() bool {
+	return len(f.Metadata) > 0 || f.MinVersion != ""
+}
+
+func (f instanceFilterConfig) allows(protocol, version string, metadata map[string]string) bool {
+	for key, want := range f.Metadata {
+		got, ok := metadata[key]
+		if !ok {
+			switch key {
+			case "protocol":
+				got = protocol
+			case "version":
+				got = version
+			}
+		}
+		if got != want {
+			return false
+		}
+	}
+	if f.MinVersion != "" {
+		if version == "" || compareVersions(version, f.MinVersion) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f instanceFilterConfig) allowsEndpoint(ep yresolver.Endpoint) bool {
+	if !f.enabled() {
+		return true
+	}
+	attrs := ep.GetAttributes()
+	metadata := make(map[string]string, len(attrs))
+	for key, value := range attrs {
+		if s, ok := value.(string); ok {
+			metadata[key] = s
+		}
+	}
+	version, _ := attrs["version"].(string)
+	return f.allows(ep.GetProtocol(), version, metadata)
+}
+
+func (f instanceFilterConfig) allowsInstance(inst model.Instance) bool {
+	return f.allows(inst.GetProtocol(), inst.GetVersion(), inst.GetMetadata())
+}
+
+// filterInstancesResponse drops the instances rejected by the filter.
+func (f instanceFilterConfig) filterInstancesResponse(
+	resp *model.InstancesResponse,
+) *model.InstancesResponse {
+	if resp == nil || !f.enabled() {
+		return resp
+	}
+	instances := make([]model.Instance, 0, len(resp.Instances))
+	for _, inst := range resp.Instances {
+		if inst != nil && f.allowsInstance(inst) {
+			instances = append(instances, inst)
+		}
+	}
+	out := *resp
+	out.Instances = instances
+	return &out
+}
+
+// compareVersions compares dot-separated versions segment by segment. A
+// leading "v" is ignored, numeric segments compare numerically and any other
+// segment compares lexically. Missing segments count as zero.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
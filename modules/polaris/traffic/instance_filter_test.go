@@ -0,0 +1,185 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+func filterTestState() yresolver.State {
+	compatible := &fakeInstance{
+		namespace: "default",
+		service:   "svc",
+		id:        "ins-1",
+		host:      "127.0.0.1",
+		port:      9000,
+		protocol:  "grpc",
+		version:   "1.4.0",
+		healthy:   true,
+		weight:    100,
+		metadata:  map[string]string{"api": "v2"},
+	}
+	legacy := &fakeInstance{
+		namespace: "default",
+		service:   "svc",
+		id:        "ins-2",
+		host:      "127.0.0.1",
+		port:      9001,
+		protocol:  "grpc",
+		version:   "1.2.0",
+		healthy:   true,
+		weight:    100,
+		metadata:  map[string]string{},
+	}
+	resp := &model.InstancesResponse{
+		ServiceInfo: model.ServiceInfo{Service: "svc", Namespace: "default"},
+		Instances:   []model.Instance{compatible, legacy},
+	}
+
+	return yresolver.BaseState{
+		Attributes: map[string]any{"polaris_instances_response": resp},
+		Endpoints: []yresolver.Endpoint{
+			yresolver.BaseEndpoint{
+				Address:  "127.0.0.1:9000",
+				Protocol: "grpc",
+				Attributes: map[string]any{
+					"instance_id": "ins-1",
+					"version":     "1.4.0",
+					"api":         "v2",
+				},
+			},
+			yresolver.BaseEndpoint{
+				Address:  "127.0.0.1:9001",
+				Protocol: "grpc",
+				Attributes: map[string]any{
+					"instance_id": "ins-2",
+					"version":     "1.2.0",
+				},
+			},
+		},
+	}
+}
+
+func TestPolarisBalancerInstanceFilterExcludesIncompatibleInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  instanceFilterConfig
+		routing bool
+	}{
+		{
+			name:   "required metadata without routing",
+			filter: instanceFilterConfig{Metadata: map[string]string{"api": "v2"}},
+		},
+		{
+			name:    "required metadata before routing",
+			filter:  instanceFilterConfig{Metadata: map[string]string{"api": "v2"}},
+			routing: true,
+		},
+		{
+			name:    "minimum version before routing",
+			filter:  instanceFilterConfig{MinVersion: "1.3"},
+			routing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := &fakeBalancerClient{}
+			pb := newTestPolarisBalancer(bc, &assertRouterNoNonReady{forbiddenID: "ins-2"})
+			pb.governance.Routing.Enable = tt.routing
+			pb.governance.InstanceFilter = tt.filter
+
+			pb.UpdateState(filterTestState())
+
+			for range 4 {
+				pr, err := bc.lastPicker.Next(
+					balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/method"},
+				)
+				if err != nil {
+					t.Fatalf("picker Next err: %v", err)
+				}
+				if got := pr.RemoteClient().Protocol(); got != "grpc/127.0.0.1:9000" {
+					t.Fatalf("picked %s, want only the compatible instance", got)
+				}
+			}
+		})
+	}
+}
+
+func TestInstanceFilterAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   instanceFilterConfig
+		protocol string
+		version  string
+		metadata map[string]string
+		want     bool
+	}{
+		{name: "empty filter", want: true},
+		{
+			name:     "protocol falls back to instance protocol",
+			filter:   instanceFilterConfig{Metadata: map[string]string{"protocol": "grpc"}},
+			protocol: "grpc",
+			want:     true,
+		},
+		{
+			name:     "metadata overrides instance protocol",
+			filter:   instanceFilterConfig{Metadata: map[string]string{"protocol": "grpc"}},
+			protocol: "grpc",
+			metadata: map[string]string{"protocol": "http"},
+			want:     false,
+		},
+		{
+			name:    "missing version fails minimum",
+			filter:  instanceFilterConfig{MinVersion: "1.0.0"},
+			version: "",
+			want:    false,
+		},
+		{
+			name:    "equal version passes minimum",
+			filter:  instanceFilterConfig{MinVersion: "v1.2"},
+			version: "1.2.0",
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.allows(tt.protocol, tt.version, tt.metadata); got != tt.want {
+				t.Fatalf("allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.10.0", b: "1.9.9", want: 1},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "v2.0.0", b: "2.0.1", want: -1},
+		{a: "1.2.beta", b: "1.2.alpha", want: 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/traffic"
+
+// governanceMetrics counts calls rejected by Polaris rate limiting and circuit
+// breaking on the global meter provider, labeled by service and method.
+type governanceMetrics struct {
+	rateLimitRejected      metric.Int64Counter
+	circuitBreakerRejected metric.Int64Counter
+}
+
+// newGovernanceMetrics creates the rejection counters. Failures are logged and
+// yield nil, which records nothing.
+func newGovernanceMetrics() *governanceMetrics {
+	meter := otel.GetMeterProvider().Meter(meterName)
+	rateLimit, rlErr := meter.Int64Counter("polaris.rate_limit.rejected",
+		metric.WithDescription("Calls rejected by Polaris rate limiting."))
+	breaker, cbErr := meter.Int64Counter("polaris.circuit_breaker.rejected",
+		metric.WithDescription("Calls rejected by an open Polaris circuit breaker."))
+	if err := errors.Join(rlErr, cbErr); err != nil {
+		slog.Warn("create polaris governance metrics failed", slog.Any("error", err))
+		return nil
+	}
+	return &governanceMetrics{rateLimitRejected: rateLimit, circuitBreakerRejected: breaker}
+}
+
+func (m *governanceMetrics) rateLimited(ctx context.Context, service, method string) {
+	if m == nil {
+		return
+	}
+	m.rateLimitRejected.Add(ctx, 1, callAttributes(service, method))
+}
+
+func (m *governanceMetrics) circuitOpen(ctx context.Context, service, method string) {
+	if m == nil {
+		return
+	}
+	m.circuitBreakerRejected.Add(ctx, 1, callAttributes(service, method))
+}
+
+func callAttributes(service, method string) metric.AddOption {
+	return metric.WithAttributes(
+		attribute.String("service", service),
+		attribute.String("method", method),
+	)
+}
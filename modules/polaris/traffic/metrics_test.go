@@ -0,0 +1,129 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+)
+
+func TestRateLimitRejectionIncrementsCounter(t *testing.T) {
+	restoreTrafficGlobals(t)
+	reader := useManualMeterProvider(t)
+
+	api := &trafficLimitAPI{future: &trafficQuotaFuture{resp: &model.QuotaResponse{
+		Code: model.QuotaResultLimited,
+	}}}
+	getRateLimitAPI = func(string, governanceConfig) (sdk.LimitAPI, error) {
+		return api, nil
+	}
+	unary := buildPolarisRateLimitUnary(func(string) map[string]any {
+		return map[string]any{"rate_limit": map[string]any{"enable": true}}
+	}, "svc")
+
+	for i := 0; i < 2; i++ {
+		err := unary(context.Background(), "/svc/method", nil, nil,
+			func(context.Context, string, any, any) error { return nil })
+		if err == nil {
+			t.Fatal("unary() error = nil, want rate limit rejection")
+		}
+	}
+
+	p := &polarisPicker{serviceName: "svc", limit: api, metrics: newGovernanceMetrics()}
+	if err := p.checkRateLimit(context.Background(), "/svc/method"); err == nil {
+		t.Fatal("checkRateLimit() error = nil, want rate limit rejection")
+	}
+
+	got := counterValue(t, reader, "polaris.rate_limit.rejected", "svc", "/svc/method")
+	if got != 3 {
+		t.Fatalf("polaris.rate_limit.rejected = %d, want 3", got)
+	}
+}
+
+func TestCircuitBreakerRejectionIncrementsCounter(t *testing.T) {
+	restoreTrafficGlobals(t)
+	reader := useManualMeterProvider(t)
+
+	api := &trafficCircuitBreakerAPI{checkResp: &model.CheckResult{Pass: false}}
+	getCircuitBreakerAPI = func(string, governanceConfig) (sdk.CircuitBreakerAPI, error) {
+		return api, nil
+	}
+	unary := buildPolarisCircuitBreakerUnary(func(string) map[string]any {
+		return map[string]any{"circuit_breaker": map[string]any{"enable": true}}
+	}, "svc")
+
+	err := unary(context.Background(), "/svc/method", nil, nil,
+		func(context.Context, string, any, any) error { return nil })
+	if err == nil {
+		t.Fatal("unary() error = nil, want open breaker rejection")
+	}
+
+	got := counterValue(t, reader, "polaris.circuit_breaker.rejected", "svc", "/svc/method")
+	if got != 1 {
+		t.Fatalf("polaris.circuit_breaker.rejected = %d, want 1", got)
+	}
+}
+
+// useManualMeterProvider installs a global meter provider backed by a manual
+// reader for the duration of the test.
+func useManualMeterProvider(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+	prev := otel.GetMeterProvider()
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+	return reader
+}
+
+func counterValue(
+	t *testing.T,
+	reader *sdkmetric.ManualReader,
+	name, service, method string,
+) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := attribute.NewSet(
+		attribute.String("service", service),
+		attribute.String("method", method),
+	)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("%s data = %T, want Sum[int64]", name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				if dp.Attributes.Equals(&want) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+)
+
+// retryConfig retries unary calls that fail with a retryable code. Each
+// attempt goes back through the balancer, so it may land on another instance.
+type retryConfig struct {
+	// MaxAttempts counts the first call; values below 2 disable retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// RetryableCodes lists status code names such as "UNAVAILABLE";
+	// empty means UNAVAILABLE only.
+	RetryableCodes []string      `mapstructure:"retryable_codes"`
+	Backoff        time.Duration `mapstructure:"backoff"`
+}
+
+// retryableCodes resolves RetryableCodes, skipping names that are not
+// status codes.
+func (c retryConfig) retryableCodes() map[code.Code]struct{} {
+	if len(c.RetryableCodes) == 0 {
+		return map[code.Code]struct{}{code.Code_UNAVAILABLE: {}}
+	}
+	out := make(map[code.Code]struct{}, len(c.RetryableCodes))
+	for _, name := range c.RetryableCodes {
+		value, ok := code.Code_value[strings.ToUpper(name)]
+		if !ok {
+			slog.Warn("polaris retry: unknown retryable code", slog.String("code", name))
+			continue
+		}
+		out[code.Code(value)] = struct{}{}
+	}
+	return out
+}
+
+func buildPolarisRetryUnary(
+	load ConfigLoader,
+	serviceName string,
+) interceptor.UnaryClientInterceptor {
+	cfg := loadGovernanceConfig(load, serviceName).Retry
+	if cfg.MaxAttempts < 2 {
+		return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+			return invoker(ctx, method, req, reply)
+		}
+	}
+	retryable := cfg.retryableCodes()
+
+	return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = invoker(ctx, method, req, reply)
+			if err == nil || attempt >= cfg.MaxAttempts {
+				return err
+			}
+			if _, ok := retryable[status.FromError(err).Code()]; !ok {
+				return err
+			}
+			if !waitRetryBackoff(ctx, cfg.Backoff) {
+				return err
+			}
+		}
+	}
+}
+
+// waitRetryBackoff waits d before the next attempt. It reports false when ctx
+// ends first, or when its deadline leaves no time for another attempt.
+func waitRetryBackoff(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
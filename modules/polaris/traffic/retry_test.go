@@ -0,0 +1,105 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+)
+
+func retryLoader(retry map[string]any) ConfigLoader {
+	return func(string) map[string]any {
+		return map[string]any{"retry": retry}
+	}
+}
+
+func TestPolarisRetryUnaryRetriesUnavailable(t *testing.T) {
+	intercept := buildPolarisRetryUnary(retryLoader(map[string]any{
+		"max_attempts": 3,
+		"backoff":      "1ms",
+	}), "svc")
+
+	// Each invoker call stands for one balancer pick; the first instance is
+	// unavailable and the retry must reach the second.
+	instances := []string{"ins-1", "ins-2"}
+	var picked []string
+	err := intercept(context.Background(), "/svc/method", nil, nil,
+		func(context.Context, string, any, any) error {
+			picked = append(picked, instances[len(picked)])
+			if len(picked) == 1 {
+				return status.New(code.Code_UNAVAILABLE, "connection refused").Err()
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("intercept() error = %v", err)
+	}
+	if len(picked) != 2 || picked[1] != "ins-2" {
+		t.Fatalf("picked = %v, want [ins-1 ins-2]", picked)
+	}
+}
+
+func TestPolarisRetryUnaryStopsOnNonRetryableCodesAndDeadline(t *testing.T) {
+	intercept := buildPolarisRetryUnary(retryLoader(map[string]any{
+		"max_attempts":    3,
+		"retryable_codes": []any{"unavailable", "RESOURCE_EXHAUSTED", "bogus"},
+		"backoff":         "50ms",
+	}), "svc")
+
+	attempts := 0
+	failWith := func(c code.Code) func(context.Context, string, any, any) error {
+		return func(context.Context, string, any, any) error {
+			attempts++
+			return status.New(c, "failed").Err()
+		}
+	}
+
+	if err := intercept(context.Background(), "/svc/method", nil, nil,
+		failWith(code.Code_INVALID_ARGUMENT)); err == nil || attempts != 1 {
+		t.Fatalf("non-retryable: err = %v, attempts = %d, want 1", err, attempts)
+	}
+
+	attempts = 0
+	if err := intercept(context.Background(), "/svc/method", nil, nil,
+		failWith(code.Code_RESOURCE_EXHAUSTED)); err == nil || attempts != 3 {
+		t.Fatalf("retryable: err = %v, attempts = %d, want 3", err, attempts)
+	}
+
+	attempts = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := intercept(ctx, "/svc/method", nil, nil, failWith(code.Code_UNAVAILABLE))
+	if status.FromError(err).Code() != code.Code_UNAVAILABLE || attempts != 1 {
+		t.Fatalf("deadline: err = %v, attempts = %d, want last error after 1", err, attempts)
+	}
+}
+
+func TestPolarisRetryUnaryDisabledByDefault(t *testing.T) {
+	intercept := buildPolarisRetryUnary(nil, "svc")
+	attempts := 0
+	_ = intercept(context.Background(), "/svc/method", nil, nil,
+		func(context.Context, string, any, any) error {
+			attempts++
+			return status.New(code.Code_UNAVAILABLE, "down").Err()
+		})
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 without retry config", attempts)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+)
+
+// ArgumentExtractor maps fields of an outgoing request to Polaris custom
+// routing arguments for method.
+type ArgumentExtractor func(method string, req any) map[string]string
+
+// Option configures the Polaris traffic interceptor providers.
+type Option func(*options)
+
+type options struct {
+	extractors map[string]ArgumentExtractor
+}
+
+// WithArgumentExtractor sets the extractor the polaris_routing_args unary
+// client interceptor runs on calls to serviceName; an empty serviceName
+// applies to every service without its own extractor. Extracted arguments
+// reach the Polaris balancer with the call.
+func WithArgumentExtractor(serviceName string, extractor ArgumentExtractor) Option {
+	return func(o *options) {
+		if o.extractors == nil {
+			o.extractors = map[string]ArgumentExtractor{}
+		}
+		o.extractors[serviceName] = extractor
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) argumentExtractorFor(serviceName string) ArgumentExtractor {
+	if extractor, ok := o.extractors[serviceName]; ok {
+		return extractor
+	}
+	return o.extractors[""]
+}
+
+type routingArgumentsKey struct{}
+
+// withRoutingArguments returns ctx carrying args for the balancer pick.
+func withRoutingArguments(ctx context.Context, args map[string]string) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, routingArgumentsKey{}, args)
+}
+
+func routingArgumentsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	args, _ := ctx.Value(routingArgumentsKey{}).(map[string]string)
+	return args
+}
+
+// buildPolarisRoutingArgsUnary runs extractor on each request and hands the
+// result to the balancer via the context.
+func buildPolarisRoutingArgsUnary(extractor ArgumentExtractor) interceptor.UnaryClientInterceptor {
+	if extractor == nil {
+		return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+			return invoker(ctx, method, req, reply)
+		}
+	}
+	return func(ctx context.Context, method string, req, reply any, invoker interceptor.UnaryInvoker) error {
+		return invoker(withRoutingArguments(ctx, extractor(method, req)), method, req, reply)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+)
+
+type getUserRequest struct {
+	UserID string
+}
+
+func TestRoutingArgsInterceptorPassesExtractedArguments(t *testing.T) {
+	o := newOptions([]Option{WithArgumentExtractor("svc",
+		func(method string, req any) map[string]string {
+			if r, ok := req.(*getUserRequest); ok && method == "/svc/GetUser" {
+				return map[string]string{"user_id": r.UserID}
+			}
+			return nil
+		})})
+
+	router := &trafficRouterAPI{}
+	bc := &fakeBalancerClient{}
+	pb := newTestPolarisBalancer(bc, router)
+	pb.UpdateState(testResolverState())
+
+	unary := buildPolarisRoutingArgsUnary(o.argumentExtractorFor("svc"))
+	err := unary(context.Background(), "/svc/GetUser", &getUserRequest{UserID: "u-42"}, nil,
+		func(ctx context.Context, method string, _, _ any) error {
+			_, err := bc.lastPicker.Next(balancer.RPCInfo{Ctx: ctx, Method: method})
+			return err
+		})
+	if err != nil {
+		t.Fatalf("unary() error = %v", err)
+	}
+
+	if len(router.routerReqs) != 1 {
+		t.Fatalf("ProcessRouters calls = %d, want 1", len(router.routerReqs))
+	}
+	if got := quotaArgsToMap(router.routerReqs[0].Arguments); got["user_id"] != "u-42" {
+		t.Fatalf("router args = %#v, want user_id u-42", got)
+	}
+}
+
+func TestArgumentExtractorForFallsBackToDefault(t *testing.T) {
+	o := newOptions([]Option{WithArgumentExtractor("", func(string, any) map[string]string {
+		return map[string]string{"tenant": "gold"}
+	})})
+
+	extractor := o.argumentExtractorFor("other")
+	if extractor == nil || extractor("/m", nil)["tenant"] != "gold" {
+		t.Fatal("argumentExtractorFor(other) should use the default extractor")
+	}
+	if newOptions(nil).argumentExtractorFor("other") != nil {
+		t.Fatal("argumentExtractorFor(other) should be nil without extractors")
+	}
+}
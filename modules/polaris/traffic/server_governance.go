@@ -0,0 +1,109 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+	"github.com/codesjoy/yggdrasil/v3/rpc/metadata"
+)
+
+// ServerConfigLoader loads merged Polaris governance config for inbound
+// requests served by the local service.
+type ServerConfigLoader func() map[string]any
+
+// UnaryServerInterceptorProviders returns Polaris governance interceptor
+// providers for inbound requests.
+func UnaryServerInterceptorProviders(
+	load ServerConfigLoader,
+) []interceptor.UnaryServerInterceptorProvider {
+	return []interceptor.UnaryServerInterceptorProvider{
+		interceptor.NewUnaryServerInterceptorProvider(
+			"polaris_ratelimit",
+			func() interceptor.UnaryServerInterceptor {
+				return buildPolarisRateLimitServerUnary(load)
+			},
+		),
+	}
+}
+
+// buildPolarisRateLimitServerUnary requests a Polaris quota for every inbound
+// method of the configured local service and rejects the call with
+// RESOURCE_EXHAUSTED when the quota is denied. The inbound metadata keys listed
+// in metadata_arguments are passed to Polaris as custom rate-limit arguments.
+func buildPolarisRateLimitServerUnary(load ServerConfigLoader) interceptor.UnaryServerInterceptor {
+	var cfg governanceConfig
+	if load != nil {
+		cfg = decodeGovernanceConfig(load())
+	}
+	if !cfg.rateLimitEnabled() {
+		return func(ctx context.Context, req any, _ *interceptor.UnaryServerInfo, handler interceptor.UnaryHandler) (any, error) {
+			return handler(ctx, req)
+		}
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	var (
+		api     sdk.LimitAPI
+		initErr error
+	)
+	if cfg.Service == "" {
+		initErr = errors.New("polaris server rate limit: service is required")
+	} else {
+		api, initErr = getRateLimitAPI(cfg.Service, cfg)
+	}
+	metrics := newGovernanceMetrics()
+
+	return func(ctx context.Context, req any, info *interceptor.UnaryServerInfo, handler interceptor.UnaryHandler) (any, error) {
+		rateLimit := cfg.forMethod(info.FullMethod).RateLimit
+		if !rateLimit.Enable {
+			return handler(ctx, req)
+		}
+		if initErr != nil {
+			return nil, initErr
+		}
+
+		args := metadataArguments(ctx, rateLimit.MetadataArguments)
+		release, err := acquireQuota(
+			ctx, api, metrics, namespace, cfg.Service, info.FullMethod, rateLimit, args,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// metadataArguments returns the first inbound metadata value of each key.
+func metadataArguments(ctx context.Context, keys []string) map[string]string {
+	md, ok := metadata.FromInContext(ctx)
+	if !ok || len(keys) == 0 {
+		return nil
+	}
+	args := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if vs := md.Get(key); len(vs) > 0 {
+			args[key] = vs[0]
+		}
+	}
+	return args
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"google.golang.org/genproto/googleapis/rpc/code"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+	"github.com/codesjoy/yggdrasil/v3/rpc/metadata"
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+)
+
+func TestUnaryServerInterceptorProvidersExposeNames(t *testing.T) {
+	providers := UnaryServerInterceptorProviders(nil)
+	if len(providers) != 1 || providers[0].Name() != "polaris_ratelimit" {
+		t.Fatalf("server providers = %#v, want polaris_ratelimit", providers)
+	}
+}
+
+func TestBuildPolarisRateLimitServerUnaryRejectsDeniedQuota(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{
+		Code: model.QuotaResultLimited,
+		Info: "too many books",
+	}}
+	api := &trafficLimitAPI{future: future}
+	var gotService string
+	getRateLimitAPI = func(serviceName string, _ governanceConfig) (sdk.LimitAPI, error) {
+		gotService = serviceName
+		return api, nil
+	}
+
+	unary := buildPolarisRateLimitServerUnary(func() map[string]any {
+		return map[string]any{
+			"service":   "library",
+			"namespace": "prod",
+			"rate_limit": map[string]any{
+				"enable":             true,
+				"token":              2,
+				"metadata_arguments": []any{"tenant"},
+			},
+		}
+	})
+	if gotService != "library" {
+		t.Fatalf("rate limit API service = %q, want library", gotService)
+	}
+
+	ctx := metadata.WithInContext(context.Background(), metadata.MD{
+		"tenant":        {"gold"},
+		"authorization": {"Bearer secret"},
+	})
+	info := &interceptor.UnaryServerInfo{FullMethod: "/library.v1.Library/ListBooks"}
+	called := 0
+	_, err := unary(ctx, nil, info, func(context.Context, any) (any, error) {
+		called++
+		return nil, nil
+	})
+	if called != 0 {
+		t.Fatalf("handler calls = %d, want 0 when quota is denied", called)
+	}
+	if got := status.FromError(err).Code(); got != code.Code_RESOURCE_EXHAUSTED {
+		t.Fatalf("denied code = %v, want RESOURCE_EXHAUSTED", got)
+	}
+
+	req := api.reqs[0].(*model.QuotaRequestImpl)
+	if req.GetNamespace() != "prod" || req.GetService() != "library" ||
+		req.GetMethod() != info.FullMethod || req.GetToken() != 2 {
+		t.Fatalf("quota request = %#v", req)
+	}
+	if got := quotaArgsToMap(req.Arguments()); len(got) != 1 || got["tenant"] != "gold" {
+		t.Fatalf("quota args = %#v, want only the tenant metadata", got)
+	}
+
+	future.resp = &model.QuotaResponse{Code: model.QuotaResultOk}
+	if _, err := unary(ctx, nil, info, func(context.Context, any) (any, error) {
+		called++
+		return nil, nil
+	}); err != nil || called != 1 {
+		t.Fatalf("allowed call err = %v, handler calls = %d, want nil and 1", err, called)
+	}
+}
+
+func TestBuildPolarisRateLimitServerUnaryPassThrough(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	for name, load := range map[string]ServerConfigLoader{
+		"nil loader": nil,
+		"disabled": func() map[string]any {
+			return map[string]any{"service": "library"}
+		},
+	} {
+		unary := buildPolarisRateLimitServerUnary(load)
+		called := 0
+		if _, err := unary(context.Background(), nil, &interceptor.UnaryServerInfo{
+			FullMethod: "/library.v1.Library/GetBook",
+		}, func(context.Context, any) (any, error) {
+			called++
+			return nil, nil
+		}); err != nil || called != 1 {
+			t.Fatalf("%s: err = %v, handler calls = %d", name, err, called)
+		}
+	}
+
+	unary := buildPolarisRateLimitServerUnary(func() map[string]any {
+		return map[string]any{"rate_limit": map[string]any{"enable": true}}
+	})
+	if _, err := unary(context.Background(), nil, &interceptor.UnaryServerInfo{
+		FullMethod: "/library.v1.Library/GetBook",
+	}, func(context.Context, any) (any, error) {
+		t.Fatal("handler invoked without a configured service")
+		return nil, nil
+	}); err == nil {
+		t.Fatal("missing service error = nil, want error")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+
+	"github.com/codesjoy/yggdrasil/v3/rpc/interceptor"
+	"github.com/codesjoy/yggdrasil/v3/rpc/stream"
+)
+
+// StreamClientInterceptorProviders returns Polaris governance stream
+// interceptor providers. Quota is checked once when a stream is established,
+// so long-lived streams are not re-throttled; the breaker result is reported
+// when the stream finishes.
+func StreamClientInterceptorProviders(
+	load ConfigLoader,
+) []interceptor.StreamClientInterceptorProvider {
+	return []interceptor.StreamClientInterceptorProvider{
+		interceptor.NewStreamClientInterceptorProvider(
+			"polaris_ratelimit",
+			func(serviceName string) interceptor.StreamClientInterceptor {
+				return buildPolarisRateLimitStream(load, serviceName)
+			},
+		),
+		interceptor.NewStreamClientInterceptorProvider(
+			"polaris_circuitbreaker",
+			func(serviceName string) interceptor.StreamClientInterceptor {
+				return buildPolarisCircuitBreakerStream(load, serviceName)
+			},
+		),
+	}
+}
+
+func passthroughStream(
+	ctx context.Context,
+	desc *stream.Desc,
+	method string,
+	streamer interceptor.Streamer,
+) (stream.ClientStream, error) {
+	return streamer(ctx, desc, method)
+}
+
+func buildPolarisRateLimitStream(
+	load ConfigLoader,
+	serviceName string,
+) interceptor.StreamClientInterceptor {
+	cfg := loadGovernanceConfig(load, serviceName)
+	if !cfg.rateLimitEnabled() {
+		return passthroughStream
+	}
+
+	api, initErr := getRateLimitAPI(serviceName, cfg)
+	metrics := newGovernanceMetrics()
+
+	return func(
+		ctx context.Context,
+		desc *stream.Desc,
+		method string,
+		streamer interceptor.Streamer,
+	) (stream.ClientStream, error) {
+		rateLimit := cfg.forMethod(method).RateLimit
+		if !rateLimit.Enable {
+			return streamer(ctx, desc, method)
+		}
+		if initErr != nil {
+			return nil, initErr
+		}
+
+		namespace := cfg.namespaceFor(ctx)
+		release, err := acquireQuota(
+			ctx, api, metrics, namespace, serviceName, method, rateLimit, nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+		cs, err := streamer(ctx, desc, method)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		return newGovernedClientStream(cs, desc, func(error) { release() }), nil
+	}
+}
+
+func buildPolarisCircuitBreakerStream(
+	load ConfigLoader,
+	serviceName string,
+) interceptor.StreamClientInterceptor {
+	cfg := loadGovernanceConfig(load, serviceName)
+	if !cfg.circuitBreakerEnabled() {
+		return passthroughStream
+	}
+
+	callerNamespace := cfg.CallerNamespace
+	if callerNamespace == "" {
+		callerNamespace = cfg.baseNamespace()
+	}
+	callerService := cfg.CallerService
+	if callerService == "" {
+		callerService = "unknown"
+	}
+
+	api, initErr := getCircuitBreakerAPI(serviceName, cfg)
+	src := &model.ServiceKey{Namespace: callerNamespace, Service: callerService}
+	metrics := newGovernanceMetrics()
+	breakers := breakerTrackerFor(serviceName)
+
+	return func(
+		ctx context.Context,
+		desc *stream.Desc,
+		method string,
+		streamer interceptor.Streamer,
+	) (stream.ClientStream, error) {
+		if !cfg.forMethod(method).CircuitBreaker.Enable {
+			return streamer(ctx, desc, method)
+		}
+		if initErr != nil {
+			return nil, initErr
+		}
+
+		dst := &model.ServiceKey{Namespace: cfg.namespaceFor(ctx), Service: serviceName}
+		res, err := checkCircuitBreaker(ctx, api, metrics, breakers, dst, src, method)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		cs, err := streamer(ctx, desc, method)
+		if err != nil {
+			reportCircuitBreaker(api, breakers, res, start, err)
+			return nil, err
+		}
+		return newGovernedClientStream(cs, desc, func(streamErr error) {
+			reportCircuitBreaker(api, breakers, res, start, streamErr)
+		}), nil
+	}
+}
+
+// governedClientStream calls done exactly once with the final stream error
+// (nil on a clean io.EOF) when the stream finishes.
+type governedClientStream struct {
+	stream.ClientStream
+
+	// singleResponse is set for client-streaming RPCs, which finish on the
+	// first successful RecvMsg.
+	singleResponse bool
+	once           sync.Once
+	done           func(error)
+}
+
+func newGovernedClientStream(
+	cs stream.ClientStream,
+	desc *stream.Desc,
+	done func(error),
+) *governedClientStream {
+	return &governedClientStream{
+		ClientStream:   cs,
+		singleResponse: desc != nil && !desc.ServerStreams,
+		done:           done,
+	}
+}
+
+func (s *governedClientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	// io.EOF means the stream broke; the real error is surfaced by RecvMsg.
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *governedClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.singleResponse {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *governedClientStream) finish(err error) {
+	s.once.Do(func() {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		s.done(err)
+	})
+}
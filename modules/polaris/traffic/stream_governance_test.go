@@ -0,0 +1,195 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"google.golang.org/genproto/googleapis/rpc/code"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/polaris/v3/internal/sdk"
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+	"github.com/codesjoy/yggdrasil/v3/rpc/stream"
+)
+
+type fakeClientStream struct {
+	stream.ClientStream
+	recvErrs []error
+}
+
+func (s *fakeClientStream) RecvMsg(any) error {
+	if len(s.recvErrs) == 0 {
+		return nil
+	}
+	err := s.recvErrs[0]
+	s.recvErrs = s.recvErrs[1:]
+	return err
+}
+
+func TestPolarisRateLimitStreamDeniedQuotaPreventsStream(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{
+		Code: model.QuotaResultLimited,
+		Info: "stream limited",
+	}}
+	api := &trafficLimitAPI{future: future}
+	getRateLimitAPI = func(string, governanceConfig) (sdk.LimitAPI, error) { return api, nil }
+
+	intercept := buildPolarisRateLimitStream(func(string) map[string]any {
+		return map[string]any{"rate_limit": map[string]any{"enable": true, "release": true}}
+	}, "svc")
+	streamed := 0
+	cs, err := intercept(
+		context.Background(),
+		&stream.Desc{ServerStreams: true},
+		"/svc/Watch",
+		func(context.Context, *stream.Desc, string) (stream.ClientStream, error) {
+			streamed++
+			return &fakeClientStream{}, nil
+		},
+	)
+	if status.FromError(err).Code() != code.Code_RESOURCE_EXHAUSTED {
+		t.Fatalf("stream error = %v, want RESOURCE_EXHAUSTED", err)
+	}
+	if cs != nil || streamed != 0 {
+		t.Fatalf("stream = %v, streamer calls = %d, want no stream", cs, streamed)
+	}
+	if future.released != 1 {
+		t.Fatalf("quota released = %d, want 1", future.released)
+	}
+}
+
+func TestPolarisRateLimitStreamReleasesQuotaOnClose(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}
+	api := &trafficLimitAPI{future: future}
+	getRateLimitAPI = func(string, governanceConfig) (sdk.LimitAPI, error) { return api, nil }
+
+	intercept := buildPolarisRateLimitStream(func(string) map[string]any {
+		return map[string]any{"rate_limit": map[string]any{"enable": true, "release": true}}
+	}, "svc")
+	cs, err := intercept(
+		context.Background(),
+		&stream.Desc{ServerStreams: true},
+		"/svc/Watch",
+		func(context.Context, *stream.Desc, string) (stream.ClientStream, error) {
+			return &fakeClientStream{recvErrs: []error{nil, nil, io.EOF}}, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cs.RecvMsg(nil); err != nil {
+			t.Fatalf("RecvMsg() error = %v", err)
+		}
+	}
+	if len(api.reqs) != 1 || future.released != 0 {
+		t.Fatalf("quota reqs = %d released = %d, want 1/0 mid-stream",
+			len(api.reqs), future.released)
+	}
+	if err := cs.RecvMsg(nil); !errors.Is(err, io.EOF) {
+		t.Fatalf("RecvMsg() error = %v, want EOF", err)
+	}
+	_ = cs.RecvMsg(nil)
+	if future.released != 1 {
+		t.Fatalf("quota released = %d, want 1 after close", future.released)
+	}
+}
+
+func TestPolarisCircuitBreakerStreamReportsOnClose(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	api := &trafficCircuitBreakerAPI{}
+	getCircuitBreakerAPI = func(string, governanceConfig) (sdk.CircuitBreakerAPI, error) {
+		return api, nil
+	}
+	intercept := buildPolarisCircuitBreakerStream(func(string) map[string]any {
+		return map[string]any{"circuit_breaker": map[string]any{"enable": true}}
+	}, "svc")
+
+	streamErr := status.New(code.Code_INTERNAL, "boom").Err()
+	cs, err := intercept(
+		context.Background(),
+		&stream.Desc{ServerStreams: true},
+		"/svc/Watch",
+		func(context.Context, *stream.Desc, string) (stream.ClientStream, error) {
+			return &fakeClientStream{recvErrs: []error{nil, streamErr}}, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if len(api.checks) != 1 || len(api.reports) != 0 {
+		t.Fatalf("checks = %d reports = %d, want 1/0", len(api.checks), len(api.reports))
+	}
+	_ = cs.RecvMsg(nil)
+	_ = cs.RecvMsg(nil)
+	if len(api.reports) != 1 {
+		t.Fatalf("reports = %d, want 1", len(api.reports))
+	}
+	if api.reports[0].RetStatus != model.RetFail ||
+		api.reports[0].RetCode != code.Code_INTERNAL.String() {
+		t.Fatalf("report = %+v, want INTERNAL failure", api.reports[0])
+	}
+
+	api.checkResp = &model.CheckResult{Pass: false}
+	if _, err := intercept(
+		context.Background(),
+		&stream.Desc{ClientStreams: true},
+		"/svc/Upload",
+		func(context.Context, *stream.Desc, string) (stream.ClientStream, error) {
+			t.Fatal("streamer called with open circuit")
+			return nil, nil
+		},
+	); status.FromError(err).Code() != code.Code_UNAVAILABLE {
+		t.Fatalf("open circuit error = %v, want UNAVAILABLE", err)
+	}
+}
+
+func TestPolarisCircuitBreakerStreamClientStreamingReportsOnResponse(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	api := &trafficCircuitBreakerAPI{}
+	getCircuitBreakerAPI = func(string, governanceConfig) (sdk.CircuitBreakerAPI, error) {
+		return api, nil
+	}
+	intercept := buildPolarisCircuitBreakerStream(func(string) map[string]any {
+		return map[string]any{"circuit_breaker": map[string]any{"enable": true}}
+	}, "svc")
+	cs, err := intercept(
+		context.Background(),
+		&stream.Desc{ClientStreams: true},
+		"/svc/Upload",
+		func(context.Context, *stream.Desc, string) (stream.ClientStream, error) {
+			return &fakeClientStream{}, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatalf("RecvMsg() error = %v", err)
+	}
+	if len(api.reports) != 1 || api.reports[0].RetStatus != model.RetSuccess {
+		t.Fatalf("reports = %+v, want one success", api.reports)
+	}
+}
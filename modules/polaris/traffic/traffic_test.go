@@ -186,12 +186,18 @@ func quotaArgsToMap(args []model.Argument) map[string]string {
 
 func TestUnaryClientInterceptorProvidersExposeNames(t *testing.T) {
 	providers := UnaryClientInterceptorProviders(nil)
-	if len(providers) != 2 {
-		t.Fatalf("providers len = %d, want 2", len(providers))
+	if len(providers) != 4 {
+		t.Fatalf("providers len = %d, want 4", len(providers))
 	}
 	if providers[0].Name() != "polaris_ratelimit" ||
-		providers[1].Name() != "polaris_circuitbreaker" {
-		t.Fatalf("provider names = %q, %q", providers[0].Name(), providers[1].Name())
+		providers[1].Name() != "polaris_circuitbreaker" ||
+		providers[2].Name() != "polaris_retry" ||
+		providers[3].Name() != "polaris_routing_args" {
+		t.Fatalf(
+			"provider names = %q, %q, %q, %q",
+			providers[0].Name(), providers[1].Name(), providers[2].Name(),
+			providers[3].Name(),
+		)
 	}
 }
 
@@ -370,6 +376,39 @@ func TestBuildPolarisRateLimitUnaryCoversControlFlow(t *testing.T) {
 	})
 }
 
+func TestBuildPolarisRateLimitUnaryUsesMethodToken(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	future := &trafficQuotaFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}
+	api := &trafficLimitAPI{future: future}
+	getRateLimitAPI = func(string, governanceConfig) (sdk.LimitAPI, error) { return api, nil }
+
+	unary := buildPolarisRateLimitUnary(func(string) map[string]any {
+		return map[string]any{
+			"rate_limit": map[string]any{"enable": true, "token": 1},
+			"methods": map[string]any{
+				"ListBooks": map[string]any{"rate_limit": map[string]any{"token": 10}},
+			},
+		}
+	}, "svc")
+	invoke := func(context.Context, string, any, any) error { return nil }
+
+	for _, method := range []string{"/svc/ListBooks", "/svc/GetBook"} {
+		if err := unary(context.Background(), method, nil, nil, invoke); err != nil {
+			t.Fatalf("unary(%s) error = %v", method, err)
+		}
+	}
+	if len(api.reqs) != 2 {
+		t.Fatalf("quota reqs = %d, want 2", len(api.reqs))
+	}
+	if got := api.reqs[0].(*model.QuotaRequestImpl).GetToken(); got != 10 {
+		t.Fatalf("ListBooks quota token = %d, want 10", got)
+	}
+	if got := api.reqs[1].(*model.QuotaRequestImpl).GetToken(); got != 1 {
+		t.Fatalf("GetBook quota token = %d, want 1", got)
+	}
+}
+
 func TestBuildPolarisCircuitBreakerUnaryCoversControlFlow(t *testing.T) {
 	restoreTrafficGlobals(t)
 
@@ -484,6 +523,42 @@ func TestBuildPolarisCircuitBreakerUnaryCoversControlFlow(t *testing.T) {
 	})
 }
 
+func TestBalancerDefaultsSourceServiceToCallerIdentity(t *testing.T) {
+	restoreTrafficGlobals(t)
+
+	router := &trafficRouterAPI{}
+	getBalancerAPIs = func(
+		string,
+		governanceConfig,
+	) (sdk.RouterAPI, error, sdk.LimitAPI, error, sdk.CircuitBreakerAPI, error) {
+		return router, nil, nil, nil, nil, nil
+	}
+	load := WithCallerDefaults(func(string) map[string]any {
+		return map[string]any{"routing": map[string]any{"enable": true}}
+	}, func() (string, string) {
+		return "library-client", "prod"
+	})
+
+	bc := &fakeBalancerClient{}
+	b, err := BalancerProvider(load).New("svc", polarisBalancerName, bc)
+	if err != nil {
+		t.Fatalf("provider.New() error = %v", err)
+	}
+	b.UpdateState(testResolverState())
+	if _, err := bc.lastPicker.Next(
+		balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/method"},
+	); err != nil {
+		t.Fatalf("picker Next err: %v", err)
+	}
+	if len(router.routerReqs) != 1 {
+		t.Fatalf("ProcessRouters calls = %d, want 1", len(router.routerReqs))
+	}
+	src := router.routerReqs[0].SourceService
+	if src.Service != "library-client" || src.Namespace != "prod" {
+		t.Fatalf("SourceService = %+v, want library-client in prod", src)
+	}
+}
+
 func TestBalancerProviderAndConstructorUseInjectedAPIs(t *testing.T) {
 	restoreTrafficGlobals(t)
 
@@ -498,7 +573,7 @@ func TestBalancerProviderAndConstructorUseInjectedAPIs(t *testing.T) {
 			t.Fatalf("serviceName = %q, want svc", serviceName)
 		}
 		if cfg.Namespace != "default" || !cfg.Routing.Enable ||
-			cfg.Routing.LbPolicy != "round_robin" {
+			cfg.Routing.LbPolicy != "ringHash" {
 			t.Fatalf("governance config = %#v", cfg)
 		}
 		return router, nil, limit, nil, cb, nil
@@ -509,7 +584,7 @@ func TestBalancerProviderAndConstructorUseInjectedAPIs(t *testing.T) {
 			"namespace": "default",
 			"routing": map[string]any{
 				"enable":    true,
-				"lb_policy": "round_robin",
+				"lb_policy": "ringHash",
 			},
 		}
 	})
@@ -935,7 +1010,7 @@ func TestPolarisPickerRoutingAndPickerHelpers(t *testing.T) {
 			t.Fatalf("router args = %#v", got)
 		}
 
-		one, err := p.processLoadBalance(filtered)
+		one, err := p.processLoadBalance("/svc/method", filtered)
 		if err != nil {
 			t.Fatalf("processLoadBalance() error = %v", err)
 		}
@@ -0,0 +1,143 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+
+	remote "github.com/codesjoy/yggdrasil/v3/transport"
+)
+
+const defaultWarmUpMinWeight = 0.1
+
+// warmUpConfig ramps the traffic share of a newly ready instance linearly
+// from MinWeight to full weight over Window.
+type warmUpConfig struct {
+	Window    time.Duration `mapstructure:"window"`
+	MinWeight float64       `mapstructure:"min_weight"`
+}
+
+func (c warmUpConfig) enabled() bool {
+	return c.Window > 0
+}
+
+// factor returns the weight multiplier for an instance ready for readyFor.
+func (c warmUpConfig) factor(readyFor time.Duration) float64 {
+	if !c.enabled() || readyFor >= c.Window {
+		return 1
+	}
+	minWeight := c.MinWeight
+	if minWeight <= 0 {
+		minWeight = defaultWarmUpMinWeight
+	}
+	if minWeight >= 1 {
+		return 1
+	}
+	f := float64(readyFor) / float64(c.Window)
+	if f < minWeight {
+		return minWeight
+	}
+	return f
+}
+
+// trackReadySince returns when each ready client in clients was first seen
+// ready, carrying over timestamps from prev and dropping everything else.
+func trackReadySince(
+	prev map[remote.Client]time.Time,
+	clients map[string]remote.Client,
+	now time.Time,
+) map[remote.Client]time.Time {
+	next := make(map[remote.Client]time.Time, len(clients))
+	for _, cli := range clients {
+		if cli.State() != remote.Ready {
+			continue
+		}
+		if since, ok := prev[cli]; ok {
+			next[cli] = since
+			continue
+		}
+		next[cli] = now
+	}
+	return next
+}
+
+// warmUpFactor returns the current weight multiplier for cli.
+func (p *polarisPicker) warmUpFactor(cli remote.Client) float64 {
+	since, ok := p.readySince[cli]
+	if !ok {
+		return 1
+	}
+	return p.governance.WarmUp.factor(p.now().Sub(since))
+}
+
+// pickWarmUp selects among candidates weighted by their warm-up factor and
+// instance weight. It reports false when no candidate is warming up, leaving
+// the caller's own selection in effect.
+func (p *polarisPicker) pickWarmUp(candidates []remote.Client) (remote.Client, bool) {
+	if !p.governance.WarmUp.enabled() || len(candidates) == 0 {
+		return nil, false
+	}
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	warming := false
+	for i, cli := range candidates {
+		f := p.warmUpFactor(cli)
+		weights[i] = f * p.instanceWeight(cli)
+		total += weights[i]
+		if f < 1 {
+			warming = true
+		}
+	}
+	if !warming {
+		return nil, false
+	}
+	r := rand.Float64() * total //nolint:gosec // load balancing does not need crypto randomness.
+	for i, w := range weights {
+		if r < w {
+			return candidates[i], true
+		}
+		r -= w
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// warmUpRouted keeps the instance chosen by Polaris load balancing with
+// probability equal to its warm-up factor and otherwise re-selects among the
+// routed instances by warm-up weight.
+func (p *polarisPicker) warmUpRouted(
+	selected remote.Client,
+	routed *model.InstancesResponse,
+) remote.Client {
+	f := p.warmUpFactor(selected)
+	if f >= 1 || rand.Float64() < f { //nolint:gosec // see pickWarmUp.
+		return selected
+	}
+	candidates := make([]remote.Client, 0, len(routed.Instances))
+	for _, inst := range routed.Instances {
+		if inst == nil {
+			continue
+		}
+		if cli, ok := p.readyClient(inst); ok {
+			candidates = append(candidates, cli)
+		}
+	}
+	if cli, ok := p.pickWarmUp(candidates); ok {
+		return cli
+	}
+	return selected
+}
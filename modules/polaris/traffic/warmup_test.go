@@ -0,0 +1,115 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	remote "github.com/codesjoy/yggdrasil/v3/transport"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+)
+
+func TestWarmUpConfigFactor(t *testing.T) {
+	cfg := warmUpConfig{Window: 10 * time.Second}
+	cases := []struct {
+		readyFor time.Duration
+		want     float64
+	}{
+		{0, defaultWarmUpMinWeight},
+		{5 * time.Second, 0.5},
+		{10 * time.Second, 1},
+		{time.Minute, 1},
+	}
+	for _, tc := range cases {
+		if got := cfg.factor(tc.readyFor); got != tc.want {
+			t.Fatalf("factor(%v) = %v, want %v", tc.readyFor, got, tc.want)
+		}
+	}
+	if got := (warmUpConfig{}).factor(0); got != 1 {
+		t.Fatalf("disabled factor = %v, want 1", got)
+	}
+	if got := (warmUpConfig{Window: time.Second, MinWeight: 0.3}).factor(0); got != 0.3 {
+		t.Fatalf("min_weight factor = %v, want 0.3", got)
+	}
+}
+
+func TestPolarisBalancerWarmUpRampsNewInstance(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bc := &fakeBalancerClient{}
+	pb := &polarisBalancer{
+		serviceName:      "svc",
+		cli:              bc,
+		remoteByName:     make(map[string]remote.Client),
+		remoteByInstance: make(map[string]remote.Client),
+		now:              func() time.Time { return now },
+		governance: governanceConfig{
+			WarmUp: warmUpConfig{Window: 10 * time.Second},
+		},
+	}
+
+	oldEndpoint := yresolver.BaseEndpoint{
+		Address:    "127.0.0.1:9000",
+		Protocol:   "grpc",
+		Attributes: map[string]any{"instance_id": "ins-1"},
+	}
+	pb.UpdateState(yresolver.BaseState{Endpoints: []yresolver.Endpoint{oldEndpoint}})
+	now = now.Add(time.Minute)
+	pb.UpdateState(yresolver.BaseState{Endpoints: []yresolver.Endpoint{
+		oldEndpoint,
+		yresolver.BaseEndpoint{
+			Address:    "127.0.0.1:9001",
+			Protocol:   "grpc",
+			Attributes: map[string]any{"instance_id": "ins-2"},
+		},
+	}})
+	start := now
+
+	newShare := func() float64 {
+		const picks = 4000
+		hits := 0
+		for i := 0; i < picks; i++ {
+			pr, err := bc.lastPicker.Next(
+				balancer.RPCInfo{Ctx: context.Background(), Method: "/svc/method"},
+			)
+			if err != nil {
+				t.Fatalf("picker Next err: %v", err)
+			}
+			if pr.RemoteClient().Protocol() == "grpc/127.0.0.1:9001" {
+				hits++
+			}
+		}
+		return float64(hits) / picks
+	}
+
+	now = start.Add(time.Second)
+	early := newShare()
+	now = start.Add(5 * time.Second)
+	mid := newShare()
+	now = start.Add(10 * time.Second)
+	done := newShare()
+
+	if early >= 0.2 {
+		t.Fatalf("share at 1s = %.2f, want < 0.2", early)
+	}
+	if mid <= early+0.1 || mid >= 0.45 {
+		t.Fatalf("share at 5s = %.2f, want between early %.2f and full weight", mid, early)
+	}
+	if done < 0.45 || done > 0.55 {
+		t.Fatalf("share after window = %.2f, want about 0.5", done)
+	}
+}
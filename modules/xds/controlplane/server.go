@@ -0,0 +1,301 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controlplane provides an ADS server that can be embedded in a
+// process to act as a lightweight xDS control plane driven by code.
+package controlplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/controlplane/snapshot"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
+	listenerservice "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
+	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	// DefaultNodeID keys the snapshot served to nodes without their own
+	// snapshot when Config.DefaultNodeID is empty.
+	DefaultNodeID = "default"
+
+	grpcMaxConcurrentStreams = 1000000
+)
+
+// ErrServerStopped is returned by Serve after Stop has been called.
+var ErrServerStopped = errors.New("xds control plane stopped")
+
+// KeepaliveConfig holds gRPC server keepalive and enforcement settings.
+// Zero values fall back to the gRPC defaults.
+type KeepaliveConfig struct {
+	MaxConnectionIdle   time.Duration
+	Time                time.Duration
+	Timeout             time.Duration
+	MinTime             time.Duration
+	PermitWithoutStream bool
+}
+
+// Config configures an embedded ADS server.
+type Config struct {
+	// DefaultNodeID keys the snapshot served to every node that has no
+	// snapshot of its own. It defaults to DefaultNodeID.
+	DefaultNodeID string
+	Keepalive     KeepaliveConfig
+	// Reflection registers gRPC server reflection so tools like grpcurl can
+	// list the discovery services; leave it off in production.
+	Reflection bool
+	// Callbacks observe discovery streams; nil disables them.
+	Callbacks server.Callbacks
+	// ServerOptions are appended to the gRPC server options.
+	ServerOptions []grpc.ServerOption
+}
+
+// Server serves xDS resources over ADS and the per-type discovery services.
+// Resources are set per node with SetConfig or SetConfigs; a node whose ID
+// has no snapshot of its own is served the default node's snapshot.
+type Server struct {
+	defaultNodeID     string
+	cache             cache.SnapshotCache
+	grpcServer        *grpc.Server
+	keepaliveParams   keepalive.ServerParameters
+	enforcementPolicy keepalive.EnforcementPolicy
+
+	mu       sync.Mutex
+	versions map[string]uint64
+	stopped  bool
+}
+
+// NewServer creates an embedded ADS server. It does not listen until Serve.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		defaultNodeID: cfg.DefaultNodeID,
+		versions:      make(map[string]uint64),
+	}
+	if s.defaultNodeID == "" {
+		s.defaultNodeID = DefaultNodeID
+	}
+	s.cache = cache.NewSnapshotCache(false, nodeHash{server: s}, nil)
+
+	callbacks := cfg.Callbacks
+	if callbacks == nil {
+		callbacks = server.CallbackFuncs{}
+	}
+	xdsServer := server.NewServer(context.Background(), s.cache, callbacks)
+
+	s.keepaliveParams = keepalive.ServerParameters{
+		MaxConnectionIdle: cfg.Keepalive.MaxConnectionIdle,
+		Time:              cfg.Keepalive.Time,
+		Timeout:           cfg.Keepalive.Timeout,
+	}
+	s.enforcementPolicy = keepalive.EnforcementPolicy{
+		MinTime:             cfg.Keepalive.MinTime,
+		PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+	}
+	opts := []grpc.ServerOption{
+		grpc.MaxConcurrentStreams(grpcMaxConcurrentStreams),
+		grpc.KeepaliveParams(s.keepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(s.enforcementPolicy),
+	}
+	s.grpcServer = grpc.NewServer(append(opts, cfg.ServerOptions...)...)
+
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(s.grpcServer, xdsServer)
+	endpointservice.RegisterEndpointDiscoveryServiceServer(s.grpcServer, xdsServer)
+	clusterservice.RegisterClusterDiscoveryServiceServer(s.grpcServer, xdsServer)
+	routeservice.RegisterRouteDiscoveryServiceServer(s.grpcServer, xdsServer)
+	listenerservice.RegisterListenerDiscoveryServiceServer(s.grpcServer, xdsServer)
+	if cfg.Reflection {
+		reflection.Register(s.grpcServer)
+	}
+	return s
+}
+
+// DefaultNode returns the node ID whose snapshot is served to unknown nodes.
+func (s *Server) DefaultNode() string { return s.defaultNodeID }
+
+// Cache returns the snapshot cache backing the server.
+func (s *Server) Cache() cache.SnapshotCache { return s.cache }
+
+// SetConfig builds cfg into a snapshot and serves it to nodeID, or to the
+// default node when nodeID is empty. Every node has its own version counter.
+func (s *Server) SetConfig(nodeID string, cfg *snapshot.XDSConfig) error {
+	if nodeID == "" {
+		nodeID = s.defaultNodeID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snaps, err := s.buildLocked(map[string]*snapshot.XDSConfig{nodeID: cfg})
+	if err != nil {
+		return err
+	}
+	return s.setLocked(snaps)
+}
+
+// SetConfigs replaces the served configuration of every node. All snapshots
+// are built before any is set, so an invalid config leaves every node on its
+// previous snapshot. Nodes missing from configs stop being served their own
+// snapshot; the default node keeps its snapshot unless it is listed.
+func (s *Server) SetConfigs(configs map[string]*snapshot.XDSConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snaps, err := s.buildLocked(configs)
+	if err != nil {
+		return err
+	}
+	if err := s.setLocked(snaps); err != nil {
+		return err
+	}
+	for nodeID := range s.versions {
+		if _, ok := snaps[nodeID]; ok || nodeID == s.defaultNodeID {
+			continue
+		}
+		s.removeLocked(nodeID)
+	}
+	return nil
+}
+
+// RemoveNode stops serving nodeID its own snapshot. Later requests from the
+// node are served the default node's snapshot.
+func (s *Server) RemoveNode(nodeID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(nodeID)
+}
+
+// Version returns the snapshot version last set for nodeID, or "" when the
+// node has no snapshot.
+func (s *Server) Version(nodeID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, ok := s.versions[nodeID]
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(version, 10)
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		_ = lis.Close()
+		return ErrServerStopped
+	}
+	if err := s.grpcServer.Serve(lis); err != nil &&
+		!errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("serve xds control plane: %w", err)
+	}
+	return nil
+}
+
+// Stop stops accepting streams and waits for open ones to finish. Streams
+// still open when ctx is done are closed forcibly.
+func (s *Server) Stop(ctx context.Context) {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-done
+	}
+}
+
+func (s *Server) buildLocked(
+	configs map[string]*snapshot.XDSConfig,
+) (map[string]*cache.Snapshot, error) {
+	nodeIDs := make([]string, 0, len(configs))
+	for nodeID := range configs {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	snaps := make(map[string]*cache.Snapshot, len(configs))
+	for _, nodeID := range nodeIDs {
+		if nodeID == "" {
+			return nil, errors.New("empty node id")
+		}
+		version := strconv.FormatUint(s.versions[nodeID]+1, 10)
+		snap, err := snapshot.NewBuilder(version).BuildSnapshot(configs[nodeID])
+		if err != nil {
+			return nil, fmt.Errorf("build snapshot for node %q: %w", nodeID, err)
+		}
+		if err := snap.Consistent(); err != nil {
+			return nil, fmt.Errorf("snapshot for node %q inconsistent: %w", nodeID, err)
+		}
+		snaps[nodeID] = snap
+	}
+	return snaps, nil
+}
+
+func (s *Server) setLocked(snaps map[string]*cache.Snapshot) error {
+	for nodeID, snap := range snaps {
+		if err := s.cache.SetSnapshot(context.Background(), nodeID, snap); err != nil {
+			return fmt.Errorf("set snapshot for node %q: %w", nodeID, err)
+		}
+		s.versions[nodeID]++
+	}
+	return nil
+}
+
+func (s *Server) removeLocked(nodeID string) {
+	if _, ok := s.versions[nodeID]; !ok {
+		return
+	}
+	s.cache.ClearSnapshot(nodeID)
+	delete(s.versions, nodeID)
+}
+
+// nodeHash keys the snapshot cache by node ID, falling back to the default
+// node for nodes without a snapshot of their own.
+type nodeHash struct {
+	server *Server
+}
+
+func (h nodeHash) ID(node *corev3.Node) string {
+	id := node.GetId()
+	if id == "" {
+		return h.server.defaultNodeID
+	}
+	h.server.mu.Lock()
+	_, ok := h.server.versions[id]
+	h.server.mu.Unlock()
+	if !ok {
+		return h.server.defaultNodeID
+	}
+	return id
+}
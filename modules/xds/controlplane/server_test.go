@@ -0,0 +1,324 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/controlplane/snapshot"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/discovery"
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+const greeterConfigYAML = `
+clusters:
+  - name: "greeter-cluster"
+endpoints:
+  - clusterName: "greeter-cluster"
+    endpoints:
+      - address: "127.0.0.1"
+        port: 56051
+listeners:
+  - name: "greeter"
+    filterChains:
+      - filters:
+          - name: "envoy.filters.network.http_connection_manager"
+            routeConfigName: "greeter-route"
+routes:
+  - name: "greeter-route"
+    virtualHosts:
+      - name: "greeter"
+        domains: ["*"]
+        routes:
+          - match:
+              path:
+                prefix: "/"
+            route:
+              cluster: "greeter-cluster"
+`
+
+type stateRecorder struct {
+	ch chan yresolver.State
+}
+
+func (r *stateRecorder) UpdateState(state yresolver.State) {
+	select {
+	case r.ch <- state:
+	default:
+	}
+}
+
+func TestServerServesConfigToResolver(t *testing.T) {
+	srv := NewServer(Config{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(lis) }()
+
+	if err := srv.SetConfig("", parseConfig(t, greeterConfigYAML)); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+
+	cfg := discovery.DefaultResolverConfig()
+	cfg.Server.Address = lis.Addr().String()
+	cfg.Node.ID = "embedded-client"
+	r, err := discovery.NewResolver("default", cfg)
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	recorder := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	if err := r.AddWatch("greeter", recorder); err != nil {
+		t.Fatalf("AddWatch() error = %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for found := false; !found; {
+		select {
+		case state := <-recorder.ch:
+			for _, ep := range state.GetEndpoints() {
+				if ep.GetAddress() == "127.0.0.1:56051" {
+					found = true
+				}
+			}
+		case <-deadline:
+			t.Fatal("resolver did not receive the embedded server's endpoints")
+		}
+	}
+	if err := r.DelWatch("greeter", recorder); err != nil {
+		t.Fatalf("DelWatch() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Stop(ctx)
+	if err := <-serveErr; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if err := srv.Serve(lis); err != ErrServerStopped {
+		t.Fatalf("Serve() after Stop error = %v, want ErrServerStopped", err)
+	}
+}
+
+func TestServerKeepsPerNodeSnapshots(t *testing.T) {
+	srv := NewServer(Config{DefaultNodeID: "fallback"})
+	base := parseConfig(t, greeterConfigYAML)
+	canary := parseConfig(t, greeterConfigYAML)
+	canary.Clusters[0].Name = "canary-cluster"
+	canary.Endpoints[0].ClusterName = "canary-cluster"
+	canary.Routes[0].VirtualHosts[0].Routes[0].Route.Cluster = "canary-cluster"
+
+	if err := srv.SetConfigs(map[string]*snapshot.XDSConfig{
+		"fallback": base,
+		"canary":   canary,
+	}); err != nil {
+		t.Fatalf("SetConfigs() error = %v", err)
+	}
+	if err := srv.SetConfig("canary", canary); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	if got := srv.Version("fallback"); got != "1" {
+		t.Fatalf("fallback version = %q, want 1", got)
+	}
+	if got := srv.Version("canary"); got != "2" {
+		t.Fatalf("canary version = %q, want 2", got)
+	}
+
+	hash := nodeHash{server: srv}
+	if got := hash.ID(&corev3.Node{Id: "canary"}); got != "canary" {
+		t.Fatalf("hash(canary) = %q, want canary", got)
+	}
+	if got := hash.ID(&corev3.Node{Id: "other"}); got != "fallback" {
+		t.Fatalf("hash(other) = %q, want fallback", got)
+	}
+	snap, err := srv.Cache().GetSnapshot("canary")
+	if err != nil {
+		t.Fatalf("GetSnapshot(canary) error = %v", err)
+	}
+	if _, ok := snap.GetResources(resource.ClusterType)["canary-cluster"]; !ok {
+		t.Fatal("canary snapshot is missing canary-cluster")
+	}
+
+	if err := srv.SetConfigs(map[string]*snapshot.XDSConfig{"fallback": base}); err != nil {
+		t.Fatalf("SetConfigs() error = %v", err)
+	}
+	if got := srv.Version("canary"); got != "" {
+		t.Fatalf("canary version after removal = %q, want empty", got)
+	}
+	if got := hash.ID(&corev3.Node{Id: "canary"}); got != "fallback" {
+		t.Fatalf("hash(canary) after removal = %q, want fallback", got)
+	}
+}
+
+func TestServerSetConfigsIsAllOrNothing(t *testing.T) {
+	srv := NewServer(Config{})
+	broken := &snapshot.XDSConfig{Listeners: []snapshot.Listener{{
+		Name:        "broken",
+		HTTPFilters: []snapshot.HTTPFilter{{Name: "envoy.filters.http.router"}, {Name: "x"}},
+	}}}
+	err := srv.SetConfigs(map[string]*snapshot.XDSConfig{
+		DefaultNodeID: parseConfig(t, greeterConfigYAML),
+		"broken":      broken,
+	})
+	if err == nil {
+		t.Fatal("SetConfigs() expected error for an invalid node config")
+	}
+	if got := srv.Version(DefaultNodeID); got != "" {
+		t.Fatalf("default version = %q, want no snapshot set", got)
+	}
+}
+
+func TestNewServerUsesConfiguredKeepalive(t *testing.T) {
+	srv := NewServer(Config{Keepalive: KeepaliveConfig{
+		MaxConnectionIdle:   5 * time.Minute,
+		Time:                30 * time.Second,
+		Timeout:             10 * time.Second,
+		MinTime:             15 * time.Second,
+		PermitWithoutStream: true,
+	}})
+	t.Cleanup(srv.grpcServer.Stop)
+
+	if got := srv.keepaliveParams.MaxConnectionIdle; got != 5*time.Minute {
+		t.Fatalf("MaxConnectionIdle = %v, want 5m", got)
+	}
+	if got := srv.keepaliveParams.Time; got != 30*time.Second {
+		t.Fatalf("Time = %v, want 30s", got)
+	}
+	if got := srv.keepaliveParams.Timeout; got != 10*time.Second {
+		t.Fatalf("Timeout = %v, want 10s", got)
+	}
+	if got := srv.enforcementPolicy.MinTime; got != 15*time.Second {
+		t.Fatalf("MinTime = %v, want 15s", got)
+	}
+	if !srv.enforcementPolicy.PermitWithoutStream {
+		t.Fatal("PermitWithoutStream = false, want true")
+	}
+}
+
+func TestServerClosesIdleConnections(t *testing.T) {
+	srv := NewServer(Config{Keepalive: KeepaliveConfig{MaxConnectionIdle: 100 * time.Millisecond}})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.grpcServer.Serve(lis) //nolint:errcheck
+	t.Cleanup(srv.grpcServer.Stop)
+
+	conn, err := grpc.NewClient(
+		lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection state = %v, want Ready", state)
+		}
+	}
+
+	// Without RPCs the server sends GOAWAY after MaxConnectionIdle and the
+	// client connection goes idle.
+	if !conn.WaitForStateChange(ctx, connectivity.Ready) {
+		t.Fatal("connection stayed Ready past MaxConnectionIdle")
+	}
+	if state := conn.GetState(); state != connectivity.Idle {
+		t.Fatalf("connection state after MaxConnectionIdle = %v, want Idle", state)
+	}
+}
+
+func TestNewServerRegistersReflectionWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			srv := NewServer(Config{Reflection: enabled})
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			go srv.grpcServer.Serve(lis) //nolint:errcheck
+			t.Cleanup(srv.grpcServer.Stop)
+
+			conn, err := grpc.NewClient(
+				lis.Addr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client := reflectionpb.NewServerReflectionClient(conn)
+			stream, err := client.ServerReflectionInfo(ctx)
+			if err != nil {
+				t.Fatalf("ServerReflectionInfo() error = %v", err)
+			}
+			if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+			}); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			resp, err := stream.Recv()
+			if !enabled {
+				if status.Code(err) != codes.Unimplemented {
+					t.Fatalf("Recv() error = %v, want Unimplemented", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Recv() error = %v", err)
+			}
+
+			var services []string
+			for _, service := range resp.GetListServicesResponse().GetService() {
+				services = append(services, service.GetName())
+			}
+			const ads = "envoy.service.discovery.v3.AggregatedDiscoveryService"
+			if !slices.Contains(services, ads) {
+				t.Fatalf("listed services = %v, want %s", services, ads)
+			}
+		})
+	}
+}
+
+func parseConfig(t *testing.T, content string) *snapshot.XDSConfig {
+	t.Helper()
+	var cfg snapshot.XDSConfig
+	if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	return &cfg
+}
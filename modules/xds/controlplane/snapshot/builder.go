@@ -0,0 +1,800 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	commonfault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/fault/v3"
+	fault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Builder builds xDS resource snapshots
+type Builder struct {
+	version string
+}
+
+// NewBuilder creates a new Builder
+func NewBuilder(version string) *Builder {
+	return &Builder{
+		version: version,
+	}
+}
+
+// BuildSnapshot builds a snapshot from the given configuration
+func (b *Builder) BuildSnapshot(config *XDSConfig) (*cache.Snapshot, error) {
+	clusters := b.buildClusters(config.Clusters)
+	endpoints := b.buildEndpoints(config.Endpoints)
+	listeners, err := b.buildListeners(config.Listeners)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := b.buildRoutes(config.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := cache.NewSnapshot(
+		b.version,
+		map[resource.Type][]types.Resource{
+			resource.ClusterType:  clusters,
+			resource.EndpointType: endpoints,
+			resource.ListenerType: listeners,
+			resource.RouteType:    routes,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func (b *Builder) buildClusters(configs []Cluster) []types.Resource {
+	var clusters []types.Resource
+
+	for _, cfg := range configs {
+		c := &cluster.Cluster{
+			Name:                 cfg.Name,
+			ConnectTimeout:       durationpb.New(ParseDuration(cfg.ConnectTimeout, 5*time.Second)),
+			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+			LbPolicy:             b.parseLbPolicy(cfg.LbPolicy),
+			EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
+				ServiceName: cfg.Name,
+				EdsConfig: &core.ConfigSource{
+					ResourceApiVersion: core.ApiVersion_V3,
+					ConfigSourceSpecifier: &core.ConfigSource_Ads{
+						Ads: &core.AggregatedConfigSource{},
+					},
+				},
+			},
+		}
+		if leastRequestP2C(cfg.LbPolicy) {
+			c.LbConfig = &cluster.Cluster_LeastRequestLbConfig_{
+				LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+					ChoiceCount: wrapperspb.UInt32(2),
+				},
+			}
+		}
+
+		if cfg.CircuitBreakers != nil {
+			c.CircuitBreakers = &cluster.CircuitBreakers{
+				Thresholds: []*cluster.CircuitBreakers_Thresholds{
+					{
+						MaxConnections: &wrapperspb.UInt32Value{
+							Value: cfg.CircuitBreakers.MaxConnections,
+						},
+						MaxPendingRequests: &wrapperspb.UInt32Value{
+							Value: cfg.CircuitBreakers.MaxPendingRequests,
+						},
+						MaxRequests: &wrapperspb.UInt32Value{
+							Value: cfg.CircuitBreakers.MaxRequests,
+						},
+						MaxRetries: &wrapperspb.UInt32Value{
+							Value: cfg.CircuitBreakers.MaxRetries,
+						},
+					},
+				},
+			}
+		}
+
+		if cfg.OutlierDetection != nil {
+			c.OutlierDetection = buildOutlierDetection(cfg.OutlierDetection)
+		}
+
+		if cfg.RateLimiting != nil {
+			c.Metadata = buildRateLimitMetadata(cfg.RateLimiting)
+		}
+
+		if cfg.LbSubset != nil {
+			c.LbSubsetConfig = buildLbSubsetConfig(cfg.LbSubset)
+		}
+
+		if cfg.HealthCheck != nil {
+			c.HealthChecks = []*core.HealthCheck{buildHealthCheck(cfg.HealthCheck)}
+		}
+
+		if cfg.TLS != nil {
+			// Skip the cluster rather than fall back to plaintext.
+			socket, err := buildUpstreamTLSSocket(cfg.TLS)
+			if err != nil {
+				continue
+			}
+			c.TransportSocket = socket
+		}
+
+		clusters = append(clusters, c)
+	}
+
+	return clusters
+}
+
+func buildLbSubsetConfig(cfg *LbSubsetConfig) *cluster.Cluster_LbSubsetConfig {
+	subset := &cluster.Cluster_LbSubsetConfig{
+		FallbackPolicy: cluster.Cluster_LbSubsetConfig_NO_FALLBACK,
+	}
+	switch strings.ToLower(cfg.FallbackPolicy) {
+	case "any_endpoint":
+		subset.FallbackPolicy = cluster.Cluster_LbSubsetConfig_ANY_ENDPOINT
+	case "default_subset":
+		subset.FallbackPolicy = cluster.Cluster_LbSubsetConfig_DEFAULT_SUBSET
+		subset.DefaultSubset = stringStruct(cfg.DefaultSubset)
+	}
+	for _, keys := range cfg.Selectors {
+		subset.SubsetSelectors = append(subset.SubsetSelectors,
+			&cluster.Cluster_LbSubsetConfig_LbSubsetSelector{Keys: keys})
+	}
+	return subset
+}
+
+// lbMetadata writes values under the envoy.lb filter, the namespace subset
+// load balancing matches on. It returns nil for no values.
+func lbMetadata(values map[string]string) *core.Metadata {
+	if len(values) == 0 {
+		return nil
+	}
+	return &core.Metadata{FilterMetadata: map[string]*structpb.Struct{
+		"envoy.lb": stringStruct(values),
+	}}
+}
+
+func stringStruct(values map[string]string) *structpb.Struct {
+	fields := make(map[string]*structpb.Value, len(values))
+	for key, value := range values {
+		fields[key] = structpb.NewStringValue(value)
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// buildRateLimitMetadata writes the local token bucket under
+// yggdrasil.rate_limit and the rate limit service descriptor under
+// yggdrasil.global_rate_limit. A config with only global limiting gets no
+// local bucket.
+func buildRateLimitMetadata(rl *RateLimitingConfig) *core.Metadata {
+	metadata := &core.Metadata{FilterMetadata: map[string]*structpb.Struct{}}
+	if rl.Global == nil || rl.MaxTokens > 0 {
+		metadata.FilterMetadata["yggdrasil.rate_limit"] = &structpb.Struct{
+			Fields: map[string]*structpb.Value{
+				"max_tokens":      structpb.NewNumberValue(float64(rl.MaxTokens)),
+				"tokens_per_fill": structpb.NewNumberValue(float64(rl.TokensPerFill)),
+				"fill_interval": structpb.NewNumberValue(
+					ParseDuration(rl.FillInterval, time.Second).Seconds(),
+				),
+			},
+		}
+	}
+	if rl.Global != nil {
+		descriptors := make([]*structpb.Value, 0, len(rl.Global.Descriptors))
+		for _, entry := range rl.Global.Descriptors {
+			descriptors = append(descriptors, structpb.NewStructValue(&structpb.Struct{
+				Fields: map[string]*structpb.Value{
+					"key":    structpb.NewStringValue(entry.Key),
+					"header": structpb.NewStringValue(entry.Header),
+					"value":  structpb.NewStringValue(entry.Value),
+				},
+			}))
+		}
+		metadata.FilterMetadata["yggdrasil.global_rate_limit"] = &structpb.Struct{
+			Fields: map[string]*structpb.Value{
+				"domain":      structpb.NewStringValue(rl.Global.Domain),
+				"descriptors": structpb.NewListValue(&structpb.ListValue{Values: descriptors}),
+			},
+		}
+	}
+	return metadata
+}
+
+// buildOutlierDetection leaves zero-valued thresholds unset so the client
+// applies Envoy's defaults to them, as it would for any other control plane.
+func buildOutlierDetection(od *OutlierDetectionConfig) *cluster.OutlierDetection {
+	return &cluster.OutlierDetection{
+		Consecutive_5Xx:               optionalUInt32(od.Consecutive5xx),
+		ConsecutiveGatewayFailure:     optionalUInt32(od.ConsecutiveGatewayFailure),
+		ConsecutiveLocalOriginFailure: optionalUInt32(od.ConsecutiveLocalOriginFailure),
+		Interval: durationpb.New(
+			ParseDuration(od.Interval, 10*time.Second),
+		),
+		BaseEjectionTime: durationpb.New(
+			ParseDuration(od.BaseEjectionTime, 30*time.Second),
+		),
+		MaxEjectionTime: durationpb.New(
+			ParseDuration(od.MaxEjectionTime, 300*time.Second),
+		),
+		MaxEjectionPercent:             optionalUInt32(od.MaxEjectionPercent),
+		EnforcingConsecutive_5Xx:       optionalUInt32(od.EnforcingConsecutive5xx),
+		EnforcingSuccessRate:           optionalUInt32(od.EnforcingSuccessRate),
+		SuccessRateMinimumHosts:        optionalUInt32(od.SuccessRateMinimumHosts),
+		SuccessRateRequestVolume:       optionalUInt32(od.SuccessRateRequestVolume),
+		SuccessRateStdevFactor:         optionalUInt32(od.SuccessRateStdevFactor),
+		FailurePercentageThreshold:     optionalUInt32(od.FailurePercentageThreshold),
+		EnforcingFailurePercentage:     optionalUInt32(od.EnforcingFailurePercentage),
+		FailurePercentageMinimumHosts:  optionalUInt32(od.FailurePercentageMinimumHosts),
+		FailurePercentageRequestVolume: optionalUInt32(od.FailurePercentageRequestVolume),
+		SplitExternalLocalOriginErrors: od.SplitExternalLocalOriginErrors,
+	}
+}
+
+func optionalUInt32(value uint32) *wrapperspb.UInt32Value {
+	if value == 0 {
+		return nil
+	}
+	return wrapperspb.UInt32(value)
+}
+
+func buildUpstreamTLSSocket(cfg *UpstreamTLSConfig) (*core.TransportSocket, error) {
+	common := &tls.CommonTlsContext{AlpnProtocols: cfg.ALPN}
+	if cfg.CAFile != "" {
+		common.ValidationContextType = &tls.CommonTlsContext_ValidationContext{
+			ValidationContext: &tls.CertificateValidationContext{
+				TrustedCa: fileDataSource(cfg.CAFile),
+			},
+		}
+	}
+	if cfg.ClientCert != nil {
+		common.TlsCertificates = []*tls.TlsCertificate{{
+			CertificateChain: fileDataSource(cfg.ClientCert.CertFile),
+			PrivateKey:       fileDataSource(cfg.ClientCert.KeyFile),
+		}}
+	}
+
+	typed, err := anypb.New(&tls.UpstreamTlsContext{
+		CommonTlsContext: common,
+		Sni:              cfg.SNI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &core.TransportSocket{
+		Name:       "envoy.transport_sockets.tls",
+		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: typed},
+	}, nil
+}
+
+func fileDataSource(path string) *core.DataSource {
+	return &core.DataSource{Specifier: &core.DataSource_Filename{Filename: path}}
+}
+
+func buildHealthCheck(cfg *HealthCheckConfig) *core.HealthCheck {
+	unhealthy := cfg.UnhealthyThreshold
+	if unhealthy == 0 {
+		unhealthy = 3
+	}
+	healthy := cfg.HealthyThreshold
+	if healthy == 0 {
+		healthy = 2
+	}
+
+	hc := &core.HealthCheck{
+		Timeout:            durationpb.New(ParseDuration(cfg.Timeout, time.Second)),
+		Interval:           durationpb.New(ParseDuration(cfg.Interval, 10*time.Second)),
+		UnhealthyThreshold: &wrapperspb.UInt32Value{Value: unhealthy},
+		HealthyThreshold:   &wrapperspb.UInt32Value{Value: healthy},
+	}
+	switch cfg.Type {
+	case "grpc":
+		hc.HealthChecker = &core.HealthCheck_GrpcHealthCheck_{
+			GrpcHealthCheck: &core.HealthCheck_GrpcHealthCheck{
+				ServiceName: cfg.ServiceName,
+			},
+		}
+	default:
+		path := cfg.Path
+		if path == "" {
+			path = "/healthz"
+		}
+		hc.HealthChecker = &core.HealthCheck_HttpHealthCheck_{
+			HttpHealthCheck: &core.HealthCheck_HttpHealthCheck{Path: path},
+		}
+	}
+	return hc
+}
+
+func (b *Builder) buildEndpoints(configs []Endpoint) []types.Resource {
+	assignments := make(map[string]*endpoint.ClusterLoadAssignment)
+	order := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		assignment, ok := assignments[cfg.ClusterName]
+		if !ok {
+			assignment = &endpoint.ClusterLoadAssignment{ClusterName: cfg.ClusterName}
+			assignments[cfg.ClusterName] = assignment
+			order = append(order, cfg.ClusterName)
+		}
+
+		var lbEndpoints []*endpoint.LbEndpoint
+
+		for _, ep := range cfg.Endpoints {
+			weight := ep.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			lbEndpoints = append(lbEndpoints, &endpoint.LbEndpoint{
+				HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+					Endpoint: &endpoint.Endpoint{
+						Address: &core.Address{
+							Address: &core.Address_SocketAddress{
+								SocketAddress: &core.SocketAddress{
+									Protocol: core.SocketAddress_TCP,
+									Address:  ep.Address,
+									PortSpecifier: &core.SocketAddress_PortValue{
+										PortValue: ep.Port,
+									},
+								},
+							},
+						},
+					},
+				},
+				LoadBalancingWeight: wrapperspb.UInt32(weight),
+				Metadata:            lbMetadata(ep.Metadata),
+			})
+		}
+
+		group := &endpoint.LocalityLbEndpoints{
+			LbEndpoints: lbEndpoints,
+			Priority:    cfg.Priority,
+		}
+		if cfg.Locality != nil {
+			group.Locality = &core.Locality{
+				Region:  cfg.Locality.Region,
+				Zone:    cfg.Locality.Zone,
+				SubZone: cfg.Locality.SubZone,
+			}
+		}
+		assignment.Endpoints = append(assignment.Endpoints, group)
+	}
+
+	endpoints := make([]types.Resource, 0, len(order))
+	for _, clusterName := range order {
+		endpoints = append(endpoints, assignments[clusterName])
+	}
+
+	return endpoints
+}
+
+func (b *Builder) buildListeners(configs []Listener) ([]types.Resource, error) {
+	var listeners []types.Resource
+
+	for _, cfg := range configs {
+		httpFilters, err := buildHTTPFilters(cfg.HTTPFilters)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %w", cfg.Name, err)
+		}
+		manager := &hcm.HttpConnectionManager{
+			CodecType:  hcm.HttpConnectionManager_AUTO,
+			StatPrefix: "ingress_http",
+			RouteSpecifier: &hcm.HttpConnectionManager_Rds{
+				Rds: &hcm.Rds{
+					ConfigSource: &core.ConfigSource{
+						ResourceApiVersion: core.ApiVersion_V3,
+						ConfigSourceSpecifier: &core.ConfigSource_Ads{
+							Ads: &core.AggregatedConfigSource{},
+						},
+					},
+					RouteConfigName: b.getRouteConfigName(cfg),
+				},
+			},
+			HttpFilters: httpFilters,
+		}
+
+		pbst, err := anypb.New(manager)
+		if err != nil {
+			continue
+		}
+
+		l := &listener.Listener{
+			Name: cfg.Name,
+			Address: &core.Address{
+				Address: &core.Address_SocketAddress{
+					SocketAddress: &core.SocketAddress{
+						Protocol: core.SocketAddress_TCP,
+						Address:  cfg.Address,
+						PortSpecifier: &core.SocketAddress_PortValue{
+							PortValue: cfg.Port,
+						},
+					},
+				},
+			},
+			FilterChains: []*listener.FilterChain{
+				{
+					Filters: []*listener.Filter{
+						{
+							Name: "envoy.filters.network.http_connection_manager",
+							ConfigType: &listener.Filter_TypedConfig{
+								TypedConfig: pbst,
+							},
+						},
+					},
+				},
+			},
+		}
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+const (
+	httpFaultFilter            = "envoy.filters.http.fault"
+	httpHeaderToMetadataFilter = "envoy.filters.http.header_to_metadata"
+	httpRouterFilter           = "envoy.filters.http.router"
+)
+
+// buildHTTPFilters builds the configured filters followed by the router,
+// which must stay last.
+func buildHTTPFilters(configs []HTTPFilter) ([]*hcm.HttpFilter, error) {
+	filters := make([]*hcm.HttpFilter, 0, len(configs)+1)
+	for i, cfg := range configs {
+		var (
+			typed *anypb.Any
+			err   error
+		)
+		switch cfg.Name {
+		case httpRouterFilter:
+			if i != len(configs)-1 {
+				return nil, fmt.Errorf("%s must be the last http filter", httpRouterFilter)
+			}
+			continue
+		case httpFaultFilter:
+			if cfg.Fault == nil {
+				return nil, fmt.Errorf("%s requires fault config", httpFaultFilter)
+			}
+			typed, err = anypb.New(buildHTTPFault(cfg.Fault))
+		case httpHeaderToMetadataFilter:
+			if cfg.HeaderToMetadata == nil {
+				return nil, fmt.Errorf(
+					"%s requires headerToMetadata config",
+					httpHeaderToMetadataFilter,
+				)
+			}
+			typed, err = anypb.New(buildHeaderToMetadata(cfg.HeaderToMetadata))
+		default:
+			return nil, fmt.Errorf("unsupported http filter %q", cfg.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &hcm.HttpFilter{
+			Name:       cfg.Name,
+			ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: typed},
+		})
+	}
+	return append(filters, &hcm.HttpFilter{Name: httpRouterFilter}), nil
+}
+
+func buildHTTPFault(cfg *FaultFilterConfig) *fault.HTTPFault {
+	out := &fault.HTTPFault{}
+	if cfg.Delay != "" {
+		out.Delay = &commonfault.FaultDelay{
+			FaultDelaySecifier: &commonfault.FaultDelay_FixedDelay{
+				FixedDelay: durationpb.New(ParseDuration(cfg.Delay, 0)),
+			},
+			Percentage: hundredPercent(cfg.DelayPercent),
+		}
+	}
+	switch {
+	case cfg.AbortGRPCStatus != 0:
+		out.Abort = &fault.FaultAbort{
+			ErrorType:  &fault.FaultAbort_GrpcStatus{GrpcStatus: cfg.AbortGRPCStatus},
+			Percentage: hundredPercent(cfg.AbortPercent),
+		}
+	case cfg.AbortHTTPStatus != 0:
+		out.Abort = &fault.FaultAbort{
+			ErrorType:  &fault.FaultAbort_HttpStatus{HttpStatus: cfg.AbortHTTPStatus},
+			Percentage: hundredPercent(cfg.AbortPercent),
+		}
+	}
+	return out
+}
+
+func hundredPercent(numerator uint32) *typev3.FractionalPercent {
+	return &typev3.FractionalPercent{
+		Numerator:   numerator,
+		Denominator: typev3.FractionalPercent_HUNDRED,
+	}
+}
+
+func buildHeaderToMetadata(cfg *HeaderToMetadataConfig) *headertometadata.Config {
+	out := &headertometadata.Config{}
+	for _, rule := range cfg.Rules {
+		out.RequestRules = append(out.RequestRules, &headertometadata.Config_Rule{
+			Header: rule.Header,
+			OnHeaderPresent: &headertometadata.Config_KeyValuePair{
+				MetadataNamespace: rule.MetadataNamespace,
+				Key:               rule.Key,
+			},
+			Remove: rule.Remove,
+		})
+	}
+	return out
+}
+
+func (b *Builder) buildRoutes(configs []Route) ([]types.Resource, error) {
+	var routes []types.Resource
+
+	for _, cfg := range configs {
+		var virtualHosts []*route.VirtualHost
+
+		for _, vh := range cfg.VirtualHosts {
+			var routeMatches []*route.Route
+			for _, rm := range vh.Routes {
+				match := &route.RouteMatch{}
+
+				if rm.Match.Path != nil {
+					switch {
+					case rm.Match.Path.Prefix != "":
+						match.PathSpecifier = &route.RouteMatch_Prefix{
+							Prefix: rm.Match.Path.Prefix,
+						}
+					case rm.Match.Path.Path != "":
+						match.PathSpecifier = &route.RouteMatch_Path{
+							Path: rm.Match.Path.Path,
+						}
+					case rm.Match.Path.Suffix != "":
+						match.PathSpecifier = safeRegexPathSpecifier(
+							fmt.Sprintf(".*%s$", regexp.QuoteMeta(rm.Match.Path.Suffix)),
+						)
+					case rm.Match.Path.Contains != "":
+						match.PathSpecifier = safeRegexPathSpecifier(
+							fmt.Sprintf(".*%s.*", regexp.QuoteMeta(rm.Match.Path.Contains)),
+						)
+					case rm.Match.Path.Regex != "":
+						match.PathSpecifier = safeRegexPathSpecifier(rm.Match.Path.Regex)
+					}
+				}
+
+				var headers []*route.HeaderMatcher
+				for _, item := range rm.Match.Headers {
+					stringMatch := &matcher.StringMatcher{}
+					switch item.Pattern {
+					case "", "exact":
+						stringMatch.MatchPattern = &matcher.StringMatcher_Exact{
+							Exact: item.Value,
+						}
+					case "prefix":
+						stringMatch.MatchPattern = &matcher.StringMatcher_Prefix{
+							Prefix: item.Value,
+						}
+					case "suffix":
+						stringMatch.MatchPattern = &matcher.StringMatcher_Suffix{
+							Suffix: item.Value,
+						}
+					case "safeRegex":
+						stringMatch.MatchPattern = &matcher.StringMatcher_SafeRegex{
+							SafeRegex: &matcher.RegexMatcher{
+								Regex: item.Value,
+							},
+						}
+					case "contains":
+						stringMatch.MatchPattern = &matcher.StringMatcher_Contains{
+							Contains: item.Value,
+						}
+					}
+					headers = append(headers, &route.HeaderMatcher{
+						Name: item.Name,
+						HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+							StringMatch: stringMatch,
+						},
+					})
+				}
+				match.Headers = headers
+
+				action, err := buildRouteAction(rm.Route)
+				if err != nil {
+					return nil, fmt.Errorf("route %s: %w", cfg.Name, err)
+				}
+				r := &route.Route{
+					Match:  match,
+					Action: &route.Route_Route{Route: action},
+				}
+				if rm.Fault != nil {
+					typed, err := anypb.New(buildHTTPFault(rm.Fault))
+					if err != nil {
+						return nil, fmt.Errorf("route %s: fault: %w", cfg.Name, err)
+					}
+					r.TypedPerFilterConfig = map[string]*anypb.Any{httpFaultFilter: typed}
+				}
+				routeMatches = append(routeMatches, r)
+			}
+
+			virtualHosts = append(virtualHosts, &route.VirtualHost{
+				Name:    vh.Name,
+				Domains: vh.Domains,
+				Routes:  routeMatches,
+			})
+		}
+
+		rc := &route.RouteConfiguration{
+			Name:         cfg.Name,
+			VirtualHosts: virtualHosts,
+		}
+		routes = append(routes, rc)
+	}
+
+	return routes, nil
+}
+
+func buildRouteAction(cfg RouteAction) (*route.RouteAction, error) {
+	action := &route.RouteAction{MetadataMatch: lbMetadata(cfg.MetadataMatch)}
+	if cfg.WeightedClusters != nil && len(cfg.WeightedClusters.Clusters) > 0 {
+		weighted, err := buildWeightedClusters(cfg.WeightedClusters)
+		if err != nil {
+			return nil, err
+		}
+		action.ClusterSpecifier = &route.RouteAction_WeightedClusters{
+			WeightedClusters: weighted,
+		}
+		return action, nil
+	}
+
+	action.ClusterSpecifier = &route.RouteAction_Cluster{Cluster: cfg.Cluster}
+	return action, nil
+}
+
+// buildWeightedClusters builds a weighted cluster action. A zero weight
+// counts as 1. When TotalWeight is set and differs from the sum of the
+// weights, the weights are scaled to it by the largest remainder method.
+func buildWeightedClusters(cfg *WeightedRouteAction) (*route.WeightedCluster, error) {
+	weights := make([]uint64, len(cfg.Clusters))
+	seen := make(map[string]struct{}, len(cfg.Clusters))
+	sum := uint64(0)
+	for i, item := range cfg.Clusters {
+		if item.Name == "" {
+			return nil, fmt.Errorf("weighted cluster at index %d has no name", i)
+		}
+		if _, ok := seen[item.Name]; ok {
+			return nil, fmt.Errorf("duplicate weighted cluster %q", item.Name)
+		}
+		seen[item.Name] = struct{}{}
+		weights[i] = uint64(max(item.Weight, 1))
+		sum += weights[i]
+	}
+
+	total := uint64(cfg.TotalWeight)
+	if total == 0 {
+		total = sum
+	}
+	if sum > math.MaxUint32 {
+		return nil, fmt.Errorf("weighted cluster weights sum to %d, over the uint32 limit", sum)
+	}
+	if total != sum {
+		weights = scaleWeights(weights, sum, total)
+		for i, weight := range weights {
+			if weight == 0 {
+				return nil, fmt.Errorf(
+					"weight of cluster %q rounds to zero at total_weight %d",
+					cfg.Clusters[i].Name,
+					total,
+				)
+			}
+		}
+	}
+
+	clusters := make([]*route.WeightedCluster_ClusterWeight, 0, len(cfg.Clusters))
+	for i, item := range cfg.Clusters {
+		clusters = append(clusters, &route.WeightedCluster_ClusterWeight{
+			Name:   item.Name,
+			Weight: wrapperspb.UInt32(uint32(weights[i])),
+		})
+	}
+	return &route.WeightedCluster{
+		Clusters:    clusters,
+		TotalWeight: wrapperspb.UInt32(uint32(total)), //nolint:staticcheck
+	}, nil
+}
+
+// scaleWeights scales weights summing to sum so they sum to total, handing
+// the units lost to rounding to the largest fractional parts first.
+func scaleWeights(weights []uint64, sum, total uint64) []uint64 {
+	scaled := make([]uint64, len(weights))
+	remainders := make([]uint64, len(weights))
+	assigned := uint64(0)
+	for i, weight := range weights {
+		scaled[i] = weight * total / sum
+		remainders[i] = weight * total % sum
+		assigned += scaled[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for _, i := range order[:total-assigned] {
+		scaled[i]++
+	}
+	return scaled
+}
+
+func safeRegexPathSpecifier(pattern string) *route.RouteMatch_SafeRegex {
+	return &route.RouteMatch_SafeRegex{
+		SafeRegex: &matcher.RegexMatcher{Regex: pattern},
+	}
+}
+
+func (b *Builder) parseLbPolicy(policy string) cluster.Cluster_LbPolicy {
+	switch strings.ToUpper(policy) {
+	case "ROUND_ROBIN":
+		return cluster.Cluster_ROUND_ROBIN
+	case "LEAST_REQUEST", "LEAST_REQUEST_P2C":
+		return cluster.Cluster_LEAST_REQUEST
+	case "RING_HASH":
+		return cluster.Cluster_RING_HASH
+	case "RANDOM":
+		return cluster.Cluster_RANDOM
+	case "MAGLEV":
+		return cluster.Cluster_MAGLEV
+	default:
+		return cluster.Cluster_ROUND_ROBIN
+	}
+}
+
+// leastRequestP2C reports whether policy asks for least request with a choice
+// count of two, which the balancer reads as power-of-two-choices.
+func leastRequestP2C(policy string) bool {
+	return strings.ToUpper(policy) == "LEAST_REQUEST_P2C"
+}
+
+func (b *Builder) getRouteConfigName(l Listener) string {
+	for _, fc := range l.FilterChains {
+		for _, f := range fc.Filters {
+			if f.RouteConfigName != "" {
+				return f.RouteConfigName
+			}
+		}
+	}
+	return "local_route"
+}
@@ -0,0 +1,649 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	faultv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestBuildEndpointsUsesWeightsAndPriority(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildEndpoints([]Endpoint{{
+		ClusterName: "sample-cluster",
+		Priority:    2,
+		Locality: &Locality{
+			Region:  "cn",
+			Zone:    "sh",
+			SubZone: "a",
+		},
+		Endpoints: []EndpointAddress{
+			{Address: "127.0.0.1", Port: 56061, Weight: 3},
+			{Address: "127.0.0.1", Port: 56062, Weight: 7},
+		},
+	}})
+
+	if len(resources) != 1 {
+		t.Fatalf("resources len = %d, want 1", len(resources))
+	}
+
+	assignment, ok := resources[0].(*endpointv3.ClusterLoadAssignment)
+	if !ok {
+		t.Fatalf("resource type = %T, want *ClusterLoadAssignment", resources[0])
+	}
+	if len(assignment.Endpoints) != 1 {
+		t.Fatalf("locality endpoints len = %d, want 1", len(assignment.Endpoints))
+	}
+
+	group := assignment.Endpoints[0]
+	if group.Priority != 2 {
+		t.Fatalf("priority = %d, want 2", group.Priority)
+	}
+	if got := group.Locality.GetRegion(); got != "cn" {
+		t.Fatalf("region = %q, want cn", got)
+	}
+	if got := group.LbEndpoints[0].GetLoadBalancingWeight().GetValue(); got != 3 {
+		t.Fatalf("first endpoint weight = %d, want 3", got)
+	}
+	if got := group.LbEndpoints[1].GetLoadBalancingWeight().GetValue(); got != 7 {
+		t.Fatalf("second endpoint weight = %d, want 7", got)
+	}
+}
+
+func TestBuildRoutesUsesWeightedClustersAndHeaderMatch(t *testing.T) {
+	builder := NewBuilder("1")
+	resources, err := builder.buildRoutes([]Route{{
+		Name: "sample-route",
+		VirtualHosts: []VirtualHost{{
+			Name:    "sample",
+			Domains: []string{"*"},
+			Routes: []RouteMatch{{
+				Match: RouteMatchCondition{
+					Path: &PathMatchCondition{Prefix: "/"},
+					Headers: []HeaderMatchCondition{{
+						Name:    "x-release",
+						Pattern: "exact",
+						Value:   "canary",
+					}},
+				},
+				Route: RouteAction{
+					WeightedClusters: &WeightedRouteAction{
+						Clusters: []WeightedCluster{
+							{Name: "stable-cluster", Weight: 95},
+							{Name: "canary-cluster", Weight: 5},
+						},
+					},
+				},
+			}},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("buildRoutes() error = %v", err)
+	}
+
+	config, ok := resources[0].(*routev3.RouteConfiguration)
+	if !ok {
+		t.Fatalf("resource type = %T, want *RouteConfiguration", resources[0])
+	}
+
+	builtRoute := config.VirtualHosts[0].Routes[0]
+	weighted := builtRoute.GetRoute().GetWeightedClusters()
+	if weighted == nil {
+		t.Fatal("weighted clusters = nil, want populated action")
+	}
+	if got := weighted.GetTotalWeight().GetValue(); got != 100 {
+		t.Fatalf("total weight = %d, want 100", got)
+	}
+	if len(weighted.Clusters) != 2 {
+		t.Fatalf("cluster count = %d, want 2", len(weighted.Clusters))
+	}
+	if got := weighted.Clusters[0].GetName(); got != "stable-cluster" {
+		t.Fatalf("first cluster = %q, want stable-cluster", got)
+	}
+	if got := weighted.Clusters[1].GetWeight().GetValue(); got != 5 {
+		t.Fatalf("second cluster weight = %d, want 5", got)
+	}
+
+	headers := builtRoute.GetMatch().GetHeaders()
+	if len(headers) != 1 {
+		t.Fatalf("headers len = %d, want 1", len(headers))
+	}
+	if got := headers[0].GetStringMatch().GetExact(); got != "canary" {
+		t.Fatalf("header exact match = %q, want canary", got)
+	}
+}
+
+func TestBuildRouteActionWeightedClustersTotalWeight(t *testing.T) {
+	action, err := buildRouteAction(RouteAction{
+		WeightedClusters: &WeightedRouteAction{
+			Clusters: []WeightedCluster{
+				{Name: "stable-cluster", Weight: 70},
+				{Name: "canary-cluster", Weight: 30},
+			},
+			TotalWeight: 100,
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildRouteAction() error = %v", err)
+	}
+	weighted := action.GetWeightedClusters()
+	if got := weighted.GetTotalWeight().GetValue(); got != 100 {
+		t.Fatalf("total weight = %d, want 100", got)
+	}
+	if got := weighted.Clusters[0].GetWeight().GetValue(); got != 70 {
+		t.Fatalf("stable weight = %d, want 70", got)
+	}
+	if got := weighted.Clusters[1].GetWeight().GetValue(); got != 30 {
+		t.Fatalf("canary weight = %d, want 30", got)
+	}
+
+	action, err = buildRouteAction(RouteAction{
+		WeightedClusters: &WeightedRouteAction{
+			Clusters: []WeightedCluster{
+				{Name: "a", Weight: 1},
+				{Name: "b", Weight: 1},
+				{Name: "c", Weight: 1},
+			},
+			TotalWeight: 100,
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildRouteAction() normalize error = %v", err)
+	}
+	var got []uint32
+	for _, item := range action.GetWeightedClusters().Clusters {
+		got = append(got, item.GetWeight().GetValue())
+	}
+	if !slices.Equal(got, []uint32{34, 33, 33}) {
+		t.Fatalf("normalized weights = %v, want [34 33 33]", got)
+	}
+
+	for name, cfg := range map[string]WeightedRouteAction{
+		"duplicate": {Clusters: []WeightedCluster{{Name: "a"}, {Name: "a"}}},
+		"unnamed":   {Clusters: []WeightedCluster{{Weight: 1}}},
+		"rounds to zero": {
+			Clusters:    []WeightedCluster{{Name: "a", Weight: 99}, {Name: "b", Weight: 1}},
+			TotalWeight: 10,
+		},
+	} {
+		if _, err := buildRouteAction(RouteAction{WeightedClusters: &cfg}); err == nil {
+			t.Fatalf("buildRouteAction(%s) expected error", name)
+		}
+	}
+}
+
+func TestBuildRoutesFaultDecodesToRoute(t *testing.T) {
+	builder := NewBuilder("1")
+	resources, err := builder.buildRoutes([]Route{{
+		Name: "route-a",
+		VirtualHosts: []VirtualHost{{
+			Name:    "vh",
+			Domains: []string{"*"},
+			Routes: []RouteMatch{
+				{
+					Match: RouteMatchCondition{Path: &PathMatchCondition{Prefix: "/"}},
+					Route: RouteAction{Cluster: "cluster-a"},
+					Fault: &FaultFilterConfig{
+						Delay:           "20ms",
+						DelayPercent:    50,
+						AbortGRPCStatus: 14,
+						AbortPercent:    100,
+					},
+				},
+				{
+					Match: RouteMatchCondition{Path: &PathMatchCondition{Prefix: "/plain"}},
+					Route: RouteAction{Cluster: "cluster-a"},
+				},
+			},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("buildRoutes() error = %v", err)
+	}
+	routeAny, err := anypb.New(resources[0].(*routev3.RouteConfiguration))
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	events, err := xdsresource.DecodeDiscoveryResponse(
+		"type.googleapis.com/envoy.config.route.v3.RouteConfiguration",
+		[]*anypb.Any{routeAny},
+	)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("DecodeDiscoveryResponse() = %v, %v", events, err)
+	}
+	routes := events[0].Data.(*xdsresource.RouteSnapshot).Vhosts[0].Routes
+
+	want := xdsresource.FaultConfig{
+		Delay:           20 * time.Millisecond,
+		DelayPerMillion: 500000,
+		AbortGRPCStatus: 14,
+		AbortPerMillion: 1000000,
+	}
+	if got := routes[0].Fault; got == nil || *got != want {
+		t.Fatalf("route fault = %+v, want %+v", got, want)
+	}
+	if routes[1].Fault != nil {
+		t.Fatalf("route without fault decoded %+v", routes[1].Fault)
+	}
+}
+
+func TestBuildRoutesUsesSafeRegexForContainsAndSuffix(t *testing.T) {
+	builder := NewBuilder("1")
+	resources, err := builder.buildRoutes([]Route{{
+		Name: "sample-route",
+		VirtualHosts: []VirtualHost{{
+			Name:    "sample",
+			Domains: []string{"*"},
+			Routes: []RouteMatch{
+				{
+					Match: RouteMatchCondition{
+						Path: &PathMatchCondition{Contains: "/canary/"},
+					},
+					Route: RouteAction{Cluster: "canary-cluster"},
+				},
+				{
+					Match: RouteMatchCondition{
+						Path: &PathMatchCondition{Suffix: "/ready"},
+					},
+					Route: RouteAction{Cluster: "ready-cluster"},
+				},
+			},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("buildRoutes() error = %v", err)
+	}
+
+	config := resources[0].(*routev3.RouteConfiguration)
+	first := config.VirtualHosts[0].Routes[0].GetMatch().GetSafeRegex()
+	second := config.VirtualHosts[0].Routes[1].GetMatch().GetSafeRegex()
+
+	if first == nil || first.Regex != ".*/canary/.*" {
+		t.Fatalf("contains regex = %#v, want .*/canary/.*", first)
+	}
+	if second == nil || second.Regex != ".*/ready$" {
+		t.Fatalf("suffix regex = %#v, want .*/ready$", second)
+	}
+}
+
+func TestBuildClustersAddsGrpcHealthCheck(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{{
+		Name: "sample-cluster",
+		HealthCheck: &HealthCheckConfig{
+			Type:               "grpc",
+			ServiceName:        "sample.v1.Greeter",
+			Interval:           "5s",
+			Timeout:            "500ms",
+			UnhealthyThreshold: 4,
+		},
+	}})
+
+	c, ok := resources[0].(*clusterv3.Cluster)
+	if !ok {
+		t.Fatalf("resource type = %T, want *Cluster", resources[0])
+	}
+	if len(c.HealthChecks) != 1 {
+		t.Fatalf("health checks len = %d, want 1", len(c.HealthChecks))
+	}
+	hc := c.HealthChecks[0]
+	grpc := hc.GetGrpcHealthCheck()
+	if grpc == nil || grpc.GetServiceName() != "sample.v1.Greeter" {
+		t.Fatalf("grpc health check = %#v", hc.GetHealthChecker())
+	}
+	if hc.GetInterval().AsDuration() != 5*time.Second ||
+		hc.GetTimeout().AsDuration() != 500*time.Millisecond {
+		t.Fatalf("interval/timeout = %v/%v", hc.GetInterval(), hc.GetTimeout())
+	}
+	if hc.GetUnhealthyThreshold().GetValue() != 4 || hc.GetHealthyThreshold().GetValue() != 2 {
+		t.Fatalf("thresholds = %v/%v", hc.GetUnhealthyThreshold(), hc.GetHealthyThreshold())
+	}
+}
+
+func TestBuildClustersDefaultsToHTTPHealthCheck(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{
+		{Name: "plain"},
+		{Name: "checked", HealthCheck: &HealthCheckConfig{Path: "/ready"}},
+	})
+
+	if got := resources[0].(*clusterv3.Cluster).HealthChecks; len(got) != 0 {
+		t.Fatalf("cluster without healthCheck has %d health checks", len(got))
+	}
+	hc := resources[1].(*clusterv3.Cluster).HealthChecks[0]
+	if got := hc.GetHttpHealthCheck().GetPath(); got != "/ready" {
+		t.Fatalf("http health check path = %q, want /ready", got)
+	}
+}
+
+func TestBuildClustersOutlierAndRateLimitDecodeToPolicy(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{{
+		Name: "sample-cluster",
+		OutlierDetection: &OutlierDetectionConfig{
+			Consecutive5xx:     3,
+			BaseEjectionTime:   "10s",
+			MaxEjectionPercent: 50,
+		},
+		RateLimiting: &RateLimitingConfig{
+			MaxTokens:     100,
+			TokensPerFill: 10,
+			FillInterval:  "500ms",
+		},
+	}})
+	clusterAny, err := anypb.New(resources[0].(*clusterv3.Cluster))
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+
+	events, err := xdsresource.DecodeDiscoveryResponse(
+		"type.googleapis.com/envoy.config.cluster.v3.Cluster",
+		[]*anypb.Any{clusterAny},
+	)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("DecodeDiscoveryResponse() = %v, %v", events, err)
+	}
+	policy := events[0].Data.(*xdsresource.ClusterSnapshot).Policy
+
+	od := policy.OutlierDetection
+	if od == nil {
+		t.Fatal("outlier detection was not decoded")
+	}
+	if od.Consecutive5xx != 3 || od.BaseEjectionTime != 10*time.Second ||
+		od.MaxEjectionPercent != 50 {
+		t.Fatalf("outlier detection = %+v, want the configured values", od)
+	}
+	// Thresholds left out of the config get Envoy's defaults, so consecutive
+	// 5xx ejection is enforced without spelling out enforcingConsecutive5xx.
+	if od.EnforcingConsecutive5xx != 100 || od.ConsecutiveGatewayFailure != 5 ||
+		od.Interval != 10*time.Second || od.EnforcingFailurePercentage != 0 {
+		t.Fatalf("outlier detection = %+v, want Envoy defaults for unset fields", od)
+	}
+
+	rl := policy.RateLimiter
+	if rl == nil || rl.MaxTokens != 100 || rl.TokensPerFill != 10 ||
+		rl.FillInterval != 500*time.Millisecond {
+		t.Fatalf("rate limiter = %+v, want 100 tokens, 10 per 500ms", rl)
+	}
+}
+
+func TestBuildClustersGlobalRateLimitDecodesToPolicy(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{{
+		Name: "sample-cluster",
+		RateLimiting: &RateLimitingConfig{
+			Global: &GlobalRateLimitingConfig{
+				Domain: "orders",
+				Descriptors: []RateLimitDescriptorConfig{
+					{Key: "tenant", Header: "x-tenant"},
+					{Key: "service", Value: "orders"},
+				},
+			},
+		},
+	}})
+	clusterAny, err := anypb.New(resources[0].(*clusterv3.Cluster))
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	events, err := xdsresource.DecodeDiscoveryResponse(
+		"type.googleapis.com/envoy.config.cluster.v3.Cluster",
+		[]*anypb.Any{clusterAny},
+	)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("DecodeDiscoveryResponse() = %v, %v", events, err)
+	}
+	policy := events[0].Data.(*xdsresource.ClusterSnapshot).Policy
+
+	if policy.RateLimiter != nil {
+		t.Fatalf("rate limiter = %+v, want none without maxTokens", policy.RateLimiter)
+	}
+	want := &xdsresource.GlobalRateLimitConfig{
+		Domain: "orders",
+		Entries: []xdsresource.RateLimitDescriptorEntry{
+			{Key: "tenant", Header: "x-tenant"},
+			{Key: "service", Value: "orders"},
+		},
+	}
+	got := policy.GlobalRateLimit
+	if got == nil || got.Domain != want.Domain || !slices.Equal(got.Entries, want.Entries) {
+		t.Fatalf("global rate limit = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildSubsetLoadBalancingDecodes(t *testing.T) {
+	builder := NewBuilder("1")
+	decode := func(typeURL string, resource proto.Message) any {
+		t.Helper()
+		resourceAny, err := anypb.New(resource)
+		if err != nil {
+			t.Fatalf("anypb.New() error = %v", err)
+		}
+		events, err := xdsresource.DecodeDiscoveryResponse(typeURL, []*anypb.Any{resourceAny})
+		if err != nil || len(events) != 1 {
+			t.Fatalf("DecodeDiscoveryResponse(%s) = %v, %v", typeURL, events, err)
+		}
+		return events[0].Data
+	}
+
+	clusters := builder.buildClusters([]Cluster{{
+		Name: "sample-cluster",
+		LbSubset: &LbSubsetConfig{
+			FallbackPolicy: "default_subset",
+			DefaultSubset:  map[string]string{"version": "v1"},
+			Selectors:      [][]string{{"version"}},
+		},
+	}})
+	built := clusters[0].(*clusterv3.Cluster)
+	if got := built.GetLbSubsetConfig().GetSubsetSelectors(); len(got) != 1 {
+		t.Fatalf("subset selectors = %v, want one", got)
+	}
+	cds := decode("type.googleapis.com/envoy.config.cluster.v3.Cluster", built)
+	policy := cds.(*xdsresource.ClusterSnapshot).Policy
+	if policy.Subset == nil ||
+		policy.Subset.FallbackPolicy != xdsresource.SubsetDefaultSubset ||
+		!maps.Equal(policy.Subset.DefaultSubset, map[string]string{"version": "v1"}) {
+		t.Fatalf("subset = %+v, want default_subset version=v1", policy.Subset)
+	}
+
+	endpoints := builder.buildEndpoints([]Endpoint{{
+		ClusterName: "sample-cluster",
+		Locality:    &Locality{Zone: "sh"},
+		Endpoints: []EndpointAddress{{
+			Address:  "127.0.0.1",
+			Port:     8080,
+			Metadata: map[string]string{"version": "v2", "zone": "ignored"},
+		}},
+	}})
+	eds := decode(
+		"type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment",
+		endpoints[0].(*endpointv3.ClusterLoadAssignment),
+	).(*xdsresource.EDSSnapshot)
+	metadata := eds.Endpoints[0].Metadata
+	if metadata["version"] != "v2" || metadata["zone"] != "sh" {
+		t.Fatalf("endpoint metadata = %v, want version=v2 and the locality zone", metadata)
+	}
+
+	routes, err := builder.buildRoutes([]Route{{
+		Name: "route-a",
+		VirtualHosts: []VirtualHost{{
+			Name:    "vh",
+			Domains: []string{"*"},
+			Routes: []RouteMatch{{
+				Match: RouteMatchCondition{Path: &PathMatchCondition{Prefix: "/"}},
+				Route: RouteAction{
+					Cluster:       "sample-cluster",
+					MetadataMatch: map[string]string{"version": "v2"},
+				},
+			}},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("buildRoutes() error = %v", err)
+	}
+	rds := decode(
+		"type.googleapis.com/envoy.config.route.v3.RouteConfiguration",
+		routes[0].(*routev3.RouteConfiguration),
+	).(*xdsresource.RouteSnapshot)
+	match := rds.Vhosts[0].Routes[0].Action.MetadataMatch
+	if !maps.Equal(match, map[string]string{"version": "v2"}) {
+		t.Fatalf("route metadata match = %v, want version=v2", match)
+	}
+}
+
+func TestBuildClustersLeastRequestP2C(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{
+		{Name: "p2c", LbPolicy: "least_request_p2c"},
+		{Name: "scan", LbPolicy: "LEAST_REQUEST"},
+	})
+
+	p2c := resources[0].(*clusterv3.Cluster)
+	if p2c.GetLbPolicy() != clusterv3.Cluster_LEAST_REQUEST {
+		t.Fatalf("lb policy = %v, want LEAST_REQUEST", p2c.GetLbPolicy())
+	}
+	if got := p2c.GetLeastRequestLbConfig().GetChoiceCount().GetValue(); got != 2 {
+		t.Fatalf("choice count = %d, want 2", got)
+	}
+	if scan := resources[1].(*clusterv3.Cluster); scan.GetLeastRequestLbConfig() != nil {
+		t.Fatalf("LEAST_REQUEST has lb config %v, want none", scan.GetLeastRequestLbConfig())
+	}
+}
+
+func TestBuildClustersAddsUpstreamTLSTransportSocket(t *testing.T) {
+	builder := NewBuilder("1")
+	resources := builder.buildClusters([]Cluster{{
+		Name: "secure-cluster",
+		TLS: &UpstreamTLSConfig{
+			CAFile: "/etc/certs/ca.pem",
+			SNI:    "greeter.internal",
+			ALPN:   []string{"h2"},
+			ClientCert: &ClientCertConfig{
+				CertFile: "/etc/certs/client.pem",
+				KeyFile:  "/etc/certs/client-key.pem",
+			},
+		},
+	}})
+
+	c := resources[0].(*clusterv3.Cluster)
+	socket := c.GetTransportSocket()
+	if socket.GetName() != "envoy.transport_sockets.tls" {
+		t.Fatalf("transport socket name = %q, want envoy.transport_sockets.tls", socket.GetName())
+	}
+	var upstream tlsv3.UpstreamTlsContext
+	if err := socket.GetTypedConfig().UnmarshalTo(&upstream); err != nil {
+		t.Fatalf("unmarshal UpstreamTlsContext: %v", err)
+	}
+	if upstream.GetSni() != "greeter.internal" {
+		t.Fatalf("sni = %q, want greeter.internal", upstream.GetSni())
+	}
+	common := upstream.GetCommonTlsContext()
+	if len(common.GetAlpnProtocols()) != 1 || common.GetAlpnProtocols()[0] != "h2" {
+		t.Fatalf("alpn = %v, want [h2]", common.GetAlpnProtocols())
+	}
+	trustedCA := common.GetValidationContext().GetTrustedCa()
+	if got := trustedCA.GetFilename(); got != "/etc/certs/ca.pem" {
+		t.Fatalf("trusted ca = %q", got)
+	}
+	certs := common.GetTlsCertificates()
+	if len(certs) != 1 || certs[0].GetPrivateKey().GetFilename() != "/etc/certs/client-key.pem" {
+		t.Fatalf("tls certificates = %v", certs)
+	}
+}
+
+func TestBuildListenersInstallsFaultFilterBeforeRouter(t *testing.T) {
+	builder := NewBuilder("1")
+	resources, err := builder.buildListeners([]Listener{{
+		Name:    "sample",
+		Address: "0.0.0.0",
+		Port:    10000,
+		HTTPFilters: []HTTPFilter{
+			{
+				Name: "envoy.filters.http.header_to_metadata",
+				HeaderToMetadata: &HeaderToMetadataConfig{Rules: []HeaderToMetadataRule{
+					{Header: "x-tenant", MetadataNamespace: "envoy.lb", Key: "tenant"},
+				}},
+			},
+			{
+				Name:  "envoy.filters.http.fault",
+				Fault: &FaultFilterConfig{AbortHTTPStatus: 503, AbortPercent: 100},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("buildListeners() error = %v", err)
+	}
+
+	l := resources[0].(*listenerv3.Listener)
+	var manager hcmv3.HttpConnectionManager
+	typed := l.GetFilterChains()[0].GetFilters()[0].GetTypedConfig()
+	if err := typed.UnmarshalTo(&manager); err != nil {
+		t.Fatalf("unmarshal HttpConnectionManager: %v", err)
+	}
+	filters := manager.GetHttpFilters()
+	var names []string
+	for _, filter := range filters {
+		names = append(names, filter.GetName())
+	}
+	want := fmt.Sprint([]string{
+		"envoy.filters.http.header_to_metadata",
+		"envoy.filters.http.fault",
+		"envoy.filters.http.router",
+	})
+	if fmt.Sprint(names) != want {
+		t.Fatalf("http filters = %v, want %s", names, want)
+	}
+
+	var httpFault faultv3.HTTPFault
+	if err := filters[1].GetTypedConfig().UnmarshalTo(&httpFault); err != nil {
+		t.Fatalf("unmarshal HTTPFault: %v", err)
+	}
+	abort := httpFault.GetAbort()
+	if abort.GetHttpStatus() != 503 || abort.GetPercentage().GetNumerator() != 100 ||
+		abort.GetPercentage().GetDenominator() != typev3.FractionalPercent_HUNDRED {
+		t.Fatalf("fault abort = %v, want 503 at 100%%", abort)
+	}
+	if httpFault.GetDelay() != nil {
+		t.Fatalf("fault delay = %v, want none", httpFault.GetDelay())
+	}
+}
+
+func TestBuildListenersRejectsRouterBeforeOtherFilters(t *testing.T) {
+	builder := NewBuilder("1")
+	_, err := builder.BuildSnapshot(&XDSConfig{Listeners: []Listener{{
+		Name: "sample",
+		HTTPFilters: []HTTPFilter{
+			{Name: "envoy.filters.http.router"},
+			{Name: "envoy.filters.http.fault", Fault: &FaultFilterConfig{AbortHTTPStatus: 503}},
+		},
+	}}})
+	if err == nil {
+		t.Fatal("BuildSnapshot() error = nil, want router ordering error")
+	}
+}
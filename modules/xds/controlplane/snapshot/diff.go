@@ -0,0 +1,109 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ResourceDiff lists the resource names that changed between two configs
+type ResourceDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty reports whether the diff contains no changes
+func (d ResourceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// ConfigDiff holds per-resource-type changes between two xDS configs
+type ConfigDiff struct {
+	Clusters  ResourceDiff
+	Endpoints ResourceDiff
+	Listeners ResourceDiff
+	Routes    ResourceDiff
+}
+
+// Empty reports whether no resource type changed
+func (d ConfigDiff) Empty() bool {
+	return d.Clusters.Empty() && d.Endpoints.Empty() && d.Listeners.Empty() && d.Routes.Empty()
+}
+
+// DiffConfigs compares two xDS configs by resource name.
+// A nil previous config reports every resource in next as added.
+func DiffConfigs(prev, next *XDSConfig) ConfigDiff {
+	if prev == nil {
+		prev = &XDSConfig{}
+	}
+	if next == nil {
+		next = &XDSConfig{}
+	}
+
+	return ConfigDiff{
+		Clusters: diffResources(
+			indexResources(prev.Clusters, func(c Cluster) string { return c.Name }),
+			indexResources(next.Clusters, func(c Cluster) string { return c.Name }),
+		),
+		Endpoints: diffResources(
+			indexResources(prev.Endpoints, func(e Endpoint) string { return e.ClusterName }),
+			indexResources(next.Endpoints, func(e Endpoint) string { return e.ClusterName }),
+		),
+		Listeners: diffResources(
+			indexResources(prev.Listeners, func(l Listener) string { return l.Name }),
+			indexResources(next.Listeners, func(l Listener) string { return l.Name }),
+		),
+		Routes: diffResources(
+			indexResources(prev.Routes, func(r Route) string { return r.Name }),
+			indexResources(next.Routes, func(r Route) string { return r.Name }),
+		),
+	}
+}
+
+// indexResources groups resources by name; duplicates (such as several
+// endpoint groups for one cluster) are compared as a whole.
+func indexResources[T any](items []T, key func(T) string) map[string][]T {
+	index := make(map[string][]T, len(items))
+	for _, item := range items {
+		name := key(item)
+		index[name] = append(index[name], item)
+	}
+	return index
+}
+
+func diffResources[T any](prev, next map[string][]T) ResourceDiff {
+	var diff ResourceDiff
+	for name, item := range next {
+		old, ok := prev[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, name)
+		case !reflect.DeepEqual(old, item):
+			diff.Modified = append(diff.Modified, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
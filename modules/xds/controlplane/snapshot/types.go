@@ -12,9 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package snapshot builds go-control-plane snapshots of clusters, endpoints,
+// listeners and routes from a declarative, YAML-friendly configuration.
 package snapshot
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // XDSConfig holds the xDS configuration
 type XDSConfig struct {
@@ -22,6 +27,42 @@ type XDSConfig struct {
 	Endpoints []Endpoint `yaml:"endpoints"`
 	Listeners []Listener `yaml:"listeners"`
 	Routes    []Route    `yaml:"routes"`
+	// Nodes holds configurations served to specific node IDs instead of the
+	// top-level resources.
+	Nodes []NodeConfig `yaml:"nodes,omitempty"`
+}
+
+// NodeConfig holds the xDS configuration served to one node ID
+type NodeConfig struct {
+	NodeID    string `yaml:"nodeID"`
+	XDSConfig `yaml:",inline"`
+}
+
+// ByNode splits the configuration by node ID. The top-level resources are
+// served to defaultNodeID and every entry of Nodes to its own node ID.
+func (c *XDSConfig) ByNode(defaultNodeID string) (map[string]*XDSConfig, error) {
+	configs := map[string]*XDSConfig{
+		defaultNodeID: {
+			Clusters:  c.Clusters,
+			Endpoints: c.Endpoints,
+			Listeners: c.Listeners,
+			Routes:    c.Routes,
+		},
+	}
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		if node.NodeID == "" {
+			return nil, fmt.Errorf("node at index %d has no nodeID", i)
+		}
+		if _, ok := configs[node.NodeID]; ok {
+			return nil, fmt.Errorf("duplicate node %q", node.NodeID)
+		}
+		if len(node.Nodes) > 0 {
+			return nil, fmt.Errorf("node %q cannot declare nested nodes", node.NodeID)
+		}
+		configs[node.NodeID] = &node.XDSConfig
+	}
+	return configs, nil
 }
 
 // Cluster represents a cluster configuration
@@ -33,6 +74,19 @@ type Cluster struct {
 	CircuitBreakers  *CircuitBreakersConfig  `yaml:"circuitBreakers,omitempty"`
 	OutlierDetection *OutlierDetectionConfig `yaml:"outlierDetection,omitempty"`
 	RateLimiting     *RateLimitingConfig     `yaml:"rateLimiting,omitempty"`
+	HealthCheck      *HealthCheckConfig      `yaml:"healthCheck,omitempty"`
+	TLS              *UpstreamTLSConfig      `yaml:"tls,omitempty"`
+	LbSubset         *LbSubsetConfig         `yaml:"lbSubset,omitempty"`
+}
+
+// LbSubsetConfig holds subset load balancing configuration. Routes pick a
+// subset with their metadataMatch; FallbackPolicy ("no_fallback",
+// "any_endpoint" or "default_subset") applies when no endpoint matches.
+type LbSubsetConfig struct {
+	FallbackPolicy string            `yaml:"fallbackPolicy,omitempty"`
+	DefaultSubset  map[string]string `yaml:"defaultSubset,omitempty"`
+	// Selectors lists the metadata key sets Envoy builds subsets for.
+	Selectors [][]string `yaml:"selectors,omitempty"`
 }
 
 // CircuitBreakersConfig holds circuit breaker configuration
@@ -69,6 +123,52 @@ type RateLimitingConfig struct {
 	MaxTokens     uint32 `yaml:"maxTokens,omitempty"`
 	TokensPerFill uint32 `yaml:"tokensPerFill,omitempty"`
 	FillInterval  string `yaml:"fillInterval,omitempty"`
+	// Global sends each pick to the client's rate limit service first; the
+	// local bucket above only decides when that service fails.
+	Global *GlobalRateLimitingConfig `yaml:"global,omitempty"`
+}
+
+// GlobalRateLimitingConfig holds the rate limit service descriptor of a cluster
+type GlobalRateLimitingConfig struct {
+	Domain      string                      `yaml:"domain"`
+	Descriptors []RateLimitDescriptorConfig `yaml:"descriptors"`
+}
+
+// RateLimitDescriptorConfig is one descriptor entry; its value is taken from
+// the request header Header, or is the static Value when Header is empty.
+type RateLimitDescriptorConfig struct {
+	Key    string `yaml:"key"`
+	Header string `yaml:"header,omitempty"`
+	Value  string `yaml:"value,omitempty"`
+}
+
+// HealthCheckConfig holds active health check configuration
+type HealthCheckConfig struct {
+	// Type is "http" (default) or "grpc".
+	Type string `yaml:"type,omitempty"`
+	// Path is the HTTP health check path.
+	Path string `yaml:"path,omitempty"`
+	// ServiceName is the service sent in gRPC health check requests.
+	ServiceName        string `yaml:"serviceName,omitempty"`
+	Interval           string `yaml:"interval,omitempty"`
+	Timeout            string `yaml:"timeout,omitempty"`
+	UnhealthyThreshold uint32 `yaml:"unhealthyThreshold,omitempty"`
+	HealthyThreshold   uint32 `yaml:"healthyThreshold,omitempty"`
+}
+
+// UpstreamTLSConfig holds the TLS settings Envoy uses to reach a cluster's
+// endpoints
+type UpstreamTLSConfig struct {
+	CAFile     string            `yaml:"caFile,omitempty"`
+	SNI        string            `yaml:"sni,omitempty"`
+	ALPN       []string          `yaml:"alpn,omitempty"`
+	ClientCert *ClientCertConfig `yaml:"clientCert,omitempty"`
+}
+
+// ClientCertConfig holds the client certificate presented to upstreams
+type ClientCertConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
 }
 
 // Endpoint represents an endpoint configuration
@@ -84,6 +184,8 @@ type EndpointAddress struct {
 	Address string `yaml:"address"`
 	Port    uint32 `yaml:"port"`
 	Weight  uint32 `yaml:"weight,omitempty"`
+	// Metadata is written under envoy.lb for subset load balancing.
+	Metadata map[string]string `yaml:"metadata,omitempty"`
 }
 
 // Locality describes one endpoint locality group.
@@ -99,6 +201,39 @@ type Listener struct {
 	Address      string        `yaml:"address"`
 	Port         uint32        `yaml:"port"`
 	FilterChains []FilterChain `yaml:"filterChains"`
+	HTTPFilters  []HTTPFilter  `yaml:"httpFilters,omitempty"`
+}
+
+// HTTPFilter represents an HTTP filter installed ahead of the router. Name is
+// envoy.filters.http.fault, envoy.filters.http.header_to_metadata, or
+// envoy.filters.http.router, which may only appear last
+type HTTPFilter struct {
+	Name             string                  `yaml:"name"`
+	Fault            *FaultFilterConfig      `yaml:"fault,omitempty"`
+	HeaderToMetadata *HeaderToMetadataConfig `yaml:"headerToMetadata,omitempty"`
+}
+
+// FaultFilterConfig holds fault injection configuration; percentages are
+// out of 100
+type FaultFilterConfig struct {
+	Delay           string `yaml:"delay,omitempty"`
+	DelayPercent    uint32 `yaml:"delayPercent,omitempty"`
+	AbortHTTPStatus uint32 `yaml:"abortHttpStatus,omitempty"`
+	AbortGRPCStatus uint32 `yaml:"abortGrpcStatus,omitempty"`
+	AbortPercent    uint32 `yaml:"abortPercent,omitempty"`
+}
+
+// HeaderToMetadataConfig holds request header to metadata mapping rules
+type HeaderToMetadataConfig struct {
+	Rules []HeaderToMetadataRule `yaml:"rules"`
+}
+
+// HeaderToMetadataRule copies one request header into dynamic metadata
+type HeaderToMetadataRule struct {
+	Header            string `yaml:"header"`
+	MetadataNamespace string `yaml:"metadataNamespace,omitempty"`
+	Key               string `yaml:"key"`
+	Remove            bool   `yaml:"remove,omitempty"`
 }
 
 // FilterChain represents a filter chain
@@ -129,6 +264,9 @@ type VirtualHost struct {
 type RouteMatch struct {
 	Match RouteMatchCondition `yaml:"match"`
 	Route RouteAction         `yaml:"route"`
+	// Fault injects delays and aborts on the clients of this route, through
+	// the route's envoy.filters.http.fault typed_per_filter_config.
+	Fault *FaultFilterConfig `yaml:"fault,omitempty"`
 }
 
 // HeaderMatchCondition represents a header match condition
@@ -157,6 +295,9 @@ type RouteMatchCondition struct {
 type RouteAction struct {
 	Cluster          string               `yaml:"cluster,omitempty"`
 	WeightedClusters *WeightedRouteAction `yaml:"weighted_clusters,omitempty"`
+	// MetadataMatch routes only to endpoints with all of these envoy.lb
+	// metadata values.
+	MetadataMatch map[string]string `yaml:"metadata_match,omitempty"`
 }
 
 // WeightedCluster represents a weighted cluster
@@ -168,6 +309,9 @@ type WeightedCluster struct {
 // WeightedRouteAction represents a weighted route action
 type WeightedRouteAction struct {
 	Clusters []WeightedCluster `yaml:"clusters"`
+	// TotalWeight is the declared sum of the cluster weights. Weights that
+	// add up to a different sum are scaled to it; zero uses their sum.
+	TotalWeight uint32 `yaml:"total_weight,omitempty"`
 }
 
 // HeaderMatch represents a header match
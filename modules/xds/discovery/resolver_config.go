@@ -23,14 +23,20 @@ type (
 	ServerConfig = internalresolver.ServerConfig
 	// TLSConfig holds TLS configuration for xDS server connection.
 	TLSConfig = internalresolver.TLSConfig
+	// KeepaliveConfig holds the HTTP/2 keepalive of the ADS connection.
+	KeepaliveConfig = internalresolver.KeepaliveConfig
 	// NodeConfig holds the node identification information.
 	NodeConfig = internalresolver.NodeConfig
 	// Locality holds the node locality information.
 	Locality = internalresolver.Locality
+	// LocalityEnv holds the environment variable names read for node locality.
+	LocalityEnv = internalresolver.LocalityEnv
 	// HealthConfig holds health check configuration.
 	HealthConfig = internalresolver.HealthConfig
 	// RetryConfig holds retry configuration.
 	RetryConfig = internalresolver.RetryConfig
+	// NACK describes a discovery response rejected by the resolver.
+	NACK = internalresolver.NACK
 	// ResolverConfigLoader loads resolver config for a named resolver.
 	ResolverConfigLoader = internalresolver.ConfigLoader
 )
@@ -4,38 +4,61 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
-	"strconv"
-	"sync/atomic"
+	"sort"
+	"sync"
 	"syscall"
 	"time"
 
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"gopkg.in/yaml.v3"
 
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/controlplane"
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/controlplane/snapshot"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/examples/internal/controlplane/server"
-	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/examples/internal/controlplane/snapshot"
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/examples/internal/controlplane/watcher"
 )
 
 type Config struct {
 	Server struct {
-		Port   uint   `yaml:"port"`
-		NodeID string `yaml:"nodeID"`
+		Port      uint            `yaml:"port"`
+		NodeID    string          `yaml:"nodeID"`
+		Keepalive KeepaliveConfig `yaml:"keepalive"`
+		// Reflection registers gRPC server reflection for debugging with
+		// grpcurl. It is off by default.
+		Reflection bool `yaml:"reflection"`
 	} `yaml:"server"`
 	XDS struct {
 		WatchInterval string `yaml:"watchInterval"`
 	} `yaml:"xds"`
 }
 
-var snapshotVersion atomic.Uint64
+// KeepaliveConfig holds the control-plane gRPC keepalive settings.
+type KeepaliveConfig struct {
+	MaxConnectionIdle   string `yaml:"maxConnectionIdle"`
+	Time                string `yaml:"time"`
+	Timeout             string `yaml:"timeout"`
+	MinTime             string `yaml:"minTime"`
+	PermitWithoutStream bool   `yaml:"permitWithoutStream"`
+}
 
-type staticNodeHash string
+func (c KeepaliveConfig) serverConfig() controlplane.KeepaliveConfig {
+	return controlplane.KeepaliveConfig{
+		MaxConnectionIdle:   parseDuration(c.MaxConnectionIdle, 0),
+		Time:                parseDuration(c.Time, 0),
+		Timeout:             parseDuration(c.Timeout, 0),
+		MinTime:             parseDuration(c.MinTime, 0),
+		PermitWithoutStream: c.PermitWithoutStream,
+	}
+}
 
-func (h staticNodeHash) ID(*core.Node) string {
-	return string(h)
+// lastConfigs holds the config last served to each node, for diff logging.
+var lastConfigs = struct {
+	sync.Mutex
+	byNode map[string]*snapshot.XDSConfig
+}{
+	byNode: make(map[string]*snapshot.XDSConfig),
 }
 
 func Run(bootstrapPath, snapshotPath string) error {
@@ -58,17 +81,24 @@ func Run(bootstrapPath, snapshotPath string) error {
 		config.Server.Port,
 		"snapshot_key",
 		nodeID,
+		"reflection",
+		config.Server.Reflection,
 	)
 
-	snapshotCache := cache.NewSnapshotCache(false, staticNodeHash(nodeID), nil)
-	if err := loadAndUpdateSnapshot(snapshotPath, nodeID, snapshotCache); err != nil {
+	xdsServer := controlplane.NewServer(controlplane.Config{
+		DefaultNodeID: nodeID,
+		Keepalive:     config.Server.Keepalive.serverConfig(),
+		Reflection:    config.Server.Reflection,
+		Callbacks:     server.NewCallbacks(),
+	})
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
 		return err
 	}
 
 	watchInterval := parseDuration(config.XDS.WatchInterval, time.Second)
 	fw, err := watcher.NewFileWatcher(snapshotPath, func(filePath string) {
 		slog.Info("Reloading xDS snapshot", "path", filePath)
-		if err := loadAndUpdateSnapshot(filePath, nodeID, snapshotCache); err != nil {
+		if err := loadAndUpdateSnapshot(filePath, xdsServer); err != nil {
 			slog.Error("Reload snapshot failed", "error", err)
 		}
 	}, watchInterval)
@@ -79,11 +109,15 @@ func Run(bootstrapPath, snapshotPath string) error {
 
 	fw.Start()
 
-	xdsServer := server.NewServer(config.Server.Port, snapshotCache)
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", config.Server.Port))
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	slog.Info("xDS server listening", "port", config.Server.Port)
 
 	serverErr := make(chan error, 1)
 	go func() {
-		if err := xdsServer.Run(); err != nil {
+		if err := xdsServer.Serve(lis); err != nil {
 			serverErr <- err
 		}
 	}()
@@ -98,11 +132,10 @@ func Run(bootstrapPath, snapshotPath string) error {
 		return fmt.Errorf("run xDS server: %w", err)
 	}
 
-	xdsServer.Stop()
-
+	slog.Info("Stopping xDS server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	<-ctx.Done()
+	xdsServer.Stop(ctx)
 
 	return nil
 }
@@ -122,13 +155,13 @@ func loadConfig(filePath string) (*Config, error) {
 	return &config, nil
 }
 
+// loadAndUpdateSnapshot serves the top-level resources of the snapshot file
+// to the server's default node and every `nodes` entry to its own node ID.
+// Each node keeps an independent version counter, and a broken file leaves
+// every node on its previous snapshot.
 //
 //nolint:gosec // Example file paths come from explicit local CLI flags.
-func loadAndUpdateSnapshot(
-	filePath string,
-	nodeID string,
-	snapshotCache cache.SnapshotCache,
-) error {
+func loadAndUpdateSnapshot(filePath string, xdsServer *controlplane.Server) error {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("read xDS snapshot file: %w", err)
@@ -138,41 +171,93 @@ func loadAndUpdateSnapshot(
 	if err := yaml.Unmarshal(data, &xdsConfig); err != nil {
 		return fmt.Errorf("parse xDS snapshot: %w", err)
 	}
-
-	version := snapshotVersion.Add(1)
-	versionStr := strconv.FormatUint(version, 10)
-
-	builder := snapshot.NewBuilder(versionStr)
-	snap, err := builder.BuildSnapshot(&xdsConfig)
+	nodeConfigs, err := xdsConfig.ByNode(xdsServer.DefaultNode())
 	if err != nil {
-		return fmt.Errorf("build xDS snapshot: %w", err)
+		return fmt.Errorf("parse xDS snapshot: %w", err)
 	}
 
-	if err := snap.Consistent(); err != nil {
-		return fmt.Errorf("snapshot inconsistent: %w", err)
-	}
+	lastConfigs.Lock()
+	defer lastConfigs.Unlock()
 
-	if err := snapshotCache.SetSnapshot(context.Background(), nodeID, snap); err != nil {
+	if err := xdsServer.SetConfigs(nodeConfigs); err != nil {
 		return fmt.Errorf("set xDS snapshot: %w", err)
 	}
 
-	slog.Info(
-		"Updated xDS snapshot",
-		"version",
-		versionStr,
-		"clusters",
-		len(xdsConfig.Clusters),
-		"endpoints",
-		len(xdsConfig.Endpoints),
-		"listeners",
-		len(xdsConfig.Listeners),
-		"routes",
-		len(xdsConfig.Routes),
-	)
+	nodeIDs := make([]string, 0, len(nodeConfigs))
+	for id := range nodeConfigs {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	for _, id := range nodeIDs {
+		version := xdsServer.Version(id)
+		if prev := lastConfigs.byNode[id]; prev != nil {
+			logSnapshotDiff(id, version, snapshot.DiffConfigs(prev, nodeConfigs[id]))
+		}
+		lastConfigs.byNode[id] = nodeConfigs[id]
+
+		slog.Info(
+			"Updated xDS snapshot",
+			"node",
+			id,
+			"version",
+			version,
+			"clusters",
+			len(nodeConfigs[id].Clusters),
+			"endpoints",
+			len(nodeConfigs[id].Endpoints),
+			"listeners",
+			len(nodeConfigs[id].Listeners),
+			"routes",
+			len(nodeConfigs[id].Routes),
+		)
+	}
+
+	for id := range lastConfigs.byNode {
+		if _, ok := nodeConfigs[id]; !ok {
+			delete(lastConfigs.byNode, id)
+			slog.Info("Removed xDS snapshot", "node", id)
+		}
+	}
 
 	return nil
 }
 
+func logSnapshotDiff(nodeID, version string, diff snapshot.ConfigDiff) {
+	if diff.Empty() {
+		slog.Info("xDS snapshot unchanged", "node", nodeID, "version", version)
+		return
+	}
+
+	for _, item := range []struct {
+		kind string
+		diff snapshot.ResourceDiff
+	}{
+		{kind: "cluster", diff: diff.Clusters},
+		{kind: "endpoint", diff: diff.Endpoints},
+		{kind: "listener", diff: diff.Listeners},
+		{kind: "route", diff: diff.Routes},
+	} {
+		if item.diff.Empty() {
+			continue
+		}
+		slog.Info(
+			"xDS snapshot changed",
+			"node",
+			nodeID,
+			"version",
+			version,
+			"kind",
+			item.kind,
+			"added",
+			item.diff.Added,
+			"removed",
+			item.diff.Removed,
+			"modified",
+			item.diff.Modified,
+		)
+	}
+}
+
 func parseDuration(input string, fallback time.Duration) time.Duration {
 	if input == "" {
 		return fallback
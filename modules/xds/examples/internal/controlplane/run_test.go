@@ -0,0 +1,269 @@
+package controlplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/controlplane"
+)
+
+const baseSnapshotYAML = `
+clusters:
+  - name: "stable-cluster"
+endpoints:
+  - clusterName: "stable-cluster"
+    endpoints:
+      - address: "127.0.0.1"
+        port: 56051
+listeners:
+  - name: "stable"
+    filterChains:
+      - filters:
+          - name: "envoy.filters.network.http_connection_manager"
+            routeConfigName: "stable-route"
+  - name: "legacy"
+    filterChains:
+      - filters:
+          - name: "envoy.filters.network.http_connection_manager"
+            routeConfigName: "legacy-route"
+routes:
+  - name: "stable-route"
+    virtualHosts:
+      - name: "stable"
+        domains: ["*"]
+        routes:
+          - match:
+              path:
+                prefix: "/"
+            route:
+              cluster: "stable-cluster"
+  - name: "legacy-route"
+    virtualHosts:
+      - name: "legacy"
+        domains: ["*"]
+        routes:
+          - match:
+              path:
+                prefix: "/"
+            route:
+              cluster: "stable-cluster"
+`
+
+const updatedSnapshotYAML = `
+clusters:
+  - name: "stable-cluster"
+  - name: "canary-cluster"
+endpoints:
+  - clusterName: "stable-cluster"
+    endpoints:
+      - address: "127.0.0.1"
+        port: 56051
+  - clusterName: "canary-cluster"
+    endpoints:
+      - address: "127.0.0.1"
+        port: 56052
+listeners:
+  - name: "stable"
+    filterChains:
+      - filters:
+          - name: "envoy.filters.network.http_connection_manager"
+            routeConfigName: "stable-route"
+routes:
+  - name: "stable-route"
+    virtualHosts:
+      - name: "stable"
+        domains: ["*"]
+        routes:
+          - match:
+              path:
+                prefix: "/"
+            route:
+              cluster: "stable-cluster"
+`
+
+func TestLoadAndUpdateSnapshotLogsDiff(t *testing.T) {
+	resetLastConfigs(t)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.yaml")
+	xdsServer := controlplane.NewServer(controlplane.Config{DefaultNodeID: "test"})
+
+	writeSnapshot(t, snapshotPath, baseSnapshotYAML)
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
+		t.Fatalf("initial loadAndUpdateSnapshot() error = %v", err)
+	}
+	if got := diffRecords(t, &buf); len(got) != 0 {
+		t.Fatalf("initial load logged diff records %v, want none", got)
+	}
+
+	writeSnapshot(t, snapshotPath, updatedSnapshotYAML)
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
+		t.Fatalf("reload loadAndUpdateSnapshot() error = %v", err)
+	}
+
+	records := diffRecords(t, &buf)
+	assertDiffRecord(t, records["cluster"], "added", "canary-cluster")
+	assertDiffRecord(t, records["endpoint"], "added", "canary-cluster")
+	assertDiffRecord(t, records["listener"], "removed", "legacy")
+	assertDiffRecord(t, records["route"], "removed", "legacy-route")
+	if len(records) != 4 {
+		t.Fatalf("diff records = %v, want cluster/endpoint/listener/route only", records)
+	}
+}
+
+const multiNodeSnapshotYAML = `
+clusters:
+  - name: "default-cluster"
+endpoints:
+  - clusterName: "default-cluster"
+    endpoints:
+      - address: "127.0.0.1"
+        port: 56051
+nodes:
+  - nodeID: "node-a"
+    clusters:
+      - name: "a-cluster"
+    endpoints:
+      - clusterName: "a-cluster"
+        endpoints:
+          - address: "127.0.0.1"
+            port: 56052
+  - nodeID: "node-b"
+    clusters:
+      - name: "b-cluster"
+    endpoints:
+      - clusterName: "b-cluster"
+        endpoints:
+          - address: "127.0.0.1"
+            port: 56053
+`
+
+func TestLoadAndUpdateSnapshotPerNode(t *testing.T) {
+	resetLastConfigs(t)
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.yaml")
+	xdsServer := controlplane.NewServer(controlplane.Config{DefaultNodeID: "default"})
+	snapshotCache := xdsServer.Cache()
+
+	writeSnapshot(t, snapshotPath, multiNodeSnapshotYAML)
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
+		t.Fatalf("initial loadAndUpdateSnapshot() error = %v", err)
+	}
+	writeSnapshot(t, snapshotPath, strings.Replace(
+		multiNodeSnapshotYAML,
+		"port: 56053",
+		"port: 56054",
+		1,
+	))
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
+		t.Fatalf("reload loadAndUpdateSnapshot() error = %v", err)
+	}
+
+	for node, wantCluster := range map[string]string{
+		"default": "default-cluster",
+		"node-a":  "a-cluster",
+		"node-b":  "b-cluster",
+	} {
+		snap, err := snapshotCache.GetSnapshot(node)
+		if err != nil {
+			t.Fatalf("GetSnapshot(%q) error = %v", node, err)
+		}
+		clusters := snap.GetResources(resource.ClusterType)
+		if _, ok := clusters[wantCluster]; !ok || len(clusters) != 1 {
+			t.Fatalf("node %q clusters = %v, want only %q", node, clusters, wantCluster)
+		}
+		if got := snap.GetVersion(resource.ClusterType); got != "2" {
+			t.Fatalf("node %q version = %q, want 2", node, got)
+		}
+	}
+
+	writeSnapshot(t, snapshotPath, baseSnapshotYAML)
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err != nil {
+		t.Fatalf("shrink loadAndUpdateSnapshot() error = %v", err)
+	}
+	if _, err := snapshotCache.GetSnapshot("node-a"); err == nil {
+		t.Fatal("node-a snapshot kept after it was removed from the file")
+	}
+	snap, err := snapshotCache.GetSnapshot("default")
+	if err != nil {
+		t.Fatalf("GetSnapshot(default) error = %v", err)
+	}
+	if got := snap.GetVersion(resource.ClusterType); got != "3" {
+		t.Fatalf("default version = %q, want 3", got)
+	}
+}
+
+func TestLoadAndUpdateSnapshotRejectsDuplicateNode(t *testing.T) {
+	resetLastConfigs(t)
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.yaml")
+	xdsServer := controlplane.NewServer(controlplane.Config{DefaultNodeID: "default"})
+	snapshotCache := xdsServer.Cache()
+	writeSnapshot(t, snapshotPath, "nodes:\n  - nodeID: a\n  - nodeID: a\n")
+	if err := loadAndUpdateSnapshot(snapshotPath, xdsServer); err == nil {
+		t.Fatal("loadAndUpdateSnapshot() expected duplicate node error")
+	}
+	if _, err := snapshotCache.GetSnapshot("default"); err == nil {
+		t.Fatal("snapshot set for a file that failed to load")
+	}
+}
+
+func resetLastConfigs(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		lastConfigs.Lock()
+		defer lastConfigs.Unlock()
+		clear(lastConfigs.byNode)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func writeSnapshot(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+}
+
+func diffRecords(t *testing.T, buf *bytes.Buffer) map[string]map[string]any {
+	t.Helper()
+	defer buf.Reset()
+
+	records := make(map[string]map[string]any)
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("decode log record: %v", err)
+		}
+		if record["msg"] != "xDS snapshot changed" {
+			continue
+		}
+		records[record["kind"].(string)] = record
+	}
+	return records
+}
+
+func assertDiffRecord(t *testing.T, record map[string]any, field, want string) {
+	t.Helper()
+	if record == nil {
+		t.Fatalf("missing diff record for %s %q", field, want)
+	}
+	values, _ := record[field].([]any)
+	if !slices.Contains(values, any(want)) {
+		t.Fatalf("record %v field %q = %v, want %q", record["kind"], field, values, want)
+	}
+}
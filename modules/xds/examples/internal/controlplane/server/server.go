@@ -16,27 +16,14 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net"
 	"sync/atomic"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
-	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
-	listenerservice "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
-	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
-	"google.golang.org/grpc"
 )
 
-const (
-	grpcMaxConcurrentStreams = 1000000
-)
-
-// Callbacks implements callbacks for xDS server
+// Callbacks logs the discovery streams of the example control plane
 type Callbacks struct {
 	signal   chan struct{}
 	fetches  int32
@@ -180,56 +167,3 @@ func (cb *Callbacks) OnStreamDeltaResponse(
 		len(resp.GetResources()),
 	)
 }
-
-// Server represents an xDS control plane server
-type Server struct {
-	grpcServer *grpc.Server
-	xdsServer  server.Server
-	cache      cache.SnapshotCache
-	port       uint
-}
-
-// NewServer creates a new xDS server
-func NewServer(port uint, cache cache.SnapshotCache) *Server {
-	callbacks := NewCallbacks()
-	xdsServer := server.NewServer(context.Background(), cache, callbacks)
-
-	grpcServer := grpc.NewServer(
-		grpc.MaxConcurrentStreams(grpcMaxConcurrentStreams),
-	)
-
-	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, xdsServer)
-	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, xdsServer)
-	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, xdsServer)
-	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, xdsServer)
-	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, xdsServer)
-
-	return &Server{
-		grpcServer: grpcServer,
-		xdsServer:  xdsServer,
-		cache:      cache,
-		port:       port,
-	}
-}
-
-// Run starts the xDS server
-func (s *Server) Run() error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
-	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
-	}
-
-	slog.Info("xDS server listening", "port", s.port)
-
-	if err := s.grpcServer.Serve(lis); err != nil {
-		return fmt.Errorf("failed to serve: %w", err)
-	}
-
-	return nil
-}
-
-// Stop gracefully stops the xDS server
-func (s *Server) Stop() {
-	slog.Info("Stopping xDS server...")
-	s.grpcServer.GracefulStop()
-}
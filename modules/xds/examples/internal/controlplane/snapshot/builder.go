@@ -1,485 +0,0 @@
-// Copyright 2022 The codesjoy Authors.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
-package snapshot
-
-import (
-	"fmt"
-	"regexp"
-	"time"
-
-	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
-	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
-	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
-	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
-	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-	"google.golang.org/protobuf/types/known/anypb"
-	"google.golang.org/protobuf/types/known/durationpb"
-	"google.golang.org/protobuf/types/known/structpb"
-	"google.golang.org/protobuf/types/known/wrapperspb"
-)
-
-// Builder builds xDS resource snapshots
-type Builder struct {
-	version string
-}
-
-// NewBuilder creates a new Builder
-func NewBuilder(version string) *Builder {
-	return &Builder{
-		version: version,
-	}
-}
-
-// BuildSnapshot builds a snapshot from the given configuration
-func (b *Builder) BuildSnapshot(config *XDSConfig) (*cache.Snapshot, error) {
-	clusters := b.buildClusters(config.Clusters)
-	endpoints := b.buildEndpoints(config.Endpoints)
-	listeners := b.buildListeners(config.Listeners)
-	routes := b.buildRoutes(config.Routes)
-
-	snapshot, err := cache.NewSnapshot(
-		b.version,
-		map[resource.Type][]types.Resource{
-			resource.ClusterType:  clusters,
-			resource.EndpointType: endpoints,
-			resource.ListenerType: listeners,
-			resource.RouteType:    routes,
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create snapshot: %w", err)
-	}
-
-	return snapshot, nil
-}
-
-func (b *Builder) buildClusters(configs []Cluster) []types.Resource {
-	var clusters []types.Resource
-
-	for _, cfg := range configs {
-		c := &cluster.Cluster{
-			Name:                 cfg.Name,
-			ConnectTimeout:       durationpb.New(ParseDuration(cfg.ConnectTimeout, 5*time.Second)),
-			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
-			LbPolicy:             b.parseLbPolicy(cfg.LbPolicy),
-			EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
-				ServiceName: cfg.Name,
-				EdsConfig: &core.ConfigSource{
-					ResourceApiVersion: core.ApiVersion_V3,
-					ConfigSourceSpecifier: &core.ConfigSource_Ads{
-						Ads: &core.AggregatedConfigSource{},
-					},
-				},
-			},
-		}
-
-		if cfg.CircuitBreakers != nil {
-			c.CircuitBreakers = &cluster.CircuitBreakers{
-				Thresholds: []*cluster.CircuitBreakers_Thresholds{
-					{
-						MaxConnections: &wrapperspb.UInt32Value{
-							Value: cfg.CircuitBreakers.MaxConnections,
-						},
-						MaxPendingRequests: &wrapperspb.UInt32Value{
-							Value: cfg.CircuitBreakers.MaxPendingRequests,
-						},
-						MaxRequests: &wrapperspb.UInt32Value{
-							Value: cfg.CircuitBreakers.MaxRequests,
-						},
-						MaxRetries: &wrapperspb.UInt32Value{
-							Value: cfg.CircuitBreakers.MaxRetries,
-						},
-					},
-				},
-			}
-		}
-
-		if cfg.OutlierDetection != nil {
-			od := cfg.OutlierDetection
-			c.OutlierDetection = &cluster.OutlierDetection{
-				Consecutive_5Xx: &wrapperspb.UInt32Value{Value: od.Consecutive5xx},
-				ConsecutiveGatewayFailure: &wrapperspb.UInt32Value{
-					Value: od.ConsecutiveGatewayFailure,
-				},
-				ConsecutiveLocalOriginFailure: &wrapperspb.UInt32Value{
-					Value: od.ConsecutiveLocalOriginFailure,
-				},
-				Interval: durationpb.New(
-					ParseDuration(od.Interval, 10*time.Second),
-				),
-				BaseEjectionTime: durationpb.New(
-					ParseDuration(od.BaseEjectionTime, 30*time.Second),
-				),
-				MaxEjectionTime: durationpb.New(
-					ParseDuration(od.MaxEjectionTime, 300*time.Second),
-				),
-				MaxEjectionPercent: &wrapperspb.UInt32Value{
-					Value: od.MaxEjectionPercent,
-				},
-				EnforcingConsecutive_5Xx: &wrapperspb.UInt32Value{
-					Value: od.EnforcingConsecutive5xx,
-				},
-				EnforcingSuccessRate: &wrapperspb.UInt32Value{
-					Value: od.EnforcingSuccessRate,
-				},
-				SuccessRateMinimumHosts: &wrapperspb.UInt32Value{
-					Value: od.SuccessRateMinimumHosts,
-				},
-				SuccessRateRequestVolume: &wrapperspb.UInt32Value{
-					Value: od.SuccessRateRequestVolume,
-				},
-				SuccessRateStdevFactor: &wrapperspb.UInt32Value{
-					Value: od.SuccessRateStdevFactor,
-				},
-				FailurePercentageThreshold: &wrapperspb.UInt32Value{
-					Value: od.FailurePercentageThreshold,
-				},
-				EnforcingFailurePercentage: &wrapperspb.UInt32Value{
-					Value: od.EnforcingFailurePercentage,
-				},
-				FailurePercentageMinimumHosts: &wrapperspb.UInt32Value{
-					Value: od.FailurePercentageMinimumHosts,
-				},
-				FailurePercentageRequestVolume: &wrapperspb.UInt32Value{
-					Value: od.FailurePercentageRequestVolume,
-				},
-				SplitExternalLocalOriginErrors: od.SplitExternalLocalOriginErrors,
-			}
-		}
-
-		if cfg.RateLimiting != nil {
-			rl := cfg.RateLimiting
-			c.Metadata = &core.Metadata{
-				FilterMetadata: map[string]*structpb.Struct{
-					"yggdrasil.rate_limit": {
-						Fields: map[string]*structpb.Value{
-							"max_tokens": {
-								Kind: &structpb.Value_NumberValue{
-									NumberValue: float64(rl.MaxTokens),
-								},
-							},
-							"tokens_per_fill": {
-								Kind: &structpb.Value_NumberValue{
-									NumberValue: float64(rl.TokensPerFill),
-								},
-							},
-							"fill_interval": {
-								Kind: &structpb.Value_NumberValue{
-									NumberValue: ParseDuration(
-										rl.FillInterval,
-										time.Second,
-									).Seconds(),
-								},
-							},
-						},
-					},
-				},
-			}
-		}
-
-		clusters = append(clusters, c)
-	}
-
-	return clusters
-}
-
-func (b *Builder) buildEndpoints(configs []Endpoint) []types.Resource {
-	assignments := make(map[string]*endpoint.ClusterLoadAssignment)
-	order := make([]string, 0, len(configs))
-	for _, cfg := range configs {
-		assignment, ok := assignments[cfg.ClusterName]
-		if !ok {
-			assignment = &endpoint.ClusterLoadAssignment{ClusterName: cfg.ClusterName}
-			assignments[cfg.ClusterName] = assignment
-			order = append(order, cfg.ClusterName)
-		}
-
-		var lbEndpoints []*endpoint.LbEndpoint
-
-		for _, ep := range cfg.Endpoints {
-			weight := ep.Weight
-			if weight == 0 {
-				weight = 1
-			}
-			lbEndpoints = append(lbEndpoints, &endpoint.LbEndpoint{
-				HostIdentifier: &endpoint.LbEndpoint_Endpoint{
-					Endpoint: &endpoint.Endpoint{
-						Address: &core.Address{
-							Address: &core.Address_SocketAddress{
-								SocketAddress: &core.SocketAddress{
-									Protocol: core.SocketAddress_TCP,
-									Address:  ep.Address,
-									PortSpecifier: &core.SocketAddress_PortValue{
-										PortValue: ep.Port,
-									},
-								},
-							},
-						},
-					},
-				},
-				LoadBalancingWeight: wrapperspb.UInt32(weight),
-			})
-		}
-
-		group := &endpoint.LocalityLbEndpoints{
-			LbEndpoints: lbEndpoints,
-			Priority:    cfg.Priority,
-		}
-		if cfg.Locality != nil {
-			group.Locality = &core.Locality{
-				Region:  cfg.Locality.Region,
-				Zone:    cfg.Locality.Zone,
-				SubZone: cfg.Locality.SubZone,
-			}
-		}
-		assignment.Endpoints = append(assignment.Endpoints, group)
-	}
-
-	endpoints := make([]types.Resource, 0, len(order))
-	for _, clusterName := range order {
-		endpoints = append(endpoints, assignments[clusterName])
-	}
-
-	return endpoints
-}
-
-func (b *Builder) buildListeners(configs []Listener) []types.Resource {
-	var listeners []types.Resource
-
-	for _, cfg := range configs {
-		manager := &hcm.HttpConnectionManager{
-			CodecType:  hcm.HttpConnectionManager_AUTO,
-			StatPrefix: "ingress_http",
-			RouteSpecifier: &hcm.HttpConnectionManager_Rds{
-				Rds: &hcm.Rds{
-					ConfigSource: &core.ConfigSource{
-						ResourceApiVersion: core.ApiVersion_V3,
-						ConfigSourceSpecifier: &core.ConfigSource_Ads{
-							Ads: &core.AggregatedConfigSource{},
-						},
-					},
-					RouteConfigName: b.getRouteConfigName(cfg),
-				},
-			},
-			HttpFilters: []*hcm.HttpFilter{
-				{
-					Name: "envoy.filters.http.router",
-				},
-			},
-		}
-
-		pbst, err := anypb.New(manager)
-		if err != nil {
-			continue
-		}
-
-		l := &listener.Listener{
-			Name: cfg.Name,
-			Address: &core.Address{
-				Address: &core.Address_SocketAddress{
-					SocketAddress: &core.SocketAddress{
-						Protocol: core.SocketAddress_TCP,
-						Address:  cfg.Address,
-						PortSpecifier: &core.SocketAddress_PortValue{
-							PortValue: cfg.Port,
-						},
-					},
-				},
-			},
-			FilterChains: []*listener.FilterChain{
-				{
-					Filters: []*listener.Filter{
-						{
-							Name: "envoy.filters.network.http_connection_manager",
-							ConfigType: &listener.Filter_TypedConfig{
-								TypedConfig: pbst,
-							},
-						},
-					},
-				},
-			},
-		}
-		listeners = append(listeners, l)
-	}
-
-	return listeners
-}
-
-func (b *Builder) buildRoutes(configs []Route) []types.Resource {
-	var routes []types.Resource
-
-	for _, cfg := range configs {
-		var virtualHosts []*route.VirtualHost
-
-		for _, vh := range cfg.VirtualHosts {
-			var routeMatches []*route.Route
-			for _, rm := range vh.Routes {
-				match := &route.RouteMatch{}
-
-				if rm.Match.Path != nil {
-					switch {
-					case rm.Match.Path.Prefix != "":
-						match.PathSpecifier = &route.RouteMatch_Prefix{
-							Prefix: rm.Match.Path.Prefix,
-						}
-					case rm.Match.Path.Path != "":
-						match.PathSpecifier = &route.RouteMatch_Path{
-							Path: rm.Match.Path.Path,
-						}
-					case rm.Match.Path.Suffix != "":
-						match.PathSpecifier = safeRegexPathSpecifier(
-							fmt.Sprintf(".*%s$", regexp.QuoteMeta(rm.Match.Path.Suffix)),
-						)
-					case rm.Match.Path.Contains != "":
-						match.PathSpecifier = safeRegexPathSpecifier(
-							fmt.Sprintf(".*%s.*", regexp.QuoteMeta(rm.Match.Path.Contains)),
-						)
-					case rm.Match.Path.Regex != "":
-						match.PathSpecifier = safeRegexPathSpecifier(rm.Match.Path.Regex)
-					}
-				}
-
-				var headers []*route.HeaderMatcher
-				for _, item := range rm.Match.Headers {
-					stringMatch := &matcher.StringMatcher{}
-					switch item.Pattern {
-					case "", "exact":
-						stringMatch.MatchPattern = &matcher.StringMatcher_Exact{
-							Exact: item.Value,
-						}
-					case "prefix":
-						stringMatch.MatchPattern = &matcher.StringMatcher_Prefix{
-							Prefix: item.Value,
-						}
-					case "suffix":
-						stringMatch.MatchPattern = &matcher.StringMatcher_Suffix{
-							Suffix: item.Value,
-						}
-					case "safeRegex":
-						stringMatch.MatchPattern = &matcher.StringMatcher_SafeRegex{
-							SafeRegex: &matcher.RegexMatcher{
-								Regex: item.Value,
-							},
-						}
-					case "contains":
-						stringMatch.MatchPattern = &matcher.StringMatcher_Contains{
-							Contains: item.Value,
-						}
-					}
-					headers = append(headers, &route.HeaderMatcher{
-						Name: item.Name,
-						HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
-							StringMatch: stringMatch,
-						},
-					})
-				}
-				match.Headers = headers
-
-				routeMatches = append(routeMatches, &route.Route{
-					Match: match,
-					Action: &route.Route_Route{
-						Route: buildRouteAction(rm.Route),
-					},
-				})
-			}
-
-			virtualHosts = append(virtualHosts, &route.VirtualHost{
-				Name:    vh.Name,
-				Domains: vh.Domains,
-				Routes:  routeMatches,
-			})
-		}
-
-		rc := &route.RouteConfiguration{
-			Name:         cfg.Name,
-			VirtualHosts: virtualHosts,
-		}
-		routes = append(routes, rc)
-	}
-
-	return routes
-}
-
-func buildRouteAction(cfg RouteAction) *route.RouteAction {
-	action := &route.RouteAction{}
-	if cfg.WeightedClusters != nil && len(cfg.WeightedClusters.Clusters) > 0 {
-		totalWeight := uint32(0)
-		clusters := make(
-			[]*route.WeightedCluster_ClusterWeight,
-			0,
-			len(cfg.WeightedClusters.Clusters),
-		)
-		for _, item := range cfg.WeightedClusters.Clusters {
-			weight := item.Weight
-			if weight == 0 {
-				weight = 1
-			}
-			totalWeight += weight
-			clusters = append(clusters, &route.WeightedCluster_ClusterWeight{
-				Name:   item.Name,
-				Weight: wrapperspb.UInt32(weight),
-			})
-		}
-		action.ClusterSpecifier = &route.RouteAction_WeightedClusters{
-			WeightedClusters: &route.WeightedCluster{
-				Clusters:    clusters,
-				TotalWeight: wrapperspb.UInt32(totalWeight), //nolint:staticcheck
-			},
-		}
-		return action
-	}
-
-	action.ClusterSpecifier = &route.RouteAction_Cluster{Cluster: cfg.Cluster}
-	return action
-}
-
-func safeRegexPathSpecifier(pattern string) *route.RouteMatch_SafeRegex {
-	return &route.RouteMatch_SafeRegex{
-		SafeRegex: &matcher.RegexMatcher{Regex: pattern},
-	}
-}
-
-func (b *Builder) parseLbPolicy(policy string) cluster.Cluster_LbPolicy {
-	switch policy {
-	case "ROUND_ROBIN":
-		return cluster.Cluster_ROUND_ROBIN
-	case "LEAST_REQUEST":
-		return cluster.Cluster_LEAST_REQUEST
-	case "RING_HASH":
-		return cluster.Cluster_RING_HASH
-	case "RANDOM":
-		return cluster.Cluster_RANDOM
-	case "MAGLEV":
-		return cluster.Cluster_MAGLEV
-	default:
-		return cluster.Cluster_ROUND_ROBIN
-	}
-}
-
-func (b *Builder) getRouteConfigName(l Listener) string {
-	for _, fc := range l.FilterChains {
-		for _, f := range fc.Filters {
-			if f.RouteConfigName != "" {
-				return f.RouteConfigName
-			}
-		}
-	}
-	return "local_route"
-}
@@ -17,11 +17,12 @@ package resolver
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -42,10 +44,13 @@ const (
 )
 
 type subscriptions struct {
-	lds []string
-	rds []string
-	cds []string
-	eds []string
+	lds  []string
+	rds  []string
+	cds  []string
+	eds  []string
+	srds []string
+	// vhds names on-demand virtual hosts, subscribed over the delta stream.
+	vhds []string
 }
 
 type typeWatchState struct {
@@ -54,20 +59,21 @@ type typeWatchState struct {
 }
 
 type adsClient struct {
-	cfg        Config
-	mu         sync.Mutex
-	ctx        context.Context
-	cancel     context.CancelFunc
-	conn       *grpc.ClientConn
-	stream     discoveryv3.AggregatedDiscoveryService_StreamAggregatedResourcesClient
-	node       *corev3.Node
-	sub        subscriptions
-	typeState  map[string]*typeWatchState
-	handle     func(xdsresource.DiscoveryEvent)
-	sendCh     chan *discoveryv3.DiscoveryRequest
-	retries    int
-	maxRetries int
-	closeOnce  sync.Once
+	cfg       Config
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	node      *corev3.Node
+	sub       subscriptions
+	order     []string
+	typeState map[string]*typeWatchState
+	handle    func(xdsresource.DiscoveryEvent)
+	sendCh    chan *discoveryv3.DiscoveryRequest
+	// deltaSendCh carries VHDS requests for the delta stream.
+	deltaSendCh chan *discoveryv3.DeltaDiscoveryRequest
+	retries     int
+	maxRetries  int
+	closeOnce   sync.Once
 }
 
 func newADSClient(cfg Config, handle func(xdsresource.DiscoveryEvent)) (*adsClient, error) {
@@ -78,6 +84,11 @@ func newADSClient(cfg Config, handle func(xdsresource.DiscoveryEvent)) (*adsClie
 		cancel()
 		return nil, err
 	}
+	order, err := subscriptionTypeURLs(cfg.SubscriptionOrder)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
 	return &adsClient{
 		cfg:        cfg,
@@ -85,10 +96,13 @@ func newADSClient(cfg Config, handle func(xdsresource.DiscoveryEvent)) (*adsClie
 		cancel:     cancel,
 		node:       node,
 		sub:        subscriptions{},
+		order:      order,
 		typeState:  make(map[string]*typeWatchState),
 		handle:     handle,
 		sendCh:     make(chan *discoveryv3.DiscoveryRequest, adsSendBufferSize),
 		maxRetries: maxADSRetries(cfg),
+
+		deltaSendCh: make(chan *discoveryv3.DeltaDiscoveryRequest, adsSendBufferSize),
 	}, nil
 }
 
@@ -108,17 +122,32 @@ func newADSNode(cfg Config) (*corev3.Node, error) {
 		Cluster:  cfg.Node.Cluster,
 		Metadata: metadata,
 	}
+	locality := &corev3.Locality{}
 	if cfg.Node.Locality != nil {
-		node.Locality = &corev3.Locality{
-			Region:  cfg.Node.Locality.Region,
-			Zone:    cfg.Node.Locality.Zone,
-			SubZone: cfg.Node.Locality.SubZone,
-		}
+		locality.Region = cfg.Node.Locality.Region
+		locality.Zone = cfg.Node.Locality.Zone
+		locality.SubZone = cfg.Node.Locality.SubZone
+	}
+	locality.Region = localityFromEnv(locality.Region, cfg.Node.LocalityEnv.Region)
+	locality.Zone = localityFromEnv(locality.Zone, cfg.Node.LocalityEnv.Zone)
+	locality.SubZone = localityFromEnv(locality.SubZone, cfg.Node.LocalityEnv.SubZone)
+	if cfg.Node.Locality != nil ||
+		locality.Region != "" || locality.Zone != "" || locality.SubZone != "" {
+		node.Locality = locality
 	}
 
 	return node, nil
 }
 
+// localityFromEnv keeps an explicitly configured value and otherwise reads
+// the named environment variable.
+func localityFromEnv(value, envName string) string {
+	if value != "" || envName == "" {
+		return value
+	}
+	return os.Getenv(envName)
+}
+
 func maxADSRetries(cfg Config) int {
 	if cfg.MaxRetries > 0 {
 		return cfg.MaxRetries
@@ -164,19 +193,14 @@ func (c *adsClient) run() {
 	}
 }
 
-func (c *adsClient) connect() error {
-	opts := []grpc.DialOption{
-		grpc.WithDefaultCallOptions(
-			grpc.MaxCallRecvMsgSize(1024*1024*10),
-			grpc.MaxCallSendMsgSize(1024*1024*10),
-		),
-	}
+// withKeepaliveParams is grpc.WithKeepaliveParams, replaceable in tests.
+var withKeepaliveParams = grpc.WithKeepaliveParams
 
-	transportCredentials, err := c.transportCredentials()
+func (c *adsClient) connect() error {
+	opts, err := c.dialOptions()
 	if err != nil {
 		return err
 	}
-	opts = append(opts, grpc.WithTransportCredentials(transportCredentials))
 
 	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.Server.Timeout)
 	defer cancel()
@@ -188,55 +212,77 @@ func (c *adsClient) connect() error {
 	}
 	defer conn.Close() //nolint:errcheck
 
+	// Canceling streamCtx tears the streams down. The send loops own their
+	// streams, so nothing else calls Send or CloseSend concurrently.
+	streamCtx, streamCancel := context.WithCancel(c.ctx)
+	defer streamCancel()
+
 	client := discoveryv3.NewAggregatedDiscoveryServiceClient(conn)
-	stream, err := client.StreamAggregatedResources(c.ctx)
+	stream, err := client.StreamAggregatedResources(streamCtx)
 	if err != nil {
 		return err
 	}
 
-	c.mu.Lock()
-	c.conn = conn
-	c.stream = stream
-	c.mu.Unlock()
-
 	c.resendSubscriptions()
 
-	errCh := make(chan error, 2)
+	errCh := make(chan error, 4)
 	go func() { errCh <- c.sendLoop(stream) }()
 	go func() { errCh <- c.watchResources(stream) }()
 
+	if c.cfg.EnableVHDS {
+		deltaStream, err := client.DeltaAggregatedResources(streamCtx)
+		if err != nil {
+			return err
+		}
+		c.resendVirtualHosts()
+		go func() { errCh <- c.sendDeltaLoop(deltaStream) }()
+		go func() { errCh <- c.watchVirtualHosts(deltaStream) }()
+	}
+
 	select {
 	case <-c.ctx.Done():
 		return nil
 	case err := <-errCh:
-		_ = stream.CloseSend()
 		return err
 	}
 }
 
+func (c *adsClient) dialOptions() ([]grpc.DialOption, error) {
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(1024*1024*10),
+			grpc.MaxCallSendMsgSize(1024*1024*10),
+		),
+	}
+
+	transportCredentials, err := c.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithTransportCredentials(transportCredentials))
+
+	if ka := c.cfg.Server.Keepalive; ka.Time > 0 {
+		opts = append(opts, withKeepaliveParams(keepalive.ClientParameters{
+			Time:                ka.Time,
+			Timeout:             ka.Timeout,
+			PermitWithoutStream: ka.PermitWithoutStream,
+		}))
+	}
+	return opts, nil
+}
+
 func (c *adsClient) transportCredentials() (credentials.TransportCredentials, error) {
 	if !c.cfg.Server.TLS.Enable {
 		return insecure.NewCredentials(), nil
 	}
 
-	//nolint:gosec // G402: MinVersion is intentionally not set to allow configuration flexibility.
-	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
-	if c.cfg.Server.TLS.CertFile != "" && c.cfg.Server.TLS.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(c.cfg.Server.TLS.CertFile, c.cfg.Server.TLS.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("load TLS cert pair: %w", err)
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
-	}
-	if c.cfg.Server.TLS.CAFile != "" {
-		caCert, err := os.ReadFile(c.cfg.Server.TLS.CAFile)
-		if err != nil {
-			return nil, fmt.Errorf("read CA file: %w", err)
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		tlsConfig.RootCAs = caCertPool
+	reloader, err := newTLSFileReloader(c.cfg.Server.TLS)
+	if err != nil {
+		return nil, err
 	}
+	//nolint:gosec // G402: server verification is performed by the reloader when a CA is set.
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	reloader.apply(tlsConfig)
 
 	return credentials.NewTLS(tlsConfig), nil
 }
@@ -263,7 +309,7 @@ func (c *adsClient) resendSubscriptions() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, typeURL := range subscriptionTypeURLs() {
+	for _, typeURL := range c.order {
 		if len(c.resourceNamesLocked(typeURL)) > 0 {
 			c.sendSubscriptionRequestLocked(typeURL)
 		}
@@ -294,10 +340,7 @@ func (c *adsClient) sendLoop(
 			return nil
 		case <-stream.Context().Done():
 			return stream.Context().Err()
-		case req, ok := <-c.sendCh:
-			if !ok {
-				return nil
-			}
+		case req := <-c.sendCh:
 			if err := stream.Send(req); err != nil {
 				return err
 			}
@@ -317,40 +360,54 @@ func (c *adsClient) watchResources(
 	}
 }
 
-func (c *adsClient) UpdateSubscriptions(lds, rds, cds, eds []string) {
+func (c *adsClient) UpdateSubscriptions(next subscriptions) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	slices.Sort(lds)
-	slices.Sort(rds)
-	slices.Sort(cds)
-	slices.Sort(eds)
+	slices.Sort(next.lds)
+	slices.Sort(next.rds)
+	slices.Sort(next.cds)
+	slices.Sort(next.eds)
+	slices.Sort(next.srds)
+	slices.Sort(next.vhds)
 
-	next := subscriptions{lds: lds, rds: rds, cds: cds, eds: eds}
 	if subscriptionsEqual(c.sub, next) {
 		return
 	}
 
+	prev := c.sub
 	c.sub = next
-	for _, typeURL := range subscriptionTypeURLs() {
+	for _, typeURL := range c.order {
+		// SRDS is only spoken with control planes that listeners point at it;
+		// an empty request would otherwise subscribe to every scope.
+		if typeURL == resource.ScopedRouteType && len(prev.srds) == 0 && len(next.srds) == 0 {
+			continue
+		}
 		c.sendSubscriptionRequestLocked(typeURL)
 	}
+	if c.cfg.EnableVHDS {
+		c.sendVirtualHostDiffLocked(prev.vhds, next.vhds)
+	}
 }
 
+// handleResponse applies every resource of resp that decodes. The response is
+// ACKed when all of them do and NACKed with the failures otherwise, so one
+// bad resource does not hold back the rest of the batch.
 func (c *adsClient) handleResponse(resp *discoveryv3.DiscoveryResponse) {
 	events, err := xdsresource.DecodeDiscoveryResponse(resp.TypeUrl, resp.Resources)
-	if err != nil {
-		log.Printf("[xds] failed to decode response: %v", err)
-		c.sendNACK(resp.TypeUrl, resp.VersionInfo, resp.Nonce, err.Error())
-		return
-	}
-
 	for _, event := range events {
 		if c.handle != nil {
 			c.handle(event)
 		}
 	}
 
+	if err != nil {
+		log.Printf("[xds] failed to decode response: %v", err)
+		c.reportNACK(resp, err)
+		c.sendNACK(resp.TypeUrl, resp.VersionInfo, resp.Nonce, err.Error())
+		return
+	}
+
 	c.mu.Lock()
 	state := c.watchStateLocked(resp.TypeUrl)
 	state.version = resp.VersionInfo
@@ -360,6 +417,33 @@ func (c *adsClient) handleResponse(resp *discoveryv3.DiscoveryResponse) {
 	c.sendACK(resp.TypeUrl, resp.VersionInfo, resp.Nonce)
 }
 
+// reportNACK calls OnNACK once for every resource err reports as failed, or
+// once with ResourceIndex -1 when the failure is not tied to a resource.
+func (c *adsClient) reportNACK(resp *discoveryv3.DiscoveryResponse, err error) {
+	if c.cfg.OnNACK == nil {
+		return
+	}
+
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, resourceErr := range errs {
+		nack := NACK{
+			TypeURL:       resp.TypeUrl,
+			Version:       resp.VersionInfo,
+			Nonce:         resp.Nonce,
+			ResourceIndex: -1,
+			Err:           resourceErr,
+		}
+		var decodeErr *xdsresource.DecodeError
+		if errors.As(resourceErr, &decodeErr) {
+			nack.ResourceIndex = decodeErr.Index
+		}
+		c.cfg.OnNACK(nack)
+	}
+}
+
 func (c *adsClient) sendACK(typeURL, version, nonce string) {
 	req := &discoveryv3.DiscoveryRequest{
 		Node:          c.node,
@@ -395,16 +479,46 @@ func subscriptionsEqual(a, b subscriptions) bool {
 	return slices.Equal(a.lds, b.lds) &&
 		slices.Equal(a.rds, b.rds) &&
 		slices.Equal(a.cds, b.cds) &&
-		slices.Equal(a.eds, b.eds)
+		slices.Equal(a.eds, b.eds) &&
+		slices.Equal(a.srds, b.srds) &&
+		slices.Equal(a.vhds, b.vhds)
 }
 
-func subscriptionTypeURLs() []string {
-	return []string{
+var subscriptionTypeNames = map[string]string{
+	"lds":  resource.ListenerType,
+	"rds":  resource.RouteType,
+	"cds":  resource.ClusterType,
+	"eds":  resource.EndpointType,
+	"srds": resource.ScopedRouteType,
+}
+
+// subscriptionTypeURLs resolves the configured subscription order to type
+// URLs. Types left out of order are appended in the default LDS, SRDS, RDS,
+// CDS, EDS order.
+func subscriptionTypeURLs(order []string) ([]string, error) {
+	typeURLs := make([]string, 0, len(subscriptionTypeNames))
+	for _, name := range order {
+		typeURL, ok := subscriptionTypeNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("xds: unknown subscription type %q", name)
+		}
+		if slices.Contains(typeURLs, typeURL) {
+			return nil, fmt.Errorf("xds: duplicate subscription type %q", name)
+		}
+		typeURLs = append(typeURLs, typeURL)
+	}
+	for _, typeURL := range []string{
 		resource.ListenerType,
+		resource.ScopedRouteType,
 		resource.RouteType,
 		resource.ClusterType,
 		resource.EndpointType,
+	} {
+		if !slices.Contains(typeURLs, typeURL) {
+			typeURLs = append(typeURLs, typeURL)
+		}
 	}
+	return typeURLs, nil
 }
 
 func (c *adsClient) resourceNamesLocked(typeURL string) []string {
@@ -417,6 +531,8 @@ func (c *adsClient) resourceNamesLocked(typeURL string) []string {
 		return c.sub.cds
 	case resource.EndpointType:
 		return c.sub.eds
+	case resource.ScopedRouteType:
+		return c.sub.srds
 	default:
 		return nil
 	}
@@ -440,15 +556,9 @@ func (c *adsClient) applyWatchStateLocked(
 	req.ResponseNonce = state.nonce
 }
 
+// Close cancels the client context. The running connection then tears down
+// its streams and closes the gRPC connection itself. The send channels stay
+// open because response handlers may still be queueing ACKs.
 func (c *adsClient) Close() {
-	c.closeOnce.Do(func() {
-		c.cancel()
-		if c.stream != nil {
-			_ = c.stream.CloseSend()
-		}
-		if c.conn != nil {
-			_ = c.conn.Close()
-		}
-		close(c.sendCh)
-	})
+	c.closeOnce.Do(c.cancel)
 }
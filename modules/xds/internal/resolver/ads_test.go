@@ -26,12 +26,18 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	clusterType "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	routeType "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/anypb"
 )
@@ -72,6 +78,41 @@ func (f *fakeADSStream) Context() context.Context {
 func (f *fakeADSStream) SendMsg(any) error { return nil }
 func (f *fakeADSStream) RecvMsg(any) error { return nil }
 
+func TestADSNodeLocalityFromEnv(t *testing.T) {
+	t.Setenv("REGION", "us-east-1")
+	t.Setenv("ZONE", "us-east-1a")
+
+	cfg := DefaultResolverConfig()
+	client, err := newADSClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	locality := client.node.GetLocality()
+	if locality.GetRegion() != "us-east-1" || locality.GetZone() != "us-east-1a" {
+		t.Fatalf("node locality = %#v, want region/zone from env", locality)
+	}
+
+	cfg.Node.Locality = &Locality{Zone: "configured"}
+	client, err = newADSClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	locality = client.node.GetLocality()
+	if locality.GetRegion() != "us-east-1" || locality.GetZone() != "configured" {
+		t.Fatalf("node locality = %#v, want configured zone and env region", locality)
+	}
+
+	cfg.Node.Locality = nil
+	cfg.Node.LocalityEnv = LocalityEnv{}
+	client, err = newADSClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	if client.node.Locality != nil {
+		t.Fatalf("node locality = %#v, want nil without env names", client.node.Locality)
+	}
+}
+
 func TestADSClientHelpers(t *testing.T) {
 	client, err := newADSClient(Config{
 		MaxRetries: 2,
@@ -102,7 +143,12 @@ func TestADSClientHelpers(t *testing.T) {
 	if len(client.sendCh) != 4 {
 		t.Fatalf("resendSubscriptions() queued %d requests, want 4", len(client.sendCh))
 	}
-	client.UpdateSubscriptions([]string{"b", "a"}, []string{"r"}, []string{"c"}, []string{"e"})
+	client.UpdateSubscriptions(subscriptions{
+		lds: []string{"b", "a"},
+		rds: []string{"r"},
+		cds: []string{"c"},
+		eds: []string{"e"},
+	})
 	if !subscriptionsEqual(client.sub, subscriptions{
 		lds: []string{"a", "b"},
 		rds: []string{"r"},
@@ -126,6 +172,69 @@ func TestADSClientHelpers(t *testing.T) {
 	client.Close()
 }
 
+func TestADSSubscriptionOrder(t *testing.T) {
+	drain := func(client *adsClient) []string {
+		var got []string
+		for len(client.sendCh) > 0 {
+			got = append(got, (<-client.sendCh).TypeUrl)
+		}
+		return got
+	}
+
+	client, err := newADSClient(Config{SubscriptionOrder: []string{"cds", "EDS"}}, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer client.Close()
+
+	want := []string{
+		resource.ClusterType,
+		resource.EndpointType,
+		resource.ListenerType,
+		resource.RouteType,
+	}
+	client.UpdateSubscriptions(subscriptions{
+		lds: []string{"l"},
+		rds: []string{"r"},
+		cds: []string{"c"},
+		eds: []string{"e"},
+	})
+	if got := drain(client); !slices.Equal(got, want) {
+		t.Fatalf("UpdateSubscriptions() sent %v, want %v", got, want)
+	}
+	client.resendSubscriptions()
+	if got := drain(client); !slices.Equal(got, want) {
+		t.Fatalf("resendSubscriptions() sent %v, want %v", got, want)
+	}
+
+	defaultClient, err := newADSClient(Config{}, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer defaultClient.Close()
+	defaultClient.UpdateSubscriptions(subscriptions{
+		lds: []string{"l"},
+		rds: []string{"r"},
+		cds: []string{"c"},
+		eds: []string{"e"},
+	})
+	want = []string{
+		resource.ListenerType,
+		resource.RouteType,
+		resource.ClusterType,
+		resource.EndpointType,
+	}
+	if got := drain(defaultClient); !slices.Equal(got, want) {
+		t.Fatalf("default order sent %v, want %v", got, want)
+	}
+
+	for _, order := range [][]string{{"sds"}, {"cds", "cds"}} {
+		if _, err := newADSClient(Config{SubscriptionOrder: order}, nil); err == nil {
+			t.Fatalf("newADSClient(order=%v) error = nil, want error", order)
+		}
+	}
+}
+
 func TestADSResponseHandling(t *testing.T) {
 	t.Run("send loop", func(t *testing.T) {
 		client, err := newADSClient(Config{
@@ -200,6 +309,137 @@ func TestADSResponseHandling(t *testing.T) {
 	})
 }
 
+func TestADSDecodeFailureReportsNACK(t *testing.T) {
+	var nacks []NACK
+	client, err := newADSClient(Config{
+		Node:   NodeConfig{ID: "node-a", Cluster: "cluster-a"},
+		OnNACK: func(nack NACK) { nacks = append(nacks, nack) },
+	}, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer client.Close()
+
+	validRoute, _ := anypb.New(&routeType.RouteConfiguration{Name: "route-a"})
+	client.handleResponse(&discoveryv3.DiscoveryResponse{
+		TypeUrl:     resource.RouteType,
+		VersionInfo: "v3",
+		Nonce:       "nonce-3",
+		Resources: []*anypb.Any{
+			validRoute,
+			{TypeUrl: resource.RouteType, Value: []byte("bad")},
+		},
+	})
+
+	if len(nacks) != 1 {
+		t.Fatalf("NACK callbacks = %d, want 1", len(nacks))
+	}
+	nack := nacks[0]
+	if nack.TypeURL != resource.RouteType || nack.Version != "v3" || nack.Nonce != "nonce-3" {
+		t.Fatalf("NACK = %+v, want route type URL with v3/nonce-3", nack)
+	}
+	if nack.ResourceIndex != 1 {
+		t.Fatalf("NACK.ResourceIndex = %d, want 1", nack.ResourceIndex)
+	}
+	if nack.Err == nil || !strings.Contains(nack.Err.Error(), "unmarshal route") {
+		t.Fatalf("NACK.Err = %v, want unmarshal route error", nack.Err)
+	}
+
+	req := <-client.sendCh
+	if req.GetErrorDetail().GetMessage() != nack.Err.Error() {
+		t.Fatalf("NACK request detail = %q, want %q", req.GetErrorDetail().GetMessage(), nack.Err)
+	}
+}
+
+func TestADSPartialNACKAppliesValidResources(t *testing.T) {
+	var events []xdsresource.DiscoveryEvent
+	var nacks []NACK
+	client, err := newADSClient(Config{
+		Node:   NodeConfig{ID: "node-a", Cluster: "cluster-a"},
+		OnNACK: func(nack NACK) { nacks = append(nacks, nack) },
+	}, func(event xdsresource.DiscoveryEvent) {
+		events = append(events, event)
+	})
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer client.Close()
+
+	validCluster, _ := anypb.New(&clusterType.Cluster{Name: "cluster-good"})
+	client.handleResponse(&discoveryv3.DiscoveryResponse{
+		TypeUrl:     resource.ClusterType,
+		VersionInfo: "v4",
+		Nonce:       "nonce-4",
+		Resources: []*anypb.Any{
+			{TypeUrl: resource.ClusterType, Value: []byte("corrupt")},
+			validCluster,
+		},
+	})
+
+	if len(events) != 1 || events[0].Typ != xdsresource.ClusterAdded ||
+		events[0].Name != "cluster-good" {
+		t.Fatalf("applied events = %#v, want only cluster-good", events)
+	}
+	if len(nacks) != 1 || nacks[0].ResourceIndex != 0 {
+		t.Fatalf("NACK callbacks = %+v, want one for resource 0", nacks)
+	}
+	req := <-client.sendCh
+	if req.GetErrorDetail() == nil || req.ResponseNonce != "nonce-4" ||
+		!strings.Contains(req.GetErrorDetail().GetMessage(), "resource 0: unmarshal cluster") {
+		t.Fatalf("NACK request = %v, want error detail for resource 0", req)
+	}
+	if state := client.watchStateLocked(resource.ClusterType); state.version != "" {
+		t.Fatalf("accepted version = %q, want unchanged after NACK", state.version)
+	}
+}
+
+func TestADSDialOptionsIncludeKeepalive(t *testing.T) {
+	var captured []keepalive.ClientParameters
+	oldKeepalive := withKeepaliveParams
+	withKeepaliveParams = func(params keepalive.ClientParameters) grpc.DialOption {
+		captured = append(captured, params)
+		return oldKeepalive(params)
+	}
+	t.Cleanup(func() { withKeepaliveParams = oldKeepalive })
+
+	cfg := DecodeConfig(map[string]any{
+		"server": map[string]any{
+			"address": "127.0.0.1:18000",
+			"keepalive": map[string]any{
+				"time":                  "30s",
+				"timeout":               "5s",
+				"permit_without_stream": true,
+			},
+		},
+	})
+	client, err := newADSClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer client.Close()
+
+	opts, err := client.dialOptions()
+	if err != nil {
+		t.Fatalf("dialOptions() error = %v", err)
+	}
+	want := keepalive.ClientParameters{
+		Time:                30 * time.Second,
+		Timeout:             5 * time.Second,
+		PermitWithoutStream: true,
+	}
+	if len(captured) != 1 || captured[0] != want || len(opts) != 3 {
+		t.Fatalf("keepalive params = %+v with %d dial options, want %+v among 3",
+			captured, len(opts), want)
+	}
+
+	captured = nil
+	client.cfg.Server.Keepalive = KeepaliveConfig{}
+	if opts, err := client.dialOptions(); err != nil || len(opts) != 2 || len(captured) != 0 {
+		t.Fatalf("dialOptions() without keepalive = %d options, %v; keepalive %+v",
+			len(opts), err, captured)
+	}
+}
+
 func TestADSClientTransportCredentialsAndConnect(t *testing.T) {
 	client, err := newADSClient(DefaultResolverConfig(), nil)
 	if err != nil {
@@ -319,7 +559,6 @@ func TestADSClientStartAndInternalHelpers(t *testing.T) {
 		t.Fatalf("sendLoop() error = %v, want context.Canceled", err)
 	}
 
-	client.stream = &fakeADSStream{}
 	client.Close()
 	client.Close()
 }
@@ -15,6 +15,9 @@
 package resolver
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -25,17 +28,58 @@ type Config struct {
 	Server     ServerConfig      `mapstructure:"server"`
 	Node       NodeConfig        `mapstructure:"node"`
 	ServiceMap map[string]string `mapstructure:"service_map"`
-	Protocol   string            `mapstructure:"protocol"`
-	MaxRetries int               `mapstructure:"max_retries"`
-	Health     HealthConfig      `mapstructure:"health"`
-	Retry      RetryConfig       `mapstructure:"retry"`
+	// ServicePatterns map targets without a ServiceMap entry to listeners by
+	// glob or regex; the first matching pattern wins.
+	ServicePatterns []ServicePattern `mapstructure:"service_patterns"`
+	Protocol        string           `mapstructure:"protocol"`
+	MaxRetries      int              `mapstructure:"max_retries"`
+	Health          HealthConfig     `mapstructure:"health"`
+	Retry           RetryConfig      `mapstructure:"retry"`
+	// SubscriptionOrder lists the order ("lds", "srds", "rds", "cds", "eds")
+	// in which ADS subscription requests are sent. Omitted types follow in the
+	// default LDS, SRDS, RDS, CDS, EDS order.
+	SubscriptionOrder []string `mapstructure:"subscription_order"`
+	// EnableVHDS looks up virtual hosts missing from a route configuration
+	// on demand over a delta ADS stream, for route configurations that
+	// declare VHDS.
+	EnableVHDS bool `mapstructure:"enable_vhds"`
+	// OnNACK, when set, is called for every resource of a discovery response
+	// the resolver rejects, or once for the response when the failure is not
+	// tied to a resource. It runs on the ADS receive goroutine and must not
+	// block.
+	OnNACK func(NACK) `mapstructure:"-"`
+}
+
+// NACK describes a discovery response rejected by the resolver.
+type NACK struct {
+	TypeURL string
+	Version string
+	Nonce   string
+	// ResourceIndex is the position of the offending resource in the
+	// response, or -1 when the failure is not tied to one resource.
+	ResourceIndex int
+	Err           error
 }
 
 // ServerConfig holds the xDS server connection configuration.
 type ServerConfig struct {
-	Address string        `mapstructure:"address"`
+	Address   string          `mapstructure:"address"`
+	Timeout   time.Duration   `mapstructure:"timeout"`
+	TLS       TLSConfig       `mapstructure:"tls"`
+	Keepalive KeepaliveConfig `mapstructure:"keepalive"`
+}
+
+// KeepaliveConfig holds the HTTP/2 keepalive of the ADS connection, so a
+// stream silently dropped by a proxy is detected and reconnected. Keepalive
+// is off while Time is zero; gRPC raises a Time below 10s to 10s.
+type KeepaliveConfig struct {
+	// Time is how long the connection may stay idle before a ping is sent.
+	Time time.Duration `mapstructure:"time"`
+	// Timeout is how long to wait for the ping ack before closing the
+	// connection; zero keeps the gRPC default of 20s.
 	Timeout time.Duration `mapstructure:"timeout"`
-	TLS     TLSConfig     `mapstructure:"tls"`
+	// PermitWithoutStream also pings while no stream is open.
+	PermitWithoutStream bool `mapstructure:"permit_without_stream"`
 }
 
 // TLSConfig holds TLS configuration for xDS server connection.
@@ -44,6 +88,9 @@ type TLSConfig struct {
 	CertFile string `mapstructure:"cert_file"`
 	KeyFile  string `mapstructure:"key_file"`
 	CAFile   string `mapstructure:"ca_file"`
+	// CAReloadInterval is how often the CA file is checked for changes.
+	// The client cert pair is re-read on the next handshake after it changes.
+	CAReloadInterval time.Duration `mapstructure:"ca_reload_interval"`
 }
 
 // NodeConfig holds the node identification information.
@@ -52,6 +99,9 @@ type NodeConfig struct {
 	Cluster  string            `mapstructure:"cluster"`
 	Metadata map[string]string `mapstructure:"metadata"`
 	Locality *Locality         `mapstructure:"locality"`
+	// LocalityEnv names environment variables, usually filled from the
+	// Kubernetes downward API, that supply locality fields left empty above.
+	LocalityEnv LocalityEnv `mapstructure:"locality_env"`
 }
 
 // Locality holds the node locality information.
@@ -61,6 +111,14 @@ type Locality struct {
 	SubZone string `mapstructure:"sub_zone"`
 }
 
+// LocalityEnv holds the environment variable names read for node locality.
+// An empty name disables that field.
+type LocalityEnv struct {
+	Region  string `mapstructure:"region"`
+	Zone    string `mapstructure:"zone"`
+	SubZone string `mapstructure:"sub_zone"`
+}
+
 // HealthConfig holds health check configuration.
 type HealthConfig struct {
 	HealthyOnly    bool     `mapstructure:"healthy_only"`
@@ -73,6 +131,41 @@ type RetryConfig struct {
 	Backoff    time.Duration `mapstructure:"backoff"`
 }
 
+// defaultServerTimeout bounds dialing the xDS server when server.timeout is
+// not set.
+const defaultServerTimeout = 5 * time.Second
+
+// Validate reports every setting that would otherwise only fail once the ADS
+// stream connects: an empty server address, a non-positive server timeout, a
+// negative keepalive, and an empty node ID. NewResolver fills in a zero timeout before it
+// validates.
+func (c Config) Validate() error {
+	var errs []error
+	if strings.TrimSpace(c.Server.Address) == "" {
+		errs = append(errs, errors.New("xds: server.address is empty"))
+	}
+	if c.Server.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("xds: server.timeout must be positive, got %s",
+			c.Server.Timeout))
+	}
+	if c.Server.Keepalive.Time < 0 || c.Server.Keepalive.Timeout < 0 {
+		errs = append(errs, errors.New("xds: server.keepalive durations must not be negative"))
+	}
+	if strings.TrimSpace(c.Node.ID) == "" {
+		errs = append(errs, errors.New("xds: node.id is empty"))
+	}
+	return errors.Join(errs...)
+}
+
+// withDefaults returns cfg with unset settings that have a safe default
+// filled in.
+func (c Config) withDefaults() Config {
+	if c.Server.Timeout == 0 {
+		c.Server.Timeout = defaultServerTimeout
+	}
+	return c
+}
+
 // ConfigLoader loads resolver config for a named resolver.
 type ConfigLoader func(name string) Config
 
@@ -80,7 +173,7 @@ func defaultResolverConfig() Config {
 	return Config{
 		Server: ServerConfig{
 			Address: "127.0.0.1:18000",
-			Timeout: 5 * time.Second,
+			Timeout: defaultServerTimeout,
 			TLS: TLSConfig{
 				Enable: false,
 			},
@@ -89,6 +182,10 @@ func defaultResolverConfig() Config {
 			ID:       "yggdrasil-node",
 			Cluster:  "yggdrasil-cluster",
 			Metadata: map[string]string{},
+			LocalityEnv: LocalityEnv{
+				Region: "REGION",
+				Zone:   "ZONE",
+			},
 		},
 		ServiceMap: map[string]string{},
 		Protocol:   "grpc",
@@ -24,13 +24,14 @@ import (
 
 type xdsResolver struct {
 	cfg      Config
+	services *serviceMapper
 	core     *xdsCore
 	watchers map[string]map[yresolver.Client]struct{}
 }
 
 type adsSubscriptionClient interface {
 	Start() error
-	UpdateSubscriptions(lds, rds, cds, eds []string)
+	UpdateSubscriptions(sub subscriptions)
 	Close()
 }
 
@@ -44,8 +45,14 @@ type xdsCore struct {
 	routes    map[string]*xdsresource.RouteSnapshot
 	clusters  map[string]*xdsresource.ClusterSnapshot
 	endpoints map[string]*xdsresource.EDSSnapshot
-	onUpdate  func(string, yresolver.State)
-	ads       adsSubscriptionClient
+	scopes    map[string]*xdsresource.ScopedRouteSnapshot
+	// vhdsHosts holds the hosts looked up over VHDS, at most maxVHDSHosts;
+	// virtualHosts holds the answers by resource name.
+	vhdsHosts    map[string]*vhdsHost
+	vhdsSeq      uint64
+	virtualHosts map[string]*xdsresource.VirtualHost
+	onUpdate     func(string, yresolver.State)
+	ads          adsSubscriptionClient
 }
 
 type appInfo struct {
@@ -59,8 +66,17 @@ var adsClientFactory = func(
 	return newADSClient(cfg, handle)
 }
 
-// NewResolver creates a new xDS resolver.
+// NewResolver creates a new xDS resolver. cfg must pass Validate once its
+// defaults are applied.
 func NewResolver(_ string, cfg Config) (yresolver.Resolver, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	services, err := newServiceMapper(cfg)
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
 	core := &xdsCore{
@@ -72,10 +88,15 @@ func NewResolver(_ string, cfg Config) (yresolver.Resolver, error) {
 		routes:    make(map[string]*xdsresource.RouteSnapshot),
 		clusters:  make(map[string]*xdsresource.ClusterSnapshot),
 		endpoints: make(map[string]*xdsresource.EDSSnapshot),
+		scopes:    make(map[string]*xdsresource.ScopedRouteSnapshot),
+
+		vhdsHosts:    make(map[string]*vhdsHost),
+		virtualHosts: make(map[string]*xdsresource.VirtualHost),
 	}
 
 	instance := &xdsResolver{
 		cfg:      cfg,
+		services: services,
 		core:     core,
 		watchers: make(map[string]map[yresolver.Client]struct{}),
 	}
@@ -110,13 +131,10 @@ func (r *xdsResolver) AddWatch(target string, client yresolver.Client) error {
 	app.listeners[r.listenerName(target)] = true
 
 	if r.core.ads == nil {
-		ads, err := adsClientFactory(r.core.cfg, r.core.handleDiscoveryEvent)
+		ads, err := sharedADSClients.acquire(r.core.cfg, r.core.handleDiscoveryEvent)
 		if err != nil {
 			return err
 		}
-		if err := ads.Start(); err != nil {
-			return err
-		}
 		r.core.ads = ads
 	}
 
@@ -153,10 +171,7 @@ func (r *xdsResolver) notifyWatchers(target string, state yresolver.State) {
 }
 
 func (r *xdsResolver) listenerName(target string) string {
-	if listenerName, ok := r.cfg.ServiceMap[target]; ok {
-		return listenerName
-	}
-	return target
+	return r.services.listenerName(target)
 }
 
 func (c *xdsCore) ensureAppLocked(target string) *appInfo {
@@ -184,6 +199,10 @@ func (c *xdsCore) handleDiscoveryEvent(event xdsresource.DiscoveryEvent) {
 		c.clusters[event.Name] = event.Data.(*xdsresource.ClusterSnapshot)
 	case xdsresource.EndpointAdded:
 		c.endpoints[event.Name] = event.Data.(*xdsresource.EDSSnapshot)
+	case xdsresource.ScopedRouteAdded:
+		c.scopes[event.Name] = event.Data.(*xdsresource.ScopedRouteSnapshot)
+	case xdsresource.VirtualHostAdded, xdsresource.VirtualHostRemoved:
+		c.applyVirtualHost(event)
 	}
 
 	c.reconcileSubscriptions()
@@ -17,6 +17,8 @@ package resolver
 import (
 	"errors"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,6 +33,8 @@ type fakeADS struct {
 	rds     []string
 	cds     []string
 	eds     []string
+	srds    []string
+	vhds    []string
 	err     error
 }
 
@@ -39,17 +43,25 @@ func (f *fakeADS) Start() error {
 	return f.err
 }
 
-func (f *fakeADS) UpdateSubscriptions(lds, rds, cds, eds []string) {
-	f.lds = append([]string(nil), lds...)
-	f.rds = append([]string(nil), rds...)
-	f.cds = append([]string(nil), cds...)
-	f.eds = append([]string(nil), eds...)
+func (f *fakeADS) UpdateSubscriptions(sub subscriptions) {
+	f.lds = append([]string(nil), sub.lds...)
+	f.rds = append([]string(nil), sub.rds...)
+	f.cds = append([]string(nil), sub.cds...)
+	f.eds = append([]string(nil), sub.eds...)
+	f.srds = append([]string(nil), sub.srds...)
+	f.vhds = append([]string(nil), sub.vhds...)
 }
 
 func (f *fakeADS) Close() {
 	f.closed = true
 }
 
+// testServer and testNode pass Config.Validate in tests that fake ADS.
+var (
+	testServer = ServerConfig{Address: "xds.example:18000"}
+	testNode   = NodeConfig{ID: "test-node"}
+)
+
 type stateRecorder struct {
 	ch chan yresolver.State
 }
@@ -73,6 +85,8 @@ func TestResolverCoreSubscriptionsAndNotifications(t *testing.T) {
 	t.Cleanup(func() { adsClientFactory = oldFactory })
 
 	resolverAny, err := NewResolver("default", Config{
+		Server:     testServer,
+		Node:       testNode,
 		Protocol:   "grpc",
 		ServiceMap: map[string]string{"svc": "listener-1"},
 	})
@@ -235,11 +249,51 @@ func TestDecodeConfigDefaultsAndFallbacks(t *testing.T) {
 	}
 }
 
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultResolverConfig().Validate(); err != nil {
+		t.Fatalf("DefaultResolverConfig().Validate() error = %v", err)
+	}
+
+	missingAddress := DefaultResolverConfig()
+	missingAddress.Server.Address = " "
+	err := missingAddress.Validate()
+	if err == nil || !strings.Contains(err.Error(), "server.address is empty") {
+		t.Fatalf("Validate(missing address) error = %v, want server.address error", err)
+	}
+	if _, err := NewResolver("default", missingAddress); err == nil ||
+		!strings.Contains(err.Error(), "server.address is empty") {
+		t.Fatalf("NewResolver(missing address) error = %v, want server.address error", err)
+	}
+
+	zeroTimeout := DefaultResolverConfig()
+	zeroTimeout.Server.Timeout = 0
+	err = zeroTimeout.Validate()
+	if err == nil || !strings.Contains(err.Error(), "server.timeout must be positive, got 0s") {
+		t.Fatalf("Validate(zero timeout) error = %v, want server.timeout error", err)
+	}
+	resolverAny, err := NewResolver("default", zeroTimeout)
+	if err != nil {
+		t.Fatalf("NewResolver(zero timeout) error = %v, want default timeout", err)
+	}
+	if got := resolverAny.(*xdsResolver).cfg.Server.Timeout; got != defaultServerTimeout {
+		t.Fatalf("defaulted timeout = %v, want %v", got, defaultServerTimeout)
+	}
+
+	err = Config{Server: ServerConfig{Timeout: -time.Second}}.Validate()
+	for _, want := range []string{"server.address", "server.timeout", "node.id"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate(empty config) error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
 func TestResolverProviderAndListenerName(t *testing.T) {
 	var loaded string
 	provider := Provider(func(name string) Config {
 		loaded = name
 		return Config{
+			Server:     testServer,
+			Node:       testNode,
 			ServiceMap: map[string]string{"svc": "listener-a"},
 		}
 	})
@@ -281,7 +335,7 @@ func TestAddWatchErrorsAndMultipleWatchers(t *testing.T) {
 			return nil, expectedErr
 		}
 
-		resolverAny, err := NewResolver("default", Config{})
+		resolverAny, err := NewResolver("default", Config{Server: testServer, Node: testNode})
 		if err != nil {
 			t.Fatalf("NewResolver() error = %v", err)
 		}
@@ -305,7 +359,7 @@ func TestAddWatchErrorsAndMultipleWatchers(t *testing.T) {
 			return &fakeADS{err: expectedErr}, nil
 		}
 
-		resolverAny, err := NewResolver("default", Config{})
+		resolverAny, err := NewResolver("default", Config{Server: testServer, Node: testNode})
 		if err != nil {
 			t.Fatalf("NewResolver() error = %v", err)
 		}
@@ -329,7 +383,7 @@ func TestAddWatchErrorsAndMultipleWatchers(t *testing.T) {
 			return fake, nil
 		}
 
-		resolverAny, err := NewResolver("default", Config{})
+		resolverAny, err := NewResolver("default", Config{Server: testServer, Node: testNode})
 		if err != nil {
 			t.Fatalf("NewResolver() error = %v", err)
 		}
@@ -347,6 +401,7 @@ func TestAddWatchErrorsAndMultipleWatchers(t *testing.T) {
 		if err := instance.DelWatch("svc", recorderA); err != nil {
 			t.Fatalf("DelWatch(recorderA) error = %v", err)
 		}
+		t.Cleanup(func() { _ = instance.DelWatch("svc", recorderB) })
 
 		if fake.closed {
 			t.Fatal("ADS client was closed while another watcher still existed")
@@ -356,3 +411,155 @@ func TestAddWatchErrorsAndMultipleWatchers(t *testing.T) {
 		}
 	})
 }
+
+func TestResolverEndpointsUseClusterALPNProtocol(t *testing.T) {
+	core := &xdsCore{
+		cfg: Config{Protocol: xdsresource.ProtocolHTTP},
+		listeners: map[string]*xdsresource.ListenerSnapshot{
+			"listener-1": {Route: "route-1"},
+		},
+		routes: map[string]*xdsresource.RouteSnapshot{
+			"route-1": {Vhosts: []*xdsresource.VirtualHost{{
+				Name: "vh",
+				Routes: []*xdsresource.Route{
+					{Action: &xdsresource.RouteAction{Cluster: "tls-h2"}},
+					{Action: &xdsresource.RouteAction{Cluster: "plain"}},
+				},
+			}}},
+		},
+		clusters: map[string]*xdsresource.ClusterSnapshot{
+			"tls-h2": {Policy: xdsresource.ClusterPolicy{
+				ALPNProtocols: []string{xdsresource.ALPNHTTP2},
+			}},
+			"plain": {},
+		},
+		endpoints: map[string]*xdsresource.EDSSnapshot{
+			"tls-h2": {Endpoints: []*xdsresource.WeightedEndpoint{{
+				Cluster:  "tls-h2",
+				Endpoint: xdsresource.Endpoint{Address: "10.0.0.1", Port: 8443},
+			}}},
+			"plain": {Endpoints: []*xdsresource.WeightedEndpoint{{
+				Cluster:  "plain",
+				Endpoint: xdsresource.Endpoint{Address: "10.0.0.2", Port: 8080},
+			}}},
+		},
+	}
+
+	endpoints := core.buildResolverEndpoints(&appInfo{
+		listeners: map[string]bool{"listener-1": true},
+	})
+	protocols := make(map[string]string, len(endpoints))
+	for _, endpoint := range endpoints {
+		protocols[endpoint.GetAddress()] = endpoint.GetProtocol()
+	}
+	if got := protocols["10.0.0.1:8443"]; got != xdsresource.ProtocolGRPC {
+		t.Fatalf("h2 cluster endpoint protocol = %q, want grpc", got)
+	}
+	if got := protocols["10.0.0.2:8080"]; got != xdsresource.ProtocolHTTP {
+		t.Fatalf("plain cluster endpoint protocol = %q, want static http", got)
+	}
+}
+
+func TestResolverAttributesReportEDSReceived(t *testing.T) {
+	core := &xdsCore{
+		listeners: map[string]*xdsresource.ListenerSnapshot{
+			"listener-1": {Route: "route-1"},
+		},
+		routes: map[string]*xdsresource.RouteSnapshot{
+			"route-1": {Vhosts: []*xdsresource.VirtualHost{{
+				Name: "vh",
+				Routes: []*xdsresource.Route{
+					{Action: &xdsresource.RouteAction{Cluster: "empty"}},
+					{Action: &xdsresource.RouteAction{Cluster: "pending"}},
+				},
+			}}},
+		},
+		endpoints: map[string]*xdsresource.EDSSnapshot{
+			"empty": {},
+		},
+	}
+
+	attributes := core.buildResolverAttributes(&appInfo{
+		listeners: map[string]bool{"listener-1": true},
+	})
+	received, ok := attributes[xdsresource.AttributeEDSReceived].(map[string]bool)
+	if !ok {
+		t.Fatalf("EDS received attribute = %T, want map[string]bool",
+			attributes[xdsresource.AttributeEDSReceived])
+	}
+	if !received["empty"] || received["pending"] {
+		t.Fatalf("EDS received = %v, want empty received and pending not", received)
+	}
+}
+
+func TestResolverResolvesScopedRoutesFromSRDS(t *testing.T) {
+	fake := &fakeADS{}
+	core := &xdsCore{
+		apps: map[string]*appInfo{
+			"svc": {listeners: map[string]bool{"listener-1": true}},
+		},
+		listeners: map[string]*xdsresource.ListenerSnapshot{
+			"listener-1": {ScopedRoutes: &xdsresource.ScopedRoutesConfig{
+				KeyBuilder: &xdsresource.ScopeKeyBuilder{
+					Fragments: []*xdsresource.ScopeKeyFragment{{Header: "x-tenant"}},
+				},
+				SRDS: true,
+			}},
+		},
+		routes:    make(map[string]*xdsresource.RouteSnapshot),
+		clusters:  make(map[string]*xdsresource.ClusterSnapshot),
+		endpoints: make(map[string]*xdsresource.EDSSnapshot),
+		scopes:    make(map[string]*xdsresource.ScopedRouteSnapshot),
+		ads:       fake,
+	}
+
+	core.reconcileSubscriptions()
+	if !reflect.DeepEqual(fake.srds, []string{srdsWildcard}) || len(fake.rds) != 0 {
+		t.Fatalf("subscriptions srds=%v rds=%v, want SRDS wildcard only", fake.srds, fake.rds)
+	}
+
+	tenants := map[string]string{"blue": "cluster-blue", "green": "cluster-green"}
+	for tenant, cluster := range tenants {
+		core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+			Typ:  xdsresource.ScopedRouteAdded,
+			Name: "scope-" + tenant,
+			Data: &xdsresource.ScopedRouteSnapshot{
+				Name:        "scope-" + tenant,
+				Key:         []string{tenant},
+				RouteConfig: "route-" + tenant,
+			},
+		})
+		core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+			Typ:  xdsresource.RouteAdded,
+			Name: "route-" + tenant,
+			Data: &xdsresource.RouteSnapshot{Vhosts: []*xdsresource.VirtualHost{{
+				Name:   "vh-" + tenant,
+				Routes: []*xdsresource.Route{{Action: &xdsresource.RouteAction{Cluster: cluster}}},
+			}}},
+		})
+	}
+	if want := []string{"route-blue", "route-green"}; !sameStrings(fake.rds, want) {
+		t.Fatalf("rds subscriptions = %v, want %v", fake.rds, want)
+	}
+	if want := []string{"cluster-blue", "cluster-green"}; !sameStrings(fake.cds, want) {
+		t.Fatalf("cds subscriptions = %v, want %v", fake.cds, want)
+	}
+
+	attributes := core.buildResolverAttributes(core.apps["svc"])
+	tables, _ := attributes[xdsresource.AttributeScopedRoutes].([]*xdsresource.ScopedRouteTable)
+	vhosts := xdsresource.RequestVirtualHosts(nil, tables, map[string]string{"x-tenant": "green"})
+	action := xdsresource.MatchRoute(vhosts, "/pkg.Service/Method", nil)
+	if action == nil || action.Cluster != "cluster-green" {
+		t.Fatalf("scoped route action = %#v, want cluster-green", action)
+	}
+	clusters, _ := attributes[xdsresource.AttributeClusters].(map[string]xdsresource.ClusterPolicy)
+	if _, ok := clusters["cluster-blue"]; !ok || len(clusters) != 2 {
+		t.Fatalf("cluster attribute = %v, want both scoped clusters", clusters)
+	}
+}
+
+func sameStrings(got, want []string) bool {
+	got = append([]string(nil), got...)
+	sort.Strings(got)
+	return reflect.DeepEqual(got, want)
+}
@@ -0,0 +1,357 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+)
+
+// sharedADSClients lets resolvers in one process reuse a single ADS stream
+// per control plane and node identity.
+var sharedADSClients = newADSPool()
+
+type adsPool struct {
+	mu      sync.Mutex
+	entries map[string]*sharedADS
+}
+
+type sharedADS struct {
+	pool   *adsPool
+	key    string
+	client adsSubscriptionClient
+
+	mu      sync.Mutex
+	members map[*pooledADS]struct{}
+	// resources holds the latest event per subscribed resource. The control
+	// plane does not resend resources when the merged subscriptions are
+	// unchanged, so members subscribing to names another member already
+	// watches are replayed these instead.
+	resources map[resourceKey]xdsresource.DiscoveryEvent
+}
+
+// resourceKey identifies a cached resource by its subscription slot and name.
+type resourceKey struct {
+	slot int
+	name string
+}
+
+// pooledADS is one resolver's view of a shared ADS client. Subscriptions from
+// all members are merged before they reach the underlying stream.
+type pooledADS struct {
+	shared *sharedADS
+	handle func(xdsresource.DiscoveryEvent)
+	onNACK func(NACK)
+	sub    subscriptions
+	// subSets holds sub as sets, so events reach only the members that
+	// subscribe to their resource.
+	subSets []map[string]struct{}
+
+	// deliverMu orders replayed and live events for this member; pending
+	// holds replayed events not delivered yet and is guarded by pendingMu.
+	deliverMu sync.Mutex
+	pendingMu sync.Mutex
+	pending   []xdsresource.DiscoveryEvent
+}
+
+func newADSPool() *adsPool {
+	return &adsPool{entries: make(map[string]*sharedADS)}
+}
+
+// acquire returns a member handle on the ADS client for cfg, creating and
+// starting the client when no resolver uses it yet.
+func (p *adsPool) acquire(
+	cfg Config,
+	handle func(xdsresource.DiscoveryEvent),
+) (adsSubscriptionClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := adsPoolKey(cfg)
+	shared, ok := p.entries[key]
+	if !ok {
+		shared = &sharedADS{
+			pool:      p,
+			key:       key,
+			members:   make(map[*pooledADS]struct{}),
+			resources: make(map[resourceKey]xdsresource.DiscoveryEvent),
+		}
+		clientCfg := cfg
+		clientCfg.OnNACK = shared.dispatchNACK
+		client, err := adsClientFactory(clientCfg, shared.dispatch)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Start(); err != nil {
+			return nil, err
+		}
+		shared.client = client
+		p.entries[key] = shared
+	}
+
+	member := &pooledADS{
+		shared:  shared,
+		handle:  handle,
+		onNACK:  cfg.OnNACK,
+		subSets: subscriptions{}.sets(),
+	}
+	shared.mu.Lock()
+	shared.members[member] = struct{}{}
+	shared.mu.Unlock()
+	return member, nil
+}
+
+func (p *adsPool) release(member *pooledADS) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	shared := member.shared
+	shared.mu.Lock()
+	if _, ok := shared.members[member]; !ok {
+		shared.mu.Unlock()
+		return
+	}
+	delete(shared.members, member)
+	remaining := len(shared.members)
+	if remaining > 0 {
+		shared.updateSubscriptionsLocked()
+	}
+	shared.mu.Unlock()
+
+	if remaining > 0 {
+		return
+	}
+	if p.entries[shared.key] == shared {
+		delete(p.entries, shared.key)
+	}
+	shared.client.Close()
+}
+
+// dispatch caches an event and delivers it to the members subscribed to its
+// resource.
+func (s *sharedADS) dispatch(event xdsresource.DiscoveryEvent) {
+	key := resourceKey{slot: subscriptionSlot(event.Typ), name: event.Name}
+	s.mu.Lock()
+	// A VHDS removal is cached too: it still answers a member's lookup.
+	s.resources[key] = event
+	var members []*pooledADS
+	for member := range s.members {
+		if covers(member.subSets, key) {
+			members = append(members, member)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, member := range members {
+		member.deliver(event)
+	}
+}
+
+// cachedEventsLocked returns the cached resources named in next but not in
+// prev, in subscription order so a replay sees listeners before the routes
+// and clusters they reference.
+func (s *sharedADS) cachedEventsLocked(prev, next subscriptions) []xdsresource.DiscoveryEvent {
+	prevSets, nextSets := prev.sets(), next.sets()
+	var keys []resourceKey
+	for key := range s.resources {
+		if covers(nextSets, key) && !covers(prevSets, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].slot != keys[j].slot {
+			return keys[i].slot < keys[j].slot
+		}
+		return keys[i].name < keys[j].name
+	})
+	events := make([]xdsresource.DiscoveryEvent, 0, len(keys))
+	for _, key := range keys {
+		events = append(events, s.resources[key])
+	}
+	return events
+}
+
+// dispatchNACK fans a rejected resource out to every member's OnNACK callback.
+func (s *sharedADS) dispatchNACK(nack NACK) {
+	s.mu.Lock()
+	callbacks := make([]func(NACK), 0, len(s.members))
+	for member := range s.members {
+		if member.onNACK != nil {
+			callbacks = append(callbacks, member.onNACK)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, onNACK := range callbacks {
+		onNACK(nack)
+	}
+}
+
+// updateSubscriptionsLocked pushes the union of member subscriptions to the
+// underlying client and drops cached resources no member subscribes to.
+// Holding s.mu keeps concurrent updates ordered.
+func (s *sharedADS) updateSubscriptionsLocked() {
+	merged := subscriptions{}.sets()
+	for member := range s.members {
+		for i, names := range member.sub.slots() {
+			for _, name := range names {
+				merged[i][name] = struct{}{}
+			}
+		}
+	}
+
+	for key := range s.resources {
+		if !covers(merged, key) {
+			delete(s.resources, key)
+		}
+	}
+
+	s.client.UpdateSubscriptions(subscriptions{
+		lds:  sortedSetKeys(merged[0]),
+		rds:  sortedSetKeys(merged[1]),
+		cds:  sortedSetKeys(merged[2]),
+		eds:  sortedSetKeys(merged[3]),
+		srds: sortedSetKeys(merged[4]),
+		vhds: sortedSetKeys(merged[5]),
+	})
+}
+
+// Start is a no-op; the shared client is started by the pool.
+func (m *pooledADS) Start() error {
+	return nil
+}
+
+func (m *pooledADS) UpdateSubscriptions(sub subscriptions) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+	prev := m.sub
+	m.sub = subscriptions{
+		lds:  slices.Clone(sub.lds),
+		rds:  slices.Clone(sub.rds),
+		cds:  slices.Clone(sub.cds),
+		eds:  slices.Clone(sub.eds),
+		srds: slices.Clone(sub.srds),
+		vhds: slices.Clone(sub.vhds),
+	}
+	m.subSets = m.sub.sets()
+	m.shared.updateSubscriptionsLocked()
+
+	// Names another member already watches leave the merged subscriptions
+	// unchanged, so the control plane resends nothing for them. Callers hold
+	// the lock handle takes, so the replay runs on its own goroutine.
+	if replay := m.shared.cachedEventsLocked(prev, m.sub); len(replay) > 0 {
+		m.pendingMu.Lock()
+		m.pending = append(m.pending, replay...)
+		m.pendingMu.Unlock()
+		go m.deliver()
+	}
+}
+
+func (m *pooledADS) Close() {
+	m.shared.pool.release(m)
+}
+
+// deliver hands events to the member after any replay still pending, so a
+// replayed resource never overwrites a newer live one.
+func (m *pooledADS) deliver(events ...xdsresource.DiscoveryEvent) {
+	m.deliverMu.Lock()
+	defer m.deliverMu.Unlock()
+
+	m.pendingMu.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.pendingMu.Unlock()
+	for _, event := range pending {
+		m.handle(event)
+	}
+	for _, event := range events {
+		m.handle(event)
+	}
+}
+
+// slots returns the subscription lists in slot order.
+func (s subscriptions) slots() [][]string {
+	return [][]string{s.lds, s.rds, s.cds, s.eds, s.srds, s.vhds}
+}
+
+// sets returns the subscription lists in slot order as sets.
+func (s subscriptions) sets() []map[string]struct{} {
+	slots := s.slots()
+	sets := make([]map[string]struct{}, len(slots))
+	for i, names := range slots {
+		sets[i] = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			sets[i][name] = struct{}{}
+		}
+	}
+	return sets
+}
+
+// covers reports whether the subscription sets include the cached resource.
+func covers(sets []map[string]struct{}, key resourceKey) bool {
+	if _, ok := sets[key.slot][key.name]; ok {
+		return true
+	}
+	_, ok := sets[key.slot][srdsWildcard]
+	return ok && key.slot == srdsSlot
+}
+
+// srdsSlot is the position of scoped routes in the merged subscription lists.
+const srdsSlot = 4
+
+// subscriptionSlot maps an event to the merged subscription list its resource
+// name belongs to, in the order used by updateSubscriptionsLocked.
+func subscriptionSlot(typ xdsresource.DiscoveryEventType) int {
+	switch typ {
+	case xdsresource.ListenerAdded:
+		return 0
+	case xdsresource.RouteAdded:
+		return 1
+	case xdsresource.ClusterAdded:
+		return 2
+	case xdsresource.EndpointAdded:
+		return 3
+	case xdsresource.ScopedRouteAdded:
+		return srdsSlot
+	default:
+		return 5
+	}
+}
+
+func sortedSetKeys(input map[string]struct{}) []string {
+	out := setKeys(input)
+	sort.Strings(out)
+	return out
+}
+
+// adsPoolKey identifies ADS clients that can share one stream: same server,
+// transport security, keepalive, node identity, retry budget, subscription
+// order and VHDS setting.
+func adsPoolKey(cfg Config) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%+v|%+v|%d|%v|%t|", cfg.Server.Address, cfg.Server.Timeout,
+		cfg.Server.TLS, cfg.Server.Keepalive, cfg.MaxRetries, cfg.SubscriptionOrder,
+		cfg.EnableVHDS)
+	fmt.Fprintf(&b, "%s|%s|%v|", cfg.Node.ID, cfg.Node.Cluster, cfg.Node.Metadata)
+	if cfg.Node.Locality != nil {
+		fmt.Fprintf(&b, "%+v", *cfg.Node.Locality)
+	}
+	return b.String()
+}
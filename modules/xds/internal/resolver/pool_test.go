@@ -0,0 +1,242 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+func TestResolversShareADSClientForSameServer(t *testing.T) {
+	oldFactory := adsClientFactory
+	t.Cleanup(func() { adsClientFactory = oldFactory })
+
+	fake := &fakeADS{}
+	var (
+		created  int
+		dispatch func(xdsresource.DiscoveryEvent)
+	)
+	adsClientFactory = func(
+		_ Config,
+		handle func(xdsresource.DiscoveryEvent),
+	) (adsSubscriptionClient, error) {
+		created++
+		dispatch = handle
+		return fake, nil
+	}
+
+	cfg := Config{Server: testServer, Node: testNode}
+	resolverA, err := NewResolver("a", cfg)
+	if err != nil {
+		t.Fatalf("NewResolver(a) error = %v", err)
+	}
+	resolverB, err := NewResolver("b", cfg)
+	if err != nil {
+		t.Fatalf("NewResolver(b) error = %v", err)
+	}
+
+	recorderA := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	recorderB := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	if err := resolverA.AddWatch("svc-a", recorderA); err != nil {
+		t.Fatalf("AddWatch(svc-a) error = %v", err)
+	}
+	if err := resolverB.AddWatch("svc-b", recorderB); err != nil {
+		t.Fatalf("AddWatch(svc-b) error = %v", err)
+	}
+
+	if created != 1 {
+		t.Fatalf("ADS clients created = %d, want 1", created)
+	}
+	if !slices.Equal(fake.lds, []string{"svc-a", "svc-b"}) {
+		t.Fatalf("merged LDS subscriptions = %#v, want both listeners", fake.lds)
+	}
+
+	dispatch(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.ListenerAdded,
+		Name: "svc-b",
+		Data: &xdsresource.ListenerSnapshot{Route: "route-b"},
+	})
+	select {
+	case <-recorderB.ch:
+	case <-time.After(time.Second):
+		t.Fatal("resolver b did not receive the shared ADS event")
+	}
+
+	if err := resolverA.DelWatch("svc-a", recorderA); err != nil {
+		t.Fatalf("DelWatch(svc-a) error = %v", err)
+	}
+	if fake.closed {
+		t.Fatal("shared ADS client closed while resolver b still watches")
+	}
+	if !slices.Equal(fake.lds, []string{"svc-b"}) {
+		t.Fatalf("LDS subscriptions after release = %#v, want [svc-b]", fake.lds)
+	}
+
+	if err := resolverB.DelWatch("svc-b", recorderB); err != nil {
+		t.Fatalf("DelWatch(svc-b) error = %v", err)
+	}
+	if !fake.closed {
+		t.Fatal("shared ADS client should close after the last resolver releases it")
+	}
+	if len(sharedADSClients.entries) != 0 {
+		t.Fatalf("pool entries = %d, want 0", len(sharedADSClients.entries))
+	}
+}
+
+func TestSharedADSClientReplaysResourcesToNewMembers(t *testing.T) {
+	oldFactory := adsClientFactory
+	t.Cleanup(func() { adsClientFactory = oldFactory })
+
+	fake := &fakeADS{}
+	var dispatch func(xdsresource.DiscoveryEvent)
+	adsClientFactory = func(
+		_ Config,
+		handle func(xdsresource.DiscoveryEvent),
+	) (adsSubscriptionClient, error) {
+		dispatch = handle
+		return fake, nil
+	}
+
+	cfg := Config{Server: testServer, Node: NodeConfig{ID: "replay-node"}}
+	resolverA, err := NewResolver("a", cfg)
+	if err != nil {
+		t.Fatalf("NewResolver(a) error = %v", err)
+	}
+	resolverB, err := NewResolver("b", cfg)
+	if err != nil {
+		t.Fatalf("NewResolver(b) error = %v", err)
+	}
+
+	recorderA := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	if err := resolverA.AddWatch("svc", recorderA); err != nil {
+		t.Fatalf("AddWatch(a) error = %v", err)
+	}
+	t.Cleanup(func() { _ = resolverA.DelWatch("svc", recorderA) })
+	dispatch(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.ListenerAdded,
+		Name: "svc",
+		Data: &xdsresource.ListenerSnapshot{Route: "route"},
+	})
+	select {
+	case <-recorderA.ch:
+	case <-time.After(time.Second):
+		t.Fatal("resolver a did not receive the listener")
+	}
+
+	// Resolver b subscribes to the same listener, so the merged subscriptions
+	// do not change and the control plane resends nothing.
+	recorderB := &stateRecorder{ch: make(chan yresolver.State, 8)}
+	if err := resolverB.AddWatch("svc", recorderB); err != nil {
+		t.Fatalf("AddWatch(b) error = %v", err)
+	}
+	t.Cleanup(func() { _ = resolverB.DelWatch("svc", recorderB) })
+	select {
+	case <-recorderB.ch:
+	case <-time.After(time.Second):
+		t.Fatal("resolver b did not receive the cached listener")
+	}
+	if !slices.Equal(fake.rds, []string{"route"}) {
+		t.Fatalf("RDS subscriptions = %#v, want [route]", fake.rds)
+	}
+}
+
+func TestSharedADSClientRoutesEventsToSubscribers(t *testing.T) {
+	oldFactory := adsClientFactory
+	t.Cleanup(func() { adsClientFactory = oldFactory })
+
+	var dispatch func(xdsresource.DiscoveryEvent)
+	adsClientFactory = func(
+		_ Config,
+		handle func(xdsresource.DiscoveryEvent),
+	) (adsSubscriptionClient, error) {
+		dispatch = handle
+		return &fakeADS{}, nil
+	}
+
+	var gotA, gotB []string
+	cfg := Config{Server: ServerConfig{Address: "xds.example:18002"}}
+	memberA, err := sharedADSClients.acquire(cfg, func(event xdsresource.DiscoveryEvent) {
+		gotA = append(gotA, event.Name)
+	})
+	if err != nil {
+		t.Fatalf("acquire(a) error = %v", err)
+	}
+	defer memberA.Close()
+	memberB, err := sharedADSClients.acquire(cfg, func(event xdsresource.DiscoveryEvent) {
+		gotB = append(gotB, event.Name)
+	})
+	if err != nil {
+		t.Fatalf("acquire(b) error = %v", err)
+	}
+	defer memberB.Close()
+
+	memberA.UpdateSubscriptions(subscriptions{lds: []string{"svc-a"}, cds: []string{"shared"}})
+	memberB.UpdateSubscriptions(subscriptions{lds: []string{"svc-b"}, cds: []string{"shared"}})
+	for _, event := range []xdsresource.DiscoveryEvent{
+		{Typ: xdsresource.ListenerAdded, Name: "svc-a"},
+		{Typ: xdsresource.ListenerAdded, Name: "svc-b"},
+		{Typ: xdsresource.ClusterAdded, Name: "shared"},
+		{Typ: xdsresource.ClusterAdded, Name: "svc-a"},
+	} {
+		dispatch(event)
+	}
+
+	if !slices.Equal(gotA, []string{"svc-a", "shared"}) {
+		t.Fatalf("events delivered to a = %v, want [svc-a shared]", gotA)
+	}
+	if !slices.Equal(gotB, []string{"svc-b", "shared"}) {
+		t.Fatalf("events delivered to b = %v, want [svc-b shared]", gotB)
+	}
+}
+
+func TestSharedADSClientFansOutNACKs(t *testing.T) {
+	oldFactory := adsClientFactory
+	t.Cleanup(func() { adsClientFactory = oldFactory })
+
+	var reportNACK func(NACK)
+	adsClientFactory = func(
+		cfg Config,
+		_ func(xdsresource.DiscoveryEvent),
+	) (adsSubscriptionClient, error) {
+		reportNACK = cfg.OnNACK
+		return &fakeADS{}, nil
+	}
+
+	var gotA, gotB []NACK
+	cfg := Config{Server: ServerConfig{Address: "xds.example:18001"}}
+	cfgA, cfgB := cfg, cfg
+	cfgA.OnNACK = func(nack NACK) { gotA = append(gotA, nack) }
+	cfgB.OnNACK = func(nack NACK) { gotB = append(gotB, nack) }
+
+	memberA, err := sharedADSClients.acquire(cfgA, func(xdsresource.DiscoveryEvent) {})
+	if err != nil {
+		t.Fatalf("acquire(a) error = %v", err)
+	}
+	defer memberA.Close()
+	memberB, err := sharedADSClients.acquire(cfgB, func(xdsresource.DiscoveryEvent) {})
+	if err != nil {
+		t.Fatalf("acquire(b) error = %v", err)
+	}
+	defer memberB.Close()
+
+	reportNACK(NACK{TypeURL: "type", ResourceIndex: -1})
+	if len(gotA) != 1 || len(gotB) != 1 {
+		t.Fatalf("NACKs delivered = %d/%d, want 1/1", len(gotA), len(gotB))
+	}
+}
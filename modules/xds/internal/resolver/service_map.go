@@ -0,0 +1,106 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// targetPlaceholder in a ServicePattern listener is replaced by the target.
+const targetPlaceholder = "{target}"
+
+// ServicePattern maps every target matching Match or Regex to a listener.
+type ServicePattern struct {
+	// Match is a path.Match glob such as "*-svc".
+	Match string `mapstructure:"match"`
+	// Regex is an anchored regular expression; it is used when Match is empty.
+	Regex string `mapstructure:"regex"`
+	// Listener is the listener name. "{target}" is replaced by the target and,
+	// for Regex patterns, "$1"-style references expand capture groups. An empty
+	// Listener uses the target itself.
+	Listener string `mapstructure:"listener"`
+}
+
+type compiledServicePattern struct {
+	glob     string
+	re       *regexp.Regexp
+	listener string
+}
+
+// serviceMapper resolves targets to listener names: exact ServiceMap entries
+// first, then ServicePatterns in order, then the target itself.
+type serviceMapper struct {
+	exact    map[string]string
+	patterns []compiledServicePattern
+}
+
+func newServiceMapper(cfg Config) (*serviceMapper, error) {
+	m := &serviceMapper{exact: cfg.ServiceMap}
+	for i, p := range cfg.ServicePatterns {
+		compiled := compiledServicePattern{glob: p.Match, listener: p.Listener}
+		switch {
+		case p.Match != "":
+			if _, err := path.Match(p.Match, ""); err != nil {
+				return nil, fmt.Errorf(
+					"service_patterns[%d]: invalid match %q: %w", i, p.Match, err)
+			}
+		case p.Regex != "":
+			re, err := regexp.Compile("^(?:" + p.Regex + ")$")
+			if err != nil {
+				return nil, fmt.Errorf(
+					"service_patterns[%d]: invalid regex %q: %w", i, p.Regex, err)
+			}
+			compiled.re = re
+		default:
+			return nil, fmt.Errorf("service_patterns[%d]: match or regex is required", i)
+		}
+		m.patterns = append(m.patterns, compiled)
+	}
+	return m, nil
+}
+
+func (m *serviceMapper) listenerName(target string) string {
+	if listenerName, ok := m.exact[target]; ok {
+		return listenerName
+	}
+	for _, p := range m.patterns {
+		if listener, ok := p.resolve(target); ok {
+			return listener
+		}
+	}
+	return target
+}
+
+func (p compiledServicePattern) resolve(target string) (string, bool) {
+	listener := p.listener
+	if p.re != nil {
+		match := p.re.FindStringSubmatchIndex(target)
+		if match == nil {
+			return "", false
+		}
+		if listener != "" {
+			listener = string(p.re.ExpandString(nil, listener, target, match))
+		}
+	} else if ok, _ := path.Match(p.glob, target); !ok {
+		return "", false
+	}
+	if listener == "" {
+		return target, true
+	}
+	return strings.ReplaceAll(listener, targetPlaceholder, target), true
+}
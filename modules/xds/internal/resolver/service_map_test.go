@@ -0,0 +1,174 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+func TestServiceMapperPatterns(t *testing.T) {
+	mapper, err := newServiceMapper(Config{
+		ServiceMap: map[string]string{"library": "library-exact"},
+		ServicePatterns: []ServicePattern{
+			{Regex: `(\w+)\.v(\d+)`, Listener: "$1-listener-v$2"},
+			{Match: "*-svc", Listener: "mesh/{target}"},
+			{Match: "greet*"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newServiceMapper() error = %v", err)
+	}
+
+	cases := map[string]string{
+		"library":     "library-exact",
+		"orders.v2":   "orders-listener-v2",
+		"xorders.v2x": "xorders.v2x",
+		"billing-svc": "mesh/billing-svc",
+		"greeter":     "greeter",
+		"unmapped":    "unmapped",
+	}
+	for target, want := range cases {
+		if got := mapper.listenerName(target); got != want {
+			t.Fatalf("listenerName(%q) = %q, want %q", target, got, want)
+		}
+	}
+
+	for _, bad := range []ServicePattern{
+		{Match: "[", Listener: "x"},
+		{Regex: "(", Listener: "x"},
+		{Listener: "x"},
+	} {
+		cfg := Config{ServicePatterns: []ServicePattern{bad}}
+		if _, err := NewResolver("default", cfg); err == nil {
+			t.Fatalf("NewResolver(%+v) expected error", bad)
+		}
+	}
+}
+
+func TestResolverPatternTargetsStayIsolated(t *testing.T) {
+	oldFactory := adsClientFactory
+	fake := &fakeADS{}
+	adsClientFactory = func(
+		Config,
+		func(xdsresource.DiscoveryEvent),
+	) (adsSubscriptionClient, error) {
+		return fake, nil
+	}
+	t.Cleanup(func() { adsClientFactory = oldFactory })
+
+	resolverAny, err := NewResolver("default", Config{
+		Server:          testServer,
+		Node:            testNode,
+		Protocol:        "grpc",
+		ServicePatterns: []ServicePattern{{Match: "*", Listener: "{target}-listener"}},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	instance := resolverAny.(*xdsResolver)
+	library := &stateRecorder{ch: make(chan yresolver.State, 32)}
+	greeter := &stateRecorder{ch: make(chan yresolver.State, 32)}
+	if err := instance.AddWatch("library", library); err != nil {
+		t.Fatalf("AddWatch(library) error = %v", err)
+	}
+	if err := instance.AddWatch("greeter", greeter); err != nil {
+		t.Fatalf("AddWatch(greeter) error = %v", err)
+	}
+	want := []string{"greeter-listener", "library-listener"}
+	if !slices.Equal(sorted(fake.lds), want) {
+		t.Fatalf("LDS subscriptions = %v, want %v", fake.lds, want)
+	}
+
+	for service, port := range map[string]int{"library": 9001, "greeter": 9002} {
+		publishService(instance, service, port)
+	}
+
+	assertOnlyEndpoint(t, library, "127.0.0.1:9001")
+	assertOnlyEndpoint(t, greeter, "127.0.0.1:9002")
+}
+
+func publishService(instance *xdsResolver, service string, port int) {
+	instance.core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.ListenerAdded,
+		Name: service + "-listener",
+		Data: &xdsresource.ListenerSnapshot{Route: service + "-route"},
+	})
+	instance.core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.RouteAdded,
+		Name: service + "-route",
+		Data: &xdsresource.RouteSnapshot{Vhosts: []*xdsresource.VirtualHost{{
+			Name:    service,
+			Domains: []string{"*"},
+			Routes: []*xdsresource.Route{
+				{Action: &xdsresource.RouteAction{Cluster: service + "-cluster"}},
+			},
+		}}},
+	})
+	instance.core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.ClusterAdded,
+		Name: service + "-cluster",
+		Data: &xdsresource.ClusterSnapshot{},
+	})
+	instance.core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.EndpointAdded,
+		Name: service + "-cluster",
+		Data: &xdsresource.EDSSnapshot{Endpoints: []*xdsresource.WeightedEndpoint{{
+			Cluster:  service + "-cluster",
+			Endpoint: xdsresource.Endpoint{Address: "127.0.0.1", Port: port},
+			Weight:   1,
+		}}},
+	})
+}
+
+// assertOnlyEndpoint drains rec and requires every non-empty state to carry
+// exactly want.
+func assertOnlyEndpoint(t *testing.T, rec *stateRecorder, want string) {
+	t.Helper()
+
+	seen := false
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case state := <-rec.ch:
+			for _, ep := range state.GetEndpoints() {
+				if ep.GetAddress() != want {
+					t.Fatalf("received foreign endpoint %s, want only %s", ep.GetAddress(), want)
+				}
+				seen = true
+			}
+		case <-timeout:
+			if !seen {
+				t.Fatalf("no state with endpoint %s received", want)
+			}
+			return
+		default:
+			if seen {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func sorted(in []string) []string {
+	out := slices.Clone(in)
+	slices.Sort(out)
+	return out
+}
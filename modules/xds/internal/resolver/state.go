@@ -16,42 +16,114 @@ package resolver
 
 import (
 	"fmt"
+	"sort"
 
 	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
 	yresolver "github.com/codesjoy/yggdrasil/v3/discovery/resolver"
 )
 
+// srdsWildcard subscribes to every scoped route configuration: SRDS
+// resources are not named by the listeners that use them.
+const srdsWildcard = "*"
+
 func (c *xdsCore) reconcileSubscriptions() {
-	ldsNames, rdsNames, cdsNames := c.collectSubscriptionNames()
+	ldsNames, rdsNames, cdsNames, srdsNames := c.collectSubscriptionNames()
 	edsNames := append([]string(nil), cdsNames...)
 
 	if c.ads != nil {
-		c.ads.UpdateSubscriptions(ldsNames, rdsNames, cdsNames, edsNames)
+		c.ads.UpdateSubscriptions(subscriptions{
+			lds:  ldsNames,
+			rds:  rdsNames,
+			cds:  cdsNames,
+			eds:  edsNames,
+			srds: srdsNames,
+			vhds: c.virtualHostNames(),
+		})
 	}
 }
 
-func (c *xdsCore) collectSubscriptionNames() (ldsNames, rdsNames, cdsNames []string) {
+func (c *xdsCore) collectSubscriptionNames() (ldsNames, rdsNames, cdsNames, srdsNames []string) {
 	ldsSet := make(map[string]struct{})
 	rdsSet := make(map[string]struct{})
 	cdsSet := make(map[string]struct{})
+	srdsSet := make(map[string]struct{})
 
 	for _, app := range c.apps {
 		for listenerName := range app.listeners {
 			ldsSet[listenerName] = struct{}{}
 
-			routeName, ok := c.routeNameForListener(listenerName)
-			if !ok {
-				continue
+			if scoped := c.listeners[listenerName].GetScopedRoutes(); scoped != nil {
+				if scoped.SRDS {
+					srdsSet[srdsWildcard] = struct{}{}
+				}
+				for _, scope := range c.listenerScopes(listenerName) {
+					if scope.Inline == nil && scope.RouteConfig != "" {
+						rdsSet[scope.RouteConfig] = struct{}{}
+					}
+				}
+			}
+			if routeName, ok := c.routeNameForListener(listenerName); ok {
+				rdsSet[routeName] = struct{}{}
 			}
 
-			rdsSet[routeName] = struct{}{}
-			for _, clusterName := range routeClusterNames(c.routes[routeName]) {
-				cdsSet[clusterName] = struct{}{}
+			for _, snapshot := range c.listenerRouteSnapshots(listenerName) {
+				for _, clusterName := range routeClusterNames(snapshot) {
+					cdsSet[clusterName] = struct{}{}
+				}
 			}
 		}
 	}
 
-	return setKeys(ldsSet), setKeys(rdsSet), setKeys(cdsSet)
+	return setKeys(ldsSet), setKeys(rdsSet), setKeys(cdsSet), setKeys(srdsSet)
+}
+
+// listenerScopes returns the scopes of a listener that uses scoped routes:
+// its inline scopes, or every scope received over SRDS ordered by name.
+func (c *xdsCore) listenerScopes(listenerName string) []*xdsresource.ScopedRouteSnapshot {
+	scoped := c.listeners[listenerName].GetScopedRoutes()
+	if scoped == nil {
+		return nil
+	}
+	if !scoped.SRDS {
+		return scoped.Scopes
+	}
+
+	names := make([]string, 0, len(c.scopes))
+	for name := range c.scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	scopes := make([]*xdsresource.ScopedRouteSnapshot, 0, len(names))
+	for _, name := range names {
+		scopes = append(scopes, c.scopes[name])
+	}
+	return scopes
+}
+
+// scopeRoute returns the route configuration of a scope, or nil while its
+// RDS resource has not arrived.
+func (c *xdsCore) scopeRoute(scope *xdsresource.ScopedRouteSnapshot) *xdsresource.RouteSnapshot {
+	if scope.Inline != nil {
+		return scope.Inline
+	}
+	return c.routeSnapshot(scope.RouteConfig)
+}
+
+// listenerRouteSnapshots returns every received route configuration a
+// listener routes by, including those of its scopes.
+func (c *xdsCore) listenerRouteSnapshots(listenerName string) []*xdsresource.RouteSnapshot {
+	var snapshots []*xdsresource.RouteSnapshot
+	if routeName, ok := c.routeNameForListener(listenerName); ok {
+		if snapshot := c.routeSnapshot(routeName); snapshot != nil {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	for _, scope := range c.listenerScopes(listenerName) {
+		if snapshot := c.scopeRoute(scope); snapshot != nil {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
 }
 
 func setKeys(input map[string]struct{}) []string {
@@ -104,15 +176,28 @@ func (c *xdsCore) buildResolverEndpoints(app *appInfo) []yresolver.Endpoint {
 	weightedEndpoints := c.collectAppEndpoints(app)
 	endpoints := make([]yresolver.Endpoint, 0, len(weightedEndpoints))
 	for _, endpoint := range weightedEndpoints {
+		attributes := map[string]any{
+			xdsresource.AttributeEndpointCluster:  endpoint.Cluster,
+			xdsresource.AttributeEndpointWeight:   endpoint.Weight,
+			xdsresource.AttributeEndpointPriority: endpoint.Priority,
+			xdsresource.AttributeEndpointMetadata: endpoint.Metadata,
+		}
+		if endpoint.Identity != nil {
+			attributes[xdsresource.AttributeEndpointIdentity] = endpoint.Identity
+		}
+		protocol := c.cfg.Protocol
+		if cluster := c.clusters[endpoint.Cluster]; cluster != nil {
+			if alpn := cluster.Policy.ALPNProtocols; len(alpn) > 0 {
+				attributes[xdsresource.AttributeEndpointALPN] = alpn
+				if selected := xdsresource.ProtocolForALPN(alpn); selected != "" {
+					protocol = selected
+				}
+			}
+		}
 		endpoints = append(endpoints, yresolver.BaseEndpoint{
-			Address:  fmt.Sprintf("%s:%d", endpoint.Endpoint.Address, endpoint.Endpoint.Port),
-			Protocol: c.cfg.Protocol,
-			Attributes: map[string]any{
-				xdsresource.AttributeEndpointCluster:  endpoint.Cluster,
-				xdsresource.AttributeEndpointWeight:   endpoint.Weight,
-				xdsresource.AttributeEndpointPriority: endpoint.Priority,
-				xdsresource.AttributeEndpointMetadata: endpoint.Metadata,
-			},
+			Address:    fmt.Sprintf("%s:%d", endpoint.Endpoint.Address, endpoint.Endpoint.Port),
+			Protocol:   protocol,
+			Attributes: attributes,
 		})
 	}
 	return endpoints
@@ -160,29 +245,43 @@ func copyWeightedEndpoint(endpoint *xdsresource.WeightedEndpoint) *xdsresource.W
 		Weight:   endpoint.Weight,
 		Priority: endpoint.Priority,
 		Metadata: endpoint.Metadata,
+		Identity: endpoint.Identity,
 	}
 }
 
 func (c *xdsCore) buildResolverAttributes(app *appInfo) map[string]any {
-	return map[string]any{
-		xdsresource.AttributeRoutes:   buildRouteConfig(app, c.routes, c.listeners),
-		xdsresource.AttributeClusters: buildClusterMap(app, c.routes, c.listeners, c.clusters),
+	attributes := map[string]any{
+		xdsresource.AttributeRoutes:       c.buildRouteConfig(app),
+		xdsresource.AttributeScopedRoutes: c.buildScopedRoutes(app),
+		xdsresource.AttributeClusters:     buildClusterMap(c.clusterNamesForApp(app), c.clusters),
+		xdsresource.AttributeEDSReceived:  c.buildEDSReceived(app),
+	}
+	if lookup := c.virtualHostLookup(); lookup != nil {
+		attributes[xdsresource.AttributeVirtualHostLookup] = lookup
+	}
+	return attributes
+}
+
+// buildEDSReceived reports, for every cluster the app routes to, whether its
+// EDS load assignment has arrived. A received assignment may still be empty.
+func (c *xdsCore) buildEDSReceived(app *appInfo) map[string]bool {
+	received := make(map[string]bool)
+	for clusterName := range c.clusterNamesForApp(app) {
+		_, ok := c.endpoints[clusterName]
+		received[clusterName] = ok
 	}
+	return received
 }
 
-func buildRouteConfig(
-	app *appInfo,
-	routes map[string]*xdsresource.RouteSnapshot,
-	listeners map[string]*xdsresource.ListenerSnapshot,
-) []*xdsresource.VirtualHost {
+func (c *xdsCore) buildRouteConfig(app *appInfo) []*xdsresource.VirtualHost {
 	var vhosts []*xdsresource.VirtualHost
 	for listenerName := range app.listeners {
-		listenerSnapshot, ok := listeners[listenerName]
+		routeName, ok := c.routeNameForListener(listenerName)
 		if !ok {
 			continue
 		}
-		routeSnapshot, ok := routes[listenerSnapshot.Route]
-		if !ok {
+		routeSnapshot := c.routeSnapshot(routeName)
+		if routeSnapshot == nil {
 			continue
 		}
 		vhosts = append(vhosts, routeSnapshot.Vhosts...)
@@ -190,14 +289,41 @@ func buildRouteConfig(
 	return vhosts
 }
 
+// buildScopedRoutes resolves the scopes of the app's scoped-route listeners.
+// Scopes whose route configuration has not arrived are left out.
+func (c *xdsCore) buildScopedRoutes(app *appInfo) []*xdsresource.ScopedRouteTable {
+	listenerNames := make([]string, 0, len(app.listeners))
+	for listenerName := range app.listeners {
+		listenerNames = append(listenerNames, listenerName)
+	}
+	sort.Strings(listenerNames)
+
+	var tables []*xdsresource.ScopedRouteTable
+	for _, listenerName := range listenerNames {
+		scoped := c.listeners[listenerName].GetScopedRoutes()
+		if scoped == nil {
+			continue
+		}
+		table := &xdsresource.ScopedRouteTable{KeyBuilder: scoped.KeyBuilder}
+		for _, scope := range c.listenerScopes(listenerName) {
+			if snapshot := c.scopeRoute(scope); snapshot != nil {
+				table.Scopes = append(table.Scopes, &xdsresource.RouteScope{
+					Key:    scope.Key,
+					Vhosts: snapshot.Vhosts,
+				})
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
 func buildClusterMap(
-	app *appInfo,
-	routes map[string]*xdsresource.RouteSnapshot,
-	listeners map[string]*xdsresource.ListenerSnapshot,
+	clusterNames map[string]struct{},
 	clusters map[string]*xdsresource.ClusterSnapshot,
 ) map[string]xdsresource.ClusterPolicy {
 	clusterPolicies := make(map[string]xdsresource.ClusterPolicy)
-	for clusterName := range clusterNamesForApp(app, routes, listeners) {
+	for clusterName := range clusterNames {
 		policy := xdsresource.ClusterPolicy{}
 		if snapshot := clusters[clusterName]; snapshot != nil {
 			policy = snapshot.Policy
@@ -208,26 +334,12 @@ func buildClusterMap(
 }
 
 func (c *xdsCore) clusterNamesForApp(app *appInfo) map[string]struct{} {
-	return clusterNamesForApp(app, c.routes, c.listeners)
-}
-
-func clusterNamesForApp(
-	app *appInfo,
-	routes map[string]*xdsresource.RouteSnapshot,
-	listeners map[string]*xdsresource.ListenerSnapshot,
-) map[string]struct{} {
 	clusterNames := make(map[string]struct{})
 	for listenerName := range app.listeners {
-		listenerSnapshot, ok := listeners[listenerName]
-		if !ok {
-			continue
-		}
-		routeSnapshot, ok := routes[listenerSnapshot.Route]
-		if !ok {
-			continue
-		}
-		for _, clusterName := range routeClusterNames(routeSnapshot) {
-			clusterNames[clusterName] = struct{}{}
+		for _, snapshot := range c.listenerRouteSnapshots(listenerName) {
+			for _, clusterName := range routeClusterNames(snapshot) {
+				clusterNames[clusterName] = struct{}{}
+			}
 		}
 	}
 	return clusterNames
@@ -0,0 +1,193 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultCAReloadInterval = time.Minute
+
+// tlsFileReloader serves the xDS client certificate and CA pool from disk so
+// rotated files are picked up on the next handshake without tearing down an
+// established ADS stream.
+type tlsFileReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certStamp   [2]time.Time
+	roots       *x509.CertPool
+	caStamp     time.Time
+	caCheckedAt time.Time
+}
+
+func newTLSFileReloader(cfg TLSConfig) (*tlsFileReloader, error) {
+	r := &tlsFileReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		caFile:   cfg.CAFile,
+		interval: cfg.CAReloadInterval,
+		now:      time.Now,
+	}
+	if r.interval <= 0 {
+		r.interval = defaultCAReloadInterval
+	}
+	if r.hasCert() {
+		if err := r.loadCert(); err != nil {
+			return nil, err
+		}
+	}
+	if r.caFile != "" {
+		if err := r.loadCA(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *tlsFileReloader) hasCert() bool {
+	return r.certFile != "" && r.keyFile != ""
+}
+
+func (r *tlsFileReloader) apply(tlsConfig *tls.Config) {
+	if r.hasCert() {
+		tlsConfig.GetClientCertificate = r.getClientCertificate
+	}
+	if r.caFile != "" {
+		// Chain and hostname verification is done in verifyConnection against the
+		// current CA pool, which may change after the config has been built.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = r.verifyConnection
+	}
+}
+
+func (r *tlsFileReloader) getClientCertificate(
+	*tls.CertificateRequestInfo,
+) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp, err := fileStamps(r.certFile, r.keyFile)
+	if err == nil && stamp != r.certStamp {
+		err = r.loadCertLocked()
+	}
+	if err != nil {
+		log.Printf("[xds] reload TLS cert pair failed, keeping previous certificate: %v", err)
+	}
+	return r.cert, nil
+}
+
+func (r *tlsFileReloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("xds server presented no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         r.rootCAs(),
+		DNSName:       cs.ServerName,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+func (r *tlsFileReloader) rootCAs() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Sub(r.caCheckedAt) >= r.interval {
+		stamp, err := fileStamps(r.caFile)
+		if err == nil && stamp[0] != r.caStamp {
+			err = r.loadCALocked()
+		}
+		if err != nil {
+			log.Printf("[xds] reload CA file failed, keeping previous pool: %v", err)
+		}
+		r.caCheckedAt = r.now()
+	}
+	return r.roots
+}
+
+func (r *tlsFileReloader) loadCert() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadCertLocked()
+}
+
+func (r *tlsFileReloader) loadCertLocked() error {
+	stamp, err := fileStamps(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS cert pair: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS cert pair: %w", err)
+	}
+	r.cert = &cert
+	r.certStamp = stamp
+	return nil
+}
+
+func (r *tlsFileReloader) loadCA() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadCALocked()
+}
+
+func (r *tlsFileReloader) loadCALocked() error {
+	stamp, err := fileStamps(r.caFile)
+	if err != nil {
+		return fmt.Errorf("read CA file: %w", err)
+	}
+	caCert, err := os.ReadFile(r.caFile)
+	if err != nil {
+		return fmt.Errorf("read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return fmt.Errorf("read CA file: no certificates found in %s", r.caFile)
+	}
+	r.roots = pool
+	r.caStamp = stamp[0]
+	r.caCheckedAt = r.now()
+	return nil
+}
+
+// fileStamps returns the modification times of up to two files.
+func fileStamps(files ...string) ([2]time.Time, error) {
+	var stamp [2]time.Time
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return stamp, err
+		}
+		stamp[i] = info.ModTime()
+	}
+	return stamp, nil
+}
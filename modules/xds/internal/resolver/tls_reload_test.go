@@ -0,0 +1,167 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSFileReloaderRotatesClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey, caFile := writeTLSFiles(t, dir)
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	writeClientCert(t, certFile, keyFile, 10)
+
+	reloader, err := newTLSFileReloader(TLSConfig{
+		Enable:   true,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   caFile,
+	})
+	if err != nil {
+		t.Fatalf("newTLSFileReloader() error = %v", err)
+	}
+	//nolint:gosec // G402: verification is delegated to the reloader.
+	clientConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	reloader.apply(clientConfig)
+
+	pair, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair(server) error = %v", err)
+	}
+	serverConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}
+
+	if got := handshakeClientSerial(t, clientConfig, serverConfig); got != 10 {
+		t.Fatalf("first handshake client serial = %d, want 10", got)
+	}
+
+	writeClientCert(t, certFile, keyFile, 11)
+	future := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, future, future); err != nil {
+			t.Fatalf("Chtimes(%s) error = %v", file, err)
+		}
+	}
+
+	if got := handshakeClientSerial(t, clientConfig, serverConfig); got != 11 {
+		t.Fatalf("second handshake client serial = %d, want 11", got)
+	}
+}
+
+func TestTLSFileReloaderReloadsCAPool(t *testing.T) {
+	dir := t.TempDir()
+	_, _, caFile := writeTLSFiles(t, dir)
+
+	reloader, err := newTLSFileReloader(TLSConfig{
+		Enable:           true,
+		CAFile:           caFile,
+		CAReloadInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newTLSFileReloader() error = %v", err)
+	}
+	now := time.Now().Add(time.Second)
+	reloader.now = func() time.Time { return now }
+	initial := reloader.rootCAs()
+
+	other := t.TempDir()
+	_, _, rotated := writeTLSFiles(t, other)
+	data, err := os.ReadFile(rotated)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if err := os.WriteFile(caFile, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	future := now.Add(time.Minute)
+	if err := os.Chtimes(caFile, future, future); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	if got := reloader.rootCAs(); got != initial {
+		t.Fatal("rootCAs() reloaded before the reload interval elapsed")
+	}
+	now = now.Add(time.Minute)
+	if got := reloader.rootCAs(); got == initial {
+		t.Fatal("rootCAs() did not reload after the reload interval elapsed")
+	}
+}
+
+func handshakeClientSerial(t *testing.T, clientConfig, serverConfig *tls.Config) int64 {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	server := tls.Server(serverConn, serverConfig)
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Handshake() }()
+
+	if err := tls.Client(clientConn, clientConfig).Handshake(); err != nil {
+		t.Fatalf("client Handshake() error = %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("server Handshake() error = %v", err)
+	}
+	peers := server.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		t.Fatal("server saw no client certificate")
+	}
+	return peers[0].SerialNumber.Int64()
+}
+
+func writeClientCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "xds-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	if err := os.WriteFile(certFile, pemEncode("CERTIFICATE", der), 0o600); err != nil {
+		t.Fatalf("WriteFile(cert) error = %v", err)
+	}
+	keyOut := pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	if err := os.WriteFile(keyFile, keyOut, 0o600); err != nil {
+		t.Fatalf("WriteFile(key) error = %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// virtualHostName is the VHDS resource name of host in a route configuration.
+func virtualHostName(routeName, host string) string {
+	return routeName + "/" + host
+}
+
+// maxVHDSHosts bounds the hosts looked up over VHDS. Authorities come from
+// requests, so without a bound the subscription list would grow forever.
+const maxVHDSHosts = 1024
+
+// vhdsHost is one looked-up host: whether the control plane has answered,
+// and when it was last looked up.
+type vhdsHost struct {
+	answered bool
+	lastUsed uint64
+}
+
+// lookupVirtualHost subscribes to host in every VHDS route configuration the
+// resolver routes by. It reports whether the answer is still outstanding.
+func (c *xdsCore) lookupVirtualHost(host string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if host == "" {
+		return false
+	}
+	c.vhdsSeq++
+	if state, ok := c.vhdsHosts[host]; ok {
+		state.lastUsed = c.vhdsSeq
+		return !state.answered
+	}
+	if len(c.vhdsRouteNames()) == 0 {
+		return false
+	}
+
+	if len(c.vhdsHosts) >= maxVHDSHosts {
+		c.evictVirtualHostLocked()
+	}
+	c.vhdsHosts[host] = &vhdsHost{lastUsed: c.vhdsSeq}
+	c.reconcileSubscriptions()
+	return true
+}
+
+// evictVirtualHostLocked drops the least recently looked-up host and its
+// answers. Its resource names leave the next subscription update; a later
+// RPC for the host looks it up again.
+func (c *xdsCore) evictVirtualHostLocked() {
+	var (
+		oldest string
+		seq    uint64
+	)
+	for host, state := range c.vhdsHosts {
+		if oldest == "" || state.lastUsed < seq {
+			oldest, seq = host, state.lastUsed
+		}
+	}
+	delete(c.vhdsHosts, oldest)
+	suffix := "/" + oldest
+	for name := range c.virtualHosts {
+		if strings.HasSuffix(name, suffix) {
+			delete(c.virtualHosts, name)
+		}
+	}
+}
+
+// applyVirtualHost records a VHDS answer. A removal still answers the lookup,
+// so RPCs waiting for an unknown host stop waiting. Answers for hosts evicted
+// in the meantime are dropped.
+func (c *xdsCore) applyVirtualHost(event xdsresource.DiscoveryEvent) {
+	idx := strings.LastIndex(event.Name, "/")
+	if idx < 0 {
+		return
+	}
+	state := c.vhdsHosts[event.Name[idx+1:]]
+	if state == nil {
+		return
+	}
+	state.answered = true
+	if event.Typ == xdsresource.VirtualHostAdded {
+		c.virtualHosts[event.Name] = event.Data.(*xdsresource.VirtualHost)
+	} else {
+		delete(c.virtualHosts, event.Name)
+	}
+}
+
+// vhdsRouteNames returns the received VHDS route configurations of the
+// watched listeners.
+func (c *xdsCore) vhdsRouteNames() []string {
+	set := make(map[string]struct{})
+	for _, app := range c.apps {
+		for listenerName := range app.listeners {
+			routeNames := make([]string, 0, 1)
+			if routeName, ok := c.routeNameForListener(listenerName); ok {
+				routeNames = append(routeNames, routeName)
+			}
+			for _, scope := range c.listenerScopes(listenerName) {
+				if scope.Inline == nil && scope.RouteConfig != "" {
+					routeNames = append(routeNames, scope.RouteConfig)
+				}
+			}
+			for _, routeName := range routeNames {
+				if snapshot := c.routes[routeName]; snapshot != nil && snapshot.VHDS {
+					set[routeName] = struct{}{}
+				}
+			}
+		}
+	}
+	return setKeys(set)
+}
+
+// virtualHostNames returns the VHDS resource names to subscribe to: every
+// looked-up host in every VHDS route configuration.
+func (c *xdsCore) virtualHostNames() []string {
+	if len(c.vhdsHosts) == 0 {
+		return nil
+	}
+	var names []string
+	for _, routeName := range c.vhdsRouteNames() {
+		for host := range c.vhdsHosts {
+			names = append(names, virtualHostName(routeName, host))
+		}
+	}
+	return names
+}
+
+// routeSnapshot returns a received route configuration with the virtual
+// hosts discovered for it on demand appended, or nil.
+func (c *xdsCore) routeSnapshot(routeName string) *xdsresource.RouteSnapshot {
+	snapshot := c.routes[routeName]
+	if snapshot == nil || !snapshot.VHDS || len(c.virtualHosts) == 0 {
+		return snapshot
+	}
+
+	prefix := virtualHostName(routeName, "")
+	names := make([]string, 0, len(c.virtualHosts))
+	for name := range c.virtualHosts {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return snapshot
+	}
+	sort.Strings(names)
+
+	merged := &xdsresource.RouteSnapshot{
+		Vhosts: slices.Clip(snapshot.Vhosts),
+		VHDS:   true,
+	}
+	for _, name := range names {
+		merged.Vhosts = append(merged.Vhosts, c.virtualHosts[name])
+	}
+	return merged
+}
+
+// virtualHostLookup returns the lookup handed to balancers, or nil while
+// VHDS is disabled.
+func (c *xdsCore) virtualHostLookup() xdsresource.VirtualHostLookup {
+	if !c.cfg.EnableVHDS {
+		return nil
+	}
+	return c.lookupVirtualHost
+}
+
+// sendVirtualHostDiffLocked queues a delta request that subscribes to the
+// names added since prev and unsubscribes from the ones removed.
+func (c *adsClient) sendVirtualHostDiffLocked(prev, next []string) {
+	req := &discoveryv3.DeltaDiscoveryRequest{
+		Node:    c.node,
+		TypeUrl: resource.VirtualHostType,
+	}
+	for _, name := range next {
+		if !slices.Contains(prev, name) {
+			req.ResourceNamesSubscribe = append(req.ResourceNamesSubscribe, name)
+		}
+	}
+	for _, name := range prev {
+		if !slices.Contains(next, name) {
+			req.ResourceNamesUnsubscribe = append(req.ResourceNamesUnsubscribe, name)
+		}
+	}
+	if len(req.ResourceNamesSubscribe) == 0 && len(req.ResourceNamesUnsubscribe) == 0 {
+		return
+	}
+	c.queueDelta(req)
+}
+
+// resendVirtualHosts queues the initial request of a new delta stream.
+func (c *adsClient) resendVirtualHosts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.sub.vhds) == 0 {
+		return
+	}
+	c.queueDelta(&discoveryv3.DeltaDiscoveryRequest{
+		Node:                   c.node,
+		TypeUrl:                resource.VirtualHostType,
+		ResourceNamesSubscribe: slices.Clone(c.sub.vhds),
+	})
+}
+
+func (c *adsClient) queueDelta(req *discoveryv3.DeltaDiscoveryRequest) {
+	select {
+	case c.deltaSendCh <- req:
+	default:
+		log.Printf("[xds] delta send buffer full, dropping request for %s", req.TypeUrl)
+	}
+}
+
+func (c *adsClient) sendDeltaLoop(
+	stream discoveryv3.AggregatedDiscoveryService_DeltaAggregatedResourcesClient,
+) error {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case req := <-c.deltaSendCh:
+			if err := stream.Send(req); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *adsClient) watchVirtualHosts(
+	stream discoveryv3.AggregatedDiscoveryService_DeltaAggregatedResourcesClient,
+) error {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		c.handleDeltaResponse(resp)
+	}
+}
+
+// handleDeltaResponse applies the virtual hosts of a VHDS response that
+// decode. It ACKs the response, or NACKs it with the ones that did not.
+func (c *adsClient) handleDeltaResponse(resp *discoveryv3.DeltaDiscoveryResponse) {
+	events := make([]xdsresource.DiscoveryEvent, 0,
+		len(resp.Resources)+len(resp.RemovedResources))
+	var errs []error
+	for idx, item := range resp.Resources {
+		event, err := xdsresource.DecodeVirtualHost(item.GetName(), item.GetResource())
+		if err != nil {
+			errs = append(errs, &xdsresource.DecodeError{Index: idx, Err: err})
+			continue
+		}
+		events = append(events, event)
+	}
+	for _, name := range resp.RemovedResources {
+		events = append(events, xdsresource.DiscoveryEvent{
+			Typ:  xdsresource.VirtualHostRemoved,
+			Name: name,
+		})
+	}
+
+	for _, event := range events {
+		if c.handle != nil {
+			c.handle(event)
+		}
+	}
+
+	req := &discoveryv3.DeltaDiscoveryRequest{
+		Node:          c.node,
+		TypeUrl:       resp.TypeUrl,
+		ResponseNonce: resp.Nonce,
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("[xds] failed to decode delta response: %v", err)
+		c.reportNACK(&discoveryv3.DiscoveryResponse{
+			TypeUrl:     resp.TypeUrl,
+			VersionInfo: resp.SystemVersionInfo,
+			Nonce:       resp.Nonce,
+		}, err)
+		req.ErrorDetail = &status.Status{Message: err.Error()}
+	}
+	c.queueDelta(req)
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	routeType "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestVirtualHostLookupSubscribesAndRoutes(t *testing.T) {
+	fake := &fakeADS{}
+	core := &xdsCore{
+		cfg: Config{EnableVHDS: true},
+		apps: map[string]*appInfo{
+			"svc": {listeners: map[string]bool{"listener-1": true}},
+		},
+		listeners: map[string]*xdsresource.ListenerSnapshot{
+			"listener-1": {Route: "route-1"},
+		},
+		routes: map[string]*xdsresource.RouteSnapshot{
+			"route-1": {
+				VHDS: true,
+				Vhosts: []*xdsresource.VirtualHost{{
+					Name:    "known",
+					Domains: []string{"known.example.com"},
+					Routes: []*xdsresource.Route{{
+						Action: &xdsresource.RouteAction{Cluster: "cluster-known"},
+					}},
+				}},
+			},
+		},
+		clusters:     make(map[string]*xdsresource.ClusterSnapshot),
+		endpoints:    make(map[string]*xdsresource.EDSSnapshot),
+		vhdsHosts:    make(map[string]*vhdsHost),
+		virtualHosts: make(map[string]*xdsresource.VirtualHost),
+		ads:          fake,
+	}
+
+	attributes := core.buildResolverAttributes(core.apps["svc"])
+	lookup, ok := attributes[xdsresource.AttributeVirtualHostLookup].(xdsresource.VirtualHostLookup)
+	if !ok {
+		t.Fatalf("lookup attribute = %T, want VirtualHostLookup",
+			attributes[xdsresource.AttributeVirtualHostLookup])
+	}
+	if !lookup("orders.example.com") {
+		t.Fatal("lookup(unknown host) = false, want outstanding")
+	}
+	if want := []string{"route-1/orders.example.com"}; !reflect.DeepEqual(fake.vhds, want) {
+		t.Fatalf("vhds subscriptions = %v, want %v", fake.vhds, want)
+	}
+	if !lookup("orders.example.com") {
+		t.Fatal("lookup(pending host) = false, want outstanding until answered")
+	}
+
+	core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.VirtualHostAdded,
+		Name: "route-1/orders.example.com",
+		Data: &xdsresource.VirtualHost{
+			Name:    "orders",
+			Domains: []string{"orders.example.com"},
+			Routes: []*xdsresource.Route{{
+				Action: &xdsresource.RouteAction{Cluster: "cluster-orders"},
+			}},
+		},
+	})
+	if lookup("orders.example.com") {
+		t.Fatal("lookup(answered host) = true, want done")
+	}
+
+	attributes = core.buildResolverAttributes(core.apps["svc"])
+	vhosts, _ := attributes[xdsresource.AttributeRoutes].([]*xdsresource.VirtualHost)
+	headers := map[string]string{":authority": "orders.example.com:443"}
+	action := xdsresource.MatchRoute(vhosts, "/orders.v1.Orders/Get", headers)
+	if action == nil || action.Cluster != "cluster-orders" {
+		t.Fatalf("route action = %#v, want cluster-orders", action)
+	}
+	if !sameStrings(fake.cds, []string{"cluster-known", "cluster-orders"}) {
+		t.Fatalf("cds subscriptions = %v, want the discovered cluster too", fake.cds)
+	}
+
+	core.cfg.EnableVHDS = false
+	attributes = core.buildResolverAttributes(core.apps["svc"])
+	if _, ok := attributes[xdsresource.AttributeVirtualHostLookup]; ok {
+		t.Fatal("lookup attribute set while VHDS is disabled")
+	}
+}
+
+func TestVirtualHostLookupEvictsLeastRecentHost(t *testing.T) {
+	fake := &fakeADS{}
+	core := &xdsCore{
+		cfg: Config{EnableVHDS: true},
+		apps: map[string]*appInfo{
+			"svc": {listeners: map[string]bool{"listener-1": true}},
+		},
+		listeners: map[string]*xdsresource.ListenerSnapshot{
+			"listener-1": {Route: "route-1"},
+		},
+		routes: map[string]*xdsresource.RouteSnapshot{
+			"route-1": {VHDS: true},
+		},
+		clusters:     make(map[string]*xdsresource.ClusterSnapshot),
+		endpoints:    make(map[string]*xdsresource.EDSSnapshot),
+		vhdsHosts:    make(map[string]*vhdsHost),
+		virtualHosts: make(map[string]*xdsresource.VirtualHost),
+		ads:          fake,
+	}
+
+	for i := range maxVHDSHosts {
+		core.lookupVirtualHost(fmt.Sprintf("host-%d.example.com", i))
+	}
+	core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.VirtualHostAdded,
+		Name: "route-1/host-0.example.com",
+		Data: &xdsresource.VirtualHost{Name: "host-0"},
+	})
+	// Looking host-0 up again makes host-1 the least recently used.
+	core.lookupVirtualHost("host-0.example.com")
+	if !core.lookupVirtualHost("extra.example.com") {
+		t.Fatal("lookup(new host) = false, want outstanding")
+	}
+
+	if len(core.vhdsHosts) != maxVHDSHosts || len(fake.vhds) != maxVHDSHosts {
+		t.Fatalf("hosts = %d, vhds subscriptions = %d, want %d",
+			len(core.vhdsHosts), len(fake.vhds), maxVHDSHosts)
+	}
+	if _, ok := core.vhdsHosts["host-1.example.com"]; ok {
+		t.Fatal("least recently looked-up host was not evicted")
+	}
+	if core.virtualHosts["route-1/host-0.example.com"] == nil {
+		t.Fatal("recently looked-up host lost its answer")
+	}
+
+	core.handleDiscoveryEvent(xdsresource.DiscoveryEvent{
+		Typ:  xdsresource.VirtualHostAdded,
+		Name: "route-1/host-1.example.com",
+		Data: &xdsresource.VirtualHost{Name: "host-1"},
+	})
+	if _, ok := core.virtualHosts["route-1/host-1.example.com"]; ok {
+		t.Fatal("late answer for an evicted host was kept")
+	}
+}
+
+func TestADSClientVirtualHostDeltaRequests(t *testing.T) {
+	var events []xdsresource.DiscoveryEvent
+	client, err := newADSClient(Config{EnableVHDS: true}, func(event xdsresource.DiscoveryEvent) {
+		events = append(events, event)
+	})
+	if err != nil {
+		t.Fatalf("newADSClient() error = %v", err)
+	}
+	defer client.Close()
+
+	client.UpdateSubscriptions(subscriptions{vhds: []string{"route-1/orders.example.com"}})
+	req := <-client.deltaSendCh
+	if req.TypeUrl != resource.VirtualHostType ||
+		!reflect.DeepEqual(req.ResourceNamesSubscribe, []string{"route-1/orders.example.com"}) {
+		t.Fatalf("delta subscribe request = %v", req)
+	}
+
+	virtualHost, err := anypb.New(&routeType.VirtualHost{
+		Name:    "orders",
+		Domains: []string{"orders.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	client.handleDeltaResponse(&discoveryv3.DeltaDiscoveryResponse{
+		TypeUrl: resource.VirtualHostType,
+		Nonce:   "n1",
+		Resources: []*discoveryv3.Resource{{
+			Name:     "route-1/orders.example.com",
+			Resource: virtualHost,
+		}},
+		RemovedResources: []string{"route-1/gone.example.com"},
+	})
+	if len(events) != 2 || events[0].Typ != xdsresource.VirtualHostAdded ||
+		events[0].Name != "route-1/orders.example.com" ||
+		events[1].Typ != xdsresource.VirtualHostRemoved {
+		t.Fatalf("events = %#v, want added and removed virtual hosts", events)
+	}
+	if ack := <-client.deltaSendCh; ack.ResponseNonce != "n1" || ack.ErrorDetail != nil {
+		t.Fatalf("delta ACK = %v", ack)
+	}
+
+	client.UpdateSubscriptions(subscriptions{})
+	req = <-client.deltaSendCh
+	if !reflect.DeepEqual(req.ResourceNamesUnsubscribe, []string{"route-1/orders.example.com"}) {
+		t.Fatalf("delta unsubscribe request = %v", req)
+	}
+}
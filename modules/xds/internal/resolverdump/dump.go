@@ -0,0 +1,143 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resolverdump copies the resolver state a balancer last received into
+// a JSON-friendly snapshot for the stats debug handler.
+package resolverdump
+
+import (
+	"fmt"
+	"math"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+// Snapshot is a JSON-friendly copy of a resolver.State.
+type Snapshot struct {
+	Endpoints  []Endpoint     `json:"endpoints"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Endpoint is a JSON-friendly copy of a resolver.Endpoint. Weight and
+// priority are lifted from the endpoint attributes when present.
+type Endpoint struct {
+	Name       string         `json:"name"`
+	Address    string         `json:"address"`
+	Protocol   string         `json:"protocol"`
+	Weight     *int64         `json:"weight,omitempty"`
+	Priority   *int64         `json:"priority,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// FromState copies state into a Snapshot. Nested maps and slices are copied
+// value by value; NaN and infinite floats, and values that are not scalars,
+// strings, or simple collections are rendered as their String() form or
+// their type name, so the snapshot is always safe to marshal.
+func FromState(state resolver.State) Snapshot {
+	if state == nil {
+		return Snapshot{Endpoints: []Endpoint{}}
+	}
+	endpoints := state.GetEndpoints()
+	out := Snapshot{
+		Endpoints:  make([]Endpoint, 0, len(endpoints)),
+		Attributes: sanitizeAttributes(state.GetAttributes()),
+	}
+	for _, ep := range endpoints {
+		if ep == nil {
+			continue
+		}
+		attrs := ep.GetAttributes()
+		out.Endpoints = append(out.Endpoints, Endpoint{
+			Name:       ep.Name(),
+			Address:    ep.GetAddress(),
+			Protocol:   ep.GetProtocol(),
+			Weight:     integerAttribute(attrs, xdsresource.AttributeEndpointWeight),
+			Priority:   integerAttribute(attrs, xdsresource.AttributeEndpointPriority),
+			Attributes: sanitizeAttributes(attrs),
+		})
+	}
+	return out
+}
+
+func sanitizeAttributes(attrs map[string]any) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(attrs))
+	for key, value := range attrs {
+		out[key] = sanitizeValue(value)
+	}
+	return out
+}
+
+func sanitizeValue(value any) any {
+	switch v := value.(type) {
+	case nil, string, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		[]string, map[string]string:
+		return v
+	case float32:
+		return sanitizeFloat(float64(v), v)
+	case float64:
+		return sanitizeFloat(v, v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = sanitizeValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = sanitizeValue(item)
+		}
+		return out
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprintf("<%T>", v)
+	}
+}
+
+// sanitizeFloat keeps value unless f is NaN or infinite, which JSON cannot
+// encode; those become "NaN", "+Inf" or "-Inf".
+func sanitizeFloat(f float64, value any) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return value
+}
+
+func integerAttribute(attrs map[string]any, key string) *int64 {
+	var n int64
+	switch v := attrs[key].(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint32:
+		n = int64(v)
+	case uint64:
+		n = int64(v) //nolint:gosec // weights and priorities are small
+	default:
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolverdump
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+type opaque struct{ n int }
+
+func weightedState() resolver.State {
+	return resolver.BaseState{
+		Attributes: map[string]any{
+			"revision": "r1",
+			"routes":   &opaque{n: 1},
+		},
+		Endpoints: []resolver.Endpoint{
+			resolver.BaseEndpoint{
+				Address:  "10.0.0.1:8080",
+				Protocol: "grpc",
+				Attributes: map[string]any{
+					"weight":   uint32(80),
+					"priority": uint32(0),
+					"zone":     "zone-a",
+				},
+			},
+			resolver.BaseEndpoint{
+				Address:  "10.0.0.2:8080",
+				Protocol: "grpc",
+				Attributes: map[string]any{
+					"weight":   20,
+					"priority": 1,
+					"zone":     "zone-b",
+				},
+			},
+		},
+	}
+}
+
+func TestFromStateListsWeightedEndpoints(t *testing.T) {
+	raw, err := json.Marshal(FromState(weightedState()))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got Snapshot
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v\n%s", err, raw)
+	}
+	if len(got.Endpoints) != 2 {
+		t.Fatalf("endpoints = %d, want 2", len(got.Endpoints))
+	}
+	if got.Attributes["revision"] != "r1" || got.Attributes["routes"] != "<*resolverdump.opaque>" {
+		t.Fatalf("attributes = %v, want the revision and the routes type name", got.Attributes)
+	}
+	for i, want := range []struct {
+		address string
+		weight  int64
+		zone    string
+	}{
+		{address: "10.0.0.1:8080", weight: 80, zone: "zone-a"},
+		{address: "10.0.0.2:8080", weight: 20, zone: "zone-b"},
+	} {
+		ep := got.Endpoints[i]
+		if ep.Address != want.address || ep.Weight == nil || *ep.Weight != want.weight {
+			t.Errorf("endpoint[%d] = %+v, want %s weight %d", i, ep, want.address, want.weight)
+		}
+		if ep.Attributes["zone"] != want.zone {
+			t.Errorf("endpoint[%d] zone = %v, want %s", i, ep.Attributes["zone"], want.zone)
+		}
+	}
+}
+
+func TestFromStateSanitizesNestedAndNonFiniteValues(t *testing.T) {
+	state := resolver.BaseState{
+		Attributes: map[string]any{
+			"ratio": math.NaN(),
+			"limit": float32(math.Inf(1)),
+			"policy": map[string]any{
+				"floor":  math.Inf(-1),
+				"chan":   make(chan int),
+				"stages": []any{1.5, math.NaN()},
+			},
+		},
+	}
+	raw, err := json.Marshal(FromState(state))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got Snapshot
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v\n%s", err, raw)
+	}
+	if got.Attributes["ratio"] != "NaN" || got.Attributes["limit"] != "+Inf" {
+		t.Fatalf("attributes = %v, want NaN and +Inf as strings", got.Attributes)
+	}
+	policy, ok := got.Attributes["policy"].(map[string]any)
+	if !ok {
+		t.Fatalf("policy = %#v, want a nested object", got.Attributes["policy"])
+	}
+	if policy["floor"] != "-Inf" || policy["chan"] != "<chan int>" {
+		t.Fatalf("policy = %v, want -Inf and the channel type name", policy)
+	}
+	stages, _ := policy["stages"].([]any)
+	if len(stages) != 2 || stages[0] != 1.5 || stages[1] != "NaN" {
+		t.Fatalf("stages = %#v, want [1.5 NaN]", policy["stages"])
+	}
+}
+
+func TestFromStateNil(t *testing.T) {
+	if got := FromState(nil); got.Endpoints == nil || len(got.Endpoints) != 0 {
+		t.Fatalf("FromState(nil) = %#v, want no endpoints", got)
+	}
+}
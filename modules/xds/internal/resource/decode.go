@@ -15,8 +15,11 @@
 package resource
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	clusterType "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
@@ -24,9 +27,15 @@ import (
 	endpointType "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	listenerType "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	routeType "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	faultType "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	hcmType "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlsType "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcherType "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	typeV3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 const (
@@ -35,21 +44,50 @@ const (
 	typeURLCluster  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
 	typeURLEndpoint = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
 
+	typeURLScopedRoute = "type.googleapis.com/envoy.config.route.v3.ScopedRouteConfiguration"
+	typeURLVirtualHost = "type.googleapis.com/envoy.config.route.v3.VirtualHost"
+
 	httpConnectionManagerFilter = "envoy.filters.network.http_connection_manager"
+	httpFaultFilter             = "envoy.filters.http.fault"
+	lbMetadataKey               = "envoy.lb"
 	rateLimitMetadataKey        = "yggdrasil.rate_limit"
+	globalRateLimitMetadataKey  = "yggdrasil.global_rate_limit"
+	securityMetadataKey         = "yggdrasil.security"
+
+	endpointCircuitBreakerMetadataKey = "yggdrasil.endpoint_circuit_breaker"
+	outlierDetectionMetadataKey       = "yggdrasil.outlier_detection"
 )
 
+// DecodeError reports which resource of a DiscoveryResponse failed to decode.
+type DecodeError struct {
+	Index int
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("resource %d: %v", e.Index, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 // DecodeDiscoveryResponse decodes a DiscoveryResponse resource list into events.
+// Resources that fail to decode are skipped: the events of the others are
+// still returned, together with one *DecodeError per failed resource joined
+// into a single error.
 func DecodeDiscoveryResponse(typeURL string, resources []*anypb.Any) ([]DiscoveryEvent, error) {
 	events := make([]DiscoveryEvent, 0, len(resources))
-	for _, item := range resources {
+	var errs []error
+	for idx, item := range resources {
 		decoded, err := DecodeDiscoveryResource(typeURL, item)
 		if err != nil {
-			return nil, err
+			errs = append(errs, &DecodeError{Index: idx, Err: err})
+			continue
 		}
 		events = append(events, decoded...)
 	}
-	return events, nil
+	return events, errors.Join(errs...)
 }
 
 // DecodeDiscoveryResource decodes one xDS resource into events.
@@ -79,11 +117,35 @@ func DecodeDiscoveryResource(typeURL string, item *anypb.Any) ([]DiscoveryEvent,
 			return nil, fmt.Errorf("unmarshal endpoint: %w", err)
 		}
 		return parseEndpoint(resource), nil
+	case typeURLScopedRoute:
+		resource := &routeType.ScopedRouteConfiguration{}
+		if err := item.UnmarshalTo(resource); err != nil {
+			return nil, fmt.Errorf("unmarshal scoped route: %w", err)
+		}
+		return parseScopedRoute(resource), nil
 	default:
 		return nil, fmt.Errorf("unknown type URL: %s", typeURL)
 	}
 }
 
+// DecodeVirtualHost decodes one VHDS resource. VHDS resources are delivered
+// over the delta protocol, so the event takes the resource name rather than
+// the virtual host name.
+func DecodeVirtualHost(name string, item *anypb.Any) (DiscoveryEvent, error) {
+	if item.GetTypeUrl() != typeURLVirtualHost {
+		return DiscoveryEvent{}, fmt.Errorf("unexpected virtual host type: %s", item.GetTypeUrl())
+	}
+	virtualHost := &routeType.VirtualHost{}
+	if err := item.UnmarshalTo(virtualHost); err != nil {
+		return DiscoveryEvent{}, fmt.Errorf("unmarshal virtual host: %w", err)
+	}
+	return DiscoveryEvent{
+		Typ:  VirtualHostAdded,
+		Name: name,
+		Data: parseVirtualHost(virtualHost),
+	}, nil
+}
+
 func parseListener(listener *listenerType.Listener) []DiscoveryEvent {
 	if listener == nil || listener.Name == "" {
 		return nil
@@ -92,11 +154,41 @@ func parseListener(listener *listenerType.Listener) []DiscoveryEvent {
 	return []DiscoveryEvent{{
 		Typ:  ListenerAdded,
 		Name: listener.Name,
-		Data: &ListenerSnapshot{Route: routeNameForListener(listener)},
+		Data: &ListenerSnapshot{
+			Route:        routeNameForListener(listener),
+			ScopedRoutes: scopedRoutesForListener(listener),
+		},
 	}}
 }
 
 func routeNameForListener(listener *listenerType.Listener) string {
+	manager, found := httpConnectionManager(listener)
+	if !found {
+		return ""
+	}
+
+	switch specifier := manager.GetRouteSpecifier().(type) {
+	case *hcmType.HttpConnectionManager_Rds:
+		if specifier.Rds != nil && specifier.Rds.RouteConfigName != "" {
+			return specifier.Rds.RouteConfigName
+		}
+	case *hcmType.HttpConnectionManager_RouteConfig:
+		if specifier.RouteConfig != nil && specifier.RouteConfig.Name != "" {
+			return specifier.RouteConfig.Name
+		}
+	case *hcmType.HttpConnectionManager_ScopedRoutes:
+		return ""
+	}
+
+	return listener.Name
+}
+
+// httpConnectionManager returns the HTTP connection manager of the first
+// filter chain that has one. found is set even when its typed config cannot
+// be decoded, in which case the manager is nil.
+func httpConnectionManager(
+	listener *listenerType.Listener,
+) (manager *hcmType.HttpConnectionManager, found bool) {
 	for _, filterChain := range listener.FilterChains {
 		for _, filter := range filterChain.Filters {
 			if filter.Name != httpConnectionManagerFilter {
@@ -105,23 +197,80 @@ func routeNameForListener(listener *listenerType.Listener) string {
 
 			manager := &hcmType.HttpConnectionManager{}
 			if typed := filter.GetTypedConfig(); typed != nil && typed.UnmarshalTo(manager) == nil {
-				switch specifier := manager.RouteSpecifier.(type) {
-				case *hcmType.HttpConnectionManager_Rds:
-					if specifier.Rds != nil && specifier.Rds.RouteConfigName != "" {
-						return specifier.Rds.RouteConfigName
-					}
-				case *hcmType.HttpConnectionManager_RouteConfig:
-					if specifier.RouteConfig != nil && specifier.RouteConfig.Name != "" {
-						return specifier.RouteConfig.Name
-					}
-				}
+				return manager, true
 			}
+			return nil, true
+		}
+	}
 
-			return listener.Name
+	return nil, false
+}
+
+// scopedRoutesForListener returns the scoped_routes specifier of the
+// listener's HTTP connection manager, or nil when it uses another one.
+func scopedRoutesForListener(listener *listenerType.Listener) *ScopedRoutesConfig {
+	manager, _ := httpConnectionManager(listener)
+	scoped := manager.GetScopedRoutes()
+	if scoped == nil {
+		return nil
+	}
+
+	config := &ScopedRoutesConfig{
+		KeyBuilder: parseScopeKeyBuilder(scoped.GetScopeKeyBuilder()),
+		SRDS:       scoped.GetScopedRds() != nil,
+	}
+	for _, item := range scoped.GetScopedRouteConfigurationsList().GetScopedRouteConfigurations() {
+		if scope := parseScopedRouteConfig(item); scope != nil {
+			config.Scopes = append(config.Scopes, scope)
 		}
 	}
+	return config
+}
+
+func parseScopeKeyBuilder(builder *hcmType.ScopedRoutes_ScopeKeyBuilder) *ScopeKeyBuilder {
+	parsed := &ScopeKeyBuilder{}
+	for _, fragment := range builder.GetFragments() {
+		extractor := fragment.GetHeaderValueExtractor()
+		parsed.Fragments = append(parsed.Fragments, &ScopeKeyFragment{
+			Header:           strings.ToLower(extractor.GetName()),
+			ElementSeparator: extractor.GetElementSeparator(),
+			Index:            extractor.GetIndex(),
+			ElementKey:       extractor.GetElement().GetKey(),
+			KeySeparator:     extractor.GetElement().GetSeparator(),
+		})
+	}
+	return parsed
+}
+
+func parseScopedRoute(scopedRoute *routeType.ScopedRouteConfiguration) []DiscoveryEvent {
+	scope := parseScopedRouteConfig(scopedRoute)
+	if scope == nil {
+		return nil
+	}
+
+	return []DiscoveryEvent{{
+		Typ:  ScopedRouteAdded,
+		Name: scope.Name,
+		Data: scope,
+	}}
+}
+
+func parseScopedRouteConfig(scopedRoute *routeType.ScopedRouteConfiguration) *ScopedRouteSnapshot {
+	if scopedRoute == nil || scopedRoute.Name == "" {
+		return nil
+	}
 
-	return ""
+	scope := &ScopedRouteSnapshot{
+		Name:        scopedRoute.Name,
+		RouteConfig: scopedRoute.RouteConfigurationName,
+	}
+	for _, fragment := range scopedRoute.GetKey().GetFragments() {
+		scope.Key = append(scope.Key, fragment.GetStringKey())
+	}
+	if inline := scopedRoute.GetRouteConfiguration(); inline != nil {
+		scope.Inline = parseRouteSnapshot(inline)
+	}
+	return scope
 }
 
 func parseRoute(routeConfig *routeType.RouteConfiguration) []DiscoveryEvent {
@@ -129,18 +278,22 @@ func parseRoute(routeConfig *routeType.RouteConfiguration) []DiscoveryEvent {
 		return nil
 	}
 
+	return []DiscoveryEvent{{
+		Typ:  RouteAdded,
+		Name: routeConfig.Name,
+		Data: parseRouteSnapshot(routeConfig),
+	}}
+}
+
+func parseRouteSnapshot(routeConfig *routeType.RouteConfiguration) *RouteSnapshot {
 	snapshot := &RouteSnapshot{
 		Vhosts: make([]*VirtualHost, 0, len(routeConfig.VirtualHosts)),
+		VHDS:   routeConfig.GetVhds() != nil,
 	}
 	for _, virtualHost := range routeConfig.VirtualHosts {
 		snapshot.Vhosts = append(snapshot.Vhosts, parseVirtualHost(virtualHost))
 	}
-
-	return []DiscoveryEvent{{
-		Typ:  RouteAdded,
-		Name: routeConfig.Name,
-		Data: snapshot,
-	}}
+	return snapshot
 }
 
 func parseVirtualHost(virtualHost *routeType.VirtualHost) *VirtualHost {
@@ -153,6 +306,7 @@ func parseVirtualHost(virtualHost *routeType.VirtualHost) *VirtualHost {
 		parsed.Routes = append(parsed.Routes, &Route{
 			Match:  parseRouteMatch(route.Match),
 			Action: parseRouteAction(route.GetRoute()),
+			Fault:  parseRouteFault(route.GetTypedPerFilterConfig()),
 		})
 	}
 	return parsed
@@ -174,6 +328,9 @@ func parseCluster(cluster *clusterType.Cluster) []DiscoveryEvent {
 		snapshot.Policy.LBPolicy = "random"
 	case clusterType.Cluster_LEAST_REQUEST:
 		snapshot.Policy.LBPolicy = "least_request"
+		if cluster.GetLeastRequestLbConfig().GetChoiceCount().GetValue() == 2 {
+			snapshot.Policy.LBPolicy = "least_request_p2c"
+		}
 	default:
 		snapshot.Policy.LBPolicy = "round_robin"
 	}
@@ -194,31 +351,20 @@ func parseCluster(cluster *clusterType.Cluster) []DiscoveryEvent {
 	}
 
 	if cluster.OutlierDetection != nil {
-		outlier := cluster.OutlierDetection
-		snapshot.Policy.OutlierDetection = &OutlierDetectionConfig{
-			Consecutive5xx:                 outlier.GetConsecutive_5Xx().GetValue(),
-			ConsecutiveGatewayFailure:      outlier.GetConsecutiveGatewayFailure().GetValue(),
-			ConsecutiveLocalOriginFailure:  outlier.GetConsecutiveLocalOriginFailure().GetValue(),
-			Interval:                       outlier.GetInterval().AsDuration(),
-			BaseEjectionTime:               outlier.GetBaseEjectionTime().AsDuration(),
-			MaxEjectionTime:                outlier.GetMaxEjectionTime().AsDuration(),
-			MaxEjectionPercent:             outlier.GetMaxEjectionPercent().GetValue(),
-			EnforcingConsecutive5xx:        outlier.GetEnforcingConsecutive_5Xx().GetValue(),
-			EnforcingSuccessRate:           outlier.GetEnforcingSuccessRate().GetValue(),
-			SuccessRateMinimumHosts:        outlier.GetSuccessRateMinimumHosts().GetValue(),
-			SuccessRateRequestVolume:       outlier.GetSuccessRateRequestVolume().GetValue(),
-			SuccessRateStdevFactor:         outlier.GetSuccessRateStdevFactor().GetValue(),
-			FailurePercentageThreshold:     outlier.GetFailurePercentageThreshold().GetValue(),
-			EnforcingFailurePercentage:     outlier.GetEnforcingFailurePercentage().GetValue(),
-			FailurePercentageMinimumHosts:  outlier.GetFailurePercentageMinimumHosts().GetValue(),
-			FailurePercentageRequestVolume: outlier.GetFailurePercentageRequestVolume().GetValue(),
-			SplitExternalLocalOriginErrors: outlier.SplitExternalLocalOriginErrors,
-		}
+		snapshot.Policy.OutlierDetection = parseOutlierDetection(cluster.OutlierDetection)
+		snapshot.Policy.OutlierDetection.StatsWindowIntervals =
+			parseOutlierStatsWindow(cluster.Metadata)
 	}
 
 	if limiter := parseRateLimiter(cluster.Metadata); limiter != nil {
 		snapshot.Policy.RateLimiter = limiter
 	}
+	snapshot.Policy.GlobalRateLimit = parseGlobalRateLimit(cluster.Metadata)
+	snapshot.Policy.Subset = parseSubsetConfig(cluster.GetLbSubsetConfig())
+	if breaker := parseEndpointCircuitBreaker(cluster.Metadata); breaker != nil {
+		snapshot.Policy.EndpointCircuitBreaker = breaker
+	}
+	snapshot.Policy.ALPNProtocols = parseUpstreamALPN(cluster.TransportSocket)
 
 	return []DiscoveryEvent{{
 		Typ:  ClusterAdded,
@@ -227,6 +373,63 @@ func parseCluster(cluster *clusterType.Cluster) []DiscoveryEvent {
 	}}
 }
 
+// parseOutlierDetection decodes an outlier detection block. Fields left unset
+// take Envoy's documented defaults rather than zero, so an empty block enables
+// consecutive 5xx ejection the same way it does in Envoy.
+func parseOutlierDetection(o *clusterType.OutlierDetection) *OutlierDetectionConfig {
+	return &OutlierDetectionConfig{
+		Consecutive5xx:                 uint32Or(o.GetConsecutive_5Xx(), 5),
+		ConsecutiveGatewayFailure:      uint32Or(o.GetConsecutiveGatewayFailure(), 5),
+		ConsecutiveLocalOriginFailure:  uint32Or(o.GetConsecutiveLocalOriginFailure(), 5),
+		Interval:                       durationOr(o.GetInterval(), 10*time.Second),
+		BaseEjectionTime:               durationOr(o.GetBaseEjectionTime(), 30*time.Second),
+		MaxEjectionTime:                durationOr(o.GetMaxEjectionTime(), 300*time.Second),
+		MaxEjectionPercent:             uint32Or(o.GetMaxEjectionPercent(), 10),
+		EnforcingConsecutive5xx:        uint32Or(o.GetEnforcingConsecutive_5Xx(), 100),
+		EnforcingSuccessRate:           uint32Or(o.GetEnforcingSuccessRate(), 100),
+		SuccessRateMinimumHosts:        uint32Or(o.GetSuccessRateMinimumHosts(), 5),
+		SuccessRateRequestVolume:       uint32Or(o.GetSuccessRateRequestVolume(), 100),
+		SuccessRateStdevFactor:         uint32Or(o.GetSuccessRateStdevFactor(), 1900),
+		FailurePercentageThreshold:     uint32Or(o.GetFailurePercentageThreshold(), 85),
+		EnforcingFailurePercentage:     o.GetEnforcingFailurePercentage().GetValue(),
+		FailurePercentageMinimumHosts:  uint32Or(o.GetFailurePercentageMinimumHosts(), 5),
+		FailurePercentageRequestVolume: uint32Or(o.GetFailurePercentageRequestVolume(), 50),
+		SplitExternalLocalOriginErrors: o.GetSplitExternalLocalOriginErrors(),
+	}
+}
+
+func uint32Or(value *wrapperspb.UInt32Value, def uint32) uint32 {
+	if value == nil {
+		return def
+	}
+	return value.GetValue()
+}
+
+func durationOr(value *durationpb.Duration, def time.Duration) time.Duration {
+	if value == nil {
+		return def
+	}
+	return value.AsDuration()
+}
+
+// parseUpstreamALPN returns the ALPN list of an upstream TLS transport socket,
+// or nil when the socket is absent or not TLS.
+func parseUpstreamALPN(socket *corev3.TransportSocket) []string {
+	typed := socket.GetTypedConfig()
+	if typed == nil {
+		return nil
+	}
+	var tlsContext tlsType.UpstreamTlsContext
+	if err := typed.UnmarshalTo(&tlsContext); err != nil {
+		return nil
+	}
+	alpn := tlsContext.GetCommonTlsContext().GetAlpnProtocols()
+	if len(alpn) == 0 {
+		return nil
+	}
+	return append([]string(nil), alpn...)
+}
+
 func parseRateLimiter(metadata *corev3.Metadata) *RateLimiterConfig {
 	if metadata == nil || metadata.FilterMetadata == nil {
 		return nil
@@ -249,6 +452,84 @@ func parseRateLimiter(metadata *corev3.Metadata) *RateLimiterConfig {
 	}
 }
 
+// parseSubsetConfig reads the fallback of a cluster's lb_subset_config. Subset
+// selectors are not needed: routes match endpoint metadata directly.
+func parseSubsetConfig(config *clusterType.Cluster_LbSubsetConfig) *SubsetConfig {
+	if config == nil {
+		return nil
+	}
+	subset := &SubsetConfig{FallbackPolicy: SubsetNoFallback}
+	switch config.GetFallbackPolicy() {
+	case clusterType.Cluster_LbSubsetConfig_ANY_ENDPOINT:
+		subset.FallbackPolicy = SubsetAnyEndpoint
+	case clusterType.Cluster_LbSubsetConfig_DEFAULT_SUBSET:
+		subset.FallbackPolicy = SubsetDefaultSubset
+		subset.DefaultSubset = structStrings(config.GetDefaultSubset())
+	}
+	return subset
+}
+
+// parseGlobalRateLimit reads {domain, descriptors: [{key, header | value}]}
+// from the global rate limit metadata. It returns nil without a domain or
+// usable descriptor entry.
+func parseGlobalRateLimit(metadata *corev3.Metadata) *GlobalRateLimitConfig {
+	fields := metadata.GetFilterMetadata()[globalRateLimitMetadataKey].GetFields()
+	domain := fields["domain"].GetStringValue()
+	if domain == "" {
+		return nil
+	}
+
+	config := &GlobalRateLimitConfig{Domain: domain}
+	for _, value := range fields["descriptors"].GetListValue().GetValues() {
+		entry := value.GetStructValue().GetFields()
+		descriptor := RateLimitDescriptorEntry{
+			Key:    entry["key"].GetStringValue(),
+			Header: entry["header"].GetStringValue(),
+			Value:  entry["value"].GetStringValue(),
+		}
+		if descriptor.Key == "" || (descriptor.Header == "" && descriptor.Value == "") {
+			continue
+		}
+		config.Entries = append(config.Entries, descriptor)
+	}
+	if len(config.Entries) == 0 {
+		return nil
+	}
+	return config
+}
+
+// parseOutlierStatsWindow reads the number of intervals outlier statistics
+// cover from the yggdrasil.outlier_detection cluster metadata.
+func parseOutlierStatsWindow(metadata *corev3.Metadata) uint32 {
+	fields := metadata.GetFilterMetadata()[outlierDetectionMetadataKey].GetFields()
+	return uint32(numberValue(fields["stats_window_intervals"]))
+}
+
+func parseEndpointCircuitBreaker(metadata *corev3.Metadata) *EndpointCircuitBreakerConfig {
+	if metadata == nil || metadata.FilterMetadata == nil {
+		return nil
+	}
+
+	config := metadata.FilterMetadata[endpointCircuitBreakerMetadataKey]
+	if config == nil {
+		return nil
+	}
+
+	fields := config.GetFields()
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &EndpointCircuitBreakerConfig{
+		FailureRateThreshold: uint32(numberValue(fields["failure_rate_threshold"])),
+		RequestVolume:        uint32(numberValue(fields["request_volume"])),
+		Interval:             time.Duration(numberValue(fields["interval"]) * float64(time.Second)),
+		OpenDuration: time.Duration(
+			numberValue(fields["open_duration"]) * float64(time.Second),
+		),
+	}
+}
+
 func numberValue(value *structpb.Value) float64 {
 	if value == nil {
 		return 0
@@ -310,13 +591,71 @@ func parseLBEndpoint(
 		}
 	}
 
+	metadata := parseEndpointMetadata(locality, lbEndpoint.GetHealthStatus())
+	for key, value := range lbMetadata(lbEndpoint.GetMetadata()) {
+		// Locality and health keys set above take precedence.
+		if _, ok := metadata[key]; !ok {
+			metadata[key] = value
+		}
+	}
+
 	return &WeightedEndpoint{
 		Cluster:  clusterName,
 		Endpoint: endpoint,
 		Weight:   weight * localityWeight,
 		Priority: priority,
-		Metadata: parseEndpointMetadata(locality, lbEndpoint.GetHealthStatus()),
+		Metadata: metadata,
+		Identity: parseEndpointIdentity(lbEndpoint.GetMetadata()),
+	}
+}
+
+func parseEndpointIdentity(metadata *corev3.Metadata) *EndpointIdentity {
+	if metadata == nil {
+		return nil
+	}
+
+	fields := metadata.FilterMetadata[securityMetadataKey].GetFields()
+	if len(fields) == 0 {
+		return nil
+	}
+
+	identity := &EndpointIdentity{SPIFFEID: fields["spiffe_id"].GetStringValue()}
+	for _, value := range fields["subject_alt_names"].GetListValue().GetValues() {
+		if name := value.GetStringValue(); name != "" {
+			identity.SubjectAltNames = append(identity.SubjectAltNames, name)
+		}
 	}
+	if identity.SPIFFEID == "" && len(identity.SubjectAltNames) == 0 {
+		return nil
+	}
+	return identity
+}
+
+// lbMetadata returns the envoy.lb filter metadata used for subset load
+// balancing, with numbers and bools formatted as strings. It returns nil when
+// there is none.
+func lbMetadata(metadata *corev3.Metadata) map[string]string {
+	return structStrings(metadata.GetFilterMetadata()[lbMetadataKey])
+}
+
+// structStrings flattens the scalar fields of a struct into strings.
+func structStrings(value *structpb.Struct) map[string]string {
+	fields := value.GetFields()
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(fields))
+	for key, value := range fields {
+		switch kind := value.GetKind().(type) {
+		case *structpb.Value_StringValue:
+			out[key] = kind.StringValue
+		case *structpb.Value_NumberValue:
+			out[key] = strconv.FormatFloat(kind.NumberValue, 'f', -1, 64)
+		case *structpb.Value_BoolValue:
+			out[key] = strconv.FormatBool(kind.BoolValue)
+		}
+	}
+	return out
 }
 
 func parseEndpointMetadata(
@@ -356,6 +695,50 @@ func endpointHealthStatus(status corev3.HealthStatus) string {
 	}
 }
 
+// parseRouteFault reads the fixed delay and abort of a route's fault filter
+// override. Header-controlled faults are not supported and are ignored.
+func parseRouteFault(configs map[string]*anypb.Any) *FaultConfig {
+	typed := configs[httpFaultFilter]
+	if typed == nil {
+		return nil
+	}
+	var httpFault faultType.HTTPFault
+	if err := typed.UnmarshalTo(&httpFault); err != nil {
+		return nil
+	}
+
+	config := &FaultConfig{}
+	if delay := httpFault.GetDelay(); delay.GetFixedDelay() != nil {
+		config.Delay = delay.GetFixedDelay().AsDuration()
+		config.DelayPerMillion = perMillion(delay.GetPercentage())
+	}
+	if abort := httpFault.GetAbort(); abort != nil {
+		config.AbortGRPCStatus = abort.GetGrpcStatus()
+		config.AbortHTTPStatus = abort.GetHttpStatus()
+		if config.AbortGRPCStatus != 0 || config.AbortHTTPStatus != 0 {
+			config.AbortPerMillion = perMillion(abort.GetPercentage())
+		}
+	}
+	if config.DelayPerMillion == 0 && config.AbortPerMillion == 0 {
+		return nil
+	}
+	return config
+}
+
+// perMillion converts a fractional percent to a share of one million, capped
+// at all RPCs.
+func perMillion(percent *typeV3.FractionalPercent) uint32 {
+	scale := uint64(10000)
+	switch percent.GetDenominator() {
+	case typeV3.FractionalPercent_TEN_THOUSAND:
+		scale = 100
+	case typeV3.FractionalPercent_MILLION:
+		scale = 1
+	}
+	//nolint:gosec // G115: capped at one million.
+	return uint32(min(uint64(percent.GetNumerator())*scale, 1000000))
+}
+
 func parseRouteMatch(match *routeType.RouteMatch) *RouteMatch {
 	if match == nil {
 		return nil
@@ -394,10 +777,61 @@ func parseRouteMatch(match *routeType.RouteMatch) *RouteMatch {
 			headerMatcher.PrefixMatch = specifier.PrefixMatch //nolint:staticcheck
 		case *routeType.HeaderMatcher_SuffixMatch:
 			headerMatcher.SuffixMatch = specifier.SuffixMatch //nolint:staticcheck
+		case *routeType.HeaderMatcher_StringMatch:
+			matched := parseStringMatcher(specifier.StringMatch)
+			headerMatcher.ExactMatch = matched.exact
+			headerMatcher.PrefixMatch = matched.prefix
+			headerMatcher.SuffixMatch = matched.suffix
+			headerMatcher.RegexMatch = matched.regex
 		}
 		parsed.Headers = append(parsed.Headers, headerMatcher)
 	}
 
+	for _, query := range match.QueryParameters {
+		queryMatcher := &QueryParameterMatcher{Name: query.Name}
+		switch specifier := query.QueryParameterMatchSpecifier.(type) {
+		case *routeType.QueryParameterMatcher_StringMatch:
+			matched := parseStringMatcher(specifier.StringMatch)
+			queryMatcher.ExactMatch = matched.exact
+			queryMatcher.PrefixMatch = matched.prefix
+			queryMatcher.SuffixMatch = matched.suffix
+			queryMatcher.RegexMatch = matched.regex
+		case *routeType.QueryParameterMatcher_PresentMatch:
+			queryMatcher.Present = specifier.PresentMatch
+		}
+		parsed.Query = append(parsed.Query, queryMatcher)
+	}
+
+	return parsed
+}
+
+type stringMatch struct {
+	exact  string
+	prefix string
+	suffix string
+	regex  *regexp.Regexp
+}
+
+func parseStringMatcher(matcher *matcherType.StringMatcher) stringMatch {
+	var parsed stringMatch
+	switch pattern := matcher.GetMatchPattern().(type) {
+	case *matcherType.StringMatcher_Exact:
+		parsed.exact = pattern.Exact
+	case *matcherType.StringMatcher_Prefix:
+		parsed.prefix = pattern.Prefix
+	case *matcherType.StringMatcher_Suffix:
+		parsed.suffix = pattern.Suffix
+	case *matcherType.StringMatcher_Contains:
+		if pattern.Contains != "" {
+			parsed.regex = regexp.MustCompile(regexp.QuoteMeta(pattern.Contains))
+		}
+	case *matcherType.StringMatcher_SafeRegex:
+		if pattern.SafeRegex != nil && pattern.SafeRegex.Regex != "" {
+			if compiled, err := regexp.Compile(pattern.SafeRegex.Regex); err == nil {
+				parsed.regex = compiled
+			}
+		}
+	}
 	return parsed
 }
 
@@ -431,6 +865,7 @@ func parseRouteAction(action *routeType.RouteAction) *RouteAction {
 		}
 		parsed.WeightedClusters = weighted
 	}
+	parsed.MetadataMatch = lbMetadata(action.GetMetadataMatch())
 
 	return parsed
 }
@@ -15,6 +15,7 @@
 package resource
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -281,4 +282,115 @@ func TestEndpointHealthStatusMapping(t *testing.T) {
 		limiter.FillInterval != 250*time.Millisecond {
 		t.Fatalf("parseRateLimiter() = %#v", limiter)
 	}
+
+	breaker := parseEndpointCircuitBreaker(&corev3.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			endpointCircuitBreakerMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					"failure_rate_threshold": structpb.NewNumberValue(60),
+					"request_volume":         structpb.NewNumberValue(5),
+					"interval":               structpb.NewNumberValue(10),
+					"open_duration":          structpb.NewNumberValue(0.5),
+				},
+			},
+		},
+	})
+	if breaker.FailureRateThreshold != 60 || breaker.RequestVolume != 5 ||
+		breaker.Interval != 10*time.Second || breaker.OpenDuration != 500*time.Millisecond {
+		t.Fatalf("parseEndpointCircuitBreaker() = %#v", breaker)
+	}
+	if got := parseEndpointCircuitBreaker(nil); got != nil {
+		t.Fatalf("parseEndpointCircuitBreaker(nil) = %#v, want nil", got)
+	}
+}
+
+func TestRouteMatchParsesStringMatchers(t *testing.T) {
+	parsed := parseRouteMatch(&routeType.RouteMatch{
+		Headers: []*routeType.HeaderMatcher{{
+			Name: "x-release",
+			HeaderMatchSpecifier: &routeType.HeaderMatcher_StringMatch{
+				StringMatch: &matcherType.StringMatcher{
+					MatchPattern: &matcherType.StringMatcher_Exact{Exact: "canary"},
+				},
+			},
+		}},
+		QueryParameters: []*routeType.QueryParameterMatcher{
+			{
+				Name: "tenant",
+				QueryParameterMatchSpecifier: &routeType.QueryParameterMatcher_StringMatch{
+					StringMatch: &matcherType.StringMatcher{
+						MatchPattern: &matcherType.StringMatcher_Contains{Contains: "beta"},
+					},
+				},
+			},
+			{
+				Name: "debug",
+				QueryParameterMatchSpecifier: &routeType.QueryParameterMatcher_PresentMatch{
+					PresentMatch: true,
+				},
+			},
+		},
+	})
+
+	if len(parsed.Headers) != 1 || parsed.Headers[0].ExactMatch != "canary" {
+		t.Fatalf("headers = %#v, want exact canary", parsed.Headers)
+	}
+	if len(parsed.Query) != 2 || parsed.Query[0].RegexMatch == nil || !parsed.Query[1].Present {
+		t.Fatalf("query = %#v, want contains and present matchers", parsed.Query)
+	}
+
+	if !parsed.Matches("/svc/Method", map[string]string{"x-release": "canary"}) {
+		t.Fatal("Matches() = false, want true for matching header")
+	}
+	query := func(raw string) url.Values {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) error = %v", raw, err)
+		}
+		return values
+	}
+	if !parsed.MatchesQuery(query("tenant=team-beta-1&debug=")) {
+		t.Fatal("MatchesQuery() = false, want true for matching query")
+	}
+	if parsed.MatchesQuery(query("tenant=team-alpha&debug=")) {
+		t.Fatal("MatchesQuery() = true, want false for non-matching tenant")
+	}
+	if parsed.MatchesQuery(query("tenant=team-beta")) {
+		t.Fatal("MatchesQuery() = true, want false without debug parameter")
+	}
+}
+
+func TestParseEndpointIdentity(t *testing.T) {
+	subjectAltNames, err := structpb.NewList([]any{"greeter.default.svc", ""})
+	if err != nil {
+		t.Fatalf("NewList() error = %v", err)
+	}
+	identity := parseEndpointIdentity(&corev3.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			securityMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					"spiffe_id":         structpb.NewStringValue("spiffe://example.org/sa/greeter"),
+					"subject_alt_names": structpb.NewListValue(subjectAltNames),
+				},
+			},
+		},
+	})
+	if identity == nil || identity.SPIFFEID != "spiffe://example.org/sa/greeter" ||
+		len(identity.SubjectAltNames) != 1 ||
+		identity.SubjectAltNames[0] != "greeter.default.svc" {
+		t.Fatalf("parseEndpointIdentity() = %#v", identity)
+	}
+
+	if got := parseEndpointIdentity(nil); got != nil {
+		t.Fatalf("parseEndpointIdentity(nil) = %#v, want nil", got)
+	}
+	if got := parseEndpointIdentity(&corev3.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			securityMetadataKey: {Fields: map[string]*structpb.Value{
+				"spiffe_id": structpb.NewStringValue(""),
+			}},
+		},
+	}); got != nil {
+		t.Fatalf("parseEndpointIdentity(empty) = %#v, want nil", got)
+	}
 }
@@ -15,6 +15,7 @@
 package resource
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	listenerType "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	routeType "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcmType "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlsType "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -169,6 +171,118 @@ func TestDecodeDiscoveryResponseUnknownType(t *testing.T) {
 	}
 }
 
+func TestDecodeScopedRoutes(t *testing.T) {
+	type (
+		fragmentBuilder = hcmType.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder
+		headerExtractor = hcmType.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor
+		kvElement       = hcmType.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor_KvElement
+	)
+	managerAny, err := anypb.New(&hcmType.HttpConnectionManager{
+		RouteSpecifier: &hcmType.HttpConnectionManager_ScopedRoutes{
+			ScopedRoutes: &hcmType.ScopedRoutes{
+				Name: "scopes",
+				ScopeKeyBuilder: &hcmType.ScopedRoutes_ScopeKeyBuilder{
+					Fragments: []*fragmentBuilder{{
+						Type: &hcmType.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor_{
+							HeaderValueExtractor: &headerExtractor{
+								Name:             "X-Tenant",
+								ElementSeparator: ";",
+								ExtractType: &hcmType.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor_Element{
+									Element: &kvElement{Separator: "=", Key: "tenant"},
+								},
+							},
+						},
+					}},
+				},
+				ConfigSpecifier: &hcmType.ScopedRoutes_ScopedRds{
+					ScopedRds: &hcmType.ScopedRds{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	listenerAny, err := anypb.New(&listenerType.Listener{
+		Name: "listener-a",
+		FilterChains: []*listenerType.FilterChain{{
+			Filters: []*listenerType.Filter{{
+				Name:       httpConnectionManagerFilter,
+				ConfigType: &listenerType.Filter_TypedConfig{TypedConfig: managerAny},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+
+	events, err := DecodeDiscoveryResponse(typeURLListener, []*anypb.Any{listenerAny})
+	if err != nil {
+		t.Fatalf("DecodeDiscoveryResponse(listener) error = %v", err)
+	}
+	listener := events[0].Data.(*ListenerSnapshot)
+	if listener.Route != "" || listener.ScopedRoutes == nil || !listener.ScopedRoutes.SRDS {
+		t.Fatalf("listener snapshot = %#v, want SRDS scoped routes", listener)
+	}
+	wantFragment := &ScopeKeyFragment{
+		Header:           "x-tenant",
+		ElementSeparator: ";",
+		ElementKey:       "tenant",
+		KeySeparator:     "=",
+	}
+	if got := listener.ScopedRoutes.KeyBuilder.Fragments; len(got) != 1 ||
+		!reflect.DeepEqual(got[0], wantFragment) {
+		t.Fatalf("scope key fragments = %#v, want %#v", got, wantFragment)
+	}
+
+	var resources []*anypb.Any
+	for tenant, route := range map[string]string{"blue": "route-blue", "green": "route-green"} {
+		scopeAny, err := anypb.New(&routeType.ScopedRouteConfiguration{
+			Name:                   "scope-" + tenant,
+			RouteConfigurationName: route,
+			Key: &routeType.ScopedRouteConfiguration_Key{
+				Fragments: []*routeType.ScopedRouteConfiguration_Key_Fragment{{
+					Type: &routeType.ScopedRouteConfiguration_Key_Fragment_StringKey{
+						StringKey: tenant,
+					},
+				}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("anypb.New() error = %v", err)
+		}
+		resources = append(resources, scopeAny)
+	}
+	events, err = DecodeDiscoveryResponse(typeURLScopedRoute, resources)
+	if err != nil {
+		t.Fatalf("DecodeDiscoveryResponse(scoped route) error = %v", err)
+	}
+	table := &ScopedRouteTable{KeyBuilder: listener.ScopedRoutes.KeyBuilder}
+	for _, event := range events {
+		scope := event.Data.(*ScopedRouteSnapshot)
+		if event.Typ != ScopedRouteAdded || event.Name != scope.Name {
+			t.Fatalf("scoped route event = %#v", event)
+		}
+		table.Scopes = append(table.Scopes, &RouteScope{
+			Key:    scope.Key,
+			Vhosts: []*VirtualHost{{Name: scope.RouteConfig}},
+		})
+	}
+
+	vhosts := RequestVirtualHosts(nil, []*ScopedRouteTable{table}, map[string]string{
+		"x-tenant": "region=eu;tenant=green",
+	})
+	if len(vhosts) != 1 || vhosts[0].Name != "route-green" {
+		t.Fatalf("RequestVirtualHosts(green) = %#v, want route-green", vhosts)
+	}
+	if got := table.VirtualHosts(map[string]string{"x-tenant": "tenant=red"}); got != nil {
+		t.Fatalf("VirtualHosts(unknown scope) = %#v, want nil", got)
+	}
+	if got := table.VirtualHosts(map[string]string{}); got != nil {
+		t.Fatalf("VirtualHosts(no header) = %#v, want nil", got)
+	}
+}
+
 func TestParseRouteWeightedClusters(t *testing.T) {
 	events := parseRoute(&routeType.RouteConfiguration{
 		Name: "route-a",
@@ -208,3 +322,120 @@ func TestParseRouteWeightedClusters(t *testing.T) {
 		t.Fatalf("clusters len = %d, want 2", len(action.WeightedClusters.Clusters))
 	}
 }
+
+func TestParseClusterOutlierDetectionDefaults(t *testing.T) {
+	events := parseCluster(&clusterType.Cluster{
+		Name:             "cluster-a",
+		OutlierDetection: &clusterType.OutlierDetection{},
+	})
+	got := events[0].Data.(*ClusterSnapshot).Policy.OutlierDetection
+	want := &OutlierDetectionConfig{
+		Consecutive5xx:                 5,
+		ConsecutiveGatewayFailure:      5,
+		ConsecutiveLocalOriginFailure:  5,
+		Interval:                       10 * time.Second,
+		BaseEjectionTime:               30 * time.Second,
+		MaxEjectionTime:                300 * time.Second,
+		MaxEjectionPercent:             10,
+		EnforcingConsecutive5xx:        100,
+		EnforcingSuccessRate:           100,
+		SuccessRateMinimumHosts:        5,
+		SuccessRateRequestVolume:       100,
+		SuccessRateStdevFactor:         1900,
+		FailurePercentageThreshold:     85,
+		FailurePercentageMinimumHosts:  5,
+		FailurePercentageRequestVolume: 50,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("OutlierDetection = %+v, want %+v", got, want)
+	}
+
+	disabled := parseCluster(&clusterType.Cluster{
+		Name: "cluster-b",
+		OutlierDetection: &clusterType.OutlierDetection{
+			EnforcingConsecutive_5Xx: wrapperspb.UInt32(0),
+		},
+	})
+	od := disabled[0].Data.(*ClusterSnapshot).Policy.OutlierDetection
+	if od.EnforcingConsecutive5xx != 0 {
+		t.Fatalf("explicit zero EnforcingConsecutive5xx = %d, want 0", od.EnforcingConsecutive5xx)
+	}
+
+	windowed := parseCluster(&clusterType.Cluster{
+		Name:             "cluster-c",
+		OutlierDetection: &clusterType.OutlierDetection{},
+		Metadata: &corev3.Metadata{FilterMetadata: map[string]*structpb.Struct{
+			outlierDetectionMetadataKey: {Fields: map[string]*structpb.Value{
+				"stats_window_intervals": structpb.NewNumberValue(3),
+			}},
+		}},
+	})
+	od = windowed[0].Data.(*ClusterSnapshot).Policy.OutlierDetection
+	if od.StatsWindowIntervals != 3 {
+		t.Fatalf("StatsWindowIntervals = %d, want 3", od.StatsWindowIntervals)
+	}
+}
+
+func TestParseClusterLeastRequestP2C(t *testing.T) {
+	events := parseCluster(&clusterType.Cluster{
+		Name:     "cluster-a",
+		LbPolicy: clusterType.Cluster_LEAST_REQUEST,
+		LbConfig: &clusterType.Cluster_LeastRequestLbConfig_{
+			LeastRequestLbConfig: &clusterType.Cluster_LeastRequestLbConfig{
+				ChoiceCount: wrapperspb.UInt32(2),
+			},
+		},
+	})
+	if got := events[0].Data.(*ClusterSnapshot).Policy.LBPolicy; got != "least_request_p2c" {
+		t.Fatalf("LBPolicy = %q, want least_request_p2c", got)
+	}
+}
+
+func TestParseClusterUpstreamALPN(t *testing.T) {
+	tlsContext, err := anypb.New(&tlsType.UpstreamTlsContext{
+		CommonTlsContext: &tlsType.CommonTlsContext{
+			AlpnProtocols: []string{ALPNHTTP2, ALPNHTTP11},
+		},
+	})
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+
+	events := parseCluster(&clusterType.Cluster{
+		Name: "cluster-a",
+		TransportSocket: &corev3.TransportSocket{
+			Name:       "envoy.transport_sockets.tls",
+			ConfigType: &corev3.TransportSocket_TypedConfig{TypedConfig: tlsContext},
+		},
+	})
+	if len(events) != 1 {
+		t.Fatalf("parseCluster() len = %d, want 1", len(events))
+	}
+	policy := events[0].Data.(*ClusterSnapshot).Policy
+	if len(policy.ALPNProtocols) != 2 || policy.ALPNProtocols[0] != ALPNHTTP2 {
+		t.Fatalf("ALPNProtocols = %v, want [h2 http/1.1]", policy.ALPNProtocols)
+	}
+
+	plain := parseCluster(&clusterType.Cluster{Name: "cluster-b"})
+	if alpn := plain[0].Data.(*ClusterSnapshot).Policy.ALPNProtocols; alpn != nil {
+		t.Fatalf("ALPNProtocols without transport socket = %v, want nil", alpn)
+	}
+}
+
+func TestProtocolForALPN(t *testing.T) {
+	tests := []struct {
+		alpn []string
+		want string
+	}{
+		{alpn: []string{ALPNHTTP2}, want: ProtocolGRPC},
+		{alpn: []string{ALPNHTTP11, ALPNHTTP2}, want: ProtocolHTTP},
+		{alpn: []string{"h3", ALPNHTTP2}, want: ProtocolGRPC},
+		{alpn: []string{"h3"}, want: ""},
+		{alpn: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := ProtocolForALPN(tt.alpn); got != tt.want {
+			t.Errorf("ProtocolForALPN(%v) = %q, want %q", tt.alpn, got, tt.want)
+		}
+	}
+}
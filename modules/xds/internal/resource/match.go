@@ -16,14 +16,32 @@ package resource
 
 import (
 	"net"
+	"net/url"
+	"slices"
 	"strings"
 )
 
 // MatchRoute finds the matching route action for a given path and headers.
+// Query parameter rules are not checked: RPC paths carry no query string.
 func MatchRoute(vhosts []*VirtualHost, path string, headers map[string]string) *RouteAction {
-	if len(vhosts) == 0 {
+	_, route := FindRoute(vhosts, path, headers, nil)
+	if route == nil {
 		return nil
 	}
+	return route.Action
+}
+
+// FindRoute finds the virtual host and route matching a given path and
+// headers. Query parameter rules are checked against query unless it is nil.
+func FindRoute(
+	vhosts []*VirtualHost,
+	path string,
+	headers map[string]string,
+	query url.Values,
+) (*VirtualHost, *Route) {
+	if len(vhosts) == 0 {
+		return nil, nil
+	}
 
 	vhost := selectVirtualHost(vhosts, requestHost(headers))
 	if vhost == nil {
@@ -31,12 +49,12 @@ func MatchRoute(vhosts []*VirtualHost, path string, headers map[string]string) *
 	}
 
 	for _, route := range vhost.Routes {
-		if route.Match.Matches(path, headers) {
-			return route.Action
+		if route.Match.Matches(path, headers) && (query == nil || route.Match.MatchesQuery(query)) {
+			return vhost, route
 		}
 	}
 
-	return nil
+	return vhost, nil
 }
 
 // Matches checks if the route match rules apply to the request.
@@ -48,6 +66,19 @@ func (m *RouteMatch) Matches(path string, headers map[string]string) bool {
 	return m.matchPath(path) && m.matchHeaders(headers)
 }
 
+// MatchesQuery checks if the query parameter rules apply to query.
+func (m *RouteMatch) MatchesQuery(query url.Values) bool {
+	if m == nil {
+		return true
+	}
+	for _, matcher := range m.Query {
+		if !matcher.matches(query) {
+			return false
+		}
+	}
+	return true
+}
+
 func (m *RouteMatch) matchPath(path string) bool {
 	switch {
 	case m.Path != "":
@@ -97,6 +128,41 @@ func (h *HeaderMatcher) matches(headers map[string]string) bool {
 	return true
 }
 
+func (q *QueryParameterMatcher) matches(values url.Values) bool {
+	if !values.Has(q.Name) {
+		return false
+	}
+	if q.Present {
+		return true
+	}
+
+	value := values.Get(q.Name)
+	if q.ExactMatch != "" && value != q.ExactMatch {
+		return false
+	}
+	if q.PrefixMatch != "" && !strings.HasPrefix(value, q.PrefixMatch) {
+		return false
+	}
+	if q.SuffixMatch != "" && !strings.HasSuffix(value, q.SuffixMatch) {
+		return false
+	}
+	if q.RegexMatch != nil && !q.RegexMatch.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+// RequestHost returns the normalized :authority, or host, header of a request.
+func RequestHost(headers map[string]string) string {
+	return requestHost(headers)
+}
+
+// MatchVirtualHost returns the virtual host whose domains best match host, or
+// nil when none does. Unlike FindRoute it never falls back to the first one.
+func MatchVirtualHost(vhosts []*VirtualHost, host string) *VirtualHost {
+	return selectVirtualHost(vhosts, normalizeHost(host))
+}
+
 func requestHost(headers map[string]string) string {
 	if host := normalizeHost(headers[":authority"]); host != "" {
 		return host
@@ -182,3 +248,79 @@ func domainMatchScore(domain, host string) int {
 
 	return 0
 }
+
+// RequestVirtualHosts returns the virtual hosts a request can be routed by:
+// vhosts followed by those of the first scoped route table whose scope
+// matches the request's scope key.
+func RequestVirtualHosts(
+	vhosts []*VirtualHost,
+	scoped []*ScopedRouteTable,
+	headers map[string]string,
+) []*VirtualHost {
+	for _, table := range scoped {
+		if scopeVhosts := table.VirtualHosts(headers); len(scopeVhosts) > 0 {
+			return append(slices.Clip(vhosts), scopeVhosts...)
+		}
+	}
+	return vhosts
+}
+
+// VirtualHosts returns the virtual hosts of the scope matching the request's
+// scope key, or nil when the key cannot be built or no scope matches.
+func (t *ScopedRouteTable) VirtualHosts(headers map[string]string) []*VirtualHost {
+	if t == nil {
+		return nil
+	}
+	key, ok := t.KeyBuilder.Build(headers)
+	if !ok {
+		return nil
+	}
+	for _, scope := range t.Scopes {
+		if slices.Equal(scope.Key, key) {
+			return scope.Vhosts
+		}
+	}
+	return nil
+}
+
+// Build computes the scope key of a request. It reports false when any
+// fragment cannot be extracted, in which case the request has no scope.
+func (b *ScopeKeyBuilder) Build(headers map[string]string) ([]string, bool) {
+	if b == nil || len(b.Fragments) == 0 {
+		return nil, false
+	}
+	key := make([]string, 0, len(b.Fragments))
+	for _, fragment := range b.Fragments {
+		value, ok := fragment.extract(headers)
+		if !ok {
+			return nil, false
+		}
+		key = append(key, value)
+	}
+	return key, true
+}
+
+func (f *ScopeKeyFragment) extract(headers map[string]string) (string, bool) {
+	value, ok := headers[f.Header]
+	if !ok {
+		return "", false
+	}
+	if f.ElementSeparator == "" {
+		return value, f.Index == 0
+	}
+
+	elements := strings.Split(value, f.ElementSeparator)
+	if f.ElementKey == "" {
+		if int(f.Index) >= len(elements) {
+			return "", false
+		}
+		return elements[f.Index], true
+	}
+	for _, element := range elements {
+		if key, elementValue, ok := strings.Cut(element, f.KeySeparator); ok &&
+			key == f.ElementKey {
+			return elementValue, true
+		}
+	}
+	return "", false
+}
@@ -15,6 +15,7 @@
 package resource
 
 import (
+	"net/url"
 	"regexp"
 	"testing"
 )
@@ -80,3 +81,37 @@ func TestMatchRouteUsesPathAndHeaders(t *testing.T) {
 		t.Fatalf("fallback MatchRoute() = %#v, want default", action)
 	}
 }
+
+func TestMatchRouteLeavesQueryRulesToFindRoute(t *testing.T) {
+	vhosts := []*VirtualHost{{
+		Name:    "default",
+		Domains: []string{"*"},
+		Routes: []*Route{
+			{
+				Match: &RouteMatch{
+					Prefix: "/pkg.Service/",
+					Query:  []*QueryParameterMatcher{{Name: "debug", Present: true}},
+				},
+				Action: &RouteAction{Cluster: "debug"},
+			},
+			{
+				Match:  &RouteMatch{Prefix: "/"},
+				Action: &RouteAction{Cluster: "default"},
+			},
+		},
+	}}
+
+	if action := MatchRoute(vhosts, "/pkg.Service/Method", nil); action == nil ||
+		action.Cluster != "debug" {
+		t.Fatalf("MatchRoute() = %#v, want debug without checking query rules", action)
+	}
+	if _, route := FindRoute(vhosts, "/pkg.Service/Method", nil, url.Values{}); route == nil ||
+		route.Action.Cluster != "default" {
+		t.Fatalf("FindRoute() = %#v, want default without debug parameter", route)
+	}
+	query := url.Values{"debug": {""}}
+	if _, route := FindRoute(vhosts, "/pkg.Service/Method", nil, query); route == nil ||
+		route.Action.Cluster != "debug" {
+		t.Fatalf("FindRoute() = %#v, want debug with debug parameter", route)
+	}
+}
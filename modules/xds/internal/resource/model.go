@@ -31,6 +31,14 @@ const (
 	ClusterAdded
 	// EndpointAdded indicates an endpoint snapshot was parsed.
 	EndpointAdded
+	// ScopedRouteAdded indicates a scoped route snapshot was parsed.
+	ScopedRouteAdded
+	// VirtualHostAdded indicates an on-demand virtual host was parsed. Its
+	// name is "<route configuration>/<host>".
+	VirtualHostAdded
+	// VirtualHostRemoved indicates the control plane has no, or no longer
+	// has, the named on-demand virtual host.
+	VirtualHostRemoved
 )
 
 // DiscoveryEvent is a parsed xDS resource update.
@@ -47,6 +55,20 @@ type ClusterPolicy struct {
 	CircuitBreaker   *CircuitBreakerConfig
 	OutlierDetection *OutlierDetectionConfig
 	RateLimiter      *RateLimiterConfig
+
+	// GlobalRateLimit asks the balancer to consult an external rate limit
+	// service before each pick, with RateLimiter as the fallback.
+	GlobalRateLimit *GlobalRateLimitConfig
+
+	EndpointCircuitBreaker *EndpointCircuitBreakerConfig
+
+	// Subset controls what happens when no endpoint matches a route's
+	// metadata match; nil means SubsetNoFallback.
+	Subset *SubsetConfig
+
+	// ALPNProtocols is the ALPN list of the cluster's upstream TLS transport
+	// socket, in preference order.
+	ALPNProtocols []string
 }
 
 // WeightedEndpoint is an endpoint plus xDS load-balancing metadata.
@@ -56,6 +78,17 @@ type WeightedEndpoint struct {
 	Weight   uint32
 	Priority uint32
 	Metadata map[string]string
+	Identity *EndpointIdentity
+
+	// Draining is set when EDS reports the endpoint DRAINING: it gets no new
+	// picks, while RPCs already picked run to completion.
+	Draining bool
+}
+
+// EndpointIdentity is the peer identity an endpoint must present over TLS.
+type EndpointIdentity struct {
+	SPIFFEID        string
+	SubjectAltNames []string
 }
 
 // Endpoint represents a service endpoint.
@@ -67,11 +100,76 @@ type Endpoint struct {
 // ListenerSnapshot is the parsed subset of an xDS listener.
 type ListenerSnapshot struct {
 	Route string
+	// ScopedRoutes is set instead of Route when the listener's HTTP connection
+	// manager picks the route configuration by request scope.
+	ScopedRoutes *ScopedRoutesConfig
+}
+
+// GetScopedRoutes returns the scoped routes of the listener; it is safe to
+// call on a nil snapshot.
+func (l *ListenerSnapshot) GetScopedRoutes() *ScopedRoutesConfig {
+	if l == nil {
+		return nil
+	}
+	return l.ScopedRoutes
+}
+
+// ScopedRoutesConfig is the scoped_routes route specifier of a listener.
+type ScopedRoutesConfig struct {
+	KeyBuilder *ScopeKeyBuilder
+	// Scopes lists inline scoped route configurations. It is nil when SRDS is
+	// set and the scopes are discovered from the control plane.
+	Scopes []*ScopedRouteSnapshot
+	SRDS   bool
+}
+
+// ScopeKeyBuilder builds the scope key of a request, one fragment per entry.
+type ScopeKeyBuilder struct {
+	Fragments []*ScopeKeyFragment
+}
+
+// ScopeKeyFragment extracts one scope key fragment from request header
+// Header. Without ElementSeparator the whole value is used. Otherwise the
+// value is split on it and either element Index is used or, when ElementKey
+// is set, the value of the element "ElementKey<KeySeparator>value".
+type ScopeKeyFragment struct {
+	Header           string
+	ElementSeparator string
+	Index            uint32
+	ElementKey       string
+	KeySeparator     string
+}
+
+// ScopedRouteSnapshot is the parsed subset of an xDS scoped route
+// configuration.
+type ScopedRouteSnapshot struct {
+	Name string
+	Key  []string
+	// RouteConfig names the RDS route configuration of the scope; Inline is
+	// set instead when the scope carries its route configuration.
+	RouteConfig string
+	Inline      *RouteSnapshot
+}
+
+// ScopedRouteTable holds the resolved scopes of one listener. Requests whose
+// scope key matches no scope get no virtual hosts.
+type ScopedRouteTable struct {
+	KeyBuilder *ScopeKeyBuilder
+	Scopes     []*RouteScope
+}
+
+// RouteScope is a scope key and the virtual hosts of its route configuration.
+type RouteScope struct {
+	Key    []string
+	Vhosts []*VirtualHost
 }
 
 // RouteSnapshot is the parsed subset of an xDS route configuration.
 type RouteSnapshot struct {
 	Vhosts []*VirtualHost
+	// VHDS is set when further virtual hosts of the route configuration are
+	// discovered on demand.
+	VHDS bool
 }
 
 // ClusterSnapshot is the parsed subset of an xDS cluster.
@@ -87,8 +185,18 @@ type EDSSnapshot struct {
 const (
 	// AttributeRoutes is the resolver state attribute key for route data.
 	AttributeRoutes = "xds_routes"
+	// AttributeScopedRoutes is the resolver state attribute key for scoped
+	// route data, as []*ScopedRouteTable.
+	AttributeScopedRoutes = "xds_scoped_routes"
+	// AttributeVirtualHostLookup is the resolver state attribute key for the
+	// on-demand virtual host lookup, as VirtualHostLookup. It is only set when
+	// VHDS is enabled.
+	AttributeVirtualHostLookup = "xds_vhds_lookup"
 	// AttributeClusters is the resolver state attribute key for cluster policies.
 	AttributeClusters = "xds_clusters"
+	// AttributeEDSReceived is the resolver state attribute key for the clusters
+	// whose EDS load assignment has been received, as map[string]bool.
+	AttributeEDSReceived = "xds_eds_received"
 	// AttributeEndpointCluster is the endpoint attribute key for cluster ownership.
 	AttributeEndpointCluster = "xds_cluster"
 	// AttributeEndpointWeight is the endpoint attribute key for xDS weight.
@@ -97,8 +205,41 @@ const (
 	AttributeEndpointPriority = "priority"
 	// AttributeEndpointMetadata is the endpoint attribute key for xDS metadata.
 	AttributeEndpointMetadata = "metadata"
+	// AttributeEndpointIdentity is the endpoint attribute key for the expected peer identity.
+	AttributeEndpointIdentity = "xds_identity"
+	// AttributeEndpointALPN is the endpoint attribute key for the cluster's upstream ALPN list.
+	AttributeEndpointALPN = "xds_alpn"
+)
+
+// VirtualHostLookup asks the control plane for the virtual host of a request
+// host. It reports whether the lookup is still outstanding, in which case the
+// caller should wait for the next resolver update before routing.
+type VirtualHostLookup func(host string) bool
+
+// ALPN protocol IDs and the Yggdrasil remote protocols they select.
+const (
+	ALPNHTTP2  = "h2"
+	ALPNHTTP11 = "http/1.1"
+
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
 )
 
+// ProtocolForALPN returns the remote protocol for the first ALPN ID in alpn
+// that Yggdrasil can serve: h2 selects grpc and http/1.1 selects http. It
+// returns "" when none of them is known.
+func ProtocolForALPN(alpn []string) string {
+	for _, id := range alpn {
+		switch id {
+		case ALPNHTTP2:
+			return ProtocolGRPC
+		case ALPNHTTP11:
+			return ProtocolHTTP
+		}
+	}
+	return ""
+}
+
 // CircuitBreakerConfig holds circuit breaker configuration parsed from xDS.
 type CircuitBreakerConfig struct {
 	MaxConnections     uint32
@@ -107,6 +248,15 @@ type CircuitBreakerConfig struct {
 	MaxRetries         uint32
 }
 
+// EndpointCircuitBreakerConfig holds per-endpoint circuit breaker configuration
+// parsed from xDS cluster metadata.
+type EndpointCircuitBreakerConfig struct {
+	FailureRateThreshold uint32
+	RequestVolume        uint32
+	Interval             time.Duration
+	OpenDuration         time.Duration
+}
+
 // OutlierDetectionConfig holds outlier detection configuration parsed from xDS.
 type OutlierDetectionConfig struct {
 	Consecutive5xx                 uint32
@@ -126,6 +276,9 @@ type OutlierDetectionConfig struct {
 	FailurePercentageMinimumHosts  uint32
 	FailurePercentageRequestVolume uint32
 	SplitExternalLocalOriginErrors bool
+	// StatsWindowIntervals is the number of intervals the success-rate and
+	// failure-percentage statistics cover; 0 and 1 use the last interval only.
+	StatsWindowIntervals uint32
 }
 
 // RateLimiterConfig holds rate limiter configuration parsed from xDS.
@@ -135,6 +288,38 @@ type RateLimiterConfig struct {
 	FillInterval  time.Duration
 }
 
+// Subset fallback policies, from the cluster's lb_subset_config.
+const (
+	// SubsetNoFallback picks no endpoint when the subset is empty.
+	SubsetNoFallback = "no_fallback"
+	// SubsetAnyEndpoint falls back to every endpoint of the cluster.
+	SubsetAnyEndpoint = "any_endpoint"
+	// SubsetDefaultSubset falls back to the endpoints matching DefaultSubset.
+	SubsetDefaultSubset = "default_subset"
+)
+
+// SubsetConfig holds the subset load-balancing fallback of a cluster.
+type SubsetConfig struct {
+	FallbackPolicy string
+	DefaultSubset  map[string]string
+}
+
+// GlobalRateLimitConfig holds the rate limit service descriptor of a cluster,
+// parsed from the yggdrasil.global_rate_limit filter metadata.
+type GlobalRateLimitConfig struct {
+	Domain  string
+	Entries []RateLimitDescriptorEntry
+}
+
+// RateLimitDescriptorEntry is one entry of the descriptor sent to the rate
+// limit service. Its value is the request header Header when set, otherwise
+// the static Value.
+type RateLimitDescriptorEntry struct {
+	Key    string
+	Header string
+	Value  string
+}
+
 // VirtualHost represents the xDS VirtualHost configuration.
 type VirtualHost struct {
 	Name    string
@@ -146,6 +331,22 @@ type VirtualHost struct {
 type Route struct {
 	Match  *RouteMatch
 	Action *RouteAction
+	// Fault is nil unless the route configures client-side fault injection.
+	Fault *FaultConfig
+}
+
+// FaultConfig is the fault injection of a route, parsed from its
+// envoy.filters.http.fault typed_per_filter_config. Percentages are per
+// million RPCs.
+type FaultConfig struct {
+	Delay           time.Duration
+	DelayPerMillion uint32
+
+	// AbortGRPCStatus is the code aborted RPCs fail with; when it is zero
+	// AbortHTTPStatus is mapped to a code instead.
+	AbortGRPCStatus uint32
+	AbortHTTPStatus uint32
+	AbortPerMillion uint32
 }
 
 // RouteMatch defines how to match a request.
@@ -156,6 +357,7 @@ type RouteMatch struct {
 	Contains string
 	Regex    *regexp.Regexp
 	Headers  []*HeaderMatcher
+	Query    []*QueryParameterMatcher
 }
 
 // HeaderMatcher matches HTTP headers.
@@ -168,10 +370,23 @@ type HeaderMatcher struct {
 	Present     bool
 }
 
+// QueryParameterMatcher matches URL query parameters.
+type QueryParameterMatcher struct {
+	Name        string
+	ExactMatch  string
+	PrefixMatch string
+	SuffixMatch string
+	RegexMatch  *regexp.Regexp
+	Present     bool
+}
+
 // RouteAction defines what to do when a route matches.
 type RouteAction struct {
 	Cluster          string
 	WeightedClusters *WeightedClusters
+	// MetadataMatch restricts the pick to endpoints whose envoy.lb metadata
+	// has all of these key/value pairs.
+	MetadataMatch map[string]string
 }
 
 // WeightedClusters supports traffic splitting.
@@ -16,6 +16,7 @@ package xds
 
 import (
 	"context"
+	"net/http"
 	"sync"
 
 	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/discovery"
@@ -31,16 +32,25 @@ const capabilityName = "xds"
 type xdsModule struct {
 	mu       sync.RWMutex
 	settings settings
+
+	balancerOpts []traffic.BalancerOption
+}
+
+// StatsHandler returns a read-only HTTP handler that serves the stats of every
+// live xDS balancer as JSON. See traffic.StatsHandler.
+func StatsHandler() http.Handler {
+	return traffic.StatsHandler()
 }
 
-// Module returns the Yggdrasil v3 xDS capability module.
-func Module() module.Module {
-	return &xdsModule{}
+// Module returns the Yggdrasil v3 xDS capability module. The options apply to
+// every xDS balancer it provides.
+func Module(opts ...traffic.BalancerOption) module.Module {
+	return &xdsModule{balancerOpts: opts}
 }
 
 // WithModule registers the xDS capability module on a Yggdrasil v3 app.
-func WithModule() yggdrasil.Option {
-	return yggdrasil.WithModules(Module())
+func WithModule(opts ...traffic.BalancerOption) yggdrasil.Option {
+	return yggdrasil.WithModules(Module(opts...))
 }
 
 func (m *xdsModule) Name() string { return capabilityName }
@@ -70,7 +80,7 @@ func (m *xdsModule) Capabilities() []module.Capability {
 		capabilities.ProvideNamed(
 			capabilities.BalancerProviderSpec,
 			capabilityName,
-			traffic.BalancerProvider(),
+			traffic.BalancerProvider(m.balancerOpts...),
 		),
 	}
 }
@@ -20,19 +20,34 @@ import (
 	"log/slog"
 	mrand "math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
 	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
 	remote "github.com/codesjoy/yggdrasil/v3/transport"
 	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const name = "xds"
 
 // BalancerProvider returns the xDS v3 client balancer provider.
-func BalancerProvider() balancer.Provider {
-	return balancer.NewProvider(name, newXdsBalancer)
+func BalancerProvider(opts ...BalancerOption) balancer.Provider {
+	var options balancerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return balancer.NewProvider(name, func(
+		serviceName, balancerName string,
+		cli balancer.Client,
+	) (balancer.Balancer, error) {
+		b, err := buildXdsBalancer(serviceName, balancerName, cli, options)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
 }
 
 type xdsBalancer struct {
@@ -41,6 +56,8 @@ type xdsBalancer struct {
 	mu               sync.RWMutex
 	remotesClient    map[string]remote.Client
 	vhosts           []*xdsresource.VirtualHost
+	scopedRoutes     []*xdsresource.ScopedRouteTable
+	vhdsLookup       xdsresource.VirtualHostLookup
 	clusterPolicies  map[string]clusterPolicy
 	endpoints        map[string][]*weightedEndpoint
 	circuitBreakers  map[string]*CircuitBreaker
@@ -48,11 +65,66 @@ type xdsBalancer struct {
 	rateLimiters     map[string]*RateLimiter
 	inFlight         map[string]*int32
 	rng              *mrand.Rand
+	// state is the last resolver state, reported by StatsHandler.
+	state resolver.State
+
+	// retiring holds clients of endpoints removed from EDS while RPCs were
+	// still in flight; each is closed when its last RPC reports.
+	retiring map[string]remote.Client
+
+	// rrMu guards rrWeights, which pickers update under the read lock.
+	// rrWeights holds the smooth round-robin current weight of each
+	// endpoint, keyed by cluster and then address:port.
+	rrMu      sync.Mutex
+	rrWeights map[string]map[string]int64
+
+	// endpointBreakers holds per-endpoint circuit breakers keyed by address:port.
+	endpointBreakers map[string]*EndpointCircuitBreaker
+
+	// pickLog is nil unless pick_log.enabled is set.
+	pickLog *pickLogger
+
+	// failFastEmptyEDS mirrors BalancerConfig.FailFastEmptyEDS; edsReceived
+	// is nil when the resolver does not report EDS arrival.
+	failFastEmptyEDS bool
+	edsReceived      map[string]bool
+
+	// rls is nil unless rate_limit_service.address is set.
+	rls *rateLimitService
+
+	// statsMetrics is set while GetStats is exported as OTel metrics.
+	statsMetrics metric.Registration
+
+	// clock drives the outlier detectors, rate limiters, and endpoint
+	// breakers; nil means the system clock.
+	clock Clock
+
+	// outlierHooks are bound to each cluster's outlier detector.
+	outlierHooks OutlierHooks
+}
+
+func newXdsBalancer(
+	serviceName, balancerName string,
+	cli balancer.Client,
+) (balancer.Balancer, error) {
+	b, err := buildXdsBalancer(serviceName, balancerName, cli, balancerOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
-func newXdsBalancer(_ string, _ string, cli balancer.Client) (balancer.Balancer, error) {
+func buildXdsBalancer(
+	serviceName, balancerName string,
+	cli balancer.Client,
+	options balancerOptions,
+) (*xdsBalancer, error) {
+	cfg, err := LoadBalancerConfig(serviceName, balancerName)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
 	//nolint:gosec // G404: Weak random is acceptable for load balancing selection (non-cryptographic use)
-	return &xdsBalancer{
+	b := &xdsBalancer{
 		cli:              cli,
 		remotesClient:    make(map[string]remote.Client),
 		vhosts:           make([]*xdsresource.VirtualHost, 0),
@@ -62,8 +134,23 @@ func newXdsBalancer(_ string, _ string, cli balancer.Client) (balancer.Balancer,
 		outlierDetectors: make(map[string]*OutlierDetector),
 		rateLimiters:     make(map[string]*RateLimiter),
 		inFlight:         make(map[string]*int32),
+		retiring:         make(map[string]remote.Client),
+		rrWeights:        make(map[string]map[string]int64),
 		rng:              mrand.New(mrand.NewSource(time.Now().UnixNano())),
-	}, nil
+		endpointBreakers: make(map[string]*EndpointCircuitBreaker),
+		pickLog:          newPickLogger(serviceName, cfg.PickLog),
+		failFastEmptyEDS: cfg.FailFastEmptyEDS,
+		outlierHooks:     options.outlierHooks,
+	}
+	rls, err := newRateLimitService(cfg.RateLimitService)
+	if err != nil {
+		slog.Warn("create xds rate limit service client failed",
+			slog.String("service", serviceName), slog.Any("error", err))
+	}
+	b.rls = rls
+	startStatsMetrics(cfg.StatsMetrics, serviceName, b)
+	liveBalancers.add(b, serviceName)
+	return b, nil
 }
 
 func (b *xdsBalancer) UpdateState(state resolver.State) {
@@ -73,6 +160,7 @@ func (b *xdsBalancer) UpdateState(state resolver.State) {
 	}
 
 	b.mu.Lock()
+	b.state = state
 	staleClients := b.refreshRemoteClientsLocked(endpoints)
 	b.applyAttributesLocked(state.GetAttributes())
 	b.rebuildEndpointsLocked(endpoints)
@@ -95,6 +183,11 @@ func (b *xdsBalancer) refreshRemoteClientsLocked(endpoints []resolver.Endpoint)
 			nextClients[endpointKey] = client
 			continue
 		}
+		if client, ok := b.retiring[endpointKey]; ok {
+			delete(b.retiring, endpointKey)
+			nextClients[endpointKey] = client
+			continue
+		}
 
 		client, err := b.cli.NewRemoteClient(
 			endpoint,
@@ -112,9 +205,14 @@ func (b *xdsBalancer) refreshRemoteClientsLocked(endpoints []resolver.Endpoint)
 
 	staleClients := make([]remote.Client, 0)
 	for key, client := range b.remotesClient {
-		if _, ok := nextClients[key]; !ok {
-			staleClients = append(staleClients, client)
+		if _, ok := nextClients[key]; ok {
+			continue
+		}
+		if value := b.inFlight[key]; value != nil && atomic.LoadInt32(value) > 0 {
+			b.retiring[key] = client
+			continue
 		}
+		staleClients = append(staleClients, client)
 	}
 
 	b.remotesClient = nextClients
@@ -127,6 +225,11 @@ func (b *xdsBalancer) applyAttributesLocked(attributes map[string]any) {
 	} else {
 		b.vhosts = nil
 	}
+	b.scopedRoutes, _ =
+		attributes[xdsresource.AttributeScopedRoutes].([]*xdsresource.ScopedRouteTable)
+	b.vhdsLookup, _ =
+		attributes[xdsresource.AttributeVirtualHostLookup].(xdsresource.VirtualHostLookup)
+	b.edsReceived, _ = attributes[xdsresource.AttributeEDSReceived].(map[string]bool)
 
 	clusters, ok := attributes[xdsresource.AttributeClusters].(map[string]clusterPolicy)
 	if !ok {
@@ -143,31 +246,52 @@ func (b *xdsBalancer) applyAttributesLocked(attributes map[string]any) {
 		return
 	}
 
-	for _, detector := range b.outlierDetectors {
-		detector.Stop()
-	}
-	for _, limiter := range b.rateLimiters {
-		limiter.Stop()
-	}
-
 	nextPolicies := make(map[string]clusterPolicy, len(clusters))
 	nextCircuitBreakers := make(map[string]*CircuitBreaker, len(clusters))
 	nextOutlierDetectors := make(map[string]*OutlierDetector, len(clusters))
 	nextRateLimiters := make(map[string]*RateLimiter, len(clusters))
 
+	// A cluster whose policy is unchanged keeps its breaker, detector and
+	// limiter, so in-flight counts, ejections and token buckets survive
+	// endpoint updates.
 	for clusterName, policy := range clusters {
 		nextPolicies[clusterName] = policy
+		previous := b.clusterPolicies[clusterName]
 
 		if policy.CircuitBreaker != nil {
-			nextCircuitBreakers[clusterName] = NewCircuitBreaker(policy.CircuitBreaker)
+			breaker := b.circuitBreakers[clusterName]
+			if breaker == nil || !sameConfig(previous.CircuitBreaker, policy.CircuitBreaker) {
+				breaker = NewCircuitBreaker(policy.CircuitBreaker)
+			}
+			nextCircuitBreakers[clusterName] = breaker
 		}
 		if policy.OutlierDetection != nil {
-			detector := NewOutlierDetector(policy.OutlierDetection)
+			detector := b.outlierDetectors[clusterName]
+			if detector == nil ||
+				!sameConfig(previous.OutlierDetection, policy.OutlierDetection) {
+				detector = NewOutlierDetectorWithClock(policy.OutlierDetection, b.clock)
+				b.outlierHooks.bind(clusterName, detector)
+				detector.Start()
+			}
 			nextOutlierDetectors[clusterName] = detector
-			detector.Start()
 		}
 		if policy.RateLimiter != nil {
-			nextRateLimiters[clusterName] = NewRateLimiter(policy.RateLimiter)
+			limiter := b.rateLimiters[clusterName]
+			if limiter == nil || !sameConfig(previous.RateLimiter, policy.RateLimiter) {
+				limiter = NewRateLimiterWithClock(policy.RateLimiter, b.clock)
+			}
+			nextRateLimiters[clusterName] = limiter
+		}
+	}
+
+	for clusterName, detector := range b.outlierDetectors {
+		if nextOutlierDetectors[clusterName] != detector {
+			detector.Stop()
+		}
+	}
+	for clusterName, limiter := range b.rateLimiters {
+		if nextRateLimiters[clusterName] != limiter {
+			limiter.Stop()
 		}
 	}
 
@@ -177,8 +301,18 @@ func (b *xdsBalancer) applyAttributesLocked(attributes map[string]any) {
 	b.rateLimiters = nextRateLimiters
 }
 
+// sameConfig reports whether two policy configs are both nil or hold equal
+// values.
+func sameConfig[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (b *xdsBalancer) rebuildEndpointsLocked(endpoints []resolver.Endpoint) {
 	b.endpoints = make(map[string][]*weightedEndpoint)
+	nextBreakers := make(map[string]*EndpointCircuitBreaker)
 	for _, endpoint := range endpoints {
 		weighted, endpointKey, ok := b.buildWeightedEndpoint(endpoint)
 		if !ok {
@@ -191,6 +325,52 @@ func (b *xdsBalancer) rebuildEndpointsLocked(endpoints []resolver.Endpoint) {
 			value := int32(0)
 			b.inFlight[endpointKey] = &value
 		}
+
+		// Per-endpoint breakers survive endpoint updates so an open breaker
+		// keeps isolating its endpoint until it recovers.
+		config := b.clusterPolicies[weighted.Cluster].EndpointCircuitBreaker
+		if config == nil {
+			continue
+		}
+		breakerKey := endpointAddress(weighted)
+		if breaker, ok := b.endpointBreakers[breakerKey]; ok {
+			nextBreakers[breakerKey] = breaker
+		} else if _, ok := nextBreakers[breakerKey]; !ok {
+			nextBreakers[breakerKey] = NewEndpointCircuitBreakerWithClock(config, b.clock)
+		}
+	}
+	b.endpointBreakers = nextBreakers
+	b.pruneRoundRobinLocked()
+
+	for cluster, detector := range b.outlierDetectors {
+		hosts := make(map[string]HealthStatus, len(b.endpoints[cluster]))
+		for _, endpoint := range b.endpoints[cluster] {
+			hosts[endpointAddress(endpoint)] = ParseHealthStatus(endpoint.Metadata["health"])
+		}
+		detector.UpdateHosts(hosts)
+	}
+}
+
+// pruneRoundRobinLocked drops the round-robin weights of clusters and
+// endpoints that are gone, keeping the rotation of the remaining ones.
+func (b *xdsBalancer) pruneRoundRobinLocked() {
+	b.rrMu.Lock()
+	defer b.rrMu.Unlock()
+	for cluster, weights := range b.rrWeights {
+		endpoints, ok := b.endpoints[cluster]
+		if !ok {
+			delete(b.rrWeights, cluster)
+			continue
+		}
+		present := make(map[string]bool, len(endpoints))
+		for _, endpoint := range endpoints {
+			present[endpointAddress(endpoint)] = true
+		}
+		for address := range weights {
+			if !present[address] {
+				delete(weights, address)
+			}
+		}
 	}
 }
 
@@ -228,6 +408,7 @@ func (b *xdsBalancer) buildWeightedEndpoint(
 	if metadata, ok := attributes[xdsresource.AttributeEndpointMetadata].(map[string]string); ok {
 		weighted.Metadata = metadata
 	}
+	weighted.Draining = ParseHealthStatus(weighted.Metadata["health"]) == HealthDraining
 
 	return weighted, address, true
 }
@@ -271,6 +452,10 @@ func (b *xdsBalancer) Close() error {
 	for _, client := range b.remotesClient {
 		clients = append(clients, client)
 	}
+	for _, client := range b.retiring {
+		clients = append(clients, client)
+	}
+	b.retiring = nil
 	for _, detector := range b.outlierDetectors {
 		detector.Stop()
 	}
@@ -278,8 +463,14 @@ func (b *xdsBalancer) Close() error {
 		limiter.Stop()
 	}
 	b.remotesClient = nil
+	statsMetrics := b.statsMetrics
+	b.statsMetrics = nil
 	picker := b.buildPicker()
 	b.mu.Unlock()
+	liveBalancers.remove(b)
+	if statsMetrics != nil {
+		_ = statsMetrics.Unregister()
+	}
 
 	b.cli.UpdateState(balancer.State{Picker: picker})
 
@@ -289,6 +480,11 @@ func (b *xdsBalancer) Close() error {
 			multiErr = errors.Join(multiErr, err)
 		}
 	}
+	if b.rls != nil {
+		if err := b.rls.close(); err != nil {
+			multiErr = errors.Join(multiErr, err)
+		}
+	}
 	return multiErr
 }
 
@@ -300,7 +496,10 @@ func (b *xdsBalancer) Type() string {
 type BalancerStats struct {
 	CircuitBreakers  map[string]CircuitBreakerStats
 	OutlierDetectors map[string]map[string]any
+	OutlierEndpoints map[string]map[string]OutlierEndpointStats
 	RateLimiters     map[string]RateLimiterStats
+
+	EndpointCircuitBreakers map[string]EndpointCircuitBreakerStats
 }
 
 func (b *xdsBalancer) GetStats() BalancerStats {
@@ -313,8 +512,10 @@ func (b *xdsBalancer) GetStats() BalancerStats {
 	}
 
 	outlierDetectorStats := make(map[string]map[string]interface{})
+	outlierEndpointStats := make(map[string]map[string]OutlierEndpointStats)
 	for name, detector := range b.outlierDetectors {
 		outlierDetectorStats[name] = detector.GetStats()
+		outlierEndpointStats[name] = detector.GetEndpointStats()
 	}
 
 	rateLimiterStats := make(map[string]RateLimiterStats)
@@ -322,13 +523,20 @@ func (b *xdsBalancer) GetStats() BalancerStats {
 		rateLimiterStats[name] = limiter.GetStats()
 	}
 
+	endpointBreakerStats := make(map[string]EndpointCircuitBreakerStats)
+	for key, breaker := range b.endpointBreakers {
+		endpointBreakerStats[key] = breaker.GetStats()
+	}
+
 	return BalancerStats{
-		CircuitBreakers:  circuitBreakerStats,
-		OutlierDetectors: outlierDetectorStats,
-		RateLimiters:     rateLimiterStats,
+		CircuitBreakers:         circuitBreakerStats,
+		OutlierDetectors:        outlierDetectorStats,
+		OutlierEndpoints:        outlierEndpointStats,
+		RateLimiters:            rateLimiterStats,
+		EndpointCircuitBreakers: endpointBreakerStats,
 	}
 }
 
 func (b *xdsBalancer) buildPicker() *xdsPicker {
-	return &xdsPicker{balancer: b}
+	return &xdsPicker{balancer: b, p2c: b.p2cSnapshotsLocked()}
 }
@@ -14,15 +14,73 @@
 
 package traffic
 
-import "fmt"
+import (
+	"fmt"
 
-// LoadBalancerConfig loads xDS balancer configuration from the config source.
-func LoadBalancerConfig(_ string) BalancerConfig {
-	return BalancerConfig{}
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+	"github.com/mitchellh/mapstructure"
+)
+
+// defaultPickLogMaxPerSecond bounds pick logging when max_per_second is unset.
+const defaultPickLogMaxPerSecond = 10
+
+// LoadBalancerConfig loads xDS balancer configuration from the config source,
+// merging the balancer defaults with the per-service overrides.
+func LoadBalancerConfig(serviceName, balancerName string) (BalancerConfig, error) {
+	return DecodeBalancerConfig(balancer.LoadConfig(serviceName, balancerName))
+}
+
+// DecodeBalancerConfig decodes a raw balancer config map over the defaults.
+func DecodeBalancerConfig(input map[string]any) (BalancerConfig, error) {
+	cfg := defaultBalancerConfig()
+	if len(input) == 0 {
+		return cfg, nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &cfg,
+	})
+	if err != nil {
+		return BalancerConfig{}, err
+	}
+	if err := decoder.Decode(input); err != nil {
+		return BalancerConfig{}, fmt.Errorf("decode xds balancer config: %w", err)
+	}
+	if cfg.PickLog.MaxPerSecond <= 0 {
+		cfg.PickLog.MaxPerSecond = defaultPickLogMaxPerSecond
+	}
+	return cfg, nil
+}
+
+func defaultBalancerConfig() BalancerConfig {
+	return BalancerConfig{
+		PickLog: PickLogConfig{MaxPerSecond: defaultPickLogMaxPerSecond},
+	}
 }
 
 // BalancerConfig holds xDS balancer configuration.
-type BalancerConfig struct{}
+type BalancerConfig struct {
+	PickLog PickLogConfig `mapstructure:"pick_log"`
+	// FailFastEmptyEDS separates clusters whose EDS has not arrived yet, which
+	// keep RPCs waiting, from clusters whose EDS arrived with no endpoints,
+	// which fail RPCs immediately and are skipped by weighted cluster
+	// selection. When unset, both wait and both are skipped.
+	FailFastEmptyEDS bool `mapstructure:"fail_fast_empty_eds"`
+	// StatsMetrics exports GetStats as OpenTelemetry metrics.
+	StatsMetrics StatsMetricsConfig `mapstructure:"stats_metrics"`
+	// RateLimitService is consulted by clusters with global rate limiting.
+	RateLimitService RateLimitServiceConfig `mapstructure:"rate_limit_service"`
+}
+
+// PickLogConfig controls the per-pick access log.
+type PickLogConfig struct {
+	// Enabled turns on a debug-level log entry for every pick.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxPerSecond caps the number of entries written per second; the rest
+	// are counted and reported on the next written entry.
+	MaxPerSecond int `mapstructure:"max_per_second"`
+}
 
 func (b *BalancerConfig) String() string {
 	return fmt.Sprintf("%+v", *b)
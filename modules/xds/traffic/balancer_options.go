@@ -0,0 +1,55 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+// BalancerOption customizes the balancers created by BalancerProvider.
+type BalancerOption func(*balancerOptions)
+
+type balancerOptions struct {
+	outlierHooks OutlierHooks
+}
+
+// OutlierHooks lets applications react to outlier detection, for example by
+// emitting metrics or alerts. The callbacks run without detector locks held.
+type OutlierHooks struct {
+	// OnEject, when set, is called with the cluster, the endpoint address and
+	// the ejection reason each time an endpoint is ejected.
+	OnEject func(cluster, endpoint, reason string)
+	// OnRecover, when set, is called with the cluster, the endpoint address
+	// and the recovery reason when an ejected endpoint returns to service.
+	OnRecover func(cluster, endpoint, reason string)
+}
+
+// WithOutlierHooks sets the callbacks run when an endpoint of any cluster is
+// ejected by outlier detection or returns to service.
+func WithOutlierHooks(hooks OutlierHooks) BalancerOption {
+	return func(opts *balancerOptions) {
+		opts.outlierHooks = hooks
+	}
+}
+
+// bind attaches the hooks to the detector of cluster.
+func (h OutlierHooks) bind(cluster string, detector *OutlierDetector) {
+	if h.OnEject != nil {
+		detector.onEject = func(endpoint, reason string) {
+			h.OnEject(cluster, endpoint, reason)
+		}
+	}
+	if h.OnRecover != nil {
+		detector.onRecover = func(endpoint, reason string) {
+			h.OnRecover(cluster, endpoint, reason)
+		}
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	mrand "math/rand"
+	randv2 "math/rand/v2"
 	"sync/atomic"
 
 	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
@@ -27,55 +29,206 @@ import (
 	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
 )
 
+// overprovisioningFactor is Envoy's default overprovisioning factor, in
+// percent, used to size the share of healthy endpoints in a priority level.
+const overprovisioningFactor = 140
+
 type xdsPicker struct {
 	balancer *xdsBalancer
+	// p2c holds the least_request_p2c clusters picked without the balancer
+	// lock.
+	p2c map[string]*p2cSnapshot
 }
 
 func (p *xdsPicker) Next(ri balancer.RPCInfo) (balancer.PickResult, error) {
+	var entry pickLogEntry
+	result, err := p.pick(ri, &entry)
+	p.balancer.pickLog.log(ri.Ctx, &entry)
+	return result, err
+}
+
+func (p *xdsPicker) pick(ri balancer.RPCInfo, entry *pickLogEntry) (balancer.PickResult, error) {
+	headers := requestHeaders(ri.Ctx)
+	if p.awaitVirtualHost(headers) {
+		entry.decision = pickDecisionNoRoute
+		return nil, balancer.ErrNoAvailableInstance
+	}
+	cluster, global, fault, err := p.route(ri, headers, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rate limit service is called without the balancer lock, so a slow
+	// answer does not hold up endpoint updates. The local limiter only
+	// decides when the service is not configured or fails.
+	checkLocal := true
+	if global != nil && p.balancer.rls != nil {
+		overLimit, err := p.balancer.rls.overLimit(ri.Ctx, global, headers)
+		switch {
+		case err != nil:
+			slog.Debug("rate limit service failed, using the local limiter",
+				slog.String("cluster", cluster), slog.Any("error", err))
+		case overLimit:
+			entry.decision = pickDecisionRateLimited
+			return nil, errRateLimitExceeded
+		default:
+			checkLocal = false
+		}
+	}
+
+	result, err := p.pickCluster(ri, cluster, checkLocal, entry)
+	if err != nil {
+		return nil, err
+	}
+	if fault == nil {
+		return result, nil
+	}
+
+	// The fault is injected only once an endpoint is picked, so the picks the
+	// client retries while endpoints connect neither repeat nor delay it.
+	if err := injectFault(ri.Ctx, fault); err != nil {
+		result.abandon()
+		entry.decision = pickDecisionFaultAbort
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *xdsPicker) pickCluster(
+	ri balancer.RPCInfo,
+	cluster string,
+	checkLocal bool,
+	entry *pickLogEntry,
+) (*pickResult, error) {
+	if snapshot := p.p2c[cluster]; snapshot != nil && len(routeMetadataMatch(entry)) == 0 {
+		if result, ok, err := p.pickSnapshot(ri, snapshot, checkLocal, entry); ok {
+			return result, err
+		}
+	}
+
+	p.balancer.mu.RLock()
+	defer p.balancer.mu.RUnlock()
+	return p.pickEndpoint(ri, cluster, checkLocal, entry)
+}
+
+func routeMetadataMatch(entry *pickLogEntry) map[string]string {
+	if entry.route == nil || entry.route.Action == nil {
+		return nil
+	}
+	return entry.route.Action.MetadataMatch
+}
+
+// awaitVirtualHost looks up the request host over VHDS when no virtual host
+// matches it and reports whether the pick has to wait for the answer. The
+// lookup runs without the balancer lock because the resolver may update the
+// balancer while it holds its own lock.
+func (p *xdsPicker) awaitVirtualHost(headers map[string]string) bool {
+	host := xdsresource.RequestHost(headers)
+	if host == "" {
+		return false
+	}
+
+	p.balancer.mu.RLock()
+	lookup := p.balancer.vhdsLookup
+	matched := lookup == nil || xdsresource.MatchVirtualHost(
+		xdsresource.RequestVirtualHosts(p.balancer.vhosts, p.balancer.scopedRoutes, headers),
+		host,
+	) != nil
+	p.balancer.mu.RUnlock()
+
+	return !matched && lookup(host)
+}
+
+// route resolves the cluster of an RPC, the cluster's global rate limit
+// config, and the route's fault injection.
+func (p *xdsPicker) route(
+	ri balancer.RPCInfo,
+	headers map[string]string,
+	entry *pickLogEntry,
+) (string, *GlobalRateLimitConfig, *FaultConfig, error) {
 	p.balancer.mu.RLock()
 	defer p.balancer.mu.RUnlock()
 
-	headers := requestHeaders(ri.Ctx)
 	path := headers[":path"]
 	if path == "" {
 		path = ri.Method
 	}
+	entry.path = path
 
-	cluster, circuitBreaker, rateLimiter := p.selectCluster(path, headers)
+	cluster := p.selectCluster(path, headers, entry)
 	if cluster == "" {
-		return nil, balancer.ErrNoAvailableInstance
+		entry.decision = pickDecisionNoRoute
+		return "", nil, nil, balancer.ErrNoAvailableInstance
 	}
+	entry.cluster = cluster
+
+	if p.balancer.clusterEmpty(cluster) {
+		entry.decision = pickDecisionClusterEmpty
+		return "", nil, nil, fmt.Errorf("%w: %s", errClusterEmpty, cluster)
+	}
+	return cluster, p.balancer.clusterPolicies[cluster].GlobalRateLimit, entry.route.Fault, nil
+}
 
-	if rateLimiter != nil && !rateLimiter.Allow() {
+func (p *xdsPicker) pickEndpoint(
+	ri balancer.RPCInfo,
+	cluster string,
+	checkLocal bool,
+	entry *pickLogEntry,
+) (*pickResult, error) {
+	circuitBreaker := p.balancer.circuitBreakers[cluster]
+	rateLimiter := p.balancer.rateLimiters[cluster]
+	if checkLocal && rateLimiter != nil && !rateLimiter.Allow() {
+		entry.decision = pickDecisionRateLimited
 		return nil, errRateLimitExceeded
 	}
 	if circuitBreaker != nil && !circuitBreaker.TryAcquire(ResourceRequest) {
+		entry.decision = pickDecisionCircuitOpen
 		return nil, errors.New("circuit breaker open: max requests reached")
 	}
 
-	endpoint := p.balancer.selectEndpoint(cluster, p.balancer.outlierDetectors[cluster])
+	match := routeMetadataMatch(entry)
+	endpoint := p.balancer.selectEndpoint(cluster, p.balancer.outlierDetectors[cluster], match)
 	if endpoint == nil {
 		if circuitBreaker != nil {
 			circuitBreaker.Release(ResourceRequest)
 		}
+		entry.decision = pickDecisionNoEndpoint
 		return nil, balancer.ErrNoAvailableInstance
 	}
 
 	endpointKey := endpointAddress(endpoint)
+	entry.endpoint = endpointKey
 	client, ok := p.balancer.remotesClient[endpointKey]
 	if !ok || client.State() != remote.Ready {
 		if circuitBreaker != nil {
 			circuitBreaker.Release(ResourceRequest)
 		}
+		entry.decision = pickDecisionEndpointNotReady
 		return nil, balancer.ErrNoAvailableInstance
 	}
 
+	endpointBreaker := p.balancer.endpointBreakers[endpointKey]
+	if endpointBreaker != nil && !endpointBreaker.TryAcquire() {
+		if circuitBreaker != nil {
+			circuitBreaker.Release(ResourceRequest)
+		}
+		entry.decision = pickDecisionEndpointCircuitOpen
+		return nil, errors.New("endpoint circuit breaker open: " + endpointKey)
+	}
+
+	// Count every pick, not only least_request ones, so a removed endpoint
+	// can be closed once its last RPC reports.
+	if value := p.balancer.inFlight[endpointKey]; value != nil {
+		atomic.AddInt32(value, 1)
+	}
+	entry.decision = pickDecisionPicked
 	return &pickResult{
 		endpoint:        client,
 		ctx:             ri.Ctx,
 		balancer:        p.balancer,
 		inflightKey:     endpointKey,
 		circuitBreaker:  circuitBreaker,
+		endpointBreaker: endpointBreaker,
 		rateLimiter:     rateLimiter,
 		outlierDetector: p.balancer.outlierDetectors[cluster],
 	}, nil
@@ -98,24 +251,77 @@ func requestHeaders(ctx context.Context) map[string]string {
 func (p *xdsPicker) selectCluster(
 	path string,
 	headers map[string]string,
-) (string, *CircuitBreaker, *RateLimiter) {
-	action := xdsresource.MatchRoute(p.balancer.vhosts, path, headers)
-	if action == nil {
-		return "", nil, nil
+	entry *pickLogEntry,
+) string {
+	vhosts := xdsresource.RequestVirtualHosts(p.balancer.vhosts, p.balancer.scopedRoutes, headers)
+	vhost, route := xdsresource.FindRoute(vhosts, path, headers, nil)
+	if route == nil || route.Action == nil {
+		return ""
 	}
+	entry.virtualHost = vhost.Name
+	entry.route = route
+
+	action := route.Action
 
 	cluster := action.Cluster
 	if action.WeightedClusters != nil && len(action.WeightedClusters.Clusters) > 0 {
 		cluster = p.balancer.selectWeightedCluster(action.WeightedClusters)
 	}
-	if cluster == "" {
-		return "", nil, nil
+	return cluster
+}
+
+// selectWeightedCluster draws a cluster by weight. When the draw lands on a
+// cluster without healthy endpoints, it re-draws among the clusters that still
+// have some, with their weights renormalized.
+func (b *xdsBalancer) selectWeightedCluster(weightedClusters *xdsresource.WeightedClusters) string {
+	cluster := selectWeightedCluster(b.rng, weightedClusters)
+	if cluster == "" || b.clusterAvailable(cluster) {
+		return cluster
+	}
+
+	available := &xdsresource.WeightedClusters{}
+	for _, candidate := range weightedClusters.Clusters {
+		if candidate.Name == cluster || !b.clusterAvailable(candidate.Name) {
+			continue
+		}
+		available.Clusters = append(available.Clusters, candidate)
+		available.TotalWeight += candidate.Weight
+	}
+	if len(available.Clusters) == 0 {
+		return cluster
+	}
+	return selectWeightedCluster(b.rng, available)
+}
+
+func (b *xdsBalancer) clusterAvailable(cluster string) bool {
+	if b.edsPending(cluster) {
+		// Keep the draw so the RPC waits for the assignment instead of
+		// failing over before the cluster had a chance to come up.
+		return true
+	}
+	endpoints := b.endpoints[cluster]
+	if len(endpoints) == 0 {
+		return false
 	}
+	return len(b.availableEndpoints(endpoints, b.outlierDetectors[cluster])) > 0
+}
 
-	return cluster, p.balancer.circuitBreakers[cluster], p.balancer.rateLimiters[cluster]
+// edsPending reports whether fail_fast_empty_eds is set and the cluster's
+// EDS load assignment has not been received yet.
+func (b *xdsBalancer) edsPending(cluster string) bool {
+	return b.failFastEmptyEDS && b.edsReceived != nil && !b.edsReceived[cluster]
 }
 
-func (b *xdsBalancer) selectWeightedCluster(weightedClusters *xdsresource.WeightedClusters) string {
+// clusterEmpty reports whether fail_fast_empty_eds is set and the cluster's
+// EDS load assignment was received without any endpoints.
+func (b *xdsBalancer) clusterEmpty(cluster string) bool {
+	return b.failFastEmptyEDS && b.edsReceived[cluster] && len(b.endpoints[cluster]) == 0
+}
+
+func selectWeightedCluster(
+	rng *mrand.Rand,
+	weightedClusters *xdsresource.WeightedClusters,
+) string {
 	if weightedClusters.TotalWeight == 0 {
 		if len(weightedClusters.Clusters) == 0 {
 			return ""
@@ -123,7 +329,7 @@ func (b *xdsBalancer) selectWeightedCluster(weightedClusters *xdsresource.Weight
 		return weightedClusters.Clusters[0].Name
 	}
 
-	randomWeight := b.rng.Uint32() % weightedClusters.TotalWeight
+	randomWeight := rng.Uint32() % weightedClusters.TotalWeight
 	accumulatedWeight := uint32(0)
 	for _, cluster := range weightedClusters.Clusters {
 		accumulatedWeight += cluster.Weight
@@ -134,38 +340,44 @@ func (b *xdsBalancer) selectWeightedCluster(weightedClusters *xdsresource.Weight
 	return weightedClusters.Clusters[0].Name
 }
 
-func (b *xdsBalancer) selectEndpoint(cluster string, detector *OutlierDetector) *weightedEndpoint {
+// selectEndpoint picks an endpoint of the cluster. A non-empty match narrows
+// the endpoints to those whose metadata has all of its key/value pairs before
+// the LB policy applies.
+func (b *xdsBalancer) selectEndpoint(
+	cluster string,
+	detector *OutlierDetector,
+	match map[string]string,
+) *weightedEndpoint {
 	endpoints, ok := b.endpoints[cluster]
 	if !ok || len(endpoints) == 0 {
 		return nil
 	}
 
-	healthyEndpoints := filterHealthyEndpoints(endpoints, detector)
-	if len(healthyEndpoints) == 0 {
-		return nil
+	policy, ok := b.clusterPolicies[cluster]
+	if !ok {
+		policy = clusterPolicy{LBPolicy: "round_robin"}
 	}
 
+	endpoints = subsetEndpoints(endpoints, match, policy.Subset)
 	priorityGroups := make(map[uint32][]*weightedEndpoint)
-	for _, endpoint := range healthyEndpoints {
+	for _, endpoint := range endpoints {
 		priorityGroups[endpoint.Priority] = append(priorityGroups[endpoint.Priority], endpoint)
 	}
 
-	policy, ok := b.clusterPolicies[cluster]
-	if !ok {
-		policy = clusterPolicy{LBPolicy: "round_robin"}
-	}
-
 	for priority := uint32(0); priority <= 10; priority++ {
-		group := priorityGroups[priority]
+		group := b.selectHealthPool(priorityGroups[priority], detector)
 		if len(group) == 0 {
 			continue
 		}
+		group = b.preferReady(group)
 
 		switch policy.LBPolicy {
 		case "random":
 			return b.selectRandom(group)
 		case "least_request":
 			return b.selectLeastRequest(group)
+		case "least_request_p2c":
+			return b.selectLeastRequestP2C(group)
 		default:
 			return b.selectRoundRobin(group)
 		}
@@ -174,6 +386,125 @@ func (b *xdsBalancer) selectEndpoint(cluster string, detector *OutlierDetector)
 	return nil
 }
 
+// subsetEndpoints returns the endpoints matching every key/value pair of
+// match. When none do, the cluster's subset fallback policy decides: no
+// endpoint (the default), every endpoint, or the default subset.
+func subsetEndpoints(
+	endpoints []*weightedEndpoint,
+	match map[string]string,
+	subset *xdsresource.SubsetConfig,
+) []*weightedEndpoint {
+	if len(match) == 0 {
+		return endpoints
+	}
+	if matched := matchEndpoints(endpoints, match); len(matched) > 0 {
+		return matched
+	}
+	if subset == nil {
+		return nil
+	}
+	switch subset.FallbackPolicy {
+	case xdsresource.SubsetAnyEndpoint:
+		return endpoints
+	case xdsresource.SubsetDefaultSubset:
+		return matchEndpoints(endpoints, subset.DefaultSubset)
+	default:
+		return nil
+	}
+}
+
+func matchEndpoints(endpoints []*weightedEndpoint, match map[string]string) []*weightedEndpoint {
+	var matched []*weightedEndpoint
+	for _, endpoint := range endpoints {
+		if metadataMatches(endpoint.Metadata, match) {
+			matched = append(matched, endpoint)
+		}
+	}
+	return matched
+}
+
+func metadataMatches(metadata, match map[string]string) bool {
+	for key, value := range match {
+		if got, ok := metadata[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectHealthPool returns the endpoints of one priority level to balance
+// across. Degraded endpoints form an overflow pool: like Envoy, healthy
+// endpoints absorb min(100%, 1.4 * healthy / total) of the traffic and only
+// the remainder goes to degraded endpoints. Ejected endpoints and endpoints
+// with an open circuit breaker count towards the total but are never returned.
+func (b *xdsBalancer) selectHealthPool(
+	group []*weightedEndpoint,
+	detector *OutlierDetector,
+) []*weightedEndpoint {
+	var healthy, degraded []*weightedEndpoint
+	for _, endpoint := range b.availableEndpoints(group, detector) {
+		if ParseHealthStatus(endpoint.Metadata["health"]) == HealthDegraded {
+			degraded = append(degraded, endpoint)
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+	if len(degraded) == 0 {
+		return healthy
+	}
+	if len(healthy) == 0 {
+		return degraded
+	}
+
+	healthyLoad := overprovisioningFactor * len(healthy) / len(group)
+	if healthyLoad >= 100 || randv2.IntN(100) < healthyLoad {
+		return healthy
+	}
+	return degraded
+}
+
+// preferReady narrows group to the endpoints whose remote client is Ready, so
+// picks right after an endpoint update avoid clients that are still
+// connecting. When none is Ready the whole group is kept: the pick then lands
+// on a connecting client and returns balancer.ErrNoAvailableInstance, which
+// makes the RPC wait for the picker published once a client becomes Ready.
+func (b *xdsBalancer) preferReady(group []*weightedEndpoint) []*weightedEndpoint {
+	ready := make([]*weightedEndpoint, 0, len(group))
+	for _, endpoint := range group {
+		client := b.remotesClient[endpointAddress(endpoint)]
+		if client != nil && client.State() == remote.Ready {
+			ready = append(ready, endpoint)
+		}
+	}
+	if len(ready) == 0 {
+		return group
+	}
+	return ready
+}
+
+// availableEndpoints drops endpoints that EDS reports as draining, that are
+// ejected by outlier detection, or that are isolated by their own circuit
+// breaker. Draining endpoints keep serving RPCs already picked.
+func (b *xdsBalancer) availableEndpoints(
+	endpoints []*weightedEndpoint,
+	detector *OutlierDetector,
+) []*weightedEndpoint {
+	healthy := filterHealthyEndpoints(endpoints, detector)
+
+	available := healthy[:0]
+	for _, endpoint := range healthy {
+		if endpoint.Draining {
+			continue
+		}
+		breaker := b.endpointBreakers[endpointAddress(endpoint)]
+		if breaker != nil && !breaker.Available() {
+			continue
+		}
+		available = append(available, endpoint)
+	}
+	return available
+}
+
 func filterHealthyEndpoints(
 	endpoints []*weightedEndpoint,
 	detector *OutlierDetector,
@@ -188,28 +519,56 @@ func filterHealthyEndpoints(
 	return healthyEndpoints
 }
 
+// selectRoundRobin rotates through endpoints in proportion to their weights
+// with nginx's smooth weighted round-robin: every pick adds each endpoint's
+// weight to its current weight, takes the endpoint with the highest current
+// weight, and subtracts the total weight from it. Weights {1, 2, 3} therefore
+// yield C B A C B C rather than bursts. The current weights are kept per
+// cluster, so the rotation continues across picks and endpoint updates. When
+// every weight is zero the endpoints rotate evenly.
 func (b *xdsBalancer) selectRoundRobin(endpoints []*weightedEndpoint) *weightedEndpoint {
 	if len(endpoints) == 0 {
 		return nil
 	}
 
-	totalWeight := uint32(0)
+	totalWeight := int64(0)
 	for _, endpoint := range endpoints {
-		totalWeight += endpoint.Weight
+		totalWeight += int64(endpoint.Weight)
+	}
+	weightOf := func(endpoint *weightedEndpoint) int64 {
+		if totalWeight == 0 {
+			return 1
+		}
+		return int64(endpoint.Weight)
 	}
 	if totalWeight == 0 {
-		return endpoints[0]
+		totalWeight = int64(len(endpoints))
 	}
 
-	randomWeight := b.rng.Uint32() % totalWeight
-	accumulatedWeight := uint32(0)
+	b.rrMu.Lock()
+	defer b.rrMu.Unlock()
+	if b.rrWeights == nil {
+		b.rrWeights = make(map[string]map[string]int64)
+	}
+	cluster := endpoints[0].Cluster
+	current := b.rrWeights[cluster]
+	if current == nil {
+		current = make(map[string]int64, len(endpoints))
+		b.rrWeights[cluster] = current
+	}
+
+	var selected *weightedEndpoint
+	var selectedKey string
 	for _, endpoint := range endpoints {
-		accumulatedWeight += endpoint.Weight
-		if randomWeight < accumulatedWeight {
-			return endpoint
+		key := endpointAddress(endpoint)
+		current[key] += weightOf(endpoint)
+		if selected == nil || current[key] > current[selectedKey] {
+			selected = endpoint
+			selectedKey = key
 		}
 	}
-	return endpoints[0]
+	current[selectedKey] -= totalWeight
+	return selected
 }
 
 func (b *xdsBalancer) selectRandom(endpoints []*weightedEndpoint) *weightedEndpoint {
@@ -236,13 +595,225 @@ func (b *xdsBalancer) selectLeastRequest(endpoints []*weightedEndpoint) *weighte
 			selected = endpoint
 		}
 	}
+	return selected
+}
 
-	if selected != nil {
-		if value, ok := b.inFlight[endpointAddress(selected)]; ok && value != nil {
-			atomic.AddInt32(value, 1)
+// selectLeastRequestP2C samples two distinct endpoints and returns the one
+// with fewer in-flight requests, preferring the first on a tie. It reads only
+// the two sampled counters and draws from the goroutine-safe global source,
+// so concurrent pickers do not serialize on a full scan or on b.rng.
+func (b *xdsBalancer) selectLeastRequestP2C(endpoints []*weightedEndpoint) *weightedEndpoint {
+	switch len(endpoints) {
+	case 0:
+		return nil
+	case 1:
+		return endpoints[0]
+	}
+
+	i, j := sampleTwo(len(endpoints))
+	first, second := endpoints[i], endpoints[j]
+	if b.loadInFlight(second) < b.loadInFlight(first) {
+		return second
+	}
+	return first
+}
+
+func (b *xdsBalancer) loadInFlight(endpoint *weightedEndpoint) int32 {
+	return loadCount(b.inFlight[endpointAddress(endpoint)])
+}
+
+func loadCount(value *int32) int32 {
+	if value == nil {
+		return 0
+	}
+	return atomic.LoadInt32(value)
+}
+
+// sampleTwo draws two distinct indexes below n, the second -1 when n is 1.
+func sampleTwo(n int) (int, int) {
+	i := randv2.IntN(n)
+	if n == 1 {
+		return i, -1
+	}
+	j := randv2.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+	return i, j
+}
+
+// p2cCandidate is one endpoint of a p2cSnapshot with the state a pick reads.
+type p2cCandidate struct {
+	key      string
+	client   remote.Client
+	inFlight *int32
+	breaker  *EndpointCircuitBreaker
+}
+
+// p2cSnapshot holds the available endpoints of the first usable priority
+// level of a least_request_p2c cluster, taken when the picker is built, split
+// into the healthy and degraded pools of selectHealthPool. Picks sample it
+// without the balancer lock, re-checking only the state that changes without
+// a new picker: outlier ejections, endpoint breakers and in-flight counts.
+type p2cSnapshot struct {
+	healthy        []p2cCandidate
+	degraded       []p2cCandidate
+	healthyLoad    int
+	detector       *OutlierDetector
+	circuitBreaker *CircuitBreaker
+	rateLimiter    *RateLimiter
+}
+
+// p2cSnapshotsLocked snapshots the least_request_p2c clusters. The caller
+// holds b.mu.
+func (b *xdsBalancer) p2cSnapshotsLocked() map[string]*p2cSnapshot {
+	var snapshots map[string]*p2cSnapshot
+	for cluster, policy := range b.clusterPolicies {
+		if policy.LBPolicy != "least_request_p2c" {
+			continue
+		}
+		if snapshot := b.p2cSnapshotLocked(cluster); snapshot != nil {
+			if snapshots == nil {
+				snapshots = make(map[string]*p2cSnapshot)
+			}
+			snapshots[cluster] = snapshot
 		}
 	}
-	return selected
+	return snapshots
+}
+
+// p2cSnapshotLocked returns nil when the first priority level with available
+// endpoints has one whose remote client is not Ready: the locked path then
+// applies preferReady and waits for the picker of the next state change.
+func (b *xdsBalancer) p2cSnapshotLocked(cluster string) *p2cSnapshot {
+	detector := b.outlierDetectors[cluster]
+	priorityGroups := make(map[uint32][]*weightedEndpoint)
+	for _, endpoint := range b.endpoints[cluster] {
+		priorityGroups[endpoint.Priority] = append(priorityGroups[endpoint.Priority], endpoint)
+	}
+
+	for priority := uint32(0); priority <= 10; priority++ {
+		group := priorityGroups[priority]
+		available := b.availableEndpoints(group, detector)
+		if len(available) == 0 {
+			continue
+		}
+
+		snapshot := &p2cSnapshot{
+			detector:       detector,
+			circuitBreaker: b.circuitBreakers[cluster],
+			rateLimiter:    b.rateLimiters[cluster],
+		}
+		for _, endpoint := range available {
+			key := endpointAddress(endpoint)
+			client := b.remotesClient[key]
+			if client == nil || client.State() != remote.Ready {
+				return nil
+			}
+			candidate := p2cCandidate{
+				key:      key,
+				client:   client,
+				inFlight: b.inFlight[key],
+				breaker:  b.endpointBreakers[key],
+			}
+			if ParseHealthStatus(endpoint.Metadata["health"]) == HealthDegraded {
+				snapshot.degraded = append(snapshot.degraded, candidate)
+			} else {
+				snapshot.healthy = append(snapshot.healthy, candidate)
+			}
+		}
+		snapshot.healthyLoad = overprovisioningFactor * len(snapshot.healthy) / len(group)
+		return snapshot
+	}
+	return nil
+}
+
+// candidate draws a pool like selectHealthPool and returns the less loaded of
+// two sampled candidates, skipping one ejected or behind an open endpoint
+// breaker since the snapshot. It returns nil when neither is usable.
+func (s *p2cSnapshot) candidate() *p2cCandidate {
+	pool := s.healthy
+	if len(s.degraded) > 0 &&
+		(len(pool) == 0 || s.healthyLoad < 100 && randv2.IntN(100) >= s.healthyLoad) {
+		pool = s.degraded
+	}
+
+	i, j := sampleTwo(len(pool))
+	first := s.usable(&pool[i])
+	if j < 0 {
+		return first
+	}
+	second := s.usable(&pool[j])
+	switch {
+	case first == nil:
+		return second
+	case second == nil:
+		return first
+	case loadCount(second.inFlight) < loadCount(first.inFlight):
+		return second
+	default:
+		return first
+	}
+}
+
+func (s *p2cSnapshot) usable(candidate *p2cCandidate) *p2cCandidate {
+	if s.detector != nil && s.detector.IsEjected(candidate.key) {
+		return nil
+	}
+	if candidate.breaker != nil && !candidate.breaker.Available() {
+		return nil
+	}
+	return candidate
+}
+
+// pickSnapshot picks from a least_request_p2c snapshot without the balancer
+// lock, applying the same limits as pickEndpoint. It reports false, before
+// touching any limit, when the sampled endpoints are no longer usable, leaving
+// the pick to the locked path.
+func (p *xdsPicker) pickSnapshot(
+	ri balancer.RPCInfo,
+	snapshot *p2cSnapshot,
+	checkLocal bool,
+	entry *pickLogEntry,
+) (*pickResult, bool, error) {
+	candidate := snapshot.candidate()
+	if candidate == nil {
+		return nil, false, nil
+	}
+
+	circuitBreaker := snapshot.circuitBreaker
+	if checkLocal && snapshot.rateLimiter != nil && !snapshot.rateLimiter.Allow() {
+		entry.decision = pickDecisionRateLimited
+		return nil, true, errRateLimitExceeded
+	}
+	if circuitBreaker != nil && !circuitBreaker.TryAcquire(ResourceRequest) {
+		entry.decision = pickDecisionCircuitOpen
+		return nil, true, errors.New("circuit breaker open: max requests reached")
+	}
+
+	entry.endpoint = candidate.key
+	if candidate.breaker != nil && !candidate.breaker.TryAcquire() {
+		if circuitBreaker != nil {
+			circuitBreaker.Release(ResourceRequest)
+		}
+		entry.decision = pickDecisionEndpointCircuitOpen
+		return nil, true, errors.New("endpoint circuit breaker open: " + candidate.key)
+	}
+
+	if candidate.inFlight != nil {
+		atomic.AddInt32(candidate.inFlight, 1)
+	}
+	entry.decision = pickDecisionPicked
+	return &pickResult{
+		endpoint:        candidate.client,
+		ctx:             ri.Ctx,
+		balancer:        p.balancer,
+		inflightKey:     candidate.key,
+		circuitBreaker:  circuitBreaker,
+		endpointBreaker: candidate.breaker,
+		rateLimiter:     snapshot.rateLimiter,
+		outlierDetector: snapshot.detector,
+	}, true, nil
 }
 
 func endpointAddress(endpoint *weightedEndpoint) string {
@@ -255,6 +826,7 @@ type pickResult struct {
 	balancer        *xdsBalancer
 	inflightKey     string
 	circuitBreaker  *CircuitBreaker
+	endpointBreaker *EndpointCircuitBreaker
 	rateLimiter     *RateLimiter
 	outlierDetector *OutlierDetector
 }
@@ -270,20 +842,49 @@ func (p *pickResult) Report(err error) {
 			slog.Any("error", err),
 		)
 	}
+	p.release(err, true)
+}
+
+// abandon releases a pick whose RPC never reached the endpoint, such as one
+// aborted by fault injection, without recording a result for it.
+func (p *pickResult) abandon() {
+	p.release(nil, false)
+}
+
+func (p *pickResult) release(err error, reached bool) {
+
+	// A retired client is closed after the balancer lock is released, since
+	// closing may report a state change back to the balancer.
+	var retired remote.Client
+	defer func() {
+		if retired != nil {
+			p.balancer.closeRemoteClients([]remote.Client{retired})
+		}
+	}()
 
 	p.balancer.mu.Lock()
 	defer p.balancer.mu.Unlock()
 
 	if p.inflightKey != "" {
 		if value := p.balancer.inFlight[p.inflightKey]; value != nil {
-			if atomic.LoadInt32(value) > 0 {
-				atomic.AddInt32(value, -1)
+			if atomic.LoadInt32(value) > 0 && atomic.AddInt32(value, -1) == 0 {
+				retired = p.balancer.retiring[p.inflightKey]
+				delete(p.balancer.retiring, p.inflightKey)
 			}
 		}
 	}
 	if p.circuitBreaker != nil {
 		p.circuitBreaker.Release(ResourceRequest)
 	}
+	if !reached {
+		if p.endpointBreaker != nil {
+			p.endpointBreaker.Abandon()
+		}
+		return
+	}
+	if p.endpointBreaker != nil {
+		p.endpointBreaker.Release(err)
+	}
 	if p.outlierDetector != nil {
 		statusCode := 200
 		if err != nil {
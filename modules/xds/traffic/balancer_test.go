@@ -17,6 +17,7 @@ package traffic
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"slices"
 	"strings"
@@ -228,6 +229,163 @@ func TestSelectRoundRobin(t *testing.T) {
 	}
 }
 
+func TestSelectRoundRobinSmoothWeights(t *testing.T) {
+	b, _ := newXdsBalancer("test", "", &mockBalancerClient{})
+	xb := b.(*xdsBalancer)
+	endpoints := []*weightedEndpoint{
+		{Cluster: "test", Endpoint: xdsresource.Endpoint{Address: "a", Port: 1}, Weight: 1},
+		{Cluster: "test", Endpoint: xdsresource.Endpoint{Address: "b", Port: 1}, Weight: 2},
+		{Cluster: "test", Endpoint: xdsresource.Endpoint{Address: "c", Port: 1}, Weight: 3},
+	}
+
+	want := []string{"c", "b", "a", "c", "b", "c"}
+	for round := 0; round < 2; round++ {
+		var got []string
+		for range want {
+			got = append(got, xb.selectRoundRobin(endpoints).Endpoint.Address)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("round %d picks = %v, want %v", round, got, want)
+		}
+	}
+
+	// Dropping an endpoint keeps the others rotating by weight.
+	xb.endpoints = map[string][]*weightedEndpoint{"test": endpoints[:2]}
+	xb.pruneRoundRobinLocked()
+	if _, ok := xb.rrWeights["test"]["c:1"]; ok {
+		t.Fatal("removed endpoint kept its round-robin weight")
+	}
+	counts := map[string]int{}
+	for i := 0; i < 3; i++ {
+		counts[xb.selectRoundRobin(endpoints[:2]).Endpoint.Address]++
+	}
+	if counts["a"] != 1 || counts["b"] != 2 {
+		t.Fatalf("picks after removal = %v, want a:1 b:2", counts)
+	}
+}
+
+func TestSelectLeastRequestP2C(t *testing.T) {
+	b, _ := newXdsBalancer("test", "", &mockBalancerClient{})
+	xb := b.(*xdsBalancer)
+	endpoints, inFlight := leastRequestEndpoints(xb, 5)
+	for i, load := range []int32{0, 1, 2, 3, 100} {
+		*inFlight[i] = load
+	}
+
+	if got := xb.selectLeastRequestP2C(nil); got != nil {
+		t.Fatalf("selectLeastRequestP2C(nil) = %#v, want nil", got)
+	}
+	if got := xb.selectLeastRequestP2C(endpoints[4:]); got != endpoints[4] {
+		t.Fatalf("selectLeastRequestP2C(one) = %#v, want the only endpoint", got)
+	}
+
+	// Each pick compares two distinct endpoints, so the most loaded one is
+	// never chosen and the least loaded one wins every pair it is in (2/5).
+	const picks = 10000
+	counts := make(map[*weightedEndpoint]int)
+	for i := 0; i < picks; i++ {
+		counts[xb.selectLeastRequestP2C(endpoints)]++
+	}
+	if counts[endpoints[4]] != 0 {
+		t.Fatalf("most loaded endpoint picked %d times, want 0", counts[endpoints[4]])
+	}
+	if least := counts[endpoints[0]]; least < picks*35/100 || least > picks*45/100 {
+		t.Fatalf("least loaded endpoint picked %d of %d times, want about 40%%", least, picks)
+	}
+	for i := 1; i < 4; i++ {
+		if counts[endpoints[i]] >= counts[endpoints[i-1]] {
+			t.Fatalf("picks = %v, want fewer picks for more loaded endpoints", counts)
+		}
+	}
+}
+
+func TestP2CSnapshotPicksWithoutBalancerLock(t *testing.T) {
+	b, _ := newXdsBalancer("test", "", &mockBalancerClient{})
+	xb := b.(*xdsBalancer)
+	endpoints, inFlight := leastRequestEndpoints(xb, 5)
+	for i, load := range []int32{0, 1, 2, 3, 100} {
+		*inFlight[i] = load
+	}
+	xb.endpoints = map[string][]*weightedEndpoint{"test": endpoints}
+	xb.clusterPolicies["test"] = clusterPolicy{LBPolicy: "least_request_p2c"}
+	xb.remotesClient = make(map[string]remote.Client)
+	for _, endpoint := range endpoints {
+		xb.remotesClient[endpointAddress(endpoint)] = &mockClient{}
+	}
+
+	snapshot := xb.buildPicker().p2c["test"]
+	if snapshot == nil || len(snapshot.healthy) != len(endpoints) {
+		t.Fatalf("p2c snapshot = %#v, want all endpoints healthy", snapshot)
+	}
+
+	// Picks read the snapshot and atomic counters only, so a writer holding
+	// the balancer lock does not block them.
+	xb.mu.Lock()
+	defer xb.mu.Unlock()
+	const picks = 10000
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		counts[snapshot.candidate().key]++
+	}
+	if most := counts[endpointAddress(endpoints[4])]; most != 0 {
+		t.Fatalf("most loaded endpoint picked %d times, want 0", most)
+	}
+	least := counts[endpointAddress(endpoints[0])]
+	if least < picks*35/100 || least > picks*45/100 {
+		t.Fatalf("least loaded endpoint picked %d of %d times, want about 40%%", least, picks)
+	}
+
+	*inFlight[4] = 0
+	if got := loadCount(snapshot.healthy[4].inFlight); got != 0 {
+		t.Fatalf("snapshot in-flight = %d, want the live counter", got)
+	}
+}
+
+func BenchmarkSelectLeastRequest(b *testing.B) {
+	balancerAny, _ := newXdsBalancer("bench", "", &mockBalancerClient{})
+	xb := balancerAny.(*xdsBalancer)
+	endpoints, inFlight := leastRequestEndpoints(xb, 64)
+	for i, value := range inFlight {
+		*value = int32(i % 7)
+	}
+
+	for _, bench := range []struct {
+		name string
+		pick func([]*weightedEndpoint) *weightedEndpoint
+	}{
+		{"scan", xb.selectLeastRequest},
+		{"p2c", xb.selectLeastRequestP2C},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					xb.mu.RLock()
+					_ = bench.pick(endpoints)
+					xb.mu.RUnlock()
+				}
+			})
+		})
+	}
+}
+
+func leastRequestEndpoints(xb *xdsBalancer, n int) ([]*weightedEndpoint, []*int32) {
+	endpoints := make([]*weightedEndpoint, 0, n)
+	inFlight := make([]*int32, 0, n)
+	for i := 0; i < n; i++ {
+		endpoint := &weightedEndpoint{
+			Cluster:  "test",
+			Endpoint: xdsresource.Endpoint{Address: "10.0.0.1", Port: 8000 + i},
+			Weight:   1,
+		}
+		value := new(int32)
+		xb.inFlight[endpointAddress(endpoint)] = value
+		endpoints = append(endpoints, endpoint)
+		inFlight = append(inFlight, value)
+	}
+	return endpoints, inFlight
+}
+
 func TestSelectRandom(t *testing.T) {
 	cli := &mockBalancerClient{}
 	b, _ := newXdsBalancer("test", "", cli)
@@ -267,6 +425,138 @@ func TestSelectLeastRequest(t *testing.T) {
 	}
 }
 
+func TestSelectEndpointUsesDegradedAsOverflow(t *testing.T) {
+	newEndpoint := func(address, health string) *weightedEndpoint {
+		return &weightedEndpoint{
+			Cluster:  "cluster-a",
+			Endpoint: xdsresource.Endpoint{Address: address, Port: 8080},
+			Weight:   1,
+			Metadata: map[string]string{"health": health},
+		}
+	}
+	countDegraded := func(instance *xdsBalancer, detector *OutlierDetector) int {
+		degraded := 0
+		for i := 0; i < 1000; i++ {
+			got := instance.selectEndpoint("cluster-a", detector, nil)
+			if got == nil {
+				t.Fatal("selectEndpoint() = nil, want an endpoint")
+			}
+			if got.Metadata["health"] == "DEGRADED" {
+				degraded++
+			}
+		}
+		return degraded
+	}
+
+	instance := newDeterministicBalancer(t, &recordingBalancerClient{})
+	instance.endpoints["cluster-a"] = []*weightedEndpoint{
+		newEndpoint("10.0.0.1", "HEALTHY"),
+		newEndpoint("10.0.0.2", "HEALTHY"),
+		newEndpoint("10.0.0.3", "HEALTHY"),
+		newEndpoint("10.0.0.4", "DEGRADED"),
+	}
+	if got := countDegraded(instance, nil); got != 0 {
+		t.Fatalf("degraded picks = %d, want 0 while healthy endpoints absorb the load", got)
+	}
+
+	detector := NewOutlierDetector(&OutlierDetectionConfig{
+		Consecutive5xx:          1,
+		BaseEjectionTime:        time.Minute,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      100,
+		EnforcingConsecutive5xx: 100,
+	})
+	detector.ReportResult("10.0.0.1:8080", errors.New("unavailable"), 503)
+	detector.ReportResult("10.0.0.2:8080", errors.New("unavailable"), 503)
+
+	// One of four endpoints is healthy: it absorbs 35% and the degraded
+	// endpoint takes the 65% overflow.
+	got := countDegraded(instance, detector)
+	if got < 550 || got > 750 {
+		t.Fatalf("degraded picks = %d of 1000, want about 650 overflow picks", got)
+	}
+
+	instance.endpoints["cluster-a"] = []*weightedEndpoint{
+		newEndpoint("10.0.0.4", "DEGRADED"),
+	}
+	if got := countDegraded(instance, nil); got != 1000 {
+		t.Fatalf("degraded picks = %d, want 1000 when no healthy endpoint remains", got)
+	}
+}
+
+func TestEndpointCircuitBreakerIsolatesOneEndpoint(t *testing.T) {
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+
+	endpoint := func(address string) resolver.BaseEndpoint {
+		return resolver.BaseEndpoint{
+			Address:  address,
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+			},
+		}
+	}
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{endpoint("10.0.0.1:8080"), endpoint("10.0.0.2:8080")},
+		testRoute("cluster-a", nil),
+		map[string]clusterPolicy{
+			"cluster-a": {
+				LBPolicy:       "round_robin",
+				CircuitBreaker: &CircuitBreakerConfig{MaxRequests: 10},
+				EndpointCircuitBreaker: &EndpointCircuitBreakerConfig{
+					FailureRateThreshold: 50,
+					RequestVolume:        2,
+					Interval:             time.Minute,
+					OpenDuration:         time.Hour,
+				},
+			},
+		},
+	))
+	defer instance.Close() //nolint:errcheck
+
+	picker := instance.buildPicker()
+	pick := func() string {
+		t.Helper()
+		result, err := picker.Next(balancer.RPCInfo{
+			Ctx:    context.Background(),
+			Method: "/svc/Method",
+		})
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		client := result.RemoteClient().(*recordingRemoteClient)
+		address := fmt.Sprintf("%s:%d", client.address, client.port)
+		if address == "10.0.0.1:8080" {
+			result.Report(errors.New("unavailable"))
+		} else {
+			result.Report(nil)
+		}
+		return address
+	}
+
+	for i := 0; i < 50; i++ {
+		pick()
+	}
+	for i := 0; i < 20; i++ {
+		if got := pick(); got != "10.0.0.2:8080" {
+			t.Fatalf("pick %d after trip = %q, want 10.0.0.2:8080", i, got)
+		}
+	}
+
+	stats := instance.GetStats()
+	if got := stats.EndpointCircuitBreakers["10.0.0.1:8080"].State; got != EndpointCircuitOpen {
+		t.Fatalf("failing endpoint breaker state = %v, want OPEN", got)
+	}
+	if got := stats.EndpointCircuitBreakers["10.0.0.2:8080"].State; got != EndpointCircuitClosed {
+		t.Fatalf("peer endpoint breaker state = %v, want CLOSED", got)
+	}
+	if cluster := stats.CircuitBreakers["cluster-a"]; cluster.ActiveRequests != 0 ||
+		cluster.RejectedRequests != 0 {
+		t.Fatalf("cluster breaker stats = %#v, want closed and idle", cluster)
+	}
+}
+
 func TestLeastRequest_Report_Bug(t *testing.T) {
 	cli := &mockBalancerClient{}
 	b, _ := newXdsBalancer("test", "", cli)
@@ -497,9 +787,35 @@ func TestBalancerProviderAndConfig(t *testing.T) {
 		t.Fatalf("provider.Type() = %q, want xds", provider.Type())
 	}
 
-	cfg := LoadBalancerConfig("svc")
-	if got := (&cfg).String(); got != "{}" {
-		t.Fatalf("BalancerConfig.String() = %q, want {}", got)
+	cfg, err := LoadBalancerConfig("svc", "xds")
+	if err != nil {
+		t.Fatalf("LoadBalancerConfig() error = %v", err)
+	}
+	want := "{PickLog:{Enabled:false MaxPerSecond:10} FailFastEmptyEDS:false " +
+		"StatsMetrics:{Enabled:false} RateLimitService:{Address: Timeout:0s}}"
+	if got := (&cfg).String(); got != want {
+		t.Fatalf("BalancerConfig.String() = %q, want %q", got, want)
+	}
+
+	cfg, err = DecodeBalancerConfig(map[string]any{
+		"pick_log": map[string]any{"enabled": true, "max_per_second": 3},
+	})
+	if err != nil || !cfg.PickLog.Enabled || cfg.PickLog.MaxPerSecond != 3 {
+		t.Fatalf("DecodeBalancerConfig() = %+v, want enabled pick log at 3/s", cfg)
+	}
+
+	cfg, err = DecodeBalancerConfig(map[string]any{
+		"rate_limit_service": map[string]any{"address": "rls:8081", "timeout": "250ms"},
+	})
+	if err != nil || cfg.RateLimitService.Address != "rls:8081" ||
+		cfg.RateLimitService.Timeout != 250*time.Millisecond {
+		t.Fatalf("DecodeBalancerConfig() = %+v, want rls:8081 with a 250ms timeout", cfg)
+	}
+
+	if _, err := DecodeBalancerConfig(map[string]any{
+		"rate_limit_service": map[string]any{"timeout": "soon"},
+	}); err == nil {
+		t.Fatal("DecodeBalancerConfig() should reject an invalid timeout")
 	}
 
 	instance, err := provider.New("svc", "xds", &recordingBalancerClient{})
@@ -511,6 +827,114 @@ func TestBalancerProviderAndConfig(t *testing.T) {
 	}
 }
 
+func TestBalancerProviderBindsOutlierHooks(t *testing.T) {
+	var ejected []string
+	provider := BalancerProvider(WithOutlierHooks(OutlierHooks{
+		OnEject: func(cluster, endpoint, reason string) {
+			ejected = append(ejected, cluster+"/"+endpoint+"/"+reason)
+		},
+	}))
+	instance, err := provider.New("svc", "xds", &recordingBalancerClient{})
+	if err != nil {
+		t.Fatalf("provider.New() error = %v", err)
+	}
+	defer instance.Close() //nolint:errcheck
+
+	instance.UpdateState(resolver.BaseState{
+		Endpoints: []resolver.Endpoint{resolver.BaseEndpoint{
+			Address:    "10.0.0.1:8080",
+			Protocol:   "grpc",
+			Attributes: map[string]any{xdsresource.AttributeEndpointCluster: "cluster-a"},
+		}},
+		Attributes: map[string]any{
+			xdsresource.AttributeClusters: map[string]clusterPolicy{
+				"cluster-a": {OutlierDetection: &OutlierDetectionConfig{
+					Consecutive5xx:          1,
+					BaseEjectionTime:        time.Minute,
+					MaxEjectionTime:         time.Minute,
+					MaxEjectionPercent:      100,
+					EnforcingConsecutive5xx: 100,
+				}},
+			},
+		},
+	})
+
+	b := instance.(*xdsBalancer)
+	b.mu.RLock()
+	detector := b.outlierDetectors["cluster-a"]
+	b.mu.RUnlock()
+	if detector == nil {
+		t.Fatal("cluster-a has no outlier detector")
+	}
+	detector.ReportResult("10.0.0.1:8080", nil, 503)
+	if want := []string{"cluster-a/10.0.0.1:8080/consecutive_5xx"}; !slices.Equal(ejected, want) {
+		t.Fatalf("OnEject events = %v, want %v", ejected, want)
+	}
+}
+
+func TestBalancerKeepsGovernanceStateForUnchangedPolicy(t *testing.T) {
+	instance := newDeterministicBalancer(t, &recordingBalancerClient{})
+	defer instance.Close() //nolint:errcheck
+
+	endpoints := []resolver.Endpoint{resolver.BaseEndpoint{
+		Address:    "10.0.0.1:8080",
+		Protocol:   "grpc",
+		Attributes: map[string]any{xdsresource.AttributeEndpointCluster: "cluster-a"},
+	}}
+	policies := func(maxTokens uint32) map[string]clusterPolicy {
+		return map[string]clusterPolicy{"cluster-a": {
+			CircuitBreaker: &CircuitBreakerConfig{MaxRequests: 10},
+			OutlierDetection: &OutlierDetectionConfig{
+				Consecutive5xx:          1,
+				BaseEjectionTime:        time.Minute,
+				MaxEjectionTime:         time.Minute,
+				MaxEjectionPercent:      100,
+				EnforcingConsecutive5xx: 100,
+			},
+			RateLimiter: &RateLimiterConfig{
+				MaxTokens:     maxTokens,
+				TokensPerFill: 1,
+				FillInterval:  time.Hour,
+			},
+		}}
+	}
+	governance := func() (*CircuitBreaker, *OutlierDetector, *RateLimiter) {
+		instance.mu.RLock()
+		defer instance.mu.RUnlock()
+		return instance.circuitBreakers["cluster-a"],
+			instance.outlierDetectors["cluster-a"],
+			instance.rateLimiters["cluster-a"]
+	}
+
+	instance.UpdateState(testState(endpoints, testRoute("cluster-a", nil), policies(2)))
+	breaker, detector, limiter := governance()
+	detector.ReportResult("10.0.0.1:8080", nil, 503)
+	if !limiter.Allow() {
+		t.Fatal("Allow() = false, want the first token")
+	}
+
+	instance.UpdateState(testState(endpoints, testRoute("cluster-a", nil), policies(2)))
+	nextBreaker, nextDetector, nextLimiter := governance()
+	if nextBreaker != breaker || nextDetector != detector || nextLimiter != limiter {
+		t.Fatal("unchanged policy rebuilt the circuit breaker, outlier detector or rate limiter")
+	}
+	if !nextDetector.IsEjected("10.0.0.1:8080") {
+		t.Fatal("endpoint ejection lost across an unchanged policy update")
+	}
+	if !nextLimiter.Allow() || nextLimiter.Allow() {
+		t.Fatal("token bucket reset across an unchanged policy update")
+	}
+
+	instance.UpdateState(testState(endpoints, testRoute("cluster-a", nil), policies(5)))
+	nextBreaker, nextDetector, nextLimiter = governance()
+	if nextBreaker != breaker || nextDetector != detector {
+		t.Fatal("rate limiter change rebuilt the circuit breaker or outlier detector")
+	}
+	if nextLimiter == limiter {
+		t.Fatal("rate limiter kept after its policy changed")
+	}
+}
+
 func TestBalancerLifecycleAndStats(t *testing.T) {
 	cli := &recordingBalancerClient{
 		newErr: map[string]error{"bad:80": errors.New("new remote client failed")},
@@ -692,6 +1116,106 @@ func TestPickerBehaviors(t *testing.T) {
 		}
 	})
 
+	t.Run("weighted cluster skips fully ejected cluster", func(t *testing.T) {
+		instance := newDeterministicBalancer(t, &recordingBalancerClient{})
+		instance.remotesClient = map[string]remote.Client{
+			"10.0.1.1:8080": &recordingRemoteClient{
+				address: "10.0.1.1",
+				port:    8080,
+				state:   remote.Ready,
+			},
+			"10.0.2.1:8080": &recordingRemoteClient{
+				address: "10.0.2.1",
+				port:    8080,
+				state:   remote.Ready,
+			},
+		}
+		instance.vhosts = testRoute("", &xdsresource.WeightedClusters{
+			Clusters: []*xdsresource.WeightedCluster{
+				{Name: "v1", Weight: 50},
+				{Name: "v2", Weight: 50},
+			},
+			TotalWeight: 100,
+		})
+		instance.endpoints["v1"] = []*weightedEndpoint{{
+			Cluster:  "v1",
+			Endpoint: xdsresource.Endpoint{Address: "10.0.1.1", Port: 8080},
+			Weight:   1,
+		}}
+		instance.endpoints["v2"] = []*weightedEndpoint{{
+			Cluster:  "v2",
+			Endpoint: xdsresource.Endpoint{Address: "10.0.2.1", Port: 8080},
+			Weight:   1,
+		}}
+		detector := NewOutlierDetector(&OutlierDetectionConfig{
+			Consecutive5xx:          1,
+			BaseEjectionTime:        time.Minute,
+			MaxEjectionTime:         time.Minute,
+			MaxEjectionPercent:      100,
+			EnforcingConsecutive5xx: 100,
+		})
+		detector.ReportResult("10.0.1.1:8080", errors.New("unavailable"), 503)
+		if !detector.IsEjected("10.0.1.1:8080") {
+			t.Fatal("v1 endpoint was not ejected")
+		}
+		instance.outlierDetectors["v1"] = detector
+
+		picker := instance.buildPicker()
+		for i := 0; i < 100; i++ {
+			result, err := picker.Next(balancer.RPCInfo{
+				Ctx:    context.Background(),
+				Method: "/svc/Method",
+			})
+			if err != nil {
+				t.Fatalf("Next() #%d error = %v, want pick from v2", i, err)
+			}
+			client, _ := result.RemoteClient().(*recordingRemoteClient)
+			if client == nil || client.Address() != "10.0.2.1" {
+				t.Fatalf("Next() #%d picked %#v, want v2 endpoint 10.0.2.1", i, client)
+			}
+			result.Report(nil)
+		}
+	})
+
+	t.Run("weighted cluster skips cluster without endpoints", func(t *testing.T) {
+		instance := newDeterministicBalancer(t, &recordingBalancerClient{})
+		instance.remotesClient = map[string]remote.Client{
+			"10.0.2.1:8080": &recordingRemoteClient{
+				address: "10.0.2.1",
+				port:    8080,
+				state:   remote.Ready,
+			},
+		}
+		instance.vhosts = testRoute("", &xdsresource.WeightedClusters{
+			Clusters: []*xdsresource.WeightedCluster{
+				{Name: "v1", Weight: 90},
+				{Name: "v2", Weight: 10},
+			},
+			TotalWeight: 100,
+		})
+		instance.endpoints["v2"] = []*weightedEndpoint{{
+			Cluster:  "v2",
+			Endpoint: xdsresource.Endpoint{Address: "10.0.2.1", Port: 8080},
+			Weight:   1,
+		}}
+
+		picker := instance.buildPicker()
+		for i := 0; i < 100; i++ {
+			result, err := picker.Next(balancer.RPCInfo{
+				Ctx:    context.Background(),
+				Method: "/svc/Method",
+			})
+			if err != nil {
+				t.Fatalf("Next() #%d error = %v, want pick from v2", i, err)
+			}
+			client, _ := result.RemoteClient().(*recordingRemoteClient)
+			if client == nil || client.Address() != "10.0.2.1" {
+				t.Fatalf("Next() #%d picked %#v, want v2 endpoint 10.0.2.1", i, client)
+			}
+			result.Report(nil)
+		}
+	})
+
 	t.Run("no route", func(t *testing.T) {
 		instance := newDeterministicBalancer(t, &recordingBalancerClient{})
 		picker := instance.buildPicker()
@@ -857,13 +1381,13 @@ func TestPickerBehaviors(t *testing.T) {
 		instance.inFlight[endpointAddress(endpoints[0])] = new(int32)
 		one := int32(1)
 		instance.inFlight[endpointAddress(endpoints[1])] = &one
-		if got := instance.selectEndpoint("cluster-a", nil); got != nil {
+		if got := instance.selectEndpoint("cluster-a", nil, nil); got != nil {
 			t.Fatalf("selectEndpoint(missing cluster map) = %#v, want nil", got)
 		}
 
 		instance.endpoints["cluster-a"] = endpoints
 		instance.clusterPolicies["cluster-a"] = clusterPolicy{LBPolicy: "least_request"}
-		if got := instance.selectEndpoint("cluster-a", nil); got != endpoints[0] {
+		if got := instance.selectEndpoint("cluster-a", nil, nil); got != endpoints[0] {
 			t.Fatalf("selectEndpoint(least_request) = %#v, want first endpoint", got)
 		}
 
@@ -881,15 +1405,327 @@ func TestPickerBehaviors(t *testing.T) {
 		}
 
 		instance.clusterPolicies["cluster-a"] = clusterPolicy{LBPolicy: "random"}
-		got := instance.selectEndpoint("cluster-a", detector)
+		got := instance.selectEndpoint("cluster-a", detector, nil)
 		if got != endpoints[1] {
 			t.Fatalf("selectEndpoint(random healthy) = %#v, want second endpoint", got)
 		}
 
 		instance.clusterPolicies = map[string]clusterPolicy{}
-		got = instance.selectEndpoint("cluster-a", nil)
+		got = instance.selectEndpoint("cluster-a", nil, nil)
 		if !slices.Contains(endpoints, got) {
 			t.Fatalf("selectEndpoint(default round_robin) = %#v, want one of %#v", got, endpoints)
 		}
 	})
 }
+
+func TestPickerDistinguishesPendingAndEmptyEDS(t *testing.T) {
+	newBalancer := func(t *testing.T, failFast bool) *xdsBalancer {
+		instance := newDeterministicBalancer(t, &recordingBalancerClient{})
+		instance.failFastEmptyEDS = failFast
+		return instance
+	}
+	stable := resolver.BaseEndpoint{
+		Address:    "10.0.0.1:8080",
+		Protocol:   "grpc",
+		Attributes: map[string]any{xdsresource.AttributeEndpointCluster: "stable"},
+	}
+	stateWith := func(
+		endpoints []resolver.Endpoint,
+		vhosts []*xdsresource.VirtualHost,
+		received map[string]bool,
+	) resolver.State {
+		return resolver.BaseState{
+			Endpoints: endpoints,
+			Attributes: map[string]any{
+				xdsresource.AttributeRoutes:      vhosts,
+				xdsresource.AttributeClusters:    map[string]clusterPolicy{},
+				xdsresource.AttributeEDSReceived: received,
+			},
+		}
+	}
+	pick := func(instance *xdsBalancer) (balancer.PickResult, error) {
+		return instance.buildPicker().Next(balancer.RPCInfo{
+			Ctx:    context.Background(),
+			Method: "/svc/Method",
+		})
+	}
+
+	t.Run("pending EDS waits", func(t *testing.T) {
+		instance := newBalancer(t, true)
+		instance.UpdateState(stateWith(
+			[]resolver.Endpoint{},
+			testRoute("canary", nil),
+			map[string]bool{"canary": false},
+		))
+		if _, err := pick(instance); !errors.Is(err, balancer.ErrNoAvailableInstance) {
+			t.Fatalf("Next() error = %v, want ErrNoAvailableInstance to wait", err)
+		}
+	})
+
+	t.Run("empty EDS fails fast", func(t *testing.T) {
+		instance := newBalancer(t, true)
+		instance.UpdateState(stateWith(
+			[]resolver.Endpoint{},
+			testRoute("canary", nil),
+			map[string]bool{"canary": true},
+		))
+		_, err := pick(instance)
+		if !errors.Is(err, errClusterEmpty) || errors.Is(err, balancer.ErrNoAvailableInstance) {
+			t.Fatalf("Next() error = %v, want errClusterEmpty", err)
+		}
+	})
+
+	t.Run("empty EDS waits without flag", func(t *testing.T) {
+		instance := newBalancer(t, false)
+		instance.UpdateState(stateWith(
+			[]resolver.Endpoint{},
+			testRoute("canary", nil),
+			map[string]bool{"canary": true},
+		))
+		if _, err := pick(instance); !errors.Is(err, balancer.ErrNoAvailableInstance) {
+			t.Fatalf("Next() error = %v, want ErrNoAvailableInstance", err)
+		}
+	})
+
+	weighted := &xdsresource.WeightedClusters{
+		Clusters: []*xdsresource.WeightedCluster{
+			{Name: "stable", Weight: 1},
+			{Name: "canary", Weight: 99},
+		},
+		TotalWeight: 100,
+	}
+
+	t.Run("weighted selection fails over from empty cluster", func(t *testing.T) {
+		instance := newBalancer(t, true)
+		instance.UpdateState(stateWith(
+			[]resolver.Endpoint{stable},
+			testRoute("", weighted),
+			map[string]bool{"stable": true, "canary": true},
+		))
+		for i := 0; i < 20; i++ {
+			result, err := pick(instance)
+			if err != nil {
+				t.Fatalf("Next() #%d error = %v, want pick from stable", i, err)
+			}
+			result.Report(nil)
+		}
+	})
+
+	t.Run("weighted selection waits for pending cluster", func(t *testing.T) {
+		instance := newBalancer(t, true)
+		instance.UpdateState(stateWith(
+			[]resolver.Endpoint{stable},
+			testRoute("", weighted),
+			map[string]bool{"stable": true, "canary": false},
+		))
+		waited := 0
+		for i := 0; i < 20; i++ {
+			result, err := pick(instance)
+			if errors.Is(err, balancer.ErrNoAvailableInstance) {
+				waited++
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Next() #%d error = %v", i, err)
+			}
+			result.Report(nil)
+		}
+		if waited == 0 {
+			t.Fatal("no pick waited on the pending canary cluster")
+		}
+	})
+}
+
+func TestPickPrefersReadyEndpoints(t *testing.T) {
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+	defer instance.Close() //nolint:errcheck
+
+	endpoint := func(address string) resolver.BaseEndpoint {
+		return resolver.BaseEndpoint{
+			Address:  address,
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+			},
+		}
+	}
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{endpoint("10.0.0.1:8080"), endpoint("10.0.0.2:8080")},
+		testRoute("cluster-a", nil),
+		map[string]clusterPolicy{"cluster-a": {LBPolicy: "round_robin"}},
+	))
+	cli.clients["10.0.0.1:8080"].state = remote.Connecting
+
+	for i := 0; i < 20; i++ {
+		result, err := instance.buildPicker().Next(balancer.RPCInfo{
+			Ctx:    context.Background(),
+			Method: "/svc/Method",
+		})
+		if err != nil {
+			t.Fatalf("pick %d error = %v", i, err)
+		}
+		if got := result.RemoteClient().(*recordingRemoteClient).address; got != "10.0.0.2" {
+			t.Fatalf("pick %d = %s, want the Ready endpoint 10.0.0.2", i, got)
+		}
+		result.Report(nil)
+	}
+
+	// With no Ready endpoint the pick waits for a connection to come up.
+	cli.clients["10.0.0.2:8080"].state = remote.Connecting
+	_, err := instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if !errors.Is(err, balancer.ErrNoAvailableInstance) {
+		t.Fatalf("pick with no Ready endpoint error = %v, want ErrNoAvailableInstance", err)
+	}
+}
+
+func TestPickRoutesToMetadataSubset(t *testing.T) {
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+	defer instance.Close() //nolint:errcheck
+
+	endpoint := func(address, version string) resolver.BaseEndpoint {
+		return resolver.BaseEndpoint{
+			Address:  address,
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster:  "cluster-a",
+				xdsresource.AttributeEndpointMetadata: map[string]string{"version": version},
+			},
+		}
+	}
+	endpoints := []resolver.Endpoint{
+		endpoint("10.0.0.1:8080", "v1"),
+		endpoint("10.0.0.2:8080", "v2"),
+		endpoint("10.0.0.3:8080", "v2"),
+	}
+	update := func(version string, subset *xdsresource.SubsetConfig) {
+		vhosts := testRoute("cluster-a", nil)
+		vhosts[0].Routes[0].Action.MetadataMatch = map[string]string{"version": version}
+		instance.UpdateState(testState(endpoints, vhosts, map[string]clusterPolicy{
+			"cluster-a": {LBPolicy: "round_robin", Subset: subset},
+		}))
+	}
+	pick := func() (string, error) {
+		result, err := instance.buildPicker().Next(balancer.RPCInfo{
+			Ctx:    context.Background(),
+			Method: "/svc/Method",
+		})
+		if err != nil {
+			return "", err
+		}
+		result.Report(nil)
+		return result.RemoteClient().(*recordingRemoteClient).address, nil
+	}
+
+	update("v2", nil)
+	seen := map[string]int{}
+	for i := 0; i < 20; i++ {
+		got, err := pick()
+		if err != nil {
+			t.Fatalf("pick %d error = %v", i, err)
+		}
+		seen[got]++
+	}
+	if seen["10.0.0.1"] != 0 || seen["10.0.0.2"] == 0 || seen["10.0.0.3"] == 0 {
+		t.Fatalf("picks = %v, want only the version=v2 endpoints", seen)
+	}
+
+	// An empty subset picks nothing by default.
+	update("v3", nil)
+	if _, err := pick(); !errors.Is(err, balancer.ErrNoAvailableInstance) {
+		t.Fatalf("pick with empty subset error = %v, want ErrNoAvailableInstance", err)
+	}
+
+	update("v3", &xdsresource.SubsetConfig{
+		FallbackPolicy: xdsresource.SubsetDefaultSubset,
+		DefaultSubset:  map[string]string{"version": "v1"},
+	})
+	for i := 0; i < 5; i++ {
+		if got, err := pick(); err != nil || got != "10.0.0.1" {
+			t.Fatalf("pick with default subset = %s, %v, want 10.0.0.1", got, err)
+		}
+	}
+
+	update("v3", &xdsresource.SubsetConfig{FallbackPolicy: xdsresource.SubsetAnyEndpoint})
+	if _, err := pick(); err != nil {
+		t.Fatalf("pick with any_endpoint fallback error = %v", err)
+	}
+}
+
+func TestDrainingEndpointStopsNewPicksAndClosesAfterInFlight(t *testing.T) {
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+	defer instance.Close() //nolint:errcheck
+
+	endpoint := func(address, health string) resolver.BaseEndpoint {
+		return resolver.BaseEndpoint{
+			Address:  address,
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster:  "cluster-a",
+				xdsresource.AttributeEndpointMetadata: map[string]string{"health": health},
+			},
+		}
+	}
+	update := func(endpoints ...resolver.Endpoint) {
+		instance.UpdateState(testState(
+			endpoints,
+			testRoute("cluster-a", nil),
+			map[string]clusterPolicy{"cluster-a": {LBPolicy: "round_robin"}},
+		))
+	}
+	pick := func() (balancer.PickResult, string) {
+		t.Helper()
+		result, err := instance.buildPicker().Next(balancer.RPCInfo{
+			Ctx:    context.Background(),
+			Method: "/svc/Method",
+		})
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		client := result.RemoteClient().(*recordingRemoteClient)
+		return result, fmt.Sprintf("%s:%d", client.address, client.port)
+	}
+
+	update(endpoint("10.0.0.1:8080", "HEALTHY"), endpoint("10.0.0.2:8080", "HEALTHY"))
+	var inFlight balancer.PickResult
+	for inFlight == nil {
+		if result, address := pick(); address == "10.0.0.1:8080" {
+			inFlight = result
+		} else {
+			result.Report(nil)
+		}
+	}
+
+	update(endpoint("10.0.0.1:8080", "DRAINING"), endpoint("10.0.0.2:8080", "HEALTHY"))
+	for _, ep := range instance.endpoints["cluster-a"] {
+		if want := ep.Endpoint.Address == "10.0.0.1"; ep.Draining != want {
+			t.Fatalf("%s Draining = %v, want %v", endpointAddress(ep), ep.Draining, want)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		result, address := pick()
+		if address != "10.0.0.2:8080" {
+			t.Fatalf("pick %d after DRAINING = %s, want 10.0.0.2:8080", i, address)
+		}
+		result.Report(nil)
+	}
+
+	draining := cli.clients["10.0.0.1:8080"]
+	update(endpoint("10.0.0.2:8080", "HEALTHY"))
+	if draining.closeCount != 0 {
+		t.Fatal("removed endpoint was closed with an RPC still in flight")
+	}
+	inFlight.Report(nil)
+	if draining.closeCount != 1 {
+		t.Fatalf("removed endpoint close count = %d, want 1 after its RPC completed",
+			draining.closeCount)
+	}
+	if _, ok := instance.retiring["10.0.0.1:8080"]; ok {
+		t.Fatal("removed endpoint still retiring after its RPC completed")
+	}
+}
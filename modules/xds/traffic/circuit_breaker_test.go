@@ -14,7 +14,11 @@
 
 package traffic
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestCircuitBreakerDefaultsAndUnlimited(t *testing.T) {
 	defaults := NewCircuitBreaker(nil)
@@ -84,3 +88,66 @@ func TestCircuitBreakerLimitsAndStats(t *testing.T) {
 		t.Fatalf("RejectedRetries = %d, want 1", stats.RejectedRetries)
 	}
 }
+
+var errEndpointFailure = errors.New("endpoint failure")
+
+func TestEndpointCircuitBreakerTripsAndRecovers(t *testing.T) {
+	cb := NewEndpointCircuitBreaker(&EndpointCircuitBreakerConfig{
+		FailureRateThreshold: 50,
+		RequestVolume:        4,
+		Interval:             time.Minute,
+		OpenDuration:         time.Hour,
+	})
+
+	for _, err := range []error{nil, errEndpointFailure, nil, errEndpointFailure} {
+		if !cb.TryAcquire() {
+			t.Fatal("TryAcquire() = false while closed")
+		}
+		cb.Release(err)
+	}
+	if stats := cb.GetStats(); stats.State != EndpointCircuitOpen {
+		t.Fatalf("state after 50%% failures = %v, want OPEN", stats.State)
+	}
+	if cb.Available() || cb.TryAcquire() {
+		t.Fatal("open breaker admitted a request")
+	}
+
+	cb.mu.Lock()
+	cb.openUntil = time.Now().Add(-time.Millisecond)
+	cb.mu.Unlock()
+	if !cb.Available() || !cb.TryAcquire() {
+		t.Fatal("breaker did not admit a half-open probe")
+	}
+	if cb.TryAcquire() {
+		t.Fatal("half-open breaker admitted a second probe")
+	}
+	cb.Release(errEndpointFailure)
+	if stats := cb.GetStats(); stats.State != EndpointCircuitOpen || stats.RejectedRequests != 2 {
+		t.Fatalf("stats after failed probe = %#v", stats)
+	}
+
+	cb.mu.Lock()
+	cb.openUntil = time.Now().Add(-time.Millisecond)
+	cb.mu.Unlock()
+	if !cb.TryAcquire() {
+		t.Fatal("breaker did not admit a second probe")
+	}
+	cb.Release(nil)
+	if stats := cb.GetStats(); stats.State != EndpointCircuitClosed {
+		t.Fatalf("state after successful probe = %v, want CLOSED", stats.State)
+	}
+}
+
+func TestEndpointCircuitBreakerDefaults(t *testing.T) {
+	cb := NewEndpointCircuitBreaker(&EndpointCircuitBreakerConfig{RequestVolume: 3})
+	defaults := DefaultEndpointCircuitBreakerConfig()
+	if cb.config.RequestVolume != 3 ||
+		cb.config.FailureRateThreshold != defaults.FailureRateThreshold ||
+		cb.config.Interval != defaults.Interval ||
+		cb.config.OpenDuration != defaults.OpenDuration {
+		t.Fatalf("config = %#v", cb.config)
+	}
+	if got := EndpointCircuitHalfOpen.String(); got != "HALF_OPEN" {
+		t.Fatalf("String() = %q, want HALF_OPEN", got)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	period time.Duration
+	next   time.Time
+	ch     chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, period: d, next: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// tickerCount reports how many tickers are running.
+func (c *fakeClock) tickerCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers)
+}
+
+// Advance moves the clock forward by d and fires due tickers and timers.
+// Like time.Ticker, a ticker whose receiver lags drops ticks.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		fired := false
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+			fired = true
+		}
+		if fired {
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+		}
+	}
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, candidate := range t.clock.tickers {
+		if candidate == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// waitFor yields until cond holds; fake-clock ticks are handled by background
+// goroutines, so their effect is observed asynchronously.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestOutlierDetectorRecoversOnFakeClockSweep(t *testing.T) {
+	clock := newFakeClock()
+	od := NewOutlierDetectorWithClock(&OutlierDetectionConfig{
+		Consecutive5xx:          1,
+		Interval:                10 * time.Second,
+		BaseEjectionTime:        30 * time.Second,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      100,
+		EnforcingConsecutive5xx: 100,
+	}, clock)
+	od.Start()
+	defer od.Stop()
+	waitFor(t, "sweep ticker", func() bool { return clock.tickerCount() == 1 })
+
+	od.ReportResult("ep-a", errors.New("boom"), 503)
+	if !od.IsEjected("ep-a") {
+		t.Fatal("endpoint should be ejected after consecutive 5xx")
+	}
+
+	// Sweeps before the ejection time keep the endpoint out.
+	clock.Advance(20 * time.Second)
+	od.mu.RLock()
+	ejectionTime := od.endpoints["ep-a"].ejectionTime
+	od.mu.RUnlock()
+	if want := clock.Now().Add(10 * time.Second); !ejectionTime.Equal(want) {
+		t.Fatalf("ejection time = %v, want %v", ejectionTime, want)
+	}
+	if !od.IsEjected("ep-a") {
+		t.Fatal("endpoint recovered before its ejection time")
+	}
+
+	clock.Advance(20 * time.Second)
+	waitFor(t, "ejection recovery", func() bool { return !od.IsEjected("ep-a") })
+}
+
+func TestRateLimiterRefillsOnFakeClockTick(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(&RateLimiterConfig{
+		MaxTokens:     2,
+		TokensPerFill: 1,
+		FillInterval:  time.Second,
+	}, clock)
+	defer rl.Stop()
+	waitFor(t, "refill ticker", func() bool { return clock.tickerCount() == 1 })
+
+	if !rl.Allow() || !rl.Allow() || rl.Allow() {
+		t.Fatal("expected exactly two tokens before refill")
+	}
+	clock.Advance(time.Second)
+	waitFor(t, "token refill", func() bool { return rl.GetStats().CurrentTokens == 1 })
+	if !rl.Allow() || rl.Allow() {
+		t.Fatal("expected exactly one refilled token")
+	}
+}
+
+func TestEndpointCircuitBreakerHalfOpensOnFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewEndpointCircuitBreakerWithClock(&EndpointCircuitBreakerConfig{
+		FailureRateThreshold: 50,
+		RequestVolume:        2,
+		Interval:             time.Minute,
+		OpenDuration:         30 * time.Second,
+	}, clock)
+
+	for i := 0; i < 2; i++ {
+		if !cb.TryAcquire() {
+			t.Fatal("TryAcquire() = false while closed")
+		}
+		cb.Release(errEndpointFailure)
+	}
+	clock.Advance(29 * time.Second)
+	if cb.Available() {
+		t.Fatal("breaker available before its open duration elapsed")
+	}
+	clock.Advance(time.Second)
+	if !cb.TryAcquire() {
+		t.Fatal("breaker did not admit a half-open probe after the open duration")
+	}
+	cb.Release(nil)
+	if stats := cb.GetStats(); stats.State != EndpointCircuitClosed {
+		t.Fatalf("state after successful probe = %v, want CLOSED", stats.State)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+// EndpointIdentityOf returns the peer identity advertised for an xDS endpoint
+// in EDS metadata. The balancer dials through the client's security profile
+// and does not verify it.
+func EndpointIdentityOf(endpoint resolver.Endpoint) (*EndpointIdentity, bool) {
+	if endpoint == nil {
+		return nil, false
+	}
+	attributes := endpoint.GetAttributes()
+	identity, ok := attributes[xdsresource.AttributeEndpointIdentity].(*EndpointIdentity)
+	return identity, ok && identity != nil
+}
+
+// EndpointALPNOf returns the upstream ALPN list of the cluster owning an xDS
+// endpoint, in preference order.
+func EndpointALPNOf(endpoint resolver.Endpoint) ([]string, bool) {
+	if endpoint == nil {
+		return nil, false
+	}
+	alpn, ok := endpoint.GetAttributes()[xdsresource.AttributeEndpointALPN].([]string)
+	return alpn, ok && len(alpn) > 0
+}
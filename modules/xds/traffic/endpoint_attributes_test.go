@@ -0,0 +1,47 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+func TestEndpointAttributeAccessors(t *testing.T) {
+	identity := &EndpointIdentity{SPIFFEID: "spiffe://example.org/ns/default/sa/greeter"}
+	endpoint := resolver.BaseEndpoint{
+		Address: "10.0.0.1:8443",
+		Attributes: map[string]any{
+			xdsresource.AttributeEndpointIdentity: identity,
+			xdsresource.AttributeEndpointALPN:     []string{xdsresource.ALPNHTTP2},
+		},
+	}
+	if got, ok := EndpointIdentityOf(endpoint); !ok || got != identity {
+		t.Fatalf("EndpointIdentityOf() = %v, %v, want %v", got, ok, identity)
+	}
+	if got, ok := EndpointALPNOf(endpoint); !ok || len(got) != 1 || got[0] != "h2" {
+		t.Fatalf("EndpointALPNOf() = %v, %v, want [h2]", got, ok)
+	}
+
+	plain := resolver.BaseEndpoint{Address: "10.0.0.2:8080"}
+	if _, ok := EndpointIdentityOf(plain); ok {
+		t.Fatal("EndpointIdentityOf() ok = true for endpoint without identity")
+	}
+	if _, ok := EndpointALPNOf(plain); ok {
+		t.Fatal("EndpointALPNOf() ok = true for endpoint without ALPN")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// EndpointCircuitBreakerState is the state of a per-endpoint circuit breaker.
+type EndpointCircuitBreakerState int
+
+const (
+	// EndpointCircuitClosed lets requests through and tracks their error rate.
+	EndpointCircuitClosed EndpointCircuitBreakerState = iota
+	// EndpointCircuitOpen rejects requests until the open duration elapses.
+	EndpointCircuitOpen
+	// EndpointCircuitHalfOpen lets a single probe request through.
+	EndpointCircuitHalfOpen
+)
+
+func (s EndpointCircuitBreakerState) String() string {
+	switch s {
+	case EndpointCircuitClosed:
+		return "CLOSED"
+	case EndpointCircuitOpen:
+		return "OPEN"
+	case EndpointCircuitHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// EndpointCircuitBreaker trips a single endpoint on its own error rate. Unlike
+// the cluster CircuitBreaker, which caps concurrent resources, it isolates one
+// misbehaving address:port while its peers keep serving.
+type EndpointCircuitBreaker struct {
+	config *EndpointCircuitBreakerConfig
+	clock  Clock
+
+	mu          sync.Mutex
+	state       EndpointCircuitBreakerState
+	windowStart time.Time
+	requests    uint32
+	failures    uint32
+	openUntil   time.Time
+	probing     bool
+	rejected    uint64
+}
+
+// DefaultEndpointCircuitBreakerConfig returns default per-endpoint circuit breaker configuration.
+func DefaultEndpointCircuitBreakerConfig() *EndpointCircuitBreakerConfig {
+	return &EndpointCircuitBreakerConfig{
+		FailureRateThreshold: 50,
+		RequestVolume:        20,
+		Interval:             10 * time.Second,
+		OpenDuration:         30 * time.Second,
+	}
+}
+
+// NewEndpointCircuitBreaker creates a per-endpoint circuit breaker. Zero fields
+// fall back to DefaultEndpointCircuitBreakerConfig.
+func NewEndpointCircuitBreaker(config *EndpointCircuitBreakerConfig) *EndpointCircuitBreaker {
+	return NewEndpointCircuitBreakerWithClock(config, nil)
+}
+
+// NewEndpointCircuitBreakerWithClock creates a per-endpoint circuit breaker
+// whose windows and open duration follow clock; a nil clock uses the system
+// clock.
+func NewEndpointCircuitBreakerWithClock(
+	config *EndpointCircuitBreakerConfig,
+	clock Clock,
+) *EndpointCircuitBreaker {
+	defaults := DefaultEndpointCircuitBreakerConfig()
+	if config == nil {
+		config = defaults
+	}
+	merged := *config
+	if merged.FailureRateThreshold == 0 {
+		merged.FailureRateThreshold = defaults.FailureRateThreshold
+	}
+	if merged.RequestVolume == 0 {
+		merged.RequestVolume = defaults.RequestVolume
+	}
+	if merged.Interval == 0 {
+		merged.Interval = defaults.Interval
+	}
+	if merged.OpenDuration == 0 {
+		merged.OpenDuration = defaults.OpenDuration
+	}
+
+	clock = clockOrSystem(clock)
+	return &EndpointCircuitBreaker{
+		config:      &merged,
+		clock:       clock,
+		windowStart: clock.Now(),
+	}
+}
+
+// Available reports whether the breaker would let a request through, without
+// claiming the half-open probe.
+func (cb *EndpointCircuitBreaker) Available() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case EndpointCircuitOpen:
+		return !cb.clock.Now().Before(cb.openUntil)
+	case EndpointCircuitHalfOpen:
+		return !cb.probing
+	default:
+		return true
+	}
+}
+
+// TryAcquire admits a request. Once the open duration elapses the breaker
+// turns half-open and admits exactly one probe until its result is reported.
+func (cb *EndpointCircuitBreaker) TryAcquire() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == EndpointCircuitOpen && !cb.clock.Now().Before(cb.openUntil) {
+		cb.state = EndpointCircuitHalfOpen
+		cb.probing = false
+	}
+
+	switch cb.state {
+	case EndpointCircuitOpen:
+		cb.rejected++
+		return false
+	case EndpointCircuitHalfOpen:
+		if cb.probing {
+			cb.rejected++
+			return false
+		}
+		cb.probing = true
+	}
+	return true
+}
+
+// Release reports the result of a request admitted by TryAcquire.
+func (cb *EndpointCircuitBreaker) Release(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.clock.Now()
+	switch cb.state {
+	case EndpointCircuitHalfOpen:
+		cb.probing = false
+		if err != nil {
+			cb.openLocked(now)
+			return
+		}
+		cb.state = EndpointCircuitClosed
+		cb.resetWindowLocked(now)
+	case EndpointCircuitClosed:
+		if now.Sub(cb.windowStart) >= cb.config.Interval {
+			cb.resetWindowLocked(now)
+		}
+		cb.requests++
+		if err != nil {
+			cb.failures++
+		}
+		if cb.requests >= cb.config.RequestVolume &&
+			uint64(cb.failures)*100 >= uint64(cb.config.FailureRateThreshold)*uint64(cb.requests) {
+			cb.openLocked(now)
+		}
+	}
+}
+
+// Abandon returns a request admitted by TryAcquire that never reached the
+// endpoint, without counting it. A half-open breaker admits a new probe.
+func (cb *EndpointCircuitBreaker) Abandon() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == EndpointCircuitHalfOpen {
+		cb.probing = false
+	}
+}
+
+func (cb *EndpointCircuitBreaker) openLocked(now time.Time) {
+	slog.Debug("endpoint circuit breaker: opened",
+		slog.Uint64("requests", uint64(cb.requests)),
+		slog.Uint64("failures", uint64(cb.failures)))
+	cb.state = EndpointCircuitOpen
+	cb.openUntil = now.Add(cb.config.OpenDuration)
+	cb.resetWindowLocked(now)
+}
+
+func (cb *EndpointCircuitBreaker) resetWindowLocked(now time.Time) {
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+}
+
+// EndpointCircuitBreakerStats represents per-endpoint circuit breaker statistics
+type EndpointCircuitBreakerStats struct {
+	State            EndpointCircuitBreakerState
+	Requests         uint32
+	Failures         uint32
+	RejectedRequests uint64
+}
+
+// GetStats returns current per-endpoint circuit breaker statistics
+func (cb *EndpointCircuitBreaker) GetStats() EndpointCircuitBreakerStats {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return EndpointCircuitBreakerStats{
+		State:            cb.state,
+		Requests:         cb.requests,
+		Failures:         cb.failures,
+		RejectedRequests: cb.rejected,
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	randv2 "math/rand/v2"
+	"time"
+
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// injectFault applies a route's fault injection once an endpoint is picked: a
+// delayed RPC waits for the delay or its context, then an aborted RPC fails
+// with the configured status. It runs without the balancer lock. The client rewrites
+// codes it does not accept from a picker, such as NOT_FOUND, to INTERNAL.
+func injectFault(ctx context.Context, fault *FaultConfig) error {
+	if faultHit(fault.DelayPerMillion) && fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return status.New(code.Code_DEADLINE_EXCEEDED, "fault delay: "+ctx.Err().Error())
+			}
+			return status.New(code.Code_CANCELLED, "fault delay: "+ctx.Err().Error())
+		}
+	}
+	if !faultHit(fault.AbortPerMillion) {
+		return nil
+	}
+	abortCode := code.Code(fault.AbortGRPCStatus) //nolint:gosec // G115: gRPC codes are small.
+	if fault.AbortGRPCStatus == 0 {
+		abortCode = status.HTTPCodeToStuCode(int32(fault.AbortHTTPStatus)) //nolint:gosec // G115
+	}
+	return status.New(abortCode, "fault filter abort")
+}
+
+func faultHit(perMillion uint32) bool {
+	if perMillion == 0 {
+		return false
+	}
+	return perMillion >= 1000000 || randv2.Uint32N(1000000) < perMillion
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	"github.com/codesjoy/yggdrasil/v3/rpc/status"
+	remote "github.com/codesjoy/yggdrasil/v3/transport"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+func newFaultBalancer(t *testing.T, fault *FaultConfig) *xdsBalancer {
+	t.Helper()
+	return newFaultBalancerWithClient(t, fault, &recordingBalancerClient{})
+}
+
+func newFaultBalancerWithClient(
+	t *testing.T,
+	fault *FaultConfig,
+	cli *recordingBalancerClient,
+) *xdsBalancer {
+	t.Helper()
+	instance := newDeterministicBalancer(t, cli)
+	t.Cleanup(func() { _ = instance.Close() })
+
+	vhosts := testRoute("cluster-a", nil)
+	vhosts[0].Routes[0].Fault = fault
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{resolver.BaseEndpoint{
+			Address:  "10.0.0.1:8080",
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+			},
+		}},
+		vhosts,
+		map[string]clusterPolicy{"cluster-a": {LBPolicy: "round_robin"}},
+	))
+	return instance
+}
+
+func TestFaultAbortReturnsConfiguredCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault *FaultConfig
+		want  code.Code
+	}{
+		{
+			name:  "grpc status",
+			fault: &FaultConfig{AbortGRPCStatus: 14, AbortPerMillion: 1000000},
+			want:  code.Code_UNAVAILABLE,
+		},
+		{
+			name:  "http status",
+			fault: &FaultConfig{AbortHTTPStatus: 429, AbortPerMillion: 1000000},
+			want:  code.Code_RESOURCE_EXHAUSTED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newFaultBalancer(t, tt.fault)
+			for i := 0; i < 10; i++ {
+				_, err := instance.buildPicker().Next(balancer.RPCInfo{
+					Ctx:    context.Background(),
+					Method: "/svc/Method",
+				})
+				st, ok := status.CoverError(err)
+				if err == nil || !ok || st.Code() != tt.want {
+					t.Fatalf("pick %d error = %v, want status %v", i, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFaultDelayAddsLatency(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	instance := newFaultBalancer(t, &FaultConfig{Delay: delay, DelayPerMillion: 1000000})
+
+	start := time.Now()
+	result, err := instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	result.Report(nil)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("pick took %v, want at least the %v fault delay", elapsed, delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = instance.buildPicker().Next(balancer.RPCInfo{Ctx: ctx, Method: "/svc/Method"})
+	if st, _ := status.CoverError(err); err == nil || st.Code() != code.Code_DEADLINE_EXCEEDED {
+		t.Fatalf("delayed pick past its deadline error = %v, want DEADLINE_EXCEEDED", err)
+	}
+}
+
+func TestFaultInjectedOnlyAfterPick(t *testing.T) {
+	cli := &recordingBalancerClient{}
+	instance := newFaultBalancerWithClient(t, &FaultConfig{
+		AbortGRPCStatus: 14,
+		AbortPerMillion: 1000000,
+	}, cli)
+	cli.clients["10.0.0.1:8080"].state = remote.Connecting
+
+	// A pick waiting for the connection is retried by the client, so it must
+	// not be aborted yet.
+	_, err := instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if !errors.Is(err, balancer.ErrNoAvailableInstance) {
+		t.Fatalf("Next() error = %v, want ErrNoAvailableInstance before the fault", err)
+	}
+
+	cli.clients["10.0.0.1:8080"].state = remote.Ready
+	_, err = instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if st, _ := status.CoverError(err); err == nil || st.Code() != code.Code_UNAVAILABLE {
+		t.Fatalf("Next() error = %v, want the fault abort", err)
+	}
+
+	// The aborted pick gives back its in-flight slot without a result.
+	if got := loadCount(instance.inFlight["10.0.0.1:8080"]); got != 0 {
+		t.Fatalf("in-flight after abort = %d, want 0", got)
+	}
+}
+
+func TestFaultZeroPercentNeverFires(t *testing.T) {
+	instance := newFaultBalancer(t, &FaultConfig{
+		Delay:           time.Hour,
+		AbortGRPCStatus: 14,
+	})
+	result, err := instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if err != nil {
+		t.Fatalf("Next() error = %v, want no fault at 0%%", err)
+	}
+	result.Report(nil)
+}
@@ -17,6 +17,7 @@ package traffic
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 )
@@ -125,3 +126,210 @@ func TestOutlierDetectorPaths(t *testing.T) {
 		t.Fatal("shouldEnforce() returned unexpected values")
 	}
 }
+
+func TestOutlierEjectionBudgetExcludesEDSUnhealthyHosts(t *testing.T) {
+	od := NewOutlierDetector(&OutlierDetectionConfig{
+		Consecutive5xx:          1,
+		BaseEjectionTime:        time.Minute,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      50,
+		EnforcingConsecutive5xx: 100,
+	})
+	// Six hosts, two of which EDS already reports unhealthy: the budget is
+	// 50% of the four serving hosts.
+	od.UpdateHosts(map[string]HealthStatus{
+		"ep-a": HealthHealthy,
+		"ep-b": HealthHealthy,
+		"ep-c": HealthHealthy,
+		"ep-d": HealthDegraded,
+		"ep-e": HealthUnhealthy,
+		"ep-f": HealthDraining,
+	})
+
+	// Ejecting EDS-unhealthy hosts uses none of the budget.
+	od.ReportResult("ep-e", errors.New("boom"), 503)
+	od.ReportResult("ep-f", errors.New("boom"), 503)
+	if !od.IsEjected("ep-e") || !od.IsEjected("ep-f") {
+		t.Fatal("EDS-unhealthy hosts should still be ejected")
+	}
+	ejected, maxEjected, total, _ := od.ejectionBudget(&EndpointStats{address: "ep-a"})
+	if ejected != 0 || maxEjected != 2 || total != 4 {
+		t.Fatalf("ejectionBudget() = (%d, %d, %d), want (0, 2, 4)", ejected, maxEjected, total)
+	}
+
+	for _, address := range []string{"ep-a", "ep-b", "ep-c"} {
+		od.ReportResult(address, errors.New("boom"), 503)
+	}
+	if !od.IsEjected("ep-a") || !od.IsEjected("ep-b") {
+		t.Fatal("first two serving hosts should be ejected within the budget")
+	}
+	if od.IsEjected("ep-c") {
+		t.Fatal("third serving host should be kept by the 50% budget")
+	}
+
+	// Once EDS marks an ejected host unhealthy it no longer holds budget.
+	od.UpdateHosts(map[string]HealthStatus{
+		"ep-a": HealthUnhealthy,
+		"ep-b": HealthHealthy,
+		"ep-c": HealthHealthy,
+		"ep-d": HealthDegraded,
+		"ep-e": HealthUnhealthy,
+		"ep-f": HealthDraining,
+	})
+	od.ReportResult("ep-c", errors.New("boom"), 503)
+	if od.IsEjected("ep-c") {
+		t.Fatal("budget of 50% of three serving hosts is one ejection")
+	}
+	od.ReportResult("ep-d", errors.New("boom"), 503)
+	if od.IsEjected("ep-d") {
+		t.Fatal("ep-d should not be ejected while ep-b uses the whole budget")
+	}
+}
+
+func TestOutlierDetectorEjectAndRecoverCallbacks(t *testing.T) {
+	type event struct{ endpoint, reason string }
+	var ejected, recovered []event
+	clock := newFakeClock()
+	var od *OutlierDetector
+	od = NewOutlierDetectorWithClock(&OutlierDetectionConfig{
+		Consecutive5xx:          2,
+		BaseEjectionTime:        time.Minute,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      100,
+		EnforcingConsecutive5xx: 100,
+	}, clock)
+	// The hooks call back into the detector, which would deadlock if they ran
+	// under the endpoint lock.
+	OutlierHooks{
+		OnEject: func(cluster, endpoint, reason string) {
+			if cluster != "cluster-a" {
+				t.Errorf("OnEject cluster = %q, want cluster-a", cluster)
+			}
+			if !od.IsEjected(endpoint) {
+				t.Errorf("OnEject(%s) ran before the endpoint was ejected", endpoint)
+			}
+			ejected = append(ejected, event{endpoint, reason})
+		},
+		OnRecover: func(cluster, endpoint, reason string) {
+			if od.IsEjected(endpoint) {
+				t.Errorf("OnRecover(%s) ran while the endpoint was still ejected", endpoint)
+			}
+			recovered = append(recovered, event{endpoint, reason})
+		},
+	}.bind("cluster-a", od)
+
+	od.ReportResult("ep-a", nil, 500)
+	od.ReportResult("ep-b", nil, 200)
+	if len(ejected) != 0 {
+		t.Fatalf("OnEject fired before the threshold: %v", ejected)
+	}
+	od.ReportResult("ep-a", nil, 500)
+	if want := []event{{"ep-a", "consecutive_5xx"}}; !slices.Equal(ejected, want) {
+		t.Fatalf("OnEject events = %v, want %v", ejected, want)
+	}
+
+	od.performHealthSweep()
+	if len(recovered) != 0 {
+		t.Fatalf("OnRecover fired before the ejection time: %v", recovered)
+	}
+	clock.Advance(time.Minute + time.Second)
+	od.performHealthSweep()
+	want := []event{{"ep-a", recoverReasonEjectionElapsed}}
+	if !slices.Equal(recovered, want) {
+		t.Fatalf("OnRecover events = %v, want %v", recovered, want)
+	}
+}
+
+func TestOutlierStatsWindowKeepsDecisionsStable(t *testing.T) {
+	newDetector := func(window uint32) *OutlierDetector {
+		return NewOutlierDetectorWithClock(&OutlierDetectionConfig{
+			BaseEjectionTime:         time.Hour,
+			MaxEjectionTime:          time.Hour,
+			MaxEjectionPercent:       100,
+			EnforcingSuccessRate:     100,
+			SuccessRateMinimumHosts:  5,
+			SuccessRateRequestVolume: 100,
+			SuccessRateStdevFactor:   1900,
+			StatsWindowIntervals:     window,
+		}, newFakeClock())
+	}
+	// Steady traffic: 40 requests per interval to each endpoint, fewer than
+	// the request volume, with ep-e failing half of them.
+	interval := func(od *OutlierDetector) {
+		for i := 0; i < 40; i++ {
+			for _, address := range []string{"ep-a", "ep-b", "ep-c", "ep-d"} {
+				od.ReportResult(address, nil, 200)
+			}
+			status := 200
+			if i%2 == 0 {
+				status = 500
+			}
+			od.ReportResult("ep-e", nil, status)
+		}
+		od.performHealthSweep()
+	}
+
+	windowed := newDetector(3)
+	single := newDetector(1)
+	for round := 1; round <= 6; round++ {
+		interval(windowed)
+		interval(single)
+
+		// The window reaches the request volume on the third interval and
+		// keeps it from then on.
+		if got, want := windowed.IsEjected("ep-e"), round >= 3; got != want {
+			t.Fatalf("interval %d: windowed ep-e ejected = %v, want %v", round, got, want)
+		}
+		for _, address := range []string{"ep-a", "ep-b", "ep-c", "ep-d"} {
+			if windowed.IsEjected(address) || single.IsEjected(address) {
+				t.Fatalf("interval %d: healthy %s ejected", round, address)
+			}
+		}
+		if single.IsEjected("ep-e") {
+			t.Fatalf("interval %d: single-interval stats reached the request volume", round)
+		}
+	}
+
+	windowed.mu.RLock()
+	history := len(windowed.endpoints["ep-e"].history)
+	windowed.mu.RUnlock()
+	if history != 2 {
+		t.Fatalf("retained intervals = %d, want 2", history)
+	}
+}
+
+func TestOutlierMaxEjectionPercentAllowsOneEjection(t *testing.T) {
+	newDetector := func(percent uint32) *OutlierDetector {
+		od := NewOutlierDetector(&OutlierDetectionConfig{
+			Consecutive5xx:          1,
+			BaseEjectionTime:        time.Minute,
+			MaxEjectionTime:         time.Minute,
+			MaxEjectionPercent:      percent,
+			EnforcingConsecutive5xx: 100,
+		})
+		od.UpdateHosts(map[string]HealthStatus{
+			"ep-a": HealthHealthy,
+			"ep-b": HealthHealthy,
+			"ep-c": HealthHealthy,
+		})
+		return od
+	}
+
+	// 10% of three hosts floors to zero, but one ejection is still allowed.
+	od := newDetector(10)
+	for _, address := range []string{"ep-a", "ep-b", "ep-c"} {
+		od.ReportResult(address, errors.New("boom"), 503)
+	}
+	if !od.IsEjected("ep-a") {
+		t.Fatal("first failing host should be ejected at 10% of a 3-node cluster")
+	}
+	if od.IsEjected("ep-b") || od.IsEjected("ep-c") {
+		t.Fatal("only one host may be ejected at 10% of a 3-node cluster")
+	}
+
+	disabled := newDetector(0)
+	disabled.ReportResult("ep-a", errors.New("boom"), 503)
+	if disabled.IsEjected("ep-a") {
+		t.Fatal("a 0% max ejection percent should eject nothing")
+	}
+}
@@ -100,56 +100,43 @@ func endpointsMeetingVolume(endpoints []*EndpointStats, minimum uint32) []*Endpo
 func endpointTotalRequests(ep *EndpointStats) uint64 {
 	ep.mu.RLock()
 	defer ep.mu.RUnlock()
-	return atomic.LoadUint64(&ep.totalRequests)
+	return ep.windowCountsLocked().total
 }
 
 func successRate(ep *EndpointStats) float64 {
 	ep.mu.RLock()
 	defer ep.mu.RUnlock()
 
-	total := atomic.LoadUint64(&ep.totalRequests)
-	if total == 0 {
+	counts := ep.windowCountsLocked()
+	if counts.total == 0 {
 		return 0
 	}
-	success := atomic.LoadUint64(&ep.successCount)
-	return float64(success) / float64(total) * 100
+	return float64(counts.success) / float64(counts.total) * 100
 }
 
 func failurePercentage(ep *EndpointStats) float64 {
 	ep.mu.RLock()
 	defer ep.mu.RUnlock()
 
-	total := atomic.LoadUint64(&ep.totalRequests)
-	if total == 0 {
+	counts := ep.windowCountsLocked()
+	if counts.total == 0 {
 		return 0
 	}
-	failures := atomic.LoadUint64(&ep.failureCount)
-	return float64(failures) / float64(total) * 100
+	return float64(counts.failure) / float64(counts.total) * 100
 }
 
-// ejectEndpoint ejects an endpoint.
+// ejectEndpoint ejects an endpoint. It must be called without ep.mu held.
 func (od *OutlierDetector) ejectEndpoint(ep *EndpointStats, reason string) {
-	if ep.ejected {
+	ep.mu.RLock()
+	ejected := ep.ejected
+	ep.mu.RUnlock()
+	if ejected {
 		return
 	}
 
-	od.mu.RLock()
-	totalEndpoints := len(od.endpoints)
-	od.mu.RUnlock()
-
-	var ejectedCount int
-	od.mu.RLock()
-	for _, candidate := range od.endpoints {
-		candidate.mu.RLock()
-		if candidate.ejected {
-			ejectedCount++
-		}
-		candidate.mu.RUnlock()
-	}
-	od.mu.RUnlock()
-
-	maxEjected := int(float64(totalEndpoints) * float64(od.config.MaxEjectionPercent) / 100.0)
-	if ejectedCount >= maxEjected && maxEjected > 0 {
+	// The candidate counts towards the limit it is checked against.
+	ejectedCount, maxEjected, totalEndpoints, counted := od.ejectionBudget(ep)
+	if counted && ejectedCount+1 > maxEjected {
 		slog.Debug(
 			"max ejection percentage reached, not ejecting",
 			"endpoint", ep.address,
@@ -159,23 +146,78 @@ func (od *OutlierDetector) ejectEndpoint(ep *EndpointStats, reason string) {
 		return
 	}
 
+	ep.mu.Lock()
+	if ep.ejected {
+		ep.mu.Unlock()
+		return
+	}
 	ep.ejected = true
 	ep.ejectionCount++
+	ejectionCount := ep.ejectionCount
 
-	ejectionDuration := od.config.BaseEjectionTime * time.Duration(ep.ejectionCount)
+	ejectionDuration := od.config.BaseEjectionTime * time.Duration(ejectionCount)
 	if ejectionDuration > od.config.MaxEjectionTime {
 		ejectionDuration = od.config.MaxEjectionTime
 	}
-	ep.ejectionTime = time.Now().Add(ejectionDuration)
+	ep.ejectionTime = od.clock.Now().Add(ejectionDuration)
+	ep.mu.Unlock()
 	atomic.AddUint64(&od.totalEjections, 1)
 
 	slog.Warn(
 		"endpoint ejected",
 		"endpoint", ep.address,
 		"reason", reason,
-		"ejectionCount", ep.ejectionCount,
+		"ejectionCount", ejectionCount,
 		"ejectionDuration", ejectionDuration,
 	)
+	if od.onEject != nil {
+		od.onEject(ep.address, reason)
+	}
+}
+
+// ejectionBudget returns how many endpoints count as ejected, how many may be,
+// and the host count the limit is taken from. Once the EDS host set is known,
+// hosts EDS already reports unhealthy are excluded: they neither widen the
+// budget nor use it up when ejected. counted is false when ep itself is such
+// a host, since ejecting it takes no serving capacity away.
+func (od *OutlierDetector) ejectionBudget(
+	ep *EndpointStats,
+) (ejected, maxEjected, total int, counted bool) {
+	od.mu.RLock()
+	defer od.mu.RUnlock()
+
+	counts := func(address string) bool {
+		if od.hosts == nil {
+			return true
+		}
+		status, ok := od.hosts[address]
+		return ok && !status.unhealthy()
+	}
+
+	if od.hosts == nil {
+		total = len(od.endpoints)
+	} else {
+		for _, status := range od.hosts {
+			if !status.unhealthy() {
+				total++
+			}
+		}
+	}
+	for address, candidate := range od.endpoints {
+		candidate.mu.RLock()
+		if candidate.ejected && counts(address) {
+			ejected++
+		}
+		candidate.mu.RUnlock()
+	}
+
+	// Like Envoy, a non-zero percentage always allows one ejection, so small
+	// clusters are not left without outlier detection.
+	maxEjected = int(float64(total) * float64(od.config.MaxEjectionPercent) / 100.0)
+	if od.config.MaxEjectionPercent > 0 {
+		maxEjected = max(maxEjected, 1)
+	}
+	return ejected, maxEjected, total, counts(ep.address)
 }
 
 // shouldEnforce determines if enforcement should happen based on percentage.
@@ -223,3 +265,27 @@ func (od *OutlierDetector) GetStats() map[string]interface{} {
 		"total_ejections": atomic.LoadUint64(&od.totalEjections),
 	}
 }
+
+// OutlierEndpointStats is the outlier detection state of one endpoint.
+type OutlierEndpointStats struct {
+	Ejected       bool
+	EjectionCount uint32
+}
+
+// GetEndpointStats returns the outlier detection state of each tracked
+// endpoint, keyed by address.
+func (od *OutlierDetector) GetEndpointStats() map[string]OutlierEndpointStats {
+	od.mu.RLock()
+	defer od.mu.RUnlock()
+
+	stats := make(map[string]OutlierEndpointStats, len(od.endpoints))
+	for address, ep := range od.endpoints {
+		ep.mu.RLock()
+		stats[address] = OutlierEndpointStats{
+			Ejected:       ep.ejected,
+			EjectionCount: ep.ejectionCount,
+		}
+		ep.mu.RUnlock()
+	}
+	return stats
+}
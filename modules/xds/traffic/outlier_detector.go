@@ -17,6 +17,7 @@ package traffic
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -58,6 +59,12 @@ func (h HealthStatus) String() string {
 	}
 }
 
+// unhealthy reports whether the control plane has taken the endpoint out of
+// service; degraded and unknown endpoints still serve traffic.
+func (h HealthStatus) unhealthy() bool {
+	return h == HealthUnhealthy || h == HealthDraining || h == HealthTimeout
+}
+
 // ParseHealthStatus parses a health status string.
 func ParseHealthStatus(s string) HealthStatus {
 	switch strings.ToUpper(s) {
@@ -109,6 +116,10 @@ type EndpointStats struct {
 	localFailures   uint64
 	gatewayFailures uint64
 
+	// history holds the counts of up to StatsWindowIntervals-1 earlier
+	// intervals, oldest first.
+	history []intervalCounts
+
 	consecutive5xx            uint32
 	consecutiveGatewayFailure uint32
 	consecutiveLocalFailure   uint32
@@ -120,21 +131,59 @@ type EndpointStats struct {
 	mu sync.RWMutex
 }
 
+// intervalCounts are the request counts of one finished interval.
+type intervalCounts struct {
+	total   uint64
+	success uint64
+	failure uint64
+}
+
+// windowCountsLocked returns the request counts of the current interval and
+// the retained earlier ones.
+func (ep *EndpointStats) windowCountsLocked() intervalCounts {
+	counts := intervalCounts{
+		total:   atomic.LoadUint64(&ep.totalRequests),
+		success: atomic.LoadUint64(&ep.successCount),
+		failure: atomic.LoadUint64(&ep.failureCount),
+	}
+	for _, past := range ep.history {
+		counts.total += past.total
+		counts.success += past.success
+		counts.failure += past.failure
+	}
+	return counts
+}
+
 // OutlierDetector implements error-rate based outlier detection.
 type OutlierDetector struct {
 	config    *OutlierDetectionConfig
 	endpoints map[string]*EndpointStats
-	mu        sync.RWMutex
+	// hosts is the cluster's EDS host set with its health status; nil until
+	// the balancer first reports it.
+	hosts map[string]HealthStatus
+	mu    sync.RWMutex
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	clock  Clock
+
+	// onEject and onRecover are the balancer's OutlierHooks bound to the
+	// cluster; they are set before Start.
+	onEject   func(endpoint, reason string)
+	onRecover func(endpoint, reason string)
 
 	totalEjections uint64
 }
 
 // NewOutlierDetector creates a new outlier detector.
 func NewOutlierDetector(config *OutlierDetectionConfig) *OutlierDetector {
+	return NewOutlierDetectorWithClock(config, nil)
+}
+
+// NewOutlierDetectorWithClock creates an outlier detector whose sweeps and
+// ejection times follow clock; a nil clock uses the system clock.
+func NewOutlierDetectorWithClock(config *OutlierDetectionConfig, clock Clock) *OutlierDetector {
 	if config == nil {
 		config = DefaultOutlierDetectionConfig()
 	}
@@ -145,6 +194,7 @@ func NewOutlierDetector(config *OutlierDetectionConfig) *OutlierDetector {
 		endpoints: make(map[string]*EndpointStats),
 		ctx:       ctx,
 		cancel:    cancel,
+		clock:     clockOrSystem(clock),
 	}
 }
 
@@ -169,14 +219,14 @@ func (od *OutlierDetector) Stop() {
 func (od *OutlierDetector) runHealthSweep() {
 	defer od.wg.Done()
 
-	ticker := time.NewTicker(od.config.Interval)
+	ticker := od.clock.NewTicker(od.config.Interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-od.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			od.performHealthSweep()
 		}
 	}
@@ -184,7 +234,7 @@ func (od *OutlierDetector) runHealthSweep() {
 
 func (od *OutlierDetector) performHealthSweep() {
 	endpoints := od.snapshotEndpoints()
-	od.recoverEndpoints(endpoints, time.Now())
+	od.recoverEndpoints(endpoints, od.clock.Now())
 	od.detectSuccessRateOutliers(endpoints)
 	od.detectFailurePercentageOutliers(endpoints)
 	od.resetIntervalStats(endpoints)
@@ -201,11 +251,17 @@ func (od *OutlierDetector) snapshotEndpoints() []*EndpointStats {
 	return endpoints
 }
 
+// recoverReasonEjectionElapsed is the recovery reason of an endpoint whose
+// ejection time has run out.
+const recoverReasonEjectionElapsed = "ejection_time_elapsed"
+
 func (od *OutlierDetector) recoverEndpoints(endpoints []*EndpointStats, now time.Time) {
+	var recovered []string
 	for _, ep := range endpoints {
 		ep.mu.Lock()
 		if ep.ejected && now.After(ep.ejectionTime) {
 			ep.ejected = false
+			recovered = append(recovered, ep.address)
 			slog.Info(
 				"endpoint recovered from ejection",
 				"endpoint",
@@ -216,11 +272,34 @@ func (od *OutlierDetector) recoverEndpoints(endpoints []*EndpointStats, now time
 		}
 		ep.mu.Unlock()
 	}
+
+	// Callbacks run after the endpoint lock is released, so they may call
+	// back into the detector.
+	if od.onRecover == nil {
+		return
+	}
+	for _, address := range recovered {
+		od.onRecover(address, recoverReasonEjectionElapsed)
+	}
 }
 
+// resetIntervalStats starts a new interval. With a stats window of several
+// intervals the finished one is kept, and the oldest dropped, so the next
+// sweep still sees the traffic of the whole window.
 func (od *OutlierDetector) resetIntervalStats(endpoints []*EndpointStats) {
+	keep := int(od.config.StatsWindowIntervals) - 1
 	for _, ep := range endpoints {
 		ep.mu.Lock()
+		if keep > 0 {
+			ep.history = append(ep.history, intervalCounts{
+				total:   ep.totalRequests,
+				success: ep.successCount,
+				failure: ep.failureCount,
+			})
+			if len(ep.history) > keep {
+				ep.history = slices.Delete(ep.history, 0, len(ep.history)-keep)
+			}
+		}
 		ep.totalRequests = 0
 		ep.successCount = 0
 		ep.failureCount = 0
@@ -230,6 +309,14 @@ func (od *OutlierDetector) resetIntervalStats(endpoints []*EndpointStats) {
 	}
 }
 
+// UpdateHosts replaces the host set the max ejection percentage applies to,
+// keyed by endpoint address with the health status reported by EDS.
+func (od *OutlierDetector) UpdateHosts(hosts map[string]HealthStatus) {
+	od.mu.Lock()
+	od.hosts = hosts
+	od.mu.Unlock()
+}
+
 // ReportResult reports request result for outlier detection.
 func (od *OutlierDetector) ReportResult(endpoint string, err error, statusCode int) {
 	ep := od.endpointStats(endpoint)
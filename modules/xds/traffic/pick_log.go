@@ -0,0 +1,143 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+)
+
+// Pick decisions recorded by the pick log.
+const (
+	pickDecisionPicked              = "picked"
+	pickDecisionNoRoute             = "no_route"
+	pickDecisionRateLimited         = "rate_limited"
+	pickDecisionFaultAbort          = "fault_abort"
+	pickDecisionCircuitOpen         = "circuit_open"
+	pickDecisionNoEndpoint          = "no_endpoint"
+	pickDecisionClusterEmpty        = "cluster_empty"
+	pickDecisionEndpointNotReady    = "endpoint_not_ready"
+	pickDecisionEndpointCircuitOpen = "endpoint_circuit_open"
+)
+
+// pickLogEntry collects what one pick saw and decided.
+type pickLogEntry struct {
+	path        string
+	virtualHost string
+	route       *xdsresource.Route
+	cluster     string
+	endpoint    string
+	decision    string
+}
+
+// pickLogger writes pick log entries at debug level, at most max per second.
+type pickLogger struct {
+	service string
+	max     int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	written     int
+	suppressed  int
+	now         func() time.Time
+}
+
+func newPickLogger(service string, cfg PickLogConfig) *pickLogger {
+	if !cfg.Enabled {
+		return nil
+	}
+	maxPerSecond := cfg.MaxPerSecond
+	if maxPerSecond <= 0 {
+		maxPerSecond = defaultPickLogMaxPerSecond
+	}
+	return &pickLogger{service: service, max: maxPerSecond, now: time.Now}
+}
+
+// log writes entry unless the logger is disabled or the budget for the
+// current second is spent. It is safe to call on a nil receiver.
+func (l *pickLogger) log(ctx context.Context, entry *pickLogEntry) {
+	if l == nil {
+		return
+	}
+	logger := slog.Default()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	suppressed, ok := l.allow()
+	if !ok {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("service", l.service),
+		slog.String("path", entry.path),
+		slog.String("virtual_host", entry.virtualHost),
+		slog.String("route", describeRoute(entry.route)),
+		slog.String("cluster", entry.cluster),
+		slog.String("endpoint", entry.endpoint),
+		slog.String("decision", entry.decision),
+	}
+	if suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", suppressed))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "xds pick", attrs...)
+}
+
+// allow reports whether an entry may be written now, along with the number of
+// entries dropped since the last written one.
+func (l *pickLogger) allow() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.written = 0
+	}
+	if l.written >= l.max {
+		l.suppressed++
+		return 0, false
+	}
+	l.written++
+	suppressed := l.suppressed
+	l.suppressed = 0
+	return suppressed, true
+}
+
+// describeRoute renders the match of a route as "<kind>:<value>".
+func describeRoute(route *xdsresource.Route) string {
+	if route == nil || route.Match == nil {
+		return ""
+	}
+	match := route.Match
+	switch {
+	case match.Path != "":
+		return "path:" + match.Path
+	case match.Prefix != "":
+		return "prefix:" + match.Prefix
+	case match.Suffix != "":
+		return "suffix:" + match.Suffix
+	case match.Contains != "":
+		return "contains:" + match.Contains
+	case match.Regex != nil:
+		return "regex:" + match.Regex.String()
+	default:
+		return "prefix:"
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+)
+
+func captureDebugLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestPickLogRecordsClusterAndEndpoint(t *testing.T) {
+	buf := captureDebugLog(t)
+
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+	instance.pickLog = newPickLogger("svc", PickLogConfig{Enabled: true})
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{resolver.BaseEndpoint{
+			Address:  "10.0.0.1:8080",
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+			},
+		}},
+		testRoute("cluster-a", nil),
+		map[string]clusterPolicy{"cluster-a": {LBPolicy: "round_robin"}},
+	))
+	defer instance.Close() //nolint:errcheck
+
+	result, err := instance.buildPicker().Next(balancer.RPCInfo{
+		Ctx:    context.Background(),
+		Method: "/svc/Method",
+	})
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	result.Report(nil)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("pick log is not one JSON record: %v\n%s", err, buf.String())
+	}
+	want := map[string]any{
+		"msg":          "xds pick",
+		"service":      "svc",
+		"path":         "/svc/Method",
+		"virtual_host": "default",
+		"route":        "prefix:/",
+		"cluster":      "cluster-a",
+		"endpoint":     "10.0.0.1:8080",
+		"decision":     pickDecisionPicked,
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("pick log %s = %v, want %v", key, entry[key], value)
+		}
+	}
+}
+
+func TestPickLoggerRateLimit(t *testing.T) {
+	buf := captureDebugLog(t)
+
+	now := time.Unix(100, 0)
+	logger := newPickLogger("svc", PickLogConfig{Enabled: true, MaxPerSecond: 2})
+	logger.now = func() time.Time { return now }
+
+	entry := &pickLogEntry{cluster: "cluster-a", decision: pickDecisionNoEndpoint}
+	for range 5 {
+		logger.log(context.Background(), entry)
+	}
+	if got := bytes.Count(buf.Bytes(), []byte("\n")); got != 2 {
+		t.Fatalf("entries in first second = %d, want 2", got)
+	}
+
+	now = now.Add(time.Second)
+	buf.Reset()
+	logger.log(context.Background(), entry)
+	if !bytes.Contains(buf.Bytes(), []byte(`"suppressed":3`)) {
+		t.Fatalf("next entry = %s, want suppressed count 3", buf.String())
+	}
+}
+
+func TestPickLoggerDisabled(t *testing.T) {
+	if logger := newPickLogger("svc", PickLogConfig{}); logger != nil {
+		t.Fatalf("newPickLogger() = %v, want nil when disabled", logger)
+	}
+	var logger *pickLogger
+	logger.log(context.Background(), &pickLogEntry{})
+}
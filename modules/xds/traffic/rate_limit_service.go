@@ -0,0 +1,132 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// defaultRateLimitServiceTimeout bounds one ShouldRateLimit call when
+// rate_limit_service.timeout is unset.
+const defaultRateLimitServiceTimeout = 100 * time.Millisecond
+
+// RateLimitServiceConfig points the balancer at an Envoy rate limit service
+// (envoy.service.ratelimit.v3) for clusters with global rate limiting.
+type RateLimitServiceConfig struct {
+	// Address is the gRPC target of the rate limit service, dialed in
+	// plaintext. Empty disables global rate limiting.
+	Address string `mapstructure:"address"`
+	// Timeout bounds each ShouldRateLimit call. On timeout or any other error
+	// the cluster's local rate limiter decides instead.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// rateLimitService asks an external rate limit service whether a pick is
+// within a cluster's global limit.
+type rateLimitService struct {
+	client  rlsv3.RateLimitServiceClient
+	conn    *grpc.ClientConn
+	timeout time.Duration
+}
+
+// newRateLimitService returns nil when no address is configured.
+func newRateLimitService(cfg RateLimitServiceConfig) (*rateLimitService, error) {
+	if cfg.Address == "" {
+		return nil, nil
+	}
+	conn, err := grpc.NewClient(cfg.Address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("dial rate limit service %s: %w", cfg.Address, err)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultRateLimitServiceTimeout
+	}
+	return &rateLimitService{
+		client:  rlsv3.NewRateLimitServiceClient(conn),
+		conn:    conn,
+		timeout: timeout,
+	}, nil
+}
+
+// overLimit sends one hit for the descriptor built from cfg and headers.
+func (s *rateLimitService) overLimit(
+	ctx context.Context,
+	cfg *GlobalRateLimitConfig,
+	headers map[string]string,
+) (bool, error) {
+	descriptor := rateLimitDescriptor(cfg, headers)
+	if descriptor == nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+		Domain:      cfg.Domain,
+		Descriptors: []*ratelimitcommon.RateLimitDescriptor{descriptor},
+		HitsAddend:  1,
+	})
+	if err != nil {
+		return false, err
+	}
+	switch resp.GetOverallCode() {
+	case rlsv3.RateLimitResponse_OK:
+		return false, nil
+	case rlsv3.RateLimitResponse_OVER_LIMIT:
+		return true, nil
+	default:
+		return false, errors.New("rate limit service returned " + resp.GetOverallCode().String())
+	}
+}
+
+func (s *rateLimitService) close() error {
+	return s.conn.Close()
+}
+
+// rateLimitDescriptor builds the descriptor of cfg for one request. Like
+// Envoy's request_headers action, a request missing one of the headers gets no
+// descriptor and is not limited.
+func rateLimitDescriptor(
+	cfg *GlobalRateLimitConfig,
+	headers map[string]string,
+) *ratelimitcommon.RateLimitDescriptor {
+	entries := make([]*ratelimitcommon.RateLimitDescriptor_Entry, 0, len(cfg.Entries))
+	for _, entry := range cfg.Entries {
+		value := entry.Value
+		if entry.Header != "" {
+			value = headers[strings.ToLower(entry.Header)]
+			if value == "" {
+				return nil
+			}
+		}
+		entries = append(entries,
+			&ratelimitcommon.RateLimitDescriptor_Entry{Key: entry.Key, Value: value})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return &ratelimitcommon.RateLimitDescriptor{Entries: entries}
+}
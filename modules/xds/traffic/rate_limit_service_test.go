@@ -0,0 +1,165 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+	rpcmetadata "github.com/codesjoy/yggdrasil/v3/rpc/metadata"
+	"github.com/codesjoy/yggdrasil/v3/transport/runtime/client/balancer"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+)
+
+type fakeRateLimitServer struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	mu       sync.Mutex
+	code     rlsv3.RateLimitResponse_Code
+	err      error
+	requests []*rlsv3.RateLimitRequest
+}
+
+func (s *fakeRateLimitServer) ShouldRateLimit(
+	_ context.Context,
+	req *rlsv3.RateLimitRequest,
+) (*rlsv3.RateLimitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &rlsv3.RateLimitResponse{OverallCode: s.code}, nil
+}
+
+func (s *fakeRateLimitServer) set(code rlsv3.RateLimitResponse_Code, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code, s.err = code, err
+}
+
+func startFakeRateLimitServer(t *testing.T) (*fakeRateLimitServer, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	fake := &fakeRateLimitServer{code: rlsv3.RateLimitResponse_OK}
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, fake)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return fake, lis.Addr().String()
+}
+
+func TestGlobalRateLimitOverLimitRejectsPick(t *testing.T) {
+	fake, address := startFakeRateLimitServer(t)
+	rls, err := newRateLimitService(RateLimitServiceConfig{Address: address, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("newRateLimitService() error = %v", err)
+	}
+
+	cli := &recordingBalancerClient{}
+	instance := newDeterministicBalancer(t, cli)
+	instance.rls = rls
+	defer instance.Close() //nolint:errcheck
+
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{resolver.BaseEndpoint{
+			Address:  "10.0.0.1:8080",
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+			},
+		}},
+		testRoute("cluster-a", nil),
+		map[string]clusterPolicy{"cluster-a": {
+			LBPolicy: "round_robin",
+			// The local bucket is empty: it only decides when the service fails.
+			RateLimiter: &RateLimiterConfig{
+				MaxTokens:     1,
+				TokensPerFill: 1,
+				FillInterval:  time.Hour,
+			},
+			GlobalRateLimit: &GlobalRateLimitConfig{
+				Domain: "orders",
+				Entries: []RateLimitDescriptorEntry{
+					{Key: "tenant", Header: "x-tenant"},
+					{Key: "service", Value: "svc"},
+				},
+			},
+		}},
+	))
+	instance.rateLimiters["cluster-a"].Allow()
+
+	pick := func() error {
+		ctx := rpcmetadata.WithOutContext(
+			context.Background(), rpcmetadata.Pairs("x-tenant", "acme"))
+		result, err := instance.buildPicker().Next(balancer.RPCInfo{Ctx: ctx, Method: "/svc/Method"})
+		if err == nil {
+			result.Report(nil)
+		}
+		return err
+	}
+
+	if err := pick(); err != nil {
+		t.Fatalf("pick with RLS OK error = %v, want the local limiter bypassed", err)
+	}
+
+	fake.set(rlsv3.RateLimitResponse_OVER_LIMIT, nil)
+	if err := pick(); !errors.Is(err, errRateLimitExceeded) {
+		t.Fatalf("pick with RLS OVER_LIMIT error = %v, want errRateLimitExceeded", err)
+	}
+
+	fake.set(rlsv3.RateLimitResponse_OK, errors.New("rls down"))
+	if err := pick(); !errors.Is(err, errRateLimitExceeded) {
+		t.Fatalf("pick with RLS error = %v, want the empty local bucket to reject", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.requests) != 3 {
+		t.Fatalf("RLS requests = %d, want 3", len(fake.requests))
+	}
+	req := fake.requests[0]
+	entries := req.GetDescriptors()[0].GetEntries()
+	if req.GetDomain() != "orders" || req.GetHitsAddend() != 1 || len(entries) != 2 ||
+		entries[0].GetKey() != "tenant" || entries[0].GetValue() != "acme" ||
+		entries[1].GetKey() != "service" || entries[1].GetValue() != "svc" {
+		t.Fatalf("RLS request = %v, want domain orders with tenant=acme, service=svc", req)
+	}
+}
+
+func TestRateLimitDescriptorSkipsRequestsWithoutHeader(t *testing.T) {
+	cfg := &GlobalRateLimitConfig{
+		Domain:  "orders",
+		Entries: []RateLimitDescriptorEntry{{Key: "tenant", Header: "X-Tenant"}},
+	}
+	if got := rateLimitDescriptor(cfg, map[string]string{}); got != nil {
+		t.Fatalf("rateLimitDescriptor(no header) = %v, want nil", got)
+	}
+	got := rateLimitDescriptor(cfg, map[string]string{"x-tenant": "acme"})
+	if got == nil || got.GetEntries()[0].GetValue() != "acme" {
+		t.Fatalf("rateLimitDescriptor() = %v, want tenant=acme", got)
+	}
+}
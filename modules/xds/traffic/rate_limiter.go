@@ -35,10 +35,17 @@ type RateLimiter struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	clock  Clock
 }
 
 // NewRateLimiter creates a new rate limiter with the given configuration
 func NewRateLimiter(config *RateLimiterConfig) *RateLimiter {
+	return NewRateLimiterWithClock(config, nil)
+}
+
+// NewRateLimiterWithClock creates a rate limiter that refills on ticks of
+// clock; a nil clock uses the system clock.
+func NewRateLimiterWithClock(config *RateLimiterConfig, clock Clock) *RateLimiter {
 	if config == nil {
 		config = &RateLimiterConfig{
 			MaxTokens:     1000,
@@ -48,13 +55,15 @@ func NewRateLimiter(config *RateLimiterConfig) *RateLimiter {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	clock = clockOrSystem(clock)
 
 	rl := &RateLimiter{
 		config:       config,
 		tokens:       config.MaxTokens,
-		lastFillTime: time.Now().UnixNano(),
+		lastFillTime: clock.Now().UnixNano(),
 		ctx:          ctx,
 		cancel:       cancel,
+		clock:        clock,
 	}
 
 	rl.wg.Add(1)
@@ -66,14 +75,14 @@ func NewRateLimiter(config *RateLimiterConfig) *RateLimiter {
 func (rl *RateLimiter) refillTokens() {
 	defer rl.wg.Done()
 
-	ticker := time.NewTicker(rl.config.FillInterval)
+	ticker := rl.clock.NewTicker(rl.config.FillInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-rl.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			rl.mu.Lock()
 			current := atomic.LoadUint32(&rl.tokens)
 			newTokens := current + rl.config.TokensPerFill
@@ -81,7 +90,7 @@ func (rl *RateLimiter) refillTokens() {
 				newTokens = rl.config.MaxTokens
 			}
 			atomic.StoreUint32(&rl.tokens, newTokens)
-			atomic.StoreInt64(&rl.lastFillTime, time.Now().UnixNano())
+			atomic.StoreInt64(&rl.lastFillTime, rl.clock.Now().UnixNano())
 			rl.mu.Unlock()
 		}
 	}
@@ -113,7 +122,7 @@ func (rl *RateLimiter) Wait(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(rl.config.FillInterval / 10):
+		case <-rl.clock.After(rl.config.FillInterval / 10):
 			continue
 		}
 	}
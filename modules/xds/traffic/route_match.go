@@ -0,0 +1,89 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net/url"
+	"strings"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+// RouteRequest describes a synthetic request for MatchRequest.
+// Header keys are expected in lowercase, as carried in outgoing metadata.
+// A query string in Path is matched together with Query.
+type RouteRequest struct {
+	Path    string
+	Headers map[string]string
+	Query   map[string]string
+}
+
+// RouteDecision is the route and cluster a request would be sent to.
+type RouteDecision struct {
+	VirtualHost string
+	Route       *Route
+	// Cluster is the route's cluster, empty for a weighted cluster action.
+	Cluster string
+	// WeightedClusters lists the candidates of a weighted cluster action. The
+	// picker draws one of them per RPC, re-drawing among the others when the
+	// drawn cluster has no available endpoint.
+	WeightedClusters []WeightedCluster
+}
+
+// MatchRequest dry-runs the picker's route and cluster selection for req
+// against the resolver state of one xDS service without performing an RPC.
+// Unlike the picker, which routes RPC paths without a query string, it also
+// checks query parameter rules. It reports false when no route or cluster
+// matches.
+func MatchRequest(state resolver.State, req RouteRequest) (RouteDecision, bool) {
+	if state == nil {
+		return RouteDecision{}, false
+	}
+	attributes := state.GetAttributes()
+	vhosts, _ := attributes[xdsresource.AttributeRoutes].([]*xdsresource.VirtualHost)
+	scoped, _ := attributes[xdsresource.AttributeScopedRoutes].([]*xdsresource.ScopedRouteTable)
+	vhosts = xdsresource.RequestVirtualHosts(vhosts, scoped, req.Headers)
+
+	path, query := requestQuery(req)
+	vhost, route := xdsresource.FindRoute(vhosts, path, req.Headers, query)
+	if route == nil || route.Action == nil {
+		return RouteDecision{}, false
+	}
+
+	decision := RouteDecision{VirtualHost: vhost.Name, Route: route}
+	if weighted := route.Action.WeightedClusters; weighted != nil && len(weighted.Clusters) > 0 {
+		for _, cluster := range weighted.Clusters {
+			decision.WeightedClusters = append(decision.WeightedClusters, *cluster)
+		}
+		return decision, true
+	}
+	decision.Cluster = route.Action.Cluster
+	return decision, decision.Cluster != ""
+}
+
+// requestQuery splits a query string off req.Path and merges it with
+// req.Query, whose values win.
+func requestQuery(req RouteRequest) (string, url.Values) {
+	path, rawQuery, _ := strings.Cut(req.Path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		query = url.Values{}
+	}
+	for key, value := range req.Query {
+		query.Set(key, value)
+	}
+	return path, query
+}
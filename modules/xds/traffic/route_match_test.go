@@ -0,0 +1,143 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"slices"
+	"testing"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+func canaryRouteState() resolver.State {
+	return resolver.BaseState{
+		Attributes: map[string]any{
+			xdsresource.AttributeRoutes: []*xdsresource.VirtualHost{{
+				Name:    "greeter",
+				Domains: []string{"*"},
+				Routes: []*xdsresource.Route{
+					{
+						Match: &xdsresource.RouteMatch{
+							Prefix: "/",
+							Headers: []*xdsresource.HeaderMatcher{
+								{Name: "x-release", ExactMatch: "canary"},
+							},
+						},
+						Action: &xdsresource.RouteAction{Cluster: "canary-cluster"},
+					},
+					{
+						Match: &xdsresource.RouteMatch{
+							Prefix: "/",
+							Query: []*xdsresource.QueryParameterMatcher{
+								{Name: "debug", Present: true},
+							},
+						},
+						Action: &xdsresource.RouteAction{Cluster: "debug-cluster"},
+					},
+					{
+						Match:  &xdsresource.RouteMatch{Prefix: "/"},
+						Action: &xdsresource.RouteAction{Cluster: "stable-cluster"},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func TestMatchRequestSelectsCanaryCluster(t *testing.T) {
+	decision, ok := MatchRequest(canaryRouteState(), RouteRequest{
+		Path:    "/helloworld.Greeter/SayHello",
+		Headers: map[string]string{"x-release": "canary"},
+	})
+	if !ok {
+		t.Fatal("MatchRequest() ok = false, want true")
+	}
+	if decision.Cluster != "canary-cluster" {
+		t.Fatalf("Cluster = %q, want canary-cluster", decision.Cluster)
+	}
+	if decision.VirtualHost != "greeter" {
+		t.Fatalf("VirtualHost = %q, want greeter", decision.VirtualHost)
+	}
+	if decision.Route == nil || decision.Route.Action.Cluster != "canary-cluster" {
+		t.Fatalf("Route = %#v, want canary route", decision.Route)
+	}
+}
+
+func TestMatchRequestQueryAndFallback(t *testing.T) {
+	state := canaryRouteState()
+
+	decision, ok := MatchRequest(state, RouteRequest{
+		Path:  "/helloworld.Greeter/SayHello",
+		Query: map[string]string{"debug": "1"},
+	})
+	if !ok || decision.Cluster != "debug-cluster" {
+		t.Fatalf("query MatchRequest() = %#v, %v, want debug-cluster", decision, ok)
+	}
+
+	decision, ok = MatchRequest(state, RouteRequest{Path: "/helloworld.Greeter/SayHello?debug=1"})
+	if !ok || decision.Cluster != "debug-cluster" {
+		t.Fatalf("path query MatchRequest() = %#v, %v, want debug-cluster", decision, ok)
+	}
+
+	decision, ok = MatchRequest(state, RouteRequest{Path: "/helloworld.Greeter/SayHello"})
+	if !ok || decision.Cluster != "stable-cluster" {
+		t.Fatalf("fallback MatchRequest() = %#v, %v, want stable-cluster", decision, ok)
+	}
+
+	if _, ok := MatchRequest(resolver.BaseState{}, RouteRequest{Path: "/"}); ok {
+		t.Fatal("MatchRequest() without routes ok = true, want false")
+	}
+	if _, ok := MatchRequest(nil, RouteRequest{Path: "/"}); ok {
+		t.Fatal("MatchRequest(nil) ok = true, want false")
+	}
+}
+
+func TestMatchRequestReportsWeightedClusters(t *testing.T) {
+	state := resolver.BaseState{
+		Attributes: map[string]any{
+			xdsresource.AttributeRoutes: []*xdsresource.VirtualHost{{
+				Name:    "greeter",
+				Domains: []string{"*"},
+				Routes: []*xdsresource.Route{{
+					Match: &xdsresource.RouteMatch{Prefix: "/"},
+					Action: &xdsresource.RouteAction{
+						WeightedClusters: &xdsresource.WeightedClusters{
+							Clusters: []*xdsresource.WeightedCluster{
+								{Name: "stable-cluster", Weight: 90},
+								{Name: "canary-cluster", Weight: 10},
+							},
+							TotalWeight: 100,
+						},
+					},
+				}},
+			}},
+		},
+	}
+
+	for range 10 {
+		decision, ok := MatchRequest(state, RouteRequest{Path: "/helloworld.Greeter/SayHello"})
+		if !ok {
+			t.Fatal("MatchRequest() ok = false, want true")
+		}
+		want := []WeightedCluster{
+			{Name: "stable-cluster", Weight: 90},
+			{Name: "canary-cluster", Weight: 10},
+		}
+		if decision.Cluster != "" || !slices.Equal(decision.WeightedClusters, want) {
+			t.Fatalf("decision = %#v, want every weighted candidate", decision)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resolverdump"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+// liveBalancers tracks the balancers StatsHandler reports on, from
+// newXdsBalancer until Close.
+var liveBalancers = &balancerSet{balancers: make(map[*xdsBalancer]string)}
+
+type balancerSet struct {
+	mu        sync.Mutex
+	balancers map[*xdsBalancer]string
+}
+
+func (s *balancerSet) add(b *xdsBalancer, serviceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balancers[b] = serviceName
+}
+
+func (s *balancerSet) remove(b *xdsBalancer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.balancers, b)
+}
+
+func (s *balancerSet) snapshot() map[*xdsBalancer]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[*xdsBalancer]string, len(s.balancers))
+	for b, serviceName := range s.balancers {
+		out[b] = serviceName
+	}
+	return out
+}
+
+type serviceStatsJSON struct {
+	Service                 string                         `json:"service"`
+	Clusters                map[string]*clusterStatsJSON   `json:"clusters"`
+	EndpointCircuitBreakers map[string]endpointBreakerJSON `json:"endpoint_circuit_breakers"`
+	ResolverState           resolverdump.Snapshot          `json:"resolver_state"`
+}
+
+type clusterStatsJSON struct {
+	CircuitBreaker   *circuitBreakerJSON `json:"circuit_breaker,omitempty"`
+	OutlierDetection *outlierJSON        `json:"outlier_detection,omitempty"`
+	RateLimiter      *rateLimiterJSON    `json:"rate_limiter,omitempty"`
+}
+
+type circuitBreakerJSON struct {
+	ActiveConnections uint32 `json:"active_connections"`
+	PendingRequests   uint32 `json:"pending_requests"`
+	ActiveRequests    uint32 `json:"active_requests"`
+	ActiveRetries     uint32 `json:"active_retries"`
+	RejectedRequests  uint64 `json:"rejected_requests"`
+	RejectedRetries   uint64 `json:"rejected_retries"`
+}
+
+type outlierJSON struct {
+	TotalEndpoints int64                          `json:"total_endpoints"`
+	EjectedCount   int64                          `json:"ejected_count"`
+	TotalEjections int64                          `json:"total_ejections"`
+	Endpoints      map[string]outlierEndpointJSON `json:"endpoints"`
+}
+
+type outlierEndpointJSON struct {
+	Ejected       bool   `json:"ejected"`
+	EjectionCount uint32 `json:"ejection_count"`
+}
+
+type rateLimiterJSON struct {
+	CurrentTokens uint32 `json:"current_tokens"`
+	MaxTokens     uint32 `json:"max_tokens"`
+	AllowedCount  uint64 `json:"allowed_count"`
+	RejectedCount uint64 `json:"rejected_count"`
+}
+
+type endpointBreakerJSON struct {
+	State            string `json:"state"`
+	Requests         uint32 `json:"requests"`
+	Failures         uint32 `json:"failures"`
+	RejectedRequests uint64 `json:"rejected_requests"`
+}
+
+// StatsHandler returns a read-only HTTP handler that serves the current
+// BalancerStats and last resolver state of every live xDS balancer as JSON,
+// sorted by service. The
+// optional service query parameter restricts the output to one service.
+// Applications register it on their own mux, typically on an admin port.
+func StatsHandler() http.Handler {
+	return http.HandlerFunc(serveStats)
+}
+
+func serveStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	filter := r.URL.Query().Get("service")
+
+	out := make([]serviceStatsJSON, 0)
+	for b, serviceName := range liveBalancers.snapshot() {
+		if filter != "" && serviceName != filter {
+			continue
+		}
+		stats := newServiceStatsJSON(serviceName, b.GetStats())
+		stats.ResolverState = resolverdump.FromState(b.resolverState())
+		out = append(out, stats)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(out)
+}
+
+func (b *xdsBalancer) resolverState() resolver.State {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.state
+}
+
+func newServiceStatsJSON(serviceName string, stats BalancerStats) serviceStatsJSON {
+	clusters := make(map[string]*clusterStatsJSON)
+	cluster := func(name string) *clusterStatsJSON {
+		c, ok := clusters[name]
+		if !ok {
+			c = &clusterStatsJSON{}
+			clusters[name] = c
+		}
+		return c
+	}
+	for name, cb := range stats.CircuitBreakers {
+		cluster(name).CircuitBreaker = &circuitBreakerJSON{
+			ActiveConnections: cb.ActiveConnections,
+			PendingRequests:   cb.PendingRequests,
+			ActiveRequests:    cb.ActiveRequests,
+			ActiveRetries:     cb.ActiveRetries,
+			RejectedRequests:  cb.RejectedRequests,
+			RejectedRetries:   cb.RejectedRetries,
+		}
+	}
+	for name, od := range stats.OutlierDetectors {
+		endpoints := make(map[string]outlierEndpointJSON)
+		for address, ep := range stats.OutlierEndpoints[name] {
+			endpoints[address] = outlierEndpointJSON{
+				Ejected:       ep.Ejected,
+				EjectionCount: ep.EjectionCount,
+			}
+		}
+		cluster(name).OutlierDetection = &outlierJSON{
+			TotalEndpoints: statsInt(od["total_endpoints"]),
+			EjectedCount:   statsInt(od["ejected_count"]),
+			TotalEjections: statsInt(od["total_ejections"]),
+			Endpoints:      endpoints,
+		}
+	}
+	for name, rl := range stats.RateLimiters {
+		cluster(name).RateLimiter = &rateLimiterJSON{
+			CurrentTokens: rl.CurrentTokens,
+			MaxTokens:     rl.MaxTokens,
+			AllowedCount:  rl.AllowedCount,
+			RejectedCount: rl.RejectedCount,
+		}
+	}
+
+	breakers := make(map[string]endpointBreakerJSON, len(stats.EndpointCircuitBreakers))
+	for key, eb := range stats.EndpointCircuitBreakers {
+		breakers[key] = endpointBreakerJSON{
+			State:            eb.State.String(),
+			Requests:         eb.Requests,
+			Failures:         eb.Failures,
+			RejectedRequests: eb.RejectedRequests,
+		}
+	}
+	return serviceStatsJSON{
+		Service:                 serviceName,
+		Clusters:                clusters,
+		EndpointCircuitBreakers: breakers,
+	}
+}
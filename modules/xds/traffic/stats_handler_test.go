@@ -0,0 +1,140 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xdsresource "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/internal/resource"
+	"github.com/codesjoy/yggdrasil/v3/discovery/resolver"
+)
+
+func TestStatsHandlerReportsEjections(t *testing.T) {
+	b, err := newXdsBalancer("stats-handler-svc", "", &mockBalancerClient{})
+	if err != nil {
+		t.Fatalf("newXdsBalancer() error = %v", err)
+	}
+	instance := b.(*xdsBalancer)
+	defer func() { _ = instance.Close() }()
+
+	detector := NewOutlierDetector(&OutlierDetectionConfig{
+		Consecutive5xx:          1,
+		BaseEjectionTime:        time.Minute,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      100,
+		EnforcingConsecutive5xx: 100,
+	})
+	detector.ReportResult("10.0.0.1:8080", errors.New("unavailable"), 503)
+	detector.ReportResult("10.0.0.2:8080", nil, 200)
+	instance.outlierDetectors["cluster-a"] = detector
+
+	rec := httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/debug/xds/stats?service=stats-handler-svc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+
+	var got []serviceStatsJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+	if len(got) != 1 || got[0].Service != "stats-handler-svc" {
+		t.Fatalf("services = %+v, want only stats-handler-svc", got)
+	}
+	od := got[0].Clusters["cluster-a"].OutlierDetection
+	if od == nil {
+		t.Fatalf("cluster-a outlier stats missing from %s", rec.Body.String())
+	}
+	if od.EjectedCount != 1 || od.TotalEjections != 1 || od.TotalEndpoints != 2 {
+		t.Fatalf("outlier stats = %+v, want 1 ejected of 2", od)
+	}
+	if ep := od.Endpoints["10.0.0.1:8080"]; !ep.Ejected || ep.EjectionCount != 1 {
+		t.Fatalf("10.0.0.1:8080 = %+v, want ejected once", ep)
+	}
+	if ep := od.Endpoints["10.0.0.2:8080"]; ep.Ejected || ep.EjectionCount != 0 {
+		t.Fatalf("10.0.0.2:8080 = %+v, want not ejected", ep)
+	}
+}
+
+func TestStatsHandlerDropsClosedBalancers(t *testing.T) {
+	b, err := newXdsBalancer("stats-handler-closed", "", &mockBalancerClient{})
+	if err != nil {
+		t.Fatalf("newXdsBalancer() error = %v", err)
+	}
+	_ = b.Close()
+
+	rec := httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/?service=stats-handler-closed", nil))
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Fatalf("body = %q, want empty list", body)
+	}
+
+	rec = httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestStatsHandlerReportsResolverState(t *testing.T) {
+	b, err := newXdsBalancer("stats-handler-state", "", &recordingBalancerClient{})
+	if err != nil {
+		t.Fatalf("newXdsBalancer() error = %v", err)
+	}
+	instance := b.(*xdsBalancer)
+	defer func() { _ = instance.Close() }()
+
+	instance.UpdateState(testState(
+		[]resolver.Endpoint{resolver.BaseEndpoint{
+			Address:  "10.0.0.1:8080",
+			Protocol: "grpc",
+			Attributes: map[string]any{
+				xdsresource.AttributeEndpointCluster: "cluster-a",
+				xdsresource.AttributeEndpointWeight:  uint32(80),
+			},
+		}},
+		testRoute("cluster-a", nil),
+		map[string]clusterPolicy{"cluster-a": {LBPolicy: "round_robin"}},
+	))
+
+	rec := httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/?service=stats-handler-state", nil))
+	var got []serviceStatsJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+	if len(got) != 1 || len(got[0].ResolverState.Endpoints) != 1 {
+		t.Fatalf("resolver state missing from %s", rec.Body.String())
+	}
+	endpoint := got[0].ResolverState.Endpoints[0]
+	if endpoint.Address != "10.0.0.1:8080" || endpoint.Weight == nil || *endpoint.Weight != 80 {
+		t.Fatalf("resolver endpoint = %+v, want 10.0.0.1:8080 with weight 80", endpoint)
+	}
+	cluster := endpoint.Attributes[xdsresource.AttributeEndpointCluster]
+	if cluster != "cluster-a" {
+		t.Fatalf("endpoint cluster = %v, want cluster-a", cluster)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const statsMeterName = "github.com/codesjoy/yggdrasil-ecosystem/modules/xds/v3/traffic"
+
+// StatsMetricsConfig controls exporting balancer stats as OpenTelemetry
+// metrics.
+type StatsMetricsConfig struct {
+	// Enabled registers observable instruments on the global meter provider.
+	// They are read on every collection cycle of its metric readers.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// statsInstruments are the observable instruments fed from BalancerStats.
+type statsInstruments struct {
+	outlierEjected         metric.Int64ObservableGauge
+	outlierEjections       metric.Int64ObservableCounter
+	circuitActiveRequests  metric.Int64ObservableGauge
+	circuitRejected        metric.Int64ObservableCounter
+	rateLimitAllowed       metric.Int64ObservableCounter
+	rateLimitRejected      metric.Int64ObservableCounter
+	endpointCircuitState   metric.Int64ObservableGauge
+	endpointCircuitRejects metric.Int64ObservableCounter
+}
+
+// startStatsMetrics registers b's stats on the global meter provider when
+// enabled. Failures are logged; the balancer works without metrics.
+func startStatsMetrics(cfg StatsMetricsConfig, serviceName string, b *xdsBalancer) {
+	if !cfg.Enabled {
+		return
+	}
+	reg, err := registerStatsMetrics(otel.GetMeterProvider(), serviceName, b)
+	if err != nil {
+		slog.Warn("register xds balancer stats metrics failed",
+			slog.String("service", serviceName), slog.Any("error", err))
+		return
+	}
+	b.statsMetrics = reg
+}
+
+// registerStatsMetrics registers a callback that reports b.GetStats() through
+// mp each time the meter's readers collect.
+func registerStatsMetrics(
+	mp metric.MeterProvider,
+	serviceName string,
+	b *xdsBalancer,
+) (metric.Registration, error) {
+	meter := mp.Meter(statsMeterName)
+	var ins statsInstruments
+	var errs []error
+	gauge := func(name, desc string) metric.Int64ObservableGauge {
+		g, err := meter.Int64ObservableGauge(name, metric.WithDescription(desc))
+		errs = append(errs, err)
+		return g
+	}
+	counter := func(name, desc string) metric.Int64ObservableCounter {
+		c, err := meter.Int64ObservableCounter(name, metric.WithDescription(desc))
+		errs = append(errs, err)
+		return c
+	}
+	ins.outlierEjected = gauge("xds.balancer.outlier.ejected",
+		"Endpoints currently ejected by outlier detection.")
+	ins.outlierEjections = counter("xds.balancer.outlier.ejections",
+		"Total outlier detection ejections.")
+	ins.circuitActiveRequests = gauge("xds.balancer.circuit_breaker.active_requests",
+		"Requests currently admitted by the cluster circuit breaker.")
+	ins.circuitRejected = counter("xds.balancer.circuit_breaker.rejected",
+		"Requests rejected by the cluster circuit breaker.")
+	ins.rateLimitAllowed = counter("xds.balancer.rate_limit.allowed",
+		"Requests allowed by the cluster rate limiter.")
+	ins.rateLimitRejected = counter("xds.balancer.rate_limit.rejected",
+		"Requests throttled by the cluster rate limiter.")
+	ins.endpointCircuitState = gauge("xds.balancer.endpoint_circuit_breaker.state",
+		"Endpoint circuit breaker state: 0 closed, 1 open, 2 half-open.")
+	ins.endpointCircuitRejects = counter("xds.balancer.endpoint_circuit_breaker.rejected",
+		"Requests rejected by the endpoint circuit breaker.")
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("create xds balancer stats instruments: %w", err)
+	}
+
+	return meter.RegisterCallback(
+		func(_ context.Context, o metric.Observer) error {
+			ins.observe(o, serviceName, b.GetStats())
+			return nil
+		},
+		ins.outlierEjected,
+		ins.outlierEjections,
+		ins.circuitActiveRequests,
+		ins.circuitRejected,
+		ins.rateLimitAllowed,
+		ins.rateLimitRejected,
+		ins.endpointCircuitState,
+		ins.endpointCircuitRejects,
+	)
+}
+
+func (ins *statsInstruments) observe(o metric.Observer, serviceName string, stats BalancerStats) {
+	service := attribute.String("service", serviceName)
+	clusterAttrs := func(cluster string) metric.ObserveOption {
+		return metric.WithAttributes(service, attribute.String("cluster", cluster))
+	}
+
+	for cluster, od := range stats.OutlierDetectors {
+		opt := clusterAttrs(cluster)
+		o.ObserveInt64(ins.outlierEjected, statsInt(od["ejected_count"]), opt)
+		o.ObserveInt64(ins.outlierEjections, statsInt(od["total_ejections"]), opt)
+	}
+	for cluster, cb := range stats.CircuitBreakers {
+		opt := clusterAttrs(cluster)
+		o.ObserveInt64(ins.circuitActiveRequests, int64(cb.ActiveRequests), opt)
+		o.ObserveInt64(ins.circuitRejected, int64(cb.RejectedRequests), opt) //nolint:gosec
+	}
+	for cluster, rl := range stats.RateLimiters {
+		opt := clusterAttrs(cluster)
+		o.ObserveInt64(ins.rateLimitAllowed, int64(rl.AllowedCount), opt)   //nolint:gosec
+		o.ObserveInt64(ins.rateLimitRejected, int64(rl.RejectedCount), opt) //nolint:gosec
+	}
+	for endpoint, cb := range stats.EndpointCircuitBreakers {
+		opt := metric.WithAttributes(service, attribute.String("endpoint", endpoint))
+		o.ObserveInt64(ins.endpointCircuitState, int64(cb.State), opt)
+		o.ObserveInt64(ins.endpointCircuitRejects, int64(cb.RejectedRequests), opt) //nolint:gosec
+	}
+}
+
+// statsInt converts an OutlierDetector.GetStats value to int64.
+func statsInt(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case uint64:
+		return int64(n) //nolint:gosec // counters stay far below math.MaxInt64.
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The codesjoy Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestStatsMetricsReportOutlierEjections(t *testing.T) {
+	b, err := newXdsBalancer("svc", "", &mockBalancerClient{})
+	if err != nil {
+		t.Fatalf("newXdsBalancer() error = %v", err)
+	}
+	instance := b.(*xdsBalancer)
+
+	detector := NewOutlierDetector(&OutlierDetectionConfig{
+		Consecutive5xx:          1,
+		BaseEjectionTime:        time.Minute,
+		MaxEjectionTime:         time.Minute,
+		MaxEjectionPercent:      100,
+		EnforcingConsecutive5xx: 100,
+	})
+	detector.ReportResult("10.0.0.1:8080", errors.New("unavailable"), 503)
+	detector.ReportResult("10.0.0.2:8080", nil, 200)
+	instance.outlierDetectors["cluster-a"] = detector
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	reg, err := registerStatsMetrics(provider, "svc", instance)
+	if err != nil {
+		t.Fatalf("registerStatsMetrics() error = %v", err)
+	}
+	defer func() { _ = reg.Unregister() }()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	gauge, ok := findStatsMetric(rm, "xds.balancer.outlier.ejected").(metricdata.Gauge[int64])
+	if !ok {
+		t.Fatalf("outlier ejected gauge missing from %+v", rm)
+	}
+	if len(gauge.DataPoints) != 1 {
+		t.Fatalf("ejected gauge points = %d, want 1", len(gauge.DataPoints))
+	}
+	point := gauge.DataPoints[0]
+	if point.Value != 1 {
+		t.Fatalf("ejected gauge = %d, want 1", point.Value)
+	}
+	if v, _ := point.Attributes.Value(attribute.Key("cluster")); v.AsString() != "cluster-a" {
+		t.Fatalf("cluster attribute = %q, want cluster-a", v.AsString())
+	}
+	if v, _ := point.Attributes.Value(attribute.Key("service")); v.AsString() != "svc" {
+		t.Fatalf("service attribute = %q, want svc", v.AsString())
+	}
+
+	sum, ok := findStatsMetric(rm, "xds.balancer.outlier.ejections").(metricdata.Sum[int64])
+	if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+		t.Fatalf("ejections counter = %+v, want 1", sum)
+	}
+}
+
+func TestStatsMetricsDisabledByDefault(t *testing.T) {
+	b, err := newXdsBalancer("svc", "", &mockBalancerClient{})
+	if err != nil {
+		t.Fatalf("newXdsBalancer() error = %v", err)
+	}
+	if b.(*xdsBalancer).statsMetrics != nil {
+		t.Fatal("stats metrics registered without stats_metrics.enabled")
+	}
+}
+
+func findStatsMetric(rm metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	return nil
+}
@@ -24,13 +24,29 @@ import (
 type (
 	// CircuitBreakerConfig holds circuit breaker configuration.
 	CircuitBreakerConfig = xdsresource.CircuitBreakerConfig
+	// EndpointCircuitBreakerConfig holds per-endpoint circuit breaker configuration.
+	EndpointCircuitBreakerConfig = xdsresource.EndpointCircuitBreakerConfig
 	// OutlierDetectionConfig holds outlier detection configuration.
 	OutlierDetectionConfig = xdsresource.OutlierDetectionConfig
 	// RateLimiterConfig holds rate limiter configuration.
 	RateLimiterConfig = xdsresource.RateLimiterConfig
+	// GlobalRateLimitConfig holds a cluster's rate limit service descriptor.
+	GlobalRateLimitConfig = xdsresource.GlobalRateLimitConfig
+	// RateLimitDescriptorEntry is one entry of a rate limit descriptor.
+	RateLimitDescriptorEntry = xdsresource.RateLimitDescriptorEntry
+	// FaultConfig holds the client-side fault injection of a route.
+	FaultConfig = xdsresource.FaultConfig
+	// EndpointIdentity is the peer identity an xDS endpoint must present over TLS.
+	EndpointIdentity = xdsresource.EndpointIdentity
+	// Route is one xDS route with its match rules and action.
+	Route = xdsresource.Route
+	// WeightedCluster is one cluster of a weighted cluster action.
+	WeightedCluster = xdsresource.WeightedCluster
 
 	clusterPolicy    = xdsresource.ClusterPolicy
 	weightedEndpoint = xdsresource.WeightedEndpoint
 )
 
 var errRateLimitExceeded = errors.New("rate limit exceeded")
+
+var errClusterEmpty = errors.New("cluster has no endpoints")